@@ -0,0 +1,219 @@
+// The gRPC API of the user log, served by internal/rpc.
+// The Go stubs next to it are generated in this directory with:
+//   protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative userlog.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: userlog.proto
+
+package userlogv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	UserLog_ListMembers_FullMethodName     = "/userlog.v1.UserLog/ListMembers"
+	UserLog_GetHistory_FullMethodName      = "/userlog.v1.UserLog/GetHistory"
+	UserLog_SubscribeEvents_FullMethodName = "/userlog.v1.UserLog/SubscribeEvents"
+)
+
+// UserLogClient is the client API for UserLog service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// UserLog serves the members and history of the tracked guilds.
+// Every call needs the token in the authorization metadata, like "Bearer your-token".
+type UserLogClient interface {
+	// ListMembers returns the current members of a guild, ordered by user ID
+	ListMembers(ctx context.Context, in *ListMembersRequest, opts ...grpc.CallOption) (*ListMembersResponse, error)
+	// GetHistory returns history events of a guild, newest first, or every event of one member, oldest first
+	GetHistory(ctx context.Context, in *GetHistoryRequest, opts ...grpc.CallOption) (*GetHistoryResponse, error)
+	// SubscribeEvents streams events as they happen, until the call is canceled.
+	// Events are dropped for subscribers that don't keep up.
+	SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type userLogClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUserLogClient(cc grpc.ClientConnInterface) UserLogClient {
+	return &userLogClient{cc}
+}
+
+func (c *userLogClient) ListMembers(ctx context.Context, in *ListMembersRequest, opts ...grpc.CallOption) (*ListMembersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMembersResponse)
+	err := c.cc.Invoke(ctx, UserLog_ListMembers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userLogClient) GetHistory(ctx context.Context, in *GetHistoryRequest, opts ...grpc.CallOption) (*GetHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetHistoryResponse)
+	err := c.cc.Invoke(ctx, UserLog_GetHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userLogClient) SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &UserLog_ServiceDesc.Streams[0], UserLog_SubscribeEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserLog_SubscribeEventsClient = grpc.ServerStreamingClient[Event]
+
+// UserLogServer is the server API for UserLog service.
+// All implementations must embed UnimplementedUserLogServer
+// for forward compatibility.
+//
+// UserLog serves the members and history of the tracked guilds.
+// Every call needs the token in the authorization metadata, like "Bearer your-token".
+type UserLogServer interface {
+	// ListMembers returns the current members of a guild, ordered by user ID
+	ListMembers(context.Context, *ListMembersRequest) (*ListMembersResponse, error)
+	// GetHistory returns history events of a guild, newest first, or every event of one member, oldest first
+	GetHistory(context.Context, *GetHistoryRequest) (*GetHistoryResponse, error)
+	// SubscribeEvents streams events as they happen, until the call is canceled.
+	// Events are dropped for subscribers that don't keep up.
+	SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedUserLogServer()
+}
+
+// UnimplementedUserLogServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedUserLogServer struct{}
+
+func (UnimplementedUserLogServer) ListMembers(context.Context, *ListMembersRequest) (*ListMembersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMembers not implemented")
+}
+func (UnimplementedUserLogServer) GetHistory(context.Context, *GetHistoryRequest) (*GetHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHistory not implemented")
+}
+func (UnimplementedUserLogServer) SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeEvents not implemented")
+}
+func (UnimplementedUserLogServer) mustEmbedUnimplementedUserLogServer() {}
+func (UnimplementedUserLogServer) testEmbeddedByValue()                 {}
+
+// UnsafeUserLogServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UserLogServer will
+// result in compilation errors.
+type UnsafeUserLogServer interface {
+	mustEmbedUnimplementedUserLogServer()
+}
+
+func RegisterUserLogServer(s grpc.ServiceRegistrar, srv UserLogServer) {
+	// If the following call pancis, it indicates UnimplementedUserLogServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&UserLog_ServiceDesc, srv)
+}
+
+func _UserLog_ListMembers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMembersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserLogServer).ListMembers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserLog_ListMembers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserLogServer).ListMembers(ctx, req.(*ListMembersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserLog_GetHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserLogServer).GetHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserLog_GetHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserLogServer).GetHistory(ctx, req.(*GetHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserLog_SubscribeEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UserLogServer).SubscribeEvents(m, &grpc.GenericServerStream[SubscribeEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserLog_SubscribeEventsServer = grpc.ServerStreamingServer[Event]
+
+// UserLog_ServiceDesc is the grpc.ServiceDesc for UserLog service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var UserLog_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "userlog.v1.UserLog",
+	HandlerType: (*UserLogServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListMembers",
+			Handler:    _UserLog_ListMembers_Handler,
+		},
+		{
+			MethodName: "GetHistory",
+			Handler:    _UserLog_GetHistory_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeEvents",
+			Handler:       _UserLog_SubscribeEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "userlog.proto",
+}
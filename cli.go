@@ -0,0 +1,424 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/avatars"
+	"go.albinodrought/discord-user-log/internal/bot"
+	"go.albinodrought/discord-user-log/internal/feed"
+	"go.albinodrought/discord-user-log/internal/importer"
+	"go.albinodrought/discord-user-log/internal/leader"
+	"go.albinodrought/discord-user-log/internal/report"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func runCommand(cfg *config, st *store.Store, command string, args []string) {
+	if cfg.Tenants && command != "tenants" {
+		// the other commands are about the data of one tenant
+		tenant := findTenant(st, cfg.Tenant)
+		tenantStore, err := store.OpenTenant(cfg.StatePath, cfg.DBKey, tenant.Name)
+		if err != nil {
+			log.Fatalf("failed to open the database of tenant '%v': %v", tenant.Name, err)
+		}
+		defer tenantStore.Close()
+		cfg, st = tenantConfig(cfg, tenant), tenantStore
+	}
+	switch command {
+	case "tenants":
+		runTenants(cfg, st, args)
+	case "forget":
+		if len(args) != 1 {
+			log.Fatal("usage: forget <discord-id>")
+		}
+		affected, err := st.Forget(args[0])
+		if err != nil {
+			log.Fatalf("failed to forget '%v': %v", args[0], err)
+		}
+		if affected == 0 {
+			log.Printf("nothing stored for '%v'", args[0])
+		}
+		if cfg.AvatarArchive != "" {
+			if err := avatars.New(cfg.AvatarArchive).Forget(args[0]); err != nil {
+				log.Fatalf("failed to forget the archived avatars of '%v': %v", args[0], err)
+			}
+		}
+	case "import":
+		importMembers(st, args)
+	case "webhooks":
+		runWebhooks(cfg, st, args)
+	case "report":
+		writeReport(cfg, st, args)
+	case "replay":
+		runReplay(cfg, args)
+	case "diff":
+		writeDiff(st, args)
+	default:
+		log.Fatalf("unknown command '%v'", command)
+	}
+}
+
+// importMembers merges members exported by another bot or installation into the members table
+func importMembers(st *store.Store, args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	path := flags.String("file", "", "CSV or JSON file of members to import")
+	format := flags.String("format", "", "csv or json, guessed from the file extension by default")
+	guildID := flags.String("guild", "", "guild of members without a guild ID column")
+	flags.Parse(args)
+	if *path == "" {
+		log.Fatal("usage: import --file <members.csv|members.json> [--format csv|json] [--guild <guild-id>]")
+	}
+	if *format == "" {
+		var err error
+		if *format, err = importer.Format(*path); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		log.Fatalf("failed to open '%v': %v", *path, err)
+	}
+	defer file.Close()
+	records, err := importer.Read(file, *format)
+	if err != nil {
+		log.Fatalf("failed to read '%v': %v", *path, err)
+	}
+	result, err := importer.Import(st, records, *guildID)
+	if err != nil {
+		log.Fatalf("failed to import '%v': %v", *path, err)
+	}
+	log.Printf("imported %v members from '%v': %v added, %v updated, %v unchanged", len(records), *path, result.Added, result.Updated, result.Unchanged)
+}
+
+// writeDiff writes who joined and left a guild since its member list of some days ago as CSV to stdout,
+// comparing with the members the bot last stored
+func writeDiff(st *store.Store, args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	guildID := flags.String("guild", "", "guild to compare")
+	days := flags.Int("days", 7, "how many days back the member list to compare with is")
+	flags.Parse(args)
+	if *guildID == "" || *days < 1 || *days > store.MemberListDays {
+		log.Fatalf("usage: diff --guild <guild-id> [--days 1-%v]", store.MemberListDays)
+	}
+
+	before, day, ok, err := st.MemberList(*guildID, time.Now().AddDate(0, 0, -*days))
+	if err != nil {
+		log.Fatalf("failed to load the member list of guild '%v': %v", *guildID, err)
+	}
+	if !ok {
+		log.Fatalf("there is no member list of guild '%v' from %v days ago", *guildID, *days)
+	}
+	current, err := st.Members(*guildID)
+	if err != nil {
+		log.Fatalf("failed to load the members of guild '%v': %v", *guildID, err)
+	}
+	diff := bot.DiffMembers(before, current)
+	os.Stdout.Write(bot.DiffCSV(diff, current))
+	log.Printf("compared with the member list of %v: %v joined, %v left", day.Format("2006-01-02"), len(diff.Joined), len(diff.Left))
+}
+
+// writeReport writes the HTML report of the configured guilds for the period up to now, guilds are named by ID since Discord isn't asked
+func writeReport(cfg *config, st *store.Store, args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	period := flags.String("period", report.Monthly, "daily, weekly, or monthly")
+	out := flags.String("out", "", "file to write the HTML report to")
+	flags.Parse(args)
+	if *out == "" || report.ValidateSchedule(*period) != nil {
+		log.Fatal("usage: report --out <report.html> [--period daily|weekly|monthly]")
+	}
+
+	location, err := cfg.reportLocation()
+	if err != nil {
+		log.Fatal(err)
+	}
+	until := time.Now().In(location)
+	since := report.Previous(until, *period)
+	summaries := []report.Summary{}
+	for _, guild := range cfg.Guilds {
+		summary, err := report.Summarize(st, guild.ID, guild.ID, since)
+		if err != nil {
+			log.Fatalf("failed to summarize guild '%v': %v", guild.ID, err)
+		}
+		summaries = append(summaries, summary)
+	}
+	page, err := report.RenderHTML(*period, summaries, since, until)
+	if err != nil {
+		log.Fatalf("failed to render the report: %v", err)
+	}
+	if err := os.WriteFile(*out, []byte(page), 0644); err != nil {
+		log.Fatalf("failed to write '%v': %v", *out, err)
+	}
+	log.Printf("wrote the %v report of %v guilds to '%v'", *period, len(summaries), *out)
+}
+
+// runWebhooks lists the deliveries that failed every attempt, or redelivers them with the configured secrets
+func runWebhooks(cfg *config, st *store.Store, args []string) {
+	const usage = "usage: webhooks list|retry"
+	if len(args) != 1 {
+		log.Fatal(usage)
+	}
+	failures, err := st.WebhookFailures()
+	if err != nil {
+		log.Fatalf("failed to load failed webhook deliveries: %v", err)
+	}
+
+	switch args[0] {
+	case "list":
+		for _, failure := range failures {
+			fmt.Printf("%v\t%v\t%v\t%v\t%v attempts\t%v\n", failure.ID, failure.At.Format(time.RFC3339), failure.URL, failure.Event, failure.Attempts, failure.Error)
+		}
+		log.Printf("%v failed deliveries", len(failures))
+	case "retry":
+		webhooks := map[string]*feed.Webhook{}
+		for _, webhook := range cfg.Webhooks {
+			webhooks[webhook.URL] = feed.NewWebhook(webhook.URL, webhook.Secret, webhook.Events, st)
+		}
+		delivered := 0
+		for _, failure := range failures {
+			webhook, ok := webhooks[failure.URL]
+			if !ok {
+				log.Printf("skipped delivery %v, %v isn't configured anymore", failure.ID, failure.URL)
+				continue
+			}
+			if _, err := webhook.Deliver(failure.Event, []byte(failure.Payload)); err != nil {
+				log.Printf("failed to redeliver %v to %v: %v", failure.ID, failure.URL, err)
+				continue
+			}
+			if err := st.DeleteWebhookFailure(failure.ID); err != nil {
+				log.Fatalf("failed to delete redelivered delivery %v: %v", failure.ID, err)
+			}
+			delivered++
+		}
+		log.Printf("redelivered %v of %v failed deliveries", delivered, len(failures))
+	default:
+		log.Fatal(usage)
+	}
+}
+
+// runMigrateStore copies every member, event, setting, and note of one database into another, empty one,
+// like moving from SQLite to Postgres, and verifies the copy
+func runMigrateStore(cfg *config, args []string) {
+	flags := flag.NewFlagSet("migrate-store", flag.ExitOnError)
+	from := flags.String("from", "", "database to copy, sqlite:<path> or a postgres:// URL")
+	to := flags.String("to", "", "empty database to copy to, sqlite:<path> or a postgres:// URL")
+	key := flags.String("key", cfg.DBKey, "SQLCipher key of the SQLite databases, DUL_DB_KEY by default")
+	flags.Parse(args)
+	if *from == "" || *to == "" {
+		log.Fatal("usage: migrate-store --from sqlite:<path>|postgres://... --to sqlite:<path>|postgres://... [--key <key>]")
+	}
+
+	source := openStoreArg(*from, *key)
+	defer source.Close()
+	// the leader lease of SQLite databases shows whether the bot is still writing to it
+	if holder, held, err := source.LeaseHolder(leader.LeaseName, time.Now()); err != nil {
+		log.Fatalf("failed to read the leader lease of %v: %v", store.Redact(*from), err)
+	} else if held {
+		log.Fatalf("'%v' holds the leader lease of %v, stop it before copying", holder, store.Redact(*from))
+	}
+	destination := openStoreArg(*to, *key)
+	defer destination.Close()
+
+	copied, err := store.Copy(source, destination)
+	if err != nil {
+		log.Fatalf("failed to copy %v to %v: %v", store.Redact(*from), store.Redact(*to), err)
+	}
+	var rows int64
+	for _, table := range copied {
+		rows += table.Rows
+	}
+	log.Printf("copied and verified %v rows of %v tables from %v to %v", rows, len(copied), store.Redact(*from), store.Redact(*to))
+}
+
+// openStoreArg opens a database given as sqlite:<path>, a plain path, or a postgres:// URL, key only applies to SQLite
+func openStoreArg(arg, key string) *store.Store {
+	path := strings.TrimPrefix(arg, "sqlite:")
+	if store.IsPostgres(path) {
+		key = ""
+	}
+	st, err := store.OpenEncrypted(path, key)
+	if err != nil {
+		log.Fatalf("failed to open the database at %v: %v", store.Redact(arg), err)
+	}
+	return st
+}
+
+// runMigrate shows, applies, or reverts schema migrations
+func runMigrate(statePath, key string, args []string) {
+	const usage = "usage: migrate status|up|down [steps]"
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+	m, err := store.OpenEncryptedMigrator(statePath, key)
+	if err != nil {
+		log.Fatalf("failed to open the database at %v: %v", store.Redact(statePath), err)
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			log.Fatalf("failed to load migration status: %v", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			switch {
+			case status.Unknown:
+				state = "unknown"
+			case status.Changed:
+				state = "changed"
+			case status.Applied:
+				state = "applied"
+			}
+			fmt.Printf("%-8v %v\n", state, status.Name)
+		}
+	case "up":
+		applied, err := m.Up()
+		if err != nil {
+			log.Fatalf("failed to migrate: %v", err)
+		}
+		log.Printf("applied %v migrations", len(applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatal(usage)
+			}
+		}
+		reverted, err := m.Down(steps)
+		if err != nil {
+			log.Fatalf("failed to revert migrations after reverting %v: %v", reverted, err)
+		}
+		log.Printf("reverted %v migrations", len(reverted))
+	default:
+		log.Fatal(usage)
+	}
+}
+
+// findTenant looks up the tenant commands are about in tenant mode
+func findTenant(st *store.Store, name string) store.Tenant {
+	if name == "" {
+		log.Fatal("in tenant mode, name the tenant to run the command for with --tenant (DUL_TENANT)")
+	}
+	tenants, err := st.Tenants()
+	if err != nil {
+		log.Fatalf("failed to load the tenants: %v", err)
+	}
+	for _, tenant := range tenants {
+		if tenant.Name == name {
+			return tenant
+		}
+	}
+	log.Fatalf("there is no tenant '%v'", name)
+	return store.Tenant{}
+}
+
+// guildFlags collects repeated --guild <guild-id>:<channel-id> flags
+type guildFlags []store.TenantGuild
+
+func (g *guildFlags) String() string {
+	return fmt.Sprint(*g)
+}
+
+func (g *guildFlags) Set(value string) error {
+	id, channelID, ok := strings.Cut(value, ":")
+	if !ok || id == "" || channelID == "" {
+		return fmt.Errorf("expected <guild-id>:<channel-id>, got '%v'", value)
+	}
+	*g = append(*g, store.TenantGuild{ID: id, ChannelID: channelID})
+	return nil
+}
+
+// runTenants lists, adds, changes, and removes the tenants run in tenant mode.
+// Running instances apply the changes on SIGHUP or SIGUSR1.
+func runTenants(cfg *config, st *store.Store, args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: tenants list|set|remove|purge")
+	}
+	switch args[0] {
+	case "list":
+		tenants, err := st.Tenants()
+		if err != nil {
+			log.Fatalf("failed to load the tenants: %v", err)
+		}
+		for _, tenant := range tenants {
+			guilds := make([]string, len(tenant.Guilds))
+			for i, guild := range tenant.Guilds {
+				guilds[i] = guild.ID + ":" + guild.ChannelID
+			}
+			fmt.Printf("%v\towner %v\tguilds %v\tsince %v\n", tenant.Name, tenant.OwnerID, strings.Join(guilds, ","), tenant.CreatedAt.Format(time.DateOnly))
+		}
+	case "set":
+		flags := flag.NewFlagSet("tenants set", flag.ExitOnError)
+		name := flags.String("name", "", "name of the tenant, lowercase letters, digits, and underscores")
+		tokenFile := flags.String("token-file", "", "file containing the tenant's bot token")
+		ownerID := flags.String("owner", "", "user allowed to run /userlog reload-token, and told by DM when the bot isn't allowed to post")
+		var guilds guildFlags
+		flags.Var(&guilds, "guild", "guild to track and its announcement channel as <guild-id>:<channel-id>, repeatable")
+		flags.Parse(args[1:])
+		if !store.ValidTenantName(*name) || *tokenFile == "" || len(guilds) == 0 {
+			log.Fatal("usage: tenants set --name <name> --token-file <file> --guild <guild-id>:<channel-id> [--guild ...] [--owner <user-id>]")
+		}
+		token, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			log.Fatalf("failed to read the token: %v", err)
+		}
+		tenant := store.Tenant{
+			Name:      *name,
+			Token:     strings.TrimSpace(string(token)),
+			OwnerID:   *ownerID,
+			Guilds:    guilds,
+			CreatedAt: time.Now(),
+		}
+		if tenant.Token == "" {
+			log.Fatal("the token is empty")
+		}
+		if err := st.SaveTenant(tenant); err != nil {
+			log.Fatalf("failed to save tenant '%v': %v", tenant.Name, err)
+		}
+		log.Printf("saved tenant '%v', send SIGHUP to running instances to apply it", tenant.Name)
+	case "remove":
+		flags := flag.NewFlagSet("tenants remove", flag.ExitOnError)
+		name := flags.String("name", "", "name of the tenant")
+		flags.Parse(args[1:])
+		if *name == "" {
+			log.Fatal("usage: tenants remove --name <name>")
+		}
+		removed, err := st.RemoveTenant(*name)
+		if err != nil {
+			log.Fatalf("failed to remove tenant '%v': %v", *name, err)
+		}
+		if !removed {
+			log.Fatalf("there is no tenant '%v'", *name)
+		}
+		log.Printf("removed tenant '%v', send SIGHUP to running instances to stop its bot, its database is kept until purged", *name)
+	case "purge":
+		flags := flag.NewFlagSet("tenants purge", flag.ExitOnError)
+		name := flags.String("name", "", "name of the removed tenant")
+		flags.Parse(args[1:])
+		if *name == "" {
+			log.Fatal("usage: tenants purge --name <name>")
+		}
+		tenants, err := st.Tenants()
+		if err != nil {
+			log.Fatalf("failed to load the tenants: %v", err)
+		}
+		for _, tenant := range tenants {
+			if tenant.Name == *name {
+				log.Fatalf("tenant '%v' still exists, remove it and send SIGHUP to running instances first", *name)
+			}
+		}
+		if err := store.DropTenant(cfg.StatePath, *name); err != nil {
+			log.Fatalf("failed to delete the database of tenant '%v': %v", *name, err)
+		}
+		log.Printf("deleted the database of tenant '%v', its members, history, and settings", *name)
+	default:
+		log.Fatalf("unknown tenants command '%v', expected list, set, remove, or purge", args[0])
+	}
+}
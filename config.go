@@ -0,0 +1,1299 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/bot"
+	"go.albinodrought/discord-user-log/internal/filter"
+	"go.albinodrought/discord-user-log/internal/i18n"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/report"
+	"go.albinodrought/discord-user-log/internal/store"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	syncModeREST    = "rest"
+	syncModeGateway = "gateway"
+)
+
+// threadModeChannel posts announcements into the channel itself, the other thread modes are notify.ThreadMode*
+const threadModeChannel = "channel"
+
+type config struct {
+	Token        string `yaml:"token"`
+	StatePath    string `yaml:"state_path"`
+	DBKey        string `yaml:"db_key"`
+	SyncInterval string `yaml:"sync_interval"`
+	SyncMode     string `yaml:"sync_mode"`
+	// SyncSlices spreads scheduled syncs over this many intervals, each reconciling a range of members over REST, 0 or 1 syncs every member
+	SyncSlices int `yaml:"sync_slices"`
+	// ShardCount is how many gateway connections to use, 0 asks Discord
+	ShardCount int `yaml:"shard_count"`
+	// EventWorkers and EventQueueSize bound how many member events are handled at once and may wait, 0 uses the defaults
+	EventWorkers   int `yaml:"event_workers"`
+	EventQueueSize int `yaml:"event_queue_size"`
+	// LeaderLease enables leader election between instances sharing the database, a standby takes over this long after the leader stops renewing
+	LeaderLease string `yaml:"leader_lease"`
+	// Tenants runs a bot for every tenant in the database instead of the configured token and guilds, each with a database of its own
+	Tenants bool `yaml:"tenants"`
+	// Tenant is the tenant whose database commands use in tenant mode
+	Tenant string `yaml:"tenant"`
+	// DryRun logs announcements and role changes instead of making them, and turns off push notifications and reports
+	DryRun bool `yaml:"dry_run"`
+	// TrackFirstMessages records when members who join first post
+	TrackFirstMessages bool `yaml:"track_first_messages"`
+	// TrackScheduledEvents records which members RSVP to scheduled events
+	TrackScheduledEvents bool `yaml:"track_scheduled_events"`
+	// TrackInvites records which invite members joined with, it needs the Manage Server permission
+	TrackInvites bool `yaml:"track_invites"`
+	// TrackPresence records when members come online and go offline, it needs the privileged presence intent
+	TrackPresence    bool           `yaml:"track_presence"`
+	HistoryRetention string         `yaml:"history_retention"`
+	AnonymizeAfter   string         `yaml:"anonymize_after"`
+	CrossGuildWindow string         `yaml:"cross_guild_window"`
+	AvatarArchive    string         `yaml:"avatar_archive"`
+	Language         string         `yaml:"language"`
+	Timezone         string         `yaml:"timezone"`
+	Templates        templateConfig `yaml:"templates"`
+	Hook             string         `yaml:"hook"`
+	HookScript       string         `yaml:"hook_script"`
+	Filter           string         `yaml:"filter"`
+	IgnoredUsers     []string       `yaml:"ignored_users"`
+	// AnniversaryOptOut are users whose join anniversaries aren't announced
+	AnniversaryOptOut []string          `yaml:"anniversary_opt_out"`
+	Announce          []string          `yaml:"announce"`
+	Milestones        *milestoneConfig  `yaml:"milestones"`
+	QuietHours        *quietHoursConfig `yaml:"quiet_hours"`
+	AutoRoleID        string            `yaml:"autorole_id"`
+	WatchRoleID       string            `yaml:"watch_role_id"`
+	LeaveRoles        []string          `yaml:"leave_roles"`
+	EditLeaves        bool              `yaml:"edit_leaves"`
+	SyncSummary       int               `yaml:"sync_summary"`
+	ThreadMode        string            `yaml:"thread_mode"`
+	ThreadTimezone    string            `yaml:"thread_timezone"`
+	MassLeave         *massLeaveConfig  `yaml:"mass_leave"`
+	LeaveSurvey       *surveyConfig     `yaml:"leave_survey"`
+	QuickActions      []string          `yaml:"quick_actions"`
+	AlertChannelID    string            `yaml:"alert_channel_id"`
+	VoiceChannelID    string            `yaml:"voice_channel_id"`
+	FallbackChannelID string            `yaml:"fallback_channel_id"`
+	OwnerID           string            `yaml:"owner_id"`
+	Web               webConfig         `yaml:"web"`
+	GRPC              grpcConfig        `yaml:"grpc"`
+	Publish           publishConfig     `yaml:"publish"`
+	Webhooks          []webhookConfig   `yaml:"webhooks"`
+	// DisabledConsumers are the names of event consumers that receive nothing, see consumers
+	DisabledConsumers []string          `yaml:"disabled_consumers"`
+	ConsumerFilters   map[string]string `yaml:"consumer_filters"`
+	Push              pushConfig        `yaml:"push"`
+	Report            reportConfig      `yaml:"report"`
+	EventLog          string            `yaml:"event_log"`
+	EventLogMaxMB     int               `yaml:"event_log_max_mb"`
+	Presence          presenceConfig    `yaml:"presence"`
+	Maintenance       maintenanceConfig `yaml:"maintenance"`
+	Telemetry         telemetryConfig   `yaml:"telemetry"`
+	Guilds            []guildConfig     `yaml:"guilds"`
+}
+
+// templateConfig maps event types (join, leave, milestone) to templates
+type templateConfig map[string]string
+
+type milestoneConfig struct {
+	Every int   `yaml:"every"`
+	At    []int `yaml:"at"`
+}
+
+// quietHoursConfig defers announcements between two times of day like "01:00" and "08:00"
+type quietHoursConfig struct {
+	Start    string `yaml:"start"`
+	End      string `yaml:"end"`
+	Timezone string `yaml:"timezone"`
+}
+
+type guildConfig struct {
+	ID           string         `yaml:"id"`
+	ChannelID    string         `yaml:"channel_id"`
+	Language     string         `yaml:"language"`
+	Timezone     string         `yaml:"timezone"`
+	Templates    templateConfig `yaml:"templates"`
+	Hook         string         `yaml:"hook"`
+	HookScript   string         `yaml:"hook_script"`
+	Filter       string         `yaml:"filter"`
+	IgnoredUsers []string       `yaml:"ignored_users"`
+	// AnniversaryOptOut is added to the global list
+	AnniversaryOptOut []string          `yaml:"anniversary_opt_out"`
+	Announce          []string          `yaml:"announce"`
+	Milestones        *milestoneConfig  `yaml:"milestones"`
+	QuietHours        *quietHoursConfig `yaml:"quiet_hours"`
+	AutoRoleID        string            `yaml:"autorole_id"`
+	WatchRoleID       string            `yaml:"watch_role_id"`
+	LeaveRoles        []string          `yaml:"leave_roles"`
+	EditLeaves        *bool             `yaml:"edit_leaves"`
+	SyncSummary       *int              `yaml:"sync_summary"`
+	MassLeave         *massLeaveConfig  `yaml:"mass_leave"`
+	LeaveSurvey       *surveyConfig     `yaml:"leave_survey"`
+	QuickActions      []string          `yaml:"quick_actions"`
+	// ThreadMode is channel, thread, or forum, falling back to the global mode
+	ThreadMode     string `yaml:"thread_mode"`
+	ThreadTimezone string `yaml:"thread_timezone"`
+	// AlertChannelID receives moderator alerts, falling back to the announcement channel
+	AlertChannelID string `yaml:"alert_channel_id"`
+	// VoiceChannelID receives the voice log, falling back to the global voice log channel
+	VoiceChannelID string `yaml:"voice_channel_id"`
+	// FallbackChannelID receives what the bot isn't allowed to post to the other channels, falling back to the global fallback channel
+	FallbackChannelID string `yaml:"fallback_channel_id"`
+	// DashboardRoleID is required to view this guild's dashboard, falling back to the web role
+	DashboardRoleID string `yaml:"dashboard_role_id"`
+}
+
+// webConfig enables the dashboard when listen is set
+type webConfig struct {
+	Listen       string `yaml:"listen"`
+	BaseURL      string `yaml:"base_url"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// RoleID is required to view a guild's dashboard, unless the guild sets dashboard_role_id
+	RoleID string `yaml:"role_id"`
+	// EventsToken enables the /events stream and /feed.atom feed for clients that send it
+	EventsToken string `yaml:"events_token"`
+	// PublicStats serves /public/<guild ID> without logging in
+	PublicStats bool `yaml:"public_stats"`
+}
+
+// grpcConfig serves the gRPC API over TLS when listen is set
+type grpcConfig struct {
+	Listen   string `yaml:"listen"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// Token must be sent by clients as "Bearer <token>" authorization metadata
+	Token string `yaml:"token"`
+}
+
+// Names of the event consumers, which can be disabled
+const (
+	consumerEventStream = "event_stream"
+	consumerEventLog    = "event_log"
+	consumerNtfy        = "ntfy"
+	consumerPushover    = "pushover"
+	consumerMQTT        = "mqtt"
+	consumerNATS        = "nats"
+	consumerWebhooks    = "webhooks"
+	consumerGRPC        = "grpc"
+)
+
+var consumers = []string{consumerEventStream, consumerEventLog, consumerNtfy, consumerPushover, consumerMQTT, consumerNATS, consumerWebhooks, consumerGRPC}
+
+// publishConfig sends every event to message brokers, each broker is disabled without a URL
+type publishConfig struct {
+	MQTTURL     string `yaml:"mqtt_url"`
+	MQTTTopic   string `yaml:"mqtt_topic"`
+	NATSURL     string `yaml:"nats_url"`
+	NATSSubject string `yaml:"nats_subject"`
+}
+
+// webhookConfig posts events as signed JSON to an HTTP endpoint
+type webhookConfig struct {
+	URL string `yaml:"url"`
+	// Secret keys the HMAC-SHA256 signatures of the deliveries
+	Secret string `yaml:"secret"`
+	// Events are the event types posted, every event by default
+	Events []string `yaml:"events"`
+	// Filter is an expression limiting the events posted further
+	Filter string `yaml:"filter"`
+}
+
+// pushConfig forwards selected event types to phone push services, each service is disabled until configured
+type pushConfig struct {
+	Events        []string `yaml:"events"`
+	NtfyURL       string   `yaml:"ntfy_url"`
+	NtfyToken     string   `yaml:"ntfy_token"`
+	PushoverToken string   `yaml:"pushover_token"`
+	PushoverUser  string   `yaml:"pushover_user"`
+}
+
+// reportConfig emails activity reports of all guilds, it is disabled without an SMTP server
+type reportConfig struct {
+	// Schedule is daily, weekly, or monthly, reports are sent at midnight in Timezone
+	Schedule string `yaml:"schedule"`
+	Timezone string `yaml:"timezone"`
+	// SMTPAddr is like smtp.example.com:587
+	SMTPAddr     string   `yaml:"smtp_addr"`
+	SMTPUsername string   `yaml:"smtp_username"`
+	SMTPPassword string   `yaml:"smtp_password"`
+	From         string   `yaml:"from"`
+	To           []string `yaml:"to"`
+}
+
+// presenceConfig shows live stats as the bot's status
+type presenceConfig struct {
+	// Template is rendered with bot.PresenceData
+	Template string `yaml:"template"`
+	Interval string `yaml:"interval"`
+}
+
+// maintenanceConfig checks and compacts the database every day, it is disabled without a window
+type maintenanceConfig struct {
+	// Window is a range of times of day like "03:00-05:00" in the global timezone
+	Window string `yaml:"window"`
+	// ChannelID receives the problems found, empty only logs them
+	ChannelID string `yaml:"channel_id"`
+	// BackupDir receives a backup of the healthy database after every maintenance, the newest BackupKeep are kept
+	BackupDir  string `yaml:"backup_dir"`
+	BackupKeep int    `yaml:"backup_keep"`
+	// Recover restores the newest backup at startup if the database is missing or corrupt, or starts over with an empty one
+	Recover bool `yaml:"recover"`
+}
+
+// telemetryConfig exports traces and metrics to an OpenTelemetry collector, it is disabled without an endpoint
+type telemetryConfig struct {
+	// Endpoint is the OTLP/HTTP base URL like http://collector:4318
+	Endpoint string `yaml:"endpoint"`
+	// Headers are sent with every export, like an Authorization header
+	Headers map[string]string `yaml:"headers"`
+}
+
+// massLeaveConfig alerts when more than count members leave within window
+type massLeaveConfig struct {
+	Count  int    `yaml:"count"`
+	Window string `yaml:"window"`
+}
+
+// surveyConfig asks members who leave why, offering the reasons or translated defaults
+type surveyConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Reasons []string `yaml:"reasons"`
+}
+
+// loadConfig reads the config file at path (if any) and then applies environment variable overrides.
+func loadConfig(path string) (*config, error) {
+	cfg := &config{
+		StatePath:    "./dul.db",
+		SyncInterval: "12h",
+		SyncMode:     syncModeREST,
+		Announce:     []string{store.EventJoin, store.EventLeave},
+		Publish: publishConfig{
+			MQTTTopic:   "user-log",
+			NATSSubject: "user-log",
+		},
+		EventLogMaxMB: 100,
+		Push: pushConfig{
+			Events: []string{notify.EventMassLeave},
+		},
+		Report: reportConfig{
+			Schedule: report.Weekly,
+		},
+		Presence: presenceConfig{
+			Template: bot.DefaultPresence,
+			Interval: "5m",
+		},
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse %v: %w", path, err)
+		}
+	}
+
+	values, err := optionValues()
+	if err != nil {
+		return nil, err
+	}
+	getenv := func(env string) string { return values[env] }
+
+	if v := getenv("DUL_TOKEN"); v != "" {
+		cfg.Token = v
+	}
+	if v := getenv("DUL_STATE_PATH"); v != "" {
+		cfg.StatePath = v
+	}
+	if v := getenv("DUL_DB_KEY"); v != "" {
+		cfg.DBKey = v
+	}
+	if v := getenv("DUL_SYNC_INTERVAL"); v != "" {
+		cfg.SyncInterval = v
+	}
+	if v := getenv("DUL_SYNC_MODE"); v != "" {
+		cfg.SyncMode = v
+	}
+	if v := getenv("DUL_SYNC_SLICES"); v != "" {
+		syncSlices, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_SYNC_SLICES: %w", err)
+		}
+		cfg.SyncSlices = syncSlices
+	}
+	if v := getenv("DUL_SHARD_COUNT"); v != "" {
+		shardCount, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_SHARD_COUNT: %w", err)
+		}
+		cfg.ShardCount = shardCount
+	}
+	if v := getenv("DUL_EVENT_WORKERS"); v != "" {
+		eventWorkers, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_EVENT_WORKERS: %w", err)
+		}
+		cfg.EventWorkers = eventWorkers
+	}
+	if v := getenv("DUL_EVENT_QUEUE_SIZE"); v != "" {
+		eventQueueSize, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_EVENT_QUEUE_SIZE: %w", err)
+		}
+		cfg.EventQueueSize = eventQueueSize
+	}
+	if v := getenv("DUL_LEADER_LEASE"); v != "" {
+		cfg.LeaderLease = v
+	}
+	if v := getenv("DUL_TENANTS"); v != "" {
+		tenants, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_TENANTS: %w", err)
+		}
+		cfg.Tenants = tenants
+	}
+	if v := getenv("DUL_TENANT"); v != "" {
+		cfg.Tenant = v
+	}
+	if v := getenv("DUL_DRY_RUN"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_DRY_RUN: %w", err)
+		}
+		cfg.DryRun = dryRun
+	}
+	if v := getenv("DUL_BACKUP_KEEP"); v != "" {
+		backupKeep, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_BACKUP_KEEP: %w", err)
+		}
+		cfg.Maintenance.BackupKeep = backupKeep
+	}
+	if v := getenv("DUL_RECOVER_DB"); v != "" {
+		recoverDB, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_RECOVER_DB: %w", err)
+		}
+		cfg.Maintenance.Recover = recoverDB
+	}
+	if v := getenv("DUL_PUBLIC_STATS"); v != "" {
+		publicStats, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_PUBLIC_STATS: %w", err)
+		}
+		cfg.Web.PublicStats = publicStats
+	}
+	if v := getenv("DUL_TRACK_PRESENCE"); v != "" {
+		trackPresence, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_TRACK_PRESENCE: %w", err)
+		}
+		cfg.TrackPresence = trackPresence
+	}
+	if v := getenv("DUL_TRACK_FIRST_MESSAGES"); v != "" {
+		trackFirstMessages, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_TRACK_FIRST_MESSAGES: %w", err)
+		}
+		cfg.TrackFirstMessages = trackFirstMessages
+	}
+	if v := getenv("DUL_TRACK_SCHEDULED_EVENTS"); v != "" {
+		trackScheduledEvents, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_TRACK_SCHEDULED_EVENTS: %w", err)
+		}
+		cfg.TrackScheduledEvents = trackScheduledEvents
+	}
+	if v := getenv("DUL_TRACK_INVITES"); v != "" {
+		trackInvites, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_TRACK_INVITES: %w", err)
+		}
+		cfg.TrackInvites = trackInvites
+	}
+	if v := getenv("DUL_HISTORY_RETENTION"); v != "" {
+		cfg.HistoryRetention = v
+	}
+	if v := getenv("DUL_ANONYMIZE_AFTER"); v != "" {
+		cfg.AnonymizeAfter = v
+	}
+	if v := getenv("DUL_CROSS_GUILD_WINDOW"); v != "" {
+		cfg.CrossGuildWindow = v
+	}
+	if v := getenv("DUL_AVATAR_ARCHIVE"); v != "" {
+		cfg.AvatarArchive = v
+	}
+	if cfg.Templates == nil {
+		cfg.Templates = templateConfig{}
+	}
+	for eventType := range notify.DefaultTemplates {
+		if v := getenv("DUL_" + strings.ToUpper(eventType) + "_TEMPLATE"); v != "" {
+			cfg.Templates[eventType] = v
+		}
+	}
+	if v := getenv("DUL_MILESTONE_EVERY"); v != "" {
+		every, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_MILESTONE_EVERY: %w", err)
+		}
+		if cfg.Milestones == nil {
+			cfg.Milestones = &milestoneConfig{}
+		}
+		cfg.Milestones.Every = every
+	}
+	if v := getenv("DUL_MILESTONES"); v != "" {
+		if cfg.Milestones == nil {
+			cfg.Milestones = &milestoneConfig{}
+		}
+		cfg.Milestones.At = nil
+		for _, at := range strings.Split(v, ",") {
+			count, err := strconv.Atoi(strings.TrimSpace(at))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse DUL_MILESTONES: %w", err)
+			}
+			cfg.Milestones.At = append(cfg.Milestones.At, count)
+		}
+	}
+	if v := getenv("DUL_QUIET_HOURS"); v != "" {
+		start, end, ok := strings.Cut(v, "-")
+		if !ok {
+			return nil, errors.New("failed to parse DUL_QUIET_HOURS: expected a range like 01:00-08:00")
+		}
+		if cfg.QuietHours == nil {
+			cfg.QuietHours = &quietHoursConfig{}
+		}
+		cfg.QuietHours.Start = strings.TrimSpace(start)
+		cfg.QuietHours.End = strings.TrimSpace(end)
+	}
+	if v := getenv("DUL_QUIET_HOURS_TIMEZONE"); v != "" {
+		if cfg.QuietHours == nil {
+			cfg.QuietHours = &quietHoursConfig{}
+		}
+		cfg.QuietHours.Timezone = v
+	}
+	if v := getenv("DUL_EVENT_LOG"); v != "" {
+		cfg.EventLog = v
+	}
+	if v := getenv("DUL_EVENT_LOG_MAX_MB"); v != "" {
+		maxMB, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_EVENT_LOG_MAX_MB: %w", err)
+		}
+		cfg.EventLogMaxMB = maxMB
+	}
+	if v := getenv("DUL_MASS_LEAVE_COUNT"); v != "" {
+		count, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_MASS_LEAVE_COUNT: %w", err)
+		}
+		if cfg.MassLeave == nil {
+			cfg.MassLeave = &massLeaveConfig{}
+		}
+		cfg.MassLeave.Count = count
+	}
+	if v := getenv("DUL_MASS_LEAVE_WINDOW"); v != "" {
+		if cfg.MassLeave == nil {
+			cfg.MassLeave = &massLeaveConfig{}
+		}
+		cfg.MassLeave.Window = v
+	}
+	if v := getenv("DUL_LEAVE_SURVEY"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_LEAVE_SURVEY: %w", err)
+		}
+		if cfg.LeaveSurvey == nil {
+			cfg.LeaveSurvey = &surveyConfig{}
+		}
+		cfg.LeaveSurvey.Enabled = enabled
+	}
+	if v := getenv("DUL_LEAVE_SURVEY_REASONS"); v != "" {
+		if cfg.LeaveSurvey == nil {
+			cfg.LeaveSurvey = &surveyConfig{}
+		}
+		cfg.LeaveSurvey.Reasons = strings.Split(v, ",")
+	}
+	if v := getenv("DUL_ALERT_CHANNEL_ID"); v != "" {
+		cfg.AlertChannelID = v
+	}
+	if v := getenv("DUL_VOICE_CHANNEL_ID"); v != "" {
+		cfg.VoiceChannelID = v
+	}
+	if v := getenv("DUL_FALLBACK_CHANNEL_ID"); v != "" {
+		cfg.FallbackChannelID = v
+	}
+	if v := getenv("DUL_OWNER_ID"); v != "" {
+		cfg.OwnerID = v
+	}
+	for env, value := range map[string]*string{
+		"DUL_WEB_LISTEN":             &cfg.Web.Listen,
+		"DUL_GRPC_LISTEN":            &cfg.GRPC.Listen,
+		"DUL_GRPC_CERT_FILE":         &cfg.GRPC.CertFile,
+		"DUL_GRPC_KEY_FILE":          &cfg.GRPC.KeyFile,
+		"DUL_GRPC_TOKEN":             &cfg.GRPC.Token,
+		"DUL_WEB_BASE_URL":           &cfg.Web.BaseURL,
+		"DUL_WEB_CLIENT_ID":          &cfg.Web.ClientID,
+		"DUL_WEB_CLIENT_SECRET":      &cfg.Web.ClientSecret,
+		"DUL_WEB_ROLE_ID":            &cfg.Web.RoleID,
+		"DUL_WEB_EVENTS_TOKEN":       &cfg.Web.EventsToken,
+		"DUL_MQTT_URL":               &cfg.Publish.MQTTURL,
+		"DUL_MQTT_TOPIC":             &cfg.Publish.MQTTTopic,
+		"DUL_NATS_URL":               &cfg.Publish.NATSURL,
+		"DUL_NATS_SUBJECT":           &cfg.Publish.NATSSubject,
+		"DUL_NTFY_URL":               &cfg.Push.NtfyURL,
+		"DUL_NTFY_TOKEN":             &cfg.Push.NtfyToken,
+		"DUL_PUSHOVER_TOKEN":         &cfg.Push.PushoverToken,
+		"DUL_PUSHOVER_USER":          &cfg.Push.PushoverUser,
+		"DUL_REPORT_SCHEDULE":        &cfg.Report.Schedule,
+		"DUL_REPORT_TIMEZONE":        &cfg.Report.Timezone,
+		"DUL_REPORT_FROM":            &cfg.Report.From,
+		"DUL_SMTP_ADDR":              &cfg.Report.SMTPAddr,
+		"DUL_SMTP_USERNAME":          &cfg.Report.SMTPUsername,
+		"DUL_SMTP_PASSWORD":          &cfg.Report.SMTPPassword,
+		"DUL_PRESENCE_TEMPLATE":      &cfg.Presence.Template,
+		"DUL_PRESENCE_INTERVAL":      &cfg.Presence.Interval,
+		"DUL_TIMEZONE":               &cfg.Timezone,
+		"DUL_HOOK":                   &cfg.Hook,
+		"DUL_HOOK_SCRIPT":            &cfg.HookScript,
+		"DUL_FILTER":                 &cfg.Filter,
+		"DUL_MAINTENANCE_WINDOW":     &cfg.Maintenance.Window,
+		"DUL_MAINTENANCE_CHANNEL_ID": &cfg.Maintenance.ChannelID,
+		"DUL_BACKUP_DIR":             &cfg.Maintenance.BackupDir,
+		"DUL_TELEMETRY_ENDPOINT":     &cfg.Telemetry.Endpoint,
+	} {
+		if v := getenv(env); v != "" {
+			*value = v
+		}
+	}
+	if v := getenv("DUL_TELEMETRY_HEADERS"); v != "" {
+		cfg.Telemetry.Headers = map[string]string{}
+		for _, header := range strings.Split(v, ",") {
+			key, value, ok := strings.Cut(header, "=")
+			if !ok {
+				return nil, fmt.Errorf("failed to parse DUL_TELEMETRY_HEADERS: expected key=value, got '%v'", header)
+			}
+			cfg.Telemetry.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if v := getenv("DUL_EDIT_LEAVES"); v != "" {
+		editLeaves, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_EDIT_LEAVES: %w", err)
+		}
+		cfg.EditLeaves = editLeaves
+	}
+	if v := getenv("DUL_SYNC_SUMMARY"); v != "" {
+		syncSummary, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DUL_SYNC_SUMMARY: %w", err)
+		}
+		cfg.SyncSummary = syncSummary
+	}
+	if v := getenv("DUL_LANGUAGE"); v != "" {
+		cfg.Language = v
+	}
+	if v := getenv("DUL_THREAD_MODE"); v != "" {
+		cfg.ThreadMode = v
+	}
+	if v := getenv("DUL_THREAD_TIMEZONE"); v != "" {
+		cfg.ThreadTimezone = v
+	}
+	if v := getenv("DUL_AUTOROLE_ID"); v != "" {
+		cfg.AutoRoleID = v
+	}
+	if v := getenv("DUL_WATCH_ROLE_ID"); v != "" {
+		cfg.WatchRoleID = v
+	}
+	if v := getenv("DUL_ANNOUNCE"); v != "" {
+		cfg.Announce = strings.Split(v, ",")
+	}
+	if v := getenv("DUL_REPORT_TO"); v != "" {
+		cfg.Report.To = strings.Split(v, ",")
+	}
+	if v := getenv("DUL_DISABLED_CONSUMERS"); v != "" {
+		cfg.DisabledConsumers = strings.Split(v, ",")
+	}
+	for _, consumer := range consumers {
+		if v := getenv("DUL_" + strings.ToUpper(consumer) + "_FILTER"); v != "" {
+			if cfg.ConsumerFilters == nil {
+				cfg.ConsumerFilters = map[string]string{}
+			}
+			cfg.ConsumerFilters[consumer] = v
+		}
+	}
+	if v := getenv("DUL_PUSH_EVENTS"); v != "" {
+		cfg.Push.Events = strings.Split(v, ",")
+	}
+	if v := getenv("DUL_LEAVE_ROLES"); v != "" {
+		cfg.LeaveRoles = strings.Split(v, ",")
+	}
+	if v := getenv("DUL_QUICK_ACTIONS"); v != "" {
+		cfg.QuickActions = strings.Split(v, ",")
+	}
+	if v := getenv("DUL_IGNORED_USERS"); v != "" {
+		cfg.IgnoredUsers = strings.Split(v, ",")
+	}
+	if v := getenv("DUL_ANNIVERSARY_OPT_OUT"); v != "" {
+		cfg.AnniversaryOptOut = strings.Split(v, ",")
+	}
+	if v := getenv("DUL_WEBHOOK_URL"); v != "" {
+		// env configures a single webhook, replacing any from the file
+		webhook := webhookConfig{URL: v, Secret: getenv("DUL_WEBHOOK_SECRET"), Filter: getenv("DUL_WEBHOOK_FILTER")}
+		if events := getenv("DUL_WEBHOOK_EVENTS"); events != "" {
+			webhook.Events = strings.Split(events, ",")
+		}
+		cfg.Webhooks = []webhookConfig{webhook}
+	}
+	if guildID, channelID := getenv("DUL_GUILD_ID"), getenv("DUL_CHANNEL_ID"); guildID != "" || channelID != "" {
+		// env configures a single guild, replacing any from the file
+		cfg.Guilds = []guildConfig{{ID: guildID, ChannelID: channelID}}
+	}
+
+	return cfg, nil
+}
+
+// validate checks the options required to run the bot.
+// Commands that only touch the DB don't call this.
+func (cfg *config) validate() error {
+	if cfg.Tenants {
+		if err := cfg.validateTenantMode(); err != nil {
+			return err
+		}
+	} else if cfg.Token == "" {
+		return errors.New("require a token (DUL_TOKEN)")
+	} else if len(cfg.Guilds) == 0 {
+		return errors.New("require at least one guild (DUL_GUILD_ID, DUL_CHANNEL_ID)")
+	}
+	for _, guild := range cfg.Guilds {
+		if guild.ID == "" || guild.ChannelID == "" {
+			return errors.New("require an id and channel_id for every guild (DUL_GUILD_ID, DUL_CHANNEL_ID)")
+		}
+	}
+	if interval, err := parseDuration(cfg.SyncInterval); err != nil {
+		return fmt.Errorf("failed to parse sync interval: %w", err)
+	} else if interval <= 0 {
+		return errors.New("sync interval must be positive")
+	}
+	if cfg.LeaderLease != "" {
+		if lease, err := parseDuration(cfg.LeaderLease); err != nil {
+			return fmt.Errorf("failed to parse leader lease: %w", err)
+		} else if lease < 3*time.Second {
+			return errors.New("leader lease must be at least 3s")
+		}
+	}
+	if store.IsPostgres(cfg.StatePath) {
+		switch {
+		case cfg.DBKey != "":
+			return errors.New("the database key only works with SQLite, protect Postgres with TLS and disk encryption instead")
+		case cfg.Maintenance.Window != "":
+			return errors.New("the maintenance window only works with SQLite, Postgres runs autovacuum itself")
+		case cfg.Maintenance.BackupDir != "":
+			return errors.New("the backup dir only works with SQLite, back Postgres up with pg_dump instead")
+		case cfg.Maintenance.Recover:
+			return errors.New("recovering only works with SQLite, restore Postgres from a pg_dump backup instead")
+		}
+	}
+	if cfg.SyncMode != syncModeREST && cfg.SyncMode != syncModeGateway {
+		return fmt.Errorf("sync mode must be '%v' or '%v'", syncModeREST, syncModeGateway)
+	}
+	if _, err := parseDuration(cfg.HistoryRetention); err != nil {
+		return fmt.Errorf("failed to parse history retention: %w", err)
+	}
+	if _, err := parseDuration(cfg.AnonymizeAfter); err != nil {
+		return fmt.Errorf("failed to parse anonymization period: %w", err)
+	}
+	if window, err := parseDuration(cfg.CrossGuildWindow); err != nil {
+		return fmt.Errorf("failed to parse cross-guild window: %w", err)
+	} else if window < 0 {
+		return errors.New("cross-guild window can't be negative")
+	}
+	if _, err := cfg.timezoneFor(guildConfig{}); err != nil {
+		return err
+	}
+	if _, err := bot.ParsePresence(cfg.Presence.Template); err != nil {
+		return fmt.Errorf("failed to parse presence template: %w", err)
+	}
+	// Discord rate limits presence updates
+	if interval, err := parseDuration(cfg.Presence.Interval); err != nil {
+		return fmt.Errorf("failed to parse presence interval: %w", err)
+	} else if interval < time.Minute {
+		return errors.New("presence interval must be at least 1m")
+	}
+	if cfg.Maintenance.BackupKeep < 0 {
+		return errors.New("backup keep can't be negative")
+	}
+	if _, _, err := cfg.maintenanceWindow(); err != nil {
+		return err
+	}
+	if cfg.Telemetry.Endpoint != "" {
+		if u, err := url.Parse(cfg.Telemetry.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("telemetry endpoint must be an http:// or https:// URL like http://collector:4318")
+		}
+	}
+	if cfg.EventLogMaxMB < 0 {
+		return errors.New("event log max size can't be negative")
+	}
+	if cfg.SyncSlices < 0 {
+		return errors.New("sync slices can't be negative")
+	}
+	if cfg.ShardCount < 0 {
+		return errors.New("shard count can't be negative")
+	}
+	if cfg.EventWorkers < 0 || cfg.EventQueueSize < 0 {
+		return errors.New("event workers and queue size can't be negative")
+	}
+	for _, eventType := range cfg.Push.Events {
+		if _, ok := notify.DefaultTemplates[eventType]; !ok {
+			return fmt.Errorf("can't push unknown event type '%v'", eventType)
+		}
+	}
+	for _, name := range cfg.DisabledConsumers {
+		known := false
+		for _, consumer := range consumers {
+			known = known || consumer == name
+		}
+		if !known {
+			return fmt.Errorf("can't disable unknown event consumer '%v', expected one of %v", name, strings.Join(consumers, ", "))
+		}
+	}
+	if _, err := cfg.consumerFilters(); err != nil {
+		return err
+	}
+	for _, webhook := range cfg.Webhooks {
+		if u, err := url.Parse(webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook URL '%v' must be an http:// or https:// URL", webhook.URL)
+		}
+		if webhook.Secret == "" {
+			return fmt.Errorf("require a secret for the webhook %v (DUL_WEBHOOK_SECRET), receivers check signatures with it", webhook.URL)
+		}
+		for _, eventType := range webhook.Events {
+			if _, ok := notify.DefaultTemplates[eventType]; !ok {
+				return fmt.Errorf("can't post unknown event type '%v' to a webhook", eventType)
+			}
+		}
+		if _, err := webhook.filter(); err != nil {
+			return fmt.Errorf("invalid filter of the webhook %v: %w", webhook.URL, err)
+		}
+	}
+	if (cfg.Push.PushoverToken == "") != (cfg.Push.PushoverUser == "") {
+		return errors.New("pushover requires both an application token and a user key (DUL_PUSHOVER_TOKEN, DUL_PUSHOVER_USER)")
+	}
+	if cfg.Report.SMTPAddr != "" {
+		if cfg.Report.From == "" || len(cfg.Report.To) == 0 {
+			return errors.New("reports require a sender and recipients (DUL_REPORT_FROM, DUL_REPORT_TO)")
+		}
+		if err := report.ValidateSchedule(cfg.Report.Schedule); err != nil {
+			return err
+		}
+		if _, err := cfg.reportLocation(); err != nil {
+			return err
+		}
+	}
+	// the web server can serve just the event stream or the public stats, otherwise the dashboard must be fully configured
+	dashboard := cfg.Web.ClientID != "" || (cfg.Web.EventsToken == "" && !cfg.Web.PublicStats)
+	if cfg.Web.Listen != "" && dashboard && (cfg.Web.BaseURL == "" || cfg.Web.ClientID == "" || cfg.Web.ClientSecret == "") {
+		return errors.New("the dashboard requires a base URL, client ID, and client secret (DUL_WEB_BASE_URL, DUL_WEB_CLIENT_ID, DUL_WEB_CLIENT_SECRET)")
+	}
+	if cfg.GRPC.Listen != "" && (cfg.GRPC.CertFile == "" || cfg.GRPC.KeyFile == "" || cfg.GRPC.Token == "") {
+		return errors.New("the gRPC API requires a TLS certificate, key, and token (DUL_GRPC_CERT_FILE, DUL_GRPC_KEY_FILE, DUL_GRPC_TOKEN)")
+	}
+	for _, guild := range cfg.Guilds {
+		if err := cfg.validateGuild(guild); err != nil {
+			return fmt.Errorf("guild '%v': %w", guild.ID, err)
+		}
+	}
+	return nil
+}
+
+// validateTenantMode checks that nothing of the config belongs to a single bot or would mix the data of tenants.
+// Tenants configure their guilds with /userlog config, the rest of the config applies to every tenant.
+func (cfg *config) validateTenantMode() error {
+	if cfg.StatePath == ":memory:" {
+		return errors.New("tenant mode needs a database file or a Postgres URL as the state path (DUL_STATE_PATH)")
+	}
+	for _, set := range []struct {
+		set  bool
+		what string
+	}{
+		{cfg.Token != "", "token (DUL_TOKEN)"},
+		{len(cfg.Guilds) > 0, "guilds (DUL_GUILD_ID)"},
+		{cfg.OwnerID != "", "owner (DUL_OWNER_ID)"},
+		{cfg.AlertChannelID != "" || cfg.VoiceChannelID != "" || cfg.FallbackChannelID != "", "alert, voice, and fallback channels"},
+		{cfg.AutoRoleID != "" || cfg.WatchRoleID != "" || len(cfg.LeaveRoles) > 0, "auto role, watch role, and leave roles"},
+	} {
+		if set.set {
+			return fmt.Errorf("in tenant mode each tenant has its own %v, leave it out of the config", set.what)
+		}
+	}
+	for _, set := range []struct {
+		set  bool
+		what string
+	}{
+		{cfg.Web.Listen != "", "the dashboard (DUL_WEB_LISTEN)"},
+		{cfg.GRPC.Listen != "", "the gRPC API (DUL_GRPC_LISTEN)"},
+		{cfg.Report.SMTPAddr != "", "reports (DUL_SMTP_ADDR)"},
+		{cfg.EventLog != "", "the event log (DUL_EVENT_LOG)"},
+		{cfg.Push.NtfyURL != "" || cfg.Push.PushoverToken != "", "push notifications"},
+		{cfg.Publish.MQTTURL != "" || cfg.Publish.NATSURL != "", "MQTT and NATS publishing"},
+		{len(cfg.Webhooks) > 0, "webhooks"},
+	} {
+		if set.set {
+			return fmt.Errorf("%v would mix the data of tenants, it doesn't work in tenant mode", set.what)
+		}
+	}
+	if cfg.Maintenance.Window != "" || cfg.Maintenance.BackupDir != "" || cfg.Maintenance.Recover {
+		return errors.New("maintenance, backups, and recovery only cover the database of the state path, they don't work in tenant mode")
+	}
+	return nil
+}
+
+// unannounced event types have templates, but are sent on their own terms
+var unannounced = map[string]bool{
+	notify.EventMilestone:      true,
+	notify.EventMassLeave:      true,
+	notify.EventSyncSummary:    true,
+	notify.EventLeaveEdit:      true,
+	notify.EventWatchedJoin:    true,
+	notify.EventWatchedLeave:   true,
+	notify.EventWatchedRename:  true,
+	notify.EventCrossGuildJoin: true,
+	store.EventVoiceJoin:       true,
+	store.EventVoiceLeave:      true,
+	store.EventVoiceMove:       true,
+}
+
+// validateGuild checks the options of a guild, including the global options it falls back to
+func (cfg *config) validateGuild(guild guildConfig) error {
+	if _, err := cfg.timezoneFor(guild); err != nil {
+		return err
+	}
+	if _, err := cfg.templatesFor(guild); err != nil {
+		return err
+	}
+	if _, err := cfg.hookFor(guild); err != nil {
+		return err
+	}
+	if _, err := cfg.filterFor(guild); err != nil {
+		return err
+	}
+	if _, err := cfg.quietHoursFor(guild); err != nil {
+		return err
+	}
+	if _, err := cfg.massLeaveFor(guild); err != nil {
+		return err
+	}
+	if _, _, err := cfg.threadFor(guild); err != nil {
+		return err
+	}
+	if _, err := cfg.languageFor(guild); err != nil {
+		return err
+	}
+	if cfg.syncSummaryFor(guild) < 0 {
+		return errors.New("sync summary threshold can't be negative")
+	}
+	if _, _, err := cfg.leaveSurveyFor(guild); err != nil {
+		return err
+	}
+	for _, eventType := range cfg.announceFor(guild) {
+		if _, ok := notify.DefaultTemplates[eventType]; !ok || unannounced[eventType] {
+			return fmt.Errorf("can't announce unknown event type '%v'", eventType)
+		}
+	}
+	for _, action := range cfg.quickActionsFor(guild) {
+		if action != bot.QuickActionBan && action != bot.QuickActionNote {
+			return fmt.Errorf("quick action must be '%v' or '%v', not '%v'", bot.QuickActionBan, bot.QuickActionNote, action)
+		}
+	}
+	return nil
+}
+
+// templatesFor parses the templates for a guild, falling back to the global templates and then the defaults of its language.
+func (cfg *config) templatesFor(guild guildConfig) (notify.Templates, error) {
+	language, err := cfg.languageFor(guild)
+	if err != nil {
+		return notify.Templates{}, err
+	}
+	sources := map[string]string{}
+	for _, templates := range []templateConfig{cfg.Templates, guild.Templates} {
+		for eventType, source := range templates {
+			if _, ok := notify.DefaultTemplates[eventType]; !ok {
+				return notify.Templates{}, fmt.Errorf("unknown template '%v'", eventType)
+			}
+			if source != "" {
+				sources[eventType] = source
+			}
+		}
+	}
+	return notify.ParseLocalizedTemplates(language, sources)
+}
+
+// hookFor parses the hook or reads and runs the hook script of a guild, falling back to the global ones,
+// nil if none is set
+func (cfg *config) hookFor(guild guildConfig) (*notify.Hook, error) {
+	source, script := cfg.Hook, cfg.HookScript
+	if guild.Hook != "" || guild.HookScript != "" {
+		source, script = guild.Hook, guild.HookScript
+	}
+	if source != "" && script != "" {
+		return nil, errors.New("set either a hook or a hook script, not both")
+	}
+	if source == "" && script == "" {
+		return nil, nil
+	}
+	language, err := cfg.languageFor(guild)
+	if err != nil {
+		return nil, err
+	}
+	if script != "" {
+		content, err := os.ReadFile(script)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the hook script: %w", err)
+		}
+		return notify.ParseScript(language, script, string(content))
+	}
+	return notify.ParseHook(language, source)
+}
+
+// filterFor parses the announcement filter of a guild, falling back to the global filter, nil if neither is set
+func (cfg *config) filterFor(guild guildConfig) (*filter.Filter, error) {
+	source := cfg.Filter
+	if guild.Filter != "" {
+		source = guild.Filter
+	}
+	if source == "" {
+		return nil, nil
+	}
+	return filter.Parse(source)
+}
+
+// consumerFilters parses the filters of the event consumers by name
+func (cfg *config) consumerFilters() (map[string]*filter.Filter, error) {
+	filters := make(map[string]*filter.Filter, len(cfg.ConsumerFilters))
+	for name, source := range cfg.ConsumerFilters {
+		known := false
+		for _, consumer := range consumers {
+			known = known || consumer == name
+		}
+		if !known {
+			return nil, fmt.Errorf("can't filter unknown event consumer '%v', expected one of %v", name, strings.Join(consumers, ", "))
+		}
+		parsed, err := filter.Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter of the event consumer '%v': %w", name, err)
+		}
+		filters[name] = parsed
+	}
+	return filters, nil
+}
+
+// filter parses the filter of a webhook, nil if it isn't set
+func (webhook webhookConfig) filter() (*filter.Filter, error) {
+	if webhook.Filter == "" {
+		return nil, nil
+	}
+	return filter.Parse(webhook.Filter)
+}
+
+// languageFor returns the language of a guild, falling back to the global language and then English
+func (cfg *config) languageFor(guild guildConfig) (*i18n.Language, error) {
+	code := cfg.Language
+	if guild.Language != "" {
+		code = guild.Language
+	}
+	language, ok := i18n.Lookup(code)
+	if !ok {
+		return nil, fmt.Errorf("unknown language '%v', it must be one of %v", code, strings.Join(i18n.Codes(), ", "))
+	}
+	return language, nil
+}
+
+// announceFor returns the announced event types of a guild, falling back to the global list
+func (cfg *config) announceFor(guild guildConfig) []string {
+	if guild.Announce != nil {
+		return guild.Announce
+	}
+	return cfg.Announce
+}
+
+// leaveRolesFor returns the roles whose leaves are announced in a guild, falling back to the global list
+func (cfg *config) leaveRolesFor(guild guildConfig) []string {
+	if guild.LeaveRoles != nil {
+		return guild.LeaveRoles
+	}
+	return cfg.LeaveRoles
+}
+
+// quickActionsFor returns the quick actions offered under announcements in a guild, falling back to the global list
+func (cfg *config) quickActionsFor(guild guildConfig) []string {
+	if guild.QuickActions != nil {
+		return guild.QuickActions
+	}
+	return cfg.QuickActions
+}
+
+// editLeavesFor returns whether leaves edit the join announcement in a guild, falling back to the global option
+func (cfg *config) editLeavesFor(guild guildConfig) bool {
+	if guild.EditLeaves != nil {
+		return *guild.EditLeaves
+	}
+	return cfg.EditLeaves
+}
+
+// syncSummaryFor returns how many events a sync has to find to be summarized in a guild, falling back to the global option
+func (cfg *config) syncSummaryFor(guild guildConfig) int {
+	if guild.SyncSummary != nil {
+		return *guild.SyncSummary
+	}
+	return cfg.SyncSummary
+}
+
+// dashboardRoles maps each guild to the role required to view its dashboard, guilds without one aren't shown
+func (cfg *config) dashboardRoles() map[string]string {
+	roles := map[string]string{}
+	for _, guild := range cfg.Guilds {
+		if guild.DashboardRoleID != "" {
+			roles[guild.ID] = guild.DashboardRoleID
+		} else if cfg.Web.RoleID != "" {
+			roles[guild.ID] = cfg.Web.RoleID
+		}
+	}
+	return roles
+}
+
+// watchRoleFor returns the role mentioned by watched user alerts of a guild, falling back to the global role
+func (cfg *config) watchRoleFor(guild guildConfig) string {
+	if guild.WatchRoleID != "" {
+		return guild.WatchRoleID
+	}
+	return cfg.WatchRoleID
+}
+
+// autoRoleFor returns the auto role of a guild, falling back to the global auto role
+func (cfg *config) autoRoleFor(guild guildConfig) string {
+	if guild.AutoRoleID != "" {
+		return guild.AutoRoleID
+	}
+	return cfg.AutoRoleID
+}
+
+// timezoneFor returns the timezone dates are shown in for a guild, falling back to the global timezone and then UTC
+func (cfg *config) timezoneFor(guild guildConfig) (*time.Location, error) {
+	timezone := cfg.Timezone
+	if guild.Timezone != "" {
+		timezone = guild.Timezone
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone: %w", err)
+	}
+	return location, nil
+}
+
+// reportLocation returns the timezone reports are scheduled in, falling back to the global timezone
+func (cfg *config) reportLocation() (*time.Location, error) {
+	if cfg.Report.Timezone == "" {
+		return cfg.timezoneFor(guildConfig{})
+	}
+	location, err := time.LoadLocation(cfg.Report.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load report timezone: %w", err)
+	}
+	return location, nil
+}
+
+// maintenanceWindow returns the start and end of the maintenance window as offsets from midnight, both zero if there is none
+func (cfg *config) maintenanceWindow() (time.Duration, time.Duration, error) {
+	if cfg.Maintenance.Window == "" {
+		return 0, 0, nil
+	}
+	start, end, ok := strings.Cut(cfg.Maintenance.Window, "-")
+	if !ok {
+		return 0, 0, errors.New("failed to parse maintenance window: expected a range like 03:00-05:00")
+	}
+	startOffset, err := parseClock(strings.TrimSpace(start))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse maintenance window start: %w", err)
+	}
+	endOffset, err := parseClock(strings.TrimSpace(end))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse maintenance window end: %w", err)
+	}
+	if startOffset == endOffset {
+		return 0, 0, errors.New("the maintenance window must not start and end at the same time")
+	}
+	return startOffset, endOffset, nil
+}
+
+// threadFor returns the thread mode of a guild and the timezone its days start in, falling back to the global settings
+func (cfg *config) threadFor(guild guildConfig) (string, *time.Location, error) {
+	mode, timezone := cfg.ThreadMode, cfg.ThreadTimezone
+	if guild.ThreadMode != "" {
+		mode = guild.ThreadMode
+	}
+	if guild.ThreadTimezone != "" {
+		timezone = guild.ThreadTimezone
+	}
+	if mode == "" {
+		mode = threadModeChannel
+	}
+	if mode != threadModeChannel && mode != notify.ThreadModeThread && mode != notify.ThreadModeForum {
+		return "", nil, fmt.Errorf("thread mode must be '%v', '%v', or '%v'", threadModeChannel, notify.ThreadModeThread, notify.ThreadModeForum)
+	}
+	if timezone == "" {
+		location, err := cfg.timezoneFor(guild)
+		return mode, location, err
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load thread timezone: %w", err)
+	}
+	return mode, location, nil
+}
+
+// milestonesFor returns the milestones of a guild, falling back to the global milestones
+func (cfg *config) milestonesFor(guild guildConfig) milestoneConfig {
+	if guild.Milestones != nil {
+		return *guild.Milestones
+	}
+	if cfg.Milestones != nil {
+		return *cfg.Milestones
+	}
+	return milestoneConfig{}
+}
+
+// massLeaveFor returns the mass leave alert settings of a guild, falling back to the global settings
+func (cfg *config) massLeaveFor(guild guildConfig) (bot.MassLeave, error) {
+	massLeave := guild.MassLeave
+	if massLeave == nil {
+		massLeave = cfg.MassLeave
+	}
+	if massLeave == nil || massLeave.Count <= 0 {
+		return bot.MassLeave{}, nil
+	}
+	window, err := parseDuration(massLeave.Window)
+	if err != nil {
+		return bot.MassLeave{}, fmt.Errorf("failed to parse mass leave window: %w", err)
+	}
+	if window <= 0 {
+		return bot.MassLeave{}, errors.New("mass leave window must be positive")
+	}
+	return bot.MassLeave{Count: massLeave.Count, Window: window}, nil
+}
+
+// leaveSurveyFor returns whether members who leave a guild are asked why and the reasons offered, falling back to the global survey.
+// The reasons are buttons, a row fits 5 with labels of up to 80 characters.
+func (cfg *config) leaveSurveyFor(guild guildConfig) (bool, []string, error) {
+	leaveSurvey := guild.LeaveSurvey
+	if leaveSurvey == nil {
+		leaveSurvey = cfg.LeaveSurvey
+	}
+	if leaveSurvey == nil || !leaveSurvey.Enabled {
+		return false, nil, nil
+	}
+	if len(leaveSurvey.Reasons) > 5 {
+		return false, nil, errors.New("the leave survey can offer at most 5 reasons")
+	}
+	for _, reason := range leaveSurvey.Reasons {
+		if reason == "" || len(reason) > 80 {
+			return false, nil, fmt.Errorf("leave survey reasons must be 1 to 80 characters long, got '%v'", reason)
+		}
+	}
+	return true, leaveSurvey.Reasons, nil
+}
+
+// alertChannelFor returns the moderator alert channel of a guild, falling back to the global alert channel and then the announcement channel
+func (cfg *config) alertChannelFor(guild guildConfig) string {
+	if guild.AlertChannelID != "" {
+		return guild.AlertChannelID
+	}
+	if cfg.AlertChannelID != "" {
+		return cfg.AlertChannelID
+	}
+	return guild.ChannelID
+}
+
+// fallbackChannelFor returns the channel of a guild receiving what the bot isn't allowed to post elsewhere, falling back to the global one.
+// Empty leaves those messages in the outbox.
+func (cfg *config) fallbackChannelFor(guild guildConfig) string {
+	if guild.FallbackChannelID != "" {
+		return guild.FallbackChannelID
+	}
+	return cfg.FallbackChannelID
+}
+
+// voiceChannelFor returns the voice log channel of a guild, falling back to the global one. Empty disables voice logging.
+func (cfg *config) voiceChannelFor(guild guildConfig) string {
+	if guild.VoiceChannelID != "" {
+		return guild.VoiceChannelID
+	}
+	return cfg.VoiceChannelID
+}
+
+// quietHoursFor returns the quiet hours of a guild, falling back to the global quiet hours.
+// It returns nil if there are none.
+func (cfg *config) quietHoursFor(guild guildConfig) (*bot.QuietHours, error) {
+	quietHours := guild.QuietHours
+	if quietHours == nil {
+		quietHours = cfg.QuietHours
+	}
+	if quietHours == nil || (quietHours.Start == "" && quietHours.End == "") {
+		return nil, nil
+	}
+
+	start, err := parseClock(quietHours.Start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse quiet hours start: %w", err)
+	}
+	end, err := parseClock(quietHours.End)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse quiet hours end: %w", err)
+	}
+	if start == end {
+		return nil, errors.New("quiet hours must not start and end at the same time")
+	}
+	location, err := cfg.timezoneFor(guild)
+	if quietHours.Timezone != "" {
+		location, err = time.LoadLocation(quietHours.Timezone)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quiet hours timezone: %w", err)
+	}
+	return &bot.QuietHours{Start: start, End: end, Location: location}, nil
+}
+
+// parseClock parses a time of day like "08:00" into the offset from midnight
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseDuration parses a duration like "180d" or "72h".
+// An empty value is zero.
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// Application flags set when the Presence and Server Members Intents are enabled, the limited ones for bots in less than 100 servers
+const (
+	applicationFlagGatewayPresence            = 1 << 12
+	applicationFlagGatewayPresenceLimited     = 1 << 13
+	applicationFlagGatewayGuildMembers        = 1 << 14
+	applicationFlagGatewayGuildMembersLimited = 1 << 15
+)
+
+// permissionNames are the channel permissions the doctor checks, in the order they are listed
+var permissionNames = []struct {
+	permission int64
+	name       string
+}{
+	{discordgo.PermissionViewChannel, "View Channel"},
+	{discordgo.PermissionSendMessages, "Send Messages"},
+	{discordgo.PermissionCreatePublicThreads, "Create Public Threads"},
+	{discordgo.PermissionSendMessagesInThreads, "Send Messages in Threads"},
+}
+
+// doctor prints the result of each check, remembering if any failed
+type doctor struct {
+	failed bool
+}
+
+func (d *doctor) ok(format string, args ...interface{}) {
+	fmt.Printf("ok    "+format+"\n", args...)
+}
+
+func (d *doctor) fail(format string, args ...interface{}) {
+	d.failed = true
+	fmt.Printf("FAIL  "+format+"\n", args...)
+}
+
+// runDoctor checks the config, database, token, intents, and channel permissions, exiting with 1 if anything needs fixing
+func runDoctor(cfg *config) {
+	d := &doctor{}
+	d.run(cfg)
+	if d.failed {
+		os.Exit(1)
+	}
+}
+
+func (d *doctor) run(cfg *config) {
+	if err := cfg.validate(); err != nil {
+		d.fail("config is invalid: %v", err)
+		return
+	}
+	d.ok("config is valid")
+
+	st, err := store.OpenEncrypted(cfg.StatePath, cfg.DBKey)
+	if err == store.ErrNoSQLCipher {
+		d.fail("DUL_DB_KEY is set, but this build isn't linked against SQLCipher")
+	} else if err != nil {
+		d.fail("failed to open the database at %v, check that its directory exists and DUL_DB_KEY is right: %v", store.Redact(cfg.StatePath), err)
+	} else {
+		defer st.Close()
+		if err := st.CheckWritable(); err != nil {
+			d.fail("database at %v isn't writable, check the permissions of it and its directory: %v", store.Redact(cfg.StatePath), err)
+		} else {
+			d.ok("database at %v is writable", store.Redact(cfg.StatePath))
+		}
+	}
+
+	if !cfg.Tenants {
+		d.checkBot(cfg, st)
+	} else if st != nil {
+		d.checkTenants(cfg, st)
+	}
+}
+
+// checkTenants checks the database and bot of every tenant
+func (d *doctor) checkTenants(cfg *config, st *store.Store) {
+	tenants, err := st.Tenants()
+	if err != nil {
+		d.fail("failed to load the tenants: %v", err)
+		return
+	}
+	if len(tenants) == 0 {
+		d.fail("there are no tenants, add one with the tenants command")
+	}
+	for _, tenant := range tenants {
+		tenantStore, err := store.OpenTenant(cfg.StatePath, cfg.DBKey, tenant.Name)
+		if err != nil {
+			d.fail("failed to open the database of tenant '%v': %v", tenant.Name, err)
+			continue
+		}
+		d.ok("opened the database of tenant '%v', checking its bot", tenant.Name)
+		d.checkBot(tenantConfig(cfg, tenant), tenantStore)
+		tenantStore.Close()
+	}
+}
+
+// checkBot checks the token, intents, and guilds of a bot, st is nil if the database can't be opened
+func (d *doctor) checkBot(cfg *config, st *store.Store) {
+	session, err := discordgo.New("Bot " + cfg.Token)
+	if err != nil {
+		d.fail("failed to create a discord session: %v", err)
+		return
+	}
+	user, err := session.User("@me")
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response.StatusCode == http.StatusUnauthorized {
+		d.fail("token was rejected, copy it again from Applications -> Bot -> Token")
+		return
+	} else if err != nil {
+		d.fail("failed to reach Discord: %v", err)
+		return
+	}
+	d.ok("token is valid, logged in as %v", user.String())
+
+	application, err := session.Application("@me")
+	if err != nil {
+		d.fail("failed to check the Server Members Intent: %v", err)
+	} else if application.Flags&(applicationFlagGatewayGuildMembers|applicationFlagGatewayGuildMembersLimited) == 0 {
+		d.fail("the Server Members Intent is disabled, enable it in Applications -> Bot -> Privileged Gateway Intents")
+	} else {
+		d.ok("the Server Members Intent is enabled")
+	}
+	if cfg.TrackPresence && application != nil {
+		if application.Flags&(applicationFlagGatewayPresence|applicationFlagGatewayPresenceLimited) == 0 {
+			d.fail("presence tracking needs the Presence Intent, enable it in Applications -> Bot -> Privileged Gateway Intents")
+		} else {
+			d.ok("the Presence Intent is enabled")
+		}
+	}
+
+	for _, guild := range cfg.Guilds {
+		d.checkGuild(session, st, cfg, guild, user.ID)
+	}
+	if channelID := cfg.Maintenance.ChannelID; channelID != "" {
+		// it may be in any guild the bot is in
+		if channel, err := session.Channel(channelID); err != nil {
+			d.fail("failed to look up the maintenance channel %v, check that the channel ID is right: %v", channelID, err)
+		} else {
+			d.checkChannel(session, user.ID, channel.GuildID, "maintenance", channelID, discordgo.PermissionViewChannel|discordgo.PermissionSendMessages)
+		}
+	}
+}
+
+// checkGuild checks that the bot is in a guild and can post into its channels, using its runtime settings if they are valid
+func (d *doctor) checkGuild(session *discordgo.Session, st *store.Store, cfg *config, guild guildConfig, userID string) {
+	if st != nil {
+		if configured, err := cfg.withStoredSettings(st, guild); err != nil {
+			d.fail("runtime settings of guild '%v' are ignored, fix them with /userlog config: %v", guild.ID, err)
+		} else {
+			guild = configured
+		}
+	}
+
+	if _, err := session.GuildMember(guild.ID, userID); err != nil {
+		d.fail("the bot isn't in guild '%v', invite it with the bot scope: %v", guild.ID, err)
+		return
+	}
+	d.ok("the bot is in guild '%v'", guild.ID)
+
+	required := int64(discordgo.PermissionViewChannel | discordgo.PermissionSendMessages)
+	threadMode, _, _ := cfg.threadFor(guild)
+	switch threadMode {
+	case notify.ThreadModeThread:
+		required |= discordgo.PermissionCreatePublicThreads | discordgo.PermissionSendMessagesInThreads
+	case notify.ThreadModeForum:
+		required |= discordgo.PermissionSendMessagesInThreads
+	}
+	d.checkChannel(session, userID, guild.ID, "announcement", guild.ChannelID, required)
+	if alertChannelID := cfg.alertChannelFor(guild); alertChannelID != guild.ChannelID {
+		d.checkChannel(session, userID, guild.ID, "alert", alertChannelID, discordgo.PermissionViewChannel|discordgo.PermissionSendMessages)
+	}
+	if voiceChannelID := cfg.voiceChannelFor(guild); voiceChannelID != "" {
+		d.checkChannel(session, userID, guild.ID, "voice log", voiceChannelID, discordgo.PermissionViewChannel|discordgo.PermissionSendMessages)
+	}
+}
+
+// checkChannel checks that the bot has the required permissions in a channel
+func (d *doctor) checkChannel(session *discordgo.Session, userID, guildID, kind, channelID string, required int64) {
+	permissions, err := session.UserChannelPermissions(userID, channelID)
+	if err != nil {
+		d.fail("failed to check permissions in the %v channel %v of guild '%v', check that the channel ID is right: %v", kind, channelID, guildID, err)
+		return
+	}
+	missing := []string{}
+	for _, permission := range permissionNames {
+		if required&permission.permission != 0 && permissions&permission.permission == 0 {
+			missing = append(missing, permission.name)
+		}
+	}
+	if len(missing) > 0 {
+		d.fail("the bot is missing %v in the %v channel %v of guild '%v', grant them to its role or in the channel", strings.Join(missing, ", "), kind, channelID, guildID)
+		return
+	}
+	d.ok("the bot can post in the %v channel %v of guild '%v'", kind, channelID, guildID)
+}
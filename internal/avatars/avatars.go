@@ -0,0 +1,84 @@
+// Package avatars archives Discord avatar images to a local directory.
+package avatars
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultBaseURL is Discord's CDN path for user avatars
+const DefaultBaseURL = "https://cdn.discordapp.com/avatars/"
+
+// Archive saves avatars as <dir>/<user ID>/<hash>.<png|gif>
+type Archive struct {
+	Dir     string
+	BaseURL string
+	Client  *http.Client
+}
+
+func New(dir string) *Archive {
+	return &Archive{
+		Dir:     dir,
+		BaseURL: DefaultBaseURL,
+		Client:  http.DefaultClient,
+	}
+}
+
+// filename returns the archived file name of an avatar hash, animated avatars are prefixed with "a_"
+func filename(hash string) string {
+	if strings.HasPrefix(hash, "a_") {
+		return hash + ".gif"
+	}
+	return hash + ".png"
+}
+
+// Save downloads an avatar unless it was already archived
+func (a *Archive) Save(userID, hash string) error {
+	if hash == "" {
+		return nil
+	}
+	dir := filepath.Join(a.Dir, userID)
+	path := filepath.Join(dir, filename(hash))
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	resp, err := a.Client.Get(a.BaseURL + userID + "/" + filename(hash) + "?size=1024")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status downloading avatar %v of '%v': %v", hash, userID, resp.Status)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// write to a temp file first so a failed download doesn't leave a truncated avatar behind
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Forget removes every archived avatar of a user
+func (a *Archive) Forget(userID string) error {
+	if userID == "" || userID == "." || userID == ".." || userID != filepath.Base(userID) {
+		return fmt.Errorf("invalid user ID '%v'", userID)
+	}
+	return os.RemoveAll(filepath.Join(a.Dir, userID))
+}
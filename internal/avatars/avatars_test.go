@@ -0,0 +1,88 @@
+package avatars
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSave(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.URL.Path == "/1/missing.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("image of " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	archive := New(t.TempDir())
+	archive.BaseURL = server.URL + "/"
+
+	for _, hash := range []string{"abc", "a_def", "abc", ""} {
+		if err := archive.Save("1", hash); err != nil {
+			t.Fatalf("failed to save %q: %v", hash, err)
+		}
+	}
+	if err := archive.Save("1", "missing"); err == nil {
+		t.Error("expected an error saving a missing avatar")
+	}
+
+	// already archived avatars aren't downloaded again
+	expected := []string{"/1/abc.png", "/1/a_def.gif", "/1/missing.png"}
+	if len(requests) != len(expected) {
+		t.Fatalf("requested %v, expected %v", requests, expected)
+	}
+	for i := range expected {
+		if requests[i] != expected[i] {
+			t.Errorf("requested %v, expected %v", requests, expected)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(archive.Dir, "1", "a_def.gif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "image of /1/a_def.gif" {
+		t.Errorf("unexpected archived data %q", data)
+	}
+	entries, err := os.ReadDir(filepath.Join(archive.Dir, "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected only the 2 archived avatars, got %v", entries)
+	}
+}
+
+func TestForget(t *testing.T) {
+	archive := New(t.TempDir())
+	for _, userID := range []string{"1", "2"} {
+		if err := os.MkdirAll(filepath.Join(archive.Dir, userID), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(archive.Dir, userID, "abc.png"), []byte("image"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := archive.Forget("1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(archive.Dir, "1")); !os.IsNotExist(err) {
+		t.Errorf("expected the avatars of 1 to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(archive.Dir, "2", "abc.png")); err != nil {
+		t.Errorf("expected the avatars of 2 to be kept: %v", err)
+	}
+	if err := archive.Forget("3"); err != nil {
+		t.Errorf("expected forgetting a user without avatars to succeed, got %v", err)
+	}
+	if err := archive.Forget(".."); err == nil {
+		t.Error("expected forgetting '..' to fail")
+	}
+}
@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+)
+
+// scheduleAnniversaries celebrates the join anniversaries of today, then those of each following day when it starts
+func (b *Bot) scheduleAnniversaries() {
+	location := b.options.Location
+	if location == nil {
+		location = time.UTC
+	}
+	for {
+		now := time.Now().In(location)
+		b.celebrateAnniversaries(now)
+		y, m, d := now.Date()
+		select {
+		case <-time.After(time.Date(y, m, d+1, 0, 0, 0, 0, location).Sub(now)):
+		case <-b.ctx.Done():
+			return
+		}
+	}
+}
+
+// celebrateAnniversaries announces the members of every guild who joined on this day in an earlier year
+func (b *Bot) celebrateAnniversaries(now time.Time) {
+	for _, g := range b.guilds {
+		g.celebrateAnniversaries(now)
+	}
+}
+
+// anniversaryYears returns how many years ago a member joined, if today is their join anniversary in the location of now.
+// Members who joined on February 29th celebrate on February 28th outside of leap years.
+func anniversaryYears(joinedAt, now time.Time) (int, bool) {
+	joinedAt = joinedAt.In(now.Location())
+	years := now.Year() - joinedAt.Year()
+	if years < 1 {
+		return 0, false
+	}
+	month, day := joinedAt.Month(), joinedAt.Day()
+	if month == time.February && day == 29 && time.Date(now.Year(), time.March, 0, 0, 0, 0, 0, time.UTC).Day() != 29 {
+		day = 28
+	}
+	return years, now.Month() == month && now.Day() == day
+}
+
+// celebrateAnniversaries announces the members who joined on this day in an earlier year, once per year
+func (g *Guild) celebrateAnniversaries(now time.Time) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if _, announced := g.announce[notify.EventAnniversary]; !announced || g.closed {
+		return
+	}
+	defer g.transactionLocked()()
+	discordIDs := make([]string, 0, len(g.state))
+	for discordID := range g.state {
+		discordIDs = append(discordIDs, discordID)
+	}
+	sort.Strings(discordIDs)
+
+	for _, discordID := range discordIDs {
+		member := g.state[discordID]
+		if member.JoinedAt.IsZero() {
+			continue
+		}
+		years, ok := anniversaryYears(member.JoinedAt, now)
+		if !ok {
+			continue
+		}
+		if _, optedOut := g.anniversaryOptOut[discordID]; optedOut {
+			continue
+		}
+		firstTime, err := g.store.RecordAnniversary(g.ID, discordID, years, now)
+		if err != nil {
+			log.Fatalf("failed to record %v year anniversary of '%v': %v", years, discordID, err)
+		}
+		if !firstTime {
+			continue
+		}
+		event := notify.Event{
+			Type:        notify.EventAnniversary,
+			GuildID:     g.ID,
+			UserID:      discordID,
+			User:        member.User,
+			At:          now,
+			MemberCount: len(g.state),
+			JoinedAt:    member.JoinedAt,
+			Years:       years,
+		}
+		g.publishLocked(&event)
+		err = g.announceLocked(event)
+		if err != nil {
+			sendFailed(err, "failed to send message about the %v year anniversary of '%v'", years, discordID)
+			continue
+		}
+		log.Printf("messaged about the %v year anniversary of '%v'", years, discordID)
+	}
+}
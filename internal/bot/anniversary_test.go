@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+)
+
+func TestAnniversariesAreCelebratedOnce(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	joinedAt := time.Date(2021, 7, 4, 18, 0, 0, 0, time.UTC)
+	alice, bob, carol := member("1", "alice", "0"), member("2", "bob", "0"), member("3", "carol", "0")
+	alice.JoinedAt = joinedAt
+	bob.JoinedAt = joinedAt.AddDate(0, 0, 1)
+	carol.JoinedAt = joinedAt
+	session.setMembers(testGuildID, alice, bob, carol)
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{
+		Announce:          []string{notify.EventAnniversary},
+		AnniversaryOptOut: []string{"3"},
+	})
+	g.syncMembersFromServer(context.Background(), session)
+
+	now := time.Date(2023, 7, 4, 9, 0, 0, 0, time.UTC)
+	g.celebrateAnniversaries(now)
+	assertSent(t, session, "🎂 <@1> (alice) has been here for 2 years today!")
+
+	g.celebrateAnniversaries(now.Add(time.Hour))
+	assertSent(t, session)
+
+	g.celebrateAnniversaries(now.AddDate(0, 0, 1))
+	assertSent(t, session, "🎂 <@2> (bob) has been here for 2 years today!")
+}
+
+func TestAnniversariesNeedAnnouncing(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	alice := member("1", "alice", "0")
+	alice.JoinedAt = time.Date(2022, 7, 4, 18, 0, 0, 0, time.UTC)
+	session.setMembers(testGuildID, alice)
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	g.celebrateAnniversaries(time.Date(2023, 7, 4, 9, 0, 0, 0, time.UTC))
+	assertSent(t, session)
+	if first, err := st.RecordAnniversary(testGuildID, "1", 1, time.Now()); err != nil || !first {
+		t.Errorf("expected an unannounced anniversary not to be recorded, got %v %v", first, err)
+	}
+}
+
+func TestAnniversaryYears(t *testing.T) {
+	at := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 12, 0, 0, 0, time.UTC) }
+	for _, tc := range []struct {
+		joinedAt, now time.Time
+		years         int
+		ok            bool
+	}{
+		{at(2020, 5, 1), at(2023, 5, 1), 3, true},
+		{at(2020, 5, 1), at(2023, 5, 2), 3, false},
+		{at(2023, 5, 1), at(2023, 5, 1), 0, false},
+		{at(2020, 2, 29), at(2023, 2, 28), 3, true},
+		{at(2020, 2, 29), at(2024, 2, 28), 4, false},
+		{at(2020, 2, 29), at(2024, 2, 29), 4, true},
+		// the day is in the location of now
+		{time.Date(2020, 5, 1, 23, 0, 0, 0, time.UTC), time.Date(2023, 5, 2, 9, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60)), 3, true},
+	} {
+		years, ok := anniversaryYears(tc.joinedAt, tc.now)
+		if years != tc.years || ok != tc.ok {
+			t.Errorf("anniversaryYears(%v, %v) = %v, %v, expected %v, %v", tc.joinedAt, tc.now, years, ok, tc.years, tc.ok)
+		}
+	}
+}
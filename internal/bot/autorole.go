@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// RoleAdder is the subset of *discordgo.Session used to assign roles
+type RoleAdder interface {
+	GuildMemberRoleAdd(guildID, userID, roleID string) error
+}
+
+// autoRoleRetryDelays are waited between attempts to assign the auto role, rate limits are already retried by discordgo
+var autoRoleRetryDelays = []time.Duration{time.Second, 5 * time.Second, 30 * time.Second}
+
+// assignAutoRoleLocked gives a member the configured auto role in the background
+func (g *Guild) assignAutoRoleLocked(discordID string) {
+	if g.autoRoleID == "" || g.roles == nil {
+		return
+	}
+	guildID, roleID, roles := g.ID, g.autoRoleID, g.roles
+	go func() {
+		var err error
+		for attempt := 0; ; attempt++ {
+			if err = roles.GuildMemberRoleAdd(guildID, discordID, roleID); err == nil {
+				log.Printf("assigned auto role '%v' to '%v' in guild '%v'", roleID, discordID, guildID)
+				return
+			}
+			if attempt == len(autoRoleRetryDelays) || !retryableRoleError(err) {
+				break
+			}
+			time.Sleep(autoRoleRetryDelays[attempt])
+		}
+		log.Printf("failed to assign auto role '%v' to '%v' in guild '%v': %v", roleID, discordID, guildID, err)
+	}()
+}
+
+// retryableRoleError reports whether assigning a role might work on another attempt.
+// Missing permissions and members that already left won't.
+func retryableRoleError(err error) bool {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response != nil {
+		switch restErr.Response.StatusCode {
+		case http.StatusForbidden, http.StatusNotFound:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// fakeRoleAdder fails with the queued errors, then reports assigned roles on a channel
+type fakeRoleAdder struct {
+	errs     chan error
+	assigned chan string
+}
+
+func (f *fakeRoleAdder) GuildMemberRoleAdd(guildID, userID, roleID string) error {
+	select {
+	case err := <-f.errs:
+		return err
+	default:
+	}
+	f.assigned <- userID + "/" + roleID
+	return nil
+}
+
+func TestAutoRole(t *testing.T) {
+	autoRoleRetryDelays = []time.Duration{0, 0}
+	st := openTestStore(t)
+	session := newFakeSession()
+	roles := &fakeRoleAdder{errs: make(chan error, 1), assigned: make(chan string, 1)}
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{AutoRoleID: "9", Roles: roles})
+	g.syncMembersFromServer(context.Background(), session)
+
+	expectAssigned := func(expected string) {
+		t.Helper()
+		select {
+		case assigned := <-roles.assigned:
+			if assigned != expected {
+				t.Errorf("assigned %v, expected %v", assigned, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %v to be assigned", expected)
+		}
+	}
+
+	// transient errors are retried
+	roles.errs <- errors.New("connection reset")
+	g.memberAdded("1", store.Member{})
+	expectAssigned("1/9")
+
+	// pending members get the role once they complete screening
+	g.memberAdded("2", store.Member{Pending: true})
+	select {
+	case assigned := <-roles.assigned:
+		t.Errorf("assigned %v to a pending member", assigned)
+	case <-time.After(50 * time.Millisecond):
+	}
+	g.memberUpdated("2", store.Member{})
+	expectAssigned("2/9")
+}
+
+func TestRetryableRoleError(t *testing.T) {
+	for status, expected := range map[int]bool{
+		http.StatusForbidden:           false,
+		http.StatusNotFound:            false,
+		http.StatusInternalServerError: true,
+	} {
+		err := &discordgo.RESTError{Response: &http.Response{StatusCode: status}}
+		if actual := retryableRoleError(err); actual != expected {
+			t.Errorf("retryableRoleError(%v) = %v, expected %v", status, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// banAuditLogLimit is how many recent audit log entries are searched for the one of a ban or unban
+const banAuditLogLimit = 10
+
+// AuditLogger is the subset of *discordgo.Session used to look up who banned someone and why
+type AuditLogger interface {
+	GuildAuditLog(guildID, userID, beforeID string, actionType, limit int) (*discordgo.GuildAuditLog, error)
+}
+
+// banDetails are stored with ban and unban history events, either may be empty if the audit log couldn't be read
+type banDetails struct {
+	Reason      string `json:"reason,omitempty"`
+	ModeratorID string `json:"moderator_id,omitempty"`
+}
+
+func (b *Bot) guildBanAdd(s *discordgo.Session, e *discordgo.GuildBanAdd) {
+	b.banChanged(s, e.GuildID, e.User, store.EventBan)
+}
+
+func (b *Bot) guildBanRemove(s *discordgo.Session, e *discordgo.GuildBanRemove) {
+	b.banChanged(s, e.GuildID, e.User, store.EventUnban)
+}
+
+func (b *Bot) banChanged(s AuditLogger, guildID string, user *discordgo.User, eventType string) {
+	if user == nil {
+		return
+	}
+	g, ok := b.guilds[guildID]
+	if !ok {
+		return
+	}
+	action := discordgo.AuditLogActionMemberBanAdd
+	if eventType == store.EventUnban {
+		action = discordgo.AuditLogActionMemberBanRemove
+	}
+	// looked up before locking the guild, it is a request to Discord
+	details := banAuditEntry(s, guildID, user.ID, action)
+	g.banChanged(user.ID, store.User{Username: user.Username, Discriminator: user.Discriminator}, eventType, details, time.Now())
+}
+
+// banAuditEntry finds the reason and moderator of a ban or unban in the audit log, it needs the View Audit Log permission
+func banAuditEntry(s AuditLogger, guildID, discordID string, action discordgo.AuditLogAction) banDetails {
+	auditLog, err := s.GuildAuditLog(guildID, "", "", int(action), banAuditLogLimit)
+	if err != nil {
+		log.Printf("failed to read the audit log of guild '%v' for the ban of '%v': %v", guildID, discordID, err)
+		return banDetails{}
+	}
+	for _, entry := range auditLog.AuditLogEntries {
+		if entry.TargetID == discordID {
+			return banDetails{Reason: entry.Reason, ModeratorID: entry.UserID}
+		}
+	}
+	return banDetails{}
+}
+
+// banChanged records and announces a user being banned or unbanned, the leave of a banned member is recorded on its own
+func (g *Guild) banChanged(discordID string, user store.User, eventType string, details banDetails, at time.Time) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed {
+		return
+	}
+	defer g.transactionLocked()()
+	g.eventLocked(notify.Event{
+		Type:        eventType,
+		UserID:      discordID,
+		User:        user,
+		At:          at,
+		Reason:      details.Reason,
+		ModeratorID: details.ModeratorID,
+	}, details)
+}
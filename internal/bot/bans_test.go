@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// fakeAuditLog returns the same entries and users for any query, or err
+type fakeAuditLog struct {
+	entries []*discordgo.AuditLogEntry
+	users   []*discordgo.User
+	err     error
+}
+
+func (f fakeAuditLog) GuildAuditLog(guildID, userID, beforeID string, actionType, limit int) (*discordgo.GuildAuditLog, error) {
+	return &discordgo.GuildAuditLog{AuditLogEntries: f.entries, Users: f.users}, f.err
+}
+
+func TestBans(t *testing.T) {
+	st := openTestStore(t)
+	if err := st.AddMember(testGuildID, "1", store.Member{User: store.User{Username: "alice", Discriminator: "0"}}); err != nil {
+		t.Fatal(err)
+	}
+	session := newFakeSession()
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{
+		Announce: append(defaultAnnounce, store.EventBan, store.EventUnban),
+	})
+
+	auditLog := fakeAuditLog{entries: []*discordgo.AuditLogEntry{
+		{TargetID: "3", UserID: "9", Reason: "wrong user"},
+		{TargetID: "2", UserID: "9", Reason: "spam"},
+	}}
+	g.bot.banChanged(auditLog, testGuildID, &discordgo.User{ID: "2", Username: "bob", Discriminator: "0"}, store.EventBan)
+	// without the View Audit Log permission, the moderator and reason are unknown
+	g.bot.banChanged(fakeAuditLog{err: errors.New("missing access")}, testGuildID, &discordgo.User{ID: "2", Username: "bob", Discriminator: "0"}, store.EventUnban)
+	assertSent(t, session,
+		"🔨 <@2> (bob) was banned by <@9>: spam",
+		"<@2> (bob) was unbanned",
+	)
+
+	events, err := st.RecentEvents(testGuildID, []string{store.EventBan, store.EventUnban}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[1].Event != store.EventBan || events[1].Details != `{"reason":"spam","moderator_id":"9"}` || events[0].Details != "{}" {
+		t.Errorf("unexpected history %+v", events)
+	}
+}
@@ -0,0 +1,395 @@
+// Package bot tracks guild members and announces joins and leaves.
+package bot
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// Store persists member state, it is implemented by *store.Store
+type Store interface {
+	Members(guildID string) (map[string]store.Member, error)
+	AddMember(guildID, discordID string, member store.Member) error
+	UpdateMember(guildID, discordID string, member store.Member) error
+	RemoveMember(guildID, discordID string) error
+	RecordEvent(event store.HistoryEvent) error
+	RecordMilestone(guildID string, memberCount int, at time.Time) (bool, error)
+	MilestonesSince(guildID string, since time.Time) ([]int, error)
+	JoinSources(guildID string, since time.Time, limit int) ([]store.JoinSource, error)
+	RecordAnniversary(guildID, discordID string, years int, at time.Time) (bool, error)
+	ForgetInGuild(guildID, discordID string, keepMember bool) (int64, error)
+	CountEvents(guildID, event string, since time.Time) (int, error)
+	LastJoin(guildID, discordID string) (time.Time, bool, error)
+	Stays(guildID string, since time.Time) ([]store.Stay, error)
+	RecentEvents(guildID string, events []string, limit, offset int) ([]store.HistoryEvent, error)
+	UserHistory(guildID, discordID string) ([]store.HistoryEvent, error)
+	RecordName(guildID, discordID, kind, name string, at time.Time) error
+	Names(guildID, discordID string) ([]store.Name, error)
+	GuildSettings(guildID string) (map[string]string, error)
+	SetGuildSetting(guildID, key, value string, at time.Time) error
+	DeleteGuildSetting(guildID, key string) error
+	RecordSnapshot(guildID string, at time.Time, memberCount int) error
+	RecordMemberList(guildID string, at time.Time, discordIDs []string) (bool, error)
+	MemberList(guildID string, at time.Time) (map[string]bool, time.Time, bool, error)
+	MemberCountHistory(guildID string, current int, since time.Time, days int) ([]store.DayCount, error)
+	WatchUser(guildID, discordID, addedBy string, at time.Time) (bool, error)
+	UnwatchUser(guildID, discordID string) (bool, error)
+	WatchedUsers(guildID string) ([]store.WatchedUser, error)
+	SaveJoinMessage(guildID, discordID string, message store.JoinMessage) error
+	TakeJoinMessage(guildID, discordID string) (store.JoinMessage, bool, error)
+	// Begin starts a transaction, committed with the returned store's Commit
+	Begin() (*store.Store, error)
+	QueueAnnouncement(guildID string, announcement store.Announcement) (int64, error)
+	QueuedAnnouncements(guildID string) ([]store.Announcement, error)
+	DeleteAnnouncements(ids []int64) error
+	RecordPresence(guildID, discordID string, online bool, at time.Time) error
+	LastSeen(guildID, discordID string) (store.Presence, bool, error)
+	Presences(guildID string) ([]store.Presence, error)
+	AwaitFirstMessage(guildID, discordID string, joinedAt time.Time) error
+	RecordFirstMessage(guildID, discordID, channelID string, at time.Time) (bool, error)
+	FirstMessage(guildID, discordID string) (store.FirstMessage, bool, error)
+	FirstMessages(guildID string, since time.Time) ([]store.FirstMessage, error)
+	RecordMessage(guildID, discordID string, at time.Time) error
+	LastActivity(guildID string, now time.Time) (map[string]time.Time, error)
+	RecordLeaveReason(guildID string, reason store.LeaveReason) error
+	LeaveReason(guildID, discordID string) (store.LeaveReason, bool, error)
+	LeaveReasons(guildID string, since time.Time) ([]store.LeaveReason, error)
+	RecordScheduledEvent(guildID string, event store.ScheduledEvent) error
+	ScheduledEvent(guildID, eventID string) (store.ScheduledEvent, bool, error)
+	ScheduledEvents(guildID string, limit int) ([]store.ScheduledEvent, error)
+	RecordRSVP(guildID, eventID, discordID string, interested bool, at time.Time) error
+	RSVPs(guildID, eventID string) ([]store.RSVP, error)
+	LastDeparture(discordID, exceptGuildID string, since time.Time) (store.HistoryEvent, bool, error)
+	AddNote(guildID string, note store.Note) (int64, error)
+	Notes(guildID, discordID string) ([]store.Note, error)
+}
+
+// Session is the subset of *discordgo.Session used to track members
+type Session interface {
+	GuildMembers(guildID string, after string, limit int) ([]*discordgo.Member, error)
+	ChannelMessageSend(channelID string, content string) (*discordgo.Message, error)
+	RequestGuildMembers(guildID, query string, limit int, nonce string, presences bool) error
+}
+
+// resyncDelay is how long to wait after reconnecting before syncing, to catch events missed while disconnected
+const resyncDelay = 30 * time.Second
+
+// Options are the bot-wide settings that can't be changed at runtime
+type Options struct {
+	// GatewaySync fetches members with gateway member chunks instead of paginated REST calls when syncing
+	GatewaySync bool
+	// AvatarArchive saves old and new avatars when members change them, nil disables archiving
+	AvatarArchive AvatarArchive
+	// Reconfigure applies changed runtime settings of a guild, returning an error if they are invalid
+	Reconfigure func(guildID string) error
+	// SettingNames are the runtime settings /userlog config accepts
+	SettingNames []string
+	// ReloadToken reads the token again and switches to it if it changed, returning ErrTokenUnchanged if it didn't.
+	// nil turns /userlog reload-token off.
+	ReloadToken func() error
+	// OwnerID is the only user allowed to run /userlog reload-token, empty allows nobody
+	OwnerID string
+	// Publisher receives every event recorded in the history, milestones, and mass leave alerts, announced or not, nil publishes nothing
+	Publisher Publisher
+	// Presence renders the bot's status from PresenceData every PresenceInterval, nil shows a static status
+	Presence         *template.Template
+	PresenceInterval time.Duration
+	// Location is the timezone days start in for the presence and anniversaries, nil is UTC
+	Location *time.Location
+	// TrackPresence records when members come online and go offline, it needs the privileged presence intent
+	TrackPresence bool
+	// TrackFirstMessages records when members who join first post, and when members last posted, it needs the guild messages intent
+	TrackFirstMessages bool
+	// TrackScheduledEvents records which members RSVP to scheduled events, it needs the guild scheduled events intent
+	TrackScheduledEvents bool
+	// TrackInvites records which invite members joined with, it needs the guild invites intent and the Manage Server permission
+	TrackInvites bool
+	// CrossGuildWindow alerts moderators instead of announcing joins of members who left or were banned from another tracked guild within it, 0 disables it
+	CrossGuildWindow time.Duration
+	// EventWorkers handle member events, events about the same user are handled in order. 0 is DefaultEventWorkers
+	EventWorkers int
+	// EventQueueSize is how many events may wait for the workers before they are dropped and a sync is scheduled. 0 is DefaultEventQueueSize
+	EventQueueSize int
+}
+
+// Publisher forwards events to external consumers, it must not block for long.
+// It is implemented by *bus.Bus.
+type Publisher interface {
+	Publish(event notify.Event)
+}
+
+// AvatarArchive saves avatar images, it is implemented by *avatars.Archive
+type AvatarArchive interface {
+	Save(userID, hash string) error
+	Forget(userID string) error
+}
+
+// Bot tracks the members of one or more guilds
+type Bot struct {
+	store   Store
+	options Options
+	chunks  *chunkCollector
+	queue   *eventQueue
+
+	// guilds maps guild IDs to their trackers, it is not modified after startup
+	guilds map[string]*Guild
+	// shards are the sessions the handlers were added to
+	shards *Shards
+
+	// guildNames maps the IDs of tracked guilds to their names, once received
+	namesLock  sync.Mutex
+	guildNames map[string]string
+
+	resyncLock   sync.Mutex
+	disconnected bool
+	resyncTimer  *time.Timer
+	// dropped is how many events were dropped since the last sync was scheduled for them
+	dropped int
+
+	presenceOnce    sync.Once
+	anniversaryOnce sync.Once
+
+	// ctx is canceled by Close, stopping syncs and background work
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func New(store Store, options Options) *Bot {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Bot{
+		store:      store,
+		options:    options,
+		chunks:     newChunkCollector(),
+		queue:      newEventQueue(options.EventWorkers, options.EventQueueSize),
+		guilds:     map[string]*Guild{},
+		guildNames: map[string]string{},
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Close stops syncs and background work, waits for events being handled,
+// and leaves the announcements deferred by quiet hours in the outbox. Events arriving afterwards are ignored.
+// It returns the context's error if that doesn't finish in time.
+func (b *Bot) Close(ctx context.Context) error {
+	b.cancel()
+	b.resyncLock.Lock()
+	if b.resyncTimer != nil {
+		b.resyncTimer.Stop()
+	}
+	b.resyncLock.Unlock()
+
+	if err := b.queue.close(ctx); err != nil {
+		return err
+	}
+	closed := make(chan struct{})
+	go func() {
+		for _, g := range b.guilds {
+			g.close()
+		}
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// AddGuild starts tracking a guild, loading its known members from the store.
+// Guilds must be added before the session is opened.
+func (b *Bot) AddGuild(guildID string) (*Guild, error) {
+	g := newGuild(guildID, b)
+	if err := g.load(); err != nil {
+		return nil, err
+	}
+	b.guilds[guildID] = g
+	return g, nil
+}
+
+// Guild returns a tracked guild
+func (b *Bot) Guild(guildID string) (*Guild, bool) {
+	g, ok := b.guilds[guildID]
+	return g, ok
+}
+
+// GuildIDs returns the IDs of all tracked guilds
+func (b *Bot) GuildIDs() []string {
+	guildIDs := make([]string, 0, len(b.guilds))
+	for guildID := range b.guilds {
+		guildIDs = append(guildIDs, guildID)
+	}
+	return guildIDs
+}
+
+// AddHandlers registers the Discord event handlers on every shard, they must not be opened yet
+func (b *Bot) AddHandlers(shards *Shards) {
+	b.shards = shards
+	for _, s := range shards.sessions {
+		s.AddHandler(b.ready)
+		b.addQueuedHandlers(s)
+		s.AddHandler(b.guildCreate)
+		s.AddHandler(b.guildScheduledEventCreate)
+		s.AddHandler(b.guildScheduledEventUpdate)
+		s.AddHandler(b.inviteCreate)
+		s.AddHandler(b.interactionCreate)
+		s.AddHandler(b.guildMembersChunk)
+		s.AddHandler(b.disconnect)
+		s.AddHandler(b.resumed)
+	}
+}
+
+// SyncAll reconciles the known state of every guild with the server.
+// Canceling ctx stops it between pages of members, without reconciling the guild being synced.
+func (b *Bot) SyncAll(ctx context.Context, s Session) {
+	for _, g := range b.guilds {
+		if ctx.Err() != nil {
+			return
+		}
+		g.syncMembersFromServer(ctx, s)
+	}
+}
+
+func (b *Bot) ready(s *discordgo.Session, event *discordgo.Ready) {
+	if b.options.Presence == nil {
+		s.UpdateGameStatus(0, "hello")
+	} else {
+		// every new session starts without a presence
+		b.updatePresence(s)
+		b.presenceOnce.Do(func() {
+			go b.refreshPresence(b.shards)
+		})
+	}
+	b.anniversaryOnce.Do(func() {
+		go b.scheduleAnniversaries()
+	})
+	b.registerCommands(s, event)
+	// a fresh Ready after a disconnect means the session couldn't be resumed
+	b.scheduleResync(b.shards)
+}
+
+func (b *Bot) resumed(s *discordgo.Session, event *discordgo.Resumed) {
+	b.scheduleResync(b.shards)
+}
+
+func (b *Bot) disconnect(s *discordgo.Session, event *discordgo.Disconnect) {
+	b.resyncLock.Lock()
+	defer b.resyncLock.Unlock()
+	b.disconnected = true
+}
+
+// scheduleResync syncs all guilds shortly after reconnecting, if we were disconnected.
+// Flapping connections only sync once they settle.
+func (b *Bot) scheduleResync(s Session) {
+	b.resyncLock.Lock()
+	defer b.resyncLock.Unlock()
+
+	if !b.disconnected {
+		return
+	}
+	b.disconnected = false
+
+	if b.resyncTimer != nil {
+		b.resyncTimer.Stop()
+	}
+	log.Printf("reconnected, syncing in %v", resyncDelay)
+	b.resyncTimer = time.AfterFunc(resyncDelay, func() {
+		log.Println("Performing post-reconnect sync")
+		b.SyncAll(b.ctx, s)
+	})
+}
+
+func (b *Bot) guildMemberAdd(s *discordgo.Session, m *discordgo.GuildMemberAdd, received time.Time) {
+	g, ok := b.guilds[m.GuildID]
+	if !ok || m.User == nil {
+		return
+	}
+	var invite *joinDetails
+	if b.options.TrackInvites {
+		invite = g.invites.used(s, g.ID)
+	}
+	g.memberAddedAt(received, m.User.ID, memberFromDiscord(m.Member), invite)
+}
+
+func (b *Bot) guildMembersChunk(s *discordgo.Session, c *discordgo.GuildMembersChunk) {
+	b.chunks.receive(c)
+}
+
+func (b *Bot) guildMemberUpdate(s *discordgo.Session, m *discordgo.GuildMemberUpdate, received time.Time) {
+	g, ok := b.guilds[m.GuildID]
+	if !ok || m.Member == nil || m.User == nil {
+		return
+	}
+	b.memberUpdated(s, s.State, g, received, m.User.ID, memberFromDiscord(m.Member))
+}
+
+// memberFromDiscord converts a member with a non-nil User
+func memberFromDiscord(m *discordgo.Member) store.Member {
+	member := store.Member{
+		User: store.User{
+			Username:      m.User.Username,
+			Discriminator: m.User.Discriminator,
+		},
+		JoinedAt: m.JoinedAt,
+		Pending:  m.Pending,
+		Avatar:   m.User.Avatar,
+		Nick:     m.Nick,
+	}
+	if len(m.Roles) > 0 {
+		member.Roles = append([]string{}, m.Roles...)
+		sort.Strings(member.Roles)
+	}
+	if m.PremiumSince != nil {
+		member.PremiumSince = *m.PremiumSince
+	}
+	if m.CommunicationDisabledUntil != nil {
+		member.TimeoutUntil = *m.CommunicationDisabledUntil
+	}
+	return member
+}
+
+func (b *Bot) guildMemberRemove(s *discordgo.Session, m *discordgo.GuildMemberRemove, received time.Time) {
+	g, ok := b.guilds[m.GuildID]
+	if !ok || m.User == nil {
+		return
+	}
+	g.memberRemovedAt(received, m.User.ID)
+}
+
+// forgetAvatars removes the archived avatars of a user forgotten by a guild, unless another guild still tracks them
+func (b *Bot) forgetAvatars(guildID, discordID string) {
+	if b.options.AvatarArchive == nil {
+		return
+	}
+	for id, g := range b.guilds {
+		if id != guildID && g.tracks(discordID) {
+			return
+		}
+	}
+	if err := b.options.AvatarArchive.Forget(discordID); err != nil {
+		log.Printf("failed to forget the archived avatars of '%v': %v", discordID, err)
+	}
+}
+
+// archiveAvatars saves avatars in the background, if archiving is enabled
+func (b *Bot) archiveAvatars(userID string, hashes ...string) {
+	if b.options.AvatarArchive == nil {
+		return
+	}
+	go func() {
+		for _, hash := range hashes {
+			if err := b.options.AvatarArchive.Save(userID, hash); err != nil {
+				log.Printf("failed to archive avatar %v of '%v': %v", hash, userID, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// chunkTimeout is how long to wait for all member chunks of a request
+const chunkTimeout = 5 * time.Minute
+
+// chunkCollector gathers GuildMembersChunk events belonging to our RequestGuildMembers calls
+type chunkCollector struct {
+	lock      sync.Mutex
+	lastNonce int
+	pending   map[string]*chunkRequest
+}
+
+type chunkRequest struct {
+	members  []*discordgo.Member
+	received int
+	done     chan struct{}
+}
+
+func newChunkCollector() *chunkCollector {
+	return &chunkCollector{
+		pending: map[string]*chunkRequest{},
+	}
+}
+
+// requestMembers requests every member of a guild over the gateway and waits for all chunks to arrive
+func (c *chunkCollector) requestMembers(ctx context.Context, s Session, guildID string) ([]*discordgo.Member, error) {
+	c.lock.Lock()
+	c.lastNonce++
+	nonce := "dul-" + strconv.Itoa(c.lastNonce)
+	request := &chunkRequest{done: make(chan struct{})}
+	c.pending[nonce] = request
+	c.lock.Unlock()
+
+	defer func() {
+		c.lock.Lock()
+		delete(c.pending, nonce)
+		c.lock.Unlock()
+	}()
+
+	if err := s.RequestGuildMembers(guildID, "", 0, nonce, false); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-request.done:
+		// receive doesn't touch the request after closing done
+		return request.members, nil
+	case <-time.After(chunkTimeout):
+		return nil, errors.New("timed out waiting for member chunks")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *chunkCollector) receive(chunk *discordgo.GuildMembersChunk) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	request, ok := c.pending[chunk.Nonce]
+	if !ok {
+		return
+	}
+	request.members = append(request.members, chunk.Members...)
+	request.received++
+	if request.received == chunk.ChunkCount {
+		close(request.done)
+		delete(c.pending, chunk.Nonce)
+	}
+}
@@ -0,0 +1,521 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/i18n"
+)
+
+// moderatorPermission is required to see the /userlog command, some subcommands require more
+var moderatorPermission int64 = discordgo.PermissionKickMembers
+
+var userlogCommand = &discordgo.ApplicationCommand{
+	Name:                     "userlog",
+	Description:              "User Log commands",
+	DefaultMemberPermissions: &moderatorPermission,
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "forget",
+			Description: "Delete everything stored about a user (admin only)",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "User (or user ID) to forget",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "stats",
+			Description: "Show member growth and churn",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "retention",
+			Description: "Show how many new members stay",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "recent",
+			Description: "Show the latest joins and leaves",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "count",
+					Description: fmt.Sprintf(recentCountDescription, recentDefaultCount),
+					MinValue:    &recentMinCount,
+					MaxValue:    recentMaxCount,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "veterans",
+			Description: "List the longest-standing members",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "names",
+			Description: "List every username and nickname seen for a user",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "User (or user ID) to look up",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "lastseen",
+			Description: "Show when a user was last seen online",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "User (or user ID) to look up",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "inactive",
+			Description: "List members without activity, with a CSV export",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "period",
+					Description: "How long members have been inactive (default 90d)",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "30 days", Value: "30d"},
+						{Name: "90 days", Value: "90d"},
+						{Name: "180 days", Value: "180d"},
+						{Name: "1 year", Value: "1y"},
+					},
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "diff",
+			Description: "List who joined and left since an earlier daily member list, with a CSV export",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "period",
+					Description: "How far back to compare (default 7d)",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "1 day", Value: "1d"},
+						{Name: "7 days", Value: "7d"},
+						{Name: "30 days", Value: "30d"},
+					},
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "whois",
+			Description: "Show everything known about a user",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "User (or user ID) to look up",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "graph",
+			Description: "Show a chart of the member count",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "period",
+					Description: "How far back to go (default 30d)",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "30 days", Value: "30d"},
+						{Name: "90 days", Value: "90d"},
+						{Name: "1 year", Value: "1y"},
+					},
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "report",
+			Description: "Attach an HTML report of the last month",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "watch",
+			Description: "Send alerts when a user joins, leaves, or changes their name",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "User (or user ID) to watch",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "unwatch",
+			Description: "Stop watching a user",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "User (or user ID) to stop watching",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "watchlist",
+			Description: "List the watched users",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "event-attendance",
+			Description: "Show who is interested in scheduled events",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "event",
+					Description: "Event name or ID, lists the latest events without it",
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "setup",
+			Description: "Pick the announcement channel, style, and events (admin only)",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "reload-token",
+			Description: "Read the bot token again and reconnect with it (bot owner only)",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+			Name:        "note",
+			Description: "Keep notes about users, shown by /userlog whois",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "add",
+					Description: "Add a note about a user, only moderators see it",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Name:        "user",
+							Description: "User (or user ID) the note is about",
+							Required:    true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "text",
+							Description: "The note",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+			Name:        "config",
+			Description: "Change this server's settings (admin only)",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "show",
+					Description: "Show the settings changed with /userlog config",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "set",
+					Description: "Change a setting, overriding the config file",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "key",
+							Description: "Setting name, like channel_id or template_join",
+							Required:    true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "value",
+							Description: "New value, lists are comma-separated",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "unset",
+					Description: "Go back to the config file value of a setting",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "key",
+							Description: "Setting name",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+// recentCountDescription describes the count option of /userlog recent, formatted with the default count
+const recentCountDescription = "Events per page (default %v)"
+
+func init() {
+	localizeCommand(userlogCommand)
+}
+
+// localizeCommand translates the descriptions and choice names of a command and its options,
+// Discord shows them in the user's client language
+func localizeCommand(command *discordgo.ApplicationCommand) {
+	localizations := map[discordgo.Locale]string{}
+	for _, language := range translations() {
+		localizations[discordgo.Locale(language.Code)] = language.Translate(command.Description)
+	}
+	command.DescriptionLocalizations = &localizations
+	localizeOptions(command.Options)
+}
+
+func localizeOptions(options []*discordgo.ApplicationCommandOption) {
+	for _, option := range options {
+		option.DescriptionLocalizations = map[discordgo.Locale]string{}
+		for _, language := range translations() {
+			description := language.Translate(option.Description)
+			if option.Name == "count" {
+				description = language.Sprintf(recentCountDescription, recentDefaultCount)
+			}
+			option.DescriptionLocalizations[discordgo.Locale(language.Code)] = description
+		}
+		for _, choice := range option.Choices {
+			choice.NameLocalizations = map[discordgo.Locale]string{}
+			for _, language := range translations() {
+				choice.NameLocalizations[discordgo.Locale(language.Code)] = language.Translate(choice.Name)
+			}
+		}
+		localizeOptions(option.Options)
+	}
+}
+
+// translations returns every language except English
+func translations() []*i18n.Language {
+	languages := []*i18n.Language{}
+	for _, code := range i18n.Codes() {
+		if language, _ := i18n.Lookup(code); language != i18n.English {
+			languages = append(languages, language)
+		}
+	}
+	return languages
+}
+
+type subcommandHandler func(b *Bot, s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData
+
+type subcommand struct {
+	// permission is required in addition to moderatorPermission
+	permission int64
+	handler    subcommandHandler
+}
+
+var userlogSubcommands = map[string]subcommand{
+	"forget":    {discordgo.PermissionAdministrator, (*Bot).commandForget},
+	"stats":     {0, (*Bot).commandStats},
+	"retention": {0, (*Bot).commandRetention},
+	"recent":    {0, (*Bot).commandRecent},
+	"veterans":  {0, (*Bot).commandVeterans},
+	"names":     {0, (*Bot).commandNames},
+	"lastseen":  {0, (*Bot).commandLastSeen},
+	"inactive":  {0, (*Bot).commandInactive},
+	"diff":      {0, (*Bot).commandDiff},
+	"whois":     {0, (*Bot).commandWhois},
+	"graph":     {0, (*Bot).commandGraph},
+	"report":    {0, (*Bot).commandReport},
+	"watch":     {0, (*Bot).commandWatch},
+	"unwatch":   {0, (*Bot).commandUnwatch},
+	"watchlist": {0, (*Bot).commandWatchlist},
+	"note":      {0, (*Bot).commandNote},
+	"setup":     {discordgo.PermissionAdministrator, (*Bot).commandSetup},
+	"config":    {discordgo.PermissionAdministrator, (*Bot).commandConfig},
+	// reload-token affects every guild of the bot, it also checks the user is the bot owner
+	"reload-token": {discordgo.PermissionAdministrator, (*Bot).commandReloadToken},
+	// event-attendance answers that it's off unless scheduled events are tracked
+	"event-attendance": {0, (*Bot).commandEventAttendance},
+}
+
+// componentHandler handles a button press, menu selection, or modal submission,
+// args are the colon-separated parts of the custom ID after the component name.
+// Responses with a custom ID open a modal instead of updating the message.
+type componentHandler func(b *Bot, s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, args []string) *discordgo.InteractionResponseData
+
+// userlogComponents are keyed by the component name in custom IDs like "userlog:<name>:<args...>"
+var userlogComponents = map[string]component{
+	"recent":   {0, (*Bot).componentRecent},
+	"veterans": {0, (*Bot).componentVeterans},
+	"setup":    {discordgo.PermissionAdministrator, (*Bot).componentSetup},
+	// leavereason notes are posted for members who couldn't be sent the leave survey
+	"leavereason": {0, (*Bot).componentLeaveReason},
+	// quick actions are offered under leave and ban announcements
+	"quickban":  {discordgo.PermissionBanMembers, (*Bot).componentQuickBan},
+	"quicknote": {0, (*Bot).componentQuickNote},
+}
+
+type component struct {
+	// permission is required in addition to moderatorPermission
+	permission int64
+	handler    componentHandler
+}
+
+func textResponse(content string) *discordgo.InteractionResponseData {
+	return &discordgo.InteractionResponseData{Content: content}
+}
+
+// ephemeral shows a response only to whoever interacted, components answer with a new message instead of updating theirs
+func ephemeral(response *discordgo.InteractionResponseData) *discordgo.InteractionResponseData {
+	response.Flags |= discordgo.MessageFlagsEphemeral
+	return response
+}
+
+// registerCommands registers the commands in the guilds of a shard
+func (b *Bot) registerCommands(s *discordgo.Session, event *discordgo.Ready) {
+	for guildID := range b.guilds {
+		if ShardID(guildID, s.ShardCount) != s.ShardID {
+			continue
+		}
+		if _, err := s.ApplicationCommandCreate(event.User.ID, guildID, userlogCommand); err != nil {
+			log.Printf("failed to register /%v command in guild '%v': %v", userlogCommand.Name, guildID, err)
+		}
+	}
+}
+
+func hasPermission(member *discordgo.Member, permission int64) bool {
+	if member.Permissions&discordgo.PermissionAdministrator == discordgo.PermissionAdministrator {
+		return true
+	}
+	return member.Permissions&permission == permission
+}
+
+func (b *Bot) interactionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// leave surveys are answered in DMs, by users who aren't members anymore
+	if i.GuildID == "" && i.User != nil && i.Type == discordgo.InteractionMessageComponent {
+		b.handleSurveyAnswer(s, i)
+		return
+	}
+	g, ok := b.guilds[i.GuildID]
+	if !ok || i.Member == nil {
+		return
+	}
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		b.handleCommand(s, i, g)
+	case discordgo.InteractionMessageComponent, discordgo.InteractionModalSubmit:
+		b.handleComponent(s, i, g)
+	}
+}
+
+func (b *Bot) handleCommand(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild) {
+	data := i.ApplicationCommandData()
+	if data.Name != userlogCommand.Name || len(data.Options) == 0 {
+		return
+	}
+
+	var response *discordgo.InteractionResponseData
+	if sub, ok := userlogSubcommands[data.Options[0].Name]; !ok {
+		response = textResponse(g.language().Translate("Unknown command."))
+	} else if !hasPermission(i.Member, moderatorPermission|sub.permission) {
+		response = textResponse(g.language().Translate("You don't have permission to use this command."))
+	} else {
+		response = sub.handler(b, s, i, g, data.Options[0].Options)
+	}
+	response.Flags |= discordgo.MessageFlagsEphemeral
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: response,
+	})
+	if err != nil {
+		log.Printf("failed to respond to interaction: %v", err)
+	}
+}
+
+func (b *Bot) handleComponent(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild) {
+	customID := ""
+	if i.Type == discordgo.InteractionModalSubmit {
+		customID = i.ModalSubmitData().CustomID
+	} else {
+		customID = i.MessageComponentData().CustomID
+	}
+	parts := strings.Split(customID, ":")
+	if len(parts) < 2 || parts[0] != userlogCommand.Name {
+		return
+	}
+
+	responseType := discordgo.InteractionResponseUpdateMessage
+	var response *discordgo.InteractionResponseData
+	if component, ok := userlogComponents[parts[1]]; !ok {
+		return
+	} else if !hasPermission(i.Member, moderatorPermission|component.permission) {
+		responseType = discordgo.InteractionResponseChannelMessageWithSource
+		response = textResponse(g.language().Translate("You don't have permission to use this command."))
+		response.Flags |= discordgo.MessageFlagsEphemeral
+	} else {
+		response = component.handler(b, s, i, g, parts[2:])
+		// only modals have a custom ID
+		if response.CustomID != "" {
+			responseType = discordgo.InteractionResponseModal
+		} else if response.Flags&discordgo.MessageFlagsEphemeral != 0 {
+			responseType = discordgo.InteractionResponseChannelMessageWithSource
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: responseType,
+		Data: response,
+	})
+	if err != nil {
+		log.Printf("failed to respond to component interaction: %v", err)
+	}
+}
+
+func (b *Bot) commandForget(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	discordID := options[0].UserValue(nil).ID
+	lang := g.language()
+
+	// other servers' admins decide about their own data, the command line forgets a user everywhere
+	affected, member, err := g.forget(discordID)
+	if err != nil {
+		log.Printf("failed to forget '%v': %v", discordID, err)
+		return textResponse(lang.Sprintf("Failed to forget <@%v>, check the logs.", discordID))
+	}
+	// archived avatars aren't per server, they are kept while another server still tracks the user
+	b.forgetAvatars(g.ID, discordID)
+	log.Printf("[forget] requested by '%v'", i.Member.User.ID)
+
+	switch {
+	case member:
+		return textResponse(lang.Sprintf("Forgot everything this server stored about <@%v>, except that they are a member.", discordID))
+	case affected == 0:
+		return textResponse(lang.Sprintf("Nothing was stored about <@%v>.", discordID))
+	}
+	return textResponse(lang.Sprintf("Forgot everything this server stored about <@%v>.", discordID))
+}
@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/i18n"
+)
+
+func TestTranslatedResponses(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	german, _ := i18n.Lookup("de")
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{Language: german})
+
+	if response := g.bot.watchlist(g); response.Content != "Niemand wird beobachtet." {
+		t.Errorf("unexpected empty watch list %+v", response)
+	}
+	if response := g.bot.unwatch(g, "1"); response.Content != "<@1> wird nicht beobachtet." {
+		t.Errorf("unexpected unwatch response %+v", response)
+	}
+}
+
+func TestLocalizedCommand(t *testing.T) {
+	if actual := (*userlogCommand.DescriptionLocalizations)[discordgo.German]; actual != "User-Log-Befehle" {
+		t.Errorf("unexpected German command description %q", actual)
+	}
+	var recent *discordgo.ApplicationCommandOption
+	for _, option := range userlogCommand.Options {
+		if option.Name == "recent" {
+			recent = option
+		}
+	}
+	if actual := recent.Options[0].DescriptionLocalizations[discordgo.French]; actual != "Événements par page (10 par défaut)" {
+		t.Errorf("unexpected French count description %q", actual)
+	}
+	if _, ok := recent.DescriptionLocalizations["en"]; ok {
+		t.Errorf("expected English to be left to the default description")
+	}
+}
+
+func TestReloadToken(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	var err error
+	reloads := 0
+	g := newTestGuildWithOptions(t, st, session, Options{OwnerID: "1", ReloadToken: func() error {
+		reloads++
+		return err
+	}})
+
+	if response := g.bot.reloadToken(g, "2"); response.Content != "Only the bot owner can reload the token." {
+		t.Errorf("expected other users to be refused, got %q", response.Content)
+	}
+
+	for _, tc := range []struct {
+		err      error
+		expected string
+	}{
+		{nil, "Switched to the new token, reconnecting."},
+		{ErrTokenUnchanged, "The token didn't change."},
+		{errors.New("401 Unauthorized"), "Failed to reload the token, check the logs."},
+	} {
+		err = tc.err
+		if response := g.bot.reloadToken(g, "1"); response.Content != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, response.Content)
+		}
+	}
+	if reloads != 3 {
+		t.Errorf("expected 3 reloads, got %v", reloads)
+	}
+}
@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"log"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+)
+
+// setGuildName remembers the name of a tracked guild, for alerts about other guilds
+func (b *Bot) setGuildName(guildID, name string) {
+	b.namesLock.Lock()
+	defer b.namesLock.Unlock()
+	b.guildNames[guildID] = name
+}
+
+// guildName returns the name of a tracked guild, or its ID until it was received
+func (b *Bot) guildName(guildID string) string {
+	b.namesLock.Lock()
+	defer b.namesLock.Unlock()
+	if name := b.guildNames[guildID]; name != "" {
+		return name
+	}
+	return guildID
+}
+
+// crossGuildLocked adds the newest leave or ban of a joining member in another tracked guild within the cross-guild window to their join,
+// returning whether there was one
+func (g *Guild) crossGuildLocked(event *notify.Event) bool {
+	window := g.bot.options.CrossGuildWindow
+	if window <= 0 || len(g.bot.guilds) < 2 {
+		return false
+	}
+	departure, ok, err := g.store.LastDeparture(event.UserID, g.ID, event.At.Add(-window))
+	if err != nil {
+		log.Printf("failed to look up whether '%v' left another guild: %v", event.UserID, err)
+		return false
+	}
+	if !ok || departure.At.After(event.At) {
+		return false
+	}
+	// guilds that aren't tracked anymore may still be in the history
+	if _, tracked := g.bot.guilds[departure.GuildID]; !tracked {
+		return false
+	}
+	event.OtherGuildID = departure.GuildID
+	event.OtherGuild = g.bot.guildName(departure.GuildID)
+	event.OtherEvent = departure.Event
+	event.OtherAt = departure.At
+	return true
+}
+
+// crossGuildAlertLocked alerts moderators about a member who joined after leaving or being banned from another tracked guild,
+// instead of announcing the join. Like watched user alerts, it skips quiet hours.
+func (g *Guild) crossGuildAlertLocked(event notify.Event) error {
+	event.Type = notify.EventCrossGuildJoin
+	g.publishLocked(&event)
+	log.Printf("alerting about '%v' joining after their %v in guild '%v'", event.UserID, event.OtherEvent, event.OtherGuildID)
+	return g.queueLocked(event, true)
+}
@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestCrossGuildJoin(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	session.setMembers("200", member("2", "bob", "0"), member("3", "carol", "0"))
+	g := newTestGuildWithGuildOptions(t, st, session, Options{CrossGuildWindow: 7 * 24 * time.Hour}, GuildOptions{})
+	other, err := g.bot.AddGuild("200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, _ := notify.ParseTemplates(nil)
+	other.Configure(GuildOptions{Notifier: notify.NewChannel(session, testChannelID, templates), Announce: defaultAnnounce})
+	g.bot.setGuildName("200", "Other Server")
+	g.syncMembersFromServer(context.Background(), session)
+	other.syncMembersFromServer(context.Background(), session)
+
+	other.memberRemoved("2")
+	session.takeSent()
+
+	// bob left the other guild just now, and is flagged instead of announced
+	g.memberAdded("2", store.Member{User: store.User{Username: "bob", Discriminator: "0"}, JoinedAt: time.Now()})
+	sent := session.takeSent()
+	if len(sent) != 1 || !strings.HasPrefix(sent[0].content, "⚠️ <@2> (bob) joined the server") || !strings.Contains(sent[0].content, "They left Other Server <t:") {
+		t.Errorf("expected a cross-guild alert, got %+v", sent)
+	}
+
+	// carol is still in the other guild
+	g.memberAdded("3", store.Member{User: store.User{Username: "carol", Discriminator: "0"}, JoinedAt: time.Now()})
+	assertSent(t, session, "<@3> (carol) joined the server, now 3 members")
+
+	// leaves outside the window aren't flagged
+	g.memberRemoved("2")
+	session.takeSent()
+	g.memberAdded("2", store.Member{User: store.User{Username: "bob", Discriminator: "0"}, JoinedAt: time.Now().Add(8 * 24 * time.Hour)})
+	assertSent(t, session, "<@2> (bob) joined the server, now 3 members")
+}
@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// diffPeriods are the /userlog diff choices, in days
+var diffPeriods = map[string]int{
+	"1d":  1,
+	"7d":  7,
+	"30d": 30,
+}
+
+// diffListSize is how many joined and left members the response lists each, the CSV has all of them
+const diffListSize = 15
+
+// memberListLocked returns the members to record as the member list of the UTC day of now after a sync,
+// nil if the list of that day was already recorded
+func (g *Guild) memberListLocked(now time.Time) []string {
+	day := now.UTC().Truncate(24 * time.Hour)
+	if g.memberListDay.Equal(day) {
+		return nil
+	}
+	g.memberListDay = day
+	discordIDs := make([]string, 0, len(g.state))
+	for discordID := range g.state {
+		discordIDs = append(discordIDs, discordID)
+	}
+	return discordIDs
+}
+
+// recordMemberList stores a member list returned by memberListLocked, without holding the guild's lock.
+// It writes to the bot's store, g.store may be the transaction of an event being handled meanwhile.
+func (run *memberSync) recordMemberList(now time.Time, discordIDs []string) {
+	if discordIDs == nil {
+		return
+	}
+	g := run.g
+	if _, err := g.bot.store.RecordMemberList(g.ID, now, discordIDs); err != nil {
+		log.Printf("failed to record the member list of guild '%v': %v", g.ID, err)
+		// the next sync tries again
+		run.step(func() { g.memberListDay = time.Time{} })
+	}
+}
+
+func (b *Bot) commandDiff(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	period := "7d"
+	for _, option := range options {
+		if option.Name == "period" {
+			period = option.StringValue()
+		}
+	}
+	return b.diffResponse(g, period, time.Now())
+}
+
+// MemberDiff is who joined and left since an earlier member list, sorted by ID
+type MemberDiff struct {
+	Joined []string
+	Left   []string
+}
+
+// DiffMembers compares the current members with an earlier member list
+func DiffMembers(before map[string]bool, current map[string]store.Member) MemberDiff {
+	diff := MemberDiff{Joined: []string{}, Left: []string{}}
+	for discordID := range current {
+		if !before[discordID] {
+			diff.Joined = append(diff.Joined, discordID)
+		}
+	}
+	for discordID := range before {
+		if _, ok := current[discordID]; !ok {
+			diff.Left = append(diff.Left, discordID)
+		}
+	}
+	sort.Strings(diff.Joined)
+	sort.Strings(diff.Left)
+	return diff
+}
+
+// members returns a copy of the known members
+func (g *Guild) members() map[string]store.Member {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	members := make(map[string]store.Member, len(g.state))
+	for discordID, member := range g.state {
+		members[discordID] = member
+	}
+	return members
+}
+
+func (b *Bot) diffResponse(g *Guild, period string, now time.Time) *discordgo.InteractionResponseData {
+	lang := g.language()
+	days, ok := diffPeriods[period]
+	if !ok {
+		return textResponse(lang.Translate("Unknown period."))
+	}
+	before, day, ok, err := b.store.MemberList(g.ID, now.AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("failed to load the member list of guild '%v': %v", g.ID, err)
+		return textResponse(lang.Translate("Failed to load the member list, check the logs."))
+	}
+	if !ok {
+		return textResponse(lang.Sprintf("There is no member list from %v days ago yet. Syncs record one a day, kept for %v days.", days, store.MemberListDays))
+	}
+	current := g.members()
+	diff := DiffMembers(before, current)
+
+	var description strings.Builder
+	for _, section := range []struct {
+		title   string
+		members []string
+	}{
+		{lang.Sprintf("Joined (%v)", len(diff.Joined)), diff.Joined},
+		{lang.Sprintf("Left (%v)", len(diff.Left)), diff.Left},
+	} {
+		fmt.Fprintf(&description, "**%v**\n", section.title)
+		listed := section.members
+		if len(listed) > diffListSize {
+			listed = listed[:diffListSize]
+		}
+		for _, discordID := range listed {
+			fmt.Fprintf(&description, "<@%v>", discordID)
+			if tag := current[discordID].User.Tag(); tag != "" {
+				fmt.Fprintf(&description, " (%v)", tag)
+			}
+			description.WriteString("\n")
+		}
+		if len(section.members) > diffListSize {
+			description.WriteString(lang.Sprintf("…and %v more, see the CSV.", len(section.members)-diffListSize) + "\n")
+		}
+		description.WriteString("\n")
+	}
+
+	response := &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       lang.Sprintf("Member Changes Since %v", day.Format("2006-01-02")),
+			Description: description.String(),
+			Footer:      &discordgo.MessageEmbedFooter{Text: lang.Sprintf("Compared with the member list of %v, %v → %v members", day.Format("2006-01-02"), lang.FormatNumber(len(before)), lang.FormatNumber(len(current)))},
+		}},
+	}
+	if len(diff.Joined)+len(diff.Left) > 0 {
+		response.Files = []*discordgo.File{{
+			Name:        "diff.csv",
+			ContentType: "text/csv",
+			Reader:      bytes.NewReader(DiffCSV(diff, current)),
+		}}
+	}
+	return response
+}
+
+// DiffCSV lists who joined and left with their user ID, and their tag if they are a member
+func DiffCSV(diff MemberDiff, current map[string]store.Member) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"change", "user_id", "username"})
+	for _, discordID := range diff.Joined {
+		w.Write([]string{"joined", discordID, current[discordID].User.Tag()})
+	}
+	for _, discordID := range diff.Left {
+		w.Write([]string{"left", discordID, ""})
+	}
+	w.Flush()
+	return buf.Bytes()
+}
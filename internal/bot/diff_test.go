@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	now := time.Now()
+	if response := g.bot.diffResponse(g, "7d", now); !strings.HasPrefix(response.Content, "There is no member list from 7 days ago yet.") {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	if response := g.bot.diffResponse(g, "2d", now); response.Content != "Unknown period." {
+		t.Fatalf("unexpected response %+v", response)
+	}
+
+	session.setMembers(testGuildID, member("2", "bob", "0"), member("3", "carol", "0"))
+	g.syncMembersFromServer(context.Background(), session)
+
+	response := g.bot.diffResponse(g, "7d", now.AddDate(0, 0, 7))
+	if len(response.Embeds) != 1 || len(response.Files) != 1 {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	description := response.Embeds[0].Description
+	for _, expected := range []string{"**Joined (1)**\n<@3> (carol)", "**Left (1)**\n<@1>\n"} {
+		if !strings.Contains(description, expected) {
+			t.Errorf("expected %q in %q", expected, description)
+		}
+	}
+	csv, err := io.ReadAll(response.Files[0].Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "change,user_id,username\njoined,3,carol\nleft,1,\n"; string(csv) != expected {
+		t.Errorf("expected %q, got %q", expected, csv)
+	}
+}
@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// joinEditor is implemented by notifiers that can edit join announcements, like *notify.Channel
+type joinEditor interface {
+	NotifyMessage(event notify.Event) (notify.Message, error)
+	Append(message notify.Message, event notify.Event) error
+}
+
+// notifyLocked sends an announcement right away, remembering join announcements if leaves edit them
+func (g *Guild) notifyLocked(event notify.Event) error {
+	editor, ok := g.notifier.(joinEditor)
+	if !g.editLeaves || !ok || event.Type != store.EventJoin {
+		return g.notifier.Notify(event)
+	}
+	message, err := editor.NotifyMessage(event)
+	if err != nil {
+		return err
+	}
+	err = g.store.SaveJoinMessage(g.ID, event.UserID, store.JoinMessage{
+		ChannelID: message.ChannelID,
+		MessageID: message.ID,
+		SentAt:    time.Now(),
+	})
+	if err != nil {
+		log.Fatalf("failed to save the join announcement of '%v': %v", event.UserID, err)
+	}
+	return nil
+}
+
+// editJoinLocked appends a leave to the member's join announcement, returning false if it should be announced instead.
+// Edits don't notify anyone, so they ignore quiet hours.
+func (g *Guild) editJoinLocked(event notify.Event) bool {
+	message, ok, err := g.store.TakeJoinMessage(g.ID, event.UserID)
+	if err != nil {
+		log.Fatalf("failed to load the join announcement of '%v': %v", event.UserID, err)
+	}
+	editor, editable := g.notifier.(joinEditor)
+	// a message set by the hook is announced as it is
+	if !g.editLeaves || !ok || !editable || event.Message != "" {
+		return false
+	}
+	event.Type = notify.EventLeaveEdit
+	if event.JoinedAt.IsZero() {
+		event.JoinedAt = message.SentAt
+	}
+	if err := editor.Append(notify.Message{ChannelID: message.ChannelID, ID: message.MessageID}, event); err != nil {
+		log.Printf("failed to edit the join announcement of '%v', announcing the leave instead: %v", event.UserID, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestEditLeaves(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{EditLeaves: true})
+	g.syncMembersFromServer(context.Background(), session)
+
+	g.memberAdded("2", store.Member{User: store.User{Username: "bob", Discriminator: "0"}, JoinedAt: time.Now().Add(-74 * time.Hour)})
+	assertSent(t, session, "<@2> (bob) joined the server, now 2 members")
+
+	// the leave is appended to the join announcement
+	g.memberRemoved("2")
+	assertSent(t, session)
+	if content := session.messages["1"]; content != "<@2> (bob) joined the server, now 2 members — left after 3 days, 2 hours" {
+		t.Errorf("unexpected edited announcement %q", content)
+	}
+
+	// members whose join wasn't announced, like ones found by the first sync, are announced as usual
+	g.memberRemoved("1")
+	assertSent(t, session, "<@1> (alice) left the server")
+
+	// leaving again edits the announcement of the rejoin
+	g.memberAdded("2", store.Member{User: store.User{Username: "bob", Discriminator: "0"}})
+	g.memberRemoved("2")
+	assertSent(t, session, "<@2> (bob) joined the server, now 1 member")
+	if content := session.messages["3"]; content != "<@2> (bob) joined the server, now 1 member — left after 0 seconds" {
+		t.Errorf("unexpected edited announcement %q", content)
+	}
+}
@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type sentMessage struct {
+	channelID string
+	content   string
+	// customIDs are the buttons sent with the message
+	customIDs []string
+}
+
+// fakeSession serves a fixed member list and records sent messages
+type fakeSession struct {
+	lock    sync.Mutex
+	members map[string][]*discordgo.Member
+	sent    []sentMessage
+	// messages maps the IDs of sent messages to their current content
+	messages         map[string]string
+	guildMemberCalls int
+
+	// deliverChunk receives the chunks of RequestGuildMembers calls, like the gateway event handler would
+	deliverChunk func(*discordgo.GuildMembersChunk)
+	// fetchedPage runs after GuildMembers fetched a page, before it is returned
+	fetchedPage func(after string)
+}
+
+func newFakeSession() *fakeSession {
+	return &fakeSession{members: map[string][]*discordgo.Member{}, messages: map[string]string{}}
+}
+
+func (f *fakeSession) setMembers(guildID string, members ...*discordgo.Member) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].User.ID < members[j].User.ID
+	})
+	f.members[guildID] = members
+}
+
+func (f *fakeSession) GuildMembers(guildID string, after string, limit int) ([]*discordgo.Member, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.guildMemberCalls++
+
+	page := []*discordgo.Member{}
+	for _, member := range f.members[guildID] {
+		if member.User.ID <= after {
+			continue
+		}
+		if len(page) == limit {
+			break
+		}
+		page = append(page, member)
+	}
+	if f.fetchedPage != nil {
+		f.lock.Unlock()
+		f.fetchedPage(after)
+		f.lock.Lock()
+	}
+	return page, nil
+}
+
+func (f *fakeSession) RequestGuildMembers(guildID, query string, limit int, nonce string, presences bool) error {
+	f.lock.Lock()
+	members := f.members[guildID]
+	f.lock.Unlock()
+
+	const chunkSize = 1000
+	chunkCount := (len(members) + chunkSize - 1) / chunkSize
+	if chunkCount == 0 {
+		chunkCount = 1
+	}
+	go func() {
+		for i := 0; i < chunkCount; i++ {
+			end := (i + 1) * chunkSize
+			if end > len(members) {
+				end = len(members)
+			}
+			f.deliverChunk(&discordgo.GuildMembersChunk{
+				GuildID:    guildID,
+				Members:    members[i*chunkSize : end],
+				ChunkIndex: i,
+				ChunkCount: chunkCount,
+				Nonce:      nonce,
+			})
+		}
+	}()
+	return nil
+}
+
+func (f *fakeSession) ChannelMessageSend(channelID string, content string) (*discordgo.Message, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.sent = append(f.sent, sentMessage{channelID: channelID, content: content})
+	id := fmt.Sprint(len(f.messages) + 1)
+	f.messages[id] = content
+	return &discordgo.Message{ID: id, ChannelID: channelID, Content: content}, nil
+}
+
+func (f *fakeSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	message, err := f.ChannelMessageSend(channelID, data.Content)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	sent := &f.sent[len(f.sent)-1]
+	for _, row := range data.Components {
+		for _, button := range row.(discordgo.ActionsRow).Components {
+			sent.customIDs = append(sent.customIDs, button.(discordgo.Button).CustomID)
+		}
+	}
+	return message, err
+}
+
+func (f *fakeSession) ChannelMessage(channelID, messageID string) (*discordgo.Message, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	content, ok := f.messages[messageID]
+	if !ok {
+		return nil, fmt.Errorf("unknown message %v", messageID)
+	}
+	return &discordgo.Message{ID: messageID, ChannelID: channelID, Content: content}, nil
+}
+
+func (f *fakeSession) ChannelMessageEdit(channelID, messageID, content string) (*discordgo.Message, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.messages[messageID] = content
+	return &discordgo.Message{ID: messageID, ChannelID: channelID, Content: content}, nil
+}
+
+// takeSent returns and clears the sent messages
+func (f *fakeSession) takeSent() []sentMessage {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	sent := f.sent
+	f.sent = nil
+	return sent
+}
+
+func member(discordID, username, discriminator string) *discordgo.Member {
+	return &discordgo.Member{
+		User: &discordgo.User{
+			ID:            discordID,
+			Username:      username,
+			Discriminator: discriminator,
+		},
+	}
+}
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/i18n"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// lastMessagePrecision is how often the last message of a member is written, chatty members would write every message otherwise
+const lastMessagePrecision = time.Hour
+
+func (b *Bot) messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if !b.options.TrackFirstMessages || m.Author == nil || m.Author.Bot || m.WebhookID != "" {
+		return
+	}
+	g, ok := b.guilds[m.GuildID]
+	if !ok {
+		return
+	}
+	at := m.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	g.messagePosted(m.Author.ID, m.ChannelID, at)
+}
+
+// messagePosted records the first message of a member awaited since joining, and when members last posted
+func (g *Guild) messagePosted(discordID, channelID string, at time.Time) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed {
+		return
+	}
+	if _, ok := g.awaiting[discordID]; ok {
+		delete(g.awaiting, discordID)
+		if _, err := g.store.RecordFirstMessage(g.ID, discordID, channelID, at); err != nil {
+			log.Printf("failed to record the first message of '%v': %v", discordID, err)
+		}
+	}
+	if recorded, ok := g.posted[discordID]; ok && at.Sub(recorded) < lastMessagePrecision {
+		return
+	}
+	g.posted[discordID] = at
+	if err := g.store.RecordMessage(g.ID, discordID, at); err != nil {
+		log.Printf("failed to record the last message of '%v': %v", discordID, err)
+	}
+}
+
+// awaitFirstMessageLocked starts waiting for the first message of a member who joined
+func (g *Guild) awaitFirstMessageLocked(discordID string, joinedAt time.Time) {
+	if !g.bot.options.TrackFirstMessages {
+		return
+	}
+	if err := g.store.AwaitFirstMessage(g.ID, discordID, joinedAt); err != nil {
+		log.Printf("failed to wait for the first message of '%v': %v", discordID, err)
+		return
+	}
+	g.awaiting[discordID] = struct{}{}
+}
+
+// loadAwaitingLocked loads the members that joined but didn't post yet
+func (g *Guild) loadAwaitingLocked() error {
+	g.awaiting = map[string]struct{}{}
+	if !g.bot.options.TrackFirstMessages {
+		return nil
+	}
+	messages, err := g.store.FirstMessages(g.ID, time.Time{})
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		if !message.Posted() {
+			g.awaiting[message.DiscordID] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// firstMessageText describes when a member first posted after joining
+func firstMessageText(lang *i18n.Language, message store.FirstMessage) string {
+	if !message.Posted() {
+		return lang.Translate("Never posted")
+	}
+	return lang.Sprintf(
+		"<t:%v:f> in <#%v>, %v after joining",
+		message.PostedAt.Unix(),
+		message.ChannelID,
+		lang.FormatDuration(message.PostedAt.Sub(message.JoinedAt)),
+	)
+}
+
+// onboardingStats summarizes how many members who joined posted, and how long the median one took
+type onboardingStats struct {
+	joined int
+	posted int
+	median time.Duration
+}
+
+func newOnboardingStats(messages []store.FirstMessage) onboardingStats {
+	stats := onboardingStats{joined: len(messages)}
+	delays := []time.Duration{}
+	for _, message := range messages {
+		if message.Posted() {
+			delays = append(delays, message.PostedAt.Sub(message.JoinedAt))
+		}
+	}
+	stats.posted = len(delays)
+	if len(delays) > 0 {
+		sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+		stats.median = delays[len(delays)/2]
+	}
+	return stats
+}
+
+func (o onboardingStats) postedRate() float64 {
+	if o.joined == 0 {
+		return 0
+	}
+	return float64(o.posted) / float64(o.joined)
+}
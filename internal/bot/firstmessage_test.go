@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestFirstMessages(t *testing.T) {
+	st := openTestStore(t)
+	if err := st.AddMember(testGuildID, "1", store.Member{User: store.User{Username: "alice", Discriminator: "0"}}); err != nil {
+		t.Fatal(err)
+	}
+	session := newFakeSession()
+	g := newTestGuildWithOptions(t, st, session, Options{TrackFirstMessages: true})
+	joined := time.Now().Add(-time.Hour)
+
+	g.memberAdded("2", store.Member{User: store.User{Username: "bob", Discriminator: "0"}, JoinedAt: joined})
+	g.memberAdded("3", store.Member{User: store.User{Username: "carol", Discriminator: "0"}, JoinedAt: joined})
+	session.takeSent()
+	// members who were already here aren't tracked
+	g.messagePosted("1", "50", joined)
+	g.messagePosted("2", "50", joined.Add(2*time.Hour))
+	g.messagePosted("2", "51", joined.Add(3*time.Hour))
+
+	if _, ok, _ := st.FirstMessage(testGuildID, "1"); ok {
+		t.Errorf("expected alice not to be tracked")
+	}
+	whois := g.bot.whoisResponse(g, "2")
+	if field := whois.Embeds[0].Fields[5]; field.Name != "First message" || !strings.Contains(field.Value, "in <#50>, 2 hours after joining") {
+		t.Errorf("unexpected first message field %+v", field)
+	}
+	whois = g.bot.whoisResponse(g, "3")
+	if field := whois.Embeds[0].Fields[5]; field.Value != "Never posted" {
+		t.Errorf("unexpected first message field %+v", field)
+	}
+
+	stats := g.bot.commandStats(nil, nil, g, nil)
+	fields := stats.Embeds[0].Fields
+	expected := "Tracked joins: 2\nPosted: 1 (50.0%)\nNever posted: 1\nMedian time to first post: 2 hours"
+	if field := fields[len(fields)-1]; field.Value != expected {
+		t.Errorf("expected onboarding %q, got %q", expected, field.Value)
+	}
+
+	// reloading keeps waiting for carol
+	g = newTestGuildWithOptions(t, st, session, Options{TrackFirstMessages: true})
+	g.messagePosted("3", "52", joined.Add(time.Hour))
+	if message, _, _ := st.FirstMessage(testGuildID, "3"); message.ChannelID != "52" {
+		t.Errorf("expected carol's first message to be recorded after reloading, got %+v", message)
+	}
+}
@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// graphPeriods are the /userlog graph choices, in days
+var graphPeriods = map[string]int{
+	"30d": 30,
+	"90d": 90,
+	"1y":  365,
+}
+
+const (
+	graphWidth   = 800
+	graphHeight  = 300
+	graphPadding = 20
+)
+
+var (
+	graphBackground = color.RGBA{0x2f, 0x31, 0x36, 0xff}
+	graphGrid       = color.RGBA{0x40, 0x44, 0x4b, 0xff}
+	graphLine       = color.RGBA{0x58, 0x65, 0xf2, 0xff}
+	graphFill       = color.RGBA{0x3b, 0x40, 0x7a, 0xff}
+)
+
+func (b *Bot) commandGraph(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	period := "30d"
+	for _, option := range options {
+		if option.Name == "period" {
+			period = option.StringValue()
+		}
+	}
+	return b.graphResponse(g, period, time.Now())
+}
+
+func (b *Bot) graphResponse(g *Guild, period string, now time.Time) *discordgo.InteractionResponseData {
+	lang := g.language()
+	days, ok := graphPeriods[period]
+	if !ok {
+		return textResponse(lang.Translate("Unknown period."))
+	}
+	since := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -days+1)
+	counts, err := b.store.MemberCountHistory(g.ID, g.MemberCount(), since, days)
+	if err != nil {
+		log.Printf("failed to load member count history of guild '%v': %v", g.ID, err)
+		return textResponse(lang.Translate("Failed to load the member count history, check the logs."))
+	}
+	chart, err := renderGraph(counts)
+	if err != nil {
+		log.Printf("failed to render member count graph: %v", err)
+		return textResponse(lang.Translate("Failed to draw the graph, check the logs."))
+	}
+
+	first, last := counts[0], counts[len(counts)-1]
+	low, high := first.Count, first.Count
+	for _, count := range counts {
+		if count.Count < low {
+			low = count.Count
+		}
+		if count.Count > high {
+			high = count.Count
+		}
+	}
+	change := fmt.Sprintf("%+d", last.Count-first.Count)
+
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title: lang.Sprintf("Members, Last %v Days", days),
+			Description: lang.Sprintf(
+				"%v to %v: %v → **%v** members (%v)\nLow %v, high %v",
+				first.Day.Format("2006-01-02"), last.Day.Format("2006-01-02"),
+				lang.FormatNumber(first.Count), lang.FormatNumber(last.Count), change,
+				lang.FormatNumber(low), lang.FormatNumber(high),
+			),
+			Image: &discordgo.MessageEmbedImage{URL: "attachment://members.png"},
+		}},
+		Files: []*discordgo.File{{
+			Name:        "members.png",
+			ContentType: "image/png",
+			Reader:      bytes.NewReader(chart),
+		}},
+	}
+}
+
+// renderGraph draws member counts as a filled line chart, scaled between the lowest and highest count
+func renderGraph(counts []store.DayCount) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, graphWidth, graphHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{graphBackground}, image.Point{}, draw.Src)
+
+	plot := image.Rect(graphPadding, graphPadding, graphWidth-graphPadding, graphHeight-graphPadding)
+	for i := 0; i <= 4; i++ {
+		y := plot.Min.Y + i*plot.Dy()/4
+		draw.Draw(img, image.Rect(plot.Min.X, y, plot.Max.X, y+1), &image.Uniform{graphGrid}, image.Point{}, draw.Src)
+	}
+
+	if len(counts) > 0 {
+		low, high := counts[0].Count, counts[0].Count
+		for _, count := range counts {
+			if count.Count < low {
+				low = count.Count
+			}
+			if count.Count > high {
+				high = count.Count
+			}
+		}
+		if high == low {
+			// a flat line sits in the middle
+			low, high = low-1, high+1
+		}
+
+		// each pixel column takes the count of the day it falls on
+		yAt := func(x int) int {
+			index := 0
+			if len(counts) > 1 {
+				index = (x - plot.Min.X) * (len(counts) - 1) / (plot.Dx() - 1)
+			}
+			return plot.Max.Y - (counts[index].Count-low)*plot.Dy()/(high-low)
+		}
+		previous := yAt(plot.Min.X)
+		for x := plot.Min.X; x < plot.Max.X; x++ {
+			y := yAt(x)
+			draw.Draw(img, image.Rect(x, y, x+1, plot.Max.Y), &image.Uniform{graphFill}, image.Point{}, draw.Src)
+			// connect steps between days with a vertical segment, 3px thick
+			top, bottom := y, previous
+			if top > bottom {
+				top, bottom = bottom, top
+			}
+			draw.Draw(img, image.Rect(x-1, top-1, x+2, bottom+2), &image.Uniform{graphLine}, image.Point{}, draw.Src)
+			previous = y
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
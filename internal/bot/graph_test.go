@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraph(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	now := time.Now()
+	counts, err := st.MemberCountHistory(testGuildID, 2, now.AddDate(0, 0, -1), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[len(counts)-1].Count != 2 {
+		t.Errorf("expected today to have 2 members, got %+v", counts)
+	}
+
+	response := g.bot.graphResponse(g, "30d", now)
+	if len(response.Files) != 1 || len(response.Embeds) != 1 {
+		t.Fatalf("expected an embed and a file, got %+v", response)
+	}
+	if !strings.Contains(response.Embeds[0].Description, "**2** members") {
+		t.Errorf("unexpected description %q", response.Embeds[0].Description)
+	}
+	data, err := io.ReadAll(response.Files[0].Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != graphWidth || size.Y != graphHeight {
+		t.Errorf("unexpected image size %v", size)
+	}
+	// the corner is outside of the plot
+	if r, g, b, _ := img.At(0, 0).RGBA(); r>>8 != uint32(graphBackground.R) || g>>8 != uint32(graphBackground.G) || b>>8 != uint32(graphBackground.B) {
+		t.Errorf("unexpected background color %v", img.At(0, 0))
+	}
+
+	if response := g.bot.graphResponse(g, "2w", now); response.Content != "Unknown period." {
+		t.Errorf("unexpected response for an unknown period %+v", response)
+	}
+}
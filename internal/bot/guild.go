@@ -0,0 +1,892 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/filter"
+	"go.albinodrought/discord-user-log/internal/i18n"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+	"go.albinodrought/discord-user-log/internal/telemetry"
+)
+
+// Guild holds the known member state of a single guild
+type Guild struct {
+	ID    string
+	bot   *Bot
+	store Store
+
+	lock sync.Mutex
+	// syncLock runs syncs one at a time, they only hold lock while reconciling what they fetched
+	syncLock sync.Mutex
+	notifier notify.Notifier
+	ignored  map[string]struct{}
+	announce map[string]struct{}
+	// anniversaryOptOut are members whose join anniversaries aren't announced
+	anniversaryOptOut map[string]struct{}
+	milestones        Milestones
+	quietHours        *QuietHours
+	autoRoleID        string
+	roles             RoleAdder
+	massLeave         MassLeave
+	alerts            notify.Notifier
+	voice             notify.Notifier
+	watchRoleID       string
+	leaveRoles        []string
+	editLeaves        bool
+	lang              *i18n.Language
+	hook              *notify.Hook
+	filter            *filter.Filter
+	leaveSurvey       *LeaveSurvey
+	quickActions      []string
+	watched           map[string]struct{}
+	state             map[string]store.Member
+	stateLoaded       bool
+	// closed ignores everything after the bot was closed
+	closed bool
+
+	// recentLeaves are the times of leaves within the mass leave window
+	recentLeaves []time.Time
+
+	// deferred are announcements held back during quiet hours, flushed by flushTimer
+	deferred   []pendingEvent
+	flushTimer *time.Timer
+
+	// syncBatch collects the announcements of a running sync, nil outside of syncs
+	syncBatch   []pendingEvent
+	syncSummary int
+
+	// tx is the transaction of the member event being handled, nil outside of them,
+	// queued are the announcements it added to the outbox, sent once it is committed
+	tx     *store.Store
+	queued []pendingEvent
+	// recovered are announcements an earlier run left in the outbox, sent by the first sync
+	recovered []pendingEvent
+
+	// seen is when the newest known state of a member was received, by an event or a sync,
+	// and syncedAt is when the last complete sync started. Events received before either are outdated.
+	seen     map[string]time.Time
+	syncedAt time.Time
+	// nextSlice is the range of members the next incremental sync reconciles
+	nextSlice int
+	// memberListDay is the UTC day whose member list was recorded last, only used by syncs
+	memberListDay time.Time
+	// invites tells which invite members join with, with invite tracking
+	invites inviteTracker
+
+	// online are the members last seen online or offline, with presence tracking
+	online map[string]bool
+	// awaiting are the members who joined but didn't post yet, with first message tracking
+	awaiting map[string]struct{}
+	// posted is when the last message of a member was recorded, with first message tracking
+	posted map[string]time.Time
+}
+
+func newGuild(guildID string, bot *Bot) *Guild {
+	return &Guild{
+		ID:       guildID,
+		bot:      bot,
+		store:    bot.store,
+		seen:     map[string]time.Time{},
+		online:   map[string]bool{},
+		awaiting: map[string]struct{}{},
+		posted:   map[string]time.Time{},
+	}
+}
+
+// GuildOptions are the reloadable settings of a guild
+type GuildOptions struct {
+	Notifier notify.Notifier
+	// IgnoredUsers are still tracked, but never announced
+	IgnoredUsers []string
+	// Announce lists the history event types to announce, the rest are only recorded
+	Announce   []string
+	Milestones Milestones
+	// AnniversaryOptOut are members whose join anniversaries aren't announced
+	AnniversaryOptOut []string
+	// QuietHours defers announcements to when they end, nil disables them
+	QuietHours *QuietHours
+	// AutoRoleID is assigned to members when they join, or when they complete membership screening
+	AutoRoleID string
+	Roles      RoleAdder
+	MassLeave  MassLeave
+	// Alerts receives urgent moderator alerts, it may be the same as Notifier
+	Alerts notify.Notifier
+	// Voice receives voice channel joins, leaves, and moves, nil disables voice logging
+	Voice notify.Notifier
+	// WatchRoleID is mentioned by watched user alerts, empty mentions nobody
+	WatchRoleID string
+	// LeaveRoles limits leave announcements to members with one of these roles, empty announces every leave
+	LeaveRoles []string
+	// SyncSummary replaces the announcements of a sync with a summary if there are more than this many, 0 never does
+	SyncSummary int
+	// EditLeaves appends leaves to the member's join announcement instead of announcing them, if the notifier can edit messages
+	EditLeaves bool
+	// Language translates command responses, nil is English
+	Language *i18n.Language
+	// Hook runs on every event before it is published, nil runs nothing
+	Hook *notify.Hook
+	// Filter limits announcements to the events it matches, nil announces every event. Alerts aren't filtered.
+	Filter *filter.Filter
+	// LeaveSurvey asks members who leave why they did, nil disables it
+	LeaveSurvey *LeaveSurvey
+	// QuickActions are offered as buttons under announcements, like QuickActionBan
+	QuickActions []string
+}
+
+// Milestones are the member counts to celebrate
+type Milestones struct {
+	// Every celebrates each multiple, 0 disables it
+	Every int
+	// At celebrates specific counts
+	At []int
+}
+
+func (m Milestones) reached(memberCount int) bool {
+	if m.Every > 0 && memberCount%m.Every == 0 {
+		return true
+	}
+	for _, at := range m.At {
+		if at == memberCount {
+			return true
+		}
+	}
+	return false
+}
+
+// Configure applies the reloadable guild options
+func (g *Guild) Configure(options GuildOptions) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.notifier = options.Notifier
+	g.ignored = make(map[string]struct{}, len(options.IgnoredUsers))
+	for _, discordID := range options.IgnoredUsers {
+		g.ignored[discordID] = struct{}{}
+	}
+	g.announce = make(map[string]struct{}, len(options.Announce))
+	for _, eventType := range options.Announce {
+		g.announce[eventType] = struct{}{}
+	}
+	g.anniversaryOptOut = make(map[string]struct{}, len(options.AnniversaryOptOut))
+	for _, discordID := range options.AnniversaryOptOut {
+		g.anniversaryOptOut[discordID] = struct{}{}
+	}
+	g.milestones = options.Milestones
+	g.quietHours = options.QuietHours
+	g.autoRoleID = options.AutoRoleID
+	g.roles = options.Roles
+	g.massLeave = options.MassLeave
+	g.alerts = options.Alerts
+	if g.alerts == nil {
+		g.alerts = g.notifier
+	}
+	g.voice = options.Voice
+	g.watchRoleID = options.WatchRoleID
+	g.leaveRoles = options.LeaveRoles
+	g.editLeaves = options.EditLeaves
+	g.syncSummary = options.SyncSummary
+	g.lang = options.Language
+	if g.lang == nil {
+		g.lang = i18n.English
+	}
+	g.hook = options.Hook
+	g.filter = options.Filter
+	g.leaveSurvey = options.LeaveSurvey
+	g.quickActions = options.QuickActions
+
+	// reschedule anything deferred under the old quiet hours
+	g.scheduleFlushLocked(time.Now())
+}
+
+// announces returns whether an event type is announced
+func (g *Guild) announces(eventType string) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	_, announced := g.announce[eventType]
+	return announced
+}
+
+// language returns the language of command responses
+func (g *Guild) language() *i18n.Language {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.lang == nil {
+		return i18n.English
+	}
+	return g.lang
+}
+
+// load members from persistent storage
+func (g *Guild) load() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	state, err := g.store.Members(g.ID)
+	if err != nil {
+		return err
+	}
+	g.state = state
+
+	watched, err := g.store.WatchedUsers(g.ID)
+	if err != nil {
+		return err
+	}
+	g.watched = make(map[string]struct{}, len(watched))
+	for _, user := range watched {
+		g.watched[user.DiscordID] = struct{}{}
+	}
+
+	if err := g.loadOutboxLocked(); err != nil {
+		return err
+	}
+
+	if err := g.loadAwaitingLocked(); err != nil {
+		return err
+	}
+
+	loadedCount := len(g.state)
+	if loadedCount == 0 {
+		g.stateLoaded = false
+		log.Printf("loaded no members of guild '%v' from DB, assuming first time load, squelching notifications", g.ID)
+	} else {
+		g.stateLoaded = true
+		log.Printf("loaded %v members of guild '%v' from DB", loadedCount, g.ID)
+	}
+	return nil
+}
+
+func (g *Guild) announceLocked(event notify.Event) error {
+	if _, announced := g.announce[event.Type]; !announced {
+		return nil
+	}
+	if _, ignored := g.ignored[event.UserID]; ignored {
+		log.Printf("not announcing ignored user '%v'", event.UserID)
+		return nil
+	}
+	if !g.filter.Match(event) {
+		log.Printf("not announcing '%v' %v, it doesn't match the filter", event.UserID, event.Type)
+		return nil
+	}
+	event.Actions = g.quickActionsLocked(event)
+	return g.deliverLocked(event)
+}
+
+// eventLocked records and announces an event that doesn't change membership.
+// Non-nil details are stored in the history as JSON.
+func (g *Guild) eventLocked(event notify.Event, details interface{}) {
+	event.GuildID = g.ID
+	event.MemberCount = len(g.state)
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	history := store.HistoryEvent{
+		DiscordID: event.UserID,
+		Event:     event.Type,
+		User:      event.User,
+		At:        event.At,
+	}
+	if details != nil {
+		history.Details = encodeDetails(event.Type, details)
+	}
+	g.recordLocked(history)
+	g.publishLocked(&event)
+	var err error
+	if voiceEvents[event.Type] {
+		err = g.logVoiceLocked(event)
+	} else {
+		err = g.announceLocked(event)
+	}
+	if err != nil {
+		sendFailed(err, "failed to send message about '%v' %v", event.UserID, event.Type)
+	}
+}
+
+// encodeDetails encodes the details of a history event as JSON
+func encodeDetails(eventType string, details interface{}) string {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		log.Fatalf("failed to encode '%v' details: %v", eventType, err)
+	}
+	return string(encoded)
+}
+
+// leaveDetails are stored with leave history events of members who had roles
+type leaveDetails struct {
+	Roles []string `json:"roles"`
+}
+
+// timeoutDetails are stored with timeout history events
+type timeoutDetails struct {
+	Until int64 `json:"until"`
+}
+
+// avatarDetails are stored with avatar change history events
+type avatarDetails struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// renameDetails are stored with rename history events, kind is username or nickname
+type renameDetails struct {
+	Kind string `json:"kind"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+func (g *Guild) memberAdded(discordID string, member store.Member) {
+	g.memberAddedAt(time.Now(), discordID, member, nil)
+}
+
+// memberAddedAt handles a join received at a time, unless it is outdated. invite is the invite they used, nil if unknown.
+func (g *Guild) memberAddedAt(received time.Time, discordID string, member store.Member, invite *joinDetails) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed || g.outdatedLocked(discordID, received) {
+		return
+	}
+	g.memberAddedLocked(discordID, member, invite)
+}
+
+// outdatedLocked reports whether an event of a member received at a time is older than their known state.
+// Event handlers run concurrently and between the steps of running syncs, so an event can be handled after a newer one,
+// or after a sync already fetched the member's newer state. Otherwise the event becomes the newest known state.
+func (g *Guild) outdatedLocked(discordID string, received time.Time) bool {
+	if received.Before(g.syncedAt) || received.Before(g.seen[discordID]) {
+		log.Printf("ignoring an outdated event of '%v' in guild '%v'", discordID, g.ID)
+		return true
+	}
+	g.seen[discordID] = received
+	return false
+}
+
+func (g *Guild) memberAddedLocked(discordID string, member store.Member, invite *joinDetails) {
+	_, exists := g.state[discordID]
+	if exists {
+		return
+	}
+	defer g.transactionLocked()()
+	err := g.store.AddMember(g.ID, discordID, member)
+	if err != nil {
+		log.Fatalf("failed to insert member '%v' to persistent storage: %v", err, discordID)
+	}
+	g.state[discordID] = member
+	g.recordNamesLocked(discordID, store.Member{}, member)
+	if g.stateLoaded {
+		// prefer Discord's join date, joins discovered by a sync may have happened a while ago
+		joinedAt := member.JoinedAt
+		if joinedAt.IsZero() {
+			joinedAt = time.Now()
+		}
+		history := store.HistoryEvent{
+			DiscordID: discordID,
+			Event:     store.EventJoin,
+			User:      member.User,
+			At:        joinedAt,
+		}
+		if invite != nil {
+			history.Details = encodeDetails(store.EventJoin, invite)
+		}
+		g.recordLocked(history)
+		g.awaitFirstMessageLocked(discordID, joinedAt)
+		event := notify.Event{
+			Type:        store.EventJoin,
+			GuildID:     g.ID,
+			UserID:      discordID,
+			User:        member.User,
+			At:          joinedAt,
+			MemberCount: len(g.state),
+			Pending:     member.Pending,
+		}
+		crossGuild := g.crossGuildLocked(&event)
+		g.publishLocked(&event)
+		_, watched := g.watched[discordID]
+		_, ignored := g.ignored[discordID]
+		if crossGuild && !watched && !ignored {
+			err = g.crossGuildAlertLocked(event)
+		} else {
+			err = g.announceOrAlertLocked(event, notify.EventWatchedJoin)
+		}
+		if err != nil {
+			sendFailed(err, "failed to send message about '%v' joining server", discordID)
+		} else {
+			log.Printf("messaged about '%v' joining", discordID)
+		}
+		g.celebrateMilestoneLocked(discordID, member.User)
+		// roles given to pending members would let them skip membership screening
+		if !member.Pending {
+			g.assignAutoRoleLocked(discordID)
+		}
+	}
+}
+
+// celebrateMilestoneLocked announces the current member count if it is a milestone that wasn't reached before
+func (g *Guild) celebrateMilestoneLocked(discordID string, user store.User) {
+	memberCount := len(g.state)
+	if !g.milestones.reached(memberCount) {
+		return
+	}
+	firstTime, err := g.store.RecordMilestone(g.ID, memberCount, time.Now())
+	if err != nil {
+		log.Fatalf("failed to record milestone of %v members: %v", memberCount, err)
+	}
+	if !firstTime {
+		return
+	}
+	event := notify.Event{
+		Type:        notify.EventMilestone,
+		GuildID:     g.ID,
+		UserID:      discordID,
+		User:        user,
+		At:          time.Now(),
+		MemberCount: memberCount,
+	}
+	g.publishLocked(&event)
+	err = g.deliverLocked(event)
+	if err != nil {
+		sendFailed(err, "failed to send message about reaching %v members", memberCount)
+		return
+	}
+	log.Printf("messaged about reaching %v members", memberCount)
+}
+
+func (g *Guild) memberUpdated(discordID string, member store.Member) {
+	g.memberUpdatedAt(time.Now(), discordID, member, roleChange{})
+}
+
+// memberUpdatedAt handles a member update received at a time, unless it is outdated.
+// Role changes are only recorded here, syncs can't tell who made them or when.
+func (g *Guild) memberUpdatedAt(received time.Time, discordID string, member store.Member, change roleChange) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed || g.outdatedLocked(discordID, received) {
+		return
+	}
+
+	known, exists := g.state[discordID]
+	if !exists {
+		// we must have missed their join
+		g.memberAddedLocked(discordID, member, nil)
+		return
+	}
+	if !known.Same(member) {
+		defer g.transactionLocked()()
+		g.memberChangedLocked(discordID, known, member)
+		if g.stateLoaded {
+			g.roleChangesLocked(discordID, known, member, change)
+		}
+	}
+}
+
+// memberChangedLocked stores the new state of a known member and handles notable changes
+func (g *Guild) memberChangedLocked(discordID string, before, after store.Member) {
+	defer g.transactionLocked()()
+	g.memberUpdatedLocked(discordID, after)
+	g.recordNamesLocked(discordID, before, after)
+	if !g.stateLoaded {
+		return
+	}
+	g.renamedLocked(discordID, before, after)
+
+	if before.PremiumSince.IsZero() && !after.PremiumSince.IsZero() {
+		g.eventLocked(notify.Event{Type: store.EventBoostStart, UserID: discordID, User: after.User}, nil)
+	} else if !before.PremiumSince.IsZero() && after.PremiumSince.IsZero() {
+		g.eventLocked(notify.Event{Type: store.EventBoostStop, UserID: discordID, User: after.User}, nil)
+	}
+
+	if before.Avatar != after.Avatar {
+		g.bot.archiveAvatars(discordID, before.Avatar, after.Avatar)
+		// members stored before avatars were tracked have no hash, so a first avatar isn't announced either
+		if before.Avatar != "" {
+			g.eventLocked(notify.Event{Type: store.EventAvatarChange, UserID: discordID, User: after.User, Avatar: after.Avatar}, avatarDetails{Old: before.Avatar, New: after.Avatar})
+		}
+	}
+
+	if before.Pending && !after.Pending {
+		g.eventLocked(notify.Event{Type: store.EventScreeningComplete, UserID: discordID, User: after.User}, nil)
+		g.assignAutoRoleLocked(discordID)
+	}
+
+	// timeouts that simply ran out aren't worth an event, only new, changed and lifted ones
+	now := time.Now()
+	if after.TimedOut(now) && !after.TimeoutUntil.Equal(before.TimeoutUntil) {
+		log.Printf("'%v' was timed out until %v", discordID, after.TimeoutUntil)
+		g.eventLocked(notify.Event{Type: store.EventTimeout, UserID: discordID, User: after.User, At: now, Until: after.TimeoutUntil}, timeoutDetails{Until: after.TimeoutUntil.Unix()})
+	} else if before.TimedOut(now) && !after.TimedOut(now) {
+		log.Printf("'%v' had their timeout removed", discordID)
+		g.eventLocked(notify.Event{Type: store.EventTimeoutEnd, UserID: discordID, User: after.User, At: now}, nil)
+	}
+}
+
+func (g *Guild) memberUpdatedLocked(discordID string, member store.Member) {
+	err := g.store.UpdateMember(g.ID, discordID, member)
+	if err != nil {
+		log.Fatalf("failed to update member '%v' in persistent storage: %v", err, discordID)
+	}
+	g.state[discordID] = member
+}
+
+func (g *Guild) memberRemoved(discordID string) {
+	g.memberRemovedAt(time.Now(), discordID)
+}
+
+// memberRemovedAt handles a leave received at a time, unless it is outdated
+func (g *Guild) memberRemovedAt(received time.Time, discordID string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed || g.outdatedLocked(discordID, received) {
+		return
+	}
+
+	member, known := g.state[discordID]
+	g.memberRemovedLocked(discordID)
+	// leaves found by a sync may have been spread over hours, only live leaves count towards mass leave alerts
+	// and are surveyed, members who left long ago won't remember why
+	if known && g.stateLoaded {
+		now := time.Now()
+		g.trackLeaveLocked(now)
+		g.surveyLeaveLocked(discordID, member.User, now)
+	}
+}
+
+func (g *Guild) memberRemovedLocked(discordID string) {
+	member, exists := g.state[discordID]
+	if !exists {
+		return
+	}
+	defer g.transactionLocked()()
+	user := member.User
+	err := g.store.RemoveMember(g.ID, discordID)
+	if err != nil {
+		log.Fatalf("failed to delete member '%v' from persistent storage: %v", err, discordID)
+	}
+	delete(g.state, discordID)
+	if g.stateLoaded {
+		now := time.Now()
+		joinedAt := member.JoinedAt
+		if joinedAt.IsZero() {
+			// members stored before join dates were, fall back to the recorded join
+			if lastJoin, ok, err := g.store.LastJoin(g.ID, discordID); err != nil {
+				log.Printf("failed to look up when '%v' joined: %v", discordID, err)
+			} else if ok {
+				joinedAt = lastJoin
+			}
+		}
+		history := store.HistoryEvent{
+			DiscordID: discordID,
+			Event:     store.EventLeave,
+			User:      user,
+			At:        now,
+		}
+		if len(member.Roles) > 0 {
+			history.Details = encodeDetails(store.EventLeave, leaveDetails{Roles: member.Roles})
+		}
+		g.recordLocked(history)
+		event := notify.Event{
+			Type:        store.EventLeave,
+			GuildID:     g.ID,
+			UserID:      discordID,
+			User:        user,
+			At:          now,
+			MemberCount: len(g.state),
+			JoinedAt:    joinedAt,
+		}
+		g.publishLocked(&event)
+		if _, watched := g.watched[discordID]; !watched && len(g.leaveRoles) > 0 && !member.HasAnyRole(g.leaveRoles) {
+			log.Printf("not announcing '%v' leaving, they had none of the announced roles", discordID)
+			return
+		}
+		err = g.announceOrAlertLocked(event, notify.EventWatchedLeave)
+		if err != nil {
+			sendFailed(err, "failed to send message about '%v' leaving server", discordID)
+			return
+		}
+		log.Printf("messaged about '%v' leaving", discordID)
+	}
+}
+
+// forget deletes everything the guild stored about a user. Current members stay tracked with their membership,
+// so the next sync doesn't announce them as joining again. It returns the number of deleted rows and whether the user is a member.
+func (g *Guild) forget(discordID string) (int64, bool, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	_, member := g.state[discordID]
+	affected, err := g.store.ForgetInGuild(g.ID, discordID, member)
+	if err != nil {
+		return 0, false, err
+	}
+	delete(g.watched, discordID)
+	return affected, member, nil
+}
+
+// tracks reports whether a user is a known member of the guild
+func (g *Guild) tracks(discordID string) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	_, ok := g.state[discordID]
+	return ok
+}
+
+// recordNamesLocked adds changed usernames and nicknames to the name history.
+// The old name is recorded too, it may predate the name history.
+func (g *Guild) recordNamesLocked(discordID string, before, after store.Member) {
+	now := time.Now()
+	for _, names := range []struct{ kind, before, after string }{
+		{store.NameUsername, before.User.Tag(), after.User.Tag()},
+		{store.NameNick, before.Nick, after.Nick},
+	} {
+		if names.before == names.after {
+			continue
+		}
+		for _, name := range []string{names.before, names.after} {
+			if name == "" {
+				continue
+			}
+			if err := g.store.RecordName(g.ID, discordID, names.kind, name, now); err != nil {
+				log.Fatalf("failed to record %v '%v' for '%v': %v", names.kind, name, discordID, err)
+			}
+		}
+	}
+}
+
+// publishLocked runs the hook on an event and forwards it to the publishers, whether or not it is announced
+func (g *Guild) publishLocked(event *notify.Event) {
+	if g.hook != nil {
+		if err := g.hook.Apply(event); err != nil {
+			log.Printf("ignoring the hook of guild '%v': %v", g.ID, err)
+		}
+	}
+	if g.bot.options.Publisher != nil {
+		g.bot.options.Publisher.Publish(*event)
+	}
+}
+
+func (g *Guild) recordLocked(event store.HistoryEvent) {
+	event.GuildID = g.ID
+	err := g.store.RecordEvent(event)
+	if err != nil {
+		log.Fatalf("failed to record '%v' history for '%v': %v", event.Event, event.DiscordID, err)
+	}
+}
+
+var syncDuration = telemetry.NewHistogram("user_log.sync.duration", "s", "Duration of guild member syncs", []float64{1, 5, 10, 30, 60, 120, 300, 600})
+
+// syncApplyBatch is how many fetched members a sync reconciles at once while holding the guild's lock
+const syncApplyBatch = 1000
+
+// memberSync is a running sync. Members are fetched without holding the guild's lock and reconciled in short locked steps,
+// so live events are handled in between instead of waiting for the whole sync.
+type memberSync struct {
+	g       *Guild
+	started time.Time
+	// unseen are the known members the sync didn't find yet
+	unseen map[string]interface{}
+	// batch collects the announcements of the sync, they are sent together once it's done
+	batch []pendingEvent
+}
+
+// startSync starts a sync of the known members in a range, nil is every member.
+// Syncs of a guild run one at a time, the returned function ends the sync.
+func (g *Guild) startSync(in func(discordID string) bool) (*memberSync, func()) {
+	g.syncLock.Lock()
+	run := &memberSync{g: g, batch: []pendingEvent{}}
+	run.step(func() {
+		run.started = time.Now()
+		run.unseen = make(map[string]interface{}, len(g.state))
+		for discordID := range g.state {
+			if in == nil || in(discordID) {
+				run.unseen[discordID] = nil
+			}
+		}
+	})
+	return run, func() {
+		run.step(g.flushSyncBatchLocked)
+		g.syncLock.Unlock()
+	}
+}
+
+// step runs part of a sync while the guild is locked, collecting the announcements it makes.
+// It returns false if the bot was closed.
+func (run *memberSync) step(fn func()) bool {
+	g := run.g
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed {
+		return false
+	}
+	g.syncBatch = run.batch
+	fn()
+	// flushing the batch empties it
+	run.batch = g.syncBatch
+	if run.batch == nil {
+		run.batch = []pendingEvent{}
+	}
+	g.syncBatch = nil
+	return true
+}
+
+// apply reconciles fetched members in locked steps of syncApplyBatch members, returning false if the bot was closed
+func (run *memberSync) apply(ctx context.Context, members []*discordgo.Member, fetchedAt time.Time) bool {
+	for len(members) > 0 {
+		batch := members
+		if len(batch) > syncApplyBatch {
+			batch = batch[:syncApplyBatch]
+		}
+		if !run.step(func() { run.g.reconcileTracedLocked(ctx, batch, run.unseen, fetchedAt) }) {
+			return false
+		}
+		members = members[len(batch):]
+	}
+	return true
+}
+
+// removeUnseenLocked removes the members the sync didn't find, assuming we missed their leave events.
+// Members with events received since the sync started are left alone, they may have rejoined after their page was fetched.
+func (run *memberSync) removeUnseenLocked() int {
+	g := run.g
+	removed := 0
+	for discordID := range run.unseen {
+		if g.seen[discordID].After(run.started) {
+			continue
+		}
+		g.memberRemovedLocked(discordID)
+		removed++
+	}
+	return removed
+}
+
+// syncMembersFromServer reconciles the known state of the guild with every member of the server.
+// Members joining while the first sync of a new database runs are recorded without being announced, like the members it finds.
+func (g *Guild) syncMembersFromServer(ctx context.Context, s Session) {
+	mode := "rest"
+	if g.bot.options.GatewaySync {
+		mode = "gateway"
+	}
+	ctx, span := telemetry.Start(ctx, "sync guild", telemetry.String("guild.id", g.ID), telemetry.String("user_log.sync.mode", mode))
+	defer span.End()
+	// announce everything the sync finds together, instead of a message per event
+	run, done := g.startSync(nil)
+	defer done()
+	if !run.step(g.sendRecoveredLocked) {
+		return
+	}
+
+	if g.bot.options.GatewaySync {
+		fetched := time.Now()
+		_, fetchSpan := telemetry.Start(ctx, "fetch members")
+		members, err := g.bot.chunks.requestMembers(ctx, s, g.ID)
+		fetchSpan.Fail(err)
+		fetchSpan.End()
+		if err != nil && err == ctx.Err() {
+			log.Printf("canceled the sync of guild '%v'", g.ID)
+			span.Fail(err)
+			return
+		}
+		if err != nil {
+			log.Fatalf("failed fetching guild members over the gateway: %v", err)
+		}
+		if !run.apply(ctx, members, fetched) {
+			return
+		}
+	} else {
+		var (
+			after   string
+			members []*discordgo.Member
+			err     error
+		)
+		const limit = 1000
+		for {
+			if ctx.Err() != nil {
+				// members not fetched yet would be mistaken for leaves
+				log.Printf("canceled the sync of guild '%v'", g.ID)
+				span.Fail(ctx.Err())
+				return
+			}
+			fetched := time.Now()
+			_, fetchSpan := telemetry.Start(ctx, "fetch members", telemetry.String("after", after))
+			members, err = s.GuildMembers(g.ID, after, limit)
+			fetchSpan.Fail(err)
+			fetchSpan.End()
+			if err != nil {
+				log.Fatalf("failed fetching guild members after '%v': %v", after, err)
+			}
+
+			if !run.apply(ctx, members, fetched) {
+				return
+			}
+
+			// less than limit returned - we're done!
+			if len(members) < limit {
+				break
+			}
+
+			// could be more
+			after = members[len(members)-1].User.ID
+		}
+	}
+
+	// the member list is written after the step, it may be long
+	var memberList []string
+	run.step(func() {
+		// these users weren't found in the server, assume we missed their leave event
+		span.SetAttributes(telemetry.Int("user_log.sync.missed_leaves", run.removeUnseenLocked()))
+
+		// member state is known now, notifications are allowed
+		g.stateLoaded = true
+
+		// events received before the sync started are reflected by it, only newer ones need to be remembered
+		g.syncedAt = run.started
+		for discordID, received := range g.seen {
+			if received.Before(run.started) {
+				delete(g.seen, discordID)
+			}
+		}
+
+		// snapshots keep the member count history accurate when history is pruned or was missed
+		if err := g.store.RecordSnapshot(g.ID, time.Now(), len(g.state)); err != nil {
+			log.Printf("failed to record member count snapshot of guild '%v': %v", g.ID, err)
+		}
+		memberList = g.memberListLocked(time.Now())
+		span.SetAttributes(telemetry.Int("user_log.sync.members", len(g.state)))
+	})
+	run.recordMemberList(time.Now(), memberList)
+	syncDuration.Record(time.Since(run.started).Seconds(), telemetry.String("user_log.sync.mode", mode))
+}
+
+// reconcileTracedLocked reconciles a page of fetched members in a span, the joins and changes it finds are written to the database
+func (g *Guild) reconcileTracedLocked(ctx context.Context, members []*discordgo.Member, unseen map[string]interface{}, fetchedAt time.Time) {
+	_, span := telemetry.Start(ctx, "reconcile members", telemetry.Int("members", len(members)))
+	defer span.End()
+	g.reconcileLocked(members, unseen, fetchedAt)
+}
+
+// reconcileLocked adds or updates members fetched at a time, removing them from unseen.
+// Members with events received after they were fetched are left alone, the events are newer.
+func (g *Guild) reconcileLocked(members []*discordgo.Member, unseen map[string]interface{}, fetchedAt time.Time) {
+	for _, member := range members {
+		if member.User == nil {
+			continue
+		}
+		delete(unseen, member.User.ID)
+		if g.seen[member.User.ID].After(fetchedAt) {
+			continue
+		}
+		g.seen[member.User.ID] = fetchedAt
+		fetched := memberFromDiscord(member)
+		known, exists := g.state[member.User.ID]
+		if exists {
+			if !known.Same(fetched) {
+				g.memberChangedLocked(member.User.ID, known, fetched)
+			}
+		} else {
+			g.memberAddedLocked(member.User.ID, fetched, nil)
+		}
+	}
+}
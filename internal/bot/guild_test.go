@@ -0,0 +1,776 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/filter"
+	"go.albinodrought/discord-user-log/internal/i18n"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+const (
+	testGuildID   = "100"
+	testChannelID = "200"
+)
+
+func openTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	st, err := store.Open(filepath.Join(t.TempDir(), "dul.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func newTestGuild(t *testing.T, st *store.Store, session *fakeSession, ignoredUsers ...string) *Guild {
+	t.Helper()
+	return newTestGuildWithOptions(t, st, session, Options{}, ignoredUsers...)
+}
+
+func newTestGuildWithOptions(t *testing.T, st *store.Store, session *fakeSession, options Options, ignoredUsers ...string) *Guild {
+	t.Helper()
+	return newTestGuildWithGuildOptions(t, st, session, options, GuildOptions{IgnoredUsers: ignoredUsers})
+}
+
+// defaultAnnounce matches the default config
+var defaultAnnounce = []string{store.EventJoin, store.EventLeave}
+
+func newTestGuildWithGuildOptions(t *testing.T, st *store.Store, session *fakeSession, options Options, guildOptions GuildOptions) *Guild {
+	t.Helper()
+	b := New(st, options)
+	session.deliverChunk = b.chunks.receive
+	g, err := b.AddGuild(testGuildID)
+	if err != nil {
+		t.Fatalf("failed to add guild: %v", err)
+	}
+	templates, err := notify.ParseTemplates(nil)
+	if err != nil {
+		t.Fatalf("failed to parse templates: %v", err)
+	}
+	guildOptions.Notifier = notify.NewChannel(session, testChannelID, templates)
+	if guildOptions.Announce == nil {
+		guildOptions.Announce = defaultAnnounce
+	}
+	g.Configure(guildOptions)
+	return g
+}
+
+func assertSent(t *testing.T, session *fakeSession, expected ...string) {
+	t.Helper()
+	actual := []string{}
+	for _, message := range session.takeSent() {
+		if message.channelID != testChannelID {
+			t.Errorf("message %q sent to channel %v, expected %v", message.content, message.channelID, testChannelID)
+		}
+		actual = append(actual, message.content)
+	}
+	if expected == nil {
+		expected = []string{}
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("sent %q, expected %q", actual, expected)
+	}
+}
+
+func assertStored(t *testing.T, st *store.Store, expected map[string]store.Member) {
+	t.Helper()
+	actual, err := st.Members(testGuildID)
+	if err != nil {
+		t.Fatalf("failed to load members: %v", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("stored %v, expected %v", actual, expected)
+	}
+}
+
+func TestFirstLoadIsSquelched(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "1234"))
+
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	assertSent(t, session)
+	assertStored(t, st, map[string]store.Member{
+		"1": {User: store.User{Username: "alice", Discriminator: "0"}},
+		"2": {User: store.User{Username: "bob", Discriminator: "1234"}},
+	})
+
+	// once synced, events are announced
+	g.memberAdded("3", store.Member{User: store.User{Username: "carol", Discriminator: "0"}})
+	assertSent(t, session, "<@3> (carol) joined the server, now 3 members")
+}
+
+func TestReloadedStateIsNotSquelched(t *testing.T) {
+	st := openTestStore(t)
+	if err := st.AddMember(testGuildID, "1", store.Member{User: store.User{Username: "alice", Discriminator: "0"}}); err != nil {
+		t.Fatal(err)
+	}
+	session := newFakeSession()
+
+	g := newTestGuild(t, st, session)
+	g.memberAdded("2", store.Member{User: store.User{Username: "bob", Discriminator: "1234"}})
+
+	assertSent(t, session, "<@2> (bob#1234) joined the server, now 2 members")
+}
+
+func TestMemberAddedAndRemoved(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	g.memberAdded("2", store.Member{User: store.User{}})
+	g.memberAdded("2", store.Member{User: store.User{}})
+	assertSent(t, session, "<@2> joined the server, now 2 members")
+
+	g.memberRemoved("1")
+	g.memberRemoved("1")
+	g.memberRemoved("3")
+	assertSent(t, session, "<@1> (alice) left the server, now 1 member")
+
+	assertStored(t, st, map[string]store.Member{
+		"2": {User: store.User{}},
+	})
+}
+
+func TestIgnoredUsersAreTrackedButNotAnnounced(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	g := newTestGuild(t, st, session, "2")
+	g.syncMembersFromServer(context.Background(), session)
+
+	g.memberAdded("2", store.Member{User: store.User{Username: "bot", Discriminator: "0"}})
+	assertSent(t, session)
+	assertStored(t, st, map[string]store.Member{
+		"2": {User: store.User{Username: "bot", Discriminator: "0"}},
+	})
+}
+
+func TestSyncDiff(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "1234"))
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	// alice left and carol joined while we weren't looking, bob renamed
+	session.setMembers(testGuildID, member("2", "robert", "0"), member("3", "carol", "0"))
+	g.syncMembersFromServer(context.Background(), session)
+
+	// found together, so announced together
+	assertSent(t, session, "<@3> (carol) joined the server, now 3 members\n<@1> (alice) left the server, now 2 members")
+	assertStored(t, st, map[string]store.Member{
+		"2": {User: store.User{Username: "robert", Discriminator: "0"}},
+		"3": {User: store.User{Username: "carol", Discriminator: "0"}},
+	})
+}
+
+func TestSyncSummary(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{SyncSummary: 2})
+	g.syncMembersFromServer(context.Background(), session)
+
+	session.setMembers(testGuildID, member("2", "bob", "0"), member("3", "carol", "0"), member("4", "dave", "0"))
+	g.syncMembersFromServer(context.Background(), session)
+	assertSent(t, session, "🔄 A sync found 3 missed events, 2 joins and 1 leaves, now 3 members")
+
+	// at or below the threshold, the events are announced as usual
+	session.setMembers(testGuildID, member("2", "bob", "0"), member("3", "carol", "0"))
+	g.syncMembersFromServer(context.Background(), session)
+	assertSent(t, session, "<@4> (dave) left the server, now 2 members")
+}
+
+func TestSyncPaginates(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	members := []*discordgo.Member{}
+	for i := 0; i < 2500; i++ {
+		members = append(members, member(fmt.Sprintf("%05d", i), "user", "0"))
+	}
+	session.setMembers(testGuildID, members...)
+
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	if session.guildMemberCalls != 3 {
+		t.Errorf("expected 3 GuildMembers calls, got %v", session.guildMemberCalls)
+	}
+	stored, err := st.Members(testGuildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != len(members) {
+		t.Errorf("expected %v stored members, got %v", len(members), len(stored))
+	}
+	assertSent(t, session)
+}
+
+func TestGatewaySync(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	members := []*discordgo.Member{}
+	for i := 0; i < 2500; i++ {
+		members = append(members, member(fmt.Sprintf("%05d", i), "user", "0"))
+	}
+	session.setMembers(testGuildID, members...)
+
+	g := newTestGuildWithOptions(t, st, session, Options{GatewaySync: true})
+	g.syncMembersFromServer(context.Background(), session)
+
+	if session.guildMemberCalls != 0 {
+		t.Errorf("expected no GuildMembers calls, got %v", session.guildMemberCalls)
+	}
+	stored, err := st.Members(testGuildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != len(members) {
+		t.Errorf("expected %v stored members, got %v", len(members), len(stored))
+	}
+
+	session.setMembers(testGuildID, members[1:]...)
+	g.syncMembersFromServer(context.Background(), session)
+	assertSent(t, session, "<@00000> (user) left the server, now 2,499 members")
+}
+
+func TestMilestonesAreCelebratedOnce(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{
+		Milestones: Milestones{Every: 2, At: []int{3}},
+	})
+	g.syncMembersFromServer(context.Background(), session)
+
+	g.memberAdded("2", store.Member{User: store.User{}})
+	assertSent(t, session, "<@2> joined the server, now 2 members", "🎉 We just reached 2 members! Welcome <@2>")
+
+	g.memberAdded("3", store.Member{User: store.User{}})
+	assertSent(t, session, "<@3> joined the server, now 3 members", "🎉 We just reached 3 members! Welcome <@3>")
+
+	// dipping below and crossing again isn't a new milestone
+	g.memberRemoved("3")
+	g.memberAdded("4", store.Member{User: store.User{}})
+	assertSent(t, session, "<@3> left the server, now 2 members", "<@4> joined the server, now 3 members")
+}
+
+func TestSyncRecordsDiscordDates(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	joinedAt := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	premiumSince := joinedAt.Add(48 * time.Hour)
+	m := member("1", "alice", "0")
+	m.JoinedAt = joinedAt
+	m.PremiumSince = &premiumSince
+	session.setMembers(testGuildID, m)
+	g.syncMembersFromServer(context.Background(), session)
+
+	stored, err := st.Members(testGuildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored["1"].JoinedAt.Equal(joinedAt) || !stored["1"].PremiumSince.Equal(premiumSince) {
+		t.Errorf("unexpected stored dates %+v", stored["1"])
+	}
+
+	// the missed join is recorded at Discord's join date
+	events, err := st.RecentEvents(testGuildID, []string{store.EventJoin}, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || !events[0].At.Equal(joinedAt) {
+		t.Errorf("unexpected join history %+v", events)
+	}
+}
+
+func TestBoostTransitions(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	m := member("1", "alice", "0")
+	session.setMembers(testGuildID, m)
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{
+		Announce: append(defaultAnnounce, store.EventBoostStart, store.EventBoostStop),
+	})
+	g.syncMembersFromServer(context.Background(), session)
+
+	premiumSince := time.Now()
+	g.memberUpdated("1", store.Member{User: store.User{Username: "alice", Discriminator: "0"}, PremiumSince: premiumSince})
+	assertSent(t, session, "💎 <@1> started boosting the server, thank you!")
+
+	// the sync notices the boost ended
+	g.syncMembersFromServer(context.Background(), session)
+	assertSent(t, session, "<@1> (alice) stopped boosting the server")
+
+	events, err := st.RecentEvents(testGuildID, []string{store.EventBoostStart, store.EventBoostStop}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Errorf("expected 2 boost events in history, got %+v", events)
+	}
+}
+
+func TestUnannouncedEventsAreRecorded(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	g.memberUpdated("1", store.Member{User: store.User{Username: "alice", Discriminator: "0"}, PremiumSince: time.Now()})
+	assertSent(t, session)
+
+	events, err := st.RecentEvents(testGuildID, []string{store.EventBoostStart}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Errorf("expected boost event in history, got %+v", events)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{
+		Announce: append(defaultAnnounce, store.EventTimeout, store.EventTimeoutEnd),
+	})
+	g.syncMembersFromServer(context.Background(), session)
+
+	alice := store.User{Username: "alice", Discriminator: "0"}
+	until := time.Now().Add(time.Hour + 30*time.Second).Truncate(time.Second)
+	g.memberUpdated("1", store.Member{User: alice, TimeoutUntil: until})
+	assertSent(t, session, fmt.Sprintf("⏳ <@1> (alice) was timed out for 1 hour, until <t:%v:f>", until.Unix()))
+
+	// lifted early
+	g.memberUpdated("1", store.Member{User: alice})
+	assertSent(t, session, "<@1> (alice)'s timeout was removed")
+
+	// a timeout that already ran out isn't an event
+	g.memberUpdated("1", store.Member{User: alice, TimeoutUntil: time.Now().Add(-time.Minute)})
+	g.memberUpdated("1", store.Member{User: alice})
+	assertSent(t, session)
+
+	events, err := st.RecentEvents(testGuildID, []string{store.EventTimeout, store.EventTimeoutEnd}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[1].Details != fmt.Sprintf(`{"until":%v}`, until.Unix()) {
+		t.Errorf("unexpected timeout history %+v", events)
+	}
+}
+
+func TestMembershipScreening(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{
+		Announce: append(defaultAnnounce, store.EventScreeningComplete),
+	})
+	g.syncMembersFromServer(context.Background(), session)
+
+	alice := store.User{Username: "alice", Discriminator: "0"}
+	g.memberAdded("1", store.Member{User: alice, Pending: true})
+	assertSent(t, session, "<@1> (alice) joined the server, pending membership screening, now 1 member")
+
+	// the sync notices screening was completed
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	g.syncMembersFromServer(context.Background(), session)
+	assertSent(t, session, "<@1> (alice) completed membership screening")
+
+	g.memberUpdated("1", store.Member{User: alice})
+	assertSent(t, session)
+}
+
+// fakeAvatarArchive reports saved avatars on a channel, archiving happens in the background
+type fakeAvatarArchive chan string
+
+func (a fakeAvatarArchive) Save(userID, hash string) error {
+	a <- userID + "/" + hash
+	return nil
+}
+
+func (a fakeAvatarArchive) Forget(userID string) error {
+	a <- userID + "/"
+	return nil
+}
+
+func TestAvatarChanges(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	m := member("1", "alice", "0")
+	m.User.Avatar = "old"
+	session.setMembers(testGuildID, m)
+	archive := make(fakeAvatarArchive, 2)
+	g := newTestGuildWithGuildOptions(t, st, session, Options{AvatarArchive: archive}, GuildOptions{
+		Announce: append(defaultAnnounce, store.EventAvatarChange),
+	})
+	g.syncMembersFromServer(context.Background(), session)
+
+	m = member("1", "alice", "0")
+	m.User.Avatar = "a_new"
+	session.setMembers(testGuildID, m)
+	g.syncMembersFromServer(context.Background(), session)
+	assertSent(t, session, "<@1> (alice) changed their avatar https://cdn.discordapp.com/avatars/1/a_new.gif")
+
+	for _, expected := range []string{"1/old", "1/a_new"} {
+		select {
+		case saved := <-archive:
+			if saved != expected {
+				t.Errorf("archived %v, expected %v", saved, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %v to be archived", expected)
+		}
+	}
+
+	events, err := st.RecentEvents(testGuildID, []string{store.EventAvatarChange}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Details != `{"old":"old","new":"a_new"}` {
+		t.Errorf("unexpected avatar history %+v", events)
+	}
+}
+
+func TestRenames(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{
+		Announce: append(defaultAnnounce, store.EventRename),
+	})
+	g.syncMembersFromServer(context.Background(), session)
+
+	// found by a sync, like a rename while the bot was offline
+	renamed := member("1", "alicia", "0")
+	renamed.Nick = "Al"
+	session.setMembers(testGuildID, renamed)
+	g.syncMembersFromServer(context.Background(), session)
+	assertSent(t, session, "✏️ <@1> changed their username from `alice` to `alicia`\n✏️ <@1> changed their nickname from `nothing` to `Al`")
+
+	events, err := st.RecentEvents(testGuildID, []string{store.EventRename}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	details := map[string]bool{}
+	for _, event := range events {
+		details[event.Details] = true
+	}
+	for _, expected := range []string{`{"kind":"username","old":"alice","new":"alicia"}`, `{"kind":"nickname","old":"","new":"Al"}`} {
+		if !details[expected] {
+			t.Errorf("expected a rename event with %v, got %+v", expected, events)
+		}
+	}
+}
+
+func TestForgetKeepsCurrentMembers(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+	session.setMembers(testGuildID, member("1", "alicia", "0"))
+	g.syncMembersFromServer(context.Background(), session)
+	session.takeSent()
+
+	for _, discordID := range []string{"1", "2"} {
+		if _, _, err := g.forget(discordID); err != nil {
+			t.Fatal(err)
+		}
+		history, err := st.UserHistory(testGuildID, discordID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 0 {
+			t.Errorf("expected the history of %v to be forgotten, got %+v", discordID, history)
+		}
+	}
+	members, err := st.Members(testGuildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := members["1"]; !ok || len(members) != 1 {
+		t.Errorf("expected only the current member to stay stored, got %+v", members)
+	}
+
+	// the current member isn't announced as joining again
+	g.syncMembersFromServer(context.Background(), session)
+	assertSent(t, session)
+}
+
+func TestMassLeaveAlert(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	members := []*discordgo.Member{}
+	for i := 0; i < 10; i++ {
+		members = append(members, member(fmt.Sprint(i), "", ""))
+	}
+	session.setMembers(testGuildID, members...)
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{
+		Announce:  []string{},
+		MassLeave: MassLeave{Count: 3, Window: 10 * time.Minute},
+	})
+	g.syncMembersFromServer(context.Background(), session)
+
+	for i := 0; i < 3; i++ {
+		g.memberRemoved(fmt.Sprint(i))
+	}
+	assertSent(t, session)
+
+	g.memberRemoved("3")
+	assertSent(t, session, "🚨 4 members left in the last 10 minutes, 6 remain")
+
+	// unknown members and leaves found by a sync don't count
+	g.memberRemoved("3")
+	session.setMembers(testGuildID, members[7:]...)
+	g.syncMembersFromServer(context.Background(), session)
+	assertSent(t, session)
+}
+
+type fakePublisher struct {
+	events *[]notify.Event
+}
+
+func (p fakePublisher) Publish(event notify.Event) {
+	*p.events = append(*p.events, event)
+}
+
+func TestEventsArePublished(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	published := []notify.Event{}
+	g := newTestGuildWithGuildOptions(t, st, session, Options{Publisher: fakePublisher{&published}}, GuildOptions{
+		IgnoredUsers: []string{"2"},
+	})
+	g.syncMembersFromServer(context.Background(), session)
+	if len(published) != 0 {
+		t.Errorf("expected the first load to be squelched, got %+v", published)
+	}
+
+	// unannounced events and ignored users are published too
+	g.memberAdded("2", store.Member{User: store.User{Username: "bot", Discriminator: "0"}})
+	g.memberUpdated("1", store.Member{User: store.User{Username: "alice", Discriminator: "0"}, PremiumSince: time.Now()})
+	g.memberRemoved("2")
+
+	types := []string{}
+	for _, event := range published {
+		types = append(types, event.Type)
+		if event.GuildID != testGuildID || event.At.IsZero() {
+			t.Errorf("expected a guild and time in %+v", event)
+		}
+	}
+	if strings.Join(types, ",") != "join,boost_start,leave" {
+		t.Errorf("unexpected published events %v", types)
+	}
+	if published[2].MemberCount != 1 {
+		t.Errorf("expected 1 member after the leave, got %v", published[2].MemberCount)
+	}
+}
+
+func TestLeaveRoles(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	staff := member("1", "alice", "0")
+	staff.Roles = []string{"20", "10"}
+	session.setMembers(testGuildID, staff, member("2", "bob", "0"), member("3", "carol", "0"))
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{LeaveRoles: []string{"10"}})
+	g.syncMembersFromServer(context.Background(), session)
+	if roles := g.state["1"].Roles; !reflect.DeepEqual(roles, []string{"10", "20"}) {
+		t.Errorf("expected sorted roles, got %v", roles)
+	}
+
+	// members without an announced role leave silently, but the leave is still recorded
+	g.memberRemoved("1")
+	g.memberRemoved("2")
+	// a role given after joining counts
+	verified := member("3", "carol", "0")
+	verified.Roles = []string{"10"}
+	g.memberUpdated("3", memberFromDiscord(verified))
+	g.memberRemoved("3")
+	assertSent(t, session, "<@1> (alice) left the server, now 2 members", "<@3> (carol) left the server")
+
+	leaves, err := st.CountEvents(testGuildID, store.EventLeave, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaves != 3 {
+		t.Errorf("expected every leave to be recorded, got %v", leaves)
+	}
+}
+
+func TestLeavesShowStay(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	g.memberAdded("3", store.Member{User: store.User{Username: "carol", Discriminator: "0"}, JoinedAt: time.Now().Add(-400 * 24 * time.Hour)})
+	g.memberRemoved("3")
+	// without a join date, the recorded join is used
+	if err := st.RecordEvent(store.HistoryEvent{GuildID: testGuildID, DiscordID: "1", Event: store.EventJoin, At: time.Now().Add(-49 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	g.memberRemoved("1")
+	// and if there is none, the stay is left out
+	g.memberRemoved("2")
+	assertSent(t, session,
+		"<@3> (carol) joined the server, now 3 members",
+		"<@3> (carol) left the server after being a member for 1 year, 1 month, now 2 members",
+		"<@1> (alice) left the server after being a member for 2 days, 1 hour, now 1 member",
+		"<@2> (bob) left the server",
+	)
+}
+
+func TestCanceledSyncKeepsMembers(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	// a canceled sync fetches nothing, which must not look like everyone left
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	session.setMembers(testGuildID)
+	g.bot.SyncAll(ctx, session)
+	g.syncMembersFromServer(ctx, session)
+	assertSent(t, session)
+	if g.MemberCount() != 1 {
+		t.Errorf("expected the member to be kept, got %v members", g.MemberCount())
+	}
+}
+
+func TestOutdatedEventsAreIgnored(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	alice := member("1", "alice", "0")
+	session.setMembers(testGuildID, alice)
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	// a nickname change received while a sync fetched the newer nickname
+	received := time.Now()
+	alice.Nick = "newer"
+	g.syncMembersFromServer(context.Background(), session)
+	g.memberUpdatedAt(received, "1", store.Member{User: store.User{Username: "alice", Discriminator: "0"}, Nick: "older"}, roleChange{})
+
+	// a join and leave handled out of order
+	joined := time.Now()
+	g.memberRemovedAt(time.Now(), "2")
+	g.memberAddedAt(joined, "2", store.Member{User: store.User{Username: "bob", Discriminator: "0"}}, nil)
+
+	// an event handled twice
+	g.memberAdded("3", store.Member{User: store.User{Username: "carol", Discriminator: "0"}})
+	g.memberAdded("3", store.Member{User: store.User{Username: "carol", Discriminator: "0"}})
+
+	assertSent(t, session, "<@3> (carol) joined the server, now 2 members")
+	assertStored(t, st, map[string]store.Member{
+		"1": {User: store.User{Username: "alice", Discriminator: "0"}, Nick: "newer"},
+		"3": {User: store.User{Username: "carol", Discriminator: "0"}},
+	})
+}
+
+func TestEventsDuringSync(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	members := []*discordgo.Member{}
+	for i := 0; i < 1500; i++ {
+		members = append(members, member(fmt.Sprintf("%05d", i), "user", "0"))
+	}
+	session.setMembers(testGuildID, members...)
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	session.fetchedPage = func(after string) {
+		if after == "" {
+			return
+		}
+		// handled while the sync runs, instead of waiting for it: a leave of a member of the page
+		// the sync reconciled, and of one on the page it just fetched
+		g.memberRemoved("00010")
+		g.memberRemoved("01200")
+		assertSent(t, session, "<@00010> (user) left the server, now 1,499 members", "<@01200> (user) left the server, now 1,498 members")
+	}
+	g.syncMembersFromServer(context.Background(), session)
+	session.fetchedPage = nil
+
+	// the page fetched before the leave doesn't bring the member back
+	assertSent(t, session)
+	if _, ok := g.state["01200"]; ok {
+		t.Error("expected the leave received after the page was fetched to stand")
+	}
+	if len(g.state) != 1498 {
+		t.Errorf("expected 1498 members, got %v", len(g.state))
+	}
+}
+
+func TestHook(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	hook, err := notify.ParseHook(i18n.English, `{{if hasPrefix .Username "spam"}}{{skip}}{{tag "spam"}}{{else if eq .Type "leave"}}{{message "bye"}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := []notify.Event{}
+	g := newTestGuildWithGuildOptions(t, st, session, Options{Publisher: fakePublisher{&published}}, GuildOptions{Hook: hook})
+	g.syncMembersFromServer(context.Background(), session)
+
+	// skipped events are recorded and published, but not announced
+	g.memberAdded("2", store.Member{User: store.User{Username: "spammer", Discriminator: "0"}})
+	g.memberRemoved("1")
+	assertSent(t, session, "bye")
+
+	if len(published) != 2 || !reflect.DeepEqual(published[0].Tags, []string{"spam"}) || !published[0].Skipped {
+		t.Errorf("expected the join to be published tagged, got %+v", published)
+	}
+	joins, err := st.CountEvents(testGuildID, store.EventJoin, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joins != 1 {
+		t.Errorf("expected the skipped join to be recorded, got %v joins", joins)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	eventFilter, err := filter.Parse(`event == "join" || username == "bob"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := []notify.Event{}
+	g := newTestGuildWithGuildOptions(t, st, session, Options{Publisher: fakePublisher{&published}}, GuildOptions{Filter: eventFilter})
+	g.syncMembersFromServer(context.Background(), session)
+
+	// filtered events are still recorded and published
+	g.memberRemoved("1")
+	g.memberRemoved("2")
+	g.memberAdded("3", store.Member{User: store.User{Username: "carol", Discriminator: "0"}})
+	assertSent(t, session, "<@2> (bob) left the server", "<@3> (carol) joined the server, now 1 member")
+	if len(published) != 3 {
+		t.Errorf("expected every event to be published, got %+v", published)
+	}
+}
@@ -0,0 +1,142 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// inactivePeriods are the /userlog inactive choices, in days
+var inactivePeriods = map[string]int{
+	"30d":  30,
+	"90d":  90,
+	"180d": 180,
+	"1y":   365,
+}
+
+// inactiveListSize is how many inactive members the response lists, the CSV has all of them
+const inactiveListSize = 20
+
+func (b *Bot) commandInactive(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	period := "90d"
+	for _, option := range options {
+		if option.Name == "period" {
+			period = option.StringValue()
+		}
+	}
+	return b.inactiveResponse(g, period, time.Now())
+}
+
+// inactiveMember is a current member without activity in the period, lastActive is zero if they were never seen active
+type inactiveMember struct {
+	discordID  string
+	member     store.Member
+	lastActive time.Time
+}
+
+// inactive returns the members that joined before since and weren't active after it, the least recently active first
+func (g *Guild) inactive(activity map[string]time.Time, since time.Time) []inactiveMember {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	inactive := []inactiveMember{}
+	for discordID, member := range g.state {
+		// members who joined during the period didn't have all of it to be active
+		if member.JoinedAt.After(since) {
+			continue
+		}
+		lastActive := activity[discordID]
+		if lastActive.After(since) {
+			continue
+		}
+		inactive = append(inactive, inactiveMember{discordID, member, lastActive})
+	}
+	sort.Slice(inactive, func(i, j int) bool {
+		if !inactive[i].lastActive.Equal(inactive[j].lastActive) {
+			return inactive[i].lastActive.Before(inactive[j].lastActive)
+		}
+		return inactive[i].discordID < inactive[j].discordID
+	})
+	return inactive
+}
+
+func (b *Bot) inactiveResponse(g *Guild, period string, now time.Time) *discordgo.InteractionResponseData {
+	lang := g.language()
+	days, ok := inactivePeriods[period]
+	if !ok {
+		return textResponse(lang.Translate("Unknown period."))
+	}
+	if !b.options.TrackFirstMessages && !b.options.TrackPresence && !g.logsVoice() {
+		return textResponse(lang.Translate("No activity is tracked, turn on first message tracking, presence tracking, or voice logging."))
+	}
+	activity, err := b.store.LastActivity(g.ID, now)
+	if err != nil {
+		log.Printf("failed to load the activity of guild '%v': %v", g.ID, err)
+		return textResponse(lang.Translate("Failed to load the activity, check the logs."))
+	}
+	inactive := g.inactive(activity, now.AddDate(0, 0, -days))
+
+	var description strings.Builder
+	description.WriteString(lang.Sprintf("%v had no activity in the last %v days.", lang.Members(len(inactive)), days))
+	description.WriteString("\n\n")
+	listed := inactive
+	if len(listed) > inactiveListSize {
+		listed = listed[:inactiveListSize]
+	}
+	for _, member := range listed {
+		fmt.Fprintf(&description, "<@%v>", member.discordID)
+		if tag := member.member.User.Tag(); tag != "" {
+			fmt.Fprintf(&description, " (%v)", tag)
+		}
+		if member.lastActive.IsZero() {
+			description.WriteString(" " + lang.Translate("never active") + "\n")
+		} else {
+			fmt.Fprintf(&description, " <t:%v:R>\n", member.lastActive.Unix())
+		}
+	}
+	if len(inactive) > inactiveListSize {
+		description.WriteString(lang.Sprintf("…and %v more, see the CSV.", len(inactive)-inactiveListSize))
+	}
+
+	response := &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       lang.Translate("Inactive Members"),
+			Description: description.String(),
+			Footer:      &discordgo.MessageEmbedFooter{Text: lang.Translate("Activity is only known since it is tracked.")},
+		}},
+	}
+	if len(inactive) > 0 {
+		response.Files = []*discordgo.File{{
+			Name:        "inactive.csv",
+			ContentType: "text/csv",
+			Reader:      bytes.NewReader(inactiveCSV(inactive)),
+		}}
+	}
+	return response
+}
+
+// inactiveCSV lists inactive members with their user ID, tag, and join and last activity times, empty if unknown
+func inactiveCSV(inactive []inactiveMember) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"user_id", "username", "joined_at", "last_active"})
+	for _, member := range inactive {
+		w.Write([]string{member.discordID, member.member.User.Tag(), csvTime(member.member.JoinedAt), csvTime(member.lastActive)})
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestInactive(t *testing.T) {
+	st := openTestStore(t)
+	now := time.Date(2023, 7, 20, 12, 0, 0, 0, time.UTC)
+	longAgo := now.AddDate(-1, 0, 0)
+	for discordID, name := range map[string]string{"1": "alice", "2": "bob", "3": "carol", "4": "dave"} {
+		if err := st.AddMember(testGuildID, discordID, store.Member{User: store.User{Username: name, Discriminator: "0"}, JoinedAt: longAgo}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// erin joined recently, they didn't have 90 days to be active
+	if err := st.AddMember(testGuildID, "5", store.Member{User: store.User{Username: "erin", Discriminator: "0"}, JoinedAt: now.AddDate(0, 0, -10)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.RecordMessage(testGuildID, "1", now.AddDate(0, 0, -5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.RecordMessage(testGuildID, "2", now.AddDate(0, 0, -100)); err != nil {
+		t.Fatal(err)
+	}
+	// carol is still online
+	if err := st.RecordPresence(testGuildID, "3", true, now.AddDate(0, 0, -200)); err != nil {
+		t.Fatal(err)
+	}
+	g := newTestGuildWithOptions(t, st, newFakeSession(), Options{})
+
+	if response := g.bot.inactiveResponse(g, "90d", now); response.Content != "No activity is tracked, turn on first message tracking, presence tracking, or voice logging." {
+		t.Errorf("unexpected response %q", response.Content)
+	}
+
+	g.bot.options.TrackFirstMessages = true
+	response := g.bot.inactiveResponse(g, "90d", now)
+	expected := "2 members had no activity in the last 90 days.\n\n<@4> (dave) never active\n<@2> (bob) <t:1681214400:R>\n"
+	if description := response.Embeds[0].Description; description != expected {
+		t.Errorf("expected description %q, got %q", expected, description)
+	}
+	if len(response.Files) != 1 {
+		t.Fatalf("expected a CSV, got %v files", len(response.Files))
+	}
+	csv, _ := io.ReadAll(response.Files[0].Reader)
+	expected = "user_id,username,joined_at,last_active\n4,dave,2022-07-20T12:00:00Z,\n2,bob,2022-07-20T12:00:00Z,2023-04-11T12:00:00Z\n"
+	if string(csv) != expected {
+		t.Errorf("expected CSV %q, got %q", expected, csv)
+	}
+}
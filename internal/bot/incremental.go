@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/telemetry"
+)
+
+// memberRange are the member IDs after one and up to another, snowflakes are compared as numbers
+type memberRange struct {
+	after uint64
+	// through is the last ID in the range, unless unbounded
+	through   uint64
+	unbounded bool
+}
+
+func (r memberRange) contains(discordID string) bool {
+	id, err := strconv.ParseUint(discordID, 10, 64)
+	if err != nil {
+		return false
+	}
+	return id > r.after && (r.unbounded || id <= r.through)
+}
+
+// past reports whether an ID comes after the range
+func (r memberRange) past(discordID string) bool {
+	id, err := strconv.ParseUint(discordID, 10, 64)
+	return err == nil && !r.unbounded && id > r.through
+}
+
+// SyncIncremental reconciles the next of slices ranges of the members of every guild with the server,
+// a pass over every range takes slices calls. Guilds that weren't fully synced yet are fully synced instead.
+func (b *Bot) SyncIncremental(ctx context.Context, s Session, slices int) {
+	for _, g := range b.guilds {
+		if ctx.Err() != nil {
+			return
+		}
+		g.syncSliceFromServer(ctx, s, slices)
+	}
+}
+
+// nextSliceLocked picks the range of members to reconcile next. Ranges split the known members into slices
+// of about the same size by ID, the last one also covering members newer than every known one.
+// Members joining and leaving between syncs move the boundaries, so a pass may reconcile some members twice and others not at all.
+func (g *Guild) nextSliceLocked(slices int) memberRange {
+	ids := make([]uint64, 0, len(g.state))
+	for discordID := range g.state {
+		if id, err := strconv.ParseUint(discordID, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	slice := g.nextSlice % slices
+	g.nextSlice = slice + 1
+	r := memberRange{unbounded: slice == slices-1}
+	if start := slice * len(ids) / slices; start > 0 {
+		r.after = ids[start-1]
+	}
+	if end := (slice + 1) * len(ids) / slices; !r.unbounded && end > 0 {
+		r.through = ids[end-1]
+	}
+	return r
+}
+
+// syncSliceFromServer reconciles the next range of members with the server over REST, the other members aren't fetched.
+// Members in the range that weren't found are assumed to have left.
+func (g *Guild) syncSliceFromServer(ctx context.Context, s Session, slices int) {
+	g.lock.Lock()
+	loaded := g.stateLoaded
+	var r memberRange
+	if loaded {
+		r = g.nextSliceLocked(slices)
+	}
+	g.lock.Unlock()
+	if !loaded {
+		g.syncMembersFromServer(ctx, s)
+		return
+	}
+
+	ctx, span := telemetry.Start(ctx, "sync guild", telemetry.String("guild.id", g.ID), telemetry.String("user_log.sync.mode", "incremental"))
+	defer span.End()
+	run, done := g.startSync(r.contains)
+	defer done()
+
+	after := strconv.FormatUint(r.after, 10)
+	const limit = 1000
+	for {
+		if ctx.Err() != nil {
+			log.Printf("canceled the sync of guild '%v'", g.ID)
+			span.Fail(ctx.Err())
+			return
+		}
+		fetched := time.Now()
+		_, fetchSpan := telemetry.Start(ctx, "fetch members", telemetry.String("after", after))
+		members, err := s.GuildMembers(g.ID, after, limit)
+		fetchSpan.Fail(err)
+		fetchSpan.End()
+		if err != nil {
+			log.Fatalf("failed fetching guild members after '%v': %v", after, err)
+		}
+
+		// pages are sorted by ID, the range ends at the first member past it
+		inRange := members
+		for i, member := range members {
+			if member.User != nil && r.past(member.User.ID) {
+				inRange = members[:i]
+				break
+			}
+		}
+		if !run.apply(ctx, inRange, fetched) {
+			return
+		}
+
+		if len(members) < limit || len(inRange) < len(members) {
+			break
+		}
+		after = members[len(members)-1].User.ID
+	}
+
+	// the member list is written after the step, it may be long
+	var memberList []string
+	run.step(func() {
+		span.SetAttributes(telemetry.Int("user_log.sync.missed_leaves", run.removeUnseenLocked()))
+		if err := g.store.RecordSnapshot(g.ID, time.Now(), len(g.state)); err != nil {
+			log.Printf("failed to record member count snapshot of guild '%v': %v", g.ID, err)
+		}
+		memberList = g.memberListLocked(time.Now())
+		span.SetAttributes(telemetry.Int("user_log.sync.members", len(g.state)))
+	})
+	run.recordMemberList(time.Now(), memberList)
+	syncDuration.Record(time.Since(run.started).Seconds(), telemetry.String("user_log.sync.mode", "incremental"))
+}
@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSyncIncremental(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID,
+		member("1", "alice", "0"), member("2", "bob", "0"), member("3", "carol", "0"),
+		member("4", "dave", "0"), member("5", "erin", "0"), member("6", "frank", "0"),
+	)
+	g := newTestGuild(t, st, session)
+	// guilds that weren't synced yet are fully synced
+	g.bot.SyncIncremental(context.Background(), session, 3)
+	if len(g.state) != 6 {
+		t.Fatalf("expected a full sync, found %v members", len(g.state))
+	}
+
+	session.setMembers(testGuildID,
+		member("2", "bob", "0"), member("3", "carol", "0"), member("4", "dave", "0"),
+		member("6", "frank", "0"), member("7", "grace", "0"),
+	)
+	session.guildMemberCalls = 0
+	// the first range is alice and bob
+	g.bot.SyncIncremental(context.Background(), session, 3)
+	assertSent(t, session, "<@1> (alice) left the server, now 5 members")
+	// then carol and dave
+	g.bot.SyncIncremental(context.Background(), session, 3)
+	assertSent(t, session)
+	// then the rest, including new members
+	g.bot.SyncIncremental(context.Background(), session, 3)
+	assertSent(t, session, "<@7> (grace) joined the server, now 6 members\n<@5> (erin) left the server, now 5 members")
+	if session.guildMemberCalls != 3 {
+		t.Errorf("expected a GuildMembers call per range, got %v", session.guildMemberCalls)
+	}
+
+	// the next pass starts over
+	session.setMembers(testGuildID, member("3", "carol", "0"), member("4", "dave", "0"), member("6", "frank", "0"), member("7", "grace", "0"))
+	g.bot.SyncIncremental(context.Background(), session, 3)
+	assertSent(t, session, "<@2> (bob) left the server, now 4 members")
+}
@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"log"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// InviteLister lists the invites of a guild, it is implemented by *discordgo.Session.
+// Listing invites needs the Manage Server permission.
+type InviteLister interface {
+	GuildInvites(guildID string) ([]*discordgo.Invite, error)
+}
+
+// joinDetails are stored with join history events of members whose invite is known
+type joinDetails struct {
+	Invite    string `json:"invite"`
+	InviterID string `json:"inviter_id,omitempty"`
+}
+
+// knownInvite is what inviteTracker remembers about an invite
+type knownInvite struct {
+	uses      int
+	maxUses   int
+	inviterID string
+}
+
+// inviteTracker tells which invite a member joined with by comparing the use counts of a guild's invites
+// before and after the join. Discord doesn't say which invite was used.
+type inviteTracker struct {
+	lock sync.Mutex
+	// invites maps invite codes to their last known state, nil until loaded
+	invites map[string]knownInvite
+}
+
+func fromInvites(invites []*discordgo.Invite) map[string]knownInvite {
+	known := make(map[string]knownInvite, len(invites))
+	for _, invite := range invites {
+		inviterID := ""
+		if invite.Inviter != nil {
+			inviterID = invite.Inviter.ID
+		}
+		known[invite.Code] = knownInvite{uses: invite.Uses, maxUses: invite.MaxUses, inviterID: inviterID}
+	}
+	return known
+}
+
+// load replaces the known invites with the current ones
+func (t *inviteTracker) load(s InviteLister, guildID string) {
+	invites, err := s.GuildInvites(guildID)
+	if err != nil {
+		log.Printf("failed to list the invites of guild '%v', joins won't show their invite until it works: %v", guildID, err)
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.invites = fromInvites(invites)
+}
+
+// created remembers an invite created while connected, so a single-use invite is known before it's used up and deleted
+func (t *inviteTracker) created(invite *discordgo.Invite) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.invites == nil {
+		return
+	}
+	for code, known := range fromInvites([]*discordgo.Invite{invite}) {
+		t.invites[code] = known
+	}
+}
+
+// used returns the invite a member who just joined used, nil if it can't be told.
+// It can't when several members joined with different invites since the last join, or with the vanity URL,
+// and before the invites were loaded.
+func (t *inviteTracker) used(s InviteLister, guildID string) *joinDetails {
+	// joins are handled concurrently, comparing them one at a time keeps each one's baseline
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	invites, err := s.GuildInvites(guildID)
+	if err != nil {
+		log.Printf("failed to list the invites of guild '%v': %v", guildID, err)
+		return nil
+	}
+	before := t.invites
+	t.invites = fromInvites(invites)
+	if before == nil {
+		return nil
+	}
+
+	candidates := []joinDetails{}
+	for code, after := range t.invites {
+		if after.uses > before[code].uses {
+			candidates = append(candidates, joinDetails{Invite: code, InviterID: after.inviterID})
+		}
+	}
+	for code, known := range before {
+		// invites are deleted when they are used up
+		if _, ok := t.invites[code]; !ok && known.maxUses > 0 && known.uses+1 >= known.maxUses {
+			candidates = append(candidates, joinDetails{Invite: code, InviterID: known.inviterID})
+		}
+	}
+	if len(candidates) != 1 {
+		return nil
+	}
+	return &candidates[0]
+}
+
+func (b *Bot) inviteCreate(s *discordgo.Session, e *discordgo.InviteCreate) {
+	if !b.options.TrackInvites || e.Invite == nil {
+		return
+	}
+	if g, ok := b.guilds[e.GuildID]; ok {
+		g.invites.created(e.Invite)
+	}
+}
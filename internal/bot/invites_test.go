@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+type fakeInviteLister struct {
+	invites []*discordgo.Invite
+	err     error
+}
+
+func (l *fakeInviteLister) GuildInvites(guildID string) ([]*discordgo.Invite, error) {
+	return l.invites, l.err
+}
+
+func invite(code string, uses, maxUses int, inviterID string) *discordgo.Invite {
+	return &discordgo.Invite{Code: code, Uses: uses, MaxUses: maxUses, Inviter: &discordgo.User{ID: inviterID}}
+}
+
+func TestInviteTrackerUsed(t *testing.T) {
+	lister := &fakeInviteLister{}
+	tracker := inviteTracker{}
+	if used := tracker.used(lister, testGuildID); used != nil {
+		t.Errorf("expected no invite before loading, got %+v", used)
+	}
+
+	lister.invites = []*discordgo.Invite{invite("abc", 3, 0, "10"), invite("def", 0, 0, "11")}
+	tracker.load(lister, testGuildID)
+	lister.invites = []*discordgo.Invite{invite("abc", 4, 0, "10"), invite("def", 0, 0, "11")}
+	if used := tracker.used(lister, testGuildID); used == nil || *used != (joinDetails{Invite: "abc", InviterID: "10"}) {
+		t.Errorf("expected abc, got %+v", used)
+	}
+
+	// a single-use invite created while connected is deleted when it's used
+	tracker.created(invite("once", 0, 1, "12"))
+	if used := tracker.used(lister, testGuildID); used == nil || *used != (joinDetails{Invite: "once", InviterID: "12"}) {
+		t.Errorf("expected the used up invite, got %+v", used)
+	}
+
+	lister.invites = []*discordgo.Invite{invite("abc", 5, 0, "10"), invite("def", 1, 0, "11")}
+	if used := tracker.used(lister, testGuildID); used != nil {
+		t.Errorf("expected two used invites to be ambiguous, got %+v", used)
+	}
+
+	lister.err = errors.New("missing permissions")
+	if used := tracker.used(lister, testGuildID); used != nil {
+		t.Errorf("expected no invite when listing fails, got %+v", used)
+	}
+}
+
+func TestWhoisShowsInvite(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	g := newTestGuildWithOptions(t, st, session, Options{TrackInvites: true})
+	g.syncMembersFromServer(context.Background(), session)
+
+	lister := &fakeInviteLister{invites: []*discordgo.Invite{invite("abc", 0, 0, "10")}}
+	g.invites.load(lister, testGuildID)
+	lister.invites = []*discordgo.Invite{invite("abc", 1, 0, "10")}
+	g.memberAddedAt(time.Now(), "2", store.Member{User: store.User{Username: "bob", Discriminator: "0"}}, g.invites.used(lister, testGuildID))
+	g.memberAddedAt(time.Now(), "3", store.Member{User: store.User{Username: "carol", Discriminator: "0"}}, g.invites.used(lister, testGuildID))
+
+	for discordID, expected := range map[string]string{"2": "`abc` by <@10>", "3": "Unknown"} {
+		found := false
+		for _, field := range g.bot.whoisResponse(g, discordID).Embeds[0].Fields {
+			if field.Name == "Invite used" {
+				found = true
+				if field.Value != expected {
+					t.Errorf("expected the invite of %v to be %q, got %q", discordID, expected, field.Value)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("expected whois of %v to show the invite", discordID)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func (b *Bot) presenceUpdate(s *discordgo.Session, p *discordgo.PresenceUpdate) {
+	if !b.options.TrackPresence || p.User == nil {
+		return
+	}
+	g, ok := b.guilds[p.GuildID]
+	if !ok {
+		return
+	}
+	// invisible members look offline
+	g.presenceUpdated(p.User.ID, p.Status != discordgo.StatusOffline, time.Now())
+}
+
+// guildCreate learns the guild's name, and who is online when the bot connects, Discord only sends presence updates for changes afterwards
+func (b *Bot) guildCreate(s *discordgo.Session, c *discordgo.GuildCreate) {
+	if c.Guild == nil {
+		return
+	}
+	g, ok := b.guilds[c.ID]
+	if !ok {
+		return
+	}
+	b.setGuildName(c.ID, c.Name)
+	if b.options.TrackInvites {
+		// the baseline joins are compared with, reloaded after reconnecting in case invites were used meanwhile
+		g.invites.load(s, c.ID)
+	}
+	if !b.options.TrackPresence {
+		return
+	}
+	online := []string{}
+	for _, presence := range c.Presences {
+		if presence.User != nil && presence.Status != discordgo.StatusOffline {
+			online = append(online, presence.User.ID)
+		}
+	}
+	g.presencesLoaded(online, time.Now())
+}
+
+// presenceUpdated records a member coming online or going offline
+func (g *Guild) presenceUpdated(discordID string, online bool, at time.Time) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed {
+		return
+	}
+	if known, ok := g.online[discordID]; ok && known == online {
+		return
+	}
+	g.online[discordID] = online
+	if err := g.store.RecordPresence(g.ID, discordID, online, at); err != nil {
+		log.Printf("failed to record the presence of '%v': %v", discordID, err)
+	}
+}
+
+// presencesLoaded records who is online after connecting, members stored as online who aren't went offline while the bot was away
+func (g *Guild) presencesLoaded(online []string, at time.Time) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed {
+		return
+	}
+	g.online = make(map[string]bool, len(online))
+	for _, discordID := range online {
+		g.online[discordID] = true
+		if err := g.store.RecordPresence(g.ID, discordID, true, at); err != nil {
+			log.Printf("failed to record the presence of '%v': %v", discordID, err)
+		}
+	}
+	presences, err := g.store.Presences(g.ID)
+	if err != nil {
+		log.Printf("failed to load the presences of guild '%v': %v", g.ID, err)
+		return
+	}
+	for _, presence := range presences {
+		if !presence.Online || g.online[presence.DiscordID] {
+			continue
+		}
+		g.online[presence.DiscordID] = false
+		if err := g.store.RecordPresence(g.ID, presence.DiscordID, false, at); err != nil {
+			log.Printf("failed to record the presence of '%v': %v", presence.DiscordID, err)
+		}
+	}
+}
+
+func (b *Bot) commandLastSeen(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	return b.lastSeen(g, options[0].UserValue(nil).ID)
+}
+
+func (b *Bot) lastSeen(g *Guild, discordID string) *discordgo.InteractionResponseData {
+	lang := g.language()
+	if !b.options.TrackPresence {
+		return textResponse(lang.Translate("Presence tracking is off."))
+	}
+	presence, ok, err := b.store.LastSeen(g.ID, discordID)
+	if err != nil {
+		log.Printf("failed to load the presence of '%v': %v", discordID, err)
+		return textResponse(lang.Translate("Failed to load the presence, check the logs."))
+	}
+	switch {
+	case !ok:
+		return textResponse(lang.Sprintf("<@%v> wasn't seen online yet.", discordID))
+	case presence.Online:
+		return textResponse(lang.Sprintf("<@%v> is online, since <t:%v:R>.", discordID, presence.OnlineSince.Unix()))
+	default:
+		return textResponse(lang.Sprintf("<@%v> was last seen online <t:%v:f> (<t:%v:R>).", discordID, presence.LastSeen.Unix(), presence.LastSeen.Unix()))
+	}
+}
@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLastSeen(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	g := newTestGuildWithOptions(t, st, session, Options{TrackPresence: true})
+	start := time.Unix(1700000000, 0)
+
+	// bob was online during the last run, but isn't anymore
+	if err := st.RecordPresence(testGuildID, "2", true, start); err != nil {
+		t.Fatal(err)
+	}
+	g.presencesLoaded([]string{"1"}, start.Add(time.Hour))
+	g.presenceUpdated("1", true, start.Add(2*time.Hour))
+	g.presenceUpdated("1", false, start.Add(3*time.Hour))
+	g.presenceUpdated("3", true, start.Add(4*time.Hour))
+
+	for discordID, expected := range map[string]string{
+		"1": "<@1> was last seen online <t:1700010800:f> (<t:1700010800:R>).",
+		"2": "<@2> was last seen online <t:1700003600:f> (<t:1700003600:R>).",
+		"3": "<@3> is online, since <t:1700014400:R>.",
+		"4": "<@4> wasn't seen online yet.",
+	} {
+		if response := g.bot.lastSeen(g, discordID); response.Content != expected {
+			t.Errorf("expected %q, got %q", expected, response.Content)
+		}
+	}
+
+	g.bot.options.TrackPresence = false
+	if response := g.bot.lastSeen(g, "1"); response.Content != "Presence tracking is off." {
+		t.Errorf("unexpected response %q", response.Content)
+	}
+}
@@ -0,0 +1,229 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// SurveySender is the subset of *discordgo.Session used to send leave surveys
+type SurveySender interface {
+	UserChannelCreate(recipientID string) (*discordgo.Channel, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error)
+}
+
+// LeaveSurvey asks members who leave why they did. Members who can't be DMed anymore,
+// because they share no other server with the bot or blocked DMs, get a note moderators can fill in instead.
+type LeaveSurvey struct {
+	Sender SurveySender
+	// Reasons are offered as buttons, at most maxSurveyReasons, empty offers defaultSurveyReasons
+	Reasons []string
+	// NoteChannelID receives the notes for moderators, empty sends none
+	NoteChannelID string
+}
+
+// maxSurveyReasons is how many buttons fit in a row
+const maxSurveyReasons = 5
+
+// defaultSurveyReasons are offered when no reasons are configured, translated to the guild's language
+var defaultSurveyReasons = []string{
+	"Not enough activity",
+	"Too many notifications",
+	"Didn't find what I was looking for",
+	"Something else",
+}
+
+// surveyReasonsLocked returns the reasons offered by the leave survey
+func (g *Guild) surveyReasonsLocked() []string {
+	if len(g.leaveSurvey.Reasons) > 0 {
+		return g.leaveSurvey.Reasons
+	}
+	reasons := make([]string, len(defaultSurveyReasons))
+	for i, reason := range defaultSurveyReasons {
+		reasons[i] = g.lang.Translate(reason)
+	}
+	return reasons
+}
+
+// surveyButtons offers each reason as a button, with custom IDs of the prefix followed by the reason's index
+func surveyButtons(reasons []string, customIDPrefix string) []discordgo.MessageComponent {
+	buttons := make([]discordgo.MessageComponent, len(reasons))
+	for i, reason := range reasons {
+		buttons[i] = discordgo.Button{
+			Label:    reason,
+			Style:    discordgo.SecondaryButton,
+			CustomID: customIDPrefix + ":" + strconv.Itoa(i),
+		}
+	}
+	return []discordgo.MessageComponent{discordgo.ActionsRow{Components: buttons}}
+}
+
+// surveyLeaveLocked asks a member who just left why in the background, or moderators if the member can't be DMed
+func (g *Guild) surveyLeaveLocked(discordID string, user store.User, leftAt time.Time) {
+	if g.leaveSurvey == nil || g.leaveSurvey.Sender == nil {
+		return
+	}
+	if _, ignored := g.ignored[discordID]; ignored {
+		return
+	}
+	survey, lang, guildID := *g.leaveSurvey, g.lang, g.ID
+	reasons := g.surveyReasonsLocked()
+	go func() {
+		channel, err := survey.Sender.UserChannelCreate(discordID)
+		if err == nil {
+			_, err = survey.Sender.ChannelMessageSendComplex(channel.ID, &discordgo.MessageSend{
+				Content:    lang.Translate("Sorry to see you go! If you have a moment, why did you leave?"),
+				Components: surveyButtons(reasons, fmt.Sprintf("%v:survey:%v:%v", userlogCommand.Name, guildID, leftAt.Unix())),
+			})
+		}
+		if err == nil {
+			log.Printf("sent the leave survey to '%v' of guild '%v'", discordID, guildID)
+			return
+		}
+		log.Printf("failed to send the leave survey to '%v' of guild '%v': %v", discordID, guildID, err)
+		if survey.NoteChannelID == "" {
+			return
+		}
+
+		name := fmt.Sprintf("<@%v>", discordID)
+		if tag := user.Tag(); tag != "" {
+			name += fmt.Sprintf(" (%v)", tag)
+		}
+		_, err = survey.Sender.ChannelMessageSendComplex(survey.NoteChannelID, &discordgo.MessageSend{
+			Content:         lang.Sprintf("%v left and couldn't be asked why. If you know the reason, pick it:", name),
+			Components:      surveyButtons(reasons, fmt.Sprintf("%v:leavereason:%v:%v", userlogCommand.Name, discordID, leftAt.Unix())),
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		})
+		if err != nil {
+			log.Printf("failed to send the leave reason note about '%v' of guild '%v': %v", discordID, guildID, err)
+		}
+	}()
+}
+
+// recordLeaveReason records the reason picked by its index in a leave survey, returning it.
+// It returns false if the reason is unknown, because the configured reasons changed since.
+func (g *Guild) recordLeaveReason(discordID, answeredBy string, leftAt time.Time, index int) (string, bool, error) {
+	g.lock.Lock()
+	var reasons []string
+	if g.leaveSurvey != nil {
+		reasons = g.surveyReasonsLocked()
+	}
+	g.lock.Unlock()
+	if index < 0 || index >= len(reasons) {
+		return "", false, nil
+	}
+
+	reason := store.LeaveReason{
+		DiscordID:  discordID,
+		LeftAt:     leftAt,
+		Reason:     reasons[index],
+		AnsweredBy: answeredBy,
+		AnsweredAt: time.Now(),
+	}
+	if err := g.store.RecordLeaveReason(g.ID, reason); err != nil {
+		return "", false, err
+	}
+	log.Printf("recorded why '%v' left guild '%v': %v", discordID, g.ID, reason.Reason)
+	return reason.Reason, true, nil
+}
+
+// parseSurveyAnswer parses the arguments of survey buttons, the subject (a guild or member ID), the leave time, and the reason's index
+func parseSurveyAnswer(args []string) (string, time.Time, int, bool) {
+	if len(args) != 3 {
+		return "", time.Time{}, 0, false
+	}
+	leftAt, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, 0, false
+	}
+	index, err := strconv.Atoi(args[2])
+	if err != nil {
+		return "", time.Time{}, 0, false
+	}
+	return args[0], time.Unix(leftAt, 0), index, true
+}
+
+// handleSurveyAnswer records the answer of a former member to a leave survey sent by DM
+func (b *Bot) handleSurveyAnswer(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	parts := strings.Split(i.MessageComponentData().CustomID, ":")
+	if len(parts) < 2 || parts[0] != userlogCommand.Name || parts[1] != "survey" {
+		return
+	}
+	guildID, leftAt, index, ok := parseSurveyAnswer(parts[2:])
+	if !ok {
+		return
+	}
+	g, ok := b.guilds[guildID]
+	if !ok {
+		return
+	}
+
+	lang := g.language()
+	response := textResponse(lang.Translate("Thanks for letting us know!"))
+	if _, ok, err := g.recordLeaveReason(i.User.ID, i.User.ID, leftAt, index); err != nil {
+		log.Printf("failed to record why '%v' left guild '%v': %v", i.User.ID, guildID, err)
+		response = textResponse(lang.Translate("Sorry, your answer couldn't be saved."))
+	} else if !ok {
+		response = textResponse(lang.Translate("This survey has expired."))
+	}
+	// the survey can only be answered once
+	response.Components = []discordgo.MessageComponent{}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: response,
+	})
+	if err != nil {
+		log.Printf("failed to respond to leave survey answer: %v", err)
+	}
+}
+
+// componentLeaveReason records the reason a moderator picked on the note about a member who couldn't be surveyed
+func (b *Bot) componentLeaveReason(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, args []string) *discordgo.InteractionResponseData {
+	lang := g.language()
+	discordID, leftAt, index, ok := parseSurveyAnswer(args)
+	if !ok {
+		return textResponse(lang.Translate("This survey has expired."))
+	}
+	reason, ok, err := g.recordLeaveReason(discordID, i.Member.User.ID, leftAt, index)
+	if err != nil {
+		log.Printf("failed to record why '%v' left guild '%v': %v", discordID, g.ID, err)
+		return textResponse(lang.Translate("Failed to save the reason, check the logs."))
+	}
+	response := textResponse(lang.Translate("This survey has expired."))
+	if ok {
+		response = textResponse(lang.Sprintf("<@%v> left: %v (noted by <@%v>)", discordID, reason, i.Member.User.ID))
+	}
+	response.Components = []discordgo.MessageComponent{}
+	response.AllowedMentions = &discordgo.MessageAllowedMentions{}
+	return response
+}
+
+// leaveReasonCounts summarizes leave reasons, the most common first
+func leaveReasonCounts(reasons []store.LeaveReason) string {
+	counts := map[string]int{}
+	for _, reason := range reasons {
+		counts[reason.Reason]++
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	var summary strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&summary, "%v: %v\n", name, counts[name])
+	}
+	return summary.String()
+}
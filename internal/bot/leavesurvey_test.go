@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type sentSurvey struct {
+	channelID string
+	content   string
+	customIDs []string
+}
+
+// fakeSurveySender fails to open DMs with blocked users, and reports sent surveys on a channel
+type fakeSurveySender struct {
+	blocked map[string]bool
+	sent    chan sentSurvey
+}
+
+func (f *fakeSurveySender) UserChannelCreate(recipientID string) (*discordgo.Channel, error) {
+	if f.blocked[recipientID] {
+		return nil, errors.New("cannot send messages to this user")
+	}
+	return &discordgo.Channel{ID: "dm-" + recipientID}, nil
+}
+
+func (f *fakeSurveySender) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	survey := sentSurvey{channelID: channelID, content: data.Content}
+	for _, button := range data.Components[0].(discordgo.ActionsRow).Components {
+		survey.customIDs = append(survey.customIDs, button.(discordgo.Button).CustomID)
+	}
+	f.sent <- survey
+	return &discordgo.Message{ChannelID: channelID}, nil
+}
+
+func TestLeaveSurvey(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	sender := &fakeSurveySender{blocked: map[string]bool{"2": true}, sent: make(chan sentSurvey, 1)}
+	survey := &LeaveSurvey{Sender: sender, Reasons: []string{"Too quiet", "Too loud"}, NoteChannelID: "mods"}
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{LeaveSurvey: survey})
+	g.syncMembersFromServer(context.Background(), session)
+
+	expectSurvey := func(channelID string) sentSurvey {
+		t.Helper()
+		select {
+		case sent := <-sender.sent:
+			if sent.channelID != channelID || len(sent.customIDs) != 2 {
+				t.Fatalf("unexpected survey %+v, expected one in %v", sent, channelID)
+			}
+			return sent
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for a survey in %v", channelID)
+		}
+		return sentSurvey{}
+	}
+
+	// reachable members are asked themselves
+	g.memberRemoved("1")
+	sent := expectSurvey("dm-1")
+	guildID, leftAt, index, ok := parseSurveyAnswer(splitCustomID(t, sent.customIDs[1], "survey"))
+	if !ok || guildID != testGuildID || index != 1 {
+		t.Fatalf("unexpected survey button %v", sent.customIDs[1])
+	}
+	if _, ok, err := g.recordLeaveReason("1", "1", leftAt, index); !ok || err != nil {
+		t.Fatalf("failed to record the answer: %v, %v", ok, err)
+	}
+	if reason, _, _ := st.LeaveReason(testGuildID, "1"); reason.Reason != "Too loud" || reason.AnsweredBy != "1" {
+		t.Errorf("unexpected leave reason %+v", reason)
+	}
+
+	// unreachable members get a note moderators can fill in
+	g.memberRemoved("2")
+	sent = expectSurvey("mods")
+	discordID, leftAt, index, ok := parseSurveyAnswer(splitCustomID(t, sent.customIDs[0], "leavereason"))
+	if !ok || discordID != "2" || index != 0 {
+		t.Fatalf("unexpected note button %v", sent.customIDs[0])
+	}
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{Member: &discordgo.Member{User: &discordgo.User{ID: "mod"}}}}
+	response := g.bot.componentLeaveReason(nil, i, g, []string{"2", "0", "0"})
+	if response.Content != "<@2> left: Too quiet (noted by <@mod>)" || response.Components == nil {
+		t.Errorf("unexpected note response %+v", response)
+	}
+	if reason, _, _ := st.LeaveReason(testGuildID, "2"); reason.Reason != "Too quiet" || reason.AnsweredBy != "mod" {
+		t.Errorf("unexpected leave reason %+v", reason)
+	}
+
+	// reasons removed from the config since can't be picked anymore
+	if _, ok, err := g.recordLeaveReason("2", "mod", leftAt, 5); ok || err != nil {
+		t.Errorf("expected an unknown reason to be rejected, got %v, %v", ok, err)
+	}
+}
+
+func splitCustomID(t *testing.T, customID, component string) []string {
+	t.Helper()
+	prefix := userlogCommand.Name + ":" + component + ":"
+	if !strings.HasPrefix(customID, prefix) {
+		t.Fatalf("expected a %v custom ID, got %v", component, customID)
+	}
+	return strings.Split(strings.TrimPrefix(customID, prefix), ":")
+}
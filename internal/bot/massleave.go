@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+)
+
+// MassLeave alerts when more than Count members leave within Window, a zero Count disables it
+type MassLeave struct {
+	Count  int
+	Window time.Duration
+}
+
+// trackLeaveLocked counts a leave seen over the gateway, alerting if too many happened recently
+func (g *Guild) trackLeaveLocked(at time.Time) {
+	if g.massLeave.Count <= 0 {
+		return
+	}
+	cutoff := at.Add(-g.massLeave.Window)
+	recent := g.recentLeaves[:0]
+	for _, leftAt := range g.recentLeaves {
+		if leftAt.After(cutoff) {
+			recent = append(recent, leftAt)
+		}
+	}
+	g.recentLeaves = append(recent, at)
+	if len(g.recentLeaves) <= g.massLeave.Count {
+		return
+	}
+
+	count := len(g.recentLeaves)
+	// start counting again, so a single exodus alerts once per threshold
+	g.recentLeaves = nil
+	log.Printf("%v members left guild '%v' in the last %v, alerting", count, g.ID, g.massLeave.Window)
+	event := notify.Event{
+		Type:        notify.EventMassLeave,
+		GuildID:     g.ID,
+		At:          at,
+		MemberCount: len(g.state),
+		Count:       count,
+		Window:      g.massLeave.Window,
+	}
+	g.publishLocked(&event)
+	err := g.queueLocked(event, true)
+	if err != nil {
+		sendFailed(err, "failed to send mass leave alert")
+	}
+}
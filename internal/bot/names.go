@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// namesMaxLength keeps the list within Discord's embed description limit
+const namesMaxLength = 4000
+
+func (b *Bot) commandNames(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	discordID := options[0].UserValue(nil).ID
+	return b.namesResponse(g, discordID)
+}
+
+func (b *Bot) namesResponse(g *Guild, discordID string) *discordgo.InteractionResponseData {
+	lang := g.language()
+	names, err := b.store.Names(g.ID, discordID)
+	if err != nil {
+		log.Printf("failed to load names of '%v': %v", discordID, err)
+		return textResponse(lang.Translate("Failed to load names, check the logs."))
+	}
+	if len(names) == 0 {
+		return textResponse(lang.Sprintf("No names were seen for <@%v>.", discordID))
+	}
+
+	var description strings.Builder
+	for _, section := range []struct{ kind, title string }{
+		{store.NameUsername, "Usernames"},
+		{store.NameNick, "Nicknames"},
+	} {
+		lines := []string{}
+		for _, name := range names {
+			if name.Kind != section.kind {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("`%v` <t:%v:d> – <t:%v:d>", strings.ReplaceAll(name.Name, "`", "'"), name.FirstSeen.Unix(), name.LastSeen.Unix()))
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(&description, "**%v**\n", lang.Translate(section.title))
+		for _, line := range lines {
+			if description.Len()+len(line) > namesMaxLength {
+				description.WriteString("…\n")
+				break
+			}
+			description.WriteString(line + "\n")
+		}
+		description.WriteString("\n")
+	}
+
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       lang.Translate("Name History"),
+			Description: fmt.Sprintf("<@%v>\n\n%v", discordID, description.String()),
+			Footer:      &discordgo.MessageEmbedFooter{Text: lang.Translate("First seen – last seen")},
+		}},
+	}
+}
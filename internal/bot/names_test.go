@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestNameHistory(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	m := member("1", "alice", "0")
+	m.Nick = "Al"
+	session.setMembers(testGuildID, m)
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	g.memberUpdated("1", store.Member{User: store.User{Username: "mallory", Discriminator: "0"}})
+
+	names, err := st.Names(testGuildID, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := []string{}
+	for _, name := range names {
+		seen = append(seen, name.Kind+":"+name.Name)
+	}
+	if strings.Join(seen, ",") != "username:alice,nick:Al,username:mallory" {
+		t.Errorf("unexpected names %v", seen)
+	}
+
+	response := g.bot.namesResponse(g, "1")
+	description := response.Embeds[0].Description
+	for _, expected := range []string{"**Usernames**\n`alice`", "`mallory`", "**Nicknames**\n`Al`"} {
+		if !strings.Contains(description, expected) {
+			t.Errorf("expected %q in %q", expected, description)
+		}
+	}
+
+	if response := g.bot.namesResponse(g, "2"); response.Content != "No names were seen for <@2>." {
+		t.Errorf("unexpected response for an unknown user %+v", response)
+	}
+}
@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/i18n"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// maxNoteLength is how long a note can be, any note fits into the notes field of /userlog whois
+const maxNoteLength = 900
+
+func (b *Bot) commandNote(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	if len(options) == 0 || options[0].Name != "add" {
+		return textResponse(g.language().Translate("Unknown command."))
+	}
+	var discordID, text string
+	for _, option := range options[0].Options {
+		switch option.Name {
+		case "user":
+			discordID = option.UserValue(nil).ID
+		case "text":
+			text = option.StringValue()
+		}
+	}
+	return b.addNote(g, discordID, i.Member.User.ID, text)
+}
+
+// addNote stores a moderator's note about a user
+func (b *Bot) addNote(g *Guild, discordID, authorID, text string) *discordgo.InteractionResponseData {
+	lang := g.language()
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return textResponse(lang.Translate("The note is empty."))
+	}
+	if len(text) > maxNoteLength {
+		return textResponse(lang.Sprintf("Notes can be at most %v characters long.", maxNoteLength))
+	}
+	note := store.Note{DiscordID: discordID, Text: text, AuthorID: authorID, At: time.Now()}
+	if _, err := b.store.AddNote(g.ID, note); err != nil {
+		log.Printf("failed to add a note about '%v' in guild '%v': %v", discordID, g.ID, err)
+		return textResponse(lang.Translate("Failed to save the note, check the logs."))
+	}
+	log.Printf("'%v' added a note about '%v' in guild '%v'", authorID, discordID, g.ID)
+	return textResponse(lang.Sprintf("Noted about <@%v>.", discordID))
+}
+
+// notesText lists notes newest first, as much as fits into an embed field
+func notesText(lang *i18n.Language, notes []store.Note) string {
+	var text strings.Builder
+	for i := len(notes) - 1; i >= 0; i-- {
+		note := notes[i]
+		line := lang.Sprintf("<t:%v:d> by <@%v>: %v", note.At.Unix(), note.AuthorID, note.Text) + "\n"
+		if text.Len()+len(line) > whoisMaxFieldLength-len("…") {
+			text.WriteString("…")
+			break
+		}
+		text.WriteString(line)
+	}
+	return text.String()
+}
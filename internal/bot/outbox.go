@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// pendingEvent is an announcement or alert in the outbox, deleted from it once sent
+type pendingEvent struct {
+	id    int64
+	alert bool
+	event notify.Event
+}
+
+func pendingEvents(pending []pendingEvent) []notify.Event {
+	events := make([]notify.Event, len(pending))
+	for i, p := range pending {
+		events[i] = p.event
+	}
+	return events
+}
+
+// transactionLocked starts writing the store changes of a member event and its announcements to the outbox in one transaction.
+// The returned function commits it, then sends the announcements. Nested calls join the running transaction.
+// A crash before the commit loses neither the change nor its announcements, the next sync finds the change again,
+// and announcements not sent before a crash are sent by the first sync after the restart.
+func (g *Guild) transactionLocked() func() {
+	if g.tx != nil {
+		return func() {}
+	}
+	tx, err := g.store.Begin()
+	if err != nil {
+		log.Fatalf("failed to begin a transaction in guild '%v': %v", g.ID, err)
+	}
+	outer := g.store
+	g.tx, g.store = tx, tx
+	return func() {
+		g.tx, g.store = nil, outer
+		if err := tx.Commit(); err != nil {
+			log.Fatalf("failed to commit a transaction in guild '%v': %v", g.ID, err)
+		}
+		queued := g.queued
+		g.queued = nil
+		for _, pending := range queued {
+			if err := g.dispatchLocked(pending); err != nil {
+				sendFailed(err, "failed to send message about '%v' %v", pending.event.UserID, pending.event.Type)
+			}
+		}
+	}
+}
+
+// sendFailed handles an announcement or alert that couldn't be sent. When Discord doesn't let the bot post, it stays
+// in the outbox until the next restart and the bot keeps running, otherwise the bot exits and the restart sends it.
+func sendFailed(err error, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if notify.IsPermissionError(err) {
+		log.Printf("%v, check the bot's permissions: %v", message, err)
+		return
+	}
+	log.Fatalf("%v: %v", message, err)
+}
+
+// queueLocked adds an announcement or alert to the outbox, sending it once the running transaction is committed,
+// or right away outside of transactions
+func (g *Guild) queueLocked(event notify.Event, alert bool) error {
+	if event.Skipped {
+		log.Printf("not sending '%v' %v, the hook skipped it", event.UserID, event.Type)
+		return nil
+	}
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	id, err := g.store.QueueAnnouncement(g.ID, store.Announcement{
+		DiscordID: event.UserID,
+		Alert:     alert,
+		Event:     string(encoded),
+		At:        event.At,
+	})
+	if err != nil {
+		return err
+	}
+	pending := pendingEvent{id: id, alert: alert, event: event}
+	if g.tx != nil {
+		g.queued = append(g.queued, pending)
+		return nil
+	}
+	return g.dispatchLocked(pending)
+}
+
+// sentLocked deletes sent announcements from the outbox
+func (g *Guild) sentLocked(pending ...pendingEvent) {
+	ids := make([]int64, len(pending))
+	for i, p := range pending {
+		ids[i] = p.id
+	}
+	if err := g.store.DeleteAnnouncements(ids); err != nil {
+		log.Printf("failed to delete %v sent announcements of guild '%v' from the outbox, they will be sent again after a restart: %v", len(ids), g.ID, err)
+	}
+}
+
+// loadOutboxLocked reads the announcements an earlier run didn't send
+func (g *Guild) loadOutboxLocked() error {
+	announcements, err := g.store.QueuedAnnouncements(g.ID)
+	if err != nil {
+		return err
+	}
+	g.recovered = nil
+	for _, announcement := range announcements {
+		var event notify.Event
+		if err := json.Unmarshal([]byte(announcement.Event), &event); err != nil {
+			return fmt.Errorf("failed to decode queued announcement %v: %w", announcement.ID, err)
+		}
+		g.recovered = append(g.recovered, pendingEvent{id: announcement.ID, alert: announcement.Alert, event: event})
+	}
+	return nil
+}
+
+// sendRecoveredLocked sends the announcements an earlier run didn't, with those of the running sync
+func (g *Guild) sendRecoveredLocked() {
+	if len(g.recovered) == 0 {
+		return
+	}
+	log.Printf("sending %v announcements of guild '%v' an earlier run didn't send", len(g.recovered), g.ID)
+	recovered := g.recovered
+	g.recovered = nil
+	for _, pending := range recovered {
+		if err := g.dispatchLocked(pending); err != nil {
+			sendFailed(err, "failed to send message about '%v' %v", pending.event.UserID, pending.event.Type)
+		}
+	}
+}
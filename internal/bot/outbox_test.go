@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestOutboxRecovery(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	assertQueued := func(expected int) {
+		t.Helper()
+		queued, err := st.QueuedAnnouncements(testGuildID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(queued) != expected {
+			t.Errorf("expected %v queued announcements, got %v", expected, len(queued))
+		}
+	}
+
+	g.memberRemoved("2")
+	assertSent(t, session, "<@2> (bob) left the server, now 1 member")
+	assertQueued(0)
+
+	// a crash after committing the join, but before announcing it
+	event, err := json.Marshal(notify.Event{Type: store.EventJoin, GuildID: testGuildID, UserID: "2", User: store.User{Username: "bob", Discriminator: "0"}, MemberCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.QueueAnnouncement(testGuildID, store.Announcement{DiscordID: "2", Event: string(event), At: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AddMember(testGuildID, "2", store.Member{User: store.User{Username: "bob", Discriminator: "0"}}); err != nil {
+		t.Fatal(err)
+	}
+	g.close()
+
+	restarted := newTestGuild(t, st, session)
+	restarted.syncMembersFromServer(context.Background(), session)
+	assertSent(t, session, "<@2> (bob) joined the server, now 2 members")
+	assertQueued(0)
+}
+
+// forbiddenSender is refused by Discord, like a channel the bot lost its permissions to
+type forbiddenSender struct{}
+
+func (forbiddenSender) ChannelMessageSend(channelID string, content string) (*discordgo.Message, error) {
+	return nil, &discordgo.RESTError{Response: &http.Response{StatusCode: http.StatusForbidden}}
+}
+
+func TestOutboxKeepsRefusedAnnouncements(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	g := newTestGuild(t, st, session)
+	templates, err := notify.ParseTemplates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Configure(GuildOptions{Notifier: notify.NewChannel(forbiddenSender{}, testChannelID, templates), Announce: defaultAnnounce})
+	g.syncMembersFromServer(context.Background(), session)
+
+	// the bot keeps running, and the announcements wait for the next restart
+	g.memberAdded("2", store.Member{User: store.User{Username: "bob", Discriminator: "0"}})
+	g.memberRemoved("1")
+	queued, err := st.QueuedAnnouncements(testGuildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 2 {
+		t.Errorf("expected the refused join and leave to stay queued, got %v", len(queued))
+	}
+	if _, ok := g.state["2"]; !ok {
+		t.Error("expected the join to be recorded")
+	}
+}
@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"bytes"
+	"log"
+	"text/template"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// DefaultPresence is the default presence template
+const DefaultPresence = "👥 {{number .MemberCount}} members"
+
+// PresenceData is passed to the presence template
+type PresenceData struct {
+	// MemberCount is the number of known members of all tracked guilds
+	MemberCount int
+	// Guilds is the number of tracked guilds
+	Guilds int
+	// JoinsToday and LeavesToday count the joins and leaves of all tracked guilds since midnight in Options.Location
+	JoinsToday  int
+	LeavesToday int
+}
+
+// ParsePresence parses a text/template presence template, with the same number and duration functions as announcements
+func ParsePresence(source string) (*template.Template, error) {
+	return template.New("presence").Funcs(template.FuncMap{
+		"number":   notify.FormatNumber,
+		"duration": notify.FormatDuration,
+	}).Parse(source)
+}
+
+// presenceSession is the subset of *discordgo.Session used to update the presence
+type presenceSession interface {
+	UpdateStatusComplex(usd discordgo.UpdateStatusData) error
+}
+
+// presenceText renders the presence template with the current stats
+func (b *Bot) presenceText(now time.Time) (string, error) {
+	data := PresenceData{Guilds: len(b.guilds)}
+	location := b.options.Location
+	if location == nil {
+		location = time.UTC
+	}
+	y, m, d := now.In(location).Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, location)
+	for _, g := range b.guilds {
+		data.MemberCount += g.MemberCount()
+		joins, err := b.store.CountEvents(g.ID, store.EventJoin, midnight)
+		if err != nil {
+			return "", err
+		}
+		leaves, err := b.store.CountEvents(g.ID, store.EventLeave, midnight)
+		if err != nil {
+			return "", err
+		}
+		data.JoinsToday += joins
+		data.LeavesToday += leaves
+	}
+
+	var text bytes.Buffer
+	err := b.options.Presence.Execute(&text, data)
+	return text.String(), err
+}
+
+// updatePresence shows the rendered presence template as the bot's custom status
+func (b *Bot) updatePresence(s presenceSession) {
+	text, err := b.presenceText(time.Now())
+	if err != nil {
+		log.Printf("failed to render presence: %v", err)
+		return
+	}
+	err = s.UpdateStatusComplex(discordgo.UpdateStatusData{
+		Activities: []*discordgo.Activity{{
+			Name:  "Custom Status",
+			Type:  discordgo.ActivityTypeCustom,
+			State: text,
+		}},
+		Status: string(discordgo.StatusOnline),
+	})
+	if err != nil {
+		log.Printf("failed to update presence: %v", err)
+	}
+}
+
+// refreshPresence updates the presence of every shard every PresenceInterval, it is started once after the first Ready
+func (b *Bot) refreshPresence(s presenceSession) {
+	ticker := time.NewTicker(b.options.PresenceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.updatePresence(s)
+		case <-b.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+type fakePresenceSession struct {
+	statuses []discordgo.UpdateStatusData
+}
+
+func (s *fakePresenceSession) UpdateStatusComplex(usd discordgo.UpdateStatusData) error {
+	s.statuses = append(s.statuses, usd)
+	return nil
+}
+
+func TestPresence(t *testing.T) {
+	presence, err := ParsePresence("{{number .MemberCount}} in {{.Guilds}}, +{{.JoinsToday}} -{{.LeavesToday}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	g := newTestGuildWithOptions(t, st, session, Options{Presence: presence})
+	g.syncMembersFromServer(context.Background(), session)
+	g.memberAdded("3", store.Member{User: store.User{Username: "carol", Discriminator: "0"}})
+	g.memberRemoved("1")
+
+	presenceSession := &fakePresenceSession{}
+	g.bot.updatePresence(presenceSession)
+	if len(presenceSession.statuses) != 1 {
+		t.Fatalf("expected one status update, got %v", len(presenceSession.statuses))
+	}
+	activity := presenceSession.statuses[0].Activities[0]
+	if activity.Type != discordgo.ActivityTypeCustom || activity.State != "2 in 1, +1 -1" {
+		t.Errorf("unexpected activity %+v", activity)
+	}
+}
+
+func TestDefaultPresence(t *testing.T) {
+	presence, err := ParsePresence(DefaultPresence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := openTestStore(t)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		if err := st.AddMember(testGuildID, id, store.Member{User: store.User{Username: id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := newTestGuildWithOptions(t, st, newFakeSession(), Options{Presence: presence})
+
+	text, err := g.bot.presenceText(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "👥 4 members" {
+		t.Errorf("rendered %q", text)
+	}
+}
@@ -0,0 +1,192 @@
+package bot
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/telemetry"
+)
+
+// Defaults of Options.EventWorkers and Options.EventQueueSize
+const (
+	DefaultEventWorkers   = 4
+	DefaultEventQueueSize = 10000
+)
+
+// queuedEvents is how many events wait in the queues of every bot, there is only one outside of tests
+var queuedEvents int64
+
+var (
+	eventQueueDepth = telemetry.NewGauge("user_log.events.queued", "{event}", "Discord events waiting to be handled", func() int64 {
+		return atomic.LoadInt64(&queuedEvents)
+	})
+	eventsDropped = telemetry.NewCounter("user_log.events.dropped", "{event}", "Discord events dropped because the queue was full")
+)
+
+// eventQueue hands Discord events to a fixed number of workers. Discord's handlers only queue them and return,
+// so a burst of events waits in a bounded queue instead of starting a goroutine per event that waits for the guild lock.
+// Events with the same key, the user they are about, are handled by the same worker in the order they were queued.
+type eventQueue struct {
+	workers []chan func()
+	done    sync.WaitGroup
+
+	// lock keeps events from being queued after close
+	lock   sync.RWMutex
+	closed bool
+}
+
+// newEventQueue starts workers sharing a queue of size events
+func newEventQueue(workers, size int) *eventQueue {
+	if workers < 1 {
+		workers = DefaultEventWorkers
+	}
+	if size < workers {
+		size = DefaultEventQueueSize
+	}
+	q := &eventQueue{workers: make([]chan func(), workers)}
+	for i := range q.workers {
+		events := make(chan func(), size/workers)
+		q.workers[i] = events
+		q.done.Add(1)
+		go func() {
+			defer q.done.Done()
+			for handle := range events {
+				atomic.AddInt64(&queuedEvents, -1)
+				handle()
+			}
+		}()
+	}
+	return q
+}
+
+// push queues an event, returning false if its worker's queue is full or the queue was closed
+func (q *eventQueue) push(key string, handle func()) bool {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	if q.closed {
+		return false
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	select {
+	case q.workers[hash.Sum32()%uint32(len(q.workers))] <- handle:
+		atomic.AddInt64(&queuedEvents, 1)
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops queueing events and waits for the queued ones to be handled, or for ctx to be done
+func (q *eventQueue) close(ctx context.Context) error {
+	q.lock.Lock()
+	if !q.closed {
+		q.closed = true
+		for _, events := range q.workers {
+			close(events)
+		}
+	}
+	q.lock.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		q.done.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue hands an event about a user to the workers. Events that don't fit are dropped,
+// and a sync shortly after the burst catches up on the member changes among them.
+func (b *Bot) enqueue(userID string, handle func()) {
+	if b.queue.push(userID, handle) || b.ctx.Err() != nil {
+		return
+	}
+	eventsDropped.Add(1)
+
+	b.resyncLock.Lock()
+	defer b.resyncLock.Unlock()
+	b.dropped++
+	if b.dropped == 1 {
+		log.Printf("the event queue is full, dropping events until it drains and syncing %v later", resyncDelay)
+	}
+	if b.resyncTimer != nil {
+		b.resyncTimer.Stop()
+	}
+	b.resyncTimer = time.AfterFunc(resyncDelay, func() {
+		b.resyncLock.Lock()
+		dropped := b.dropped
+		b.dropped = 0
+		b.resyncLock.Unlock()
+		log.Printf("Performing sync after dropping %v events", dropped)
+		b.SyncAll(b.ctx, b.shards)
+	})
+}
+
+// addQueuedHandlers registers the handlers of member events on a shard, queueing the events for the workers.
+// Member events keep when they were received, a sync that started while they waited makes them outdated.
+func (b *Bot) addQueuedHandlers(s *discordgo.Session) {
+	s.AddHandler(func(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+		received := time.Now()
+		b.enqueue(memberUserID(m.Member), func() { b.guildMemberAdd(s, m, received) })
+	})
+	s.AddHandler(func(s *discordgo.Session, m *discordgo.GuildMemberUpdate) {
+		received := time.Now()
+		b.enqueue(memberUserID(m.Member), func() { b.guildMemberUpdate(s, m, received) })
+	})
+	s.AddHandler(func(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+		received := time.Now()
+		b.enqueue(memberUserID(m.Member), func() { b.guildMemberRemove(s, m, received) })
+	})
+	s.AddHandler(func(s *discordgo.Session, e *discordgo.GuildBanAdd) {
+		b.enqueue(userID(e.User), func() { b.guildBanAdd(s, e) })
+	})
+	s.AddHandler(func(s *discordgo.Session, e *discordgo.GuildBanRemove) {
+		b.enqueue(userID(e.User), func() { b.guildBanRemove(s, e) })
+	})
+	s.AddHandler(func(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+		if v.VoiceState != nil {
+			b.enqueue(v.UserID, func() { b.voiceStateUpdate(s, v) })
+		}
+	})
+	s.AddHandler(func(s *discordgo.Session, p *discordgo.PresenceUpdate) {
+		b.enqueue(userID(p.User), func() { b.presenceUpdate(s, p) })
+	})
+	s.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
+		if m.Message != nil {
+			b.enqueue(userID(m.Author), func() { b.messageCreate(s, m) })
+		}
+	})
+	s.AddHandler(func(s *discordgo.Session, e *discordgo.GuildScheduledEventUserAdd) {
+		b.enqueue(e.UserID, func() { b.guildScheduledEventUserAdd(s, e) })
+	})
+	s.AddHandler(func(s *discordgo.Session, e *discordgo.GuildScheduledEventUserRemove) {
+		b.enqueue(e.UserID, func() { b.guildScheduledEventUserRemove(s, e) })
+	})
+}
+
+// memberUserID is the ID of a member's user, empty without one
+func memberUserID(m *discordgo.Member) string {
+	if m == nil {
+		return ""
+	}
+	return userID(m.User)
+}
+
+// userID is the ID of a user, empty for nil
+func userID(u *discordgo.User) string {
+	if u == nil {
+		return ""
+	}
+	return u.ID
+}
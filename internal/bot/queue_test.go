@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestEventQueue(t *testing.T) {
+	// two workers with room for two waiting events each
+	q := newEventQueue(2, 4)
+
+	var handled []int
+	started := make(chan struct{})
+	block := make(chan struct{})
+	q.push("1", func() {
+		close(started)
+		<-block
+		handled = append(handled, 0)
+	})
+	<-started
+	for i := 1; i <= 2; i++ {
+		i := i
+		if !q.push("1", func() { handled = append(handled, i) }) {
+			t.Fatalf("event %v of a worker with room wasn't queued", i)
+		}
+	}
+	if q.push("1", func() { handled = append(handled, 3) }) {
+		t.Error("expected a full worker to drop events")
+	}
+
+	close(block)
+	if err := q.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(handled) != 3 || handled[0] != 0 || handled[1] != 1 || handled[2] != 2 {
+		t.Errorf("expected the events of a user handled in order, got %v", handled)
+	}
+	if q.push("1", func() {}) {
+		t.Error("expected a closed queue to drop events")
+	}
+}
+
+func TestQueuedEventsOutdatedBySync(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	// bob leaves and rejoins while the events of his worker wait
+	started := make(chan struct{})
+	block := make(chan struct{})
+	g.bot.enqueue("2", func() {
+		close(started)
+		<-block
+	})
+	<-started
+	leave := &discordgo.GuildMemberRemove{Member: &discordgo.Member{GuildID: testGuildID, User: &discordgo.User{ID: "2"}}}
+	received := time.Now()
+	g.bot.enqueue("2", func() { g.bot.guildMemberRemove(nil, leave, received) })
+
+	// a sync sees bob as a member, so the queued leave is outdated once it is handled
+	g.syncMembersFromServer(context.Background(), session)
+	close(block)
+	if err := g.bot.queue.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.state["2"]; !ok {
+		t.Error("expected the leave received before the sync to be ignored")
+	}
+	assertSent(t, session)
+}
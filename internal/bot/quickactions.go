@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// Quick actions are offered as buttons under leave and ban announcements, see GuildOptions.QuickActions
+const (
+	// QuickActionBan bans a member who just left, only offered on leaves
+	QuickActionBan = "ban"
+	// QuickActionNote asks for a note about the user and stores it with their notes
+	QuickActionNote = "note"
+)
+
+// Banner is the subset of *discordgo.Session used to ban users
+type Banner interface {
+	GuildBanCreateWithReason(guildID, userID, reason string, days int) error
+}
+
+// quickActionsLocked returns the buttons offered under an announcement, none for events other than leaves and bans
+func (g *Guild) quickActionsLocked(event notify.Event) []notify.Action {
+	if event.Type != store.EventLeave && event.Type != store.EventBan {
+		return nil
+	}
+	var actions []notify.Action
+	for _, action := range g.quickActions {
+		switch {
+		case action == QuickActionBan && event.Type == store.EventLeave:
+			actions = append(actions, notify.Action{
+				Label:    g.lang.Translate("Ban"),
+				Emoji:    "🔨",
+				CustomID: fmt.Sprintf("%v:quickban:%v", userlogCommand.Name, event.UserID),
+			})
+		case action == QuickActionNote:
+			actions = append(actions, notify.Action{
+				Label:    g.lang.Translate("Note"),
+				Emoji:    "📝",
+				CustomID: fmt.Sprintf("%v:quicknote:%v", userlogCommand.Name, event.UserID),
+			})
+		}
+	}
+	return actions
+}
+
+// componentQuickBan bans the user of a leave announcement, answering only the moderator who pressed the button.
+// The ban is announced like any other, so the other moderators see it.
+func (b *Bot) componentQuickBan(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, args []string) *discordgo.InteractionResponseData {
+	return b.quickBan(s, i, g, args)
+}
+
+// quickBan bans like componentQuickBan with any Banner
+func (b *Bot) quickBan(s Banner, i *discordgo.InteractionCreate, g *Guild, args []string) *discordgo.InteractionResponseData {
+	lang := g.language()
+	if len(args) != 1 {
+		return ephemeral(textResponse(lang.Translate("Unknown command.")))
+	}
+	discordID, moderator := args[0], i.Member.User
+	reason := fmt.Sprintf("Banned from the member log by %v (%v)", moderator.Username, moderator.ID)
+	if err := s.GuildBanCreateWithReason(g.ID, discordID, reason, 0); err != nil {
+		log.Printf("failed to ban '%v' from guild '%v' for '%v': %v", discordID, g.ID, moderator.ID, err)
+		return ephemeral(textResponse(lang.Sprintf("Failed to ban <@%v>, check the logs.", discordID)))
+	}
+	log.Printf("[quick action] '%v' banned '%v' from guild '%v'", moderator.ID, discordID, g.ID)
+	return ephemeral(textResponse(lang.Sprintf("Banned <@%v>.", discordID)))
+}
+
+// componentQuickNote asks for a note about the user of an announcement, and stores it once the modal is submitted
+func (b *Bot) componentQuickNote(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, args []string) *discordgo.InteractionResponseData {
+	lang := g.language()
+	if len(args) != 1 {
+		return ephemeral(textResponse(lang.Translate("Unknown command.")))
+	}
+	discordID := args[0]
+	if i.Type != discordgo.InteractionModalSubmit {
+		return &discordgo.InteractionResponseData{
+			CustomID: fmt.Sprintf("%v:quicknote:%v", userlogCommand.Name, discordID),
+			Title:    lang.Translate("Note"),
+			Components: []discordgo.MessageComponent{discordgo.ActionsRow{Components: []discordgo.MessageComponent{discordgo.TextInput{
+				CustomID:  "note",
+				Label:     lang.Translate("Note, only moderators see it"),
+				Style:     discordgo.TextInputParagraph,
+				Required:  true,
+				MaxLength: maxNoteLength,
+			}}}},
+		}
+	}
+
+	text := modalInputs(i.ModalSubmitData())["note"]
+	return ephemeral(b.addNote(g, discordID, i.Member.User.ID, text))
+}
@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// fakeBanner records bans, failing for users in fail
+type fakeBanner struct {
+	banned []string
+	fail   map[string]bool
+}
+
+func (f *fakeBanner) GuildBanCreateWithReason(guildID, userID, reason string, days int) error {
+	if f.fail[userID] {
+		return errors.New("missing permissions")
+	}
+	f.banned = append(f.banned, userID)
+	return nil
+}
+
+func TestQuickActions(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{
+		Announce:     []string{store.EventJoin, store.EventLeave, store.EventBan},
+		QuickActions: []string{QuickActionBan, QuickActionNote},
+	})
+	g.syncMembersFromServer(context.Background(), session)
+	session.takeSent()
+
+	// leaves seen live get both buttons
+	g.memberRemoved("1")
+	sent := session.takeSent()
+	if len(sent) != 1 || !reflect.DeepEqual(sent[0].customIDs, []string{"userlog:quickban:1", "userlog:quicknote:1"}) {
+		t.Fatalf("expected ban and note buttons under the leave, sent %+v", sent)
+	}
+	// bans can only be noted
+	g.banChanged("1", store.User{Username: "alice", Discriminator: "0"}, store.EventBan, banDetails{}, time.Now())
+	sent = session.takeSent()
+	if len(sent) != 1 || !reflect.DeepEqual(sent[0].customIDs, []string{"userlog:quicknote:1"}) {
+		t.Fatalf("expected a note button under the ban, sent %+v", sent)
+	}
+	// leaves found by a sync are batched without buttons
+	session.setMembers(testGuildID)
+	g.syncMembersFromServer(context.Background(), session)
+	if sent := session.takeSent(); len(sent) != 1 || sent[0].customIDs != nil {
+		t.Fatalf("expected the synced leave without buttons, sent %+v", sent)
+	}
+
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{Member: &discordgo.Member{User: &discordgo.User{ID: "mod", Username: "mod"}}}}
+	banner := &fakeBanner{fail: map[string]bool{"2": true}}
+	if response := g.bot.quickBan(banner, i, g, []string{"1"}); response.Content != "Banned <@1>." || response.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Errorf("unexpected ban response %+v", response)
+	}
+	if response := g.bot.quickBan(banner, i, g, []string{"2"}); response.Content != "Failed to ban <@2>, check the logs." {
+		t.Errorf("unexpected failed ban response %+v", response)
+	}
+	if !reflect.DeepEqual(banner.banned, []string{"1"}) {
+		t.Errorf("expected only 1 banned, got %v", banner.banned)
+	}
+
+	// pressing note opens a modal, submitting it stores the note
+	if response := g.bot.componentQuickNote(nil, i, g, []string{"1"}); response.CustomID != "userlog:quicknote:1" {
+		t.Fatalf("expected a modal, got %+v", response)
+	}
+	i.Type = discordgo.InteractionModalSubmit
+	i.Data = discordgo.ModalSubmitInteractionData{
+		CustomID: "userlog:quicknote:1",
+		Components: []discordgo.MessageComponent{&discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			&discordgo.TextInput{CustomID: "note", Value: " raided with alts "},
+		}}},
+	}
+	if response := g.bot.componentQuickNote(nil, i, g, []string{"1"}); response.Content != "Noted about <@1>." || response.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Errorf("unexpected note response %+v", response)
+	}
+	notes, err := st.Notes(testGuildID, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 || notes[0].Text != "raided with alts" || notes[0].AuthorID != "mod" {
+		t.Errorf("unexpected notes %+v", notes)
+	}
+}
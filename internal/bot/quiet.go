@@ -0,0 +1,186 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// QuietHours is a daily period during which announcements are deferred.
+// Start and End are offsets from midnight, the period wraps past midnight if End is before Start.
+type QuietHours struct {
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// active reports whether t is within the quiet hours
+func (q *QuietHours) active(t time.Time) bool {
+	if q == nil || q.Start == q.End {
+		return false
+	}
+	offset := sinceMidnight(t.In(q.Location))
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+// end returns the first end of the quiet hours after t
+func (q *QuietHours) end(t time.Time) time.Time {
+	local := t.In(q.Location)
+	for day := 0; ; day++ {
+		date := local.AddDate(0, 0, day)
+		// rebuilding the time from the wall clock keeps it correct across DST changes
+		end := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, int(q.End/time.Second), 0, q.Location)
+		if end.After(t) {
+			return end
+		}
+	}
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	hour, min, sec := t.Clock()
+	return time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+}
+
+// deliverLocked queues an announcement, it is sent, or deferred until the quiet hours end, once its transaction is committed
+func (g *Guild) deliverLocked(event notify.Event) error {
+	return g.queueLocked(event, false)
+}
+
+// dispatchLocked sends a queued announcement or alert, deferring announcements until the quiet hours end
+func (g *Guild) dispatchLocked(pending pendingEvent) error {
+	if pending.alert || voiceEvents[pending.event.Type] {
+		// alerts skip quiet hours, they're urgent, and so does the voice log, it's only useful right away
+		notifier := g.alerts
+		if !pending.alert {
+			notifier = g.voice
+		}
+		// voice logging may have been disabled since
+		if notifier != nil {
+			if err := notifier.Notify(pending.event); err != nil {
+				return err
+			}
+		}
+		g.sentLocked(pending)
+		return nil
+	}
+	event := pending.event
+	if event.Type == store.EventLeave && g.editJoinLocked(event) {
+		g.sentLocked(pending)
+		return nil
+	}
+	now := time.Now()
+	if !g.quietHours.active(now) {
+		if g.syncBatch != nil {
+			g.syncBatch = append(g.syncBatch, pending)
+			return nil
+		}
+		if err := g.notifyLocked(event); err != nil {
+			return err
+		}
+		g.sentLocked(pending)
+		return nil
+	}
+	g.deferred = append(g.deferred, pending)
+	if g.flushTimer == nil {
+		log.Printf("quiet hours in guild '%v', deferring announcements until %v", g.ID, g.quietHours.end(now))
+		g.scheduleFlushLocked(now)
+	}
+	return nil
+}
+
+func (g *Guild) flushDeferred() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed {
+		return
+	}
+	g.scheduleFlushLocked(time.Now())
+}
+
+// flushSyncBatchLocked sends the announcements collected during a sync in as few messages as possible,
+// or a summary of them if there are too many
+func (g *Guild) flushSyncBatchLocked() {
+	batch := g.syncBatch
+	g.syncBatch = nil
+	if len(batch) == 0 {
+		return
+	}
+	events := pendingEvents(batch)
+	if g.syncSummary > 0 && len(events) > g.syncSummary {
+		summary := notify.Event{
+			Type:        notify.EventSyncSummary,
+			GuildID:     g.ID,
+			At:          time.Now(),
+			MemberCount: len(g.state),
+			Count:       len(events),
+		}
+		for _, event := range events {
+			switch event.Type {
+			case store.EventJoin:
+				summary.Joins++
+			case store.EventLeave:
+				summary.Leaves++
+			}
+		}
+		if err := g.notifier.Notify(summary); err != nil {
+			sendFailed(err, "failed to send the summary of %v events found by a sync", len(events))
+			return
+		}
+		g.sentLocked(batch...)
+		log.Printf("messaged a summary of %v events found by a sync", len(events))
+		return
+	}
+	if err := g.notifier.NotifyBatch(events); err != nil {
+		sendFailed(err, "failed to send %v announcements of events found by a sync", len(events))
+		return
+	}
+	g.sentLocked(batch...)
+	log.Printf("messaged about %v events found by a sync", len(events))
+}
+
+// close ignores everything afterwards. Announcements deferred by quiet hours stay in the outbox,
+// the next run sends them once the quiet hours end instead of pinging the channel on every restart.
+func (g *Guild) close() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.closed = true
+	if g.flushTimer != nil {
+		g.flushTimer.Stop()
+		g.flushTimer = nil
+	}
+	if len(g.deferred) == 0 {
+		return
+	}
+	log.Printf("leaving %v announcements of guild '%v' deferred during quiet hours in the outbox for the next run", len(g.deferred), g.ID)
+	g.deferred = nil
+}
+
+// scheduleFlushLocked flushes the deferred events when the quiet hours end, or right away if they already did
+func (g *Guild) scheduleFlushLocked(now time.Time) {
+	if g.flushTimer != nil {
+		g.flushTimer.Stop()
+		g.flushTimer = nil
+	}
+	if len(g.deferred) == 0 {
+		return
+	}
+	if g.quietHours.active(now) {
+		g.flushTimer = time.AfterFunc(g.quietHours.end(now).Sub(now), g.flushDeferred)
+		return
+	}
+
+	deferred := g.deferred
+	g.deferred = nil
+	if err := g.notifier.NotifyBatch(pendingEvents(deferred)); err != nil {
+		sendFailed(err, "failed to send %v deferred announcements", len(deferred))
+		return
+	}
+	g.sentLocked(deferred...)
+	log.Printf("messaged about %v events deferred during quiet hours", len(deferred))
+}
@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestQuietHours(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	overnight := &QuietHours{Start: 23 * time.Hour, End: 7*time.Hour + 30*time.Minute, Location: berlin}
+	afternoon := &QuietHours{Start: 13 * time.Hour, End: 15 * time.Hour, Location: time.UTC}
+
+	for _, test := range []struct {
+		quiet  *QuietHours
+		at     time.Time
+		active bool
+		end    time.Time
+	}{
+		{overnight, time.Date(2023, 3, 1, 23, 30, 0, 0, berlin), true, time.Date(2023, 3, 2, 7, 30, 0, 0, berlin)},
+		{overnight, time.Date(2023, 3, 2, 3, 0, 0, 0, berlin), true, time.Date(2023, 3, 2, 7, 30, 0, 0, berlin)},
+		{overnight, time.Date(2023, 3, 2, 7, 30, 0, 0, berlin), false, time.Date(2023, 3, 3, 7, 30, 0, 0, berlin)},
+		// the night clocks go forward
+		{overnight, time.Date(2023, 3, 25, 23, 0, 0, 0, berlin), true, time.Date(2023, 3, 26, 7, 30, 0, 0, berlin)},
+		{afternoon, time.Date(2023, 3, 1, 12, 59, 0, 0, time.UTC), false, time.Date(2023, 3, 1, 15, 0, 0, 0, time.UTC)},
+		{afternoon, time.Date(2023, 3, 1, 14, 0, 0, 0, time.UTC), true, time.Date(2023, 3, 1, 15, 0, 0, 0, time.UTC)},
+		{afternoon, time.Date(2023, 3, 1, 15, 0, 0, 0, time.UTC), false, time.Date(2023, 3, 2, 15, 0, 0, 0, time.UTC)},
+	} {
+		if active := test.quiet.active(test.at); active != test.active {
+			t.Errorf("active(%v) = %v, expected %v", test.at, active, test.active)
+		}
+		if end := test.quiet.end(test.at); !end.Equal(test.end) {
+			t.Errorf("end(%v) = %v, expected %v", test.at, end, test.end)
+		}
+	}
+
+	var disabled *QuietHours
+	if disabled.active(time.Now()) {
+		t.Error("expected nil quiet hours to never be active")
+	}
+}
+
+func TestQuietHoursDeferAnnouncements(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	now := sinceMidnight(time.Now().UTC())
+	quiet := &QuietHours{
+		Start:    (now + 23*time.Hour) % (24 * time.Hour),
+		End:      (now + time.Hour) % (24 * time.Hour),
+		Location: time.UTC,
+	}
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{QuietHours: quiet})
+	g.syncMembersFromServer(context.Background(), session)
+
+	g.memberAdded("1", store.Member{User: store.User{Username: "alice", Discriminator: "0"}})
+	g.memberRemoved("1")
+	assertSent(t, session)
+
+	// turning quiet hours off flushes the deferred announcements as one message
+	g.Configure(GuildOptions{Notifier: g.notifier, Announce: defaultAnnounce})
+	assertSent(t, session, "<@1> (alice) joined the server, now 1 member\n<@1> (alice) left the server")
+}
+
+func TestCloseKeepsDeferredAnnouncements(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	now := sinceMidnight(time.Now().UTC())
+	quiet := &QuietHours{
+		Start:    (now + 23*time.Hour) % (24 * time.Hour),
+		End:      (now + time.Hour) % (24 * time.Hour),
+		Location: time.UTC,
+	}
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{QuietHours: quiet})
+	g.syncMembersFromServer(context.Background(), session)
+
+	g.memberAdded("1", store.Member{User: store.User{Username: "alice", Discriminator: "0"}})
+	assertSent(t, session)
+
+	// deferred announcements aren't sent during quiet hours on exit, they stay in the outbox
+	if err := g.bot.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertSent(t, session)
+	if queued, err := st.QueuedAnnouncements(testGuildID); err != nil || len(queued) != 1 {
+		t.Errorf("expected the deferred announcement to stay queued, got %v %v", queued, err)
+	}
+
+	// and events arriving afterwards are ignored
+	g.memberAdded("2", store.Member{User: store.User{Username: "bob", Discriminator: "0"}})
+	assertSent(t, session)
+	if members, err := st.Members(testGuildID); err != nil || len(members) != 1 {
+		t.Errorf("expected nothing to be stored after closing, got %v %v", members, err)
+	}
+
+	// the next run defers it again while the quiet hours last, and sends it once they end
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	restarted := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{QuietHours: quiet})
+	restarted.syncMembersFromServer(context.Background(), session)
+	assertSent(t, session)
+	restarted.Configure(GuildOptions{Notifier: restarted.notifier, Announce: defaultAnnounce})
+	assertSent(t, session, "<@1> (alice) joined the server, now 1 member")
+}
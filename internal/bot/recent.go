@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+const (
+	recentDefaultCount = 10
+	recentMaxCount     = 25
+)
+
+var recentMinCount float64 = 1
+
+func (b *Bot) commandRecent(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	count := recentDefaultCount
+	for _, option := range options {
+		if option.Name == "count" {
+			count = int(option.IntValue())
+		}
+	}
+	return b.recentPage(g, 0, count)
+}
+
+// componentRecent handles the page buttons, with custom IDs like "userlog:recent:<page>:<count>"
+func (b *Bot) componentRecent(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, args []string) *discordgo.InteractionResponseData {
+	if len(args) != 2 {
+		return textResponse(g.language().Translate("Unknown page."))
+	}
+	page, err := strconv.Atoi(args[0])
+	if err != nil || page < 0 {
+		return textResponse(g.language().Translate("Unknown page."))
+	}
+	count, err := strconv.Atoi(args[1])
+	if err != nil || count < 1 || count > recentMaxCount {
+		return textResponse(g.language().Translate("Unknown page."))
+	}
+	return b.recentPage(g, page, count)
+}
+
+func (b *Bot) recentPage(g *Guild, page, count int) *discordgo.InteractionResponseData {
+	lang := g.language()
+	// fetch one extra to know if there's a next page
+	events, err := b.store.RecentEvents(g.ID, []string{store.EventJoin, store.EventLeave}, count+1, page*count)
+	if err != nil {
+		log.Printf("failed to load recent events: %v", err)
+		return textResponse(lang.Translate("Failed to load recent events, check the logs."))
+	}
+	hasNext := len(events) > count
+	if hasNext {
+		events = events[:count]
+	}
+
+	var description strings.Builder
+	for _, event := range events {
+		verb := lang.Translate("joined")
+		if event.Event == store.EventLeave {
+			verb = lang.Translate("left")
+		}
+		if store.IsPseudonym(event.DiscordID) {
+			fmt.Fprintf(&description, "<t:%v:f> (<t:%v:R>) %v", event.At.Unix(), event.At.Unix(), lang.Translate("An anonymized member"))
+		} else {
+			fmt.Fprintf(&description, "<t:%v:f> (<t:%v:R>) <@%v>", event.At.Unix(), event.At.Unix(), event.DiscordID)
+		}
+		if tag := event.User.Tag(); tag != "" {
+			fmt.Fprintf(&description, " (%v)", tag)
+		}
+		fmt.Fprintf(&description, " %v\n", verb)
+	}
+	if len(events) == 0 {
+		description.WriteString(lang.Translate("Nothing here."))
+	}
+
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       lang.Translate("Recent Joins and Leaves"),
+			Description: description.String(),
+			Footer:      &discordgo.MessageEmbedFooter{Text: lang.Sprintf("Page %v", page+1)},
+		}},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    lang.Translate("Newer"),
+						Style:    discordgo.SecondaryButton,
+						CustomID: fmt.Sprintf("%v:recent:%v:%v", userlogCommand.Name, page-1, count),
+						Disabled: page == 0,
+					},
+					discordgo.Button{
+						Label:    lang.Translate("Older"),
+						Style:    discordgo.SecondaryButton,
+						CustomID: fmt.Sprintf("%v:recent:%v:%v", userlogCommand.Name, page+1, count),
+						Disabled: !hasNext,
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/report"
+)
+
+// commandReport attaches the HTML report of the last month, the report itself is in English like the emailed ones
+func (b *Bot) commandReport(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	name := g.ID
+	if guild, err := s.State.Guild(g.ID); err == nil {
+		name = guild.Name
+	}
+	return b.reportResponse(g, name, time.Now())
+}
+
+func (b *Bot) reportResponse(g *Guild, name string, now time.Time) *discordgo.InteractionResponseData {
+	lang := g.language()
+	location := b.options.Location
+	if location == nil {
+		location = time.UTC
+	}
+	until := now.In(location)
+	since := report.Previous(until, report.Monthly)
+	summary, err := report.Summarize(b.store, g.ID, name, since)
+	if err != nil {
+		log.Printf("failed to summarize guild '%v': %v", g.ID, err)
+		return textResponse(lang.Translate("Failed to load stats, check the logs."))
+	}
+	page, err := report.RenderHTML(report.Monthly, []report.Summary{summary}, since, until)
+	if err != nil {
+		log.Printf("failed to render the report of guild '%v': %v", g.ID, err)
+		return textResponse(lang.Translate("Failed to load stats, check the logs."))
+	}
+	response := textResponse(lang.Sprintf("Member report from %v to %v.", since.Format("2006-01-02"), until.Format("2006-01-02")))
+	response.Files = []*discordgo.File{{
+		Name:        fmt.Sprintf("user-log-report-%v.html", until.Format("2006-01-02")),
+		ContentType: "text/html",
+		Reader:      strings.NewReader(page),
+	}}
+	return response
+}
@@ -0,0 +1,35 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	now := time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)
+	response := g.bot.reportResponse(g, "<Test Server>", now)
+	if response.Content != "Member report from 2023-06-15 to 2023-07-15." || len(response.Files) != 1 {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	if name := response.Files[0].Name; name != "user-log-report-2023-07-15.html" {
+		t.Errorf("unexpected file name %q", name)
+	}
+	page, err := io.ReadAll(response.Files[0].Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"<h2>&lt;Test Server&gt;</h2>", "<svg class=\"chart\""} {
+		if !strings.Contains(string(page), expected) {
+			t.Errorf("expected %q in %q", expected, page)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/retention"
+)
+
+func (b *Bot) commandRetention(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	return b.retentionResponse(g, time.Now())
+}
+
+func (b *Bot) retentionResponse(g *Guild, now time.Time) *discordgo.InteractionResponseData {
+	lang := g.language()
+	if b.options.Location != nil {
+		now = now.In(b.options.Location)
+	}
+	stats, err := retention.Compute(b.store, g.ID, now)
+	if err != nil {
+		log.Printf("failed to compute retention of guild '%v': %v", g.ID, err)
+		return textResponse(lang.Translate("Failed to load retention, check the logs."))
+	}
+
+	rate := func(r retention.Rate) string {
+		if r.Joins == 0 {
+			return lang.Translate("Not enough joins yet")
+		}
+		return lang.Sprintf("%.1f%% (%v of %v)", r.Fraction()*100, r.Retained, r.Joins)
+	}
+	medianStay := lang.Translate("Nobody left")
+	if stats.Left > 0 {
+		medianStay = lang.Sprintf("%v (%v left)", lang.FormatDuration(stats.MedianStay), stats.Left)
+	}
+	var cohorts strings.Builder
+	for _, cohort := range stats.Cohorts {
+		cohorts.WriteString(lang.Sprintf("%v: %v of %v", cohort.Month.Format("2006-01"), cohort.Remaining, cohort.Joins) + "\n")
+	}
+	fields := []*discordgo.MessageEmbedField{
+		{Name: lang.Translate("Stayed 7 days"), Value: rate(stats.Day7), Inline: true},
+		{Name: lang.Translate("Stayed 30 days"), Value: rate(stats.Day30), Inline: true},
+		{Name: lang.Translate("Median stay of leavers"), Value: medianStay, Inline: true},
+		{Name: lang.Translate("Still here, by join month"), Value: cohorts.String()},
+	}
+	// answers to the leave survey, over the same months
+	if reasons, err := b.store.LeaveReasons(g.ID, stats.Cohorts[0].Month); err != nil {
+		log.Printf("failed to load the leave reasons of guild '%v': %v", g.ID, err)
+	} else if len(reasons) > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: lang.Translate("Reasons for leaving"), Value: leaveReasonCounts(reasons)})
+	}
+
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:  lang.Translate("Member Retention"),
+			Fields: fields,
+			Footer: &discordgo.MessageEmbedFooter{Text: lang.Sprintf("Joins of the last %v months", retention.Months)},
+		}},
+	}
+}
@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// roleAuditLogLimit is how many recent audit log entries are searched for the one of a role change
+const roleAuditLogLimit = 10
+
+// roleAuditWindow is how much older than the member update its audit log entry may be, older entries are of earlier changes
+const roleAuditWindow = time.Minute
+
+// RoleNamer looks up the names of roles, it is implemented by *discordgo.State
+type RoleNamer interface {
+	Role(guildID, roleID string) (*discordgo.Role, error)
+}
+
+// roleChange is who changed a member's roles, and the names of the roles, as far as Discord tells
+type roleChange struct {
+	// ModeratorID changed the roles, like a reaction role bot or a moderator, empty if the audit log couldn't tell
+	ModeratorID string
+	// Bot is set if the moderator is a bot
+	Bot bool
+	// names maps role IDs to their names, roles missing from it are shown by ID
+	names map[string]string
+}
+
+// roleDetails are stored with role_add and role_remove history events
+type roleDetails struct {
+	RoleID      string `json:"role_id"`
+	RoleName    string `json:"role_name,omitempty"`
+	ModeratorID string `json:"moderator_id,omitempty"`
+	Bot         bool   `json:"bot,omitempty"`
+}
+
+// memberUpdated handles a live member update received at a time, finding out who changed the member's roles if they changed
+func (b *Bot) memberUpdated(s AuditLogger, roles RoleNamer, g *Guild, received time.Time, discordID string, member store.Member) {
+	change := roleChange{}
+	if before, known := g.memberRoles(discordID); known {
+		added, removed := diffRoles(before, member.Roles)
+		if len(added)+len(removed) > 0 {
+			// looked up before locking the guild, it is a request to Discord
+			change = roleAuditEntry(s, g.ID, discordID, received)
+			change.names = roleNames(roles, g.ID, append(added, removed...))
+		}
+	}
+	g.memberUpdatedAt(received, discordID, member, change)
+}
+
+// memberRoles returns the known roles of a member
+func (g *Guild) memberRoles(discordID string) ([]string, bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	member, known := g.state[discordID]
+	return member.Roles, known
+}
+
+// roleAuditEntry finds who changed a member's roles in the audit log, it needs the View Audit Log permission.
+// Members giving themselves roles through onboarding or linked roles may not be in it.
+func roleAuditEntry(s AuditLogger, guildID, discordID string, changedAt time.Time) roleChange {
+	auditLog, err := s.GuildAuditLog(guildID, "", "", int(discordgo.AuditLogActionMemberRoleUpdate), roleAuditLogLimit)
+	if err != nil {
+		log.Printf("failed to read the audit log of guild '%v' for the role change of '%v': %v", guildID, discordID, err)
+		return roleChange{}
+	}
+	// entries are newest first
+	for _, entry := range auditLog.AuditLogEntries {
+		if entry.TargetID != discordID {
+			continue
+		}
+		if at, err := discordgo.SnowflakeTimestamp(entry.ID); err != nil || at.Before(changedAt.Add(-roleAuditWindow)) {
+			return roleChange{}
+		}
+		change := roleChange{ModeratorID: entry.UserID}
+		for _, user := range auditLog.Users {
+			if user.ID == entry.UserID {
+				change.Bot = user.Bot
+			}
+		}
+		return change
+	}
+	return roleChange{}
+}
+
+// roleNames looks up the names of roles, leaving out the ones that can't be found
+func roleNames(roles RoleNamer, guildID string, roleIDs []string) map[string]string {
+	names := make(map[string]string, len(roleIDs))
+	if roles == nil {
+		return names
+	}
+	for _, roleID := range roleIDs {
+		if role, err := roles.Role(guildID, roleID); err == nil {
+			names[roleID] = role.Name
+		}
+	}
+	return names
+}
+
+// diffRoles returns the roles in after but not before, and in before but not after
+func diffRoles(before, after []string) (added, removed []string) {
+	had := make(map[string]struct{}, len(before))
+	for _, roleID := range before {
+		had[roleID] = struct{}{}
+	}
+	for _, roleID := range after {
+		if _, ok := had[roleID]; ok {
+			delete(had, roleID)
+			continue
+		}
+		added = append(added, roleID)
+	}
+	for _, roleID := range before {
+		if _, ok := had[roleID]; ok {
+			removed = append(removed, roleID)
+		}
+	}
+	return added, removed
+}
+
+// roleChangesLocked records and announces every role a member was given or lost
+func (g *Guild) roleChangesLocked(discordID string, before, after store.Member, change roleChange) {
+	added, removed := diffRoles(before.Roles, after.Roles)
+	for _, roles := range []struct {
+		eventType string
+		roleIDs   []string
+	}{
+		{store.EventRoleAdd, added},
+		{store.EventRoleRemove, removed},
+	} {
+		for _, roleID := range roles.roleIDs {
+			log.Printf("recording %v of role '%v' for '%v'", roles.eventType, roleID, discordID)
+			g.eventLocked(notify.Event{
+				Type:        roles.eventType,
+				UserID:      discordID,
+				User:        after.User,
+				RoleID:      roleID,
+				RoleName:    change.names[roleID],
+				ModeratorID: change.ModeratorID,
+			}, roleDetails{RoleID: roleID, RoleName: change.names[roleID], ModeratorID: change.ModeratorID, Bot: change.Bot})
+		}
+	}
+}
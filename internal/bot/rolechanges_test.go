@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+type fakeRoleNamer map[string]string
+
+func (f fakeRoleNamer) Role(guildID, roleID string) (*discordgo.Role, error) {
+	if name, ok := f[roleID]; ok {
+		return &discordgo.Role{ID: roleID, Name: name}, nil
+	}
+	return nil, errors.New("unknown role")
+}
+
+// snowflakeAt returns a snowflake created at a time
+func snowflakeAt(at time.Time) string {
+	return strconv.FormatInt((at.UnixMilli()-1420070400000)<<22, 10)
+}
+
+func TestRoleChanges(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	alice := member("1", "alice", "0")
+	alice.Roles = []string{"10", "20"}
+	session.setMembers(testGuildID, alice)
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{
+		Announce: append(defaultAnnounce, store.EventRoleAdd, store.EventRoleRemove),
+	})
+	g.syncMembersFromServer(context.Background(), session)
+	assertSent(t, session)
+
+	names := fakeRoleNamer{"10": "Verified", "30": "Gamer"}
+	auditLog := fakeAuditLog{
+		entries: []*discordgo.AuditLogEntry{
+			{ID: snowflakeAt(time.Now()), TargetID: "2", UserID: "8"},
+			{ID: snowflakeAt(time.Now()), TargetID: "1", UserID: "9"},
+		},
+		users: []*discordgo.User{{ID: "9", Username: "Carl-bot", Bot: true}},
+	}
+	alice.Roles = []string{"10", "30"}
+	g.bot.memberUpdated(auditLog, names, g, time.Now(), "1", memberFromDiscord(alice))
+	// an old entry is of an earlier change
+	stale := fakeAuditLog{entries: []*discordgo.AuditLogEntry{{ID: snowflakeAt(time.Now().Add(-time.Hour)), TargetID: "1", UserID: "9"}}}
+	alice.Roles = []string{"30"}
+	g.bot.memberUpdated(stale, names, g, time.Now(), "1", memberFromDiscord(alice))
+	// without the View Audit Log permission, the source is unknown
+	alice.Roles = []string{"30", "40"}
+	g.bot.memberUpdated(fakeAuditLog{err: errors.New("missing access")}, names, g, time.Now(), "1", memberFromDiscord(alice))
+	assertSent(t, session,
+		"🏷️ <@1> (alice) was given the Gamer role by <@9>",
+		"<@1> (alice) lost the 20 role, removed by <@9>",
+		"<@1> (alice) lost the Verified role",
+		"🏷️ <@1> (alice) was given the 40 role",
+	)
+
+	events, err := st.UserHistory(testGuildID, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 || events[0].Event != store.EventRoleAdd || events[0].Details != `{"role_id":"30","role_name":"Gamer","moderator_id":"9","bot":true}` {
+		t.Errorf("unexpected history %+v", events)
+	}
+
+	// role changes found by a sync aren't recorded
+	alice.Roles = nil
+	g.syncMembersFromServer(context.Background(), session)
+	assertSent(t, session)
+	if events, err := st.UserHistory(testGuildID, "1"); err != nil || len(events) != 4 {
+		t.Errorf("expected no new history, got %+v (%v)", events, err)
+	}
+}
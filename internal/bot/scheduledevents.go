@@ -0,0 +1,209 @@
+package bot
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/i18n"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// scheduledEventListSize is how many events /userlog event-attendance lists without an event
+const scheduledEventListSize = 10
+
+// ScheduledEventGetter looks up scheduled events the bot didn't see being created, it is implemented by *discordgo.Session
+type ScheduledEventGetter interface {
+	GuildScheduledEvent(guildID, eventID string, userCount bool) (*discordgo.GuildScheduledEvent, error)
+}
+
+func (b *Bot) guildScheduledEventCreate(s *discordgo.Session, e *discordgo.GuildScheduledEventCreate) {
+	b.scheduledEventChanged(e.GuildScheduledEvent)
+}
+
+func (b *Bot) guildScheduledEventUpdate(s *discordgo.Session, e *discordgo.GuildScheduledEventUpdate) {
+	b.scheduledEventChanged(e.GuildScheduledEvent)
+}
+
+func (b *Bot) guildScheduledEventUserAdd(s *discordgo.Session, e *discordgo.GuildScheduledEventUserAdd) {
+	b.rsvpChanged(s, e.GuildID, e.GuildScheduledEventID, e.UserID, true, time.Now())
+}
+
+func (b *Bot) guildScheduledEventUserRemove(s *discordgo.Session, e *discordgo.GuildScheduledEventUserRemove) {
+	b.rsvpChanged(s, e.GuildID, e.GuildScheduledEventID, e.UserID, false, time.Now())
+}
+
+func (b *Bot) scheduledEventChanged(event *discordgo.GuildScheduledEvent) {
+	if !b.options.TrackScheduledEvents || event == nil {
+		return
+	}
+	g, ok := b.guilds[event.GuildID]
+	if !ok {
+		return
+	}
+	g.scheduledEventChanged(store.ScheduledEvent{ID: event.ID, Name: event.Name, StartsAt: event.ScheduledStartTime})
+}
+
+// rsvpChanged records a member marking themselves interested in a scheduled event or withdrawing,
+// looking up the event first if it was created while the bot was away
+func (b *Bot) rsvpChanged(s ScheduledEventGetter, guildID, eventID, discordID string, interested bool, at time.Time) {
+	if !b.options.TrackScheduledEvents {
+		return
+	}
+	g, ok := b.guilds[guildID]
+	if !ok {
+		return
+	}
+	if _, known, err := b.store.ScheduledEvent(guildID, eventID); err != nil {
+		log.Printf("failed to load scheduled event '%v': %v", eventID, err)
+	} else if !known {
+		event := store.ScheduledEvent{ID: eventID}
+		if fetched, err := s.GuildScheduledEvent(guildID, eventID, false); err != nil {
+			log.Printf("failed to look up scheduled event '%v': %v", eventID, err)
+		} else {
+			event.Name, event.StartsAt = fetched.Name, fetched.ScheduledStartTime
+		}
+		g.scheduledEventChanged(event)
+	}
+	g.rsvpChanged(eventID, discordID, interested, at)
+}
+
+// scheduledEventChanged records a scheduled event being created or edited
+func (g *Guild) scheduledEventChanged(event store.ScheduledEvent) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed {
+		return
+	}
+	if err := g.store.RecordScheduledEvent(g.ID, event); err != nil {
+		log.Printf("failed to record scheduled event '%v': %v", event.ID, err)
+	}
+}
+
+// rsvpChanged records a member marking themselves interested in a scheduled event, or withdrawing
+func (g *Guild) rsvpChanged(eventID, discordID string, interested bool, at time.Time) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed {
+		return
+	}
+	if err := g.store.RecordRSVP(g.ID, eventID, discordID, interested, at); err != nil {
+		log.Printf("failed to record the RSVP of '%v' to scheduled event '%v': %v", discordID, eventID, err)
+	}
+}
+
+func (b *Bot) commandEventAttendance(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	event := ""
+	for _, option := range options {
+		if option.Name == "event" {
+			event = option.StringValue()
+		}
+	}
+	return b.eventAttendance(g, event)
+}
+
+// eventAttendance lists the latest scheduled events with how many members are interested,
+// or the RSVPs to the event with the given ID or name
+func (b *Bot) eventAttendance(g *Guild, event string) *discordgo.InteractionResponseData {
+	lang := g.language()
+	if !b.options.TrackScheduledEvents {
+		return textResponse(lang.Translate("Scheduled event tracking is off."))
+	}
+	if event == "" {
+		return b.scheduledEvents(g)
+	}
+
+	found, ok, err := b.findScheduledEvent(g, event)
+	if err != nil {
+		log.Printf("failed to load scheduled events: %v", err)
+		return textResponse(lang.Translate("Failed to load the scheduled events, check the logs."))
+	}
+	if !ok {
+		return textResponse(lang.Sprintf("No scheduled event %v was recorded.", event))
+	}
+	rsvps, err := b.store.RSVPs(g.ID, found.ID)
+	if err != nil {
+		log.Printf("failed to load the RSVPs to scheduled event '%v': %v", found.ID, err)
+		return textResponse(lang.Translate("Failed to load the scheduled events, check the logs."))
+	}
+
+	var description strings.Builder
+	interested := 0
+	for _, rsvp := range rsvps {
+		line := lang.Sprintf("<@%v> interested since <t:%v:d>", rsvp.DiscordID, rsvp.At.Unix()) + "\n"
+		if rsvp.WithdrawnAt.IsZero() {
+			interested++
+		} else {
+			line = lang.Sprintf("~~<@%v>~~ withdrew <t:%v:d>", rsvp.DiscordID, rsvp.WithdrawnAt.Unix()) + "\n"
+		}
+		if description.Len()+len(line) > watchListMaxLength {
+			description.WriteString("…\n")
+			break
+		}
+		description.WriteString(line)
+	}
+	if len(rsvps) == 0 {
+		description.WriteString(lang.Translate("Nobody RSVPed yet."))
+	}
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       scheduledEventName(lang, found),
+			Description: description.String(),
+			Footer:      &discordgo.MessageEmbedFooter{Text: lang.Sprintf("%v interested, %v withdrew", interested, len(rsvps)-interested)},
+		}},
+	}
+}
+
+// findScheduledEvent finds a scheduled event by ID, or by name among the latest events, ignoring case
+func (b *Bot) findScheduledEvent(g *Guild, event string) (store.ScheduledEvent, bool, error) {
+	if found, ok, err := b.store.ScheduledEvent(g.ID, event); ok || err != nil {
+		return found, ok, err
+	}
+	events, err := b.store.ScheduledEvents(g.ID, 100)
+	if err != nil {
+		return store.ScheduledEvent{}, false, err
+	}
+	for _, found := range events {
+		if strings.EqualFold(found.Name, event) {
+			return found, true, nil
+		}
+	}
+	return store.ScheduledEvent{}, false, nil
+}
+
+// scheduledEvents lists the latest scheduled events with how many members are interested
+func (b *Bot) scheduledEvents(g *Guild) *discordgo.InteractionResponseData {
+	lang := g.language()
+	events, err := b.store.ScheduledEvents(g.ID, scheduledEventListSize)
+	if err != nil {
+		log.Printf("failed to load scheduled events: %v", err)
+		return textResponse(lang.Translate("Failed to load the scheduled events, check the logs."))
+	}
+	if len(events) == 0 {
+		return textResponse(lang.Translate("No scheduled events were recorded yet."))
+	}
+
+	var description strings.Builder
+	for _, event := range events {
+		if event.StartsAt.IsZero() {
+			description.WriteString(lang.Sprintf("**%v**: %v interested", scheduledEventName(lang, event), event.Interested) + "\n")
+			continue
+		}
+		description.WriteString(lang.Sprintf("**%v** <t:%v:f>: %v interested", scheduledEventName(lang, event), event.StartsAt.Unix(), event.Interested) + "\n")
+	}
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       lang.Translate("Scheduled Events"),
+			Description: description.String(),
+		}},
+	}
+}
+
+// scheduledEventName names an event by its ID if its name couldn't be looked up
+func scheduledEventName(lang *i18n.Language, event store.ScheduledEvent) string {
+	if event.Name == "" {
+		return lang.Sprintf("Event %v", event.ID)
+	}
+	return event.Name
+}
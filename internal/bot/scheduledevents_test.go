@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type fakeEventGetter map[string]*discordgo.GuildScheduledEvent
+
+func (f fakeEventGetter) GuildScheduledEvent(guildID, eventID string, userCount bool) (*discordgo.GuildScheduledEvent, error) {
+	if event, ok := f[eventID]; ok {
+		return event, nil
+	}
+	return nil, errors.New("unknown event")
+}
+
+func TestEventAttendance(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	g := newTestGuildWithOptions(t, st, session, Options{TrackScheduledEvents: true})
+	b := g.bot
+	at := time.Unix(1700000000, 0)
+
+	if response := b.eventAttendance(g, ""); response.Content != "No scheduled events were recorded yet." {
+		t.Errorf("unexpected response %q", response.Content)
+	}
+
+	b.scheduledEventChanged(&discordgo.GuildScheduledEvent{ID: "e1", GuildID: testGuildID, Name: "Movie Night", ScheduledStartTime: at.Add(24 * time.Hour)})
+	// e2 was created while the bot was away, e3 can't be looked up
+	getter := fakeEventGetter{"e2": {ID: "e2", GuildID: testGuildID, Name: "Game Night", ScheduledStartTime: at.Add(48 * time.Hour)}}
+	b.rsvpChanged(getter, testGuildID, "e1", "1", true, at)
+	b.rsvpChanged(getter, testGuildID, "e1", "2", true, at.Add(time.Hour))
+	b.rsvpChanged(getter, testGuildID, "e1", "2", false, at.Add(2*time.Hour))
+	b.rsvpChanged(getter, testGuildID, "e2", "1", true, at.Add(3*time.Hour))
+	b.rsvpChanged(getter, testGuildID, "e3", "3", true, at.Add(4*time.Hour))
+	// events of untracked guilds are ignored
+	b.rsvpChanged(getter, "999", "e1", "3", true, at)
+
+	response := b.eventAttendance(g, "")
+	expected := "**Game Night** <t:1700172800:f>: 1 interested\n**Movie Night** <t:1700086400:f>: 1 interested\n**Event e3**: 1 interested\n"
+	if len(response.Embeds) != 1 || response.Embeds[0].Description != expected {
+		t.Errorf("expected %q, got %+v", expected, response)
+	}
+
+	// events are found by name too
+	for _, event := range []string{"e1", "movie night"} {
+		response = b.eventAttendance(g, event)
+		if len(response.Embeds) != 1 {
+			t.Fatalf("expected an embed, got %+v", response)
+		}
+		embed := response.Embeds[0]
+		if embed.Title != "Movie Night" || embed.Footer.Text != "1 interested, 1 withdrew" ||
+			embed.Description != "<@1> interested since <t:1700000000:d>\n~~<@2>~~ withdrew <t:1700007200:d>\n" {
+			t.Errorf("unexpected attendance of %v: %+v %+v", event, embed, embed.Footer)
+		}
+	}
+	if response := b.eventAttendance(g, "Book Club"); response.Content != "No scheduled event Book Club was recorded." {
+		t.Errorf("unexpected response %q", response.Content)
+	}
+
+	b.options.TrackScheduledEvents = false
+	b.rsvpChanged(getter, testGuildID, "e1", "4", true, at)
+	if response := b.eventAttendance(g, ""); !strings.Contains(response.Content, "tracking is off") {
+		t.Errorf("unexpected response %q", response.Content)
+	}
+	if rsvps, err := st.RSVPs(testGuildID, "e1"); err != nil || len(rsvps) != 2 {
+		t.Errorf("expected RSVPs not to be recorded without tracking, got %+v (%v)", rsvps, err)
+	}
+}
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func (b *Bot) commandConfig(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	if len(options) == 0 {
+		return textResponse(g.language().Translate("Unknown command."))
+	}
+	values := map[string]string{}
+	for _, option := range options[0].Options {
+		values[option.Name] = option.StringValue()
+	}
+
+	var response *discordgo.InteractionResponseData
+	switch options[0].Name {
+	case "show":
+		response = b.showSettings(g)
+	case "set":
+		response = b.changeSetting(g, values["key"], values["value"], true)
+	case "unset":
+		response = b.changeSetting(g, values["key"], "", false)
+	default:
+		response = textResponse(g.language().Translate("Unknown command."))
+	}
+	if options[0].Name != "show" {
+		log.Printf("[config] %v '%v' in guild '%v' requested by '%v'", options[0].Name, values["key"], g.ID, i.Member.User.ID)
+	}
+	return response
+}
+
+func (b *Bot) showSettings(g *Guild) *discordgo.InteractionResponseData {
+	lang := g.language()
+	settings, err := b.store.GuildSettings(g.ID)
+	if err != nil {
+		log.Printf("failed to load settings of guild '%v': %v", g.ID, err)
+		return textResponse(lang.Translate("Failed to load settings, check the logs."))
+	}
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var description strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&description, "**%v**\n```\n%v\n```\n", key, strings.ReplaceAll(settings[key], "```", "'''"))
+	}
+	if len(keys) == 0 {
+		description.WriteString(lang.Translate("Nothing was changed, the config file is used as-is.") + "\n")
+	}
+	description.WriteString("\n" + lang.Sprintf("Available settings: %v", strings.Join(b.options.SettingNames, ", ")))
+
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       lang.Translate("Settings"),
+			Description: description.String(),
+		}},
+	}
+}
+
+// changeSetting stores a setting and applies it, restoring the old value if the new one doesn't work
+func (b *Bot) changeSetting(g *Guild, key, value string, set bool) *discordgo.InteractionResponseData {
+	if b.options.Reconfigure == nil {
+		return textResponse(g.language().Translate("Settings can't be changed at runtime."))
+	}
+	settings, err := b.store.GuildSettings(g.ID)
+	if err != nil {
+		log.Printf("failed to load settings of guild '%v': %v", g.ID, err)
+		return textResponse(g.language().Translate("Failed to load settings, check the logs."))
+	}
+	if _, existed := settings[key]; !set && !existed {
+		return textResponse(g.language().Sprintf("`%v` isn't set.", key))
+	}
+
+	if response := b.applySettings(g, []settingChange{{key, value, set}}); response != nil {
+		return response
+	}
+
+	// the language itself may have just changed
+	if set {
+		return textResponse(g.language().Sprintf("Set `%v`.", key))
+	}
+	return textResponse(g.language().Sprintf("Unset `%v`, the config file value is used again.", key))
+}
+
+// settingChange sets a setting to value, or unsets it
+type settingChange struct {
+	key   string
+	value string
+	set   bool
+}
+
+// applySettings stores settings and applies them together, restoring the old values if the new ones don't work.
+// It returns a response explaining the failure, or nil if the settings were applied.
+func (b *Bot) applySettings(g *Guild, changes []settingChange) *discordgo.InteractionResponseData {
+	if b.options.Reconfigure == nil {
+		return textResponse(g.language().Translate("Settings can't be changed at runtime."))
+	}
+	settings, err := b.store.GuildSettings(g.ID)
+	if err != nil {
+		log.Printf("failed to load settings of guild '%v': %v", g.ID, err)
+		return textResponse(g.language().Translate("Failed to load settings, check the logs."))
+	}
+
+	for n, change := range changes {
+		if err := b.storeSetting(g.ID, change.key, change.value, change.set); err != nil {
+			log.Printf("failed to store setting '%v' of guild '%v': %v", change.key, g.ID, err)
+			b.restoreSettings(g.ID, settings, changes[:n])
+			return textResponse(g.language().Translate("Failed to store the setting, check the logs."))
+		}
+	}
+	if err := b.options.Reconfigure(g.ID); err != nil {
+		b.restoreSettings(g.ID, settings, changes)
+		return textResponse(g.language().Sprintf("Invalid setting, nothing was changed: %v", err))
+	}
+	return nil
+}
+
+// restoreSettings puts back the old values of changed settings
+func (b *Bot) restoreSettings(guildID string, old map[string]string, changes []settingChange) {
+	for _, change := range changes {
+		value, existed := old[change.key]
+		if err := b.storeSetting(guildID, change.key, value, existed); err != nil {
+			log.Printf("failed to restore setting '%v' of guild '%v': %v", change.key, guildID, err)
+		}
+	}
+}
+
+func (b *Bot) storeSetting(guildID, key, value string, set bool) error {
+	if set {
+		return b.store.SetGuildSetting(guildID, key, value, time.Now())
+	}
+	return b.store.DeleteGuildSetting(guildID, key)
+}
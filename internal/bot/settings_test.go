@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChangeSettingRestoresInvalidValues(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	reconfigured := 0
+	g := newTestGuildWithOptions(t, st, session, Options{
+		Reconfigure: func(guildID string) error {
+			reconfigured++
+			settings, err := st.GuildSettings(guildID)
+			if err != nil {
+				return err
+			}
+			if settings["channel_id"] == "bad" {
+				return errors.New("bad channel")
+			}
+			return nil
+		},
+	})
+
+	assertSetting := func(expected string, expectedSet bool) {
+		t.Helper()
+		settings, err := st.GuildSettings(testGuildID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value, set := settings["channel_id"]; value != expected || set != expectedSet {
+			t.Errorf("channel_id is %q (set %v), expected %q (set %v)", value, set, expected, expectedSet)
+		}
+	}
+
+	if response := g.bot.changeSetting(g, "channel_id", "300", true); response.Content != "Set `channel_id`." {
+		t.Errorf("unexpected response %q", response.Content)
+	}
+	assertSetting("300", true)
+
+	if response := g.bot.changeSetting(g, "channel_id", "bad", true); response.Content != "Invalid setting, nothing was changed: bad channel" {
+		t.Errorf("unexpected response %q", response.Content)
+	}
+	assertSetting("300", true)
+
+	g.bot.changeSetting(g, "channel_id", "", false)
+	assertSetting("", false)
+	if response := g.bot.changeSetting(g, "channel_id", "", false); response.Content != "`channel_id` isn't set." {
+		t.Errorf("unexpected response %q", response.Content)
+	}
+
+	if reconfigured != 3 {
+		t.Errorf("expected 3 reconfigurations, got %v", reconfigured)
+	}
+}
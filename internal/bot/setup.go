@@ -0,0 +1,343 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/i18n"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// maxSelectOptions is Discord's limit of options in a select menu
+const maxSelectOptions = 25
+
+// setupStyle is an announcement style offered by /userlog setup, empty templates are the language's defaults
+type setupStyle struct {
+	name        string
+	label       string
+	description string
+	join        string
+	leave       string
+}
+
+// setupStyles are offered in order, setupStyleCustom opens a modal to write the templates instead
+var setupStyles = []setupStyle{
+	{"default", "Default", "Mention, name, and member count", "", ""},
+	{"compact", "Compact", "Just the mention", "📥 <@{{.ID}}>", "📤 <@{{.ID}}>"},
+}
+
+const setupStyleCustom = "custom"
+
+// setupEvents are the event types /userlog setup offers to announce
+var setupEvents = []string{
+	store.EventJoin, store.EventLeave, store.EventBoostStart, store.EventBoostStop,
+	store.EventTimeout, store.EventTimeoutEnd, store.EventScreeningComplete, store.EventAvatarChange,
+	store.EventRename, store.EventBan, store.EventUnban, store.EventRoleAdd, store.EventRoleRemove, notify.EventAnniversary,
+}
+
+// languageNames are shown in the language menu, in each language itself
+var languageNames = map[string]string{
+	"en":    "English",
+	"de":    "Deutsch",
+	"fr":    "Français",
+	"pt-BR": "Português (Brasil)",
+}
+
+func (b *Bot) commandSetup(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	return b.setupPage(g, guildChannels(s, g.ID))
+}
+
+// componentSetup handles the menus, buttons, and modals of the setup wizard, with custom IDs like "userlog:setup:<step>"
+func (b *Bot) componentSetup(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, args []string) *discordgo.InteractionResponseData {
+	if len(args) != 1 {
+		return textResponse(g.language().Translate("Unknown command."))
+	}
+	step := args[0]
+	if step == "done" {
+		return &discordgo.InteractionResponseData{
+			Content:    g.language().Translate("Setup is done. Single settings can be changed with /userlog config."),
+			Embeds:     []*discordgo.MessageEmbed{},
+			Components: []discordgo.MessageComponent{},
+		}
+	}
+
+	var values []string
+	var inputs map[string]string
+	if i.Type == discordgo.InteractionModalSubmit {
+		inputs = modalInputs(i.ModalSubmitData())
+	} else {
+		values = i.MessageComponentData().Values
+	}
+	modal, problem := b.setupStep(g, step, values, inputs)
+	if modal != nil {
+		return modal
+	}
+	if problem == "" {
+		log.Printf("[setup] %v in guild '%v' requested by '%v'", step, g.ID, i.Member.User.ID)
+	}
+	page := b.setupPage(g, guildChannels(s, g.ID))
+	page.Content = problem
+	return page
+}
+
+// setupStep applies a step of the setup wizard with the selected values or submitted modal inputs.
+// It returns a modal to open instead, or a problem to show above the wizard.
+func (b *Bot) setupStep(g *Guild, step string, values []string, inputs map[string]string) (*discordgo.InteractionResponseData, string) {
+	lang := g.language()
+	var changes []settingChange
+	switch step {
+	case "channel", "language":
+		if len(values) != 1 {
+			return nil, lang.Translate("Unknown command.")
+		}
+		key := "channel_id"
+		if step == "language" {
+			key = "language"
+		}
+		changes = []settingChange{{key, values[0], true}}
+	case "style":
+		if len(values) != 1 {
+			return nil, lang.Translate("Unknown command.")
+		}
+		if values[0] == setupStyleCustom {
+			return b.templatesModal(g), ""
+		}
+		for _, style := range setupStyles {
+			if style.name == values[0] {
+				changes = templateChanges(style.join, style.leave)
+			}
+		}
+		if changes == nil {
+			return nil, lang.Translate("Unknown command.")
+		}
+	case "announce":
+		changes = []settingChange{{"announce", strings.Join(values, ","), true}}
+	case "more":
+		return b.optionsModal(g), ""
+	case "templates":
+		changes = templateChanges(inputs["template_join"], inputs["template_leave"])
+	case "options":
+		for _, key := range []string{"quiet_hours", "milestone_every", "timezone"} {
+			value := strings.TrimSpace(inputs[key])
+			changes = append(changes, settingChange{key, value, value != ""})
+		}
+	default:
+		return nil, lang.Translate("Unknown command.")
+	}
+
+	if response := b.applySettings(g, changes); response != nil {
+		return nil, response.Content
+	}
+	return nil, ""
+}
+
+// templateChanges sets the join and leave templates, unsetting empty ones
+func templateChanges(join, leave string) []settingChange {
+	return []settingChange{
+		{"template_" + store.EventJoin, join, join != ""},
+		{"template_" + store.EventLeave, leave, leave != ""},
+	}
+}
+
+// guildChannels returns the channels announcements can be posted in, in the order Discord lists them
+func guildChannels(s *discordgo.Session, guildID string) []*discordgo.Channel {
+	all, err := s.GuildChannels(guildID)
+	if err != nil {
+		log.Printf("failed to load the channels of guild '%v': %v", guildID, err)
+		return nil
+	}
+	channels := []*discordgo.Channel{}
+	for _, channel := range all {
+		if channel.Type == discordgo.ChannelTypeGuildText || channel.Type == discordgo.ChannelTypeGuildNews {
+			channels = append(channels, channel)
+		}
+	}
+	sort.SliceStable(channels, func(i, j int) bool {
+		return channels[i].Position < channels[j].Position
+	})
+	return channels
+}
+
+// setupPage renders the setup wizard, preselecting the current settings
+func (b *Bot) setupPage(g *Guild, channels []*discordgo.Channel) *discordgo.InteractionResponseData {
+	lang := g.language()
+	settings, err := b.store.GuildSettings(g.ID)
+	if err != nil {
+		log.Printf("failed to load settings of guild '%v': %v", g.ID, err)
+		return textResponse(lang.Translate("Failed to load settings, check the logs."))
+	}
+
+	channelOptions := []discordgo.SelectMenuOption{}
+	for _, channel := range channels {
+		if len(channelOptions) == maxSelectOptions {
+			break
+		}
+		channelOptions = append(channelOptions, discordgo.SelectMenuOption{
+			Label:   "#" + channel.Name,
+			Value:   channel.ID,
+			Default: channel.ID == settings["channel_id"],
+		})
+	}
+	description := lang.Translate("Pick where and how members are announced. Changes are saved right away and override the config file.")
+	if len(channels) > maxSelectOptions {
+		description += "\n" + lang.Sprintf("Only the first %v channels are listed, set others with /userlog config set channel_id.", maxSelectOptions)
+	}
+
+	current := setupStyleCustom
+	for _, style := range setupStyles {
+		if settings["template_"+store.EventJoin] == style.join && settings["template_"+store.EventLeave] == style.leave {
+			current = style.name
+		}
+	}
+	styleOptions := []discordgo.SelectMenuOption{}
+	for _, style := range setupStyles {
+		styleOptions = append(styleOptions, discordgo.SelectMenuOption{
+			Label:       lang.Translate(style.label),
+			Value:       style.name,
+			Description: lang.Translate(style.description),
+			Default:     style.name == current,
+		})
+	}
+	styleOptions = append(styleOptions, discordgo.SelectMenuOption{
+		Label:       lang.Translate("Custom"),
+		Value:       setupStyleCustom,
+		Description: lang.Translate("Write your own templates"),
+		Default:     current == setupStyleCustom,
+	})
+
+	announceOptions := []discordgo.SelectMenuOption{}
+	for _, eventType := range setupEvents {
+		announceOptions = append(announceOptions, discordgo.SelectMenuOption{
+			Label:   eventType,
+			Value:   eventType,
+			Default: g.announces(eventType),
+		})
+	}
+	noEvents := 0
+
+	languageOptions := []discordgo.SelectMenuOption{}
+	for _, code := range i18n.Codes() {
+		language, _ := i18n.Lookup(code)
+		languageOptions = append(languageOptions, discordgo.SelectMenuOption{
+			Label:   languageNames[code],
+			Value:   code,
+			Default: language == lang,
+		})
+	}
+
+	// menus need at least one option, the channel can still be set with /userlog config if none were loaded
+	components := []discordgo.MessageComponent{}
+	if len(channelOptions) > 0 {
+		components = append(components, discordgo.ActionsRow{Components: []discordgo.MessageComponent{discordgo.SelectMenu{
+			CustomID:    setupID("channel"),
+			Placeholder: lang.Translate("Announcement channel"),
+			Options:     channelOptions,
+		}}})
+	}
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       lang.Translate("Setup"),
+			Description: description,
+		}},
+		Components: append(components,
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{discordgo.SelectMenu{
+				CustomID:    setupID("style"),
+				Placeholder: lang.Translate("Announcement style"),
+				Options:     styleOptions,
+			}}},
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{discordgo.SelectMenu{
+				CustomID:    setupID("announce"),
+				Placeholder: lang.Translate("Announced events"),
+				MinValues:   &noEvents,
+				MaxValues:   len(announceOptions),
+				Options:     announceOptions,
+			}}},
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{discordgo.SelectMenu{
+				CustomID:    setupID("language"),
+				Placeholder: lang.Translate("Language"),
+				Options:     languageOptions,
+			}}},
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    lang.Translate("More options"),
+					Style:    discordgo.SecondaryButton,
+					CustomID: setupID("more"),
+				},
+				discordgo.Button{
+					Label:    lang.Translate("Done"),
+					Style:    discordgo.SuccessButton,
+					CustomID: setupID("done"),
+				},
+			}},
+		),
+	}
+}
+
+// templatesModal asks for the join and leave templates, filled in with the current ones
+func (b *Bot) templatesModal(g *Guild) *discordgo.InteractionResponseData {
+	lang := g.language()
+	settings, err := b.store.GuildSettings(g.ID)
+	if err != nil {
+		log.Printf("failed to load settings of guild '%v': %v", g.ID, err)
+	}
+	return &discordgo.InteractionResponseData{
+		CustomID: setupID("templates"),
+		Title:    lang.Translate("Announcement templates"),
+		Components: []discordgo.MessageComponent{
+			modalInput("template_"+store.EventJoin, lang.Translate("Join template, empty for the default"), settings["template_"+store.EventJoin], discordgo.TextInputParagraph),
+			modalInput("template_"+store.EventLeave, lang.Translate("Leave template, empty for the default"), settings["template_"+store.EventLeave], discordgo.TextInputParagraph),
+		},
+	}
+}
+
+// optionsModal asks for the options without a menu of their own, filled in with the current ones
+func (b *Bot) optionsModal(g *Guild) *discordgo.InteractionResponseData {
+	lang := g.language()
+	settings, err := b.store.GuildSettings(g.ID)
+	if err != nil {
+		log.Printf("failed to load settings of guild '%v': %v", g.ID, err)
+	}
+	return &discordgo.InteractionResponseData{
+		CustomID: setupID("options"),
+		Title:    lang.Translate("More options"),
+		Components: []discordgo.MessageComponent{
+			modalInput("quiet_hours", lang.Translate("Quiet hours, like 01:00-08:00"), settings["quiet_hours"], discordgo.TextInputShort),
+			modalInput("milestone_every", lang.Translate("Announce every N members"), settings["milestone_every"], discordgo.TextInputShort),
+			modalInput("timezone", lang.Translate("Timezone, like Europe/Berlin"), settings["timezone"], discordgo.TextInputShort),
+		},
+	}
+}
+
+func setupID(step string) string {
+	return fmt.Sprintf("%v:setup:%v", userlogCommand.Name, step)
+}
+
+func modalInput(customID, label, value string, style discordgo.TextInputStyle) discordgo.MessageComponent {
+	return discordgo.ActionsRow{Components: []discordgo.MessageComponent{discordgo.TextInput{
+		CustomID: customID,
+		Label:    label,
+		Style:    style,
+		Value:    value,
+	}}}
+}
+
+// modalInputs returns the submitted text inputs of a modal, keyed by custom ID
+func modalInputs(data discordgo.ModalSubmitInteractionData) map[string]string {
+	inputs := map[string]string{}
+	for _, row := range data.Components {
+		actionsRow, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range actionsRow.Components {
+			if input, ok := component.(*discordgo.TextInput); ok {
+				inputs[input.CustomID] = input.Value
+			}
+		}
+	}
+	return inputs
+}
@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestSetupSteps(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	g := newTestGuildWithOptions(t, st, session, Options{
+		Reconfigure: func(guildID string) error { return nil },
+	})
+
+	assertSettings := func(expected map[string]string) {
+		t.Helper()
+		settings, err := st.GuildSettings(testGuildID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(settings, expected) {
+			t.Errorf("settings are %v, expected %v", settings, expected)
+		}
+	}
+
+	g.bot.setupStep(g, "channel", []string{"300"}, nil)
+	g.bot.setupStep(g, "style", []string{"compact"}, nil)
+	g.bot.setupStep(g, "announce", []string{"join", "anniversary"}, nil)
+	assertSettings(map[string]string{
+		"channel_id":     "300",
+		"template_join":  "📥 <@{{.ID}}>",
+		"template_leave": "📤 <@{{.ID}}>",
+		"announce":       "join,anniversary",
+	})
+
+	// custom templates are asked for in a modal, empty ones go back to the default
+	modal, problem := g.bot.setupStep(g, "style", []string{setupStyleCustom}, nil)
+	if modal == nil || modal.CustomID != "userlog:setup:templates" || problem != "" {
+		t.Fatalf("expected the templates modal, got %+v %q", modal, problem)
+	}
+	g.bot.setupStep(g, "templates", nil, map[string]string{"template_join": "hi <@{{.ID}}>", "template_leave": ""})
+	g.bot.setupStep(g, "options", nil, map[string]string{"quiet_hours": " 01:00-08:00 ", "milestone_every": "", "timezone": ""})
+	assertSettings(map[string]string{
+		"channel_id":    "300",
+		"template_join": "hi <@{{.ID}}>",
+		"announce":      "join,anniversary",
+		"quiet_hours":   "01:00-08:00",
+	})
+
+	if _, problem := g.bot.setupStep(g, "style", []string{"fancy"}, nil); problem != "Unknown command." {
+		t.Errorf("expected an unknown style to fail, got %q", problem)
+	}
+}
+
+func TestSetupPage(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	g := newTestGuildWithOptions(t, st, session, Options{
+		Reconfigure: func(guildID string) error { return nil },
+	})
+	g.bot.setupStep(g, "channel", []string{"2"}, nil)
+
+	page := g.bot.setupPage(g, []*discordgo.Channel{
+		{ID: "1", Name: "general"},
+		{ID: "2", Name: "member-log"},
+	})
+	selected := map[string][]string{}
+	for _, row := range page.Components {
+		for _, component := range row.(discordgo.ActionsRow).Components {
+			if menu, ok := component.(discordgo.SelectMenu); ok {
+				for _, option := range menu.Options {
+					if option.Default {
+						selected[menu.CustomID] = append(selected[menu.CustomID], option.Value)
+					}
+				}
+			}
+		}
+	}
+	expected := map[string][]string{
+		"userlog:setup:channel":  {"2"},
+		"userlog:setup:style":    {"default"},
+		"userlog:setup:announce": {"join", "leave"},
+		"userlog:setup:language": {"en"},
+	}
+	if !reflect.DeepEqual(selected, expected) {
+		t.Errorf("selected %v, expected %v", selected, expected)
+	}
+}
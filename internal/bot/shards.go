@@ -0,0 +1,240 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/telemetry"
+)
+
+// identifyInterval is how long Discord wants between identifying groups of shards
+const identifyInterval = 5 * time.Second
+
+// Shards are the gateway sessions of the bot, each receiving the events of the guilds Discord assigns to it.
+// Requests about a guild are sent through its shard, the others through the first one.
+type Shards struct {
+	sessions []*discordgo.Session
+	// concurrency is how many shards may identify at once
+	concurrency int
+	// reconnectLock keeps token rotations from reconnecting the shards at the same time
+	reconnectLock sync.Mutex
+}
+
+// ErrTokenUnchanged is returned by Rotate if the shards already use the token
+var ErrTokenUnchanged = errors.New("the token didn't change")
+
+// NewShards creates the sessions of count shards, asking Discord for the recommended count if it is 0
+func NewShards(token string, count int, intents discordgo.Intent) (*Shards, error) {
+	session, err := newSession(token)
+	if err != nil {
+		return nil, err
+	}
+	concurrency := 1
+	if count == 0 {
+		gateway, err := session.GatewayBot()
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect the shard count: %w", err)
+		}
+		count = gateway.Shards
+		if gateway.SessionStartLimit.MaxConcurrency > 1 {
+			concurrency = gateway.SessionStartLimit.MaxConcurrency
+		}
+	}
+	if count < 1 {
+		count = 1
+	}
+
+	shards := &Shards{concurrency: concurrency}
+	for shardID := 0; shardID < count; shardID++ {
+		if shardID > 0 {
+			if session, err = newSession(token); err != nil {
+				return nil, err
+			}
+		}
+		session.ShardID = shardID
+		session.ShardCount = count
+		session.Identify.Intents = intents
+		shards.sessions = append(shards.sessions, session)
+	}
+	return shards, nil
+}
+
+// newSession creates a session whose requests are traced when telemetry is enabled
+func newSession(token string) (*discordgo.Session, error) {
+	session, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return nil, err
+	}
+	session.Client.Transport = &telemetry.Transport{Base: session.Client.Transport}
+	return session, nil
+}
+
+// Rotate switches every shard to a new token, checking it first, so a wrong token doesn't disconnect the bot.
+// Requests use it right away, and the shards reconnect in the background to identify with it.
+func (s *Shards) Rotate(token string) error {
+	primary := s.Primary()
+	primary.RLock()
+	current := primary.Token
+	primary.RUnlock()
+	if current == "Bot "+token {
+		return ErrTokenUnchanged
+	}
+
+	check, err := newSession(token)
+	if err != nil {
+		return err
+	}
+	user, err := check.User("@me")
+	if err != nil {
+		return fmt.Errorf("the new token doesn't work: %w", err)
+	}
+	if primary.State.User != nil && primary.State.User.ID != user.ID {
+		return fmt.Errorf("the new token belongs to '%v', not this bot", user.ID)
+	}
+
+	for _, session := range s.sessions {
+		session.Lock()
+		session.Token = "Bot " + token
+		session.Identify.Token = session.Token
+		session.Unlock()
+	}
+	go s.reconnect()
+	return nil
+}
+
+// reconnect closes and reopens every shard, identifying them in groups as Discord's rate limit allows
+func (s *Shards) reconnect() {
+	s.reconnectLock.Lock()
+	defer s.reconnectLock.Unlock()
+	for n, session := range s.sessions {
+		if n > 0 && n%s.concurrency == 0 {
+			time.Sleep(identifyInterval)
+		}
+		session.Close()
+		if err := session.Open(); err != nil {
+			log.Printf("failed to reconnect shard %v with the new token: %v", session.ShardID, err)
+			continue
+		}
+		log.Printf("reconnected shard %v of %v with the new token", session.ShardID+1, len(s.sessions))
+	}
+}
+
+// ShardID returns the shard receiving the events of a guild
+func ShardID(guildID string, count int) int {
+	id, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil || count < 1 {
+		return 0
+	}
+	return int((id >> 22) % uint64(count))
+}
+
+// Count returns the number of shards
+func (s *Shards) Count() int {
+	return len(s.sessions)
+}
+
+// Primary returns the first shard's session, for requests that aren't about a guild
+func (s *Shards) Primary() *discordgo.Session {
+	return s.sessions[0]
+}
+
+// Session returns the session of a guild's shard
+func (s *Shards) Session(guildID string) *discordgo.Session {
+	return s.sessions[ShardID(guildID, len(s.sessions))]
+}
+
+// Open connects every shard, identifying them in groups as Discord's rate limit allows
+func (s *Shards) Open() error {
+	for n, session := range s.sessions {
+		if n > 0 && n%s.concurrency == 0 {
+			time.Sleep(identifyInterval)
+		}
+		if err := session.Open(); err != nil {
+			s.Close()
+			return fmt.Errorf("shard %v: %w", session.ShardID, err)
+		}
+		if len(s.sessions) > 1 {
+			log.Printf("opened shard %v of %v", session.ShardID+1, len(s.sessions))
+		}
+	}
+	return nil
+}
+
+// OnReady calls ready once every shard received its first Ready event, it must be called before opening them
+func (s *Shards) OnReady(ready func()) {
+	var (
+		lock   sync.Mutex
+		shards = map[int]struct{}{}
+		once   sync.Once
+	)
+	for _, session := range s.sessions {
+		session.AddHandler(func(session *discordgo.Session, event *discordgo.Ready) {
+			lock.Lock()
+			shards[session.ShardID] = struct{}{}
+			all := len(shards) == len(s.sessions)
+			lock.Unlock()
+			if all {
+				once.Do(ready)
+			}
+		})
+	}
+}
+
+// HeartbeatAge returns how long ago Discord last acknowledged a heartbeat of the least recently acknowledged shard
+func (s *Shards) HeartbeatAge() time.Duration {
+	var oldest time.Duration
+	for _, session := range s.sessions {
+		session.RLock()
+		age := time.Since(session.LastHeartbeatAck)
+		session.RUnlock()
+		if age > oldest {
+			oldest = age
+		}
+	}
+	return oldest
+}
+
+// Token returns the token the shards identify with, the last one rotated to
+func (s *Shards) Token() string {
+	primary := s.Primary()
+	primary.RLock()
+	defer primary.RUnlock()
+	return strings.TrimPrefix(primary.Token, "Bot ")
+}
+
+// Close disconnects every shard
+func (s *Shards) Close() {
+	for _, session := range s.sessions {
+		session.Close()
+	}
+}
+
+func (s *Shards) GuildMembers(guildID string, after string, limit int) ([]*discordgo.Member, error) {
+	return s.Session(guildID).GuildMembers(guildID, after, limit)
+}
+
+func (s *Shards) ChannelMessageSend(channelID string, content string) (*discordgo.Message, error) {
+	return s.Primary().ChannelMessageSend(channelID, content)
+}
+
+// RequestGuildMembers requests member chunks over the gateway connection of the guild's shard
+func (s *Shards) RequestGuildMembers(guildID, query string, limit int, nonce string, presences bool) error {
+	return s.Session(guildID).RequestGuildMembers(guildID, query, limit, nonce, presences)
+}
+
+// UpdateStatusComplex sets the presence of every shard
+func (s *Shards) UpdateStatusComplex(usd discordgo.UpdateStatusData) error {
+	var firstErr error
+	for _, session := range s.sessions {
+		if err := session.UpdateStatusComplex(usd); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestShardID(t *testing.T) {
+	for _, tc := range []struct {
+		guildID  string
+		count    int
+		expected int
+	}{
+		{"41771983423143937", 1, 0},
+		{"41771983423143937", 4, 2},
+		{"41771983423143937", 16, 6},
+		{"197038439483310086", 16, 2},
+		{"not an ID", 16, 0},
+	} {
+		if actual := ShardID(tc.guildID, tc.count); actual != tc.expected {
+			t.Errorf("expected guild %v to be on shard %v of %v, got %v", tc.guildID, tc.expected, tc.count, actual)
+		}
+	}
+}
+
+func TestShardsRouteGuilds(t *testing.T) {
+	shards, err := NewShards("token", 16, discordgo.IntentsGuildMembers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shards.Count() != 16 {
+		t.Fatalf("expected 16 shards, got %v", shards.Count())
+	}
+	for shardID, session := range shards.sessions {
+		if session.ShardID != shardID || session.ShardCount != 16 || session.Identify.Intents != discordgo.IntentsGuildMembers {
+			t.Errorf("unexpected shard %v: %v of %v with intents %v", shardID, session.ShardID, session.ShardCount, session.Identify.Intents)
+		}
+	}
+	if session := shards.Session("41771983423143937"); session.ShardID != 6 {
+		t.Errorf("expected the guild's requests to use shard 6, got %v", session.ShardID)
+	}
+}
+
+func TestShardsHeartbeatAge(t *testing.T) {
+	shards, err := NewShards("token", 2, discordgo.IntentsGuildMembers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards.sessions[0].LastHeartbeatAck = time.Now().Add(-time.Minute)
+	shards.sessions[1].LastHeartbeatAck = time.Now().Add(-10 * time.Minute)
+	if age := shards.HeartbeatAge(); age < 10*time.Minute || age > 11*time.Minute {
+		t.Errorf("expected the oldest heartbeat to be 10 minutes old, got %v", age)
+	}
+}
@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// MemberCount returns the number of known members
+func (g *Guild) MemberCount() int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return len(g.state)
+}
+
+type periodStats struct {
+	days   int
+	joins  int
+	leaves int
+}
+
+func (p periodStats) net() int {
+	return p.joins - p.leaves
+}
+
+// churn is the fraction of members present at the start of the period that left during it
+func (p periodStats) churn(memberCount int) float64 {
+	startCount := memberCount - p.net()
+	if startCount <= 0 {
+		return 0
+	}
+	return float64(p.leaves) / float64(startCount)
+}
+
+func (b *Bot) commandStats(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	lang := g.language()
+	memberCount := g.MemberCount()
+	now := time.Now()
+
+	embed := &discordgo.MessageEmbed{
+		Title: lang.Translate("Member Stats"),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: lang.Translate("Members"), Value: fmt.Sprint(memberCount)},
+		},
+		Timestamp: now.Format(time.RFC3339),
+	}
+
+	for _, days := range []int{7, 30} {
+		since := now.AddDate(0, 0, -days)
+		period := periodStats{days: days}
+		var err error
+		if period.joins, err = b.store.CountEvents(g.ID, store.EventJoin, since); err == nil {
+			period.leaves, err = b.store.CountEvents(g.ID, store.EventLeave, since)
+		}
+		if err != nil {
+			log.Printf("failed to count events of the last %v days: %v", days, err)
+			return textResponse(lang.Translate("Failed to load stats, check the logs."))
+		}
+
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: lang.Sprintf("Last %v days", period.days),
+			Value: lang.Sprintf(
+				"Joins: %v\nLeaves: %v\nNet growth: %+d\nChurn: %.1f%%",
+				period.joins,
+				period.leaves,
+				period.net(),
+				period.churn(memberCount)*100,
+			),
+			Inline: true,
+		})
+	}
+
+	if b.options.TrackFirstMessages {
+		messages, err := b.store.FirstMessages(g.ID, now.AddDate(0, 0, -30))
+		if err != nil {
+			log.Printf("failed to load the first messages of the last 30 days: %v", err)
+			return textResponse(lang.Translate("Failed to load stats, check the logs."))
+		}
+		onboarding := newOnboardingStats(messages)
+		value := lang.Sprintf(
+			"Tracked joins: %v\nPosted: %v (%.1f%%)\nNever posted: %v",
+			onboarding.joined,
+			onboarding.posted,
+			onboarding.postedRate()*100,
+			onboarding.joined-onboarding.posted,
+		)
+		if onboarding.posted > 0 {
+			value += "\n" + lang.Sprintf("Median time to first post: %v", lang.FormatDuration(onboarding.median))
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  lang.Translate("Onboarding, last 30 days"),
+			Value: value,
+		})
+	}
+
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{embed},
+	}
+}
@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestPeriodStatsChurn(t *testing.T) {
+	// 100 members at the start, 10 left and 20 joined
+	period := periodStats{days: 30, joins: 20, leaves: 10}
+	if net := period.net(); net != 10 {
+		t.Errorf("expected net growth of 10, got %v", net)
+	}
+	if churn := period.churn(110); churn != 0.1 {
+		t.Errorf("expected churn of 0.1, got %v", churn)
+	}
+	if churn := (periodStats{joins: 5}).churn(5); churn != 0 {
+		t.Errorf("expected no churn for a new guild, got %v", churn)
+	}
+}
+
+func TestRetentionResponse(t *testing.T) {
+	st := openTestStore(t)
+	g := newTestGuild(t, st, newFakeSession())
+	now := time.Date(2023, 7, 20, 12, 0, 0, 0, time.UTC)
+	for _, event := range []store.HistoryEvent{
+		{GuildID: testGuildID, DiscordID: "1", Event: store.EventJoin, At: now.AddDate(0, -1, 0)},
+		{GuildID: testGuildID, DiscordID: "2", Event: store.EventJoin, At: now.AddDate(0, -1, 0)},
+		{GuildID: testGuildID, DiscordID: "2", Event: store.EventLeave, At: now.AddDate(0, -1, 3)},
+	} {
+		if err := st.RecordEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	response := g.bot.retentionResponse(g, now)
+	if len(response.Embeds) != 1 {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	values := []string{}
+	for _, field := range response.Embeds[0].Fields {
+		values = append(values, field.Value)
+	}
+	expected := []string{"50.0% (1 of 2)", "50.0% (1 of 2)", "3 days (1 left)", "2023-02: 0 of 0\n2023-03: 0 of 0\n2023-04: 0 of 0\n2023-05: 0 of 0\n2023-06: 1 of 2\n2023-07: 0 of 0\n"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("unexpected fields %q", values)
+	}
+}
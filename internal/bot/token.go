@@ -0,0 +1,34 @@
+package bot
+
+import (
+	"errors"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func (b *Bot) commandReloadToken(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	log.Printf("[token] reload requested by '%v' in guild '%v'", i.Member.User.ID, g.ID)
+	return b.reloadToken(g, i.Member.User.ID)
+}
+
+// reloadToken switches to a rotated token, the shards reconnect after the response is sent.
+// The token is shared by every guild, so only the bot owner may reload it, not any guild's admins.
+func (b *Bot) reloadToken(g *Guild, userID string) *discordgo.InteractionResponseData {
+	lang := g.language()
+	if b.options.ReloadToken == nil {
+		return textResponse(lang.Translate("Token reloading is off."))
+	}
+	if b.options.OwnerID == "" || userID != b.options.OwnerID {
+		return textResponse(lang.Translate("Only the bot owner can reload the token."))
+	}
+	err := b.options.ReloadToken()
+	if errors.Is(err, ErrTokenUnchanged) {
+		return textResponse(lang.Translate("The token didn't change."))
+	}
+	if err != nil {
+		log.Printf("failed to reload the token: %v", err)
+		return textResponse(lang.Translate("Failed to reload the token, check the logs."))
+	}
+	return textResponse(lang.Translate("Switched to the new token, reconnecting."))
+}
@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// veteransPageSize is how many members a page of /userlog veterans lists
+const veteransPageSize = 10
+
+func (b *Bot) commandVeterans(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	return b.veteransPage(g, 0)
+}
+
+// componentVeterans handles the page buttons, with custom IDs like "userlog:veterans:<page>"
+func (b *Bot) componentVeterans(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, args []string) *discordgo.InteractionResponseData {
+	if len(args) != 1 {
+		return textResponse(g.language().Translate("Unknown page."))
+	}
+	page, err := strconv.Atoi(args[0])
+	if err != nil || page < 0 {
+		return textResponse(g.language().Translate("Unknown page."))
+	}
+	return b.veteransPage(g, page)
+}
+
+// veteran is a current member with a known join date
+type veteran struct {
+	discordID string
+	member    store.Member
+}
+
+// veterans returns the current members with a known join date, longest-standing first
+func (g *Guild) veterans() []veteran {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	veterans := make([]veteran, 0, len(g.state))
+	for discordID, member := range g.state {
+		if !member.JoinedAt.IsZero() {
+			veterans = append(veterans, veteran{discordID, member})
+		}
+	}
+	sort.Slice(veterans, func(i, j int) bool {
+		if !veterans[i].member.JoinedAt.Equal(veterans[j].member.JoinedAt) {
+			return veterans[i].member.JoinedAt.Before(veterans[j].member.JoinedAt)
+		}
+		return veterans[i].discordID < veterans[j].discordID
+	})
+	return veterans
+}
+
+func (b *Bot) veteransPage(g *Guild, page int) *discordgo.InteractionResponseData {
+	lang := g.language()
+	veterans := g.veterans()
+	start := page * veteransPageSize
+	if start > len(veterans) {
+		start = len(veterans)
+	}
+	end := start + veteransPageSize
+	if end > len(veterans) {
+		end = len(veterans)
+	}
+
+	var description strings.Builder
+	for rank, veteran := range veterans[start:end] {
+		joinedAt := veteran.member.JoinedAt.Unix()
+		fmt.Fprintf(&description, "**%v.** <@%v>", start+rank+1, veteran.discordID)
+		if tag := veteran.member.User.Tag(); tag != "" {
+			fmt.Fprintf(&description, " (%v)", tag)
+		}
+		fmt.Fprintf(&description, " <t:%v:D> (<t:%v:R>)\n", joinedAt, joinedAt)
+	}
+	if start == end {
+		description.WriteString(lang.Translate("Nothing here."))
+	}
+
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       lang.Translate("Longest-Standing Members"),
+			Description: description.String(),
+			Footer:      &discordgo.MessageEmbedFooter{Text: lang.Sprintf("Page %v", page+1)},
+		}},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    lang.Translate("Previous"),
+						Style:    discordgo.SecondaryButton,
+						CustomID: fmt.Sprintf("%v:veterans:%v", userlogCommand.Name, page-1),
+						Disabled: page == 0,
+					},
+					discordgo.Button{
+						Label:    lang.Translate("Next"),
+						Style:    discordgo.SecondaryButton,
+						CustomID: fmt.Sprintf("%v:veterans:%v", userlogCommand.Name, page+1),
+						Disabled: end >= len(veterans),
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestVeteransPage(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	g := newTestGuild(t, st, session)
+	joinedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	// newer members first, so the order comes from the join dates
+	for n := veteransPageSize; n >= 0; n-- {
+		g.memberAdded(fmt.Sprint(100+n), store.Member{User: store.User{Username: "user", Discriminator: "0"}, JoinedAt: joinedAt.AddDate(0, 0, n)})
+	}
+	g.memberAdded("999", store.Member{User: store.User{Username: "unknown", Discriminator: "0"}})
+
+	first := g.bot.veteransPage(g, 0)
+	lines := strings.Split(strings.TrimSpace(first.Embeds[0].Description), "\n")
+	if len(lines) != veteransPageSize {
+		t.Fatalf("expected a full page, got %q", lines)
+	}
+	if expected := fmt.Sprintf("**1.** <@100> (user) <t:%v:D> (<t:%v:R>)", joinedAt.Unix(), joinedAt.Unix()); lines[0] != expected {
+		t.Errorf("expected the oldest member first, got %q", lines[0])
+	}
+	buttons := first.Components[0].(discordgo.ActionsRow).Components
+	if !buttons[0].(discordgo.Button).Disabled || buttons[1].(discordgo.Button).Disabled {
+		t.Errorf("expected only the next page button on the first page, got %+v", buttons)
+	}
+
+	// members without a join date are left out
+	second := g.bot.veteransPage(g, 1)
+	if description := second.Embeds[0].Description; !strings.HasPrefix(description, fmt.Sprintf("**%v.** <@%v>", veteransPageSize+1, 100+veteransPageSize)) || strings.Contains(description, "999") {
+		t.Errorf("unexpected second page %q", description)
+	}
+	buttons = second.Components[0].(discordgo.ActionsRow).Components
+	if buttons[0].(discordgo.Button).Disabled || !buttons[1].(discordgo.Button).Disabled {
+		t.Errorf("expected only the previous page button on the last page, got %+v", buttons)
+	}
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// voiceEvents are logged to the voice log channel instead of being announced
+var voiceEvents = map[string]bool{
+	store.EventVoiceJoin:  true,
+	store.EventVoiceLeave: true,
+	store.EventVoiceMove:  true,
+}
+
+// voiceDetails are stored with voice history events, From is empty for joins and To for leaves
+type voiceDetails struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+func (b *Bot) voiceStateUpdate(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+	if v.VoiceState == nil {
+		return
+	}
+	g, ok := b.guilds[v.GuildID]
+	if !ok {
+		return
+	}
+	// the state cache knows the previous channel if it saw the guild's voice states, or an earlier update
+	before := ""
+	if v.BeforeUpdate != nil {
+		before = v.BeforeUpdate.ChannelID
+	}
+	g.voiceStateUpdated(v.UserID, before, v.ChannelID)
+}
+
+// voiceStateUpdated records and logs a member joining, leaving, or moving between voice channels, if voice logging is enabled.
+// Mutes, deafens, and other changes within a channel are ignored.
+func (g *Guild) voiceStateUpdated(discordID, before, after string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed || g.voice == nil || before == after {
+		return
+	}
+
+	event := notify.Event{
+		UserID:       discordID,
+		User:         g.state[discordID].User,
+		ChannelID:    after,
+		OldChannelID: before,
+	}
+	switch {
+	case before == "":
+		event.Type = store.EventVoiceJoin
+	case after == "":
+		event.Type = store.EventVoiceLeave
+		event.ChannelID, event.OldChannelID = before, ""
+	default:
+		event.Type = store.EventVoiceMove
+	}
+	defer g.transactionLocked()()
+	g.eventLocked(event, voiceDetails{From: before, To: after})
+}
+
+// logsVoice returns whether voice logging is enabled
+func (g *Guild) logsVoice() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.voice != nil
+}
+
+// logVoiceLocked sends a voice event to the voice log channel, unless the user is ignored
+func (g *Guild) logVoiceLocked(event notify.Event) error {
+	if _, ignored := g.ignored[event.UserID]; ignored {
+		log.Printf("not logging voice activity of ignored user '%v'", event.UserID)
+		return nil
+	}
+	return g.queueLocked(event, false)
+}
@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestVoiceLog(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	templates, err := notify.ParseTemplates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{
+		IgnoredUsers: []string{"2"},
+		Voice:        notify.NewChannel(session, "voice", templates),
+	})
+	g.syncMembersFromServer(context.Background(), session)
+
+	g.voiceStateUpdated("1", "", "10")
+	// muting doesn't change the channel
+	g.voiceStateUpdated("1", "10", "10")
+	g.voiceStateUpdated("1", "10", "11")
+	g.voiceStateUpdated("1", "11", "")
+	g.voiceStateUpdated("2", "", "10")
+
+	sent := []string{}
+	for _, message := range session.takeSent() {
+		if message.channelID != "voice" {
+			t.Errorf("message %q sent to channel %v, expected the voice log", message.content, message.channelID)
+		}
+		sent = append(sent, message.content)
+	}
+	expected := []string{
+		"🔊 <@1> (alice) joined <#10>",
+		"🔀 <@1> (alice) moved from <#10> to <#11>",
+		"🔇 <@1> (alice) left <#11>",
+	}
+	if !reflect.DeepEqual(sent, expected) {
+		t.Errorf("sent %q, expected %q", sent, expected)
+	}
+
+	history, err := st.UserHistory(testGuildID, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	details := []string{}
+	for _, event := range history {
+		if voiceEvents[event.Event] {
+			details = append(details, event.Event+" "+event.Details)
+		}
+	}
+	expected = []string{
+		store.EventVoiceJoin + ` {"to":"10"}`,
+		store.EventVoiceMove + ` {"from":"10","to":"11"}`,
+		store.EventVoiceLeave + ` {"from":"11"}`,
+	}
+	if !reflect.DeepEqual(details, expected) {
+		t.Errorf("recorded %q, expected %q", details, expected)
+	}
+
+	// without a voice log channel, voice activity isn't recorded either
+	g.Configure(GuildOptions{Notifier: g.notifier})
+	g.voiceStateUpdated("1", "", "10")
+	assertSent(t, session)
+}
@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// watchListMaxLength keeps the list within Discord's embed description limit
+const watchListMaxLength = 4000
+
+// announceOrAlertLocked announces an event, or sends a watched user alert of watchedType instead if the user is watched
+func (g *Guild) announceOrAlertLocked(event notify.Event, watchedType string) error {
+	if _, watched := g.watched[event.UserID]; !watched {
+		return g.announceLocked(event)
+	}
+	event.Type = watchedType
+	return g.watchAlertLocked(event)
+}
+
+// watchAlertLocked alerts moderators about a watched user, skipping quiet hours and the ignore list
+func (g *Guild) watchAlertLocked(event notify.Event) error {
+	event.PingRoleID = g.watchRoleID
+	g.publishLocked(&event)
+	log.Printf("alerting about watched user '%v': %v", event.UserID, event.Type)
+	return g.queueLocked(event, true)
+}
+
+// renamedLocked records username and nickname changes in the history and announces them,
+// or alerts about them if the user is watched
+func (g *Guild) renamedLocked(discordID string, before, after store.Member) {
+	now := time.Now()
+	for _, names := range []struct{ kind, before, after string }{
+		{"username", before.User.Tag(), after.User.Tag()},
+		{"nickname", before.Nick, after.Nick},
+	} {
+		if names.before == names.after {
+			continue
+		}
+		g.recordLocked(store.HistoryEvent{
+			DiscordID: discordID,
+			Event:     store.EventRename,
+			User:      after.User,
+			At:        now,
+			Details:   encodeDetails(store.EventRename, renameDetails{Kind: names.kind, Old: names.before, New: names.after}),
+		})
+		event := notify.Event{
+			Type:        store.EventRename,
+			GuildID:     g.ID,
+			UserID:      discordID,
+			User:        after.User,
+			At:          now,
+			MemberCount: len(g.state),
+			NameKind:    names.kind,
+			OldName:     names.before,
+			NewName:     names.after,
+		}
+		g.publishLocked(&event)
+		if err := g.announceOrAlertLocked(event, notify.EventWatchedRename); err != nil {
+			sendFailed(err, "failed to send message about '%v' changing their %v", discordID, names.kind)
+		}
+	}
+}
+
+// setWatched adds or removes a user from the in-memory watch list
+func (g *Guild) setWatched(discordID string, watched bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if watched {
+		g.watched[discordID] = struct{}{}
+	} else {
+		delete(g.watched, discordID)
+	}
+}
+
+func (b *Bot) commandWatch(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	return b.watch(g, options[0].UserValue(nil).ID, i.Member.User.ID)
+}
+
+func (b *Bot) watch(g *Guild, discordID, moderatorID string) *discordgo.InteractionResponseData {
+	added, err := b.store.WatchUser(g.ID, discordID, moderatorID, time.Now())
+	if err != nil {
+		log.Printf("failed to watch '%v': %v", discordID, err)
+		return textResponse(g.language().Translate("Failed to watch the user, check the logs."))
+	}
+	g.setWatched(discordID, true)
+	if !added {
+		return textResponse(g.language().Sprintf("<@%v> is already watched.", discordID))
+	}
+	log.Printf("[watch] '%v' watched by '%v'", discordID, moderatorID)
+	return textResponse(g.language().Sprintf("Watching <@%v>, their joins, leaves, and renames will be sent as alerts.", discordID))
+}
+
+func (b *Bot) commandUnwatch(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	return b.unwatch(g, options[0].UserValue(nil).ID)
+}
+
+func (b *Bot) unwatch(g *Guild, discordID string) *discordgo.InteractionResponseData {
+	removed, err := b.store.UnwatchUser(g.ID, discordID)
+	if err != nil {
+		log.Printf("failed to unwatch '%v': %v", discordID, err)
+		return textResponse(g.language().Translate("Failed to unwatch the user, check the logs."))
+	}
+	g.setWatched(discordID, false)
+	if !removed {
+		return textResponse(g.language().Sprintf("<@%v> isn't watched.", discordID))
+	}
+	return textResponse(g.language().Sprintf("Stopped watching <@%v>.", discordID))
+}
+
+func (b *Bot) commandWatchlist(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	return b.watchlist(g)
+}
+
+func (b *Bot) watchlist(g *Guild) *discordgo.InteractionResponseData {
+	lang := g.language()
+	watched, err := b.store.WatchedUsers(g.ID)
+	if err != nil {
+		log.Printf("failed to load watched users: %v", err)
+		return textResponse(lang.Translate("Failed to load the watch list, check the logs."))
+	}
+	if len(watched) == 0 {
+		return textResponse(lang.Translate("Nobody is watched."))
+	}
+
+	var description strings.Builder
+	for _, user := range watched {
+		line := lang.Sprintf("<@%v> by <@%v> <t:%v:d>", user.DiscordID, user.AddedBy, user.AddedAt.Unix()) + "\n"
+		if description.Len()+len(line) > watchListMaxLength {
+			description.WriteString("…\n")
+			break
+		}
+		description.WriteString(line)
+	}
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       lang.Translate("Watched Users"),
+			Description: description.String(),
+		}},
+	}
+}
@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestWatchedUsers(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"), member("2", "bob", "0"))
+	g := newTestGuildWithGuildOptions(t, st, session, Options{}, GuildOptions{WatchRoleID: "300"})
+	g.syncMembersFromServer(context.Background(), session)
+
+	if response := g.bot.watch(g, "1", "99"); !strings.HasPrefix(response.Content, "Watching <@1>") {
+		t.Errorf("unexpected watch response %+v", response)
+	}
+	if response := g.bot.watch(g, "1", "99"); response.Content != "<@1> is already watched." {
+		t.Errorf("unexpected repeated watch response %+v", response)
+	}
+
+	// watched users get alerts instead of announcements, unwatched users are announced as usual
+	g.memberUpdated("1", store.Member{User: store.User{Username: "alice", Discriminator: "0"}, Nick: "Al"})
+	g.memberRemoved("1")
+	g.memberRemoved("2")
+	g.memberAdded("1", store.Member{User: store.User{Username: "alice", Discriminator: "0"}})
+	assertSent(t, session,
+		"<@&300> 👀 Watched user <@1> changed their nickname from `nothing` to `Al`",
+		"<@&300> 👀 Watched user <@1> (alice) left the server",
+		"<@2> (bob) left the server",
+		"<@&300> 👀 Watched user <@1> (alice) joined the server",
+	)
+
+	// the watch list survives restarts
+	reloaded := newTestGuild(t, st, session)
+	if _, watched := reloaded.watched["1"]; !watched {
+		t.Errorf("expected the watch list to be loaded")
+	}
+
+	if response := g.bot.watchlist(g); len(response.Embeds) != 1 || !strings.HasPrefix(response.Embeds[0].Description, "<@1> by <@99>") {
+		t.Errorf("unexpected watch list %+v", response)
+	}
+	if response := g.bot.unwatch(g, "1"); response.Content != "Stopped watching <@1>." {
+		t.Errorf("unexpected unwatch response %+v", response)
+	}
+	g.memberRemoved("1")
+	assertSent(t, session, "<@1> (alice) left the server")
+	if response := g.bot.watchlist(g); response.Content != "Nobody is watched." {
+		t.Errorf("unexpected empty watch list %+v", response)
+	}
+}
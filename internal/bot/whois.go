@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/i18n"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// whoisMaxFieldLength is Discord's embed field value limit
+const whoisMaxFieldLength = 1024
+
+func (b *Bot) commandWhois(s *discordgo.Session, i *discordgo.InteractionCreate, g *Guild, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionResponseData {
+	discordID := options[0].UserValue(nil).ID
+	return b.whoisResponse(g, discordID)
+}
+
+// member returns the known state of a current member
+func (g *Guild) member(discordID string) (store.Member, bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	member, ok := g.state[discordID]
+	return member, ok
+}
+
+func (b *Bot) whoisResponse(g *Guild, discordID string) *discordgo.InteractionResponseData {
+	lang := g.language()
+	history, err := b.store.UserHistory(g.ID, discordID)
+	if err != nil {
+		log.Printf("failed to load the history of '%v': %v", discordID, err)
+		return textResponse(lang.Translate("Failed to look up the user, check the logs."))
+	}
+	names, err := b.store.Names(g.ID, discordID)
+	if err != nil {
+		log.Printf("failed to load names of '%v': %v", discordID, err)
+		return textResponse(lang.Translate("Failed to look up the user, check the logs."))
+	}
+	notes, err := b.store.Notes(g.ID, discordID)
+	if err != nil {
+		log.Printf("failed to load the notes about '%v': %v", discordID, err)
+		return textResponse(lang.Translate("Failed to look up the user, check the logs."))
+	}
+	member, isMember := g.member(discordID)
+	if len(history) == 0 && len(names) == 0 && len(notes) == 0 && !isMember {
+		return textResponse(lang.Sprintf("Nothing is known about <@%v>.", discordID))
+	}
+
+	var firstSeen, lastSeen time.Time
+	seen := func(from, to time.Time) {
+		if firstSeen.IsZero() || from.Before(firstSeen) {
+			firstSeen = from
+		}
+		if to.After(lastSeen) {
+			lastSeen = to
+		}
+	}
+	user := member.User
+	joins, leaves := 0, 0
+	var lastJoin, lastLeave *store.HistoryEvent
+	for i, event := range history {
+		seen(event.At, event.At)
+		switch event.Event {
+		case store.EventJoin:
+			joins++
+			if lastJoin == nil || event.At.After(lastJoin.At) {
+				lastJoin = &history[i]
+			}
+		case store.EventLeave:
+			leaves++
+			lastLeave = &history[i]
+		}
+		if !isMember && event.User.Username != "" {
+			user = event.User
+		}
+	}
+	for _, name := range names {
+		seen(name.FirstSeen, name.LastSeen)
+	}
+	if isMember && !member.JoinedAt.IsZero() {
+		seen(member.JoinedAt, member.JoinedAt)
+	}
+
+	status := lang.Translate("Not a member")
+	if isMember {
+		status = lang.Translate("Member")
+		if !member.JoinedAt.IsZero() {
+			status = lang.Sprintf("Member since <t:%v:D>", member.JoinedAt.Unix())
+		}
+	}
+	lastSeenText := lang.Translate("Still here")
+	if !isMember {
+		lastSeenText = fmt.Sprintf("<t:%v:f>", lastSeen.Unix())
+	}
+	rolesText := lang.Translate("None recorded")
+	if lastLeave != nil && lastLeave.Details != "" {
+		var details leaveDetails
+		if err := json.Unmarshal([]byte(lastLeave.Details), &details); err != nil {
+			log.Printf("failed to decode the leave details of '%v': %v", discordID, err)
+		} else if len(details.Roles) > 0 {
+			mentions := make([]string, len(details.Roles))
+			for i, roleID := range details.Roles {
+				mentions[i] = "<@&" + roleID + ">"
+			}
+			rolesText = strings.Join(mentions, " ")
+		}
+	}
+
+	description := fmt.Sprintf("<@%v>", discordID)
+	if tag := user.Tag(); tag != "" {
+		description += fmt.Sprintf(" (%v)", tag)
+	}
+	fields := []*discordgo.MessageEmbedField{
+		{Name: lang.Translate("Status"), Value: status, Inline: true},
+		{Name: lang.Translate("First seen"), Value: fmt.Sprintf("<t:%v:f>", firstSeen.Unix()), Inline: true},
+		{Name: lang.Translate("Last seen"), Value: lastSeenText, Inline: true},
+		{Name: lang.Translate("Joins / leaves"), Value: fmt.Sprintf("%v / %v", joins, leaves), Inline: true},
+		{Name: lang.Translate("Roles at last leave"), Value: rolesText, Inline: true},
+	}
+	if reason, ok, err := b.store.LeaveReason(g.ID, discordID); err != nil {
+		log.Printf("failed to load the leave reason of '%v': %v", discordID, err)
+	} else if ok {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: lang.Translate("Reason for last leave"), Value: reason.Reason, Inline: true})
+	}
+	if b.options.TrackFirstMessages {
+		if message, ok, err := b.store.FirstMessage(g.ID, discordID); err != nil {
+			log.Printf("failed to load the first message of '%v': %v", discordID, err)
+		} else if ok {
+			fields = append(fields, &discordgo.MessageEmbedField{Name: lang.Translate("First message"), Value: firstMessageText(lang, message), Inline: true})
+		}
+	}
+	if b.options.TrackInvites && lastJoin != nil {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: lang.Translate("Invite used"), Value: inviteText(lang, *lastJoin), Inline: true})
+	}
+	for _, section := range []struct{ kind, title string }{
+		{store.NameUsername, "Usernames"},
+		{store.NameNick, "Nicknames"},
+	} {
+		var value strings.Builder
+		for _, name := range names {
+			if name.Kind != section.kind {
+				continue
+			}
+			line := "`" + strings.ReplaceAll(name.Name, "`", "'") + "`\n"
+			if value.Len()+len(line) > whoisMaxFieldLength-len("…") {
+				value.WriteString("…")
+				break
+			}
+			value.WriteString(line)
+		}
+		if value.Len() > 0 {
+			fields = append(fields, &discordgo.MessageEmbedField{Name: lang.Translate(section.title), Value: value.String()})
+		}
+	}
+	if len(notes) > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: lang.Translate("Notes"), Value: notesText(lang, notes)})
+	}
+
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       lang.Translate("User Lookup"),
+			Description: description,
+			Fields:      fields,
+		}},
+	}
+}
+
+// inviteText describes the invite of a join, if it is known
+func inviteText(lang *i18n.Language, join store.HistoryEvent) string {
+	var details joinDetails
+	if join.Details != "" {
+		if err := json.Unmarshal([]byte(join.Details), &details); err != nil {
+			log.Printf("failed to decode the join details of '%v': %v", join.DiscordID, err)
+		}
+	}
+	switch {
+	case details.Invite == "":
+		return lang.Translate("Unknown")
+	case details.InviterID == "":
+		return "`" + details.Invite + "`"
+	}
+	return lang.Sprintf("`%v` by <@%v>", details.Invite, details.InviterID)
+}
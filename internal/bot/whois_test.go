@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestWhoisResponse(t *testing.T) {
+	st := openTestStore(t)
+	session := newFakeSession()
+	session.setMembers(testGuildID, member("1", "alice", "0"))
+	g := newTestGuild(t, st, session)
+	g.syncMembersFromServer(context.Background(), session)
+
+	joinedAt := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	g.memberAdded("2", store.Member{User: store.User{Username: "bob", Discriminator: "0"}, JoinedAt: joinedAt, Roles: []string{"7", "8"}, Nick: "bobby"})
+	g.memberRemoved("2")
+
+	noteAt := time.Now().Unix()
+	if response := g.bot.addNote(g, "2", "mod", "raided with alts"); response.Content != "Noted about <@2>." {
+		t.Errorf("unexpected note response %+v", response)
+	}
+	if response := g.bot.addNote(g, "2", "mod", "  "); response.Content != "The note is empty." {
+		t.Errorf("expected an empty note to be rejected, got %+v", response)
+	}
+
+	response := g.bot.whoisResponse(g, "2")
+	embed := response.Embeds[0]
+	if embed.Description != "<@2> (bob)" {
+		t.Errorf("unexpected description %q", embed.Description)
+	}
+	fields := map[string]string{}
+	for _, field := range embed.Fields {
+		fields[field.Name] = field.Value
+	}
+	for name, expected := range map[string]string{
+		"Status":              "Not a member",
+		"First seen":          fmt.Sprintf("<t:%v:f>", joinedAt.Unix()),
+		"Joins / leaves":      "1 / 1",
+		"Roles at last leave": "<@&7> <@&8>",
+		"Usernames":           "`bob`\n",
+		"Nicknames":           "`bobby`\n",
+		"Notes":               fmt.Sprintf("<t:%v:d> by <@mod>: raided with alts\n", noteAt),
+	} {
+		if fields[name] != expected {
+			t.Errorf("expected %v %q, got %q", name, expected, fields[name])
+		}
+	}
+
+	if response := g.bot.whoisResponse(g, "1"); response.Embeds[0].Fields[0].Value != "Member" || response.Embeds[0].Fields[2].Value != "Still here" {
+		t.Errorf("unexpected current member %+v", response.Embeds[0].Fields)
+	}
+	if response := g.bot.whoisResponse(g, "3"); response.Content != "Nothing is known about <@3>." {
+		t.Errorf("unexpected unknown user %+v", response)
+	}
+}
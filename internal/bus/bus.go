@@ -0,0 +1,133 @@
+// Package bus delivers the events of every guild to the consumers registered at startup,
+// like the event stream, push notifications, brokers, and webhooks, each of which can be turned off or filtered by name.
+// Announcements and the history aren't consumers: they are written in the transaction of the member event.
+package bus
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/telemetry"
+)
+
+// Consumer receives events, it must not block for long.
+// Events are published in the transaction recording them, so consumers must not write to the database right away.
+type Consumer interface {
+	Publish(event notify.Event)
+}
+
+var publishDuration = telemetry.NewHistogram("user_log.bus.publish.duration", "s", "Duration of handing events to consumers", telemetry.DurationBounds)
+
+// Filter selects the events a consumer receives
+type Filter interface {
+	Match(event notify.Event) bool
+}
+
+// Filtered wraps a consumer to only receive the events the filter matches
+func Filtered(filter Filter, consumer Consumer) Consumer {
+	return filtered{filter, consumer}
+}
+
+type filtered struct {
+	filter   Filter
+	consumer Consumer
+}
+
+func (f filtered) Publish(event notify.Event) {
+	if f.filter.Match(event) {
+		f.consumer.Publish(event)
+	}
+}
+
+type registration struct {
+	name     string
+	consumer Consumer
+}
+
+// Bus publishes events to its enabled consumers in the order they were registered
+type Bus struct {
+	lock      sync.RWMutex
+	consumers []registration
+	disabled  map[string]struct{}
+	filters   map[string]Filter
+}
+
+// New creates a bus without consumers
+func New() *Bus {
+	return &Bus{disabled: map[string]struct{}{}, filters: map[string]Filter{}}
+}
+
+// Register adds a consumer, several consumers can share a name to be turned off together
+func (b *Bus) Register(name string, consumer Consumer) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.consumers = append(b.consumers, registration{name, consumer})
+}
+
+// Disable turns off the consumers with the given names, turning the others back on
+func (b *Bus) Disable(names []string) {
+	disabled := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		disabled[name] = struct{}{}
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.disabled = disabled
+}
+
+// SetFilters limits the consumers with the given names to the events their filter matches, replacing the previous filters
+func (b *Bus) SetFilters(filters map[string]Filter) {
+	copied := make(map[string]Filter, len(filters))
+	for name, filter := range filters {
+		copied[name] = filter
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.filters = copied
+}
+
+// Enabled lists the names of the enabled consumers, once each
+func (b *Bus) Enabled() []string {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	names := []string{}
+	seen := map[string]struct{}{}
+	for _, registered := range b.consumers {
+		_, disabled := b.disabled[registered.name]
+		_, duplicate := seen[registered.name]
+		if !disabled && !duplicate {
+			names = append(names, registered.name)
+			seen[registered.name] = struct{}{}
+		}
+	}
+	return names
+}
+
+// Publish hands an event to every enabled consumer whose filter matches it.
+// A consumer that panics is logged and skipped, so one broken integration doesn't take down the others.
+func (b *Bus) Publish(event notify.Event) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	for _, registered := range b.consumers {
+		if _, disabled := b.disabled[registered.name]; disabled {
+			continue
+		}
+		if filter, ok := b.filters[registered.name]; ok && !filter.Match(event) {
+			continue
+		}
+		b.deliver(registered, event)
+	}
+}
+
+func (b *Bus) deliver(registered registration, event notify.Event) {
+	started := time.Now()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("[bus] consumer '%v' panicked on '%v' event: %v", registered.name, event.Type, recovered)
+		}
+		publishDuration.Record(time.Since(started).Seconds(), telemetry.String("consumer", registered.name))
+	}()
+	registered.consumer.Publish(event)
+}
@@ -0,0 +1,87 @@
+package bus
+
+import (
+	"reflect"
+	"testing"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+type recorder struct {
+	events *[]string
+	name   string
+}
+
+func (r recorder) Publish(event notify.Event) {
+	*r.events = append(*r.events, r.name+":"+event.Type)
+}
+
+type panicking struct{}
+
+func (panicking) Publish(event notify.Event) {
+	panic("broken")
+}
+
+func TestBus(t *testing.T) {
+	received := []string{}
+	b := New()
+	b.Register("mqtt", recorder{&received, "mqtt"})
+	b.Register("broken", panicking{})
+	b.Register("webhooks", recorder{&received, "first"})
+	b.Register("webhooks", recorder{&received, "second"})
+
+	b.Publish(notify.Event{Type: store.EventJoin})
+	if expected := []string{"mqtt:join", "first:join", "second:join"}; !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected %v past the panicking consumer, got %v", expected, received)
+	}
+	if enabled := b.Enabled(); !reflect.DeepEqual(enabled, []string{"mqtt", "broken", "webhooks"}) {
+		t.Errorf("unexpected enabled consumers %v", enabled)
+	}
+
+	received = received[:0]
+	b.Disable([]string{"webhooks", "broken"})
+	b.Publish(notify.Event{Type: store.EventLeave})
+	if expected := []string{"mqtt:leave"}; !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected only %v, got %v", expected, received)
+	}
+
+	// disabling again replaces the disabled consumers
+	received = received[:0]
+	b.Disable([]string{"mqtt"})
+	b.Publish(notify.Event{Type: store.EventLeave})
+	if expected := []string{"first:leave", "second:leave"}; !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected %v, got %v", expected, received)
+	}
+}
+
+type typeFilter string
+
+func (f typeFilter) Match(event notify.Event) bool {
+	return event.Type == string(f)
+}
+
+func TestBusFilters(t *testing.T) {
+	received := []string{}
+	b := New()
+	b.Register("mqtt", recorder{&received, "mqtt"})
+	b.Register("webhooks", Filtered(typeFilter(store.EventBan), recorder{&received, "bans"}))
+	b.Register("webhooks", recorder{&received, "all"})
+
+	b.SetFilters(map[string]Filter{"mqtt": typeFilter(store.EventLeave)})
+	for _, eventType := range []string{store.EventJoin, store.EventLeave, store.EventBan} {
+		b.Publish(notify.Event{Type: eventType})
+	}
+	expected := []string{"all:join", "mqtt:leave", "all:leave", "bans:ban", "all:ban"}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected %v, got %v", expected, received)
+	}
+
+	// setting filters again replaces them
+	received = received[:0]
+	b.SetFilters(map[string]Filter{"webhooks": typeFilter(store.EventJoin)})
+	b.Publish(notify.Event{Type: store.EventJoin})
+	if expected := []string{"mqtt:join", "all:join"}; !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected %v, got %v", expected, received)
+	}
+}
@@ -0,0 +1,63 @@
+package feed
+
+import (
+	"log"
+	"sync"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+)
+
+// subscriberBuffer is how many events a subscriber can fall behind before events are dropped for it
+const subscriberBuffer = 64
+
+// Broker fans out events to live subscribers, like /events streams
+type Broker struct {
+	lock        sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	// guildID limits the subscription to one guild, empty means all guilds
+	guildID string
+	events  chan []byte
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[*subscriber]struct{}{}}
+}
+
+// Publish sends an event to the current subscribers without blocking, slow subscribers miss it
+func (b *Broker) Publish(event notify.Event) {
+	encoded, err := Encode(event)
+	if err != nil {
+		log.Printf("[feed] failed to encode '%v' event: %v", event.Type, err)
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for sub := range b.subscribers {
+		if sub.guildID != "" && sub.guildID != event.GuildID {
+			continue
+		}
+		select {
+		case sub.events <- encoded:
+		default:
+			log.Printf("[feed] dropped '%v' event for a slow subscriber", event.Type)
+		}
+	}
+}
+
+// Subscribe receives the JSON of events published from now on, until cancel is called
+func (b *Broker) Subscribe(guildID string) (events <-chan []byte, cancel func()) {
+	sub := &subscriber{guildID: guildID, events: make(chan []byte, subscriberBuffer)}
+	b.lock.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.lock.Unlock()
+
+	return sub.events, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		delete(b.subscribers, sub)
+	}
+}
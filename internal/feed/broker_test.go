@@ -0,0 +1,58 @@
+package feed
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker()
+	all, cancelAll := broker.Subscribe("")
+	one, cancelOne := broker.Subscribe("100")
+	defer cancelOne()
+
+	at := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	broker.Publish(notify.Event{Type: store.EventJoin, GuildID: "100", UserID: "1", User: store.User{Username: "alice", Discriminator: "0"}, At: at, MemberCount: 5})
+	broker.Publish(notify.Event{Type: store.EventLeave, GuildID: "200", UserID: "2", At: at, MemberCount: 9})
+
+	var event Event
+	if err := json.Unmarshal(<-one, &event); err != nil {
+		t.Fatal(err)
+	}
+	expected := Event{Type: store.EventJoin, GuildID: "100", UserID: "1", Username: "alice", Discriminator: "0", At: at, MemberCount: 5}
+	if !reflect.DeepEqual(event, expected) {
+		t.Errorf("expected %+v, got %+v", expected, event)
+	}
+	if len(one) != 0 {
+		t.Errorf("expected the other guild's event to be filtered out")
+	}
+	if len(all) != 2 {
+		t.Errorf("expected both events for the unfiltered subscriber, got %v", len(all))
+	}
+
+	// cancelled and slow subscribers don't block publishing
+	cancelAll()
+	for i := 0; i < subscriberBuffer+1; i++ {
+		broker.Publish(notify.Event{Type: store.EventJoin, GuildID: "100", At: at})
+	}
+	if len(one) != subscriberBuffer {
+		t.Errorf("expected a full buffer, got %v", len(one))
+	}
+}
+
+func TestEncodeTimeout(t *testing.T) {
+	at := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	encoded, err := Encode(notify.Event{Type: store.EventTimeout, GuildID: "100", UserID: "1", At: at, Until: at.Add(time.Hour), MemberCount: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"timeout","guild_id":"100","user_id":"1","at":"2023-07-01T12:00:00Z","member_count":5,"until":"2023-07-01T13:00:00Z"}`
+	if string(encoded) != expected {
+		t.Errorf("expected %v, got %v", expected, string(encoded))
+	}
+}
@@ -0,0 +1,99 @@
+// Package feed publishes member events as JSON, for overlays and external automation.
+package feed
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// Event is the JSON form of a member event
+type Event struct {
+	Type          string    `json:"type"`
+	GuildID       string    `json:"guild_id"`
+	UserID        string    `json:"user_id,omitempty"`
+	Username      string    `json:"username,omitempty"`
+	Discriminator string    `json:"discriminator,omitempty"`
+	At            time.Time `json:"at"`
+	MemberCount   int       `json:"member_count"`
+	// Until is when a timeout ends
+	Until   *time.Time `json:"until,omitempty"`
+	Pending bool       `json:"pending,omitempty"`
+	Avatar  string     `json:"avatar,omitempty"`
+	// Count members left within WindowSeconds, for mass leave alerts
+	Count         int     `json:"count,omitempty"`
+	WindowSeconds float64 `json:"window_seconds,omitempty"`
+	// Tags were added by the guild's hook
+	Tags []string `json:"tags,omitempty"`
+	// OtherGuildID is the tracked guild a joining member recently left or was banned from
+	OtherGuildID string `json:"other_guild_id,omitempty"`
+	// RoleID is the role given or removed, ModeratorID who changed it if the audit log says
+	RoleID      string `json:"role_id,omitempty"`
+	ModeratorID string `json:"moderator_id,omitempty"`
+}
+
+// FromNotify converts an event to its JSON form
+func FromNotify(event notify.Event) Event {
+	converted := Event{
+		Type:          event.Type,
+		GuildID:       event.GuildID,
+		UserID:        event.UserID,
+		Username:      event.User.Username,
+		Discriminator: event.User.Discriminator,
+		At:            event.At.UTC(),
+		MemberCount:   event.MemberCount,
+		Pending:       event.Pending,
+		Avatar:        event.Avatar,
+		Count:         event.Count,
+		WindowSeconds: event.Window.Seconds(),
+		Tags:          event.Tags,
+		OtherGuildID:  event.OtherGuildID,
+		RoleID:        event.RoleID,
+		ModeratorID:   event.ModeratorID,
+	}
+	if !event.Until.IsZero() {
+		until := event.Until.UTC()
+		converted.Until = &until
+	}
+	return converted
+}
+
+// Encode converts an event to JSON
+func Encode(event notify.Event) ([]byte, error) {
+	return json.Marshal(FromNotify(event))
+}
+
+// Notify converts the JSON form back to an event, for replaying recorded events
+func (e Event) Notify() notify.Event {
+	event := notify.Event{
+		Type:         e.Type,
+		GuildID:      e.GuildID,
+		UserID:       e.UserID,
+		User:         store.User{Username: e.Username, Discriminator: e.Discriminator},
+		At:           e.At,
+		MemberCount:  e.MemberCount,
+		Pending:      e.Pending,
+		Avatar:       e.Avatar,
+		Count:        e.Count,
+		Window:       time.Duration(e.WindowSeconds * float64(time.Second)),
+		Tags:         e.Tags,
+		OtherGuildID: e.OtherGuildID,
+		RoleID:       e.RoleID,
+		ModeratorID:  e.ModeratorID,
+	}
+	if e.Until != nil {
+		event.Until = *e.Until
+	}
+	return event
+}
+
+// Decode converts an event encoded by Encode back
+func Decode(data []byte) (notify.Event, error) {
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return notify.Event{}, err
+	}
+	return event.Notify(), nil
+}
@@ -0,0 +1,111 @@
+package feed
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+)
+
+// File appends events to a file as JSON lines.
+// When the file grows past a size it is renamed with a timestamp suffix and a new file is started, rotated files are never deleted.
+type File struct {
+	path    string
+	maxSize int64
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenFile appends to path, creating its directory if needed. A maxSize of 0 disables rotation.
+func OpenFile(path string, maxSize int64) (*File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f := &File{path: path, maxSize: maxSize}
+	if err := f.openLocked(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) openLocked() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Publish appends an event, failures are logged
+func (f *File) Publish(event notify.Event) {
+	encoded, err := Encode(event)
+	if err != nil {
+		log.Printf("[event log] failed to encode '%v' event: %v", event.Type, err)
+		return
+	}
+	line := append(encoded, '\n')
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		if err := f.rotateLocked(time.Now()); err != nil {
+			log.Printf("[event log] failed to rotate %v: %v", f.path, err)
+		}
+	}
+	if f.file == nil {
+		if err := f.openLocked(); err != nil {
+			log.Printf("[event log] failed to reopen %v, dropped '%v' event: %v", f.path, event.Type, err)
+			return
+		}
+	}
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	if err != nil {
+		log.Printf("[event log] failed to write '%v' event to %v: %v", event.Type, f.path, err)
+	}
+}
+
+// rotateLocked moves the current file aside, like events.jsonl.20230701T120000Z
+func (f *File) rotateLocked(now time.Time) error {
+	if err := f.file.Close(); err != nil {
+		log.Printf("[event log] failed to close %v: %v", f.path, err)
+	}
+	f.file = nil
+	rotated := fmt.Sprintf("%v.%v", f.path, now.UTC().Format("20060102T150405Z"))
+	// don't overwrite a file rotated within the same second
+	for i := 1; fileExists(rotated); i++ {
+		rotated = fmt.Sprintf("%v.%v-%v", f.path, now.UTC().Format("20060102T150405Z"), i)
+	}
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+	return f.openLocked()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (f *File) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
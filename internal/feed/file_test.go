@@ -0,0 +1,113 @@
+package feed
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func readLines(t *testing.T, path string) []Event {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	events := []Event{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "events.jsonl")
+	f, err := OpenFile(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Publish(notify.Event{Type: store.EventJoin, GuildID: "100", UserID: "1"})
+	f.Close()
+
+	// reopening appends
+	f, err = OpenFile(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Publish(notify.Event{Type: store.EventLeave, GuildID: "100", UserID: "1"})
+	f.Close()
+
+	events := readLines(t, path)
+	if len(events) != 2 || events[0].Type != store.EventJoin || events[1].Type != store.EventLeave {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestFileRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.jsonl")
+	f, err := OpenFile(path, 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// each line is about 100 bytes, so every line after the first starts a new file
+	f.Publish(notify.Event{Type: store.EventJoin, GuildID: "100", UserID: "1"})
+	f.Publish(notify.Event{Type: store.EventJoin, GuildID: "100", UserID: "2"})
+	f.Publish(notify.Event{Type: store.EventJoin, GuildID: "100", UserID: "3"})
+
+	events := readLines(t, path)
+	if len(events) != 1 || events[0].UserID != "3" {
+		t.Errorf("expected only the latest event in the current file, got %+v", events)
+	}
+	rotated, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// files rotated within the same second aren't overwritten
+	if len(rotated) != 2 {
+		t.Fatalf("expected two rotated files, got %v", rotated)
+	}
+	for _, path := range rotated {
+		if events := readLines(t, path); len(events) != 1 {
+			t.Errorf("unexpected rotated events %+v", events)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	until := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	event := notify.Event{
+		Type:    store.EventTimeout,
+		GuildID: "100",
+		UserID:  "1",
+		User:    store.User{Username: "alice", Discriminator: "0"},
+		At:      until.Add(-time.Hour),
+		Until:   until,
+		Window:  90 * time.Second,
+		Tags:    []string{"spam"},
+	}
+	encoded, err := Encode(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, event) {
+		t.Errorf("expected %+v, got %+v", event, decoded)
+	}
+}
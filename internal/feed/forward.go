@@ -0,0 +1,50 @@
+package feed
+
+import (
+	"log"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+)
+
+// Forwarder sends selected event types to a notifier in the background, like push notification services
+type Forwarder struct {
+	name     string
+	notifier notify.Notifier
+	types    map[string]struct{}
+	events   chan notify.Event
+}
+
+// NewForwarder forwards events of the given types to notifier
+func NewForwarder(name string, notifier notify.Notifier, eventTypes []string) *Forwarder {
+	f := &Forwarder{
+		name:     name,
+		notifier: notifier,
+		types:    make(map[string]struct{}, len(eventTypes)),
+		events:   make(chan notify.Event, remoteBuffer),
+	}
+	for _, eventType := range eventTypes {
+		f.types[eventType] = struct{}{}
+	}
+	go f.run()
+	return f
+}
+
+// Publish queues an event of a selected type without blocking
+func (f *Forwarder) Publish(event notify.Event) {
+	if _, selected := f.types[event.Type]; !selected {
+		return
+	}
+	select {
+	case f.events <- event:
+	default:
+		log.Printf("[%v] queue is full, dropped '%v' event", f.name, event.Type)
+	}
+}
+
+func (f *Forwarder) run() {
+	for event := range f.events {
+		if err := f.notifier.Notify(event); err != nil {
+			log.Printf("[%v] failed to send '%v' event: %v", f.name, event.Type, err)
+		}
+	}
+}
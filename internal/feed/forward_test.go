@@ -0,0 +1,39 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+type channelNotifier chan notify.Event
+
+func (c channelNotifier) Notify(event notify.Event) error {
+	c <- event
+	return nil
+}
+
+func (c channelNotifier) NotifyBatch(events []notify.Event) error {
+	for _, event := range events {
+		c <- event
+	}
+	return nil
+}
+
+func TestForwarder(t *testing.T) {
+	notified := make(channelNotifier, 2)
+	forwarder := NewForwarder("test", notified, []string{notify.EventMassLeave})
+	forwarder.Publish(notify.Event{Type: store.EventJoin})
+	forwarder.Publish(notify.Event{Type: notify.EventMassLeave, Count: 5})
+
+	select {
+	case event := <-notified:
+		if event.Type != notify.EventMassLeave {
+			t.Errorf("expected only the selected event type, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the event")
+	}
+}
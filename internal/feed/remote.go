@@ -0,0 +1,76 @@
+package feed
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+)
+
+const (
+	// remoteBuffer is how many events can wait for a broker before new ones are dropped
+	remoteBuffer = 256
+	// remoteTimeout limits connecting and each publish
+	remoteTimeout = 10 * time.Second
+	// remoteMaxBackoff is the longest wait between reconnection attempts
+	remoteMaxBackoff = time.Minute
+)
+
+// Remote publishes events to a message broker in the background, the broker's client library connects and reconnects.
+// Publish never waits for the broker.
+type Remote struct {
+	name    string
+	publish func(event notify.Event, payload []byte) error
+	events  chan notify.Event
+}
+
+func newRemote(name string, publish func(event notify.Event, payload []byte) error) *Remote {
+	r := &Remote{
+		name:    name,
+		publish: publish,
+		events:  make(chan notify.Event, remoteBuffer),
+	}
+	go r.run()
+	return r
+}
+
+// Publish queues an event without blocking
+func (r *Remote) Publish(event notify.Event) {
+	select {
+	case r.events <- event:
+	default:
+		log.Printf("[%v] queue is full, dropped '%v' event", r.name, event.Type)
+	}
+}
+
+func (r *Remote) run() {
+	for event := range r.events {
+		payload, err := Encode(event)
+		if err != nil {
+			log.Printf("[%v] failed to encode '%v' event: %v", r.name, event.Type, err)
+			continue
+		}
+		if err := r.publish(event, payload); err != nil {
+			log.Printf("[%v] failed to publish '%v' event: %v", r.name, event.Type, err)
+		}
+	}
+}
+
+// parseRemoteURL checks a broker URL against the supported schemes and their default ports, adding the port if missing
+func parseRemoteURL(rawURL string, schemes map[string]string, example string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("expected a URL like %v", example)
+	}
+	port, ok := schemes[u.Scheme]
+	if !ok || u.Hostname() == "" {
+		return nil, fmt.Errorf("expected a URL like %v", example)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return u, nil
+}
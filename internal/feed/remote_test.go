@@ -0,0 +1,140 @@
+package feed
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// listen accepts one connection and passes it to serve
+func listen(t *testing.T, serve func(conn net.Conn)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(conn)
+	}()
+	return listener.Addr().String()
+}
+
+func receive(t *testing.T, received chan string) string {
+	t.Helper()
+	select {
+	case message := <-received:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the event")
+		return ""
+	}
+}
+
+var remoteTestEvent = notify.Event{Type: store.EventJoin, GuildID: "100", UserID: "1", At: time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC), MemberCount: 5}
+
+func TestMQTT(t *testing.T) {
+	received := make(chan string, 1)
+	address := listen(t, func(conn net.Conn) {
+		packet, err := packets.ReadPacket(conn)
+		connect, ok := packet.(*packets.ConnectPacket)
+		if err != nil || !ok {
+			t.Errorf("expected CONNECT, got %v %v", packet, err)
+			return
+		}
+		if connect.Username != "alice" || string(connect.Password) != "secret" {
+			t.Errorf("expected credentials, got %q %q", connect.Username, connect.Password)
+		}
+		connack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+		connack.Write(conn)
+
+		packet, err = packets.ReadPacket(conn)
+		publish, ok := packet.(*packets.PublishPacket)
+		if err != nil || !ok {
+			t.Errorf("expected PUBLISH, got %v %v", packet, err)
+			return
+		}
+		received <- publish.TopicName + " " + string(publish.Payload)
+	})
+
+	remote, err := NewMQTT("mqtt://alice:secret@"+address, "user-log/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote.Publish(remoteTestEvent)
+
+	topic, payload, _ := strings.Cut(receive(t, received), " ")
+	if topic != "user-log/100/join" {
+		t.Errorf("unexpected topic %q", topic)
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil || event.UserID != "1" {
+		t.Errorf("unexpected payload %q: %v", payload, err)
+	}
+}
+
+func TestNATS(t *testing.T) {
+	received := make(chan string, 1)
+	address := listen(t, func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("INFO {\"server_id\":\"test\",\"proto\":1,\"max_payload\":1048576}\r\n"))
+		connect, _ := reader.ReadString('\n')
+		if !strings.HasPrefix(connect, "CONNECT ") || !strings.Contains(connect, `"auth_token":"hunter2"`) {
+			t.Errorf("unexpected CONNECT %q", connect)
+		}
+		if ping, _ := reader.ReadString('\n'); ping != "PING\r\n" {
+			t.Errorf("expected PING, got %q", ping)
+		}
+		conn.Write([]byte("PONG\r\n"))
+
+		pub, _ := reader.ReadString('\n')
+		payload, _ := reader.ReadString('\n')
+
+		// the client answers our pings
+		conn.Write([]byte("PING\r\n"))
+		if pong, _ := reader.ReadString('\n'); pong != "PONG\r\n" {
+			t.Errorf("expected PONG, got %q", pong)
+		}
+		received <- pub + payload
+	})
+
+	remote, err := NewNATS("nats://hunter2@"+address, "user-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote.Publish(remoteTestEvent)
+
+	message := receive(t, received)
+	pub, payload, _ := strings.Cut(message, "\r\n")
+	if !strings.HasPrefix(pub, "PUB user-log.100.join ") {
+		t.Errorf("unexpected PUB %q", pub)
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil || event.UserID != "1" {
+		t.Errorf("unexpected payload %q: %v", payload, err)
+	}
+}
+
+func TestRemoteURLs(t *testing.T) {
+	for _, rawURL := range []string{"http://host", "mqtt://", "host:1883"} {
+		if _, err := NewMQTT(rawURL, "user-log"); err == nil {
+			t.Errorf("expected %v to be rejected", rawURL)
+		}
+	}
+	u, err := parseRemoteURL("nats://host", map[string]string{"nats": "4222"}, "nats://host")
+	if err != nil || u.Host != "host:4222" {
+		t.Errorf("expected the default port, got %v %v", u, err)
+	}
+}
@@ -0,0 +1,174 @@
+package feed
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+const (
+	// webhookAttempts is how often a delivery is tried before it becomes a dead letter
+	webhookAttempts = 5
+	// webhookBackoff is the wait after the first failed attempt, it doubles after every further one
+	webhookBackoff = 2 * time.Second
+	// webhookTimeout limits each attempt
+	webhookTimeout = 10 * time.Second
+)
+
+// Headers of webhook deliveries
+const (
+	// SignatureHeader is "sha256=" and the hex HMAC-SHA256 of the timestamp, a dot, and the body, keyed with the secret
+	SignatureHeader = "X-User-Log-Signature"
+	// TimestampHeader is when the delivery was signed in unix seconds, receivers should reject old ones to prevent replays
+	TimestampHeader = "X-User-Log-Timestamp"
+	EventHeader     = "X-User-Log-Event"
+)
+
+// DeadLetters keeps the deliveries that failed every attempt, like *store.Store
+type DeadLetters interface {
+	AddWebhookFailure(failure store.WebhookFailure) error
+}
+
+// Webhook posts selected events as signed JSON to an HTTP endpoint in the background, retrying failed deliveries with backoff.
+// Deliveries that still fail become dead letters, to be redelivered later.
+type Webhook struct {
+	url         string
+	secret      string
+	types       map[string]struct{}
+	deadLetters DeadLetters
+	client      *http.Client
+	events      chan notify.Event
+	// backoff is webhookBackoff, shorter in tests
+	backoff time.Duration
+}
+
+// NewWebhook posts events of the given types to url, or every event if there are none
+func NewWebhook(url, secret string, eventTypes []string, deadLetters DeadLetters) *Webhook {
+	w := newWebhook(url, secret, eventTypes, deadLetters)
+	go w.run()
+	return w
+}
+
+func newWebhook(url, secret string, eventTypes []string, deadLetters DeadLetters) *Webhook {
+	w := &Webhook{
+		url:         url,
+		secret:      secret,
+		types:       make(map[string]struct{}, len(eventTypes)),
+		deadLetters: deadLetters,
+		client:      &http.Client{Timeout: webhookTimeout},
+		events:      make(chan notify.Event, remoteBuffer),
+		backoff:     webhookBackoff,
+	}
+	for _, eventType := range eventTypes {
+		w.types[eventType] = struct{}{}
+	}
+	return w
+}
+
+// Publish queues an event of a selected type without blocking, it becomes a dead letter if the queue is full
+func (w *Webhook) Publish(event notify.Event) {
+	if _, selected := w.types[event.Type]; len(w.types) > 0 && !selected {
+		return
+	}
+	select {
+	case w.events <- event:
+	default:
+		// events are published in the transaction recording them, the dead letter waits for it to commit
+		go w.deadLetter(event, nil, 0, errors.New("queue is full"))
+	}
+}
+
+func (w *Webhook) run() {
+	for event := range w.events {
+		w.send(event)
+	}
+}
+
+// send delivers an event, waiting longer after every failed attempt
+func (w *Webhook) send(event notify.Event) {
+	payload, err := Encode(event)
+	if err != nil {
+		log.Printf("[webhook] failed to encode '%v' event: %v", event.Type, err)
+		return
+	}
+	wait := w.backoff
+	attempt := 1
+	for ; ; attempt++ {
+		var retry bool
+		if retry, err = w.Deliver(event.Type, payload); err == nil {
+			return
+		}
+		if !retry || attempt == webhookAttempts {
+			break
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+	w.deadLetter(event, payload, attempt, err)
+}
+
+func (w *Webhook) deadLetter(event notify.Event, payload []byte, attempts int, err error) {
+	log.Printf("[webhook] failed to deliver '%v' event to %v after %v attempts, keeping it to redeliver: %v", event.Type, w.url, attempts, err)
+	if payload == nil {
+		payload, _ = Encode(event)
+	}
+	failure := store.WebhookFailure{
+		URL:       w.url,
+		GuildID:   event.GuildID,
+		DiscordID: event.UserID,
+		Event:     event.Type,
+		Payload:   string(payload),
+		Attempts:  attempts,
+		Error:     err.Error(),
+		At:        time.Now(),
+	}
+	if err := w.deadLetters.AddWebhookFailure(failure); err != nil {
+		log.Printf("[webhook] failed to keep the undelivered '%v' event: %v", event.Type, err)
+	}
+}
+
+// Deliver posts a payload once, signed at the current time.
+// It reports whether a failure is worth retrying: rejected deliveries (4xx other than timeouts and rate limits) aren't.
+func (w *Webhook) Deliver(eventType string, payload []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "user-log")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(w.secret, timestamp, payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("responded with %v", resp.Status)
+}
+
+// Sign returns the signature of a payload signed at a timestamp, like the SignatureHeader of its delivery
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%v.", timestamp)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
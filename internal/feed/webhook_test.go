@@ -0,0 +1,108 @@
+package feed
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+type deadLetterList struct {
+	lock     sync.Mutex
+	failures []store.WebhookFailure
+}
+
+func (d *deadLetterList) AddWebhookFailure(failure store.WebhookFailure) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.failures = append(d.failures, failure)
+	return nil
+}
+
+// webhookServer responds with the statuses in order, then 204, and counts the requests
+func webhookServer(t *testing.T, statuses ...int) (*httptest.Server, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		if signature := r.Header.Get(SignatureHeader); signature != Sign("secret", timestamp, body) {
+			t.Errorf("unexpected signature %v", signature)
+		}
+		if r.Header.Get(EventHeader) != store.EventJoin {
+			t.Errorf("unexpected event header %v", r.Header.Get(EventHeader))
+		}
+		status := http.StatusNoContent
+		if requests < len(statuses) {
+			status = statuses[requests]
+		}
+		requests++
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestWebhookRetries(t *testing.T) {
+	server, requests := webhookServer(t, http.StatusBadGateway, http.StatusTooManyRequests)
+	deadLetters := &deadLetterList{}
+	w := newWebhook(server.URL, "secret", nil, deadLetters)
+	w.backoff = time.Millisecond
+
+	w.send(notify.Event{Type: store.EventJoin, GuildID: "g", UserID: "1", At: time.Now()})
+	if *requests != 3 || len(deadLetters.failures) != 0 {
+		t.Errorf("expected the third attempt to be delivered, got %v requests and %+v", *requests, deadLetters.failures)
+	}
+}
+
+func TestWebhookDeadLetters(t *testing.T) {
+	for _, tc := range []struct {
+		status   int
+		attempts int
+	}{
+		{http.StatusInternalServerError, webhookAttempts},
+		// rejected deliveries aren't retried
+		{http.StatusBadRequest, 1},
+	} {
+		statuses := make([]int, webhookAttempts)
+		for i := range statuses {
+			statuses[i] = tc.status
+		}
+		server, requests := webhookServer(t, statuses...)
+		deadLetters := &deadLetterList{}
+		w := newWebhook(server.URL, "secret", nil, deadLetters)
+		w.backoff = time.Millisecond
+
+		w.send(notify.Event{Type: store.EventJoin, GuildID: "g", UserID: "1", At: time.Now()})
+		if *requests != tc.attempts || len(deadLetters.failures) != 1 {
+			t.Fatalf("expected %v attempts and a dead letter for %v, got %v and %+v", tc.attempts, tc.status, *requests, deadLetters.failures)
+		}
+		failure := deadLetters.failures[0]
+		if failure.URL != server.URL || failure.DiscordID != "1" || failure.Attempts != tc.attempts || failure.Payload == "" {
+			t.Errorf("unexpected dead letter %+v", failure)
+		}
+	}
+}
+
+func TestWebhookSelectsEvents(t *testing.T) {
+	deadLetters := &deadLetterList{}
+	w := newWebhook("http://127.0.0.1:0", "secret", []string{store.EventJoin}, deadLetters)
+	w.Publish(notify.Event{Type: store.EventLeave})
+	w.Publish(notify.Event{Type: store.EventJoin})
+	if len(w.events) != 1 {
+		t.Errorf("expected only the join to be queued, got %v events", len(w.events))
+	}
+}
+
+func TestSign(t *testing.T) {
+	// printf '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	expected := "sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+	if signature := Sign("secret", 1700000000, []byte("{}")); signature != expected {
+		t.Errorf("unexpected signature %v", signature)
+	}
+}
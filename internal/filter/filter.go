@@ -0,0 +1,365 @@
+// Package filter matches events against expressions like `event == "leave" && member_age_days < 1`,
+// so announcement channels and event consumers can each receive their own slice of the events.
+//
+// Expressions compare event attributes with ==, !=, <, <=, >, and >=, match strings against regular expressions with =~,
+// test membership with in (like `event in ["join", "leave"]` or `"spam" in tags`), and combine conditions with &&, ||, !, and parentheses.
+// Strings are double- or single-quoted, numbers are decimal, and true and false are booleans.
+// Expressions are type checked when they are parsed, so a filter that parses never fails on an event.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/notify"
+)
+
+type kind int
+
+const (
+	kindBool kind = iota
+	kindNumber
+	kindString
+	kindStrings
+	kindNumbers
+)
+
+func (k kind) String() string {
+	return [...]string{"boolean", "number", "string", "list of strings", "list of numbers"}[k]
+}
+
+// attribute is an event attribute expressions can refer to by name
+type attribute struct {
+	kind  kind
+	value func(event notify.Event) interface{}
+}
+
+// attributes are the event attributes by name, documented in the README
+var attributes = map[string]attribute{
+	"event":          {kindString, func(e notify.Event) interface{} { return e.Type }},
+	"guild_id":       {kindString, func(e notify.Event) interface{} { return e.GuildID }},
+	"user_id":        {kindString, func(e notify.Event) interface{} { return e.UserID }},
+	"username":       {kindString, func(e notify.Event) interface{} { return e.User.Username }},
+	"tag":            {kindString, func(e notify.Event) interface{} { return e.User.Tag() }},
+	"member_count":   {kindNumber, func(e notify.Event) interface{} { return float64(e.MemberCount) }},
+	"pending":        {kindBool, func(e notify.Event) interface{} { return e.Pending }},
+	"tags":           {kindStrings, func(e notify.Event) interface{} { return e.Tags }},
+	"count":          {kindNumber, func(e notify.Event) interface{} { return float64(e.Count) }},
+	"reason":         {kindString, func(e notify.Event) interface{} { return e.Reason }},
+	"moderator_id":   {kindString, func(e notify.Event) interface{} { return e.ModeratorID }},
+	"channel_id":     {kindString, func(e notify.Event) interface{} { return e.ChannelID }},
+	"role_id":        {kindString, func(e notify.Event) interface{} { return e.RoleID }},
+	"other_guild_id": {kindString, func(e notify.Event) interface{} { return e.OtherGuildID }},
+	// member_age_days is how long a leaving member or one celebrating an anniversary was in the guild, 0 if unknown
+	"member_age_days": {kindNumber, func(e notify.Event) interface{} {
+		if e.JoinedAt.IsZero() {
+			return 0.0
+		}
+		return days(e.At.Sub(e.JoinedAt))
+	}},
+	// account_age_days is how old the user's Discord account was when the event happened, 0 without a user
+	"account_age_days": {kindNumber, func(e notify.Event) interface{} {
+		created, err := discordgo.SnowflakeTimestamp(e.UserID)
+		if e.UserID == "" || err != nil {
+			return 0.0
+		}
+		return days(e.At.Sub(created))
+	}},
+}
+
+func days(d time.Duration) float64 {
+	return d.Hours() / 24
+}
+
+// Attributes lists the names of the attributes expressions can use
+func Attributes() []string {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Filter is a parsed expression, safe for concurrent use
+type Filter struct {
+	source string
+	root   node
+}
+
+// node is a type checked part of an expression
+type node struct {
+	kind kind
+	eval func(event notify.Event) interface{}
+}
+
+// Parse parses and type checks an expression, which must be a condition
+func Parse(source string) (*Filter, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse filter: %w", err)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.or()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = p.unexpected()
+	}
+	if err == nil && root.kind != kindBool {
+		err = fmt.Errorf("expected a condition, got a %v", root.kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse filter: %w", err)
+	}
+	return &Filter{source: source, root: root}, nil
+}
+
+// Match returns whether an event matches the filter, a nil filter matches every event
+func (f *Filter) Match(event notify.Event) bool {
+	if f == nil {
+		return true
+	}
+	return f.root.eval(event).(bool)
+}
+
+// String returns the expression
+func (f *Filter) String() string {
+	return f.source
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) unexpected() error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return fmt.Errorf("unexpected end at position %v", t.pos)
+	}
+	return fmt.Errorf("unexpected '%v' at position %v", t.text, t.pos)
+}
+
+func (p *parser) expect(text string) error {
+	if t := p.peek(); t.kind != tokenOperator || t.text != text {
+		return fmt.Errorf("expected '%v' at position %v", text, t.pos)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	for err == nil && p.peek().is("||") {
+		op := p.next()
+		var right node
+		if right, err = p.and(); err == nil {
+			left, err = logical(op, left, right)
+		}
+	}
+	return left, err
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.not()
+	for err == nil && p.peek().is("&&") {
+		op := p.next()
+		var right node
+		if right, err = p.not(); err == nil {
+			left, err = logical(op, left, right)
+		}
+	}
+	return left, err
+}
+
+func logical(op token, left, right node) (node, error) {
+	if left.kind != kindBool || right.kind != kindBool {
+		return node{}, fmt.Errorf("'%v' at position %v needs conditions on both sides", op.text, op.pos)
+	}
+	if op.text == "&&" {
+		return node{kindBool, func(e notify.Event) interface{} { return left.eval(e).(bool) && right.eval(e).(bool) }}, nil
+	}
+	return node{kindBool, func(e notify.Event) interface{} { return left.eval(e).(bool) || right.eval(e).(bool) }}, nil
+}
+
+func (p *parser) not() (node, error) {
+	if !p.peek().is("!") {
+		return p.comparison()
+	}
+	op := p.next()
+	operand, err := p.not()
+	if err != nil {
+		return node{}, err
+	}
+	if operand.kind != kindBool {
+		return node{}, fmt.Errorf("'!' at position %v needs a condition", op.pos)
+	}
+	return node{kindBool, func(e notify.Event) interface{} { return !operand.eval(e).(bool) }}, nil
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.operand()
+	if err != nil {
+		return node{}, err
+	}
+	op := p.peek()
+	switch {
+	case op.is("==", "!=", "<", "<=", ">", ">="):
+		p.next()
+		right, err := p.operand()
+		if err != nil {
+			return node{}, err
+		}
+		return compare(op, left, right)
+	case op.is("=~"):
+		p.next()
+		pattern := p.next()
+		if pattern.kind != tokenString {
+			return node{}, fmt.Errorf("'=~' at position %v needs a quoted regular expression", op.pos)
+		}
+		re, err := regexp.Compile(pattern.value.(string))
+		if err != nil {
+			return node{}, fmt.Errorf("invalid regular expression at position %v: %w", pattern.pos, err)
+		}
+		if left.kind != kindString {
+			return node{}, fmt.Errorf("'=~' at position %v needs a string, got a %v", op.pos, left.kind)
+		}
+		return node{kindBool, func(e notify.Event) interface{} { return re.MatchString(left.eval(e).(string)) }}, nil
+	case op.kind == tokenIdent && op.text == "in":
+		p.next()
+		right, err := p.operand()
+		if err != nil {
+			return node{}, err
+		}
+		return contains(op, left, right)
+	}
+	return left, nil
+}
+
+func compare(op token, left, right node) (node, error) {
+	if left.kind != right.kind || (left.kind != kindNumber && left.kind != kindString && left.kind != kindBool) {
+		return node{}, fmt.Errorf("can't compare a %v with a %v at position %v", left.kind, right.kind, op.pos)
+	}
+	if left.kind != kindNumber && !op.is("==", "!=") {
+		return node{}, fmt.Errorf("'%v' at position %v needs numbers, got a %v", op.text, op.pos, left.kind)
+	}
+	var test func(a, b interface{}) bool
+	switch op.text {
+	case "==":
+		test = func(a, b interface{}) bool { return a == b }
+	case "!=":
+		test = func(a, b interface{}) bool { return a != b }
+	case "<":
+		test = func(a, b interface{}) bool { return a.(float64) < b.(float64) }
+	case "<=":
+		test = func(a, b interface{}) bool { return a.(float64) <= b.(float64) }
+	case ">":
+		test = func(a, b interface{}) bool { return a.(float64) > b.(float64) }
+	case ">=":
+		test = func(a, b interface{}) bool { return a.(float64) >= b.(float64) }
+	}
+	return node{kindBool, func(e notify.Event) interface{} { return test(left.eval(e), right.eval(e)) }}, nil
+}
+
+func contains(op token, left, right node) (node, error) {
+	if !(left.kind == kindString && right.kind == kindStrings) && !(left.kind == kindNumber && right.kind == kindNumbers) {
+		return node{}, fmt.Errorf("can't look for a %v in a %v at position %v", left.kind, right.kind, op.pos)
+	}
+	return node{kindBool, func(e notify.Event) interface{} {
+		needle := left.eval(e)
+		switch haystack := right.eval(e).(type) {
+		case []string:
+			for _, value := range haystack {
+				if value == needle {
+					return true
+				}
+			}
+		case []float64:
+			for _, value := range haystack {
+				if value == needle {
+					return true
+				}
+			}
+		}
+		return false
+	}}, nil
+}
+
+func (p *parser) operand() (node, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokenString || t.kind == tokenNumber:
+		p.next()
+		return constant(t), nil
+	case t.kind == tokenIdent && (t.text == "true" || t.text == "false"):
+		p.next()
+		value := t.text == "true"
+		return node{kindBool, func(notify.Event) interface{} { return value }}, nil
+	case t.kind == tokenIdent && t.text != "in":
+		p.next()
+		attribute, ok := attributes[t.text]
+		if !ok {
+			return node{}, fmt.Errorf("unknown attribute '%v' at position %v, expected one of %v", t.text, t.pos, strings.Join(Attributes(), ", "))
+		}
+		return node{attribute.kind, attribute.value}, nil
+	case t.is("("):
+		p.next()
+		inner, err := p.or()
+		if err != nil {
+			return node{}, err
+		}
+		return inner, p.expect(")")
+	case t.is("["):
+		p.next()
+		return p.list()
+	}
+	return node{}, p.unexpected()
+}
+
+func constant(t token) node {
+	value := t.value
+	if t.kind == tokenNumber {
+		return node{kindNumber, func(notify.Event) interface{} { return value }}
+	}
+	return node{kindString, func(notify.Event) interface{} { return value }}
+}
+
+// list parses a list literal of strings or numbers after its opening bracket
+func (p *parser) list() (node, error) {
+	strs, numbers := []string{}, []float64{}
+	for !p.peek().is("]") {
+		if len(strs)+len(numbers) > 0 {
+			if err := p.expect(","); err != nil {
+				return node{}, err
+			}
+		}
+		switch t := p.next(); {
+		case t.kind == tokenString && len(numbers) == 0:
+			strs = append(strs, t.value.(string))
+		case t.kind == tokenNumber && len(strs) == 0:
+			numbers = append(numbers, t.value.(float64))
+		default:
+			return node{}, fmt.Errorf("lists must be all strings or all numbers, at position %v", t.pos)
+		}
+	}
+	p.next()
+	if len(numbers) > 0 {
+		return node{kindNumbers, func(notify.Event) interface{} { return numbers }}, nil
+	}
+	return node{kindStrings, func(notify.Event) interface{} { return strs }}, nil
+}
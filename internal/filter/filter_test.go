@@ -0,0 +1,76 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestMatch(t *testing.T) {
+	at := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	leave := notify.Event{
+		Type:        store.EventLeave,
+		GuildID:     "100",
+		UserID:      "1120000000000000000",
+		User:        store.User{Username: "alice", Discriminator: "0"},
+		At:          at,
+		MemberCount: 41,
+		JoinedAt:    at.Add(-6 * time.Hour),
+		Tags:        []string{"spam"},
+	}
+
+	for expression, expected := range map[string]bool{
+		`event == "leave" && member_age_days < 1`:       true,
+		`event == 'leave' && member_age_days >= 1`:      false,
+		`event in ["join", "leave"]`:                    true,
+		`!(event in ["join"])`:                          true,
+		`"spam" in tags`:                                true,
+		`"raid" in tags || member_count in [40, 41]`:    true,
+		`username =~ "^ali" && !pending`:                true,
+		`tag == "alice" && guild_id != "200"`:           true,
+		`account_age_days < 30 && account_age_days > 0`: true,
+		`count > 0 || reason != ""`:                     false,
+		`true && (false || member_count <= 41)`:         true,
+	} {
+		filter, err := Parse(expression)
+		if err != nil {
+			t.Errorf("failed to parse %v: %v", expression, err)
+			continue
+		}
+		if actual := filter.Match(leave); actual != expected {
+			t.Errorf("%v matched %v, expected %v", expression, actual, expected)
+		}
+	}
+
+	var none *Filter
+	if !none.Match(leave) {
+		t.Error("expected a nil filter to match every event")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for expression, expected := range map[string]string{
+		`event == `:               "unexpected end at position 10",
+		`event = "leave"`:         "unexpected '=' at position 7",
+		`event == 1`:              "can't compare a string with a number",
+		`member_count`:            "expected a condition, got a number",
+		`event < "leave"`:         "'<' at position 7 needs numbers",
+		`nick == "alice"`:         "unknown attribute 'nick'",
+		`event == "leave`:         "unterminated string at position 10",
+		`username =~ "("`:         "invalid regular expression",
+		`event in ["join", 1]`:    "lists must be all strings or all numbers",
+		`(event == "join"`:        "expected ')' at position 17",
+		`event == "join" member`:  "unexpected 'member' at position 17",
+		`member_count in ["a"]`:   "can't look for a number in a list of strings",
+		`pending && member_count`: "'&&' at position 9 needs conditions on both sides",
+		`!member_count`:           "'!' at position 1 needs a condition",
+	} {
+		_, err := Parse(expression)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("parsing %v failed with %v, expected %q", expression, err, expected)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// value is the string or float64 of literals
+	value interface{}
+	// pos is the 1-based position in the expression, for errors
+	pos int
+}
+
+// is returns whether the token is one of the operators
+func (t token) is(operators ...string) bool {
+	if t.kind != tokenOperator {
+		return false
+	}
+	for _, operator := range operators {
+		if t.text == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// operators are matched longest first
+var operators = []string{"==", "!=", "<=", ">=", "=~", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func lex(source string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(source) && source[end] != c {
+				if source[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(source) {
+				return nil, fmt.Errorf("unterminated string at position %v", i+1)
+			}
+			text := source[i : end+1]
+			value, err := unquote(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %v: %w", i+1, err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, value: value, pos: i + 1})
+			i = end + 1
+		case c >= '0' && c <= '9' || c == '-' || c == '.':
+			end := i + 1
+			for end < len(source) && (source[end] >= '0' && source[end] <= '9' || source[end] == '.') {
+				end++
+			}
+			value, err := strconv.ParseFloat(source[i:end], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number '%v' at position %v", source[i:end], i+1)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: source[i:end], value: value, pos: i + 1})
+			i = end
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			end := i + 1
+			for end < len(source) && (source[end] == '_' || source[end] >= 'a' && source[end] <= 'z' || source[end] >= 'A' && source[end] <= 'Z' || source[end] >= '0' && source[end] <= '9') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[i:end], pos: i + 1})
+			i = end
+		default:
+			matched := ""
+			for _, operator := range operators {
+				if strings.HasPrefix(source[i:], operator) {
+					matched = operator
+					break
+				}
+			}
+			if matched == "" {
+				return nil, fmt.Errorf("unexpected '%c' at position %v", c, i+1)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: matched, pos: i + 1})
+			i += len(matched)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(source) + 1}), nil
+}
+
+// unquote decodes a double-quoted string with Go escapes, or a single-quoted one where only \' and \\ are escapes
+func unquote(text string) (string, error) {
+	if text[0] == '"' {
+		return strconv.Unquote(text)
+	}
+	replacer := strings.NewReplacer(`\'`, `'`, `\\`, `\`)
+	return replacer.Replace(text[1 : len(text)-1]), nil
+}
@@ -0,0 +1,261 @@
+package i18n
+
+var german = &Language{
+	Code:      "de",
+	thousands: ".",
+	units: [6][2]string{
+		{"Jahr", "Jahre"},
+		{"Monat", "Monate"},
+		{"Tag", "Tage"},
+		{"Stunde", "Stunden"},
+		{"Minute", "Minuten"},
+		{"Sekunde", "Sekunden"},
+	},
+	member: [2]string{"Mitglied", "Mitglieder"},
+	templates: map[string]string{
+		"join":               "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} ist dem Server beigetreten{{if .Pending}}, Mitgliedschaftsprüfung ausstehend{{end}}{{if .MemberCount}}, jetzt {{.Members}}{{end}}",
+		"leave":              "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} hat den Server{{if .Stay}} nach {{duration .Stay}} Mitgliedschaft{{end}} verlassen{{if .MemberCount}}, jetzt {{.Members}}{{end}}",
+		"milestone":          "🎉 Wir haben gerade {{number .MemberCount}} Mitglieder erreicht! Willkommen <@{{.ID}}>",
+		"mass_leave":         "🚨 {{number .Count}} Mitglieder sind innerhalb von {{duration .Window}} gegangen, {{number .MemberCount}} sind geblieben",
+		"boost_start":        "💎 <@{{.ID}}> boostet jetzt den Server, danke!",
+		"boost_stop":         "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} boostet den Server nicht mehr",
+		"timeout":            "⏳ <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} hat einen Timeout für {{duration .Timeout}} erhalten, bis <t:{{.Until.Unix}}:f>",
+		"timeout_end":        "Der Timeout von <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} wurde aufgehoben",
+		"screening_complete": "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} hat die Mitgliedschaftsprüfung abgeschlossen",
+		"rename":             "✏️ <@{{.ID}}> hat {{if eq .NameKind \"username\"}}den Benutzernamen{{else}}den Spitznamen{{end}} von `{{or .OldName \"nichts\"}}` zu `{{or .NewName \"nichts\"}}` geändert",
+		"avatar_change":      "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} hat den Avatar geändert{{with .AvatarURL}} {{.}}{{end}}",
+		"anniversary":        "🎂 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} ist heute seit {{.Years}} {{if eq .Years 1}}Jahr{{else}}Jahren{{end}} hier!",
+		"sync_summary":       "🔄 Ein Abgleich hat {{number .Count}} verpasste Ereignisse gefunden, {{number .Joins}} Beitritte und {{number .Leaves}} Austritte, jetzt {{.Members}}",
+		"leave_edit":         " — gegangen, war {{duration .Stay}} dabei",
+		"watched_join":       "{{with .Ping}}{{.}} {{end}}👀 Beobachtete Person <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} ist dem Server beigetreten",
+		"watched_leave":      "{{with .Ping}}{{.}} {{end}}👀 Beobachtete Person <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} hat den Server verlassen",
+		"watched_rename":     "{{with .Ping}}{{.}} {{end}}👀 Beobachtete Person <@{{.ID}}> hat {{if eq .NameKind \"username\"}}den Benutzernamen{{else}}den Spitznamen{{end}} von `{{or .OldName \"nichts\"}}` zu `{{or .NewName \"nichts\"}}` geändert",
+		"cross_guild_join":   "⚠️ <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} ist dem Server beigetreten{{if .MemberCount}}, jetzt {{.Members}}{{end}}. {{if eq .OtherEvent \"ban\"}}Wurde <t:{{.OtherAt.Unix}}:R> aus {{.OtherGuild}} gebannt{{else}}Hat {{.OtherGuild}} <t:{{.OtherAt.Unix}}:R> verlassen{{end}}",
+		"voice_join":         "🔊 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} ist <#{{.ChannelID}}> beigetreten",
+		"voice_leave":        "🔇 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} hat <#{{.ChannelID}}> verlassen",
+		"voice_move":         "🔀 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} ist von <#{{.OldChannelID}}> nach <#{{.ChannelID}}> gewechselt",
+		"ban":                "🔨 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} wurde{{with .ModeratorID}} von <@{{.}}>{{end}} gebannt{{with .Reason}}: {{.}}{{end}}",
+		"unban":              "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} wurde{{with .ModeratorID}} von <@{{.}}>{{end}} entbannt{{with .Reason}}: {{.}}{{end}}",
+		"role_add":           "🏷️ <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} hat die Rolle {{or .RoleName .RoleID}}{{with .ModeratorID}} von <@{{.}}>{{end}} bekommen",
+		"role_remove":        "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} hat die Rolle {{or .RoleName .RoleID}} verloren{{with .ModeratorID}}, entfernt von <@{{.}}>{{end}}",
+	},
+	messages: map[string]string{
+		"User Log commands": "User-Log-Befehle",
+		"Delete everything stored about a user (admin only)":           "Alles über eine Person Gespeicherte löschen (nur Admins)",
+		"User (or user ID) to forget":                                  "Person (oder Benutzer-ID), die vergessen werden soll",
+		"Show member growth and churn":                                 "Mitgliederwachstum und Abwanderung anzeigen",
+		"Show the latest joins and leaves":                             "Die neuesten Beitritte und Austritte anzeigen",
+		"Events per page (default %v)":                                 "Ereignisse pro Seite (Standard %v)",
+		"List every username and nickname seen for a user":             "Alle gesehenen Benutzernamen und Spitznamen einer Person auflisten",
+		"User (or user ID) to look up":                                 "Person (oder Benutzer-ID), die nachgeschlagen werden soll",
+		"Show a chart of the member count":                             "Ein Diagramm der Mitgliederzahl anzeigen",
+		"Attach an HTML report of the last month":                      "Einen HTML-Bericht des letzten Monats anhängen",
+		"How far back to go (default 30d)":                             "Wie weit zurück (Standard 30 Tage)",
+		"30 days":                                                      "30 Tage",
+		"90 days":                                                      "90 Tage",
+		"1 year":                                                       "1 Jahr",
+		"Send alerts when a user joins, leaves, or changes their name": "Warnungen senden, wenn eine Person beitritt, geht oder ihren Namen ändert",
+		"User (or user ID) to watch":                                   "Person (oder Benutzer-ID), die beobachtet werden soll",
+		"Stop watching a user":                                         "Eine Person nicht mehr beobachten",
+		"User (or user ID) to stop watching":                           "Person (oder Benutzer-ID), die nicht mehr beobachtet werden soll",
+		"List the watched users":                                       "Die beobachteten Personen auflisten",
+		"Change this server's settings (admin only)":                   "Die Einstellungen dieses Servers ändern (nur Admins)",
+		"Show the settings changed with /userlog config":               "Die mit /userlog config geänderten Einstellungen anzeigen",
+		"Change a setting, overriding the config file":                 "Eine Einstellung ändern, statt der Konfigurationsdatei",
+		"Setting name, like channel_id or template_join":               "Name der Einstellung, wie channel_id oder template_join",
+		"New value, lists are comma-separated":                         "Neuer Wert, Listen sind kommagetrennt",
+		"Go back to the config file value of a setting":                "Wieder den Wert der Konfigurationsdatei verwenden",
+		"Setting name":                                                 "Name der Einstellung",
+
+		"Unknown command.": "Unbekannter Befehl.",
+		"You don't have permission to use this command.":                                   "Du darfst diesen Befehl nicht verwenden.",
+		"Failed to forget <@%v>, check the logs.":                                          "<@%v> konnte nicht vergessen werden, siehe Logs.",
+		"Nothing was stored about <@%v>.":                                                  "Über <@%v> war nichts gespeichert.",
+		"Forgot everything this server stored about <@%v>.":                                "Alles, was dieser Server über <@%v> gespeichert hat, wurde vergessen.",
+		"Forgot everything this server stored about <@%v>, except that they are a member.": "Alles, was dieser Server über <@%v> gespeichert hat, wurde vergessen, außer der Mitgliedschaft.",
+		"Unknown period.": "Unbekannter Zeitraum.",
+		"Failed to load the member count history, check the logs.": "Der Verlauf der Mitgliederzahl konnte nicht geladen werden, siehe Logs.",
+		"Failed to draw the graph, check the logs.":                "Das Diagramm konnte nicht gezeichnet werden, siehe Logs.",
+		"Members, Last %v Days":                                    "Mitglieder, letzte %v Tage",
+		"%v to %v: %v → **%v** members (%v)\nLow %v, high %v":      "%v bis %v: %v → **%v** Mitglieder (%v)\nTiefstand %v, Höchststand %v",
+		"Failed to load names, check the logs.":                    "Die Namen konnten nicht geladen werden, siehe Logs.",
+		"No names were seen for <@%v>.":                            "Für <@%v> wurden keine Namen gesehen.",
+		"Usernames":                                                "Benutzernamen",
+		"Nicknames":                                                "Spitznamen",
+		"Name History":                                             "Namensverlauf",
+		"First seen – last seen":                                   "Zuerst gesehen – zuletzt gesehen",
+		"Unknown page.":                                            "Unbekannte Seite.",
+		"Failed to load recent events, check the logs.":            "Die letzten Ereignisse konnten nicht geladen werden, siehe Logs.",
+		"joined":                  "ist beigetreten",
+		"left":                    "ist gegangen",
+		"Nothing here.":           "Hier ist nichts.",
+		"Recent Joins and Leaves": "Letzte Beitritte und Austritte",
+		"Page %v":                 "Seite %v",
+		"Newer":                   "Neuer",
+		"Older":                   "Älter",
+		"Failed to load settings, check the logs.":            "Die Einstellungen konnten nicht geladen werden, siehe Logs.",
+		"Nothing was changed, the config file is used as-is.": "Nichts wurde geändert, die Konfigurationsdatei gilt unverändert.",
+		"Available settings: %v":                              "Verfügbare Einstellungen: %v",
+		"Settings":                                            "Einstellungen",
+		"Settings can't be changed at runtime.":               "Einstellungen können nicht zur Laufzeit geändert werden.",
+		"`%v` isn't set.":                                     "`%v` ist nicht gesetzt.",
+		"Failed to store the setting, check the logs.":        "Die Einstellung konnte nicht gespeichert werden, siehe Logs.",
+		"Invalid setting, nothing was changed: %v":            "Ungültige Einstellung, nichts wurde geändert: %v",
+		"Set `%v`.": "`%v` wurde gesetzt.",
+		"Unset `%v`, the config file value is used again.": "`%v` wurde zurückgesetzt, der Wert der Konfigurationsdatei gilt wieder.",
+		"Member Stats": "Mitgliederstatistik",
+		"Members":      "Mitglieder",
+		"Last %v days": "Letzte %v Tage",
+		"Joins: %v\nLeaves: %v\nNet growth: %+d\nChurn: %.1f%%":                    "Beitritte: %v\nAustritte: %v\nNettowachstum: %+d\nAbwanderung: %.1f %%",
+		"Failed to load stats, check the logs.":                                    "Die Statistik konnte nicht geladen werden, siehe Logs.",
+		"Member report from %v to %v.":                                             "Mitgliederbericht vom %v bis %v.",
+		"Failed to watch the user, check the logs.":                                "Die Person konnte nicht beobachtet werden, siehe Logs.",
+		"<@%v> is already watched.":                                                "<@%v> wird bereits beobachtet.",
+		"Watching <@%v>, their joins, leaves, and renames will be sent as alerts.": "<@%v> wird beobachtet, Beitritte, Austritte und Namensänderungen werden als Warnungen gesendet.",
+		"Failed to unwatch the user, check the logs.":                              "Die Beobachtung konnte nicht beendet werden, siehe Logs.",
+		"<@%v> isn't watched.":                                                     "<@%v> wird nicht beobachtet.",
+		"Stopped watching <@%v>.":                                                  "<@%v> wird nicht mehr beobachtet.",
+		"Failed to load the watch list, check the logs.":                           "Die Beobachtungsliste konnte nicht geladen werden, siehe Logs.",
+		"Nobody is watched.":                                                       "Niemand wird beobachtet.",
+		"<@%v> by <@%v> <t:%v:d>":                                                  "<@%v> von <@%v> <t:%v:d>",
+		"Watched Users":                                                            "Beobachtete Personen",
+		"Show how many new members stay":                                           "Anzeigen, wie viele neue Mitglieder bleiben",
+		"Failed to load retention, check the logs.":                                "Die Bindung konnte nicht geladen werden, siehe Logs.",
+		"Not enough joins yet":                                                     "Noch nicht genug Beitritte",
+		"%.1f%% (%v of %v)":                                                        "%.1f %% (%v von %v)",
+		"Nobody left":                                                              "Niemand ist gegangen",
+		"%v (%v left)":                                                             "%v (%v gegangen)",
+		"%v: %v of %v":                                                             "%v: %v von %v",
+		"Member Retention":                                                         "Mitgliederbindung",
+		"Stayed 7 days":                                                            "7 Tage geblieben",
+		"Stayed 30 days":                                                           "30 Tage geblieben",
+		"Median stay of leavers":                                                   "Mittlere Dauer bis zum Austritt",
+		"Still here, by join month":                                                "Noch da, nach Beitrittsmonat",
+		"Joins of the last %v months":                                              "Beitritte der letzten %v Monate",
+		"List the longest-standing members":                                        "Die dienstältesten Mitglieder auflisten",
+		"Longest-Standing Members":                                                 "Dienstälteste Mitglieder",
+		"Previous":                                                                 "Zurück",
+		"Next":                                                                     "Weiter",
+		"Show everything known about a user":                                       "Alles anzeigen, was über eine Person bekannt ist",
+		"Failed to look up the user, check the logs.":                              "Die Person konnte nicht nachgeschlagen werden, siehe Logs.",
+		"Nothing is known about <@%v>.":                                            "Über <@%v> ist nichts bekannt.",
+		"Not a member":                                                             "Kein Mitglied",
+		"Member":                                                                   "Mitglied",
+		"Member since <t:%v:D>":                                                    "Mitglied seit <t:%v:D>",
+		"Still here":                                                               "Noch da",
+		"None recorded":                                                            "Keine gespeichert",
+		"Invite used":                                                              "Verwendete Einladung",
+		"Unknown":                                                                  "Unbekannt",
+		"`%v` by <@%v>":                                                            "`%v` von <@%v>",
+		"Status":                                                                   "Status",
+		"First seen":                                                               "Zuerst gesehen",
+		"Last seen":                                                                "Zuletzt gesehen",
+		"Joins / leaves":                                                           "Beitritte / Austritte",
+		"Roles at last leave":                                                      "Rollen beim letzten Austritt",
+		"User Lookup":                                                              "Personenabfrage",
+		"Pick the announcement channel, style, and events (admin only)":       "Ankündigungskanal, Stil und Ereignisse wählen (nur Admins)",
+		"Setup is done. Single settings can be changed with /userlog config.": "Die Einrichtung ist fertig. Einzelne Einstellungen können mit /userlog config geändert werden.",
+		"Default":                         "Standard",
+		"Compact":                         "Kompakt",
+		"Mention, name, and member count": "Erwähnung, Name und Mitgliederzahl",
+		"Just the mention":                "Nur die Erwähnung",
+		"Custom":                          "Eigene",
+		"Write your own templates":        "Eigene Vorlagen schreiben",
+		"Pick where and how members are announced. Changes are saved right away and override the config file.": "Wähle, wo und wie Mitglieder angekündigt werden. Änderungen werden sofort gespeichert und überschreiben die Konfigurationsdatei.",
+		"Only the first %v channels are listed, set others with /userlog config set channel_id.":               "Nur die ersten %v Kanäle werden angezeigt, andere können mit /userlog config set channel_id gesetzt werden.",
+		"Setup":                                                    "Einrichtung",
+		"Announcement channel":                                     "Ankündigungskanal",
+		"Announcement style":                                       "Ankündigungsstil",
+		"Announced events":                                         "Angekündigte Ereignisse",
+		"Language":                                                 "Sprache",
+		"More options":                                             "Weitere Optionen",
+		"Done":                                                     "Fertig",
+		"Announcement templates":                                   "Ankündigungsvorlagen",
+		"Join template, empty for the default":                     "Beitrittsvorlage, leer für den Standard",
+		"Leave template, empty for the default":                    "Austrittsvorlage, leer für den Standard",
+		"Quiet hours, like 01:00-08:00":                            "Ruhezeiten, wie 01:00-08:00",
+		"Announce every N members":                                 "Alle N Mitglieder ankündigen",
+		"Timezone, like Europe/Berlin":                             "Zeitzone, wie Europe/Berlin",
+		"Show when a user was last seen online":                    "Anzeigen, wann eine Person zuletzt online gesehen wurde",
+		"Presence tracking is off.":                                "Die Online-Status-Erfassung ist aus.",
+		"Failed to load the presence, check the logs.":             "Der Online-Status konnte nicht geladen werden, siehe Logs.",
+		"<@%v> wasn't seen online yet.":                            "<@%v> wurde noch nicht online gesehen.",
+		"<@%v> is online, since <t:%v:R>.":                         "<@%v> ist online, seit <t:%v:R>.",
+		"<@%v> was last seen online <t:%v:f> (<t:%v:R>).":          "<@%v> wurde zuletzt am <t:%v:f> (<t:%v:R>) online gesehen.",
+		"First message":                                            "Erste Nachricht",
+		"Never posted":                                             "Nie geschrieben",
+		"<t:%v:f> in <#%v>, %v after joining":                      "<t:%v:f> in <#%v>, %v nach dem Beitritt",
+		"Onboarding, last 30 days":                                 "Onboarding, letzte 30 Tage",
+		"Tracked joins: %v\nPosted: %v (%.1f%%)\nNever posted: %v": "Erfasste Beitritte: %v\nGeschrieben: %v (%.1f %%)\nNie geschrieben: %v",
+		"Median time to first post: %v":                            "Mittlere Zeit bis zur ersten Nachricht: %v",
+		"List members without activity, with a CSV export":         "Mitglieder ohne Aktivität auflisten, mit CSV-Export",
+		"How long members have been inactive (default 90d)":        "Wie lange Mitglieder inaktiv sind (Standard 90 Tage)",
+		"180 days": "180 Tage",
+		"No activity is tracked, turn on first message tracking, presence tracking, or voice logging.": "Es wird keine Aktivität erfasst, aktiviere die Erfassung erster Nachrichten, der Anwesenheit oder das Sprachprotokoll.",
+		"Failed to load the activity, check the logs.":                                                 "Die Aktivität konnte nicht geladen werden, siehe Logs.",
+		"%v had no activity in the last %v days.":                                                      "%v ohne Aktivität in den letzten %v Tagen.",
+		"never active":                                "nie aktiv",
+		"…and %v more, see the CSV.":                  "…und %v weitere, siehe CSV.",
+		"Inactive Members":                            "Inaktive Mitglieder",
+		"Activity is only known since it is tracked.": "Aktivität ist erst seit Beginn der Erfassung bekannt.",
+		"An anonymized member":                        "Ein anonymisiertes Mitglied",
+		"Not enough activity":                         "Zu wenig Aktivität",
+		"Too many notifications":                      "Zu viele Benachrichtigungen",
+		"Didn't find what I was looking for":          "Nicht gefunden, was ich gesucht habe",
+		"Something else":                              "Etwas anderes",
+		"Sorry to see you go! If you have a moment, why did you leave?":       "Schade, dass du gehst! Wenn du kurz Zeit hast: Warum bist du gegangen?",
+		"%v left and couldn't be asked why. If you know the reason, pick it:": "%v ist gegangen und konnte nicht nach dem Grund gefragt werden. Wenn du ihn kennst, wähle ihn aus:",
+		"Thanks for letting us know!":                                         "Danke für deine Rückmeldung!",
+		"Sorry, your answer couldn't be saved.":                               "Deine Antwort konnte leider nicht gespeichert werden.",
+		"This survey has expired.":                                            "Diese Umfrage ist abgelaufen.",
+		"Failed to save the reason, check the logs.":                          "Der Grund konnte nicht gespeichert werden, siehe Logs.",
+		"<@%v> left: %v (noted by <@%v>)":                                     "<@%v> ist gegangen: %v (notiert von <@%v>)",
+		"Reason for last leave":                                               "Grund des letzten Verlassens",
+		"Reasons for leaving":                                                 "Gründe fürs Verlassen",
+		"Show who is interested in scheduled events":                          "Zeigen, wer sich für geplante Events interessiert",
+		"Event name or ID, lists the latest events without it":                "Name oder ID des Events, ohne werden die neuesten Events aufgelistet",
+		"Scheduled event tracking is off.":                                    "Die Erfassung geplanter Events ist aus.",
+		"Failed to load the scheduled events, check the logs.":                "Die geplanten Events konnten nicht geladen werden, siehe Logs.",
+		"No scheduled event %v was recorded.":                                 "Kein geplantes Event %v wurde erfasst.",
+		"<@%v> interested since <t:%v:d>":                                     "<@%v> interessiert seit <t:%v:d>",
+		"~~<@%v>~~ withdrew <t:%v:d>":                                         "~~<@%v>~~ zurückgezogen <t:%v:d>",
+		"Nobody RSVPed yet.":                                                  "Noch niemand hat Interesse angemeldet.",
+		"%v interested, %v withdrew":                                          "%v interessiert, %v zurückgezogen",
+		"No scheduled events were recorded yet.":                              "Es wurden noch keine geplanten Events erfasst.",
+		"**%v**: %v interested":                                               "**%v**: %v interessiert",
+		"**%v** <t:%v:f>: %v interested":                                      "**%v** <t:%v:f>: %v interessiert",
+		"Scheduled Events":                                                    "Geplante Events",
+		"Event %v":                                                            "Event %v",
+		"Read the bot token again and reconnect with it (bot owner only)":     "Bot-Token neu einlesen und damit neu verbinden (nur Bot-Besitzer)",
+		"Only the bot owner can reload the token.":                            "Nur der Besitzer des Bots kann den Token neu laden.",
+		"Token reloading is off.":                                             "Das Neuladen des Tokens ist aus.",
+		"The token didn't change.":                                            "Der Token hat sich nicht geändert.",
+		"Failed to reload the token, check the logs.":                         "Der Token konnte nicht neu geladen werden, siehe Logs.",
+		"Switched to the new token, reconnecting.":                            "Zum neuen Token gewechselt, verbinde neu.",
+		"Ban":                                  "Bannen",
+		"Failed to ban <@%v>, check the logs.": "<@%v> konnte nicht gebannt werden, siehe Logs.",
+		"Banned <@%v>.":                        "<@%v> wurde gebannt.",
+		"Note":                                 "Notiz",
+		"Notes":                                "Notizen",
+		"Note, only moderators see it":         "Notiz, nur Moderatoren sehen sie",
+		"The note is empty.":                   "Die Notiz ist leer.",
+		"Failed to save the note, check the logs.":        "Die Notiz konnte nicht gespeichert werden, siehe Logs.",
+		"Noted about <@%v>.":                              "Notiz zu <@%v> gespeichert.",
+		"Notes can be at most %v characters long.":        "Notizen dürfen höchstens %v Zeichen lang sein.",
+		"<t:%v:d> by <@%v>: %v":                           "<t:%v:d> von <@%v>: %v",
+		"Keep notes about users, shown by /userlog whois": "Notizen zu Nutzern festhalten, angezeigt von /userlog whois",
+		"Add a note about a user, only moderators see it": "Notiz zu einem Nutzer hinzufügen, nur Moderatoren sehen sie",
+		"User (or user ID) the note is about":             "Nutzer (oder Nutzer-ID), um den es geht",
+		"The note":                                        "Die Notiz",
+		"List who joined and left since an earlier daily member list, with a CSV export": "Auflisten, wer seit einer früheren täglichen Mitgliederliste beigetreten ist und wer gegangen ist, mit CSV-Export",
+		"How far back to compare (default 7d)":                                           "Wie weit zurück verglichen wird (Standard 7 Tage)",
+		"1 day":                                                                          "1 Tag",
+		"7 days":                                                                         "7 Tage",
+		"Failed to load the member list, check the logs.":                                "Die Mitgliederliste konnte nicht geladen werden, siehe Logs.",
+		"There is no member list from %v days ago yet. Syncs record one a day, kept for %v days.": "Es gibt noch keine Mitgliederliste von vor %v Tagen. Synchronisierungen speichern eine pro Tag, sie werden %v Tage aufbewahrt.",
+		"Joined (%v)":             "Beigetreten (%v)",
+		"Left (%v)":               "Gegangen (%v)",
+		"Member Changes Since %v": "Mitgliederänderungen seit %v",
+		"Compared with the member list of %v, %v → %v members": "Verglichen mit der Mitgliederliste vom %v, %v → %v Mitglieder",
+	},
+}
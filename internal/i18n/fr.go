@@ -0,0 +1,263 @@
+package i18n
+
+var french = &Language{
+	Code: "fr",
+	// a narrow no-break space, like 1 234
+	thousands: "\u202f",
+	units: [6][2]string{
+		{"an", "ans"},
+		{"mois", "mois"},
+		{"jour", "jours"},
+		{"heure", "heures"},
+		{"minute", "minutes"},
+		{"seconde", "secondes"},
+	},
+	member:       [2]string{"membre", "membres"},
+	singularZero: true,
+	templates: map[string]string{
+		"join":               "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a rejoint le serveur{{if .Pending}}, en attente de la vérification d'adhésion{{end}}{{if .MemberCount}}, désormais {{.Members}}{{end}}",
+		"leave":              "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a quitté le serveur{{if .Stay}} après {{duration .Stay}} en tant que membre{{end}}{{if .MemberCount}}, désormais {{.Members}}{{end}}",
+		"milestone":          "🎉 Nous venons d'atteindre {{number .MemberCount}} membres ! Bienvenue <@{{.ID}}>",
+		"mass_leave":         "🚨 {{number .Count}} membres sont partis en {{duration .Window}}, il en reste {{number .MemberCount}}",
+		"boost_start":        "💎 <@{{.ID}}> a commencé à booster le serveur, merci !",
+		"boost_stop":         "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a arrêté de booster le serveur",
+		"timeout":            "⏳ <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a été exclu temporairement pendant {{duration .Timeout}}, jusqu'au <t:{{.Until.Unix}}:f>",
+		"timeout_end":        "L'exclusion temporaire de <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a été levée",
+		"screening_complete": "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a terminé la vérification d'adhésion",
+		"rename":             "✏️ <@{{.ID}}> a changé {{if eq .NameKind \"username\"}}de nom d'utilisateur{{else}}de pseudo{{end}} de `{{or .OldName \"rien\"}}` à `{{or .NewName \"rien\"}}`",
+		"avatar_change":      "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a changé d'avatar{{with .AvatarURL}} {{.}}{{end}}",
+		"anniversary":        "🎂 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} est parmi nous depuis {{.Years}} {{if eq .Years 1}}an{{else}}ans{{end}} aujourd'hui !",
+		"sync_summary":       "🔄 Une synchronisation a trouvé {{number .Count}} événements manqués, {{number .Joins}} arrivées et {{number .Leaves}} départs, désormais {{.Members}}",
+		"leave_edit":         " — parti après {{duration .Stay}}",
+		"watched_join":       "{{with .Ping}}{{.}} {{end}}👀 L'utilisateur surveillé <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a rejoint le serveur",
+		"watched_leave":      "{{with .Ping}}{{.}} {{end}}👀 L'utilisateur surveillé <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a quitté le serveur",
+		"watched_rename":     "{{with .Ping}}{{.}} {{end}}👀 L'utilisateur surveillé <@{{.ID}}> a changé {{if eq .NameKind \"username\"}}de nom d'utilisateur{{else}}de pseudo{{end}} de `{{or .OldName \"rien\"}}` à `{{or .NewName \"rien\"}}`",
+		"cross_guild_join":   "⚠️ <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a rejoint le serveur{{if .MemberCount}}, désormais {{.Members}}{{end}}. {{if eq .OtherEvent \"ban\"}}Banni de{{else}}A quitté{{end}} {{.OtherGuild}} <t:{{.OtherAt.Unix}}:R>",
+		"voice_join":         "🔊 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a rejoint <#{{.ChannelID}}>",
+		"voice_leave":        "🔇 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a quitté <#{{.ChannelID}}>",
+		"voice_move":         "🔀 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} est passé de <#{{.OldChannelID}}> à <#{{.ChannelID}}>",
+		"ban":                "🔨 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a été banni{{with .ModeratorID}} par <@{{.}}>{{end}}{{with .Reason}} : {{.}}{{end}}",
+		"unban":              "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a été débanni{{with .ModeratorID}} par <@{{.}}>{{end}}{{with .Reason}} : {{.}}{{end}}",
+		"role_add":           "🏷️ <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a reçu le rôle {{or .RoleName .RoleID}}{{with .ModeratorID}} de <@{{.}}>{{end}}",
+		"role_remove":        "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} a perdu le rôle {{or .RoleName .RoleID}}{{with .ModeratorID}}, retiré par <@{{.}}>{{end}}",
+	},
+	messages: map[string]string{
+		"User Log commands": "Commandes de User Log",
+		"Delete everything stored about a user (admin only)":           "Supprimer tout ce qui est enregistré sur un utilisateur (admins uniquement)",
+		"User (or user ID) to forget":                                  "Utilisateur (ou ID) à oublier",
+		"Show member growth and churn":                                 "Afficher la croissance et l'attrition des membres",
+		"Show the latest joins and leaves":                             "Afficher les dernières arrivées et les derniers départs",
+		"Events per page (default %v)":                                 "Événements par page (%v par défaut)",
+		"List every username and nickname seen for a user":             "Lister tous les noms d'utilisateur et pseudos vus pour un utilisateur",
+		"User (or user ID) to look up":                                 "Utilisateur (ou ID) à rechercher",
+		"Show a chart of the member count":                             "Afficher un graphique du nombre de membres",
+		"Attach an HTML report of the last month":                      "Joindre un rapport HTML du dernier mois",
+		"How far back to go (default 30d)":                             "Période à afficher (30 jours par défaut)",
+		"30 days":                                                      "30 jours",
+		"90 days":                                                      "90 jours",
+		"1 year":                                                       "1 an",
+		"Send alerts when a user joins, leaves, or changes their name": "Envoyer des alertes quand un utilisateur arrive, part ou change de nom",
+		"User (or user ID) to watch":                                   "Utilisateur (ou ID) à surveiller",
+		"Stop watching a user":                                         "Arrêter de surveiller un utilisateur",
+		"User (or user ID) to stop watching":                           "Utilisateur (ou ID) à ne plus surveiller",
+		"List the watched users":                                       "Lister les utilisateurs surveillés",
+		"Change this server's settings (admin only)":                   "Modifier les paramètres de ce serveur (admins uniquement)",
+		"Show the settings changed with /userlog config":               "Afficher les paramètres modifiés avec /userlog config",
+		"Change a setting, overriding the config file":                 "Modifier un paramètre, à la place du fichier de configuration",
+		"Setting name, like channel_id or template_join":               "Nom du paramètre, comme channel_id ou template_join",
+		"New value, lists are comma-separated":                         "Nouvelle valeur, les listes sont séparées par des virgules",
+		"Go back to the config file value of a setting":                "Revenir à la valeur du fichier de configuration",
+		"Setting name":                                                 "Nom du paramètre",
+
+		"Unknown command.": "Commande inconnue.",
+		"You don't have permission to use this command.":                                   "Tu n'as pas la permission d'utiliser cette commande.",
+		"Failed to forget <@%v>, check the logs.":                                          "Impossible d'oublier <@%v>, consulte les logs.",
+		"Nothing was stored about <@%v>.":                                                  "Rien n'était enregistré sur <@%v>.",
+		"Forgot everything this server stored about <@%v>.":                                "Tout ce que ce serveur avait enregistré sur <@%v> a été oublié.",
+		"Forgot everything this server stored about <@%v>, except that they are a member.": "Tout ce que ce serveur avait enregistré sur <@%v> a été oublié, sauf son adhésion.",
+		"Unknown period.": "Période inconnue.",
+		"Failed to load the member count history, check the logs.": "Impossible de charger l'historique du nombre de membres, consulte les logs.",
+		"Failed to draw the graph, check the logs.":                "Impossible de dessiner le graphique, consulte les logs.",
+		"Members, Last %v Days":                                    "Membres, %v derniers jours",
+		"%v to %v: %v → **%v** members (%v)\nLow %v, high %v":      "Du %v au %v : %v → **%v** membres (%v)\nMinimum %v, maximum %v",
+		"Failed to load names, check the logs.":                    "Impossible de charger les noms, consulte les logs.",
+		"No names were seen for <@%v>.":                            "Aucun nom n'a été vu pour <@%v>.",
+		"Usernames":                                                "Noms d'utilisateur",
+		"Nicknames":                                                "Pseudos",
+		"Name History":                                             "Historique des noms",
+		"First seen – last seen":                                   "Vu pour la première fois – vu pour la dernière fois",
+		"Unknown page.":                                            "Page inconnue.",
+		"Failed to load recent events, check the logs.":            "Impossible de charger les derniers événements, consulte les logs.",
+		"joined":                  "est arrivé",
+		"left":                    "est parti",
+		"Nothing here.":           "Rien ici.",
+		"Recent Joins and Leaves": "Arrivées et départs récents",
+		"Page %v":                 "Page %v",
+		"Newer":                   "Plus récents",
+		"Older":                   "Plus anciens",
+		"Failed to load settings, check the logs.":            "Impossible de charger les paramètres, consulte les logs.",
+		"Nothing was changed, the config file is used as-is.": "Rien n'a été modifié, le fichier de configuration est utilisé tel quel.",
+		"Available settings: %v":                              "Paramètres disponibles : %v",
+		"Settings":                                            "Paramètres",
+		"Settings can't be changed at runtime.":               "Les paramètres ne peuvent pas être modifiés pendant l'exécution.",
+		"`%v` isn't set.":                                     "`%v` n'est pas défini.",
+		"Failed to store the setting, check the logs.":        "Impossible d'enregistrer le paramètre, consulte les logs.",
+		"Invalid setting, nothing was changed: %v":            "Paramètre invalide, rien n'a été modifié : %v",
+		"Set `%v`.": "`%v` a été défini.",
+		"Unset `%v`, the config file value is used again.": "`%v` a été réinitialisé, la valeur du fichier de configuration est de nouveau utilisée.",
+		"Member Stats": "Statistiques des membres",
+		"Members":      "Membres",
+		"Last %v days": "%v derniers jours",
+		"Joins: %v\nLeaves: %v\nNet growth: %+d\nChurn: %.1f%%":                    "Arrivées : %v\nDéparts : %v\nCroissance nette : %+d\nAttrition : %.1f %%",
+		"Failed to load stats, check the logs.":                                    "Impossible de charger les statistiques, consulte les logs.",
+		"Member report from %v to %v.":                                             "Rapport des membres du %v au %v.",
+		"Failed to watch the user, check the logs.":                                "Impossible de surveiller l'utilisateur, consulte les logs.",
+		"<@%v> is already watched.":                                                "<@%v> est déjà surveillé.",
+		"Watching <@%v>, their joins, leaves, and renames will be sent as alerts.": "<@%v> est surveillé, ses arrivées, départs et changements de nom seront envoyés comme alertes.",
+		"Failed to unwatch the user, check the logs.":                              "Impossible d'arrêter de surveiller l'utilisateur, consulte les logs.",
+		"<@%v> isn't watched.":                                                     "<@%v> n'est pas surveillé.",
+		"Stopped watching <@%v>.":                                                  "<@%v> n'est plus surveillé.",
+		"Failed to load the watch list, check the logs.":                           "Impossible de charger la liste de surveillance, consulte les logs.",
+		"Nobody is watched.":                                                       "Personne n'est surveillé.",
+		"<@%v> by <@%v> <t:%v:d>":                                                  "<@%v> par <@%v> <t:%v:d>",
+		"Watched Users":                                                            "Utilisateurs surveillés",
+		"Show how many new members stay":                                           "Afficher combien de nouveaux membres restent",
+		"Failed to load retention, check the logs.":                                "Impossible de charger la rétention, consulte les logs.",
+		"Not enough joins yet":                                                     "Pas encore assez d'arrivées",
+		"%.1f%% (%v of %v)":                                                        "%.1f %% (%v sur %v)",
+		"Nobody left":                                                              "Personne n'est parti",
+		"%v (%v left)":                                                             "%v (%v départs)",
+		"%v: %v of %v":                                                             "%v : %v sur %v",
+		"Member Retention":                                                         "Rétention des membres",
+		"Stayed 7 days":                                                            "Restés 7 jours",
+		"Stayed 30 days":                                                           "Restés 30 jours",
+		"Median stay of leavers":                                                   "Durée médiane avant départ",
+		"Still here, by join month":                                                "Toujours là, par mois d'arrivée",
+		"Joins of the last %v months":                                              "Arrivées des %v derniers mois",
+		"List the longest-standing members":                                        "Lister les membres les plus anciens",
+		"Longest-Standing Members":                                                 "Membres les plus anciens",
+		"Previous":                                                                 "Précédent",
+		"Next":                                                                     "Suivant",
+		"Show everything known about a user":                                       "Afficher tout ce qui est connu sur un utilisateur",
+		"Failed to look up the user, check the logs.":                              "Impossible de rechercher l'utilisateur, consultez les journaux.",
+		"Nothing is known about <@%v>.":                                            "Rien n'est connu sur <@%v>.",
+		"Not a member":                                                             "Pas membre",
+		"Member":                                                                   "Membre",
+		"Member since <t:%v:D>":                                                    "Membre depuis <t:%v:D>",
+		"Still here":                                                               "Toujours là",
+		"None recorded":                                                            "Aucun enregistré",
+		"Invite used":                                                              "Invitation utilisée",
+		"Unknown":                                                                  "Inconnue",
+		"`%v` by <@%v>":                                                            "`%v` de <@%v>",
+		"Status":                                                                   "Statut",
+		"First seen":                                                               "Vu pour la première fois",
+		"Last seen":                                                                "Vu pour la dernière fois",
+		"Joins / leaves":                                                           "Arrivées / départs",
+		"Roles at last leave":                                                      "Rôles au dernier départ",
+		"User Lookup":                                                              "Recherche d'utilisateur",
+		"Pick the announcement channel, style, and events (admin only)":       "Choisir le salon, le style et les événements annoncés (admins)",
+		"Setup is done. Single settings can be changed with /userlog config.": "La configuration est terminée. Chaque paramètre peut être modifié avec /userlog config.",
+		"Default":                         "Par défaut",
+		"Compact":                         "Compact",
+		"Mention, name, and member count": "Mention, nom et nombre de membres",
+		"Just the mention":                "Juste la mention",
+		"Custom":                          "Personnalisé",
+		"Write your own templates":        "Écrire vos propres modèles",
+		"Pick where and how members are announced. Changes are saved right away and override the config file.": "Choisissez où et comment les membres sont annoncés. Les changements sont enregistrés immédiatement et remplacent le fichier de configuration.",
+		"Only the first %v channels are listed, set others with /userlog config set channel_id.":               "Seuls les %v premiers salons sont listés, définissez les autres avec /userlog config set channel_id.",
+		"Setup":                                                    "Configuration",
+		"Announcement channel":                                     "Salon des annonces",
+		"Announcement style":                                       "Style des annonces",
+		"Announced events":                                         "Événements annoncés",
+		"Language":                                                 "Langue",
+		"More options":                                             "Plus d'options",
+		"Done":                                                     "Terminé",
+		"Announcement templates":                                   "Modèles d'annonce",
+		"Join template, empty for the default":                     "Modèle d'arrivée, vide par défaut",
+		"Leave template, empty for the default":                    "Modèle de départ, vide par défaut",
+		"Quiet hours, like 01:00-08:00":                            "Heures calmes, comme 01:00-08:00",
+		"Announce every N members":                                 "Annoncer tous les N membres",
+		"Timezone, like Europe/Berlin":                             "Fuseau horaire, comme Europe/Berlin",
+		"Show when a user was last seen online":                    "Afficher quand un utilisateur a été vu en ligne pour la dernière fois",
+		"Presence tracking is off.":                                "Le suivi de présence est désactivé.",
+		"Failed to load the presence, check the logs.":             "Impossible de charger la présence, consulte les logs.",
+		"<@%v> wasn't seen online yet.":                            "<@%v> n'a pas encore été vu en ligne.",
+		"<@%v> is online, since <t:%v:R>.":                         "<@%v> est en ligne, depuis <t:%v:R>.",
+		"<@%v> was last seen online <t:%v:f> (<t:%v:R>).":          "<@%v> a été vu en ligne pour la dernière fois le <t:%v:f> (<t:%v:R>).",
+		"First message":                                            "Premier message",
+		"Never posted":                                             "N'a jamais écrit",
+		"<t:%v:f> in <#%v>, %v after joining":                      "<t:%v:f> dans <#%v>, %v après l'arrivée",
+		"Onboarding, last 30 days":                                 "Intégration, 30 derniers jours",
+		"Tracked joins: %v\nPosted: %v (%.1f%%)\nNever posted: %v": "Arrivées suivies : %v\nOnt écrit : %v (%.1f %%)\nN'ont jamais écrit : %v",
+		"Median time to first post: %v":                            "Délai médian avant le premier message : %v",
+		"List members without activity, with a CSV export":         "Lister les membres sans activité, avec un export CSV",
+		"How long members have been inactive (default 90d)":        "Depuis combien de temps les membres sont inactifs (90 jours par défaut)",
+		"180 days": "180 jours",
+		"No activity is tracked, turn on first message tracking, presence tracking, or voice logging.": "Aucune activité n'est suivie, active le suivi des premiers messages, de la présence ou le journal vocal.",
+		"Failed to load the activity, check the logs.":                                                 "Impossible de charger l'activité, consulte les logs.",
+		"%v had no activity in the last %v days.":                                                      "%v sans activité ces %v derniers jours.",
+		"never active":                                "jamais actif",
+		"…and %v more, see the CSV.":                  "…et %v de plus, voir le CSV.",
+		"Inactive Members":                            "Membres inactifs",
+		"Activity is only known since it is tracked.": "L'activité n'est connue que depuis qu'elle est suivie.",
+		"An anonymized member":                        "Un membre anonymisé",
+		"Not enough activity":                         "Pas assez d'activité",
+		"Too many notifications":                      "Trop de notifications",
+		"Didn't find what I was looking for":          "Je n'ai pas trouvé ce que je cherchais",
+		"Something else":                              "Autre chose",
+		"Sorry to see you go! If you have a moment, why did you leave?":       "Dommage de te voir partir ! Si tu as un instant, pourquoi es-tu parti ?",
+		"%v left and couldn't be asked why. If you know the reason, pick it:": "%v est parti et n'a pas pu être interrogé. Si vous connaissez la raison, choisissez-la :",
+		"Thanks for letting us know!":                                         "Merci de nous l'avoir dit !",
+		"Sorry, your answer couldn't be saved.":                               "Désolé, ta réponse n'a pas pu être enregistrée.",
+		"This survey has expired.":                                            "Ce sondage a expiré.",
+		"Failed to save the reason, check the logs.":                          "Impossible d'enregistrer la raison, consultez les journaux.",
+		"<@%v> left: %v (noted by <@%v>)":                                     "<@%v> est parti : %v (noté par <@%v>)",
+		"Reason for last leave":                                               "Raison du dernier départ",
+		"Reasons for leaving":                                                 "Raisons des départs",
+		"Show who is interested in scheduled events":                          "Afficher qui est intéressé par les événements programmés",
+		"Event name or ID, lists the latest events without it":                "Nom ou ID de l'événement, sans lui les derniers événements sont listés",
+		"Scheduled event tracking is off.":                                    "Le suivi des événements programmés est désactivé.",
+		"Failed to load the scheduled events, check the logs.":                "Impossible de charger les événements programmés, consulte les logs.",
+		"No scheduled event %v was recorded.":                                 "Aucun événement programmé %v n'a été enregistré.",
+		"<@%v> interested since <t:%v:d>":                                     "<@%v> intéressé depuis le <t:%v:d>",
+		"~~<@%v>~~ withdrew <t:%v:d>":                                         "~~<@%v>~~ s'est retiré le <t:%v:d>",
+		"Nobody RSVPed yet.":                                                  "Personne ne s'est encore inscrit.",
+		"%v interested, %v withdrew":                                          "%v intéressés, %v retirés",
+		"No scheduled events were recorded yet.":                              "Aucun événement programmé n'a encore été enregistré.",
+		"**%v**: %v interested":                                               "**%v** : %v intéressés",
+		"**%v** <t:%v:f>: %v interested":                                      "**%v** <t:%v:f> : %v intéressés",
+		"Scheduled Events":                                                    "Événements programmés",
+		"Event %v":                                                            "Événement %v",
+		"Read the bot token again and reconnect with it (bot owner only)":     "Relire le jeton du bot et se reconnecter avec (propriétaire du bot uniquement)",
+		"Only the bot owner can reload the token.":                            "Seul le propriétaire du bot peut recharger le jeton.",
+		"Token reloading is off.":                                             "Le rechargement du jeton est désactivé.",
+		"The token didn't change.":                                            "Le jeton n'a pas changé.",
+		"Failed to reload the token, check the logs.":                         "Impossible de recharger le jeton, consulte les logs.",
+		"Switched to the new token, reconnecting.":                            "Nouveau jeton utilisé, reconnexion en cours.",
+		"Ban":                                  "Bannir",
+		"Failed to ban <@%v>, check the logs.": "Impossible de bannir <@%v>, consulte les logs.",
+		"Banned <@%v>.":                        "<@%v> a été banni.",
+		"Note":                                 "Note",
+		"Notes":                                "Notes",
+		"Note, only moderators see it":         "Note, visible des modérateurs seulement",
+		"The note is empty.":                   "La note est vide.",
+		"Failed to save the note, check the logs.":        "Impossible d'enregistrer la note, consulte les logs.",
+		"Noted about <@%v>.":                              "Note sur <@%v> enregistrée.",
+		"Notes can be at most %v characters long.":        "Les notes font au plus %v caractères.",
+		"<t:%v:d> by <@%v>: %v":                           "<t:%v:d> par <@%v> : %v",
+		"Keep notes about users, shown by /userlog whois": "Garder des notes sur les utilisateurs, affichées par /userlog whois",
+		"Add a note about a user, only moderators see it": "Ajouter une note sur un utilisateur, visible des modérateurs seulement",
+		"User (or user ID) the note is about":             "Utilisateur (ou ID) concerné par la note",
+		"The note":                                        "La note",
+		"List who joined and left since an earlier daily member list, with a CSV export": "Lister qui est arrivé et parti depuis une liste quotidienne des membres antérieure, avec un export CSV",
+		"How far back to compare (default 7d)":                                           "Jusqu'où comparer (7 jours par défaut)",
+		"1 day":                                                                          "1 jour",
+		"7 days":                                                                         "7 jours",
+		"Failed to load the member list, check the logs.":                                "Impossible de charger la liste des membres, consulte les logs.",
+		"There is no member list from %v days ago yet. Syncs record one a day, kept for %v days.": "Il n'y a pas encore de liste des membres d'il y a %v jours. Les synchronisations en enregistrent une par jour, gardée %v jours.",
+		"Joined (%v)":             "Arrivés (%v)",
+		"Left (%v)":               "Partis (%v)",
+		"Member Changes Since %v": "Changements de membres depuis le %v",
+		"Compared with the member list of %v, %v → %v members": "Comparé à la liste des membres du %v, %v → %v membres",
+	},
+}
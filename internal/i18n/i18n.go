@@ -0,0 +1,156 @@
+// Package i18n translates announcements and command responses.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Language is a message catalog, English strings without a translation stay in English
+type Language struct {
+	// Code is the Discord locale of the language, like "de" or "pt-BR"
+	Code string
+	// thousands separates groups of three digits in numbers
+	thousands string
+	// units are the singular and plural names of years, months, days, hours, minutes, and seconds
+	units [6][2]string
+	// member is the singular and plural of "member"
+	member [2]string
+	// singularZero uses the singular for 0, like "0 seconde"
+	singularZero bool
+	// messages translate English messages and format strings
+	messages map[string]string
+	// templates are the default announcement templates by event type, missing ones are English
+	templates map[string]string
+}
+
+// English is the default language
+var English = &Language{
+	Code:      "en",
+	thousands: ",",
+	units: [6][2]string{
+		{"year", "years"},
+		{"month", "months"},
+		{"day", "days"},
+		{"hour", "hours"},
+		{"minute", "minutes"},
+		{"second", "seconds"},
+	},
+	member: [2]string{"member", "members"},
+}
+
+// languages are keyed by code
+var languages = map[string]*Language{}
+
+func init() {
+	for _, language := range []*Language{English, german, french, portugueseBR} {
+		languages[language.Code] = language
+	}
+}
+
+// Lookup returns the language of a code like "de", the empty code is English
+func Lookup(code string) (*Language, bool) {
+	if code == "" {
+		return English, true
+	}
+	language, ok := languages[code]
+	return language, ok
+}
+
+// Codes returns the codes of all languages, sorted
+func Codes() []string {
+	codes := make([]string, 0, len(languages))
+	for code := range languages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Translate returns the translation of an English message
+func (l *Language) Translate(message string) string {
+	if translated, ok := l.messages[message]; ok {
+		return translated
+	}
+	return message
+}
+
+// Sprintf translates an English format string, then formats it
+func (l *Language) Sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(l.Translate(format), args...)
+}
+
+// Template returns the default announcement template of an event type, false if it should be the English default
+func (l *Language) Template(eventType string) (string, bool) {
+	template, ok := l.templates[eventType]
+	return template, ok
+}
+
+func (l *Language) plural(n int64) bool {
+	if l.singularZero {
+		return n > 1
+	}
+	return n != 1
+}
+
+// FormatNumber formats n with thousands separators, like 1,234
+func (l *Language) FormatNumber(n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var formatted strings.Builder
+	for i := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			formatted.WriteString(l.thousands)
+		}
+		formatted.WriteByte(digits[i])
+	}
+	return sign + formatted.String()
+}
+
+// Members formats a member count with its unit, like "1,234 members"
+func (l *Language) Members(n int) string {
+	if l.plural(int64(n)) {
+		return l.FormatNumber(n) + " " + l.member[1]
+	}
+	return l.FormatNumber(n) + " " + l.member[0]
+}
+
+// FormatDuration formats d with its two largest units, like "2 years, 3 months" or "5 minutes".
+// Months are 30 days and years are 365 days.
+func (l *Language) FormatDuration(d time.Duration) string {
+	sizes := []time.Duration{365 * 24 * time.Hour, 30 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	parts := []string{}
+	for i, size := range sizes {
+		if len(parts) == 2 {
+			break
+		}
+		n := d / size
+		if n == 0 {
+			if len(parts) > 0 {
+				// don't skip units, "1 year, 2 hours" reads oddly
+				break
+			}
+			continue
+		}
+		d -= n * size
+		parts = append(parts, l.unit(i, int64(n)))
+	}
+	if len(parts) == 0 {
+		return l.unit(len(sizes)-1, 0)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (l *Language) unit(i int, n int64) string {
+	if l.plural(n) {
+		return fmt.Sprintf("%v %v", n, l.units[i][1])
+	}
+	return fmt.Sprintf("%v %v", n, l.units[i][0])
+}
@@ -0,0 +1,80 @@
+package i18n
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+var formatVerb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestTranslations(t *testing.T) {
+	for _, code := range Codes() {
+		language, _ := Lookup(code)
+		if language == English {
+			continue
+		}
+		// every translation keeps the format verbs of its English message, in order
+		for message, translated := range language.messages {
+			if expected, actual := formatVerb.FindAllString(message, -1), formatVerb.FindAllString(translated, -1); !reflect.DeepEqual(expected, actual) {
+				t.Errorf("%v translation of %q has verbs %v, expected %v", code, message, actual, expected)
+			}
+		}
+		// and translates the same messages and templates as German
+		if expected, actual := keys(german.messages), keys(language.messages); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%v translates messages %v, expected %v", code, actual, expected)
+		}
+		if expected, actual := keys(german.templates), keys(language.templates); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%v translates templates %v, expected %v", code, actual, expected)
+		}
+	}
+}
+
+func keys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestLookup(t *testing.T) {
+	if language, ok := Lookup(""); !ok || language != English {
+		t.Errorf("expected the empty code to be English, got %v", language)
+	}
+	if language, ok := Lookup("pt-BR"); !ok || language.Translate("Settings") != "Configurações" {
+		t.Errorf("expected pt-BR to translate, got %v", language)
+	}
+	if _, ok := Lookup("xx"); ok {
+		t.Errorf("expected an unknown code to fail")
+	}
+	if actual := german.Translate("not a message"); actual != "not a message" {
+		t.Errorf("expected untranslated messages to stay English, got %q", actual)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		language *Language
+		number   string
+		members  [2]string
+		duration [2]string
+	}{
+		{English, "1,234,567", [2]string{"0 members", "1 member"}, [2]string{"0 seconds", "1 hour, 30 minutes"}},
+		{german, "1.234.567", [2]string{"0 Mitglieder", "1 Mitglied"}, [2]string{"0 Sekunden", "1 Stunde, 30 Minuten"}},
+		{french, "1 234 567", [2]string{"0 membre", "1 membre"}, [2]string{"0 seconde", "1 heure, 30 minutes"}},
+	} {
+		if actual := tc.language.FormatNumber(1234567); actual != tc.number {
+			t.Errorf("%v: FormatNumber(1234567) = %q, expected %q", tc.language.Code, actual, tc.number)
+		}
+		if actual := [2]string{tc.language.Members(0), tc.language.Members(1)}; actual != tc.members {
+			t.Errorf("%v: Members = %q, expected %q", tc.language.Code, actual, tc.members)
+		}
+		if actual := [2]string{tc.language.FormatDuration(0), tc.language.FormatDuration(90 * time.Minute)}; actual != tc.duration {
+			t.Errorf("%v: FormatDuration = %q, expected %q", tc.language.Code, actual, tc.duration)
+		}
+	}
+}
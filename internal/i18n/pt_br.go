@@ -0,0 +1,261 @@
+package i18n
+
+var portugueseBR = &Language{
+	Code:      "pt-BR",
+	thousands: ".",
+	units: [6][2]string{
+		{"ano", "anos"},
+		{"mês", "meses"},
+		{"dia", "dias"},
+		{"hora", "horas"},
+		{"minuto", "minutos"},
+		{"segundo", "segundos"},
+	},
+	member: [2]string{"membro", "membros"},
+	templates: map[string]string{
+		"join":               "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} entrou no servidor{{if .Pending}}, aguardando a triagem de associação{{end}}{{if .MemberCount}}, agora com {{.Members}}{{end}}",
+		"leave":              "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} saiu do servidor{{if .Stay}} depois de {{duration .Stay}} como membro{{end}}{{if .MemberCount}}, agora com {{.Members}}{{end}}",
+		"milestone":          "🎉 Acabamos de chegar a {{number .MemberCount}} membros! Boas-vindas, <@{{.ID}}>",
+		"mass_leave":         "🚨 {{number .Count}} membros saíram em {{duration .Window}}, restam {{number .MemberCount}}",
+		"boost_start":        "💎 <@{{.ID}}> começou a impulsionar o servidor, obrigado!",
+		"boost_stop":         "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} parou de impulsionar o servidor",
+		"timeout":            "⏳ <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} recebeu um castigo de {{duration .Timeout}}, até <t:{{.Until.Unix}}:f>",
+		"timeout_end":        "O castigo de <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} foi removido",
+		"screening_complete": "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} concluiu a triagem de associação",
+		"rename":             "✏️ <@{{.ID}}> mudou {{if eq .NameKind \"username\"}}o nome de usuário{{else}}o apelido{{end}} de `{{or .OldName \"nada\"}}` para `{{or .NewName \"nada\"}}`",
+		"avatar_change":      "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} mudou o avatar{{with .AvatarURL}} {{.}}{{end}}",
+		"anniversary":        "🎂 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} completa {{.Years}} {{if eq .Years 1}}ano{{else}}anos{{end}} aqui hoje!",
+		"sync_summary":       "🔄 Uma sincronização encontrou {{number .Count}} eventos perdidos, {{number .Joins}} entradas e {{number .Leaves}} saídas, agora com {{.Members}}",
+		"leave_edit":         " — saiu depois de {{duration .Stay}}",
+		"watched_join":       "{{with .Ping}}{{.}} {{end}}👀 Usuário observado <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} entrou no servidor",
+		"watched_leave":      "{{with .Ping}}{{.}} {{end}}👀 Usuário observado <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} saiu do servidor",
+		"watched_rename":     "{{with .Ping}}{{.}} {{end}}👀 Usuário observado <@{{.ID}}> mudou {{if eq .NameKind \"username\"}}o nome de usuário{{else}}o apelido{{end}} de `{{or .OldName \"nada\"}}` para `{{or .NewName \"nada\"}}`",
+		"cross_guild_join":   "⚠️ <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} entrou no servidor{{if .MemberCount}}, agora com {{.Members}}{{end}}. {{if eq .OtherEvent \"ban\"}}Foi banido de{{else}}Saiu de{{end}} {{.OtherGuild}} <t:{{.OtherAt.Unix}}:R>",
+		"voice_join":         "🔊 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} entrou em <#{{.ChannelID}}>",
+		"voice_leave":        "🔇 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} saiu de <#{{.ChannelID}}>",
+		"voice_move":         "🔀 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} mudou de <#{{.OldChannelID}}> para <#{{.ChannelID}}>",
+		"ban":                "🔨 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} foi banido{{with .ModeratorID}} por <@{{.}}>{{end}}{{with .Reason}}: {{.}}{{end}}",
+		"unban":              "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} teve o banimento removido{{with .ModeratorID}} por <@{{.}}>{{end}}{{with .Reason}}: {{.}}{{end}}",
+		"role_add":           "🏷️ <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} recebeu o cargo {{or .RoleName .RoleID}}{{with .ModeratorID}} de <@{{.}}>{{end}}",
+		"role_remove":        "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} perdeu o cargo {{or .RoleName .RoleID}}{{with .ModeratorID}}, removido por <@{{.}}>{{end}}",
+	},
+	messages: map[string]string{
+		"User Log commands": "Comandos do User Log",
+		"Delete everything stored about a user (admin only)":           "Apagar tudo o que está salvo sobre um usuário (só admins)",
+		"User (or user ID) to forget":                                  "Usuário (ou ID) a esquecer",
+		"Show member growth and churn":                                 "Mostrar o crescimento e a evasão de membros",
+		"Show the latest joins and leaves":                             "Mostrar as últimas entradas e saídas",
+		"Events per page (default %v)":                                 "Eventos por página (padrão %v)",
+		"List every username and nickname seen for a user":             "Listar todos os nomes de usuário e apelidos vistos de um usuário",
+		"User (or user ID) to look up":                                 "Usuário (ou ID) a consultar",
+		"Show a chart of the member count":                             "Mostrar um gráfico do número de membros",
+		"Attach an HTML report of the last month":                      "Anexar um relatório HTML do último mês",
+		"How far back to go (default 30d)":                             "Até quando voltar (padrão 30 dias)",
+		"30 days":                                                      "30 dias",
+		"90 days":                                                      "90 dias",
+		"1 year":                                                       "1 ano",
+		"Send alerts when a user joins, leaves, or changes their name": "Enviar alertas quando um usuário entrar, sair ou mudar de nome",
+		"User (or user ID) to watch":                                   "Usuário (ou ID) a observar",
+		"Stop watching a user":                                         "Parar de observar um usuário",
+		"User (or user ID) to stop watching":                           "Usuário (ou ID) a deixar de observar",
+		"List the watched users":                                       "Listar os usuários observados",
+		"Change this server's settings (admin only)":                   "Mudar as configurações deste servidor (só admins)",
+		"Show the settings changed with /userlog config":               "Mostrar as configurações mudadas com /userlog config",
+		"Change a setting, overriding the config file":                 "Mudar uma configuração, no lugar do arquivo de configuração",
+		"Setting name, like channel_id or template_join":               "Nome da configuração, como channel_id ou template_join",
+		"New value, lists are comma-separated":                         "Novo valor, listas são separadas por vírgulas",
+		"Go back to the config file value of a setting":                "Voltar ao valor do arquivo de configuração",
+		"Setting name":                                                 "Nome da configuração",
+
+		"Unknown command.": "Comando desconhecido.",
+		"You don't have permission to use this command.":                                   "Você não tem permissão para usar este comando.",
+		"Failed to forget <@%v>, check the logs.":                                          "Não foi possível esquecer <@%v>, veja os logs.",
+		"Nothing was stored about <@%v>.":                                                  "Nada estava salvo sobre <@%v>.",
+		"Forgot everything this server stored about <@%v>.":                                "Tudo o que este servidor salvou sobre <@%v> foi esquecido.",
+		"Forgot everything this server stored about <@%v>, except that they are a member.": "Tudo o que este servidor salvou sobre <@%v> foi esquecido, exceto que é membro.",
+		"Unknown period.": "Período desconhecido.",
+		"Failed to load the member count history, check the logs.": "Não foi possível carregar o histórico do número de membros, veja os logs.",
+		"Failed to draw the graph, check the logs.":                "Não foi possível desenhar o gráfico, veja os logs.",
+		"Members, Last %v Days":                                    "Membros, últimos %v dias",
+		"%v to %v: %v → **%v** members (%v)\nLow %v, high %v":      "%v a %v: %v → **%v** membros (%v)\nMínimo %v, máximo %v",
+		"Failed to load names, check the logs.":                    "Não foi possível carregar os nomes, veja os logs.",
+		"No names were seen for <@%v>.":                            "Nenhum nome foi visto para <@%v>.",
+		"Usernames":                                                "Nomes de usuário",
+		"Nicknames":                                                "Apelidos",
+		"Name History":                                             "Histórico de nomes",
+		"First seen – last seen":                                   "Visto pela primeira vez – visto pela última vez",
+		"Unknown page.":                                            "Página desconhecida.",
+		"Failed to load recent events, check the logs.":            "Não foi possível carregar os eventos recentes, veja os logs.",
+		"joined":                  "entrou",
+		"left":                    "saiu",
+		"Nothing here.":           "Nada aqui.",
+		"Recent Joins and Leaves": "Entradas e saídas recentes",
+		"Page %v":                 "Página %v",
+		"Newer":                   "Mais recentes",
+		"Older":                   "Mais antigos",
+		"Failed to load settings, check the logs.":            "Não foi possível carregar as configurações, veja os logs.",
+		"Nothing was changed, the config file is used as-is.": "Nada foi mudado, o arquivo de configuração é usado como está.",
+		"Available settings: %v":                              "Configurações disponíveis: %v",
+		"Settings":                                            "Configurações",
+		"Settings can't be changed at runtime.":               "As configurações não podem ser mudadas durante a execução.",
+		"`%v` isn't set.":                                     "`%v` não está definida.",
+		"Failed to store the setting, check the logs.":        "Não foi possível salvar a configuração, veja os logs.",
+		"Invalid setting, nothing was changed: %v":            "Configuração inválida, nada foi mudado: %v",
+		"Set `%v`.": "`%v` foi definida.",
+		"Unset `%v`, the config file value is used again.": "`%v` foi removida, o valor do arquivo de configuração volta a ser usado.",
+		"Member Stats": "Estatísticas de membros",
+		"Members":      "Membros",
+		"Last %v days": "Últimos %v dias",
+		"Joins: %v\nLeaves: %v\nNet growth: %+d\nChurn: %.1f%%":                    "Entradas: %v\nSaídas: %v\nCrescimento líquido: %+d\nEvasão: %.1f%%",
+		"Failed to load stats, check the logs.":                                    "Não foi possível carregar as estatísticas, veja os logs.",
+		"Member report from %v to %v.":                                             "Relatório de membros de %v a %v.",
+		"Failed to watch the user, check the logs.":                                "Não foi possível observar o usuário, veja os logs.",
+		"<@%v> is already watched.":                                                "<@%v> já está sendo observado.",
+		"Watching <@%v>, their joins, leaves, and renames will be sent as alerts.": "Observando <@%v>, entradas, saídas e mudanças de nome serão enviadas como alertas.",
+		"Failed to unwatch the user, check the logs.":                              "Não foi possível parar de observar o usuário, veja os logs.",
+		"<@%v> isn't watched.":                                                     "<@%v> não está sendo observado.",
+		"Stopped watching <@%v>.":                                                  "<@%v> não está mais sendo observado.",
+		"Failed to load the watch list, check the logs.":                           "Não foi possível carregar a lista de observação, veja os logs.",
+		"Nobody is watched.":                                                       "Ninguém está sendo observado.",
+		"<@%v> by <@%v> <t:%v:d>":                                                  "<@%v> por <@%v> <t:%v:d>",
+		"Watched Users":                                                            "Usuários observados",
+		"Show how many new members stay":                                           "Mostrar quantos membros novos ficam",
+		"Failed to load retention, check the logs.":                                "Não foi possível carregar a retenção, veja os logs.",
+		"Not enough joins yet":                                                     "Ainda não há entradas suficientes",
+		"%.1f%% (%v of %v)":                                                        "%.1f%% (%v de %v)",
+		"Nobody left":                                                              "Ninguém saiu",
+		"%v (%v left)":                                                             "%v (%v saíram)",
+		"%v: %v of %v":                                                             "%v: %v de %v",
+		"Member Retention":                                                         "Retenção de membros",
+		"Stayed 7 days":                                                            "Ficaram 7 dias",
+		"Stayed 30 days":                                                           "Ficaram 30 dias",
+		"Median stay of leavers":                                                   "Permanência mediana de quem saiu",
+		"Still here, by join month":                                                "Ainda aqui, por mês de entrada",
+		"Joins of the last %v months":                                              "Entradas dos últimos %v meses",
+		"List the longest-standing members":                                        "Listar os membros mais antigos",
+		"Longest-Standing Members":                                                 "Membros mais antigos",
+		"Previous":                                                                 "Anterior",
+		"Next":                                                                     "Próxima",
+		"Show everything known about a user":                                       "Mostrar tudo o que se sabe sobre um usuário",
+		"Failed to look up the user, check the logs.":                              "Não foi possível consultar o usuário, veja os logs.",
+		"Nothing is known about <@%v>.":                                            "Nada se sabe sobre <@%v>.",
+		"Not a member":                                                             "Não é membro",
+		"Member":                                                                   "Membro",
+		"Member since <t:%v:D>":                                                    "Membro desde <t:%v:D>",
+		"Still here":                                                               "Ainda aqui",
+		"None recorded":                                                            "Nenhum registrado",
+		"Invite used":                                                              "Convite usado",
+		"Unknown":                                                                  "Desconhecido",
+		"`%v` by <@%v>":                                                            "`%v` de <@%v>",
+		"Status":                                                                   "Status",
+		"First seen":                                                               "Visto pela primeira vez",
+		"Last seen":                                                                "Visto pela última vez",
+		"Joins / leaves":                                                           "Entradas / saídas",
+		"Roles at last leave":                                                      "Cargos na última saída",
+		"User Lookup":                                                              "Consulta de usuário",
+		"Pick the announcement channel, style, and events (admin only)":       "Escolher o canal, o estilo e os eventos anunciados (admins)",
+		"Setup is done. Single settings can be changed with /userlog config.": "A configuração terminou. Cada configuração pode ser alterada com /userlog config.",
+		"Default":                         "Padrão",
+		"Compact":                         "Compacto",
+		"Mention, name, and member count": "Menção, nome e contagem de membros",
+		"Just the mention":                "Só a menção",
+		"Custom":                          "Personalizado",
+		"Write your own templates":        "Escreva seus próprios modelos",
+		"Pick where and how members are announced. Changes are saved right away and override the config file.": "Escolha onde e como os membros são anunciados. As alterações são salvas na hora e substituem o arquivo de configuração.",
+		"Only the first %v channels are listed, set others with /userlog config set channel_id.":               "Só os primeiros %v canais são listados, defina outros com /userlog config set channel_id.",
+		"Setup":                                                    "Configuração inicial",
+		"Announcement channel":                                     "Canal de anúncios",
+		"Announcement style":                                       "Estilo dos anúncios",
+		"Announced events":                                         "Eventos anunciados",
+		"Language":                                                 "Idioma",
+		"More options":                                             "Mais opções",
+		"Done":                                                     "Concluído",
+		"Announcement templates":                                   "Modelos de anúncio",
+		"Join template, empty for the default":                     "Modelo de entrada, vazio para o padrão",
+		"Leave template, empty for the default":                    "Modelo de saída, vazio para o padrão",
+		"Quiet hours, like 01:00-08:00":                            "Horário silencioso, como 01:00-08:00",
+		"Announce every N members":                                 "Anunciar a cada N membros",
+		"Timezone, like Europe/Berlin":                             "Fuso horário, como Europe/Berlin",
+		"Show when a user was last seen online":                    "Mostrar quando um usuário foi visto online pela última vez",
+		"Presence tracking is off.":                                "O rastreamento de presença está desligado.",
+		"Failed to load the presence, check the logs.":             "Não foi possível carregar a presença, veja os logs.",
+		"<@%v> wasn't seen online yet.":                            "<@%v> ainda não foi visto online.",
+		"<@%v> is online, since <t:%v:R>.":                         "<@%v> está online, desde <t:%v:R>.",
+		"<@%v> was last seen online <t:%v:f> (<t:%v:R>).":          "<@%v> foi visto online pela última vez em <t:%v:f> (<t:%v:R>).",
+		"First message":                                            "Primeira mensagem",
+		"Never posted":                                             "Nunca postou",
+		"<t:%v:f> in <#%v>, %v after joining":                      "<t:%v:f> em <#%v>, %v após entrar",
+		"Onboarding, last 30 days":                                 "Integração, últimos 30 dias",
+		"Tracked joins: %v\nPosted: %v (%.1f%%)\nNever posted: %v": "Entradas registradas: %v\nPostaram: %v (%.1f%%)\nNunca postaram: %v",
+		"Median time to first post: %v":                            "Tempo mediano até a primeira mensagem: %v",
+		"List members without activity, with a CSV export":         "Listar membros sem atividade, com exportação CSV",
+		"How long members have been inactive (default 90d)":        "Há quanto tempo os membros estão inativos (padrão 90 dias)",
+		"180 days": "180 dias",
+		"No activity is tracked, turn on first message tracking, presence tracking, or voice logging.": "Nenhuma atividade é registrada, ative o registro de primeiras mensagens, de presença ou o log de voz.",
+		"Failed to load the activity, check the logs.":                                                 "Falha ao carregar a atividade, veja os logs.",
+		"%v had no activity in the last %v days.":                                                      "%v sem atividade nos últimos %v dias.",
+		"never active":                                "nunca ativo",
+		"…and %v more, see the CSV.":                  "…e mais %v, veja o CSV.",
+		"Inactive Members":                            "Membros inativos",
+		"Activity is only known since it is tracked.": "A atividade só é conhecida desde que é registrada.",
+		"An anonymized member":                        "Um membro anonimizado",
+		"Not enough activity":                         "Pouca atividade",
+		"Too many notifications":                      "Notificações demais",
+		"Didn't find what I was looking for":          "Não encontrei o que procurava",
+		"Something else":                              "Outro motivo",
+		"Sorry to see you go! If you have a moment, why did you leave?":       "Que pena que você saiu! Se tiver um momento, por que saiu?",
+		"%v left and couldn't be asked why. If you know the reason, pick it:": "%v saiu e não pôde ser perguntado por quê. Se você souber o motivo, escolha-o:",
+		"Thanks for letting us know!":                                         "Obrigado por nos contar!",
+		"Sorry, your answer couldn't be saved.":                               "Desculpe, sua resposta não pôde ser salva.",
+		"This survey has expired.":                                            "Esta pesquisa expirou.",
+		"Failed to save the reason, check the logs.":                          "Falha ao salvar o motivo, verifique os logs.",
+		"<@%v> left: %v (noted by <@%v>)":                                     "<@%v> saiu: %v (anotado por <@%v>)",
+		"Reason for last leave":                                               "Motivo da última saída",
+		"Reasons for leaving":                                                 "Motivos das saídas",
+		"Show who is interested in scheduled events":                          "Mostrar quem tem interesse em eventos agendados",
+		"Event name or ID, lists the latest events without it":                "Nome ou ID do evento, sem ele os eventos mais recentes são listados",
+		"Scheduled event tracking is off.":                                    "O rastreamento de eventos agendados está desligado.",
+		"Failed to load the scheduled events, check the logs.":                "Não foi possível carregar os eventos agendados, veja os logs.",
+		"No scheduled event %v was recorded.":                                 "Nenhum evento agendado %v foi registrado.",
+		"<@%v> interested since <t:%v:d>":                                     "<@%v> com interesse desde <t:%v:d>",
+		"~~<@%v>~~ withdrew <t:%v:d>":                                         "~~<@%v>~~ desistiu em <t:%v:d>",
+		"Nobody RSVPed yet.":                                                  "Ninguém confirmou interesse ainda.",
+		"%v interested, %v withdrew":                                          "%v com interesse, %v desistiram",
+		"No scheduled events were recorded yet.":                              "Nenhum evento agendado foi registrado ainda.",
+		"**%v**: %v interested":                                               "**%v**: %v com interesse",
+		"**%v** <t:%v:f>: %v interested":                                      "**%v** <t:%v:f>: %v com interesse",
+		"Scheduled Events":                                                    "Eventos agendados",
+		"Event %v":                                                            "Evento %v",
+		"Read the bot token again and reconnect with it (bot owner only)":     "Ler o token do bot novamente e reconectar com ele (só o dono do bot)",
+		"Only the bot owner can reload the token.":                            "Só o dono do bot pode recarregar o token.",
+		"Token reloading is off.":                                             "O recarregamento do token está desligado.",
+		"The token didn't change.":                                            "O token não mudou.",
+		"Failed to reload the token, check the logs.":                         "Não foi possível recarregar o token, veja os logs.",
+		"Switched to the new token, reconnecting.":                            "Token novo em uso, reconectando.",
+		"Ban":                                  "Banir",
+		"Failed to ban <@%v>, check the logs.": "Não foi possível banir <@%v>, veja os logs.",
+		"Banned <@%v>.":                        "<@%v> foi banido.",
+		"Note":                                 "Nota",
+		"Notes":                                "Notas",
+		"Note, only moderators see it":         "Nota, só moderadores veem",
+		"The note is empty.":                   "A nota está vazia.",
+		"Failed to save the note, check the logs.":        "Não foi possível salvar a nota, veja os logs.",
+		"Noted about <@%v>.":                              "Nota sobre <@%v> salva.",
+		"Notes can be at most %v characters long.":        "Notas podem ter no máximo %v caracteres.",
+		"<t:%v:d> by <@%v>: %v":                           "<t:%v:d> por <@%v>: %v",
+		"Keep notes about users, shown by /userlog whois": "Guardar notas sobre usuários, mostradas por /userlog whois",
+		"Add a note about a user, only moderators see it": "Adicionar uma nota sobre um usuário, só moderadores veem",
+		"User (or user ID) the note is about":             "Usuário (ou ID) sobre quem é a nota",
+		"The note":                                        "A nota",
+		"List who joined and left since an earlier daily member list, with a CSV export": "Listar quem entrou e saiu desde uma lista diária de membros anterior, com exportação CSV",
+		"How far back to compare (default 7d)":                                           "Até quando comparar (padrão 7 dias)",
+		"1 day":                                                                          "1 dia",
+		"7 days":                                                                         "7 dias",
+		"Failed to load the member list, check the logs.":                                "Não foi possível carregar a lista de membros, veja os logs.",
+		"There is no member list from %v days ago yet. Syncs record one a day, kept for %v days.": "Ainda não há lista de membros de %v dias atrás. As sincronizações gravam uma por dia, mantida por %v dias.",
+		"Joined (%v)":             "Entraram (%v)",
+		"Left (%v)":               "Saíram (%v)",
+		"Member Changes Since %v": "Mudanças de membros desde %v",
+		"Compared with the member list of %v, %v → %v members": "Comparado com a lista de membros de %v, %v → %v membros",
+	},
+}
@@ -0,0 +1,277 @@
+// Package importer reads member lists exported by other bots or earlier installations.
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// Record is an imported guild member
+type Record struct {
+	// GuildID is empty if the export doesn't have one
+	GuildID   string
+	DiscordID string
+	Member    store.Member
+}
+
+// columns maps the accepted column names (or JSON keys) of each field, compared case-insensitively
+var columns = map[string][]string{
+	"guild_id":      {"guild_id", "guildid", "guild", "server_id", "serverid"},
+	"discord_id":    {"discord_id", "discordid", "user_id", "userid", "member_id", "id"},
+	"username":      {"username", "discord_username", "user", "name"},
+	"discriminator": {"discriminator", "discord_discriminator"},
+	"nick":          {"nick", "nickname"},
+	"joined_at":     {"joined_at", "joinedat", "joined", "join_date", "joindate"},
+	"avatar":        {"avatar", "avatar_hash"},
+	"roles":         {"roles", "role_ids"},
+}
+
+// Format guesses the format of a file from its extension, "csv" or "json"
+func Format(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv", nil
+	case ".json":
+		return "json", nil
+	}
+	return "", fmt.Errorf("can't tell the format of '%v', use a .csv or .json file", path)
+}
+
+// Read reads members in a format, "csv" with a header row or "json" as an array of objects.
+// Records of the same member are merged, in the order they were first seen.
+func Read(r io.Reader, format string) ([]Record, error) {
+	var (
+		rows []map[string]string
+		err  error
+	)
+	switch format {
+	case "csv":
+		rows, err = readCSV(r)
+	case "json":
+		rows, err = readJSON(r)
+	default:
+		return nil, fmt.Errorf("unknown format '%v'", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records := []Record{}
+	seen := map[[2]string]int{}
+	for i, row := range rows {
+		record, err := parseRecord(row)
+		if err != nil {
+			return nil, fmt.Errorf("record %v: %w", i+1, err)
+		}
+		key := [2]string{record.GuildID, record.DiscordID}
+		if j, ok := seen[key]; ok {
+			records[j].Member = Merge(records[j].Member, record.Member)
+			continue
+		}
+		seen[key] = len(records)
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// readCSV returns the rows of a CSV file keyed by lowercase header
+func readCSV(r io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	rows := []map[string]string{}
+	for {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, value := range values {
+			row[header[i]] = value
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readJSON returns the objects of a JSON array keyed by lowercase key, with role arrays joined by commas
+func readJSON(r io.Reader) ([]map[string]string, error) {
+	decoder := json.NewDecoder(r)
+	// large IDs don't fit a float64
+	decoder.UseNumber()
+	var objects []map[string]interface{}
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, err
+	}
+
+	rows := make([]map[string]string, len(objects))
+	for i, object := range objects {
+		rows[i] = make(map[string]string, len(object))
+		for key, value := range object {
+			switch value := value.(type) {
+			case nil:
+			case []interface{}:
+				values := make([]string, len(value))
+				for j, v := range value {
+					values[j] = fmt.Sprint(v)
+				}
+				rows[i][strings.ToLower(key)] = strings.Join(values, ",")
+			default:
+				rows[i][strings.ToLower(key)] = fmt.Sprint(value)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// field returns the value of the first column of a field that is set
+func field(row map[string]string, name string) string {
+	for _, column := range columns[name] {
+		if value := strings.TrimSpace(row[column]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func parseRecord(row map[string]string) (Record, error) {
+	record := Record{
+		GuildID:   field(row, "guild_id"),
+		DiscordID: field(row, "discord_id"),
+		Member: store.Member{
+			User: store.User{
+				Username:      field(row, "username"),
+				Discriminator: field(row, "discriminator"),
+			},
+			Nick:   field(row, "nick"),
+			Avatar: field(row, "avatar"),
+		},
+	}
+	if _, err := strconv.ParseUint(record.DiscordID, 10, 64); err != nil {
+		return Record{}, fmt.Errorf("invalid Discord ID '%v'", record.DiscordID)
+	}
+	if joinedAt := field(row, "joined_at"); joinedAt != "" {
+		var err error
+		if record.Member.JoinedAt, err = parseTime(joinedAt); err != nil {
+			return Record{}, err
+		}
+	}
+	if roles := field(row, "roles"); roles != "" {
+		record.Member.Roles = strings.FieldsFunc(roles, func(r rune) bool {
+			return r == ',' || r == ';' || r == ' '
+		})
+		sort.Strings(record.Member.Roles)
+	}
+	return record, nil
+}
+
+// parseTime accepts RFC 3339 times, dates, and Unix timestamps in seconds or milliseconds
+func parseTime(value string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if unix > 1e12 {
+			return time.UnixMilli(unix), nil
+		}
+		return time.Unix(unix, 0), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid join date '%v'", value)
+}
+
+// Merge fills the unknown fields of a member from another record of them, keeping the earlier join date
+func Merge(member, other store.Member) store.Member {
+	if member.Username == "" {
+		member.User = other.User
+	}
+	if member.Nick == "" {
+		member.Nick = other.Nick
+	}
+	if member.Avatar == "" {
+		member.Avatar = other.Avatar
+	}
+	if len(member.Roles) == 0 {
+		member.Roles = other.Roles
+	}
+	if member.JoinedAt.IsZero() || (!other.JoinedAt.IsZero() && other.JoinedAt.Before(member.JoinedAt)) {
+		member.JoinedAt = other.JoinedAt
+	}
+	return member
+}
+
+// Store is the subset of *store.Store records are imported into
+type Store interface {
+	Members(guildID string) (map[string]store.Member, error)
+	AddMember(guildID, discordID string, member store.Member) error
+	UpdateMember(guildID, discordID string, member store.Member) error
+}
+
+// Result counts what an import changed
+type Result struct {
+	Added, Updated, Unchanged int
+}
+
+// Import adds the records to the stored members, merging them into members that are already stored.
+// Records without a guild ID belong to defaultGuildID.
+func Import(st Store, records []Record, defaultGuildID string) (Result, error) {
+	var result Result
+	members := map[string]map[string]store.Member{}
+	for _, record := range records {
+		guildID := record.GuildID
+		if guildID == "" {
+			guildID = defaultGuildID
+		}
+		if guildID == "" {
+			return result, fmt.Errorf("no guild ID for member '%v'", record.DiscordID)
+		}
+		if _, ok := members[guildID]; !ok {
+			stored, err := st.Members(guildID)
+			if err != nil {
+				return result, err
+			}
+			members[guildID] = stored
+		}
+
+		existing, ok := members[guildID][record.DiscordID]
+		if !ok {
+			if err := st.AddMember(guildID, record.DiscordID, record.Member); err != nil {
+				return result, err
+			}
+			members[guildID][record.DiscordID] = record.Member
+			result.Added++
+			continue
+		}
+		merged := Merge(existing, record.Member)
+		if merged.Same(existing) {
+			result.Unchanged++
+			continue
+		}
+		if err := st.UpdateMember(guildID, record.DiscordID, merged); err != nil {
+			return result, err
+		}
+		members[guildID][record.DiscordID] = merged
+		result.Updated++
+	}
+	return result, nil
+}
@@ -0,0 +1,103 @@
+package importer
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestReadCSV(t *testing.T) {
+	records, err := Read(strings.NewReader(`User_ID,Username,Nickname,Joined,Roles
+100,alice,Al,2021-03-04T05:06:07Z,"7;5"
+200,bob,,1614834367,
+100,,,2020-01-02,
+`), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Record{
+		{DiscordID: "100", Member: store.Member{User: store.User{Username: "alice"}, Nick: "Al", JoinedAt: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), Roles: []string{"5", "7"}}},
+		{DiscordID: "200", Member: store.Member{User: store.User{Username: "bob"}, JoinedAt: time.Unix(1614834367, 0)}},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("unexpected records %+v", records)
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	records, err := Read(strings.NewReader(`[
+		{"guild_id": "1", "id": 123456789012345678, "username": "alice", "discriminator": "0", "joined_at": 1614834367000, "roles": [7, "5"]},
+		{"guild_id": "1", "id": "200", "nick": null}
+	]`), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Record{
+		{GuildID: "1", DiscordID: "123456789012345678", Member: store.Member{User: store.User{Username: "alice", Discriminator: "0"}, JoinedAt: time.UnixMilli(1614834367000), Roles: []string{"5", "7"}}},
+		{GuildID: "1", DiscordID: "200"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("unexpected records %+v", records)
+	}
+}
+
+func TestReadRejectsInvalidRecords(t *testing.T) {
+	for _, input := range []string{
+		"username\nalice\n",
+		"id,joined_at\n100,yesterday\n",
+	} {
+		if _, err := Read(strings.NewReader(input), "csv"); err == nil {
+			t.Errorf("expected %q to fail", input)
+		}
+	}
+	if _, err := Read(strings.NewReader("[]"), "xml"); err == nil {
+		t.Errorf("expected an unknown format to fail")
+	}
+}
+
+func TestImport(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "dul.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer st.Close()
+	joinedAt := time.Unix(1614834367, 0)
+	if err := st.AddMember("1", "100", store.Member{User: store.User{Username: "alice"}, JoinedAt: joinedAt}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AddMember("1", "300", store.Member{User: store.User{Username: "carol"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Import(st, []Record{
+		{DiscordID: "100", Member: store.Member{User: store.User{Username: "old-alice"}, Nick: "Al", JoinedAt: joinedAt.Add(time.Hour)}},
+		{DiscordID: "200", Member: store.Member{User: store.User{Username: "bob"}}},
+		{DiscordID: "300", Member: store.Member{User: store.User{Username: "carol"}}},
+	}, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (Result{Added: 1, Updated: 1, Unchanged: 1}) {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	members, err := st.Members("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// stored fields win, missing ones are filled in
+	if alice := members["100"]; alice.Username != "alice" || alice.Nick != "Al" || !alice.JoinedAt.Equal(joinedAt) {
+		t.Errorf("unexpected merged member %+v", alice)
+	}
+	if len(members) != 3 || members["200"].Username != "bob" {
+		t.Errorf("unexpected members %+v", members)
+	}
+
+	if _, err := Import(st, []Record{{DiscordID: "400"}}, ""); err == nil {
+		t.Errorf("expected a record without a guild to fail")
+	}
+}
@@ -0,0 +1,140 @@
+// Package leader elects one of several instances sharing a database, so only one of them tracks members and announces.
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LeaseName is the lease the instances compete for
+const LeaseName = "leader"
+
+// Store is the subset of *store.Store holding the lease
+type Store interface {
+	AcquireLease(name, holder string, now time.Time, ttl time.Duration) (bool, error)
+	ReleaseLease(name, holder string) error
+}
+
+// Elector holds the leader lease for one instance.
+// The lease expires ttl after it was last renewed, so a standby takes over within ttl of the leader dying.
+type Elector struct {
+	store  Store
+	holder string
+	ttl    time.Duration
+	now    func() time.Time
+
+	lock     sync.Mutex
+	released bool
+	// expires is when the lease last taken or renewed by this instance expires
+	expires time.Time
+}
+
+// New elects among instances with different holder names
+func New(store Store, holder string, ttl time.Duration) *Elector {
+	return &Elector{
+		store:  store,
+		holder: holder,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// Holder is the name this instance holds the lease as
+func (e *Elector) Holder() string {
+	return e.holder
+}
+
+// Interval is how often the lease is renewed, and taking it is retried
+func (e *Elector) Interval() time.Duration {
+	return e.ttl / 3
+}
+
+func (e *Elector) acquire() (bool, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.released {
+		return false, nil
+	}
+	// the lease runs from before the request, a slow request doesn't extend it
+	now := e.now()
+	leader, err := e.store.AcquireLease(LeaseName, e.holder, now, e.ttl)
+	if leader && err == nil {
+		e.expires = now.Add(e.ttl)
+	}
+	return leader, err
+}
+
+// expiresBeforeNextRenewal reports whether the lease would expire before the next attempt to renew it
+func (e *Elector) expiresBeforeNextRenewal() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return !e.now().Add(e.Interval()).Before(e.expires)
+}
+
+// Wait blocks until this instance is the leader, or ctx is canceled.
+// A leader that lost the lease can wait for it again, unless it was released.
+func (e *Elector) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(e.Interval())
+	defer ticker.Stop()
+	for {
+		leader, err := e.acquire()
+		if err != nil {
+			return err
+		}
+		if leader {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Keep renews the lease until ctx is canceled or the lease is released.
+// lost is called once if another instance took over, or if renewing failed and the lease would expire
+// before the next attempt, so the leader steps down while the lease still keeps standbys waiting.
+func (e *Elector) Keep(ctx context.Context, lost func(error)) {
+	ticker := time.NewTicker(e.Interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		leader, err := e.acquire()
+		switch {
+		case err != nil && !e.expiresBeforeNextRenewal():
+			// try again before the lease expires
+			continue
+		case err != nil:
+			lost(fmt.Errorf("failed to renew the lease: %w", err))
+			return
+		case !leader:
+			if e.isReleased() {
+				return
+			}
+			lost(errors.New("another instance took over"))
+			return
+		}
+	}
+}
+
+func (e *Elector) isReleased() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.released
+}
+
+// Release gives up the lease, letting a standby take over right away. The lease isn't renewed afterwards.
+func (e *Elector) Release() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.released = true
+	return e.store.ReleaseLease(LeaseName, e.holder)
+}
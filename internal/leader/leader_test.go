@@ -0,0 +1,164 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStore holds one lease per name, expiring leases like *store.Store
+type fakeStore struct {
+	lock    sync.Mutex
+	holders map[string]string
+	expires map[string]time.Time
+	// steal hands every lease to another holder
+	steal bool
+	// fail makes acquiring fail, calling it first
+	fail     func()
+	failures int
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{holders: map[string]string{}, expires: map[string]time.Time{}}
+}
+
+func (f *fakeStore) AcquireLease(name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.fail != nil {
+		f.fail()
+		f.failures++
+		return false, errors.New("database is locked")
+	}
+	if f.steal {
+		holder = "thief"
+	}
+	if current, ok := f.holders[name]; ok && current != holder && now.Before(f.expires[name]) {
+		return false, nil
+	}
+	f.holders[name], f.expires[name] = holder, now.Add(ttl)
+	return !f.steal, nil
+}
+
+func (f *fakeStore) ReleaseLease(name, holder string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.holders[name] == holder {
+		delete(f.holders, name)
+	}
+	return nil
+}
+
+const testTTL = 30 * time.Millisecond
+
+func TestStandbyTakesOverAfterRelease(t *testing.T) {
+	st := newFakeStore()
+	leader := New(st, "a", testTTL)
+	standby := New(st, "b", testTTL)
+	if err := leader.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*testTTL)
+	defer cancel()
+	go leader.Keep(ctx, func(err error) { t.Errorf("unexpected loss: %v", err) })
+	if err := standby.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the standby to keep waiting while the lease is renewed, got %v", err)
+	}
+
+	if err := leader.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := standby.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeepNoticesTakeover(t *testing.T) {
+	st := newFakeStore()
+	leader := New(st, "a", testTTL)
+	if err := leader.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	st.lock.Lock()
+	st.steal = true
+	st.lock.Unlock()
+	lost := make(chan error, 1)
+	go leader.Keep(context.Background(), func(err error) { lost <- err })
+	select {
+	case err := <-lost:
+		if err.Error() != "another instance took over" {
+			t.Errorf("unexpected loss %v", err)
+		}
+	case <-time.After(10 * testTTL):
+		t.Fatal("expected the takeover to be noticed")
+	}
+}
+
+func TestKeepStepsDownBeforeExpiry(t *testing.T) {
+	st := newFakeStore()
+	leader := New(st, "a", testTTL)
+	// every renewal attempt happens one interval after the previous one
+	clock := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	leader.now = func() time.Time { return clock }
+	if err := leader.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expires := clock.Add(testTTL)
+
+	st.lock.Lock()
+	st.fail = func() { clock = clock.Add(leader.Interval()) }
+	st.lock.Unlock()
+	lost := make(chan error, 1)
+	go leader.Keep(context.Background(), func(err error) { lost <- err })
+	select {
+	case <-lost:
+	case <-time.After(10 * testTTL):
+		t.Fatal("expected the leader to step down")
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.failures != 2 {
+		t.Errorf("expected to step down after the second failed renewal, the last one before the lease expires, got %v failures", st.failures)
+	}
+	if !clock.Before(expires) {
+		t.Errorf("expected to step down before the lease expires at %v, stepped down at %v", expires, clock)
+	}
+}
+
+func TestWaitAgainAfterLoss(t *testing.T) {
+	st := newFakeStore()
+	leader := New(st, "a", testTTL)
+	if err := leader.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	st.lock.Lock()
+	st.steal = true
+	st.lock.Unlock()
+	lost := make(chan error, 1)
+	go leader.Keep(context.Background(), func(err error) { lost <- err })
+	select {
+	case <-lost:
+	case <-time.After(10 * testTTL):
+		t.Fatal("expected the takeover to be noticed")
+	}
+
+	// the thief stops renewing, the former leader takes over once its lease expires
+	st.lock.Lock()
+	st.steal = false
+	st.lock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*testTTL)
+	defer cancel()
+	if err := leader.Wait(ctx); err != nil {
+		t.Fatalf("expected to lead again, got %v", err)
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.holders[LeaseName] != "a" {
+		t.Errorf("expected a to hold the lease, got %v", st.holders[LeaseName])
+	}
+}
@@ -0,0 +1,172 @@
+package maintenance
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultBackupKeep is how many backups are kept if Maintainer.BackupKeep is 0
+const DefaultBackupKeep = 7
+
+// backupPrefix and backupLayout name backups by when they were written, so they sort by age
+const (
+	backupPrefix = "user-log-"
+	backupLayout = "20060102-150405"
+)
+
+// backup writes a snapshot of the healthy database to BackupDir, removing the oldest ones beyond BackupKeep
+func (m *Maintainer) backup(now time.Time) {
+	if m.BackupDir == "" {
+		return
+	}
+	if err := os.MkdirAll(m.BackupDir, 0o700); err != nil {
+		m.report(fmt.Sprintf("Failed to create the backup directory: %v", err))
+		return
+	}
+	path := filepath.Join(m.BackupDir, backupPrefix+now.UTC().Format(backupLayout)+".db")
+	// written under another name first, an interrupted backup must not look like the latest one
+	partial := path + ".partial"
+	os.Remove(partial)
+	if err := m.Store.Backup(partial); err != nil {
+		os.Remove(partial)
+		m.report(fmt.Sprintf("Failed to back up the database: %v", err))
+		return
+	}
+	if err := os.Rename(partial, path); err != nil {
+		m.report(fmt.Sprintf("Failed to back up the database: %v", err))
+		return
+	}
+	log.Printf("[maintenance] backed up the database to %v", path)
+
+	keep := m.BackupKeep
+	if keep <= 0 {
+		keep = DefaultBackupKeep
+	}
+	backups, err := Backups(m.BackupDir)
+	if err != nil {
+		log.Printf("[maintenance] failed to list backups: %v", err)
+		return
+	}
+	for _, old := range backups[min(keep, len(backups)):] {
+		if err := os.Remove(old); err != nil {
+			log.Printf("[maintenance] failed to remove old backup %v: %v", old, err)
+		}
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Backups returns the paths of the backups in dir, newest first
+func Backups(dir string) ([]string, error) {
+	backups, err := filepath.Glob(filepath.Join(dir, backupPrefix+"*.db"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// ErrCorrupt is wrapped by the errors of Recover's check for databases that are damaged, and only those are replaced
+var ErrCorrupt = errors.New("the database is corrupt")
+
+// Recover makes the database at path usable if it is missing or corrupt, that is check fails with ErrCorrupt.
+// A broken file is kept next to it with a .corrupt suffix, and the newest backup in dir passing check is restored.
+// Without one, the database is left missing, to be created empty and rebuilt by the first sync.
+// check opens the database at path and checks it, it is not called while the file is missing.
+// Other errors of check, like a wrong key or a locked database, are returned without touching anything.
+func Recover(path, dir string, check func() error) error {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		log.Printf("[recovery] the database %v is missing", path)
+	} else if err := check(); err != nil {
+		if !errors.Is(err, ErrCorrupt) {
+			return err
+		}
+		log.Printf("[recovery] the database %v is unusable: %v", path, err)
+		aside := fmt.Sprintf("%v.corrupt-%v", path, time.Now().Unix())
+		if err := moveDatabase(path, aside); err != nil {
+			return err
+		}
+		log.Printf("[recovery] moved the unusable database to %v", aside)
+	} else {
+		return nil
+	}
+
+	backups := []string{}
+	if dir != "" {
+		var err error
+		if backups, err = Backups(dir); err != nil {
+			return err
+		}
+	}
+	for _, backup := range backups {
+		if err := copyFile(backup, path); err != nil {
+			return err
+		}
+		if err := check(); err != nil {
+			if removeErr := removeDatabase(path); removeErr != nil {
+				return removeErr
+			}
+			if !errors.Is(err, ErrCorrupt) {
+				return fmt.Errorf("failed to check the backup %v: %w", backup, err)
+			}
+			log.Printf("[recovery] the backup %v is unusable too: %v", backup, err)
+			continue
+		}
+		log.Printf("[recovery] restored the backup %v, events since it was written are found by the first sync", backup)
+		return nil
+	}
+	log.Println("[recovery] no usable backup, starting with an empty database, the first sync rebuilds it without announcing anything")
+	return nil
+}
+
+// databaseSuffixes are the files of a SQLite database, its write-ahead log and shared memory belong to it
+var databaseSuffixes = []string{"", "-wal", "-shm", "-journal"}
+
+// moveDatabase renames the files of a database
+func moveDatabase(from, to string) error {
+	for _, suffix := range databaseSuffixes {
+		if err := os.Rename(from+suffix, to+suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeDatabase removes the files of a database
+func removeDatabase(path string) error {
+	for _, suffix := range databaseSuffixes {
+		if err := os.Remove(path + suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
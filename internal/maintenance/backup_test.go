@@ -0,0 +1,121 @@
+package maintenance
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackup(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backups")
+	st := &fakeStore{}
+	m := &Maintainer{Store: st, Location: time.UTC, BackupDir: dir, BackupKeep: 2}
+	start := time.Date(2023, 7, 1, 3, 0, 0, 0, time.UTC)
+	for day := 0; day < 3; day++ {
+		m.Maintain(start.AddDate(0, 0, day))
+	}
+
+	backups, err := Backups(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || filepath.Base(backups[0]) != "user-log-20230703-030000.db" || filepath.Base(backups[1]) != "user-log-20230702-030000.db" {
+		t.Errorf("expected the newest 2 backups, got %v", backups)
+	}
+}
+
+// checkContent fails unless the file at path says "ok"
+func checkContent(path string) func() error {
+	return func() error {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		switch string(content) {
+		case "ok":
+			return nil
+		case "locked":
+			return errors.New("database is locked")
+		}
+		return fmt.Errorf("%w: file is not a database", ErrCorrupt)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		existing string
+		backups  map[string]string
+		restored string
+		aside    bool
+	}{
+		{"healthy", "ok", map[string]string{"user-log-20230701-030000.db": "backup"}, "ok", false},
+		{"corrupt", "garbage", map[string]string{"user-log-20230701-030000.db": "ok", "user-log-20230630-030000.db": "old"}, "ok", true},
+		{"corrupt backup", "garbage", map[string]string{"user-log-20230701-030000.db": "garbage"}, "", true},
+		{"missing", "", map[string]string{"user-log-20230701-030000.db": "ok"}, "ok", false},
+		{"missing without backups", "", nil, "", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "state.db")
+			if test.existing != "" {
+				if err := os.WriteFile(path, []byte(test.existing), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			backupDir := filepath.Join(dir, "backups")
+			os.Mkdir(backupDir, 0o700)
+			for name, content := range test.backups {
+				if err := os.WriteFile(filepath.Join(backupDir, name), []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := Recover(path, backupDir, checkContent(path)); err != nil {
+				t.Fatal(err)
+			}
+			content, err := os.ReadFile(path)
+			if test.restored == "" && !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("expected no database to start over, got %q %v", content, err)
+			} else if test.restored != "" && string(content) != test.restored {
+				t.Errorf("expected %q, got %q %v", test.restored, content, err)
+			}
+			aside, _ := filepath.Glob(path + ".corrupt-*")
+			if test.aside != (len(aside) == 1) {
+				t.Errorf("expected the broken database kept aside: %v, got %v", test.aside, aside)
+			}
+			if test.aside && len(aside) == 1 {
+				if kept, _ := os.ReadFile(aside[0]); !strings.Contains(string(kept), "garbage") {
+					t.Errorf("expected the broken database kept, got %q", kept)
+				}
+			}
+		})
+	}
+}
+
+func TestRecoverOnlyCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.db")
+	if err := os.WriteFile(path, []byte("locked"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	backupDir := filepath.Join(dir, "backups")
+	os.Mkdir(backupDir, 0o700)
+	if err := os.WriteFile(filepath.Join(backupDir, "user-log-20230701-030000.db"), []byte("ok"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Recover(path, backupDir, checkContent(path)); err == nil || errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected the check's error, got %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "locked" {
+		t.Errorf("expected the database to be left alone, got %q", content)
+	}
+	if aside, _ := filepath.Glob(path + ".corrupt-*"); len(aside) != 0 {
+		t.Errorf("expected nothing moved aside, got %v", aside)
+	}
+}
@@ -0,0 +1,144 @@
+// Package maintenance keeps a long-running SQLite database healthy, checking its integrity and compacting it during a daily window.
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// vacuumFreeRatio is the fraction of unused pages above which the database file is rebuilt
+const vacuumFreeRatio = 0.1
+
+// maxReportedProblems limits how many integrity problems a report lists, a badly damaged database has thousands
+const maxReportedProblems = 10
+
+// Store is the subset of *store.Store that is maintained
+type Store interface {
+	IntegrityCheck() ([]string, error)
+	Optimize() error
+	FreePages() (int64, int64, error)
+	Vacuum() error
+	Backup(path string) error
+}
+
+// Maintainer runs maintenance once a day, when the window starts
+type Maintainer struct {
+	Store Store
+	// Start and End are the window as offsets from midnight in Location, it may span midnight
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+	// Report receives problems found, it must not block for long
+	Report func(problem string)
+	// BackupDir receives a backup of the healthy database every day, empty disables backups.
+	// Only the newest BackupKeep are kept, 0 keeps DefaultBackupKeep.
+	BackupDir  string
+	BackupKeep int
+}
+
+// Next returns when the next maintenance is due: now if it is within the window, or the next start of the window
+func (m *Maintainer) Next(now time.Time) time.Time {
+	if !m.end(now).IsZero() {
+		return now
+	}
+	local := now.In(m.Location)
+	for day := 0; ; day++ {
+		date := local.AddDate(0, 0, day)
+		// rebuilding the time from the wall clock keeps it correct across DST changes
+		start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, int(m.Start/time.Second), 0, m.Location)
+		if start.After(now) {
+			return start
+		}
+	}
+}
+
+// end returns the end of the window t is in, or the zero time if it isn't in one
+func (m *Maintainer) end(t time.Time) time.Time {
+	local := t.In(m.Location)
+	// a window spanning midnight may have started the day before
+	for day := -1; day <= 0; day++ {
+		date := local.AddDate(0, 0, day)
+		start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, int(m.Start/time.Second), 0, m.Location)
+		length := m.End - m.Start
+		if length <= 0 {
+			length += 24 * time.Hour
+		}
+		end := start.Add(length)
+		if !t.Before(start) && t.Before(end) {
+			return end
+		}
+	}
+	return time.Time{}
+}
+
+// Run maintains the database every day until ctx is canceled
+func (m *Maintainer) Run(ctx context.Context) {
+	for {
+		due := m.Next(time.Now())
+		select {
+		case <-time.After(time.Until(due)):
+		case <-ctx.Done():
+			return
+		}
+		m.Maintain(time.Now())
+		// wait for the window to pass, so it runs once a day
+		select {
+		case <-time.After(time.Until(m.end(due))):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Maintain checks the integrity of the database, optimizes it, and backs it up.
+// The file is only rebuilt if enough of it is unused, the database is healthy, and the window didn't end yet.
+func (m *Maintainer) Maintain(now time.Time) {
+	end := m.end(now)
+	problems, err := m.Store.IntegrityCheck()
+	if err != nil {
+		m.report(fmt.Sprintf("The database integrity check failed: %v", err))
+		return
+	}
+	if len(problems) > 0 {
+		listed := problems
+		if len(listed) > maxReportedProblems {
+			listed = listed[:maxReportedProblems]
+		}
+		m.report(fmt.Sprintf("The database failed its integrity check with %v problems, restore a backup:\n%v", len(problems), strings.Join(listed, "\n")))
+		return
+	}
+	log.Println("[maintenance] the database passed its integrity check")
+
+	if err := m.Store.Optimize(); err != nil {
+		m.report(fmt.Sprintf("Failed to optimize the database: %v", err))
+	}
+	m.backup(now)
+
+	free, total, err := m.Store.FreePages()
+	if err != nil {
+		m.report(fmt.Sprintf("Failed to count the free pages of the database: %v", err))
+		return
+	}
+	if total == 0 || float64(free)/float64(total) <= vacuumFreeRatio {
+		return
+	}
+	if !end.IsZero() && time.Now().After(end) {
+		log.Printf("[maintenance] not vacuuming %v free pages of %v, the maintenance window is over", free, total)
+		return
+	}
+	if err := m.Store.Vacuum(); err != nil {
+		m.report(fmt.Sprintf("Failed to vacuum the database: %v", err))
+		return
+	}
+	log.Printf("[maintenance] vacuumed %v free pages of %v", free, total)
+}
+
+func (m *Maintainer) report(problem string) {
+	log.Printf("[maintenance] %v", problem)
+	if m.Report != nil {
+		m.Report(problem)
+	}
+}
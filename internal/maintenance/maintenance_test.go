@@ -0,0 +1,93 @@
+package maintenance
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	problems    []string
+	err         error
+	free, total int64
+	calls       []string
+}
+
+func (f *fakeStore) IntegrityCheck() ([]string, error) {
+	f.calls = append(f.calls, "integrity_check")
+	return f.problems, f.err
+}
+
+func (f *fakeStore) Optimize() error {
+	f.calls = append(f.calls, "optimize")
+	return nil
+}
+
+func (f *fakeStore) FreePages() (int64, int64, error) {
+	return f.free, f.total, nil
+}
+
+func (f *fakeStore) Vacuum() error {
+	f.calls = append(f.calls, "vacuum")
+	return nil
+}
+
+func (f *fakeStore) Backup(path string) error {
+	f.calls = append(f.calls, "backup")
+	return os.WriteFile(path, []byte("ok"), 0o600)
+}
+
+func TestNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	m := &Maintainer{Start: 23 * time.Hour, End: 2 * time.Hour, Location: berlin}
+	for _, test := range []struct{ now, expected time.Time }{
+		// before the window starts
+		{time.Date(2023, 7, 1, 12, 0, 0, 0, berlin), time.Date(2023, 7, 1, 23, 0, 0, 0, berlin)},
+		// within it, on either side of midnight
+		{time.Date(2023, 7, 1, 23, 30, 0, 0, berlin), time.Date(2023, 7, 1, 23, 30, 0, 0, berlin)},
+		{time.Date(2023, 7, 2, 1, 0, 0, 0, berlin), time.Date(2023, 7, 2, 1, 0, 0, 0, berlin)},
+		// after it ended
+		{time.Date(2023, 7, 2, 2, 0, 0, 0, berlin), time.Date(2023, 7, 2, 23, 0, 0, 0, berlin)},
+	} {
+		if next := m.Next(test.now); !next.Equal(test.expected) {
+			t.Errorf("next maintenance after %v is %v, expected %v", test.now, next, test.expected)
+		}
+	}
+}
+
+func TestMaintain(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		store    fakeStore
+		calls    []string
+		reported int
+	}{
+		{"healthy", fakeStore{free: 1, total: 100}, []string{"integrity_check", "optimize"}, 0},
+		{"fragmented", fakeStore{free: 20, total: 100}, []string{"integrity_check", "optimize", "vacuum"}, 0},
+		{"corrupt", fakeStore{problems: []string{"row 1 missing from index"}, free: 20, total: 100}, []string{"integrity_check"}, 1},
+		{"failing", fakeStore{err: errors.New("disk I/O error")}, []string{"integrity_check"}, 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			reported := []string{}
+			m := &Maintainer{
+				Store:    &test.store,
+				Start:    0,
+				End:      24*time.Hour - time.Minute,
+				Location: time.UTC,
+				Report:   func(problem string) { reported = append(reported, problem) },
+			}
+			m.Maintain(time.Now())
+			if !reflect.DeepEqual(test.store.calls, test.calls) {
+				t.Errorf("expected calls %v, got %v", test.calls, test.store.calls)
+			}
+			if len(reported) != test.reported {
+				t.Errorf("expected %v reported problems, got %v", test.reported, reported)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package notify
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxMessageLength is Discord's message length limit
+const maxMessageLength = 2000
+
+// MessageSender is the subset of *discordgo.Session used to post messages
+type MessageSender interface {
+	ChannelMessageSend(channelID string, content string) (*discordgo.Message, error)
+}
+
+// messageEditor is the subset of *discordgo.Session used to edit announcements
+type messageEditor interface {
+	ChannelMessage(channelID, messageID string) (*discordgo.Message, error)
+	ChannelMessageEdit(channelID, messageID, content string) (*discordgo.Message, error)
+}
+
+// componentSender is the subset of *discordgo.Session used to send announcements with buttons
+type componentSender interface {
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error)
+}
+
+// Message identifies a sent announcement
+type Message struct {
+	ChannelID string
+	ID        string
+}
+
+// Channel posts rendered events to a Discord channel
+type Channel struct {
+	session   MessageSender
+	channelID string
+	templates Templates
+}
+
+func NewChannel(session MessageSender, channelID string, templates Templates) *Channel {
+	return &Channel{
+		session:   session,
+		channelID: channelID,
+		templates: templates,
+	}
+}
+
+func (c *Channel) Notify(event Event) error {
+	message, err := c.templates.Render(event)
+	if err != nil {
+		return err
+	}
+	_, err = c.send(message, event.Actions)
+	return err
+}
+
+// send posts a message, with its actions as buttons if the session can send them
+func (c *Channel) send(message string, actions []Action) (*discordgo.Message, error) {
+	sender, ok := c.session.(componentSender)
+	if len(actions) == 0 || !ok {
+		return c.session.ChannelMessageSend(c.channelID, message)
+	}
+	return sender.ChannelMessageSendComplex(c.channelID, &discordgo.MessageSend{
+		Content:    message,
+		Components: actionButtons(actions),
+	})
+}
+
+// actionButtons lays out actions as a row of buttons
+func actionButtons(actions []Action) []discordgo.MessageComponent {
+	buttons := make([]discordgo.MessageComponent, len(actions))
+	for i, action := range actions {
+		button := discordgo.Button{
+			Label:    action.Label,
+			Style:    discordgo.SecondaryButton,
+			CustomID: action.CustomID,
+		}
+		if action.Emoji != "" {
+			button.Emoji = discordgo.ComponentEmoji{Name: action.Emoji}
+		}
+		buttons[i] = button
+	}
+	return []discordgo.MessageComponent{discordgo.ActionsRow{Components: buttons}}
+}
+
+// NotifyMessage announces an event like Notify, returning the sent message so it can be edited later
+func (c *Channel) NotifyMessage(event Event) (Message, error) {
+	message, err := c.templates.Render(event)
+	if err != nil {
+		return Message{}, err
+	}
+	sent, err := c.send(message, event.Actions)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{ChannelID: sent.ChannelID, ID: sent.ID}, nil
+}
+
+// Append renders an event onto the end of a sent message.
+// It fails if the session can't edit messages or the edited message would be too long.
+func (c *Channel) Append(message Message, event Event) error {
+	editor, ok := c.session.(messageEditor)
+	if !ok {
+		return errors.New("session can't edit messages")
+	}
+	suffix, err := c.templates.Render(event)
+	if err != nil {
+		return err
+	}
+	sent, err := editor.ChannelMessage(message.ChannelID, message.ID)
+	if err != nil {
+		return err
+	}
+	if len(sent.Content)+len(suffix) > maxMessageLength {
+		return errors.New("edited message would be too long")
+	}
+	_, err = editor.ChannelMessageEdit(message.ChannelID, message.ID, sent.Content+suffix)
+	return err
+}
+
+// NotifyBatch sends the rendered events as few messages as possible, one event per line
+func (c *Channel) NotifyBatch(events []Event) error {
+	messages, err := batchMessages(c.templates, events)
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		if _, err := c.session.ChannelMessageSend(c.channelID, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchMessages renders events one per line, joined into as few messages as possible
+func batchMessages(templates Templates, events []Event) ([]string, error) {
+	messages := []string{}
+	var message strings.Builder
+	for _, event := range events {
+		line, err := templates.Render(event)
+		if err != nil {
+			return nil, err
+		}
+		if message.Len() > 0 && message.Len()+1+len(line) > maxMessageLength {
+			messages = append(messages, message.String())
+			message.Reset()
+		}
+		if message.Len() > 0 {
+			message.WriteString("\n")
+		}
+		message.WriteString(line)
+	}
+	if message.Len() > 0 {
+		messages = append(messages, message.String())
+	}
+	return messages, nil
+}
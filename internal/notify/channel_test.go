@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+type recordingSender []string
+
+func (r *recordingSender) ChannelMessageSend(channelID string, content string) (*discordgo.Message, error) {
+	*r = append(*r, content)
+	return &discordgo.Message{ChannelID: channelID, Content: content}, nil
+}
+
+func TestNotifyBatch(t *testing.T) {
+	templates, err := ParseTemplates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := &recordingSender{}
+	channel := NewChannel(sent, "1", templates)
+
+	events := []Event{}
+	for i := 0; i < 100; i++ {
+		events = append(events, Event{Type: store.EventJoin, UserID: "12345678901234567890"})
+	}
+	if err := channel.NotifyBatch(events); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*sent) != 3 {
+		t.Fatalf("expected the batch to be split into 3 messages, got %v", len(*sent))
+	}
+	lines := 0
+	for _, message := range *sent {
+		if len(message) > maxMessageLength {
+			t.Errorf("message of %v characters is too long", len(message))
+		}
+		lines += len(strings.Split(message, "\n"))
+	}
+	if lines != len(events) {
+		t.Errorf("expected %v lines, got %v", len(events), lines)
+	}
+
+	*sent = nil
+	if err := channel.NotifyBatch(nil); err != nil || len(*sent) != 0 {
+		t.Errorf("expected nothing to be sent for an empty batch, sent %v (err %v)", *sent, err)
+	}
+}
+
+// complexSender records the buttons sent with messages
+type complexSender struct {
+	recordingSender
+	buttons [][]discordgo.Button
+}
+
+func (c *complexSender) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	buttons := []discordgo.Button{}
+	for _, button := range data.Components[0].(discordgo.ActionsRow).Components {
+		buttons = append(buttons, button.(discordgo.Button))
+	}
+	c.buttons = append(c.buttons, buttons)
+	return c.ChannelMessageSend(channelID, data.Content)
+}
+
+func TestNotifyActions(t *testing.T) {
+	templates, err := ParseTemplates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := Event{Type: store.EventLeave, UserID: "1", Actions: []Action{{Label: "Ban", Emoji: "🔨", CustomID: "userlog:quickban:1"}}}
+
+	sent := &complexSender{}
+	if err := NewChannel(sent, "1", templates).Notify(event); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.buttons) != 1 || len(sent.buttons[0]) != 1 || sent.buttons[0][0].CustomID != "userlog:quickban:1" || sent.buttons[0][0].Emoji.Name != "🔨" {
+		t.Errorf("expected the action as a button, got %+v", sent.buttons)
+	}
+	// batches are sent without buttons
+	if err := NewChannel(sent, "1", templates).NotifyBatch([]Event{event}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.buttons) != 1 || len(sent.recordingSender) != 2 {
+		t.Errorf("expected the batch without buttons, got %+v", sent.buttons)
+	}
+
+	// sessions that can't send buttons send the announcement without them
+	plain := &recordingSender{}
+	if err := NewChannel(plain, "1", templates).Notify(event); err != nil || len(*plain) != 1 {
+		t.Errorf("expected the announcement sent without buttons, got %v (%v)", *plain, err)
+	}
+}
@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ownerWarningInterval is how often the owner is told about a channel the bot can't post to
+const ownerWarningInterval = time.Hour
+
+// FallbackSession is the subset of *discordgo.Session used by Fallback
+type FallbackSession interface {
+	MessageSender
+	componentSender
+	messageEditor
+	UserChannelCreate(recipientID string) (*discordgo.Channel, error)
+}
+
+// Fallback posts messages to a fallback channel when Discord doesn't let the bot post to their channel,
+// and tells the owner by DM. Use it as the session of a Channel.
+type Fallback struct {
+	session FallbackSession
+	// channelID receives the refused messages, empty to only tell the owner
+	channelID string
+	// ownerID is told about refused channels, empty to tell nobody
+	ownerID string
+	now     func() time.Time
+
+	lock sync.Mutex
+	// warned maps refused channels to when the owner was last told about them
+	warned map[string]time.Time
+}
+
+func NewFallback(session FallbackSession, channelID, ownerID string) *Fallback {
+	return &Fallback{
+		session:   session,
+		channelID: channelID,
+		ownerID:   ownerID,
+		now:       time.Now,
+		warned:    map[string]time.Time{},
+	}
+}
+
+// IsPermissionError reports whether Discord refused a request because the bot lacks permissions, like sending messages to a channel
+func IsPermissionError(err error) bool {
+	var restErr *discordgo.RESTError
+	return errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusForbidden
+}
+
+func (f *Fallback) ChannelMessageSend(channelID string, content string) (*discordgo.Message, error) {
+	message, err := f.session.ChannelMessageSend(channelID, content)
+	if !f.refused(channelID, err) {
+		return message, err
+	}
+	return f.session.ChannelMessageSend(f.channelID, content)
+}
+
+func (f *Fallback) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	message, err := f.session.ChannelMessageSendComplex(channelID, data)
+	if !f.refused(channelID, err) {
+		return message, err
+	}
+	return f.session.ChannelMessageSendComplex(f.channelID, data)
+}
+
+func (f *Fallback) ChannelMessage(channelID, messageID string) (*discordgo.Message, error) {
+	return f.session.ChannelMessage(channelID, messageID)
+}
+
+func (f *Fallback) ChannelMessageEdit(channelID, messageID, content string) (*discordgo.Message, error) {
+	return f.session.ChannelMessageEdit(channelID, messageID, content)
+}
+
+// refused reports whether a message Discord refused to post to a channel should go to the fallback channel,
+// telling the owner about it
+func (f *Fallback) refused(channelID string, err error) bool {
+	if !IsPermissionError(err) || channelID == f.channelID {
+		return false
+	}
+	f.warnOwner(channelID, err)
+	if f.channelID == "" {
+		return false
+	}
+	log.Printf("not allowed to post to channel '%v', posting to the fallback channel '%v' instead: %v", channelID, f.channelID, err)
+	return true
+}
+
+// warnOwner tells the owner the bot can't post to a channel, at most once every ownerWarningInterval per channel
+func (f *Fallback) warnOwner(channelID string, err error) {
+	if f.ownerID == "" {
+		return
+	}
+	f.lock.Lock()
+	now := f.now()
+	if warned, ok := f.warned[channelID]; ok && now.Sub(warned) < ownerWarningInterval {
+		f.lock.Unlock()
+		return
+	}
+	f.warned[channelID] = now
+	f.lock.Unlock()
+
+	message := fmt.Sprintf("I'm not allowed to post to <#%v> anymore, check my permissions there. Discord said: %v\n", channelID, err)
+	if f.channelID != "" {
+		message += fmt.Sprintf("Until it's fixed, I'm posting to <#%v> instead.", f.channelID)
+	} else {
+		message += "Until it's fixed, announcements there are only logged, and sent after the next restart."
+	}
+	dm, dmErr := f.session.UserChannelCreate(f.ownerID)
+	if dmErr == nil {
+		_, dmErr = f.session.ChannelMessageSend(dm.ID, message)
+	}
+	if dmErr != nil {
+		log.Printf("failed to tell the owner '%v' about channel '%v': %v", f.ownerID, channelID, dmErr)
+	}
+}
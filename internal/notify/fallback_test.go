@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// forbiddingSession refuses to post to the channels in forbidden, and records the other messages by channel
+type forbiddingSession struct {
+	forbidden map[string]bool
+	sent      map[string][]string
+}
+
+func (f *forbiddingSession) ChannelMessageSend(channelID string, content string) (*discordgo.Message, error) {
+	if f.forbidden[channelID] {
+		return nil, &discordgo.RESTError{Response: &http.Response{StatusCode: http.StatusForbidden}}
+	}
+	f.sent[channelID] = append(f.sent[channelID], content)
+	return &discordgo.Message{ChannelID: channelID, Content: content}, nil
+}
+
+func (f *forbiddingSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	return f.ChannelMessageSend(channelID, data.Content)
+}
+
+func (f *forbiddingSession) ChannelMessage(channelID, messageID string) (*discordgo.Message, error) {
+	return &discordgo.Message{ChannelID: channelID, ID: messageID}, nil
+}
+
+func (f *forbiddingSession) ChannelMessageEdit(channelID, messageID, content string) (*discordgo.Message, error) {
+	return &discordgo.Message{ChannelID: channelID, ID: messageID, Content: content}, nil
+}
+
+func (f *forbiddingSession) UserChannelCreate(recipientID string) (*discordgo.Channel, error) {
+	return &discordgo.Channel{ID: "dm-" + recipientID, Type: discordgo.ChannelTypeDM}, nil
+}
+
+func TestFallback(t *testing.T) {
+	templates, err := ParseTemplates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &forbiddingSession{forbidden: map[string]bool{"1": true}, sent: map[string][]string{}}
+	fallback := NewFallback(session, "2", "99")
+	now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	fallback.now = func() time.Time { return now }
+	channel := NewChannel(fallback, "1", templates)
+
+	event := Event{Type: store.EventJoin, UserID: "3", MemberCount: 10}
+	for i := 0; i < 2; i++ {
+		if err := channel.Notify(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(session.sent["2"]) != 2 {
+		t.Errorf("expected both announcements in the fallback channel, got %v", session.sent["2"])
+	}
+	if dms := session.sent["dm-99"]; len(dms) != 1 || !strings.Contains(dms[0], "<#1>") || !strings.Contains(dms[0], "<#2>") {
+		t.Errorf("expected one DM to the owner about both channels, got %v", dms)
+	}
+
+	now = now.Add(ownerWarningInterval)
+	session.forbidden["2"] = true
+	if err := channel.Notify(event); !IsPermissionError(err) {
+		t.Errorf("expected a permission error once the fallback channel is refused too, got %v", err)
+	}
+	if len(session.sent["dm-99"]) != 2 {
+		t.Errorf("expected the owner to be told again after an hour, got %v", session.sent["dm-99"])
+	}
+}
+
+func TestFallbackWithoutChannel(t *testing.T) {
+	templates, err := ParseTemplates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &forbiddingSession{forbidden: map[string]bool{"1": true}, sent: map[string][]string{}}
+	channel := NewChannel(NewFallback(session, "", "99"), "1", templates)
+
+	if err := channel.Notify(Event{Type: store.EventJoin, UserID: "3"}); !IsPermissionError(err) {
+		t.Errorf("expected the permission error, got %v", err)
+	}
+	if dms := session.sent["dm-99"]; len(dms) != 1 || !strings.Contains(dms[0], "only logged") {
+		t.Errorf("expected the owner to be told announcements are only logged, got %v", dms)
+	}
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"go.albinodrought/discord-user-log/internal/i18n"
+)
+
+// Hook is a text/template run on every event before it is published and announced, letting admins add their own rules.
+// It sees the same data as the announcement templates, and decides with these functions, its output is ignored:
+//
+//	skip           the event is still published, but not announced
+//	tag "name"     adds a tag, published with the event and available to templates as .Tags
+//	message "text" replaces the announcement
+//
+// contains, hasPrefix, hasSuffix, lower, and matches (a regular expression) help matching names.
+// A hook can also be a Lua script, see ParseScript.
+type Hook struct {
+	language *i18n.Language
+	tmpl     *template.Template
+	// script is set instead of tmpl for Lua hooks
+	script *script
+}
+
+// ParseHook parses a hook, formatting numbers and durations in a language
+func ParseHook(language *i18n.Language, source string) (*Hook, error) {
+	tmpl, err := template.New("hook").Funcs(hookFuncs(language, &Event{})).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse hook: %w", err)
+	}
+	return &Hook{language: language, tmpl: tmpl}, nil
+}
+
+// Apply runs the hook on an event, replacing what an earlier run decided.
+// The event is left as it was if the hook fails.
+func (h *Hook) Apply(event *Event) error {
+	event.Tags, event.Message, event.Skipped = nil, "", false
+	if h.script != nil {
+		return h.script.apply(event)
+	}
+	decided := *event
+	// every run gets its own functions, hooks are shared by guilds handling events concurrently
+	tmpl, err := h.tmpl.Clone()
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Funcs(hookFuncs(h.language, &decided)).Execute(io.Discard, newMessageData(h.language, *event)); err != nil {
+		return fmt.Errorf("hook failed on '%v' event: %w", event.Type, err)
+	}
+	*event = decided
+	return nil
+}
+
+func hookFuncs(language *i18n.Language, event *Event) template.FuncMap {
+	return template.FuncMap{
+		"number":   language.FormatNumber,
+		"duration": language.FormatDuration,
+		"skip": func() string {
+			event.Skipped = true
+			return ""
+		},
+		"tag": func(tag string) string {
+			event.Tags = append(event.Tags, tag)
+			return ""
+		},
+		"message": func(message string) string {
+			event.Message = message
+			return ""
+		},
+		"contains":  strings.Contains,
+		"hasPrefix": strings.HasPrefix,
+		"hasSuffix": strings.HasSuffix,
+		"lower":     strings.ToLower,
+		"matches":   regexp.MatchString,
+	}
+}
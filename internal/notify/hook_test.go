@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"reflect"
+	"testing"
+
+	"go.albinodrought/discord-user-log/internal/i18n"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestHook(t *testing.T) {
+	hook, err := ParseHook(i18n.English, `
+{{- if matches "(?i)free nitro" .Username}}{{skip}}{{tag "spam"}}{{end}}
+{{- if eq .Type "join"}}{{tag "join"}}{{message (printf "hi <@%v>, member %v" .ID (number .MemberCount))}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, err := ParseTemplates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := Event{Type: store.EventJoin, UserID: "1", User: store.User{Username: "alice", Discriminator: "0"}, MemberCount: 1234}
+	if err := hook.Apply(&event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Skipped || !reflect.DeepEqual(event.Tags, []string{"join"}) {
+		t.Errorf("expected a tagged join, got %+v", event)
+	}
+	if message, _ := templates.Render(event); message != "hi <@1>, member 1,234" {
+		t.Errorf("expected the hook's message, got %q", message)
+	}
+
+	// running again replaces what the last run decided
+	event.Type = store.EventLeave
+	event.User.Username = "FREE NITRO"
+	if err := hook.Apply(&event); err != nil {
+		t.Fatal(err)
+	}
+	if !event.Skipped || event.Message != "" || !reflect.DeepEqual(event.Tags, []string{"spam"}) {
+		t.Errorf("expected a skipped leave tagged spam, got %+v", event)
+	}
+
+	// failures leave the event as it was
+	failing, err := ParseHook(i18n.English, `{{tag "before"}}{{matches "(" .Username}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := failing.Apply(&event); err == nil {
+		t.Error("expected an invalid pattern to fail")
+	}
+	if event.Skipped || len(event.Tags) != 0 {
+		t.Errorf("expected the failed hook to change nothing, got %+v", event)
+	}
+
+	if _, err := ParseHook(i18n.English, `{{unknown}}`); err == nil {
+		t.Error("expected an unknown function to fail parsing")
+	}
+}
+
+func TestScript(t *testing.T) {
+	hook, err := ParseScript(i18n.English, "hook.lua", `
+joins = 0
+function on_event(event)
+  if matches("(?i)free nitro", event.username) then
+    skip()
+    tag("spam")
+  end
+  if event.event == "join" then
+    joins = joins + 1
+    tag("join")
+    message("hi <@" .. event.user_id .. ">, member " .. number(event.member_count) .. ", join " .. joins)
+  end
+end`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, err := ParseTemplates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := Event{Type: store.EventJoin, UserID: "1", User: store.User{Username: "alice", Discriminator: "0"}, MemberCount: 1234}
+	for i := 0; i < 2; i++ {
+		if err := hook.Apply(&event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if event.Skipped || !reflect.DeepEqual(event.Tags, []string{"join"}) {
+		t.Errorf("expected a tagged join, got %+v", event)
+	}
+	// globals are kept between events
+	if message, _ := templates.Render(event); message != "hi <@1>, member 1,234, join 2" {
+		t.Errorf("expected the script's message, got %q", message)
+	}
+
+	event.Type = store.EventLeave
+	event.User.Username = "FREE NITRO"
+	if err := hook.Apply(&event); err != nil {
+		t.Fatal(err)
+	}
+	if !event.Skipped || event.Message != "" || !reflect.DeepEqual(event.Tags, []string{"spam"}) {
+		t.Errorf("expected a skipped leave tagged spam, got %+v", event)
+	}
+
+	// failures, including loops that never end, leave the event as it was
+	for _, source := range []string{
+		`function on_event(event) tag("before") error("failed") end`,
+		`function on_event(event) tag("before") while true do end end`,
+	} {
+		failing, err := ParseScript(i18n.English, "failing.lua", source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := failing.Apply(&event); err == nil {
+			t.Errorf("expected %q to fail", source)
+		}
+		if event.Skipped || len(event.Tags) != 0 {
+			t.Errorf("expected the failed script to change nothing, got %+v", event)
+		}
+	}
+
+	for _, source := range []string{
+		`on_event = 1`,
+		`function on_event(event)`,
+		`skip() function on_event(event) end`,
+		`dofile("/etc/passwd") function on_event(event) end`,
+		`require("os") function on_event(event) end`,
+		`os.exit(1) function on_event(event) end`,
+	} {
+		if _, err := ParseScript(i18n.English, "invalid.lua", source); err == nil {
+			t.Errorf("expected %q to fail", source)
+		}
+	}
+}
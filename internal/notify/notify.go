@@ -0,0 +1,265 @@
+// Package notify announces member events.
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/i18n"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// EventMilestone is announced when a join brings the guild to a member count milestone.
+// It is not recorded in the history.
+const EventMilestone = "milestone"
+
+// EventMassLeave alerts moderators when many members leave in a short time.
+// It is not recorded in the history.
+const EventMassLeave = "mass_leave"
+
+// EventLeaveEdit is appended to the join announcement of a leaving member, instead of announcing the leave.
+// It is not recorded in the history.
+const EventLeaveEdit = "leave_edit"
+
+// EventSyncSummary replaces the announcements of a sync that found more missed events than configured.
+// It is not recorded in the history.
+const EventSyncSummary = "sync_summary"
+
+// EventAnniversary is announced on the days members have been in the guild for another whole year.
+// It is not recorded in the history.
+const EventAnniversary = "anniversary"
+
+// Watched user alerts replace the announcements of users on the watch list.
+// They are sent to the alert channel and not recorded in the history.
+const (
+	EventWatchedJoin   = "watched_join"
+	EventWatchedLeave  = "watched_leave"
+	EventWatchedRename = "watched_rename"
+)
+
+// EventCrossGuildJoin replaces the announcement of a member who recently left or was banned from another tracked guild.
+// It is sent to the alert channel and not recorded in the history.
+const EventCrossGuildJoin = "cross_guild_join"
+
+// DefaultTemplates are used for event types without a configured template
+var DefaultTemplates = map[string]string{
+	store.EventJoin:              "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} joined the server{{if .Pending}}, pending membership screening{{end}}{{if .MemberCount}}, now {{.Members}}{{end}}",
+	store.EventLeave:             "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} left the server{{if .Stay}} after being a member for {{duration .Stay}}{{end}}{{if .MemberCount}}, now {{.Members}}{{end}}",
+	EventMilestone:               "🎉 We just reached {{number .MemberCount}} members! Welcome <@{{.ID}}>",
+	EventMassLeave:               "🚨 {{number .Count}} members left in the last {{duration .Window}}, {{number .MemberCount}} remain",
+	store.EventBoostStart:        "💎 <@{{.ID}}> started boosting the server, thank you!",
+	store.EventBoostStop:         "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} stopped boosting the server",
+	store.EventTimeout:           "⏳ <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} was timed out for {{duration .Timeout}}, until <t:{{.Until.Unix}}:f>",
+	store.EventTimeoutEnd:        "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}}'s timeout was removed",
+	store.EventScreeningComplete: "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} completed membership screening",
+	store.EventAvatarChange:      "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} changed their avatar{{with .AvatarURL}} {{.}}{{end}}",
+	store.EventRename:            "✏️ <@{{.ID}}> changed their {{.NameKind}} from `{{or .OldName \"nothing\"}}` to `{{or .NewName \"nothing\"}}`",
+	EventAnniversary:             "🎂 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} has been here for {{.Years}} {{if eq .Years 1}}year{{else}}years{{end}} today!",
+	EventSyncSummary:             "🔄 A sync found {{number .Count}} missed events, {{number .Joins}} joins and {{number .Leaves}} leaves, now {{.Members}}",
+	EventLeaveEdit:               " — left after {{duration .Stay}}",
+	EventWatchedJoin:             "{{with .Ping}}{{.}} {{end}}👀 Watched user <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} joined the server",
+	EventWatchedLeave:            "{{with .Ping}}{{.}} {{end}}👀 Watched user <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} left the server",
+	EventWatchedRename:           "{{with .Ping}}{{.}} {{end}}👀 Watched user <@{{.ID}}> changed their {{.NameKind}} from `{{or .OldName \"nothing\"}}` to `{{or .NewName \"nothing\"}}`",
+	EventCrossGuildJoin:          "⚠️ <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} joined the server{{if .MemberCount}}, now {{.Members}}{{end}}. They {{if eq .OtherEvent \"ban\"}}were banned from{{else}}left{{end}} {{.OtherGuild}} <t:{{.OtherAt.Unix}}:R>",
+	store.EventVoiceJoin:         "🔊 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} joined <#{{.ChannelID}}>",
+	store.EventVoiceLeave:        "🔇 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} left <#{{.ChannelID}}>",
+	store.EventVoiceMove:         "🔀 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} moved from <#{{.OldChannelID}}> to <#{{.ChannelID}}>",
+	store.EventBan:               "🔨 <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} was banned{{with .ModeratorID}} by <@{{.}}>{{end}}{{with .Reason}}: {{.}}{{end}}",
+	store.EventUnban:             "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} was unbanned{{with .ModeratorID}} by <@{{.}}>{{end}}{{with .Reason}}: {{.}}{{end}}",
+	store.EventRoleAdd:           "🏷️ <@{{.ID}}>{{with .Tag}} ({{.}}){{end}} was given the {{or .RoleName .RoleID}} role{{with .ModeratorID}} by <@{{.}}>{{end}}",
+	store.EventRoleRemove:        "<@{{.ID}}>{{with .Tag}} ({{.}}){{end}} lost the {{or .RoleName .RoleID}} role{{with .ModeratorID}}, removed by <@{{.}}>{{end}}",
+}
+
+// Event is something that happened to a guild member
+type Event struct {
+	// Type is one of the store.Event* history types, or a notify.Event* type
+	Type    string
+	GuildID string
+	UserID  string
+	User    store.User
+	// At is when the event happened
+	At time.Time
+	// MemberCount is the number of known guild members after the event
+	MemberCount int
+	// Until is when a timeout ends, for timeout events
+	Until time.Time
+	// JoinedAt is when a leaving member joined, or when a member celebrating an anniversary did, zero if unknown
+	JoinedAt time.Time
+	// Years is how many whole years a member has been in the guild, for anniversaries
+	Years int
+	// Pending is set for joins of members that haven't completed membership screening yet
+	Pending bool
+	// Avatar is the user's avatar hash
+	Avatar string
+	// Count and Window are how many members left in how long, for mass leave alerts
+	Count  int
+	Window time.Duration
+	// Joins and Leaves are among the Count events a sync summary replaces
+	Joins  int
+	Leaves int
+	// PingRoleID is mentioned by watched user alerts, if set
+	PingRoleID string
+	// NameKind is "username" or "nickname", OldName and NewName are empty for a missing nickname, for watched user renames
+	NameKind string
+	OldName  string
+	NewName  string
+	// ChannelID is the voice channel joined, moved to, or left, and OldChannelID the one moved from, for voice events
+	ChannelID    string
+	OldChannelID string
+	// Reason and ModeratorID are from the audit log, for bans and unbans, empty if it couldn't be read.
+	// ModeratorID is also who changed a member's roles, like a reaction role bot, for role events.
+	Reason      string
+	ModeratorID string
+	// RoleID is the role given or removed, and RoleName its name, empty if it couldn't be looked up, for role events
+	RoleID   string
+	RoleName string
+	// OtherGuildID and OtherGuild are the ID and name of the tracked guild a joining member recently left or was banned from,
+	// OtherEvent is store.EventLeave or store.EventBan, and OtherAt when it happened, for cross-guild joins
+	OtherGuildID string
+	OtherGuild   string
+	OtherEvent   string
+	OtherAt      time.Time
+	// Tags, Message, and Skipped are set by the guild's hook, if it has one.
+	// Message replaces the rendered announcement, and skipped events are published but not announced.
+	Tags    []string
+	Message string
+	Skipped bool
+	// Actions are offered as buttons under the announcement, by notifiers that can send them
+	Actions []Action `json:",omitempty"`
+}
+
+// Action is a button under an announcement, pressing it sends an interaction with its custom ID
+type Action struct {
+	Label    string
+	Emoji    string
+	CustomID string
+}
+
+// Notifier announces events somewhere
+type Notifier interface {
+	Notify(event Event) error
+	// NotifyBatch announces deferred events together
+	NotifyBatch(events []Event) error
+}
+
+// Templates renders events into messages in a language
+type Templates struct {
+	language *i18n.Language
+	// location shows plain text dates, nil is UTC
+	location *time.Location
+	// byType maps event types to their templates
+	byType map[string]*template.Template
+}
+
+// ParseTemplates parses English text/template announcement templates keyed by event type.
+// Missing or empty templates fall back to the defaults.
+func ParseTemplates(sources map[string]string) (Templates, error) {
+	return ParseLocalizedTemplates(i18n.English, sources)
+}
+
+// ParseLocalizedTemplates parses announcement templates like ParseTemplates,
+// falling back to the defaults of a language, and formatting numbers and durations in it.
+func ParseLocalizedTemplates(language *i18n.Language, sources map[string]string) (Templates, error) {
+	funcs := template.FuncMap{
+		"number":   language.FormatNumber,
+		"duration": language.FormatDuration,
+	}
+	templates := Templates{language: language, byType: map[string]*template.Template{}}
+	for eventType, defaultSource := range DefaultTemplates {
+		source := sources[eventType]
+		if source == "" {
+			if localized, ok := language.Template(eventType); ok {
+				defaultSource = localized
+			}
+			source = defaultSource
+		}
+		tmpl, err := template.New(eventType).Funcs(funcs).Parse(source)
+		if err != nil {
+			return Templates{}, fmt.Errorf("failed to parse %v template: %w", eventType, err)
+		}
+		templates.byType[eventType] = tmpl
+	}
+	return templates, nil
+}
+
+// messageData is passed to the templates
+type messageData struct {
+	Event
+	ID            string
+	Username      string
+	Discriminator string
+	Tag           string
+	// Members is MemberCount with its unit, like "1,234 members"
+	Members string
+	// Timeout is the length of a timeout, for timeout events
+	Timeout time.Duration
+	// Stay is how long a leaving member was in the guild in whole seconds, zero if their join date is unknown
+	Stay time.Duration
+	// AvatarURL links to the user's avatar, empty if they have none
+	AvatarURL string
+	// Ping mentions PingRoleID, empty if it isn't set
+	Ping string
+}
+
+// In returns the templates showing plain text dates, like in push notifications, in a timezone
+func (t Templates) In(location *time.Location) Templates {
+	t.location = location
+	return t
+}
+
+// Render renders the message for an event, or returns the message its hook set
+func (t Templates) Render(event Event) (string, error) {
+	if event.Message != "" {
+		return event.Message, nil
+	}
+	tmpl, ok := t.byType[event.Type]
+	if !ok {
+		return "", fmt.Errorf("no template for event type '%v'", event.Type)
+	}
+
+	var message bytes.Buffer
+	err := tmpl.Execute(&message, newMessageData(t.language, event))
+	return message.String(), err
+}
+
+func newMessageData(language *i18n.Language, event Event) messageData {
+	data := messageData{
+		Event:         event,
+		ID:            event.UserID,
+		Username:      event.User.Username,
+		Discriminator: event.User.Discriminator,
+		Tag:           event.User.Tag(),
+		Members:       language.Members(event.MemberCount),
+	}
+	if strings.HasPrefix(event.Avatar, "a_") {
+		data.AvatarURL = discordgo.EndpointUserAvatarAnimated(event.UserID, event.Avatar)
+	} else if event.Avatar != "" {
+		data.AvatarURL = discordgo.EndpointUserAvatar(event.UserID, event.Avatar)
+	}
+	if !event.JoinedAt.IsZero() {
+		// whole seconds, so a member who left right away has no stay
+		data.Stay = event.At.Sub(event.JoinedAt).Truncate(time.Second)
+	}
+	if !event.Until.IsZero() {
+		data.Timeout = event.Until.Sub(event.At)
+	}
+	if event.PingRoleID != "" {
+		data.Ping = "<@&" + event.PingRoleID + ">"
+	}
+	return data
+}
+
+// FormatNumber formats n with thousands separators, like 1,234
+func FormatNumber(n int) string {
+	return i18n.English.FormatNumber(n)
+}
+
+// FormatDuration formats d with its two largest units, like "2 years, 3 months" or "5 minutes".
+// Months are 30 days and years are 365 days.
+func FormatDuration(d time.Duration) string {
+	return i18n.English.FormatDuration(d)
+}
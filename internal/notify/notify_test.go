@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/i18n"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestRenderDefaults(t *testing.T) {
+	templates, err := ParseTemplates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		event    Event
+		expected string
+	}{
+		{Event{Type: store.EventJoin, UserID: "1"}, "<@1> joined the server"},
+		{Event{Type: store.EventJoin, UserID: "1", User: store.User{Username: "alice", Discriminator: "0"}}, "<@1> (alice) joined the server"},
+		{Event{Type: store.EventLeave, UserID: "1", User: store.User{Username: "bob", Discriminator: "1234"}}, "<@1> (bob#1234) left the server"},
+		{Event{Type: store.EventJoin, UserID: "1", MemberCount: 1234}, "<@1> joined the server, now 1,234 members"},
+		{Event{Type: store.EventLeave, UserID: "1", MemberCount: 1}, "<@1> left the server, now 1 member"},
+		{Event{Type: store.EventLeave, UserID: "1", At: time.Unix(1700000000, 0), JoinedAt: time.Unix(1700000000-(2*365+95)*86400, 0)}, "<@1> left the server after being a member for 2 years, 3 months"},
+		{Event{Type: EventMilestone, UserID: "1", MemberCount: 1000}, "🎉 We just reached 1,000 members! Welcome <@1>"},
+		{Event{Type: store.EventTimeout, UserID: "1", At: time.Unix(1700000000, 0), Until: time.Unix(1700000000+90*60, 0)}, "⏳ <@1> was timed out for 1 hour, 30 minutes, until <t:1700005400:f>"},
+	} {
+		actual, err := templates.Render(tc.event)
+		if err != nil {
+			t.Errorf("failed to render %+v: %v", tc.event, err)
+		} else if actual != tc.expected {
+			t.Errorf("rendered %q, expected %q", actual, tc.expected)
+		}
+	}
+}
+
+func TestRenderLocalizedDefaults(t *testing.T) {
+	for _, code := range i18n.Codes() {
+		language, _ := i18n.Lookup(code)
+		templates, err := ParseLocalizedTemplates(language, nil)
+		if err != nil {
+			t.Fatalf("failed to parse %v templates: %v", code, err)
+		}
+		for eventType := range DefaultTemplates {
+			event := Event{Type: eventType, UserID: "1", MemberCount: 1234, NameKind: "nickname", At: time.Unix(1700000000, 0)}
+			if _, err := templates.Render(event); err != nil {
+				t.Errorf("failed to render %v %v: %v", code, eventType, err)
+			}
+		}
+	}
+
+	german, _ := i18n.Lookup("de")
+	templates, err := ParseLocalizedTemplates(german, map[string]string{store.EventLeave: "{{.Members}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		event    Event
+		expected string
+	}{
+		{Event{Type: store.EventJoin, UserID: "1", MemberCount: 1234}, "<@1> ist dem Server beigetreten, jetzt 1.234 Mitglieder"},
+		// configured templates still win, but format in the language
+		{Event{Type: store.EventLeave, UserID: "1", MemberCount: 1}, "1 Mitglied"},
+	} {
+		actual, err := templates.Render(tc.event)
+		if err != nil {
+			t.Errorf("failed to render %+v: %v", tc.event, err)
+		} else if actual != tc.expected {
+			t.Errorf("rendered %q, expected %q", actual, tc.expected)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	for n, expected := range map[int]string{
+		0:        "0",
+		999:      "999",
+		1000:     "1,000",
+		1234567:  "1,234,567",
+		-12345:   "-12,345",
+		100000:   "100,000",
+		99999999: "99,999,999",
+	} {
+		if actual := FormatNumber(n); actual != expected {
+			t.Errorf("FormatNumber(%v) = %q, expected %q", n, actual, expected)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	day := 24 * time.Hour
+	for d, expected := range map[time.Duration]string{
+		0:                                   "0 seconds",
+		time.Second:                         "1 second",
+		90 * time.Minute:                    "1 hour, 30 minutes",
+		3 * day:                             "3 days",
+		(2*365+95)*day + time.Hour:          "2 years, 3 months",
+		365*day + 2*time.Hour:               "1 year",
+		400 * time.Millisecond:              "0 seconds",
+		28*day + 23*time.Hour + time.Minute: "28 days, 23 hours",
+	} {
+		if actual := FormatDuration(d); actual != expected {
+			t.Errorf("FormatDuration(%v) = %q, expected %q", d, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// pushTitles are the notification titles of event types, others use the event type
+var pushTitles = map[string]string{
+	store.EventJoin:              "Member joined",
+	store.EventLeave:             "Member left",
+	EventMilestone:               "Member milestone",
+	EventMassLeave:               "Mass leave",
+	EventAnniversary:             "Join anniversary",
+	EventSyncSummary:             "Missed events",
+	store.EventBoostStart:        "New boost",
+	store.EventBoostStop:         "Boost ended",
+	store.EventTimeout:           "Member timed out",
+	store.EventTimeoutEnd:        "Timeout removed",
+	store.EventScreeningComplete: "Screening completed",
+	store.EventAvatarChange:      "Avatar changed",
+	store.EventRename:            "Member renamed",
+	EventWatchedJoin:             "Watched user joined",
+	EventWatchedLeave:            "Watched user left",
+	EventWatchedRename:           "Watched user renamed",
+	EventCrossGuildJoin:          "Member joined from another server",
+	store.EventVoiceJoin:         "Joined voice",
+	store.EventBan:               "Member banned",
+	store.EventUnban:             "Member unbanned",
+	store.EventRoleAdd:           "Role given",
+	store.EventRoleRemove:        "Role removed",
+	store.EventVoiceLeave:        "Left voice",
+	store.EventVoiceMove:         "Moved in voice",
+}
+
+var (
+	mentionPattern   = regexp.MustCompile(`<@!?(\d+)>`)
+	timestampPattern = regexp.MustCompile(`<t:(-?\d+)(:[tTdDfFR])?>`)
+)
+
+// plainText replaces Discord mentions and timestamps, which push notifications can't show
+func (t Templates) plainText(message string, event Event) string {
+	location := t.location
+	if location == nil {
+		location = time.UTC
+	}
+	message = mentionPattern.ReplaceAllStringFunc(message, func(mention string) string {
+		if tag := event.User.Tag(); tag != "" && mentionPattern.FindStringSubmatch(mention)[1] == event.UserID {
+			return "@" + tag
+		}
+		return mention
+	})
+	return timestampPattern.ReplaceAllStringFunc(message, func(timestamp string) string {
+		var unix int64
+		fmt.Sscan(timestampPattern.FindStringSubmatch(timestamp)[1], &unix)
+		return time.Unix(unix, 0).In(location).Format("2006-01-02 15:04 MST")
+	})
+}
+
+// urgent events are sent with a higher push priority
+func urgent(event Event) bool {
+	return event.Type == EventMassLeave
+}
+
+// pushClient times out slow push services
+var pushClient = &http.Client{Timeout: 10 * time.Second}
+
+func checkResponse(resp *http.Response, service string) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%v returned %v: %s", service, resp.Status, body)
+}
+
+// Ntfy sends events as ntfy push notifications
+type Ntfy struct {
+	// topicURL is like https://ntfy.sh/my-topic
+	topicURL  string
+	token     string
+	templates Templates
+}
+
+// NewNtfy publishes to a topic URL like https://ntfy.sh/my-topic, the access token is optional
+func NewNtfy(topicURL, token string, templates Templates) *Ntfy {
+	return &Ntfy{topicURL: topicURL, token: token, templates: templates}
+}
+
+func (n *Ntfy) Notify(event Event) error {
+	message, err := n.templates.Render(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, n.topicURL, strings.NewReader(n.templates.plainText(message, event)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", pushTitle(event))
+	req.Header.Set("Tags", event.Type)
+	if urgent(event) {
+		req.Header.Set("Priority", "high")
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, "ntfy")
+}
+
+func (n *Ntfy) NotifyBatch(events []Event) error {
+	for _, event := range events {
+		if err := n.Notify(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushoverURL is the Pushover message API, replaced in tests
+var pushoverURL = "https://api.pushover.net/1/messages.json"
+
+// Pushover sends events as Pushover notifications
+type Pushover struct {
+	appToken  string
+	userKey   string
+	templates Templates
+}
+
+func NewPushover(appToken, userKey string, templates Templates) *Pushover {
+	return &Pushover{appToken: appToken, userKey: userKey, templates: templates}
+}
+
+func (p *Pushover) Notify(event Event) error {
+	message, err := p.templates.Render(event)
+	if err != nil {
+		return err
+	}
+	form := url.Values{
+		"token":   {p.appToken},
+		"user":    {p.userKey},
+		"title":   {pushTitle(event)},
+		"message": {p.templates.plainText(message, event)},
+	}
+	if !event.At.IsZero() {
+		form.Set("timestamp", fmt.Sprint(event.At.Unix()))
+	}
+	if urgent(event) {
+		form.Set("priority", "1")
+	}
+	resp, err := pushClient.PostForm(pushoverURL, form)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, "pushover")
+}
+
+func (p *Pushover) NotifyBatch(events []Event) error {
+	for _, event := range events {
+		if err := p.Notify(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pushTitle(event Event) string {
+	if title, ok := pushTitles[event.Type]; ok {
+		return title
+	}
+	return event.Type
+}
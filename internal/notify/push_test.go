@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestPlainText(t *testing.T) {
+	event := Event{UserID: "1", User: store.User{Username: "alice", Discriminator: "0"}}
+	message := Templates{}.plainText("<@1> was timed out until <t:1688212800:f> by <@2>", event)
+	if expected := "@alice was timed out until 2023-07-01 12:00 UTC by <@2>"; message != expected {
+		t.Errorf("expected %q, got %q", expected, message)
+	}
+
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	message = Templates{}.In(location).plainText("until <t:1688212800:f>", event)
+	if expected := "until 2023-07-01 14:00 CEST"; message != expected {
+		t.Errorf("expected %q, got %q", expected, message)
+	}
+}
+
+func TestNtfy(t *testing.T) {
+	templates, err := ParseTemplates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var request *http.Request
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		request, body = r, string(data)
+	}))
+	defer server.Close()
+
+	ntfy := NewNtfy(server.URL+"/mods", "tk_secret", templates)
+	err = ntfy.Notify(Event{Type: EventMassLeave, At: time.Now(), Count: 5, Window: time.Minute, MemberCount: 95})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.URL.Path != "/mods" || request.Header.Get("Title") != "Mass leave" || request.Header.Get("Priority") != "high" || request.Header.Get("Authorization") != "Bearer tk_secret" {
+		t.Errorf("unexpected request %v %v", request.URL, request.Header)
+	}
+	if body != "🚨 5 members left in the last 1 minute, 95 remain" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestPushover(t *testing.T) {
+	templates, err := ParseTemplates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var form map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("token") != "app" {
+			http.Error(w, `{"status":0}`, http.StatusBadRequest)
+			return
+		}
+		form = r.PostForm
+	}))
+	defer server.Close()
+	pushoverURL = server.URL
+
+	event := Event{Type: store.EventLeave, UserID: "1", User: store.User{Username: "alice", Discriminator: "0"}, At: time.Unix(1688212800, 0)}
+	if err := NewPushover("app", "user", templates).Notify(event); err != nil {
+		t.Fatal(err)
+	}
+	if form["title"][0] != "Member left" || form["message"][0] != "@alice (alice) left the server" || form["timestamp"][0] != "1688212800" {
+		t.Errorf("unexpected form %v", form)
+	}
+	if err := NewPushover("wrong", "user", templates).Notify(event); err == nil {
+		t.Error("expected an error for a rejected message")
+	}
+}
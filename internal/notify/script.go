@@ -0,0 +1,188 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	lua "github.com/yuin/gopher-lua"
+	"go.albinodrought/discord-user-log/internal/i18n"
+)
+
+// scriptTimeout limits each run of a script's on_event, so a loop that never ends only fails that event
+const scriptTimeout = time.Second
+
+// script is a Lua hook. It runs once when parsed, defining a global on_event(event) function that gets a table
+// with the event's filter attributes for every event, and decides with the same functions as template hooks:
+// skip(), tag(name), and message(text). Globals keep their values between events.
+type script struct {
+	name     string
+	language *i18n.Language
+
+	// lock serializes runs, a Lua state can't be used concurrently
+	lock  sync.Mutex
+	state *lua.LState
+	// decided is the event being handled, the functions change it
+	decided *Event
+}
+
+// ParseScript runs a Lua hook read from a file called name, formatting numbers and durations in a language.
+// Only the base, string, table, and math libraries are available, without loading files or modules; print logs.
+func ParseScript(language *i18n.Language, name, source string) (*Hook, error) {
+	s := &script{name: name, language: language}
+	s.state = lua.NewState(lua.Options{SkipOpenLibs: true, CallStackSize: 256})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		s.state.Push(s.state.NewFunction(lib.open))
+		s.state.Push(lua.LString(lib.name))
+		s.state.Call(1, 0)
+	}
+	for _, unsafe := range []string{"dofile", "loadfile", "module", "require", "_printregs"} {
+		s.state.SetGlobal(unsafe, lua.LNil)
+	}
+	for name, fn := range map[string]lua.LGFunction{
+		"print":    s.print,
+		"skip":     s.skip,
+		"tag":      s.tag,
+		"message":  s.message,
+		"matches":  s.matches,
+		"number":   s.number,
+		"duration": s.duration,
+	} {
+		s.state.SetGlobal(name, s.state.NewFunction(fn))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), scriptTimeout)
+	defer cancel()
+	s.state.SetContext(ctx)
+	err := s.state.DoString(source)
+	s.state.RemoveContext()
+	if err != nil {
+		s.state.Close()
+		return nil, fmt.Errorf("failed to run hook script %v: %w", name, err)
+	}
+	if s.state.GetGlobal("on_event").Type() != lua.LTFunction {
+		s.state.Close()
+		return nil, fmt.Errorf("hook script %v doesn't define a function on_event(event)", name)
+	}
+	return &Hook{script: s}, nil
+}
+
+// apply runs on_event on an event, see Hook.Apply
+func (s *script) apply(event *Event) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	decided := *event
+	s.decided = &decided
+	defer func() { s.decided = nil }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), scriptTimeout)
+	defer cancel()
+	s.state.SetContext(ctx)
+	defer s.state.RemoveContext()
+	err := s.state.CallByParam(lua.P{Fn: s.state.GetGlobal("on_event"), NRet: 0, Protect: true}, s.table(*event))
+	if err != nil {
+		return fmt.Errorf("hook script %v failed on '%v' event: %w", s.name, event.Type, err)
+	}
+	*event = decided
+	return nil
+}
+
+// table has the event's attributes as named in filters
+func (s *script) table(event Event) *lua.LTable {
+	t := s.state.NewTable()
+	for name, value := range map[string]string{
+		"event":          event.Type,
+		"guild_id":       event.GuildID,
+		"user_id":        event.UserID,
+		"username":       event.User.Username,
+		"tag":            event.User.Tag(),
+		"reason":         event.Reason,
+		"moderator_id":   event.ModeratorID,
+		"channel_id":     event.ChannelID,
+		"role_id":        event.RoleID,
+		"other_guild_id": event.OtherGuildID,
+	} {
+		t.RawSetString(name, lua.LString(value))
+	}
+	t.RawSetString("member_count", lua.LNumber(event.MemberCount))
+	t.RawSetString("count", lua.LNumber(event.Count))
+	t.RawSetString("pending", lua.LBool(event.Pending))
+	memberAge := 0.0
+	if !event.JoinedAt.IsZero() {
+		memberAge = event.At.Sub(event.JoinedAt).Hours() / 24
+	}
+	t.RawSetString("member_age_days", lua.LNumber(memberAge))
+	accountAge := 0.0
+	if created, err := discordgo.SnowflakeTimestamp(event.UserID); event.UserID != "" && err == nil {
+		accountAge = event.At.Sub(created).Hours() / 24
+	}
+	t.RawSetString("account_age_days", lua.LNumber(accountAge))
+	return t
+}
+
+func (s *script) print(L *lua.LState) int {
+	values := make([]string, L.GetTop())
+	for i := range values {
+		values[i] = L.ToStringMeta(L.Get(i + 1)).String()
+	}
+	log.Printf("[hook %v] %v", s.name, strings.Join(values, "\t"))
+	return 0
+}
+
+// decidedEvent returns the event being handled, raising an error when the script's top level decides
+func (s *script) decidedEvent(L *lua.LState) *Event {
+	if s.decided == nil {
+		L.RaiseError("only on_event can decide about events")
+	}
+	return s.decided
+}
+
+func (s *script) skip(L *lua.LState) int {
+	s.decidedEvent(L).Skipped = true
+	return 0
+}
+
+func (s *script) tag(L *lua.LState) int {
+	event := s.decidedEvent(L)
+	event.Tags = append(event.Tags, L.CheckString(1))
+	return 0
+}
+
+func (s *script) message(L *lua.LState) int {
+	s.decidedEvent(L).Message = L.CheckString(1)
+	return 0
+}
+
+// matches is a Go regular expression match, Lua patterns lack alternatives and case-insensitivity
+func (s *script) matches(L *lua.LState) int {
+	matched, err := regexp.MatchString(L.CheckString(1), L.CheckString(2))
+	if err != nil {
+		L.RaiseError("%v", err)
+	}
+	L.Push(lua.LBool(matched))
+	return 1
+}
+
+func (s *script) number(L *lua.LState) int {
+	L.Push(lua.LString(s.language.FormatNumber(L.CheckInt(1))))
+	return 1
+}
+
+// duration formats seconds, like member_age_days * 86400
+func (s *script) duration(L *lua.LState) int {
+	L.Push(lua.LString(s.language.FormatDuration(time.Duration(float64(L.CheckNumber(1)) * float64(time.Second)))))
+	return 1
+}
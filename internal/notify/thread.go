@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Thread modes post announcements into a thread per day instead of the channel itself
+const (
+	// ThreadModeThread starts a public thread in a text channel
+	ThreadModeThread = "thread"
+	// ThreadModeForum creates a post in a forum channel
+	ThreadModeForum = "forum"
+)
+
+// threadNamePrefix is followed by the date, like "Member log 2023-07-01"
+const threadNamePrefix = "Member log "
+
+// threadArchiveMinutes hides daily threads after a day without messages
+const threadArchiveMinutes = 24 * 60
+
+// ThreadSession is the subset of *discordgo.Session used to post into daily threads
+type ThreadSession interface {
+	MessageSender
+	ThreadStart(channelID, name string, typ discordgo.ChannelType, archiveDuration int) (*discordgo.Channel, error)
+	GuildThreadsActive(guildID string) (*discordgo.ThreadsList, error)
+	RequestWithBucketID(method, urlStr string, data interface{}, bucketID string) ([]byte, error)
+}
+
+// DailyThread posts rendered events into a thread per day of a text or forum channel, creating it with the day's first message
+type DailyThread struct {
+	session   ThreadSession
+	channelID string
+	mode      string
+	location  *time.Location
+	templates Templates
+	now       func() time.Time
+
+	lock sync.Mutex
+	// name and threadID are the current day's thread, threadID is empty until it is found or created
+	name     string
+	threadID string
+}
+
+// NewDailyThread posts into daily threads of a channel, mode is ThreadModeThread or ThreadModeForum.
+// Days start at midnight in location.
+func NewDailyThread(session ThreadSession, channelID, mode string, location *time.Location, templates Templates) *DailyThread {
+	return &DailyThread{
+		session:   session,
+		channelID: channelID,
+		mode:      mode,
+		location:  location,
+		templates: templates,
+		now:       time.Now,
+	}
+}
+
+func (d *DailyThread) Notify(event Event) error {
+	message, err := d.templates.Render(event)
+	if err != nil {
+		return err
+	}
+	return d.send(event.GuildID, []string{message})
+}
+
+// NotifyBatch sends the rendered events into today's thread as few messages as possible, one event per line
+func (d *DailyThread) NotifyBatch(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	messages, err := batchMessages(d.templates, events)
+	if err != nil {
+		return err
+	}
+	return d.send(events[0].GuildID, messages)
+}
+
+func (d *DailyThread) send(guildID string, messages []string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	name := threadNamePrefix + d.now().In(d.location).Format("2006-01-02")
+	if name != d.name {
+		d.name, d.threadID = name, ""
+		// the thread survives restarts and reconfiguration, unlike this notifier
+		threads, err := d.session.GuildThreadsActive(guildID)
+		if err != nil {
+			return err
+		}
+		for _, thread := range threads.Threads {
+			if thread.ParentID == d.channelID && thread.Name == name {
+				d.threadID = thread.ID
+			}
+		}
+	}
+
+	for _, message := range messages {
+		if d.threadID != "" {
+			_, err := d.session.ChannelMessageSend(d.threadID, message)
+			if !isUnknownChannel(err) {
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			// a moderator deleted today's thread, start another one
+			d.threadID = ""
+		}
+		if err := d.start(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forumPost creates a forum post, discordgo can't create them yet
+type forumPost struct {
+	Name                string `json:"name"`
+	AutoArchiveDuration int    `json:"auto_archive_duration"`
+	Message             struct {
+		Content string `json:"content"`
+	} `json:"message"`
+}
+
+// start creates today's thread, posting message into it
+func (d *DailyThread) start(message string) error {
+	if d.mode == ThreadModeForum {
+		post := forumPost{Name: d.name, AutoArchiveDuration: threadArchiveMinutes}
+		post.Message.Content = message
+		endpoint := discordgo.EndpointChannelThreads(d.channelID)
+		body, err := d.session.RequestWithBucketID(http.MethodPost, endpoint, post, endpoint)
+		if err != nil {
+			return err
+		}
+		var thread discordgo.Channel
+		if err := json.Unmarshal(body, &thread); err != nil {
+			return err
+		}
+		d.threadID = thread.ID
+		return nil
+	}
+
+	thread, err := d.session.ThreadStart(d.channelID, d.name, discordgo.ChannelTypeGuildPublicThread, threadArchiveMinutes)
+	if err != nil {
+		return err
+	}
+	d.threadID = thread.ID
+	_, err = d.session.ChannelMessageSend(d.threadID, message)
+	return err
+}
+
+func isUnknownChannel(err error) bool {
+	var restErr *discordgo.RESTError
+	return errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// fakeThreads records messages by channel and creates threads with increasing IDs
+type fakeThreads struct {
+	messages map[string][]string
+	threads  []*discordgo.Channel
+	deleted  map[string]bool
+}
+
+func newFakeThreads() *fakeThreads {
+	return &fakeThreads{messages: map[string][]string{}, deleted: map[string]bool{}}
+}
+
+func (f *fakeThreads) ChannelMessageSend(channelID string, content string) (*discordgo.Message, error) {
+	if f.deleted[channelID] {
+		return nil, &discordgo.RESTError{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	}
+	f.messages[channelID] = append(f.messages[channelID], content)
+	return &discordgo.Message{ChannelID: channelID, Content: content}, nil
+}
+
+func (f *fakeThreads) startThread(parentID, name string) *discordgo.Channel {
+	thread := &discordgo.Channel{ID: fmt.Sprint(100 + len(f.threads)), ParentID: parentID, Name: name}
+	f.threads = append(f.threads, thread)
+	return thread
+}
+
+func (f *fakeThreads) ThreadStart(channelID, name string, typ discordgo.ChannelType, archiveDuration int) (*discordgo.Channel, error) {
+	return f.startThread(channelID, name), nil
+}
+
+func (f *fakeThreads) GuildThreadsActive(guildID string) (*discordgo.ThreadsList, error) {
+	return &discordgo.ThreadsList{Threads: f.threads}, nil
+}
+
+func (f *fakeThreads) RequestWithBucketID(method, urlStr string, data interface{}, bucketID string) ([]byte, error) {
+	post := data.(forumPost)
+	thread := f.startThread("1", post.Name)
+	f.messages[thread.ID] = append(f.messages[thread.ID], post.Message.Content)
+	return json.Marshal(thread)
+}
+
+func TestDailyThread(t *testing.T) {
+	templates, err := ParseTemplates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := newFakeThreads()
+	// 23:30 in Berlin
+	now := time.Date(2023, 7, 1, 21, 30, 0, 0, time.UTC)
+	newNotifier := func() *DailyThread {
+		d := NewDailyThread(session, "1", ThreadModeThread, location, templates)
+		d.now = func() time.Time { return now }
+		return d
+	}
+	d := newNotifier()
+	join := Event{Type: store.EventJoin, UserID: "2"}
+
+	if err := d.Notify(join); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.NotifyBatch([]Event{join, join}); err != nil {
+		t.Fatal(err)
+	}
+	// a new notifier, like after reconfiguring, finds the existing thread
+	d = newNotifier()
+	if err := d.Notify(join); err != nil {
+		t.Fatal(err)
+	}
+	// midnight in Berlin starts a new thread
+	now = now.Add(time.Hour)
+	if err := d.Notify(join); err != nil {
+		t.Fatal(err)
+	}
+	// so does deleting it
+	session.deleted["101"] = true
+	if err := d.Notify(join); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, thread := range session.threads {
+		names = append(names, thread.Name)
+	}
+	if expected := []string{"Member log 2023-07-01", "Member log 2023-07-02", "Member log 2023-07-02"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("created threads %v, expected %v", names, expected)
+	}
+	expected := map[string][]string{
+		"100": {"<@2> joined the server", "<@2> joined the server\n<@2> joined the server", "<@2> joined the server"},
+		"101": {"<@2> joined the server"},
+		"102": {"<@2> joined the server"},
+	}
+	if !reflect.DeepEqual(session.messages, expected) {
+		t.Errorf("sent %v, expected %v", session.messages, expected)
+	}
+}
+
+func TestDailyForumPost(t *testing.T) {
+	templates, err := ParseTemplates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := newFakeThreads()
+	d := NewDailyThread(session, "1", ThreadModeForum, time.UTC, templates)
+	d.now = func() time.Time { return time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC) }
+
+	for _, userID := range []string{"2", "3"} {
+		if err := d.Notify(Event{Type: store.EventLeave, UserID: userID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(session.threads) != 1 || session.threads[0].Name != "Member log 2023-07-01" {
+		t.Errorf("expected one post, got %+v", session.threads)
+	}
+	expected := map[string][]string{"100": {"<@2> left the server", "<@3> left the server"}}
+	if !reflect.DeepEqual(session.messages, expected) {
+		t.Errorf("sent %v, expected %v", session.messages, expected)
+	}
+}
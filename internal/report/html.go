@@ -0,0 +1,142 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// htmlReport is a standalone page, styled inline so it can be emailed, attached, or printed to PDF by a browser
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"number": notify.FormatNumber,
+	"chart":  Chart,
+	"describe": func(event string) string {
+		return notableDescriptions[event]
+	},
+	"source": describeSource,
+	"tag": func(event store.HistoryEvent) string {
+		if tag := event.User.Tag(); tag != "" {
+			return tag
+		}
+		return event.DiscordID
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 800px; margin: 2em auto; color: #2e3338; }
+h2 { border-bottom: 1px solid #ddd; padding-bottom: 0.2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.3em 0.5em; text-align: left; border-bottom: 1px solid #ddd; }
+.chart { width: 100%; color: #5865f2; }
+.chart text { font-size: 12px; fill: #4f545c; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Member activity from {{.Since}} to {{.Until}}.</p>
+{{range .Summaries}}
+<h2>{{.GuildName}}</h2>
+{{chart .Growth}}
+<table>
+<tr><th>Members</th><td>{{number .Members}}</td></tr>
+<tr><th>Joins</th><td>{{number .Joins}}</td></tr>
+<tr><th>Leaves</th><td>{{number .Leaves}}</td></tr>
+<tr><th>Net growth</th><td>{{printf "%+d" .NetGrowth}}</td></tr>
+<tr><th>Churn</th><td>{{printf "%.1f%%" .Churn}}</td></tr>
+</table>
+{{range .Milestones}}<p>Reached {{number .}} members!</p>{{end}}
+{{if .JoinSources}}
+<h3>Top join sources</h3>
+<table>
+{{range .JoinSources}}<tr><td>{{source .}}</td><td>{{number .Joins}} joins</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Notable}}
+<h3>Notable events</h3>
+<table>
+{{range .Notable}}<tr><td>{{$.Date .At}}</td><td>{{tag .}} {{describe .Event}}</td></tr>
+{{end}}</table>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+type htmlPage struct {
+	Title     string
+	Since     string
+	Until     string
+	Summaries []Summary
+	location  *time.Location
+}
+
+// Date formats a time in the timezone of the report
+func (p htmlPage) Date(t time.Time) string {
+	return t.In(p.location).Format("2006-01-02 15:04")
+}
+
+// RenderHTML formats summaries as a standalone HTML page, with a chart of each guild's member count
+func RenderHTML(period string, summaries []Summary, since, until time.Time) (string, error) {
+	subject, _ := Render(period, summaries, since, until)
+	var page strings.Builder
+	err := htmlReport.Execute(&page, htmlPage{
+		Title:     subject,
+		Since:     since.Format("2006-01-02 15:04 MST"),
+		Until:     until.Format("2006-01-02 15:04 MST"),
+		Summaries: summaries,
+		location:  until.Location(),
+	})
+	return page.String(), err
+}
+
+// Chart draws member counts as an inline SVG line chart, or nothing with fewer than two days
+func Chart(counts []store.DayCount) template.HTML {
+	const (
+		width   = 800
+		height  = 200
+		padding = 30
+	)
+	if len(counts) < 2 {
+		return ""
+	}
+	min, max := counts[0].Count, counts[0].Count
+	for _, count := range counts {
+		if count.Count < min {
+			min = count.Count
+		}
+		if count.Count > max {
+			max = count.Count
+		}
+	}
+	if max == min {
+		max = min + 1
+	}
+
+	points := make([]string, len(counts))
+	for i, count := range counts {
+		x := padding + float64(i)*(width-2*padding)/float64(len(counts)-1)
+		y := height - padding - float64(count.Count-min)*(height-2*padding)/float64(max-min)
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+
+	// every value is a number or a date, nothing needs escaping
+	return template.HTML(fmt.Sprintf(
+		`<svg class="chart" viewBox="0 0 %v %v" role="img" aria-label="Member count">`+
+			`<polyline fill="none" stroke="currentColor" stroke-width="2" points="%v"/>`+
+			`<text x="%v" y="%v">%v</text><text x="%v" y="%v">%v</text>`+
+			`<text x="%v" y="%v">%v</text><text x="%v" y="%v" text-anchor="end">%v</text>`+
+			`</svg>`,
+		width, height, strings.Join(points, " "),
+		2, padding-10, max,
+		2, height-padding+15, min,
+		padding, height-5, counts[0].Day.Format("2006-01-02"),
+		width-padding, height-5, counts[len(counts)-1].Day.Format("2006-01-02"),
+	))
+}
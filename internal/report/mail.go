@@ -0,0 +1,82 @@
+package report
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Mailer sends plain text emails over SMTP, using STARTTLS when the server supports it
+type Mailer struct {
+	// Addr is the SMTP server, like smtp.example.com:587
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Attachment is a file sent along with an email
+type Attachment struct {
+	Name        string
+	ContentType string
+	Content     []byte
+}
+
+// Send emails a message to every recipient
+func (m *Mailer) Send(subject, body string, attachments ...Attachment) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, m.To, m.message(subject, body, time.Now(), attachments...))
+}
+
+func (m *Mailer) message(subject, body string, now time.Time, attachments ...Attachment) []byte {
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %v\r\n", m.From)
+	fmt.Fprintf(&message, "To: %v\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&message, "Subject: %v\r\n", subject)
+	fmt.Fprintf(&message, "Date: %v\r\n", now.Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	body = strings.ReplaceAll(body, "\n", "\r\n")
+	if len(attachments) == 0 {
+		message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		message.WriteString(body)
+		return []byte(message.String())
+	}
+
+	// writing to a bytes.Buffer can't fail
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+	text, _ := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	text.Write([]byte(body))
+	for _, attachment := range attachments {
+		part, _ := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", attachment.Name)},
+		})
+		encoded := base64.StdEncoding.EncodeToString(attachment.Content)
+		// lines of encoded content are limited to 76 characters
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%v\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%v\r\n", encoded)
+	}
+	writer.Close()
+	fmt.Fprintf(&message, "Content-Type: multipart/mixed; boundary=%v\r\n\r\n", writer.Boundary())
+	message.Write(parts.Bytes())
+	return []byte(message.String())
+}
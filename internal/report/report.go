@@ -0,0 +1,149 @@
+// Package report emails periodic summaries of member activity, as plain text or as HTML pages.
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// Store is the subset of *store.Store summarized in reports
+type Store interface {
+	Members(guildID string) (map[string]store.Member, error)
+	CountEvents(guildID, event string, since time.Time) (int, error)
+	RecentEvents(guildID string, events []string, limit, offset int) ([]store.HistoryEvent, error)
+	MilestonesSince(guildID string, since time.Time) ([]int, error)
+	JoinSources(guildID string, since time.Time, limit int) ([]store.JoinSource, error)
+	MemberCountHistory(guildID string, current int, since time.Time, days int) ([]store.DayCount, error)
+}
+
+// notableEvents are listed individually in reports
+var notableEvents = []string{store.EventBoostStart, store.EventBoostStop, store.EventTimeout, store.EventBan}
+
+// maxNotable limits how many notable events a report lists per guild
+const maxNotable = 20
+
+// maxJoinSources limits how many invites a report lists per guild
+const maxJoinSources = 5
+
+// Summary is the activity of one guild during a report period
+type Summary struct {
+	GuildID    string
+	GuildName  string
+	Members    int
+	Joins      int
+	Leaves     int
+	Milestones []int
+	// Notable are boosts, timeouts, and bans, newest first
+	Notable []store.HistoryEvent
+	// Growth is the member count of each UTC day of the period, oldest first
+	Growth []store.DayCount
+	// JoinSources are the invites most joins used, empty without invite tracking
+	JoinSources []store.JoinSource
+}
+
+// NetGrowth is how many more members the guild has than at the start of the period
+func (s Summary) NetGrowth() int {
+	return s.Joins - s.Leaves
+}
+
+// Churn is the percentage of the members at the start of the period who left during it
+func (s Summary) Churn() float64 {
+	start := s.Members - s.Joins + s.Leaves
+	if start <= 0 {
+		return 0
+	}
+	return float64(s.Leaves) * 100 / float64(start)
+}
+
+// Summarize collects the activity of a guild since a time
+func Summarize(st Store, guildID, guildName string, since time.Time) (Summary, error) {
+	summary := Summary{GuildID: guildID, GuildName: guildName}
+	members, err := st.Members(guildID)
+	if err != nil {
+		return summary, err
+	}
+	summary.Members = len(members)
+	if summary.Joins, err = st.CountEvents(guildID, store.EventJoin, since); err != nil {
+		return summary, err
+	}
+	if summary.Leaves, err = st.CountEvents(guildID, store.EventLeave, since); err != nil {
+		return summary, err
+	}
+	if summary.Milestones, err = st.MilestonesSince(guildID, since); err != nil {
+		return summary, err
+	}
+	if summary.JoinSources, err = st.JoinSources(guildID, since, maxJoinSources); err != nil {
+		return summary, err
+	}
+	events, err := st.RecentEvents(guildID, notableEvents, maxNotable, 0)
+	if err != nil {
+		return summary, err
+	}
+	for _, event := range events {
+		if !event.At.Before(since) {
+			summary.Notable = append(summary.Notable, event)
+		}
+	}
+	day := since.UTC().Truncate(24 * time.Hour)
+	days := int(time.Since(day)/(24*time.Hour)) + 1
+	if summary.Growth, err = st.MemberCountHistory(guildID, summary.Members, day, days); err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
+
+// notableDescriptions describe notable events after the member's name
+var notableDescriptions = map[string]string{
+	store.EventBoostStart: "started boosting",
+	store.EventBoostStop:  "stopped boosting",
+	store.EventTimeout:    "was timed out",
+	store.EventBan:        "was banned",
+}
+
+// describeSource names an invite and who created it, by ID since reports don't look up users
+func describeSource(source store.JoinSource) string {
+	if source.InviterID == "" {
+		return source.Invite
+	}
+	return fmt.Sprintf("%v (created by user %v)", source.Invite, source.InviterID)
+}
+
+// Render formats summaries as the subject and plain text body of an email
+func Render(period string, summaries []Summary, since, until time.Time) (string, string) {
+	subject := fmt.Sprintf("User Log %v report, %v", period, until.Format("2006-01-02"))
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Member activity from %v to %v.\n", since.Format("2006-01-02 15:04 MST"), until.Format("2006-01-02 15:04 MST"))
+	for _, summary := range summaries {
+		fmt.Fprintf(&body, "\n%v\n%v\n", summary.GuildName, strings.Repeat("=", len([]rune(summary.GuildName))))
+		fmt.Fprintf(&body, "Members: %v\n", notify.FormatNumber(summary.Members))
+		fmt.Fprintf(&body, "Joins: %v\n", notify.FormatNumber(summary.Joins))
+		fmt.Fprintf(&body, "Leaves: %v\n", notify.FormatNumber(summary.Leaves))
+		fmt.Fprintf(&body, "Net growth: %+d\n", summary.NetGrowth())
+		fmt.Fprintf(&body, "Churn: %.1f%%\n", summary.Churn())
+		for _, milestone := range summary.Milestones {
+			fmt.Fprintf(&body, "Reached %v members!\n", notify.FormatNumber(milestone))
+		}
+		if len(summary.JoinSources) > 0 {
+			body.WriteString("\nTop join sources:\n")
+			for _, source := range summary.JoinSources {
+				fmt.Fprintf(&body, "- %v: %v joins\n", describeSource(source), notify.FormatNumber(source.Joins))
+			}
+		}
+		if len(summary.Notable) > 0 {
+			body.WriteString("\nNotable events:\n")
+			for _, event := range summary.Notable {
+				name := event.User.Tag()
+				if name == "" {
+					name = event.DiscordID
+				}
+				fmt.Fprintf(&body, "- %v: %v %v\n", event.At.In(until.Location()).Format("2006-01-02 15:04"), name, notableDescriptions[event.Event])
+			}
+		}
+	}
+	return subject, body.String()
+}
@@ -0,0 +1,136 @@
+package report
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestSchedule(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a Wednesday
+	now := time.Date(2023, 7, 5, 15, 30, 0, 0, berlin)
+	if next := Next(now, Daily); !next.Equal(time.Date(2023, 7, 6, 0, 0, 0, 0, berlin)) {
+		t.Errorf("unexpected daily report time %v", next)
+	}
+	next := Next(now, Weekly)
+	if !next.Equal(time.Date(2023, 7, 10, 0, 0, 0, 0, berlin)) {
+		t.Errorf("unexpected weekly report time %v", next)
+	}
+	if since := Previous(next, Weekly); !since.Equal(time.Date(2023, 7, 3, 0, 0, 0, 0, berlin)) {
+		t.Errorf("unexpected weekly report period start %v", since)
+	}
+	next = Next(now, Monthly)
+	if !next.Equal(time.Date(2023, 8, 1, 0, 0, 0, 0, berlin)) {
+		t.Errorf("unexpected monthly report time %v", next)
+	}
+	if since := Previous(next, Monthly); !since.Equal(time.Date(2023, 7, 1, 0, 0, 0, 0, berlin)) {
+		t.Errorf("unexpected monthly report period start %v", since)
+	}
+	// a Monday gets next week's report
+	if next := Next(time.Date(2023, 7, 10, 0, 0, 0, 0, berlin), Weekly); !next.Equal(time.Date(2023, 7, 17, 0, 0, 0, 0, berlin)) {
+		t.Errorf("unexpected weekly report time %v", next)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "dul.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	until := time.Date(2023, 7, 6, 0, 0, 0, 0, time.UTC)
+	since := Previous(until, Daily)
+	alice := store.User{Username: "alice", Discriminator: "0"}
+	for _, event := range []store.HistoryEvent{
+		{GuildID: "100", DiscordID: "1", Event: store.EventJoin, At: since.Add(-time.Hour)},
+		{GuildID: "100", DiscordID: "1", Event: store.EventBoostStart, User: alice, At: since.Add(-time.Hour)},
+		{GuildID: "100", DiscordID: "2", Event: store.EventJoin, At: since.Add(time.Hour), Details: `{"invite":"abc","inviter_id":"10"}`},
+		{GuildID: "100", DiscordID: "3", Event: store.EventJoin, At: since.Add(2 * time.Hour)},
+		{GuildID: "100", DiscordID: "4", Event: store.EventLeave, At: since.Add(3 * time.Hour)},
+		{GuildID: "100", DiscordID: "1", Event: store.EventTimeout, User: alice, At: since.Add(4 * time.Hour)},
+	} {
+		if err := st.RecordEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, discordID := range []string{"1", "2", "3"} {
+		if err := st.AddMember("100", discordID, store.Member{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.RecordMilestone("100", 3, since.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := Summarize(st, "100", "Test Server", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Members != 3 || summary.Joins != 2 || summary.Leaves != 1 || len(summary.Milestones) != 1 || len(summary.Notable) != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+
+	subject, body := Render(Daily, []Summary{summary}, since, until)
+	if subject != "User Log daily report, 2023-07-06" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	for _, expected := range []string{"Test Server\n===========\n", "Joins: 2\n", "Net growth: +1\n", "Churn: 50.0%\n", "Reached 3 members!\n", "- 2023-07-05 04:00: alice was timed out\n", "Top join sources:\n- abc (created by user 10): 1 joins\n"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in %q", expected, body)
+		}
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	until := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	since := Previous(until, Monthly)
+	summary := Summary{
+		GuildName:   "<Test Server>",
+		Members:     3,
+		Joins:       2,
+		Leaves:      1,
+		Notable:     []store.HistoryEvent{{DiscordID: "1", Event: store.EventBan, User: store.User{Username: "alice", Discriminator: "0"}, At: since.Add(time.Hour)}},
+		Growth:      []store.DayCount{{Day: since, Count: 2}, {Day: since.AddDate(0, 0, 1), Count: 3}},
+		JoinSources: []store.JoinSource{{Invite: "abc", Joins: 2}},
+	}
+	page, err := RenderHTML(Monthly, []Summary{summary}, since, until)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"<title>User Log monthly report, 2023-07-01</title>", "<h2>&lt;Test Server&gt;</h2>", "<svg class=\"chart\"", "<td>&#43;1</td>", "<td>50.0%</td>", "alice was banned", "<td>abc</td><td>2 joins</td>"} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("expected %q in %q", expected, page)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	mailer := &Mailer{From: "bot@example.com", To: []string{"a@example.com", "b@example.com"}}
+	message := string(mailer.message("Report", "line 1\nline 2\n", time.Date(2023, 7, 6, 0, 0, 0, 0, time.UTC)))
+	expected := "From: bot@example.com\r\nTo: a@example.com, b@example.com\r\nSubject: Report\r\nDate: Thu, 06 Jul 2023 00:00:00 +0000\r\n"
+	if !strings.HasPrefix(message, expected) || !strings.HasSuffix(message, "\r\n\r\nline 1\r\nline 2\r\n") {
+		t.Errorf("unexpected message %q", message)
+	}
+}
+
+func TestMessageAttachment(t *testing.T) {
+	mailer := &Mailer{From: "bot@example.com", To: []string{"a@example.com"}}
+	message := string(mailer.message("Report", "line 1\n", time.Date(2023, 7, 6, 0, 0, 0, 0, time.UTC), Attachment{
+		Name:        "report.html",
+		ContentType: "text/html; charset=utf-8",
+		Content:     []byte("<p>report</p>"),
+	}))
+	for _, expected := range []string{"Content-Type: multipart/mixed; boundary=", "\r\n\r\nline 1\r\n", "Content-Disposition: attachment; filename=\"report.html\"", "PHA+cmVwb3J0PC9wPg=="} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("expected %q in %q", expected, message)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Schedules
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+)
+
+// ValidateSchedule checks that a schedule is daily, weekly, or monthly
+func ValidateSchedule(schedule string) error {
+	if schedule != Daily && schedule != Weekly && schedule != Monthly {
+		return errors.New("the report schedule must be 'daily', 'weekly', or 'monthly'")
+	}
+	return nil
+}
+
+// Next returns when the next report is due: the next midnight, the next Monday at midnight for weekly reports,
+// or the first of the next month at midnight for monthly reports
+func Next(now time.Time, schedule string) time.Time {
+	if schedule == Monthly {
+		return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	if schedule == Weekly {
+		for next.Weekday() != time.Monday {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+		}
+	}
+	return next
+}
+
+// Previous returns when the period of a report sent at due started
+func Previous(due time.Time, schedule string) time.Time {
+	if schedule == Monthly {
+		return time.Date(due.Year(), due.Month()-1, due.Day(), 0, 0, 0, 0, due.Location())
+	}
+	days := 1
+	if schedule == Weekly {
+		days = 7
+	}
+	return time.Date(due.Year(), due.Month(), due.Day()-days, 0, 0, 0, 0, due.Location())
+}
+
+// Reporter sends a report of each period once it is over
+type Reporter struct {
+	Schedule string
+	Location *time.Location
+	Mailer   *Mailer
+	// Summarize collects the summaries of the guilds since a time
+	Summarize func(since time.Time) ([]Summary, error)
+}
+
+// Run sends reports until ctx is canceled
+func (r *Reporter) Run(ctx context.Context) {
+	for {
+		due := Next(time.Now().In(r.Location), r.Schedule)
+		select {
+		case <-time.After(time.Until(due)):
+		case <-ctx.Done():
+			return
+		}
+		if err := r.send(due); err != nil {
+			log.Printf("[report] failed to send the %v report: %v", r.Schedule, err)
+		}
+	}
+}
+
+func (r *Reporter) send(due time.Time) error {
+	since := Previous(due, r.Schedule)
+	summaries, err := r.Summarize(since)
+	if err != nil {
+		return err
+	}
+	subject, body := Render(r.Schedule, summaries, since, due)
+	var attachments []Attachment
+	if r.Schedule == Monthly {
+		page, err := RenderHTML(r.Schedule, summaries, since, due)
+		if err != nil {
+			return err
+		}
+		attachments = append(attachments, Attachment{
+			Name:        fmt.Sprintf("user-log-report-%v.html", since.Format("2006-01")),
+			ContentType: "text/html; charset=utf-8",
+			Content:     []byte(page),
+		})
+	}
+	if err := r.Mailer.Send(subject, body, attachments...); err != nil {
+		return err
+	}
+	log.Printf("[report] sent the %v report", r.Schedule)
+	return nil
+}
@@ -0,0 +1,108 @@
+// Package retention works out how many new members stay, from the recorded joins and leaves.
+package retention
+
+import (
+	"sort"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// Months is how many calendar months of joins are analyzed, including the current one
+const Months = 6
+
+// Store is the subset of *store.Store analyzed for retention
+type Store interface {
+	Stays(guildID string, since time.Time) ([]store.Stay, error)
+}
+
+// Rate counts the joins old enough to judge, and how many of them stayed
+type Rate struct {
+	Joins    int `json:"joins"`
+	Retained int `json:"retained"`
+}
+
+// Fraction is the retained share of joins, 0 if there are none
+func (r Rate) Fraction() float64 {
+	if r.Joins == 0 {
+		return 0
+	}
+	return float64(r.Retained) / float64(r.Joins)
+}
+
+// Cohort is the joins of a calendar month and how many of them haven't left since
+type Cohort struct {
+	Month     time.Time `json:"month"`
+	Joins     int       `json:"joins"`
+	Remaining int       `json:"remaining"`
+}
+
+// Stats are the retention metrics of the joins of the last Months months
+type Stats struct {
+	// Cohorts are oldest first, including months without joins
+	Cohorts []Cohort `json:"cohorts"`
+	// Day7 and Day30 count joins that didn't leave within 7 and 30 days, of the joins at least that old
+	Day7  Rate `json:"day_7"`
+	Day30 Rate `json:"day_30"`
+	// MedianStay is the median time until members who left did so, 0 if nobody left
+	MedianStay time.Duration `json:"-"`
+	// Left counts the joins that ended in a leave
+	Left int `json:"left"`
+}
+
+// Compute collects the retention metrics of a guild, months start in the location of now
+func Compute(st Store, guildID string, now time.Time) (Stats, error) {
+	y, m, _ := now.Date()
+	since := time.Date(y, m-Months+1, 1, 0, 0, 0, 0, now.Location())
+	stays, err := st.Stays(guildID, since)
+	if err != nil {
+		return Stats{}, err
+	}
+	return compute(stays, since, now), nil
+}
+
+func compute(stays []store.Stay, since, now time.Time) Stats {
+	stats := Stats{Cohorts: make([]Cohort, Months)}
+	for i := range stats.Cohorts {
+		stats.Cohorts[i].Month = since.AddDate(0, i, 0)
+	}
+
+	lengths := []time.Duration{}
+	for _, stay := range stays {
+		joinedAt := stay.JoinedAt.In(now.Location())
+		i := (joinedAt.Year()-since.Year())*12 + int(joinedAt.Month()-since.Month())
+		if i < 0 || i >= Months {
+			continue
+		}
+		stats.Cohorts[i].Joins++
+		if stay.LeftAt.IsZero() {
+			stats.Cohorts[i].Remaining++
+		} else {
+			stats.Left++
+			lengths = append(lengths, stay.LeftAt.Sub(stay.JoinedAt))
+		}
+		stats.Day7.add(stay, 7*24*time.Hour, now)
+		stats.Day30.add(stay, 30*24*time.Hour, now)
+	}
+
+	if len(lengths) > 0 {
+		sort.Slice(lengths, func(i, j int) bool { return lengths[i] < lengths[j] })
+		middle := len(lengths) / 2
+		stats.MedianStay = lengths[middle]
+		if len(lengths)%2 == 0 {
+			stats.MedianStay = (lengths[middle-1] + lengths[middle]) / 2
+		}
+	}
+	return stats
+}
+
+// add counts a stay if it joined at least window ago, as retained if it didn't leave within the window
+func (r *Rate) add(stay store.Stay, window time.Duration, now time.Time) {
+	if now.Sub(stay.JoinedAt) < window {
+		return
+	}
+	r.Joins++
+	if stay.LeftAt.IsZero() || stay.LeftAt.Sub(stay.JoinedAt) >= window {
+		r.Retained++
+	}
+}
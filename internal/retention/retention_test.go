@@ -0,0 +1,72 @@
+package retention
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+type fakeStore []store.Stay
+
+func (f fakeStore) Stays(guildID string, since time.Time) ([]store.Stay, error) {
+	stays := []store.Stay{}
+	for _, stay := range f {
+		if !stay.JoinedAt.Before(since) {
+			stays = append(stays, stay)
+		}
+	}
+	return stays, nil
+}
+
+func TestCompute(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Date(2023, 7, 20, 12, 0, 0, 0, time.UTC)
+	at := func(month time.Month, d int) time.Time { return time.Date(2023, month, d, 12, 0, 0, 0, time.UTC) }
+	st := fakeStore{
+		// too old to count
+		{DiscordID: "0", JoinedAt: at(1, 10)},
+		{DiscordID: "1", JoinedAt: at(2, 1)},
+		{DiscordID: "2", JoinedAt: at(2, 1), LeftAt: at(2, 1).Add(2 * day)},
+		{DiscordID: "3", JoinedAt: at(5, 1), LeftAt: at(5, 1).Add(10 * day)},
+		{DiscordID: "4", JoinedAt: at(5, 15), LeftAt: at(5, 15).Add(60 * day)},
+		{DiscordID: "5", JoinedAt: at(7, 1)},
+		// too new for the 7 day retention
+		{DiscordID: "6", JoinedAt: at(7, 18)},
+	}
+
+	stats, err := Compute(st, "g", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cohorts := []Cohort{
+		{Month: time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), Joins: 2, Remaining: 1},
+		{Month: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Month: time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{Month: time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC), Joins: 2},
+		{Month: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Month: time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC), Joins: 2, Remaining: 2},
+	}
+	if !reflect.DeepEqual(stats.Cohorts, cohorts) {
+		t.Errorf("unexpected cohorts %+v", stats.Cohorts)
+	}
+	if expected := (Rate{Joins: 5, Retained: 4}); stats.Day7 != expected {
+		t.Errorf("unexpected 7 day retention %+v", stats.Day7)
+	}
+	if expected := (Rate{Joins: 4, Retained: 2}); stats.Day30 != expected {
+		t.Errorf("unexpected 30 day retention %+v", stats.Day30)
+	}
+	if stats.Left != 3 || stats.MedianStay != 10*day {
+		t.Errorf("unexpected median stay %v of %v leaves", stats.MedianStay, stats.Left)
+	}
+}
+
+func TestRateFraction(t *testing.T) {
+	if fraction := (Rate{}).Fraction(); fraction != 0 {
+		t.Errorf("expected 0 without joins, got %v", fraction)
+	}
+	if fraction := (Rate{Joins: 4, Retained: 3}).Fraction(); fraction != 0.75 {
+		t.Errorf("expected 0.75, got %v", fraction)
+	}
+}
@@ -0,0 +1,321 @@
+// Package rpc serves the members and history of the tracked guilds, and a stream of live events, over gRPC.
+// The API is defined in api/userlog/v1/userlog.proto, and served with grpc-go and the stubs generated next to it.
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	// registers gzip, so clients can compress requests and ask for compressed responses
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	userlogv1 "go.albinodrought/discord-user-log/api/userlog/v1"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+const (
+	// maxPageSize limits listed members and history events
+	maxPageSize = 1000
+	// defaultHistoryLimit is used when a history request has no limit
+	defaultHistoryLimit = 100
+	// subscriberBuffer is how many events a slow subscriber can fall behind before events are dropped
+	subscriberBuffer = 64
+)
+
+// historyEvents are the types returned by history requests without event types
+var historyEvents = []string{
+	store.EventJoin, store.EventLeave, store.EventBoostStart, store.EventBoostStop, store.EventTimeout, store.EventTimeoutEnd,
+	store.EventScreeningComplete, store.EventAvatarChange, store.EventRename, store.EventBan, store.EventUnban,
+	store.EventRoleAdd, store.EventRoleRemove, store.EventVoiceJoin, store.EventVoiceLeave, store.EventVoiceMove,
+}
+
+// Store is the subset of *store.Store the API reads
+type Store interface {
+	Members(guildID string) (map[string]store.Member, error)
+	RecentEvents(guildID string, events []string, limit, offset int) ([]store.HistoryEvent, error)
+	UserHistory(guildID, discordID string) ([]store.HistoryEvent, error)
+}
+
+// Server serves the API, and streams the events it is published to subscribers
+type Server struct {
+	userlogv1.UnimplementedUserLogServer
+
+	store  Store
+	token  string
+	server *grpc.Server
+	// stopping is closed when shutting down, ending the event streams
+	stopping chan struct{}
+	stopOnce sync.Once
+
+	lock        sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	guildID string
+	events  chan notify.Event
+}
+
+// New serves a store to clients sending token, options are like grpc.Creds for TLS
+func New(st Store, token string, options ...grpc.ServerOption) *Server {
+	s := &Server{store: st, token: token, stopping: make(chan struct{}), subscribers: map[*subscriber]struct{}{}}
+	options = append(options, grpc.ChainUnaryInterceptor(s.authorizeUnary), grpc.ChainStreamInterceptor(s.authorizeStream))
+	s.server = grpc.NewServer(options...)
+	userlogv1.RegisterUserLogServer(s.server, s)
+	return s
+}
+
+// Serve accepts calls on listener until Shutdown
+func (s *Server) Serve(listener net.Listener) error {
+	return s.server.Serve(listener)
+}
+
+// Shutdown ends the event streams and waits for running calls, canceling them when ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stopping) })
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
+
+// Publish sends an event to the subscribers of its guild without blocking, dropping it for subscribers that fell behind
+func (s *Server) Publish(event notify.Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for sub := range s.subscribers {
+		if sub.guildID != "" && sub.guildID != event.GuildID {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			log.Printf("[rpc] subscriber fell behind, dropped '%v' event", event.Type)
+		}
+	}
+}
+
+func (s *Server) subscribe(guildID string) (*subscriber, func()) {
+	sub := &subscriber{guildID: guildID, events: make(chan notify.Event, subscriberBuffer)}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.subscribers[sub] = struct{}{}
+	return sub, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		delete(s.subscribers, sub)
+	}
+}
+
+func (s *Server) authorizeUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !s.authorized(ctx) {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	resp, err := handler(ctx, req)
+	return resp, internalError(info.FullMethod, err)
+}
+
+func (s *Server) authorizeStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !s.authorized(stream.Context()) {
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return internalError(info.FullMethod, handler(srv, stream))
+}
+
+// authorized checks the token from the authorization metadata
+func (s *Server) authorized(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token := strings.TrimPrefix(value, "Bearer ")
+		if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// internalError logs errors without a status, like failed queries, and hides them from clients
+func internalError(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	log.Printf("[rpc] %v failed: %v", method, err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// ListMembers returns the current members of a guild, ordered by user ID
+func (s *Server) ListMembers(ctx context.Context, req *userlogv1.ListMembersRequest) (*userlogv1.ListMembersResponse, error) {
+	if req.GuildId == "" {
+		return nil, status.Error(codes.InvalidArgument, "guild_id is required")
+	}
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 || pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	members, err := s.store.Members(req.GuildId)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(members))
+	for id := range members {
+		if req.PageToken == "" || snowflakeLess(req.PageToken, id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return snowflakeLess(ids[i], ids[j]) })
+
+	resp := &userlogv1.ListMembersResponse{}
+	if len(ids) > pageSize {
+		ids = ids[:pageSize]
+		resp.NextPageToken = ids[pageSize-1]
+	}
+	for _, id := range ids {
+		resp.Members = append(resp.Members, memberMessage(id, members[id]))
+	}
+	return resp, nil
+}
+
+// snowflakeLess orders Discord IDs by their number
+func snowflakeLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// GetHistory returns history events of a guild, newest first, or every event of one member, oldest first
+func (s *Server) GetHistory(ctx context.Context, req *userlogv1.GetHistoryRequest) (*userlogv1.GetHistoryResponse, error) {
+	if req.GuildId == "" {
+		return nil, status.Error(codes.InvalidArgument, "guild_id is required")
+	}
+	if req.UserId != "" {
+		events, err := s.store.UserHistory(req.GuildId, req.UserId)
+		return historyResponse(events), err
+	}
+	eventTypes := req.EventTypes
+	if len(eventTypes) == 0 {
+		eventTypes = historyEvents
+	}
+	for _, eventType := range eventTypes {
+		known := false
+		for _, historyEvent := range historyEvents {
+			known = known || historyEvent == eventType
+		}
+		if !known {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown event type '%v'", eventType)
+		}
+	}
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	if req.Offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "offset can't be negative")
+	}
+	events, err := s.store.RecentEvents(req.GuildId, eventTypes, limit, int(req.Offset))
+	return historyResponse(events), err
+}
+
+// SubscribeEvents streams events until the call is canceled or the server shuts down
+func (s *Server) SubscribeEvents(req *userlogv1.SubscribeEventsRequest, stream userlogv1.UserLog_SubscribeEventsServer) error {
+	sub, cancel := s.subscribe(req.GuildId)
+	defer cancel()
+	// the response headers tell the client the stream started
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case <-s.stopping:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case published := <-sub.events:
+			if err := stream.Send(eventMessage(published)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func memberMessage(id string, m store.Member) *userlogv1.Member {
+	return &userlogv1.Member{
+		UserId:        id,
+		Username:      m.Username,
+		Discriminator: m.Discriminator,
+		Nick:          m.Nick,
+		Avatar:        m.Avatar,
+		JoinedAt:      timestamp(m.JoinedAt),
+		PremiumSince:  timestamp(m.PremiumSince),
+		TimeoutUntil:  timestamp(m.TimeoutUntil),
+		Pending:       m.Pending,
+		Roles:         m.Roles,
+	}
+}
+
+func historyResponse(events []store.HistoryEvent) *userlogv1.GetHistoryResponse {
+	resp := &userlogv1.GetHistoryResponse{}
+	for _, event := range events {
+		resp.Events = append(resp.Events, &userlogv1.HistoryEvent{
+			Event:         event.Event,
+			UserId:        event.DiscordID,
+			Username:      event.User.Username,
+			Discriminator: event.User.Discriminator,
+			At:            timestamp(event.At),
+			Details:       event.Details,
+		})
+	}
+	return resp
+}
+
+func eventMessage(event notify.Event) *userlogv1.Event {
+	return &userlogv1.Event{
+		Type:          event.Type,
+		GuildId:       event.GuildID,
+		UserId:        event.UserID,
+		Username:      event.User.Username,
+		Discriminator: event.User.Discriminator,
+		At:            timestamp(event.At),
+		MemberCount:   int32(event.MemberCount),
+		Until:         timestamp(event.Until),
+		Pending:       event.Pending,
+		Avatar:        event.Avatar,
+		Count:         int32(event.Count),
+		WindowSeconds: event.Window.Seconds(),
+		Tags:          event.Tags,
+	}
+}
+
+// timestamp leaves zero times unset
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,152 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	userlogv1 "go.albinodrought/discord-user-log/api/userlog/v1"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// openTestServer serves a new store in memory, returning a client and a context sending the token
+func openTestServer(t *testing.T) (*Server, userlogv1.UserLogClient, context.Context, *store.Store) {
+	t.Helper()
+	st, err := store.Open(filepath.Join(t.TempDir(), "dul.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	s := New(st, "secret")
+	listener := bufconn.Listen(1 << 20)
+	go s.Serve(listener)
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return s, userlogv1.NewUserLogClient(conn), metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret"), st
+}
+
+func TestListMembers(t *testing.T) {
+	_, client, ctx, st := openTestServer(t)
+	for _, id := range []string{"30", "100", "2"} {
+		if err := st.AddMember("1", id, store.Member{User: store.User{Username: "user" + id}, Roles: []string{"5"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wrong := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer wrong")
+	if _, err := client.ListMembers(wrong, &userlogv1.ListMembersRequest{GuildId: "1"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected unauthenticated, got %v", err)
+	}
+	if _, err := client.ListMembers(ctx, &userlogv1.ListMembersRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected a missing guild to be invalid, got %v", err)
+	}
+
+	req := &userlogv1.ListMembersRequest{GuildId: "1", PageSize: 2}
+	// compressed requests work like any other
+	resp, err := client.ListMembers(ctx, req, grpc.UseCompressor(gzip.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, m := range resp.Members {
+		ids = append(ids, m.UserId)
+		if !reflect.DeepEqual(m.Roles, []string{"5"}) {
+			t.Errorf("expected roles, got %v", m.Roles)
+		}
+	}
+	if !reflect.DeepEqual(ids, []string{"2", "30"}) {
+		t.Errorf("expected the first page ordered by ID, got %v", ids)
+	}
+	if resp.NextPageToken != "30" {
+		t.Fatalf("expected a next page token, got %v", resp.NextPageToken)
+	}
+
+	req.PageToken = resp.NextPageToken
+	resp, err = client.ListMembers(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Members) != 1 || resp.Members[0].UserId != "100" || resp.NextPageToken != "" {
+		t.Errorf("expected the last page, got %v", resp)
+	}
+}
+
+func TestGetHistory(t *testing.T) {
+	_, client, ctx, st := openTestServer(t)
+	at := time.Unix(1700000000, 0)
+	for i, event := range []string{store.EventJoin, store.EventBan, store.EventLeave} {
+		if err := st.RecordEvent(store.HistoryEvent{GuildID: "1", DiscordID: "2", Event: event, At: at.Add(time.Duration(i) * time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := &userlogv1.GetHistoryRequest{GuildId: "1", EventTypes: []string{store.EventJoin, store.EventLeave}}
+	resp, err := client.GetHistory(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := []string{}
+	for _, event := range resp.Events {
+		types = append(types, event.Event)
+	}
+	if !reflect.DeepEqual(types, []string{store.EventLeave, store.EventJoin}) {
+		t.Errorf("expected the selected types newest first, got %v", types)
+	}
+	if !resp.Events[1].At.AsTime().Equal(at) {
+		t.Errorf("expected the join's time, got %v", resp.Events[1].At.AsTime())
+	}
+
+	req.EventTypes = []string{"unknown"}
+	if _, err := client.GetHistory(ctx, req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected an unknown type to be invalid, got %v", err)
+	}
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	s, client, ctx, _ := openTestServer(t)
+	stream, err := client.SubscribeEvents(ctx, &userlogv1.SubscribeEventsRequest{GuildId: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the headers arrive once the server subscribed
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Publish(notify.Event{Type: store.EventJoin, GuildID: "2", UserID: "3"})
+	s.Publish(notify.Event{Type: store.EventLeave, GuildID: "1", UserID: "4", Tags: []string{"spam"}})
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != store.EventLeave || !reflect.DeepEqual(event.Tags, []string{"spam"}) {
+		t.Errorf("expected only the subscribed guild's leave with its tags, got %v", event)
+	}
+
+	s.Shutdown(context.Background())
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected shutting down to end the stream, got %v", err)
+	}
+}
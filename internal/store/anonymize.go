@@ -0,0 +1,106 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+)
+
+// pseudonymPrefix starts the Discord IDs of anonymized members, real IDs are numeric
+const pseudonymPrefix = "anon:"
+
+// IsPseudonym returns whether a Discord ID was replaced by Anonymize
+func IsPseudonym(discordID string) bool {
+	return strings.HasPrefix(discordID, pseudonymPrefix)
+}
+
+// anonymizedTables are deleted from for anonymized members, the history is kept under a pseudonym
+var anonymizedTables = []string{"name_history", "join_messages", "anniversaries", "presence", "first_messages", "last_messages", "webhook_failures", "leave_reasons", "event_rsvps", "notes", "member_lists"}
+
+// Anonymize replaces the Discord IDs of former members whose last event in a guild is older than cutoff with pseudonyms in the history,
+// clearing their names and event details, and deletes everything else stored about them except the watch list.
+// The same user always gets the same pseudonym, so counts, stays, and retention still add up.
+// It returns how many members were anonymized.
+func (s *Store) Anonymize(cutoff time.Time) (int64, error) {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	salt, err := anonymizationSalt(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(`SELECT guild_id, discord_id FROM history h
+		WHERE discord_id NOT LIKE ? AND NOT EXISTS (SELECT 1 FROM members m WHERE m.guild_id = h.guild_id AND m.discord_id = h.discord_id)
+		GROUP BY guild_id, discord_id HAVING MAX(created_at) < ?`, pseudonymPrefix+"%", cutoff.Unix())
+	if err != nil {
+		return 0, err
+	}
+	type formerMember struct{ guildID, discordID string }
+	former := []formerMember{}
+	for rows.Next() {
+		var member formerMember
+		if err := rows.Scan(&member.guildID, &member.discordID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		former = append(former, member)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, member := range former {
+		if _, err := tx.Exec("UPDATE history SET discord_id = ?, discord_username = '', discord_discriminator = '', details = '' WHERE guild_id = ? AND discord_id = ?", pseudonym(salt, member.discordID), member.guildID, member.discordID); err != nil {
+			return 0, err
+		}
+		for _, table := range anonymizedTables {
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE guild_id = ? AND discord_id = ?", member.guildID, member.discordID); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	s.cache.invalidateAll()
+	if len(former) > 0 {
+		log.Printf("[anonymize] anonymized %v former members who left before %v", len(former), cutoff)
+	}
+	return int64(len(former)), nil
+}
+
+// anonymizationSalt returns the random key pseudonyms are derived with, creating it the first time.
+// Without it, pseudonyms could be reversed by hashing every possible Discord ID.
+func anonymizationSalt(tx *sql.Tx) ([]byte, error) {
+	var encoded string
+	err := tx.QueryRow("SELECT value FROM secrets WHERE name = 'anonymization'").Scan(&encoded)
+	if err == sql.ErrNoRows {
+		salt := make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		encoded = hex.EncodeToString(salt)
+		_, err = tx.Exec("INSERT INTO secrets(name, value) VALUES ('anonymization', ?)", encoded)
+		return salt, err
+	} else if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(encoded)
+}
+
+func pseudonym(salt []byte, discordID string) string {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(discordID))
+	return pseudonymPrefix + hex.EncodeToString(mac.Sum(nil))[:16]
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// cacheSize is how many history query results are kept, the least recently used are dropped first
+	cacheSize = 1024
+	// cacheTTL bounds how stale results can get when another process, like the CLI, writes to the database
+	cacheTTL = time.Minute
+)
+
+// historyCache keeps the results of history queries run by commands and the web server.
+// Results are dropped when the history or snapshots of their guild change, and after cacheTTL.
+type historyCache struct {
+	lock    sync.Mutex
+	entries map[cacheKey]*list.Element
+	// order has the most recently used entry in front
+	order *list.List
+	// generation counts changes, results of queries that ran during a change aren't kept
+	generation uint64
+}
+
+type cacheKey struct {
+	guildID string
+	query   string
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	value   interface{}
+	expires time.Time
+}
+
+func newHistoryCache() *historyCache {
+	return &historyCache{
+		entries: map[cacheKey]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// key identifies a query of a guild by its name and arguments
+func (c *historyCache) key(guildID, query string, args ...interface{}) cacheKey {
+	return cacheKey{guildID: guildID, query: fmt.Sprint(append([]interface{}{query}, args...)...)}
+}
+
+// get returns a cached result, or the generation to pass to put
+func (c *historyCache) get(key cacheKey, now time.Time) (interface{}, uint64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if now.Before(entry.expires) {
+			c.order.MoveToFront(element)
+			return entry.value, 0, true
+		}
+		c.remove(element)
+	}
+	return nil, c.generation, false
+}
+
+// put caches a result unless anything changed since get
+func (c *historyCache) put(key cacheKey, generation uint64, value interface{}, now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.generation != generation {
+		return
+	}
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value, expires: now.Add(cacheTTL)})
+	for c.order.Len() > cacheSize {
+		c.remove(c.order.Back())
+	}
+}
+
+// invalidate drops the results of a guild
+func (c *historyCache) invalidate(guildID string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation++
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		if element.Value.(*cacheEntry).key.guildID == guildID {
+			c.remove(element)
+		}
+		element = next
+	}
+}
+
+// invalidateAll drops every result, for changes across guilds
+func (c *historyCache) invalidateAll() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation++
+	for _, element := range c.entries {
+		c.remove(element)
+	}
+}
+
+func (c *historyCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryCache(t *testing.T) {
+	st := openTestStore(t)
+	record := func(st *Store, discordID string) {
+		t.Helper()
+		if err := st.RecordEvent(HistoryEvent{GuildID: "100", DiscordID: discordID, Event: EventJoin, At: time.Unix(1000, 0)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func() int {
+		t.Helper()
+		events, err := st.RecentEvents("100", []string{EventJoin}, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(events)
+	}
+
+	record(st, "1")
+	if n := count(); n != 1 {
+		t.Fatalf("expected 1 event, got %v", n)
+	}
+	// cached results are copies
+	events, _ := st.RecentEvents("100", []string{EventJoin}, 10, 0)
+	events[0].DiscordID = "changed"
+	if events, _ := st.RecentEvents("100", []string{EventJoin}, 10, 0); events[0].DiscordID != "1" {
+		t.Errorf("expected the cached result to be unchanged, got %+v", events)
+	}
+
+	record(st, "2")
+	if n := count(); n != 2 {
+		t.Errorf("expected recording to drop the cached result, got %v events", n)
+	}
+
+	tx, err := st.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(tx, "3")
+	if n := count(); n != 2 {
+		t.Errorf("expected uncommitted events to be invisible, got %v events", n)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 3 {
+		t.Errorf("expected committing to drop the cached result, got %v events", n)
+	}
+
+	if _, err := st.Forget("3"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("expected forgetting to drop the cached result, got %v events", n)
+	}
+}
+
+func TestHistoryCacheEviction(t *testing.T) {
+	cache := newHistoryCache()
+	now := time.Unix(1000, 0)
+	for i := 0; i <= cacheSize; i++ {
+		if i == cacheSize {
+			// using the first result keeps it, the second is the least recently used
+			cache.get(cache.key("100", "query", 0), now)
+		}
+		key := cache.key("100", "query", i)
+		_, generation, _ := cache.get(key, now)
+		cache.put(key, generation, i, now)
+	}
+	if _, _, ok := cache.get(cache.key("100", "query", 0), now); !ok {
+		t.Error("expected the recently used result to be kept")
+	}
+	if _, _, ok := cache.get(cache.key("100", "query", 1), now); ok {
+		t.Error("expected the least recently used result to be evicted")
+	}
+	if _, _, ok := cache.get(cache.key("100", "query", 0), now.Add(cacheTTL)); ok {
+		t.Error("expected the result to expire")
+	}
+
+	// results of queries that ran during a change aren't kept
+	key := cache.key("200", "query")
+	_, generation, _ := cache.get(key, now)
+	cache.invalidate("100")
+	cache.put(key, generation, "stale", now)
+	if _, _, ok := cache.get(key, now); ok {
+		t.Error("expected the stale result to be dropped")
+	}
+}
+
+func TestHistoryIndexes(t *testing.T) {
+	st := openTestStore(t)
+	for query, index := range map[string]string{
+		"SELECT event FROM history WHERE guild_id = ? AND discord_id = ? ORDER BY created_at, id": "history_guild_id_discord_id",
+		"SELECT COUNT(*) FROM history WHERE guild_id = ? AND event = ? AND created_at >= ?":       "history_guild_id_event",
+	} {
+		rows, err := st.conn.Query("EXPLAIN QUERY PLAN "+query, "100", "1", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan := ""
+		for rows.Next() {
+			var id, parent, unused int
+			var detail string
+			if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+				t.Fatal(err)
+			}
+			plan += detail + "\n"
+		}
+		rows.Close()
+		if !strings.Contains(plan, index) {
+			t.Errorf("expected %q to use %v, got %v", query, index, plan)
+		}
+	}
+}
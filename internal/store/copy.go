@@ -0,0 +1,211 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strings"
+)
+
+// copyBatch is how many rows are inserted by one statement when copying, within the parameter limits of SQLite and Postgres
+const copyBatch = 500
+
+// notCopied are the tables each database keeps for itself: the migrations it applied, and the leases of running instances
+var notCopied = map[string]bool{"migrations": true, "leases": true}
+
+// TableCopy is how many rows of a table Copy copied
+type TableCopy struct {
+	Table string
+	Rows  int64
+}
+
+// Copy copies every row of from into to, which must be empty, like moving from SQLite to Postgres.
+// The rows are written in one transaction, and compared afterwards: each table must have as many rows in both databases,
+// with the same checksum of their values. Both databases are migrated by this build, so their tables match.
+func Copy(from, to *Store) ([]TableCopy, error) {
+	if from.tx != nil || to.tx != nil {
+		return nil, errors.New("already in a transaction")
+	}
+	// the source is read in one transaction, the copy and its verification see the same rows
+	source, err := from.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer source.Rollback()
+
+	tables, err := copiedTables(source, from.dialect)
+	if err != nil {
+		return nil, err
+	}
+	destinationTables, err := copiedTables(to.conn, to.dialect)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Join(tables, ",") != strings.Join(destinationTables, ",") {
+		return nil, fmt.Errorf("the databases have different tables: %v and %v", tables, destinationTables)
+	}
+	for _, table := range tables {
+		var rows int64
+		if err := to.conn.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&rows); err != nil {
+			return nil, err
+		}
+		if rows > 0 {
+			return nil, fmt.Errorf("the destination isn't empty, its table %v has %v rows", table, rows)
+		}
+	}
+
+	destination, err := to.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer destination.Rollback()
+	copied := []TableCopy{}
+	for _, table := range tables {
+		rows, err := copyTable(source, destination, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy %v: %w", table, err)
+		}
+		if to.dialect == postgres {
+			// explicit IDs don't advance the identity sequences, new rows would collide with the copied ones
+			if _, err := destination.Exec(`SELECT setval(pg_get_serial_sequence(?, 'id'), COALESCE(MAX(id), 0) + 1, false) FROM `+table+`
+				WHERE EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = 'id')`, table, table); err != nil {
+				return nil, fmt.Errorf("failed to advance the IDs of %v: %w", table, err)
+			}
+		}
+		log.Printf("[copy] copied %v rows of %v", rows, table)
+		copied = append(copied, TableCopy{Table: table, Rows: rows})
+	}
+	if err := destination.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, table := range tables {
+		sourceRows, sourceSum, err := tableChecksum(source, table)
+		if err != nil {
+			return copied, err
+		}
+		copiedRows, copiedSum, err := tableChecksum(to.conn, table)
+		if err != nil {
+			return copied, err
+		}
+		if sourceRows != copiedRows || sourceSum != copiedSum {
+			return copied, fmt.Errorf("%v differs after copying: %v rows with checksum %016x, copied %v rows with checksum %016x", table, sourceRows, sourceSum, copiedRows, copiedSum)
+		}
+	}
+	return copied, nil
+}
+
+// copiedTables lists the tables of a database that Copy copies
+func copiedTables(q querier, d dialect) ([]string, error) {
+	rows, err := q.Query(d.tablesQuery())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := []string{}
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		if !notCopied[table] {
+			tables = append(tables, table)
+		}
+	}
+	return tables, rows.Err()
+}
+
+// copyTable inserts every row of a table in source into destination, in batches of copyBatch rows
+func copyTable(source, destination querier, table string) (int64, error) {
+	rows, err := source.Query("SELECT * FROM " + table)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	insert := func(args []interface{}) error {
+		batch := len(args) / len(columns)
+		_, err := destination.Exec("INSERT INTO "+table+" ("+strings.Join(columns, ", ")+") VALUES "+strings.TrimSuffix(strings.Repeat(placeholders+", ", batch), ", "), args...)
+		return err
+	}
+
+	var copied int64
+	args := make([]interface{}, 0, copyBatch*len(columns))
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return copied, err
+		}
+		args = append(args, values...)
+		copied++
+		if copied%copyBatch == 0 {
+			if err := insert(args); err != nil {
+				return copied, err
+			}
+			args = args[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return copied, err
+	}
+	if len(args) > 0 {
+		return copied, insert(args)
+	}
+	return copied, nil
+}
+
+// tableChecksum counts the rows of a table, and sums hashes of their values by column name,
+// which doesn't depend on the order of rows or columns, or on how each database returns text
+func tableChecksum(q querier, table string) (int64, uint64, error) {
+	rows, err := q.Query("SELECT * FROM " + table)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, 0, err
+	}
+	order := make([]int, len(columns))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return columns[order[i]] < columns[order[j]] })
+
+	var count int64
+	var sum uint64
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return 0, 0, err
+		}
+		hash := fnv.New64a()
+		for _, i := range order {
+			switch value := values[i].(type) {
+			case nil:
+				fmt.Fprintf(hash, "%v\x00\x01", columns[i])
+			case []byte:
+				fmt.Fprintf(hash, "%v\x00%s\x00", columns[i], value)
+			default:
+				fmt.Fprintf(hash, "%v\x00%v\x00", columns[i], value)
+			}
+		}
+		sum += hash.Sum64()
+		count++
+	}
+	return count, sum, rows.Err()
+}
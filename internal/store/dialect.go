@@ -0,0 +1,80 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dialect is the SQL flavor of a database. Queries are written to run on both, with the few exceptions asking their dialect.
+type dialect int
+
+const (
+	sqlite dialect = iota
+	postgres
+)
+
+// String is the database's name in telemetry
+func (d dialect) String() string {
+	if d == postgres {
+		return "postgresql"
+	}
+	return "sqlite"
+}
+
+// migrations is the directory of the dialect's schema migrations
+func (d dialect) migrations() string {
+	if d == postgres {
+		return "migrations_postgres"
+	}
+	return "migrations"
+}
+
+// tablesQuery lists the tables of a database, without the ones SQLite keeps itself
+func (d dialect) tablesQuery() string {
+	if d == postgres {
+		return "SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' ORDER BY table_name"
+	}
+	return "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name"
+}
+
+// jsonField extracts a string field of the JSON object in a column, NULL if it is missing
+func (d dialect) jsonField(column, field string) string {
+	if d == postgres {
+		return fmt.Sprintf("(%v::json ->> '%v')", column, field)
+	}
+	return fmt.Sprintf("json_extract(%v, '$.%v')", column, field)
+}
+
+// isJSON is a condition that a column holds JSON, like event details that are JSON or empty.
+// Postgres can't check without failing on invalid JSON, so it checks for an object.
+func (d dialect) isJSON(column string) string {
+	if d == postgres {
+		return column + " LIKE '{%'"
+	}
+	return "json_valid(" + column + ")"
+}
+
+// rebind numbers the ? placeholders of a query like Postgres expects them, $1, $2, and so on.
+// Quoted strings and identifiers are left alone.
+func rebind(query string) string {
+	var rebound strings.Builder
+	placeholders := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			placeholders++
+			fmt.Fprintf(&rebound, "$%d", placeholders)
+			continue
+		}
+		rebound.WriteByte(c)
+	}
+	return rebound.String()
+}
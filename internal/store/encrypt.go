@@ -0,0 +1,58 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrNoSQLCipher is returned when opening a database with a key in a build that isn't linked against SQLCipher
+var ErrNoSQLCipher = errors.New("encryption keys need a build linked against SQLCipher, see the README")
+
+// IsCorrupt reports whether SQLite failed because the database file is corrupt, or isn't a database.
+// SQLCipher can't tell a wrong key from a damaged file, so with a key only corruption SQLite found counts.
+func IsCorrupt(err error, key string) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrCorrupt || (sqliteErr.Code == sqlite3.ErrNotADB && key == "")
+}
+
+// keyedDrivers numbers the drivers registered for encryption keys, each key needs its own
+var keyedDrivers int64
+
+// openDB opens the SQLite database at dsn, or the SQLCipher database if key isn't empty.
+// Plain SQLite ignores keys, so opening fails with ErrNoSQLCipher instead of leaving the file unencrypted.
+func openDB(dsn, key string) (*sql.DB, error) {
+	if key == "" {
+		return sql.Open("sqlite3", dsn)
+	}
+
+	driverName := fmt.Sprintf("sqlite3_keyed_%v", atomic.AddInt64(&keyedDrivers, 1))
+	quotedKey := "'" + strings.ReplaceAll(key, "'", "''") + "'"
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			// the key must be set before anything is read from a connection
+			_, err := conn.Exec("PRAGMA key = "+quotedKey, nil)
+			return err
+		},
+	})
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	var version string
+	if err := db.QueryRow("PRAGMA cipher_version").Scan(&version); err == sql.ErrNoRows {
+		db.Close()
+		return nil, ErrNoSQLCipher
+	} else if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,315 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// migrations are applied in name order. Each <name>.sql has a paired <name>.down.sql reverting it.
+// SQLite databases use migrations, Postgres ones migrations_postgres.
+//
+//go:embed migrations migrations_postgres
+var migrations embed.FS
+
+// downSuffix marks the file reverting the migration of the same name
+const downSuffix = ".down.sql"
+
+// MigrationStatus is the state of an embedded migration, or of an applied one this build doesn't know
+type MigrationStatus struct {
+	Name    string
+	Applied bool
+	// Changed is set if the migration's SQL differs from what was applied
+	Changed bool
+	// Unknown is set for applied migrations without an embedded file, from a newer build
+	Unknown bool
+}
+
+// Migrator applies and reverts the schema migrations of a database
+type Migrator struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// OpenMigrator opens (or creates) the SQLite database at path, or the Postgres database at a postgres:// URL, without migrating it
+func OpenMigrator(path string) (*Migrator, error) {
+	return OpenEncryptedMigrator(path, "")
+}
+
+// OpenEncryptedMigrator opens the SQLCipher database at path encrypted with key, like OpenMigrator
+func OpenEncryptedMigrator(path, key string) (*Migrator, error) {
+	var db *sql.DB
+	d := sqlite
+	var err error
+	if IsPostgres(path) {
+		if key != "" {
+			return nil, ErrSQLiteOnly
+		}
+		d = postgres
+		db, _, err = openPostgresDB(path)
+	} else {
+		db, err = openDB(path, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := &Migrator{db: db, dialect: d}
+	if err := m.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Close closes the underlying database
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+func migrate(db *sql.DB, d dialect) error {
+	m := &Migrator{db: db, dialect: d}
+	if err := m.init(); err != nil {
+		return err
+	}
+	_, err := m.Up()
+	return err
+}
+
+// init creates the migrations table, adding checksums to tables created before they were recorded
+func (m *Migrator) init() error {
+	if m.dialect == postgres {
+		return m.initPostgres()
+	}
+	_, err := m.db.Exec("CREATE TABLE IF NOT EXISTS migrations (id INTEGER NOT NULL PRIMARY KEY, name TEXT UNIQUE, checksum TEXT NOT NULL DEFAULT '');")
+	if err != nil {
+		return err
+	}
+	var hasChecksum bool
+	if err := m.db.QueryRow("SELECT COUNT(*) > 0 FROM pragma_table_info('migrations') WHERE name = 'checksum'").Scan(&hasChecksum); err != nil {
+		return err
+	}
+	if !hasChecksum {
+		_, err = m.db.Exec("ALTER TABLE migrations ADD COLUMN checksum TEXT NOT NULL DEFAULT ''")
+	}
+	return err
+}
+
+// initPostgres creates the migrations table once, while other instances sharing the database wait
+func (m *Migrator) initPostgres() error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey("migrations")); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("CREATE TABLE IF NOT EXISTS migrations (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, name TEXT UNIQUE, checksum TEXT NOT NULL DEFAULT '')"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrationFiles returns the names of the embedded migrations, without their down files, in order
+func migrationFiles(d dialect) ([]string, error) {
+	migrationDirEntries, err := migrations.ReadDir(d.migrations())
+	if err != nil {
+		return nil, err
+	}
+
+	migrationFiles := []string{}
+	for _, migrationDirEntry := range migrationDirEntries {
+		if migrationDirEntry.IsDir() || strings.HasSuffix(migrationDirEntry.Name(), downSuffix) {
+			continue
+		}
+		migrationFiles = append(migrationFiles, migrationDirEntry.Name())
+	}
+
+	sort.Slice(migrationFiles, func(i, j int) bool {
+		return strings.Compare(migrationFiles[i], migrationFiles[j]) <= 0
+	})
+	return migrationFiles, nil
+}
+
+func readMigration(d dialect, migrationFile string) (string, string, error) {
+	migrationSql, err := migrations.ReadFile(path.Join(d.migrations(), migrationFile))
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256(migrationSql)
+	return string(migrationSql), hex.EncodeToString(sum[:]), nil
+}
+
+// applied returns the checksums of the applied migrations, keyed by name, empty if it wasn't recorded
+func (m *Migrator) applied() (map[string]string, error) {
+	rows, err := m.db.Query("SELECT name, checksum FROM migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]string{}
+	for rows.Next() {
+		var name, checksum string
+		if err := rows.Scan(&name, &checksum); err != nil {
+			return nil, err
+		}
+		applied[name] = checksum
+	}
+	return applied, rows.Err()
+}
+
+// Status lists the embedded migrations in order, followed by unknown applied ones
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	migrationFiles, err := migrationFiles(m.dialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []MigrationStatus{}
+	for _, migrationFile := range migrationFiles {
+		checksum, isApplied := applied[migrationFile]
+		delete(applied, migrationFile)
+		status := MigrationStatus{Name: migrationFile, Applied: isApplied}
+		if isApplied && checksum != "" {
+			_, expected, err := readMigration(m.dialect, migrationFile)
+			if err != nil {
+				return nil, err
+			}
+			status.Changed = checksum != expected
+		}
+		statuses = append(statuses, status)
+	}
+
+	unknown := make([]string, 0, len(applied))
+	for name := range applied {
+		unknown = append(unknown, name)
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		statuses = append(statuses, MigrationStatus{Name: name, Applied: true, Unknown: true})
+	}
+	return statuses, nil
+}
+
+// Up applies the pending migrations and returns their names.
+// It fails without applying anything if an applied migration was changed since,
+// migrations applied before checksums were recorded are trusted as they are.
+func (m *Migrator) Up() ([]string, error) {
+	migrationFiles, err := migrationFiles(m.dialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []string{}
+	for _, migrationFile := range migrationFiles {
+		checksum, isApplied := applied[migrationFile]
+		if !isApplied {
+			pending = append(pending, migrationFile)
+			continue
+		}
+		_, expected, err := readMigration(m.dialect, migrationFile)
+		if err != nil {
+			return nil, err
+		}
+		if checksum == "" {
+			if _, err := m.db.Exec("UPDATE migrations SET checksum = ? WHERE name = ?", expected, migrationFile); err != nil {
+				return nil, err
+			}
+		} else if checksum != expected {
+			return nil, fmt.Errorf("migration %v was changed after it was applied", migrationFile)
+		}
+	}
+
+	for _, migrationFile := range pending {
+		migrationSql, checksum, err := readMigration(m.dialect, migrationFile)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("[migration] RUN %v", migrationFile)
+		err = m.transaction(migrationFile, true, migrationSql, "INSERT INTO migrations(name, checksum) VALUES (?, ?)", migrationFile, checksum)
+		if err != nil {
+			return nil, fmt.Errorf("migration %v failed: %w", migrationFile, err)
+		}
+		log.Printf("[migration] FIN %v", migrationFile)
+	}
+	return pending, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns their names
+func (m *Migrator) Down(steps int) ([]string, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := []string{}
+	for i := len(statuses) - 1; i >= 0 && len(reverted) < steps; i-- {
+		status := statuses[i]
+		if !status.Applied {
+			continue
+		}
+		if status.Unknown {
+			return reverted, fmt.Errorf("migration %v is unknown to this build, revert it with the build that applied it", status.Name)
+		}
+		downFile := strings.TrimSuffix(status.Name, ".sql") + downSuffix
+		downSql, err := migrations.ReadFile(path.Join(m.dialect.migrations(), downFile))
+		if err != nil {
+			return reverted, fmt.Errorf("migration %v can't be reverted: %w", status.Name, err)
+		}
+
+		log.Printf("[migration] REVERT %v", status.Name)
+		if err := m.transaction(status.Name, false, string(downSql), "DELETE FROM migrations WHERE name = ?", status.Name); err != nil {
+			return reverted, fmt.Errorf("reverting migration %v failed: %w", status.Name, err)
+		}
+		log.Printf("[migration] REVERTED %v", status.Name)
+		reverted = append(reverted, status.Name)
+	}
+	return reverted, nil
+}
+
+// transaction runs migration SQL and records it with a statement, or does neither.
+// On Postgres, instances sharing the database migrate one at a time, skipping what another one applied (up) or reverted meanwhile.
+func (m *Migrator) transaction(name string, up bool, migrationSql, record string, args ...interface{}) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if m.dialect == postgres {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey("migrations")); err != nil {
+			return err
+		}
+		var applied bool
+		if err := tx.QueryRow("SELECT COUNT(*) > 0 FROM migrations WHERE name = ?", name).Scan(&applied); err != nil {
+			return err
+		}
+		if applied == up {
+			return nil
+		}
+	}
+
+	if _, err := tx.Exec(migrationSql); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateDownAndUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dul.db")
+	st, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	if err := st.AddMember("g", "1", Member{User: User{Username: "alice"}, Roles: []string{"5"}}); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	m, err := OpenMigrator(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.Changed || status.Unknown {
+			t.Errorf("unexpected status %+v", status)
+		}
+	}
+
+	reverted, err := m.Down(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != 1 || reverted[0] != statuses[len(statuses)-1].Name {
+		t.Errorf("expected the newest migration to be reverted, got %v", reverted)
+	}
+	// every down file reverts its migration, so the whole schema can be rebuilt
+	if reverted, err = m.Down(len(statuses)); err != nil || len(reverted) != len(statuses)-1 {
+		t.Fatalf("failed to revert every migration, reverted %v: %v", reverted, err)
+	}
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(statuses) {
+		t.Errorf("expected every migration to be applied again, got %v", applied)
+	}
+}
+
+func TestMigrateVerifiesChecksums(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dul.db")
+	m, err := OpenMigrator(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	// migrations applied before checksums were recorded are trusted
+	if _, err := m.db.Exec("UPDATE migrations SET checksum = '' WHERE name = '1690000002_milestones.sql'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Errorf("expected a missing checksum to be filled in, got %v", err)
+	}
+
+	if _, err := m.db.Exec("UPDATE migrations SET checksum = 'changed' WHERE name = '1690000002_milestones.sql'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err == nil {
+		t.Errorf("expected a changed migration to fail")
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.Changed != (status.Name == "1690000002_milestones.sql") {
+			t.Errorf("unexpected status %+v", status)
+		}
+	}
+	if _, err := Open(path); err == nil {
+		t.Errorf("expected opening a store with a changed migration to fail")
+	}
+}
+
+func TestMigrateAddsChecksumColumn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dul.db")
+	m, err := OpenMigrator(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the migrations table before checksums were recorded
+	if _, err := m.db.Exec("DROP TABLE migrations; CREATE TABLE migrations (id INTEGER NOT NULL PRIMARY KEY, name TEXT UNIQUE); INSERT INTO migrations(name) VALUES ('1675390465_members.sql'); CREATE TABLE members (id INTEGER NOT NULL PRIMARY KEY, discord_id VARCHAR(20) NOT NULL UNIQUE);"); err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	st, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to migrate a store without checksums: %v", err)
+	}
+	st.Close()
+}
@@ -0,0 +1,2 @@
+ALTER TABLE members DROP COLUMN discord_username;
+ALTER TABLE members DROP COLUMN discord_discriminator;
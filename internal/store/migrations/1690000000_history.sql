@@ -0,0 +1,3 @@
+CREATE TABLE IF NOT EXISTS history (id INTEGER NOT NULL PRIMARY KEY, discord_id VARCHAR(20) NOT NULL, event VARCHAR(16) NOT NULL, discord_username VARCHAR(64) NOT NULL DEFAULT '', discord_discriminator VARCHAR(16) NOT NULL DEFAULT '', created_at INTEGER NOT NULL);
+CREATE INDEX IF NOT EXISTS history_created_at ON history (created_at);
+CREATE INDEX IF NOT EXISTS history_discord_id ON history (discord_id);
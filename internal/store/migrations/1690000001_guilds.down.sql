@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS history_guild_id_created_at;
+ALTER TABLE history DROP COLUMN guild_id;
+CREATE TABLE members_legacy (id INTEGER NOT NULL PRIMARY KEY, discord_id VARCHAR(20) NOT NULL UNIQUE, discord_username VARCHAR(64) NOT NULL DEFAULT '', discord_discriminator VARCHAR(16) NOT NULL DEFAULT '');
+INSERT OR IGNORE INTO members_legacy (id, discord_id, discord_username, discord_discriminator) SELECT id, discord_id, discord_username, discord_discriminator FROM members ORDER BY id;
+DROP TABLE members;
+ALTER TABLE members_legacy RENAME TO members;
@@ -0,0 +1,6 @@
+CREATE TABLE members_guilds (id INTEGER NOT NULL PRIMARY KEY, guild_id VARCHAR(20) NOT NULL DEFAULT '', discord_id VARCHAR(20) NOT NULL, discord_username VARCHAR(64) NOT NULL DEFAULT '', discord_discriminator VARCHAR(16) NOT NULL DEFAULT '', UNIQUE (guild_id, discord_id));
+INSERT INTO members_guilds (id, discord_id, discord_username, discord_discriminator) SELECT id, discord_id, discord_username, discord_discriminator FROM members;
+DROP TABLE members;
+ALTER TABLE members_guilds RENAME TO members;
+ALTER TABLE history ADD COLUMN guild_id VARCHAR(20) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS history_guild_id_created_at ON history (guild_id, created_at);
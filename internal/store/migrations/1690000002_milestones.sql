@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS milestones (id INTEGER NOT NULL PRIMARY KEY, guild_id VARCHAR(20) NOT NULL, member_count INTEGER NOT NULL, created_at INTEGER NOT NULL, UNIQUE (guild_id, member_count));
@@ -0,0 +1,2 @@
+ALTER TABLE members DROP COLUMN joined_at;
+ALTER TABLE members DROP COLUMN premium_since;
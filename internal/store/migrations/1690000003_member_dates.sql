@@ -0,0 +1,2 @@
+ALTER TABLE members ADD COLUMN joined_at INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE members ADD COLUMN premium_since INTEGER NOT NULL DEFAULT 0;
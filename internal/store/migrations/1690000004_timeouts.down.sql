@@ -0,0 +1,2 @@
+ALTER TABLE members DROP COLUMN timeout_until;
+ALTER TABLE history DROP COLUMN details;
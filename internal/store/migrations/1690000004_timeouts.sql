@@ -0,0 +1,2 @@
+ALTER TABLE members ADD COLUMN timeout_until INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE history ADD COLUMN details TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE members ADD COLUMN pending INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE members ADD COLUMN avatar TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS name_history;
+ALTER TABLE members DROP COLUMN nick;
@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS name_history (id INTEGER NOT NULL PRIMARY KEY, guild_id VARCHAR(20) NOT NULL, discord_id VARCHAR(20) NOT NULL, kind VARCHAR(16) NOT NULL, name VARCHAR(64) NOT NULL, first_seen INTEGER NOT NULL, last_seen INTEGER NOT NULL, UNIQUE (guild_id, discord_id, kind, name));
+ALTER TABLE members ADD COLUMN nick VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS guild_settings (guild_id VARCHAR(20) NOT NULL, key VARCHAR(64) NOT NULL, value TEXT NOT NULL, updated_at INTEGER NOT NULL, PRIMARY KEY (guild_id, key));
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS member_snapshots (guild_id VARCHAR(20) NOT NULL, day INTEGER NOT NULL, member_count INTEGER NOT NULL, PRIMARY KEY (guild_id, day));
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS watched_users (guild_id VARCHAR(20) NOT NULL, discord_id VARCHAR(20) NOT NULL, added_by VARCHAR(20) NOT NULL, created_at INTEGER NOT NULL, PRIMARY KEY (guild_id, discord_id));
@@ -0,0 +1 @@
+ALTER TABLE members ADD COLUMN roles TEXT NOT NULL DEFAULT '';
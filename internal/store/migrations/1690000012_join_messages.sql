@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS join_messages (guild_id VARCHAR(20) NOT NULL, discord_id VARCHAR(20) NOT NULL, channel_id VARCHAR(20) NOT NULL, message_id VARCHAR(20) NOT NULL, created_at INTEGER NOT NULL, PRIMARY KEY (guild_id, discord_id));
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS anniversaries (id INTEGER NOT NULL PRIMARY KEY, guild_id VARCHAR(20) NOT NULL, discord_id VARCHAR(20) NOT NULL, years INTEGER NOT NULL, created_at INTEGER NOT NULL, UNIQUE (guild_id, discord_id, years));
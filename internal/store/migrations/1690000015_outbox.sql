@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS outbox (id INTEGER PRIMARY KEY AUTOINCREMENT, guild_id TEXT NOT NULL, discord_id TEXT NOT NULL, alert INTEGER NOT NULL, event TEXT NOT NULL, created_at INTEGER NOT NULL);
+CREATE INDEX IF NOT EXISTS outbox_guild_id ON outbox (guild_id);
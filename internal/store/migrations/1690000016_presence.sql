@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS presence (guild_id TEXT NOT NULL, discord_id TEXT NOT NULL, online INTEGER NOT NULL, online_since INTEGER NOT NULL, last_seen INTEGER NOT NULL, PRIMARY KEY (guild_id, discord_id));
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS last_messages (guild_id TEXT NOT NULL, discord_id TEXT NOT NULL, posted_at INTEGER NOT NULL, PRIMARY KEY (guild_id, discord_id));
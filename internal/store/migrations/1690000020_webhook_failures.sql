@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS webhook_failures (id INTEGER PRIMARY KEY AUTOINCREMENT, url TEXT NOT NULL, guild_id TEXT NOT NULL, discord_id TEXT NOT NULL, event TEXT NOT NULL, payload TEXT NOT NULL, attempts INTEGER NOT NULL, error TEXT NOT NULL, failed_at INTEGER NOT NULL);
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS leave_reasons (guild_id TEXT NOT NULL, discord_id TEXT NOT NULL, left_at INTEGER NOT NULL, reason TEXT NOT NULL, answered_by TEXT NOT NULL, answered_at INTEGER NOT NULL, PRIMARY KEY (guild_id, discord_id, left_at));
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS event_rsvps;
+DROP TABLE IF EXISTS scheduled_events;
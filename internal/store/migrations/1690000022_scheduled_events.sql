@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS scheduled_events (guild_id TEXT NOT NULL, event_id TEXT NOT NULL, name TEXT NOT NULL, starts_at INTEGER NOT NULL, PRIMARY KEY (guild_id, event_id));
+CREATE TABLE IF NOT EXISTS event_rsvps (guild_id TEXT NOT NULL, event_id TEXT NOT NULL, discord_id TEXT NOT NULL, rsvp_at INTEGER NOT NULL, withdrawn_at INTEGER NOT NULL, PRIMARY KEY (guild_id, event_id, discord_id));
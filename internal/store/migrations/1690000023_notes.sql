@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS notes (id INTEGER NOT NULL PRIMARY KEY, guild_id TEXT NOT NULL, discord_id TEXT NOT NULL, text TEXT NOT NULL, author_id TEXT NOT NULL, at INTEGER NOT NULL);
+CREATE INDEX IF NOT EXISTS notes_guild_id_discord_id ON notes (guild_id, discord_id, at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS history_guild_id_discord_id;
+DROP INDEX IF EXISTS history_guild_id_event;
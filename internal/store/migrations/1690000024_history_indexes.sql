@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS history_guild_id_discord_id ON history (guild_id, discord_id, created_at);
+CREATE INDEX IF NOT EXISTS history_guild_id_event ON history (guild_id, event, created_at);
@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS member_lists (guild_id VARCHAR(20) NOT NULL, day INTEGER NOT NULL, discord_id VARCHAR(20) NOT NULL, PRIMARY KEY (guild_id, day, discord_id));
+CREATE INDEX IF NOT EXISTS member_lists_discord_id ON member_lists (discord_id);
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS tenants (name TEXT NOT NULL PRIMARY KEY, token TEXT NOT NULL, owner_id TEXT NOT NULL, guilds TEXT NOT NULL, created_at INTEGER NOT NULL);
@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS member_lists;
+DROP TABLE IF EXISTS notes;
+DROP TABLE IF EXISTS event_rsvps;
+DROP TABLE IF EXISTS scheduled_events;
+DROP TABLE IF EXISTS leave_reasons;
+DROP TABLE IF EXISTS webhook_failures;
+DROP TABLE IF EXISTS secrets;
+DROP TABLE IF EXISTS last_messages;
+DROP TABLE IF EXISTS first_messages;
+DROP TABLE IF EXISTS presence;
+DROP TABLE IF EXISTS outbox;
+DROP TABLE IF EXISTS leases;
+DROP TABLE IF EXISTS anniversaries;
+DROP TABLE IF EXISTS join_messages;
+DROP TABLE IF EXISTS watched_users;
+DROP TABLE IF EXISTS member_snapshots;
+DROP TABLE IF EXISTS guild_settings;
+DROP TABLE IF EXISTS name_history;
+DROP TABLE IF EXISTS milestones;
+DROP TABLE IF EXISTS history;
+DROP TABLE IF EXISTS members;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS members (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, guild_id VARCHAR(20) NOT NULL DEFAULT '', discord_id VARCHAR(20) NOT NULL, discord_username VARCHAR(64) NOT NULL DEFAULT '', discord_discriminator VARCHAR(16) NOT NULL DEFAULT '', joined_at BIGINT NOT NULL DEFAULT 0, premium_since BIGINT NOT NULL DEFAULT 0, timeout_until BIGINT NOT NULL DEFAULT 0, pending INTEGER NOT NULL DEFAULT 0, avatar TEXT NOT NULL DEFAULT '', nick VARCHAR(64) NOT NULL DEFAULT '', roles TEXT NOT NULL DEFAULT '', UNIQUE (guild_id, discord_id));
+CREATE TABLE IF NOT EXISTS history (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, discord_id VARCHAR(20) NOT NULL, event VARCHAR(16) NOT NULL, discord_username VARCHAR(64) NOT NULL DEFAULT '', discord_discriminator VARCHAR(16) NOT NULL DEFAULT '', created_at BIGINT NOT NULL, guild_id VARCHAR(20) NOT NULL DEFAULT '', details TEXT NOT NULL DEFAULT '');
+CREATE INDEX IF NOT EXISTS history_created_at ON history (created_at);
+CREATE INDEX IF NOT EXISTS history_discord_id ON history (discord_id);
+CREATE INDEX IF NOT EXISTS history_guild_id_created_at ON history (guild_id, created_at);
+CREATE INDEX IF NOT EXISTS history_guild_id_discord_id ON history (guild_id, discord_id, created_at);
+CREATE INDEX IF NOT EXISTS history_guild_id_event ON history (guild_id, event, created_at);
+CREATE TABLE IF NOT EXISTS milestones (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, guild_id VARCHAR(20) NOT NULL, member_count BIGINT NOT NULL, created_at BIGINT NOT NULL, UNIQUE (guild_id, member_count));
+CREATE TABLE IF NOT EXISTS name_history (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, guild_id VARCHAR(20) NOT NULL, discord_id VARCHAR(20) NOT NULL, kind VARCHAR(16) NOT NULL, name VARCHAR(64) NOT NULL, first_seen BIGINT NOT NULL, last_seen BIGINT NOT NULL, UNIQUE (guild_id, discord_id, kind, name));
+CREATE TABLE IF NOT EXISTS guild_settings (guild_id VARCHAR(20) NOT NULL, key VARCHAR(64) NOT NULL, value TEXT NOT NULL, updated_at BIGINT NOT NULL, PRIMARY KEY (guild_id, key));
+CREATE TABLE IF NOT EXISTS member_snapshots (guild_id VARCHAR(20) NOT NULL, day BIGINT NOT NULL, member_count BIGINT NOT NULL, PRIMARY KEY (guild_id, day));
+CREATE TABLE IF NOT EXISTS watched_users (guild_id VARCHAR(20) NOT NULL, discord_id VARCHAR(20) NOT NULL, added_by VARCHAR(20) NOT NULL, created_at BIGINT NOT NULL, PRIMARY KEY (guild_id, discord_id));
+CREATE TABLE IF NOT EXISTS join_messages (guild_id VARCHAR(20) NOT NULL, discord_id VARCHAR(20) NOT NULL, channel_id VARCHAR(20) NOT NULL, message_id VARCHAR(20) NOT NULL, created_at BIGINT NOT NULL, PRIMARY KEY (guild_id, discord_id));
+CREATE TABLE IF NOT EXISTS anniversaries (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, guild_id VARCHAR(20) NOT NULL, discord_id VARCHAR(20) NOT NULL, years BIGINT NOT NULL, created_at BIGINT NOT NULL, UNIQUE (guild_id, discord_id, years));
+CREATE TABLE IF NOT EXISTS leases (name TEXT NOT NULL PRIMARY KEY, holder TEXT NOT NULL, expires_at BIGINT NOT NULL);
+CREATE TABLE IF NOT EXISTS outbox (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, guild_id TEXT NOT NULL, discord_id TEXT NOT NULL, alert INTEGER NOT NULL, event TEXT NOT NULL, created_at BIGINT NOT NULL);
+CREATE INDEX IF NOT EXISTS outbox_guild_id ON outbox (guild_id);
+CREATE TABLE IF NOT EXISTS presence (guild_id TEXT NOT NULL, discord_id TEXT NOT NULL, online INTEGER NOT NULL, online_since BIGINT NOT NULL, last_seen BIGINT NOT NULL, PRIMARY KEY (guild_id, discord_id));
+CREATE TABLE IF NOT EXISTS first_messages (guild_id TEXT NOT NULL, discord_id TEXT NOT NULL, joined_at BIGINT NOT NULL, channel_id TEXT NOT NULL, posted_at BIGINT NOT NULL, PRIMARY KEY (guild_id, discord_id));
+CREATE TABLE IF NOT EXISTS last_messages (guild_id TEXT NOT NULL, discord_id TEXT NOT NULL, posted_at BIGINT NOT NULL, PRIMARY KEY (guild_id, discord_id));
+CREATE TABLE IF NOT EXISTS secrets (name TEXT NOT NULL PRIMARY KEY, value TEXT NOT NULL);
+CREATE TABLE IF NOT EXISTS webhook_failures (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, url TEXT NOT NULL, guild_id TEXT NOT NULL, discord_id TEXT NOT NULL, event TEXT NOT NULL, payload TEXT NOT NULL, attempts BIGINT NOT NULL, error TEXT NOT NULL, failed_at BIGINT NOT NULL);
+CREATE TABLE IF NOT EXISTS leave_reasons (guild_id TEXT NOT NULL, discord_id TEXT NOT NULL, left_at BIGINT NOT NULL, reason TEXT NOT NULL, answered_by TEXT NOT NULL, answered_at BIGINT NOT NULL, PRIMARY KEY (guild_id, discord_id, left_at));
+CREATE TABLE IF NOT EXISTS scheduled_events (guild_id TEXT NOT NULL, event_id TEXT NOT NULL, name TEXT NOT NULL, starts_at BIGINT NOT NULL, PRIMARY KEY (guild_id, event_id));
+CREATE TABLE IF NOT EXISTS event_rsvps (guild_id TEXT NOT NULL, event_id TEXT NOT NULL, discord_id TEXT NOT NULL, rsvp_at BIGINT NOT NULL, withdrawn_at BIGINT NOT NULL, PRIMARY KEY (guild_id, event_id, discord_id));
+CREATE TABLE IF NOT EXISTS notes (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, guild_id TEXT NOT NULL, discord_id TEXT NOT NULL, text TEXT NOT NULL, author_id TEXT NOT NULL, at BIGINT NOT NULL);
+CREATE INDEX IF NOT EXISTS notes_guild_id_discord_id ON notes (guild_id, discord_id, at);
+CREATE TABLE IF NOT EXISTS member_lists (guild_id VARCHAR(20) NOT NULL, day BIGINT NOT NULL, discord_id VARCHAR(20) NOT NULL, PRIMARY KEY (guild_id, day, discord_id));
+CREATE INDEX IF NOT EXISTS member_lists_discord_id ON member_lists (discord_id);
@@ -0,0 +1,1580 @@
+// Package store persists known guild members and their join/leave history in SQLite, or in Postgres to share it between hosts.
+// Queries are written to run on both, the Postgres driver only rewrites their placeholders.
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// History event types
+const (
+	EventJoin       = "join"
+	EventLeave      = "leave"
+	EventBoostStart = "boost_start"
+	EventBoostStop  = "boost_stop"
+	EventTimeout    = "timeout"
+	EventTimeoutEnd = "timeout_end"
+	// EventScreeningComplete is when a pending member completes membership screening
+	EventScreeningComplete = "screening_complete"
+	EventAvatarChange      = "avatar_change"
+	EventRename            = "rename"
+	EventBan               = "ban"
+	EventUnban             = "unban"
+	// Role events are only recorded for live member updates, not for changes found by syncs
+	EventRoleAdd    = "role_add"
+	EventRoleRemove = "role_remove"
+	// Voice events are only recorded if voice logging is enabled
+	EventVoiceJoin  = "voice_join"
+	EventVoiceLeave = "voice_leave"
+	EventVoiceMove  = "voice_move"
+)
+
+// User is the stored identity of a Discord user
+type User struct {
+	Username      string
+	Discriminator string
+}
+
+// Tag is "username#discriminator", just "username" for the new numberless format, or empty if unknown
+func (u User) Tag() string {
+	if u.Username == "" && u.Discriminator == "" {
+		return ""
+	}
+	if u.Discriminator == "0" {
+		// discriminator of "0" == new discord username format, numberless
+		return u.Username
+	}
+	return u.Username + "#" + u.Discriminator
+}
+
+// Member is the stored state of a guild member
+type Member struct {
+	User
+	// JoinedAt is when Discord says the member joined, zero if unknown
+	JoinedAt time.Time
+	// PremiumSince is when the member started boosting, zero if they aren't
+	PremiumSince time.Time
+	// TimeoutUntil is when the member's timeout ends, zero if they were never timed out
+	TimeoutUntil time.Time
+	// Pending is set until the member completes membership screening
+	Pending bool
+	// Avatar is the hash of the user's avatar, empty if they have none
+	Avatar string
+	// Nick is the member's server nickname, empty if they have none
+	Nick string
+	// Roles are the member's role IDs, sorted
+	Roles []string
+}
+
+// Same reports whether two members have the same stored state
+func (m Member) Same(other Member) bool {
+	return m.User == other.User &&
+		m.JoinedAt.Equal(other.JoinedAt) &&
+		m.PremiumSince.Equal(other.PremiumSince) &&
+		m.TimeoutUntil.Equal(other.TimeoutUntil) &&
+		m.Pending == other.Pending &&
+		m.Avatar == other.Avatar &&
+		m.Nick == other.Nick &&
+		strings.Join(m.Roles, ",") == strings.Join(other.Roles, ",")
+}
+
+// HasAnyRole reports whether the member has at least one of the roles
+func (m Member) HasAnyRole(roleIDs []string) bool {
+	for _, roleID := range roleIDs {
+		for _, memberRoleID := range m.Roles {
+			if memberRoleID == roleID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// joinRoles stores role IDs as a comma-separated list
+func joinRoles(roles []string) string {
+	return strings.Join(roles, ",")
+}
+
+// splitRoles reads a comma-separated list of role IDs, an empty list is nil
+func splitRoles(roles string) []string {
+	if roles == "" {
+		return nil
+	}
+	return strings.Split(roles, ",")
+}
+
+// TimedOut reports whether the member is timed out at a time
+func (m Member) TimedOut(at time.Time) bool {
+	return m.TimeoutUntil.After(at)
+}
+
+// unixOrZero converts a time to unix seconds, with zero times stored as 0
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// timeOrZero converts unix seconds to a time, with 0 read as the zero time
+func timeOrZero(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
+
+// Store is a migrated SQLite or Postgres database, or a transaction in it started by Begin
+type Store struct {
+	db      querier
+	dialect dialect
+	// locks are the leases held as Postgres advisory locks, nil for SQLite
+	locks *advisoryLocks
+	// conn is the database the transaction runs in, tx is nil outside of transactions
+	conn                                         *sql.DB
+	tx                                           *sql.Tx
+	stmtAdd, stmtUpdate, stmtRemove, stmtHistory *sql.Stmt
+	cache                                        *historyCache
+	// changed are the guilds whose history a transaction changed, their cached results are dropped on Commit
+	changed map[string]bool
+}
+
+// querier is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// the queries prepared when opening the database, the hot path of syncs
+const (
+	queryAddMember    = "INSERT INTO members(guild_id, discord_id, discord_username, discord_discriminator, joined_at, premium_since, timeout_until, pending, avatar, nick, roles) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	queryUpdateMember = "UPDATE members SET discord_username = ?, discord_discriminator = ?, joined_at = ?, premium_since = ?, timeout_until = ?, pending = ?, avatar = ?, nick = ?, roles = ? WHERE guild_id = ? AND discord_id = ?"
+	queryRemoveMember = "DELETE FROM members WHERE guild_id = ? AND discord_id = ?"
+	queryRecordEvent  = "INSERT INTO history(guild_id, discord_id, event, discord_username, discord_discriminator, created_at, details) VALUES (?, ?, ?, ?, ?, ?, ?)"
+)
+
+// Open opens (or creates) the SQLite database at path and runs pending migrations.
+// A postgres:// URL opens that Postgres database instead.
+func Open(path string) (*Store, error) {
+	return OpenEncrypted(path, "")
+}
+
+// OpenEncrypted opens (or creates) the SQLCipher database at path encrypted with key, like Open.
+// An empty key opens an unencrypted database.
+func OpenEncrypted(path, key string) (*Store, error) {
+	if IsPostgres(path) {
+		if key != "" {
+			return nil, ErrSQLiteOnly
+		}
+		return openPostgres(path)
+	}
+	// transactions lock the database for writing, other connections wait for them instead of failing
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	db, err := openDB(path+separator+"_busy_timeout=5000", key)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStore(db, sqlite, nil)
+}
+
+// newStore migrates a database and prepares the queries of syncs
+func newStore(db *sql.DB, d dialect, locks *advisoryLocks) (*Store, error) {
+	if err := migrate(db, d); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &Store{db: tracedQuerier{db, d}, dialect: d, locks: locks, conn: db, cache: newHistoryCache()}
+	for _, prepare := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.stmtAdd, queryAddMember},
+		{&s.stmtUpdate, queryUpdateMember},
+		{&s.stmtRemove, queryRemoveMember},
+		{&s.stmtHistory, queryRecordEvent},
+	} {
+		var err error
+		if *prepare.stmt, err = db.Prepare(prepare.query); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Close closes the underlying database, releasing the leases held
+func (s *Store) Close() error {
+	if s.locks != nil {
+		s.locks.close()
+	}
+	return s.conn.Close()
+}
+
+// Begin starts a transaction. The returned store makes its changes in it until Commit or Rollback.
+func (s *Store) Begin() (*Store, error) {
+	if s.tx != nil {
+		return nil, errors.New("already in a transaction")
+	}
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Store{
+		db:          tracedQuerier{tx, s.dialect},
+		dialect:     s.dialect,
+		locks:       s.locks,
+		conn:        s.conn,
+		tx:          tx,
+		stmtAdd:     tx.Stmt(s.stmtAdd),
+		stmtUpdate:  tx.Stmt(s.stmtUpdate),
+		stmtRemove:  tx.Stmt(s.stmtRemove),
+		stmtHistory: tx.Stmt(s.stmtHistory),
+		cache:       s.cache,
+		changed:     map[string]bool{},
+	}, nil
+}
+
+// Commit commits a transaction started by Begin
+func (s *Store) Commit() (err error) {
+	if s.tx == nil {
+		return errors.New("not in a transaction")
+	}
+	defer observeQuery(s.dialect, "COMMIT", time.Now(), &err)
+	if err := s.tx.Commit(); err != nil {
+		return err
+	}
+	for guildID := range s.changed {
+		s.cache.invalidate(guildID)
+	}
+	return nil
+}
+
+// Rollback discards the changes of a transaction started by Begin
+func (s *Store) Rollback() error {
+	if s.tx == nil {
+		return errors.New("not in a transaction")
+	}
+	return s.tx.Rollback()
+}
+
+// CheckWritable fails if the database can't be written to, without changing it
+func (s *Store) CheckWritable() error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec("CREATE TABLE writable_check (id INTEGER)")
+	return err
+}
+
+// IntegrityCheck checks the whole SQLite database for corruption, returning the problems found
+func (s *Store) IntegrityCheck() ([]string, error) {
+	return s.check("PRAGMA integrity_check")
+}
+
+// QuickCheck checks the SQLite database for corruption like IntegrityCheck, skipping the slow comparison of indexes with their tables
+func (s *Store) QuickCheck() ([]string, error) {
+	return s.check("PRAGMA quick_check")
+}
+
+func (s *Store) check(pragma string) ([]string, error) {
+	if s.dialect != sqlite {
+		return nil, ErrSQLiteOnly
+	}
+	rows, err := s.conn.Query(pragma)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	problems := []string{}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// Optimize updates the query planner statistics where they are stale
+func (s *Store) Optimize() error {
+	query := "PRAGMA optimize"
+	if s.dialect == postgres {
+		query = "ANALYZE"
+	}
+	_, err := s.conn.Exec(query)
+	return err
+}
+
+// FreePages returns how many pages of the SQLite database file are unused, and how many it has
+func (s *Store) FreePages() (int64, int64, error) {
+	if s.dialect != sqlite {
+		return 0, 0, ErrSQLiteOnly
+	}
+	var free, total int64
+	if err := s.conn.QueryRow("PRAGMA freelist_count").Scan(&free); err != nil {
+		return 0, 0, err
+	}
+	if err := s.conn.QueryRow("PRAGMA page_count").Scan(&total); err != nil {
+		return 0, 0, err
+	}
+	return free, total, nil
+}
+
+// Vacuum rebuilds the SQLite database file without its free pages, blocking writes while it runs
+func (s *Store) Vacuum() error {
+	if s.dialect != sqlite {
+		return ErrSQLiteOnly
+	}
+	_, err := s.conn.Exec("VACUUM")
+	return err
+}
+
+// Backup writes a compacted copy of the SQLite database to a new file at path, blocking writes while it runs
+func (s *Store) Backup(path string) error {
+	if s.dialect != sqlite {
+		return ErrSQLiteOnly
+	}
+	_, err := s.conn.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+// Members returns the stored members of a guild, keyed by Discord ID
+func (s *Store) Members(guildID string) (map[string]Member, error) {
+	rows, err := s.db.Query("SELECT discord_id, discord_username, discord_discriminator, joined_at, premium_since, timeout_until, pending, avatar, nick, roles FROM members WHERE guild_id = ?", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := map[string]Member{}
+	var (
+		discordID, roles                     string
+		joinedAt, premiumSince, timeoutUntil int64
+	)
+	for rows.Next() {
+		member := Member{}
+		if err = rows.Scan(&discordID, &member.Username, &member.Discriminator, &joinedAt, &premiumSince, &timeoutUntil, &member.Pending, &member.Avatar, &member.Nick, &roles); err != nil {
+			return nil, err
+		}
+		member.JoinedAt = timeOrZero(joinedAt)
+		member.PremiumSince = timeOrZero(premiumSince)
+		member.TimeoutUntil = timeOrZero(timeoutUntil)
+		member.Roles = splitRoles(roles)
+		members[discordID] = member
+	}
+	return members, rows.Err()
+}
+
+func (s *Store) AddMember(guildID, discordID string, member Member) (err error) {
+	defer observeQuery(s.dialect, queryAddMember, time.Now(), &err)
+	_, err = s.stmtAdd.Exec(guildID, discordID, member.Username, member.Discriminator, unixOrZero(member.JoinedAt), unixOrZero(member.PremiumSince), unixOrZero(member.TimeoutUntil), member.Pending, member.Avatar, member.Nick, joinRoles(member.Roles))
+	return err
+}
+
+func (s *Store) UpdateMember(guildID, discordID string, member Member) (err error) {
+	defer observeQuery(s.dialect, queryUpdateMember, time.Now(), &err)
+	_, err = s.stmtUpdate.Exec(member.Username, member.Discriminator, unixOrZero(member.JoinedAt), unixOrZero(member.PremiumSince), unixOrZero(member.TimeoutUntil), member.Pending, member.Avatar, member.Nick, joinRoles(member.Roles), guildID, discordID)
+	return err
+}
+
+func (s *Store) RemoveMember(guildID, discordID string) (err error) {
+	defer observeQuery(s.dialect, queryRemoveMember, time.Now(), &err)
+	_, err = s.stmtRemove.Exec(guildID, discordID)
+	return err
+}
+
+// RecordEvent appends an event to the history
+func (s *Store) RecordEvent(event HistoryEvent) (err error) {
+	defer observeQuery(s.dialect, queryRecordEvent, time.Now(), &err)
+	_, err = s.stmtHistory.Exec(event.GuildID, event.DiscordID, event.Event, event.User.Username, event.User.Discriminator, event.At.Unix(), event.Details)
+	s.historyChanged(event.GuildID)
+	return err
+}
+
+// historyChanged drops the cached history query results of a guild, at the end of the transaction if there is one
+func (s *Store) historyChanged(guildID string) {
+	if s.tx != nil {
+		s.changed[guildID] = true
+		return
+	}
+	s.cache.invalidate(guildID)
+}
+
+// cached returns the cached result of a history query, or runs load and caches its result.
+// Transactions don't use the cache, they may see their own changes.
+func (s *Store) cached(guildID string, key cacheKey, load func() (interface{}, error)) (interface{}, error) {
+	if s.tx != nil {
+		return load()
+	}
+	now := time.Now()
+	value, generation, ok := s.cache.get(key, now)
+	if ok {
+		return value, nil
+	}
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+	s.cache.put(key, generation, value, now)
+	return value, nil
+}
+
+// HistoryEvent is a recorded history row
+type HistoryEvent struct {
+	GuildID   string
+	DiscordID string
+	Event     string
+	User      User
+	At        time.Time
+	// Details is extra JSON for some event types, or empty
+	Details string
+}
+
+// RecentEvents returns the newest history events of the given types, skipping offset events
+func (s *Store) RecentEvents(guildID string, events []string, limit, offset int) ([]HistoryEvent, error) {
+	history, err := s.cached(guildID, s.cache.key(guildID, "RecentEvents", events, limit, offset), func() (interface{}, error) {
+		return s.recentEvents(guildID, events, limit, offset)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// callers may change the events they get
+	return append([]HistoryEvent{}, history.([]HistoryEvent)...), nil
+}
+
+func (s *Store) recentEvents(guildID string, events []string, limit, offset int) ([]HistoryEvent, error) {
+	query := "SELECT discord_id, event, discord_username, discord_discriminator, created_at, details FROM history WHERE guild_id = ? AND event IN (?" + strings.Repeat(", ?", len(events)-1) + ") ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+	args := []interface{}{guildID}
+	for _, event := range events {
+		args = append(args, event)
+	}
+	args = append(args, limit, offset)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []HistoryEvent{}
+	for rows.Next() {
+		event := HistoryEvent{GuildID: guildID}
+		var createdAt int64
+		if err := rows.Scan(&event.DiscordID, &event.Event, &event.User.Username, &event.User.Discriminator, &createdAt, &event.Details); err != nil {
+			return nil, err
+		}
+		event.At = time.Unix(createdAt, 0)
+		history = append(history, event)
+	}
+	return history, rows.Err()
+}
+
+// UserHistory returns every history event of a user in a guild, oldest first
+func (s *Store) UserHistory(guildID, discordID string) ([]HistoryEvent, error) {
+	history, err := s.cached(guildID, s.cache.key(guildID, "UserHistory", discordID), func() (interface{}, error) {
+		return s.userHistory(guildID, discordID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]HistoryEvent{}, history.([]HistoryEvent)...), nil
+}
+
+func (s *Store) userHistory(guildID, discordID string) ([]HistoryEvent, error) {
+	rows, err := s.db.Query("SELECT event, discord_username, discord_discriminator, created_at, details FROM history WHERE guild_id = ? AND discord_id = ? ORDER BY created_at, id", guildID, discordID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []HistoryEvent{}
+	for rows.Next() {
+		event := HistoryEvent{GuildID: guildID, DiscordID: discordID}
+		var createdAt int64
+		if err := rows.Scan(&event.Event, &event.User.Username, &event.User.Discriminator, &createdAt, &event.Details); err != nil {
+			return nil, err
+		}
+		event.At = time.Unix(createdAt, 0)
+		history = append(history, event)
+	}
+	return history, rows.Err()
+}
+
+// LastDeparture returns the newest leave or ban of a user recorded in another guild since a time, preferring bans.
+// It returns false if there is none.
+func (s *Store) LastDeparture(discordID, exceptGuildID string, since time.Time) (HistoryEvent, bool, error) {
+	event := HistoryEvent{DiscordID: discordID}
+	var createdAt int64
+	row := s.db.QueryRow(`SELECT guild_id, event, discord_username, discord_discriminator, created_at, details FROM history
+		WHERE discord_id = ? AND guild_id != ? AND event IN (?, ?) AND created_at >= ?
+		ORDER BY event = ? DESC, created_at DESC, id DESC LIMIT 1`, discordID, exceptGuildID, EventLeave, EventBan, since.Unix(), EventBan)
+	if err := row.Scan(&event.GuildID, &event.Event, &event.User.Username, &event.User.Discriminator, &createdAt, &event.Details); err == sql.ErrNoRows {
+		return event, false, nil
+	} else if err != nil {
+		return event, false, err
+	}
+	event.At = time.Unix(createdAt, 0)
+	return event, true, nil
+}
+
+// CountEvents counts the history events of a type recorded since a time
+func (s *Store) CountEvents(guildID, event string, since time.Time) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM history WHERE guild_id = ? AND event = ? AND created_at >= ?", guildID, event, since.Unix()).Scan(&count)
+	return count, err
+}
+
+// LastJoin returns when a member last joined according to the history, false if no join was recorded
+func (s *Store) LastJoin(guildID, discordID string) (time.Time, bool, error) {
+	var createdAt int64
+	row := s.db.QueryRow("SELECT created_at FROM history WHERE guild_id = ? AND discord_id = ? AND event = ? ORDER BY created_at DESC, id DESC LIMIT 1", guildID, discordID, EventJoin)
+	if err := row.Scan(&createdAt); err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	} else if err != nil {
+		return time.Time{}, false, err
+	}
+	return time.Unix(createdAt, 0), true, nil
+}
+
+// Stay is a recorded join and the first leave after it
+type Stay struct {
+	DiscordID string
+	JoinedAt  time.Time
+	// LeftAt is zero if the member hasn't left since
+	LeftAt time.Time
+}
+
+// Stays returns the stays of the joins recorded since a time, oldest first
+func (s *Store) Stays(guildID string, since time.Time) ([]Stay, error) {
+	rows, err := s.db.Query(
+		"SELECT j.discord_id, j.created_at, (SELECT MIN(l.created_at) FROM history l WHERE l.guild_id = j.guild_id AND l.discord_id = j.discord_id AND l.event = ? AND l.created_at >= j.created_at) FROM history j WHERE j.guild_id = ? AND j.event = ? AND j.created_at >= ? ORDER BY j.created_at, j.id",
+		EventLeave, guildID, EventJoin, since.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stays := []Stay{}
+	for rows.Next() {
+		var (
+			stay     Stay
+			joinedAt int64
+			leftAt   sql.NullInt64
+		)
+		if err := rows.Scan(&stay.DiscordID, &joinedAt, &leftAt); err != nil {
+			return nil, err
+		}
+		stay.JoinedAt = time.Unix(joinedAt, 0)
+		if leftAt.Valid {
+			stay.LeftAt = time.Unix(leftAt.Int64, 0)
+		}
+		stays = append(stays, stay)
+	}
+	return stays, rows.Err()
+}
+
+// DayEvents are the joins and leaves of a UTC day
+type DayEvents struct {
+	Day    time.Time
+	Joins  int
+	Leaves int
+}
+
+// EventsByDay counts the joins and leaves of each UTC day since a time, oldest first.
+// Days without joins or leaves are skipped.
+func (s *Store) EventsByDay(guildID string, since time.Time) ([]DayEvents, error) {
+	rows, err := s.db.Query(
+		"SELECT created_at / 86400 AS day, SUM(CASE WHEN event = ? THEN 1 ELSE 0 END), SUM(CASE WHEN event = ? THEN 1 ELSE 0 END) FROM history WHERE guild_id = ? AND event IN (?, ?) AND created_at >= ? GROUP BY day ORDER BY day",
+		EventJoin, EventLeave, guildID, EventJoin, EventLeave, since.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []DayEvents{}
+	for rows.Next() {
+		var (
+			day    int64
+			events DayEvents
+		)
+		if err := rows.Scan(&day, &events.Joins, &events.Leaves); err != nil {
+			return nil, err
+		}
+		events.Day = time.Unix(day*86400, 0).UTC()
+		days = append(days, events)
+	}
+	return days, rows.Err()
+}
+
+// RecordSnapshot stores the member count of a guild for the UTC day of at, replacing earlier snapshots of that day
+func (s *Store) RecordSnapshot(guildID string, at time.Time, memberCount int) error {
+	_, err := s.db.Exec("INSERT INTO member_snapshots(guild_id, day, member_count) VALUES (?, ?, ?) ON CONFLICT (guild_id, day) DO UPDATE SET member_count = excluded.member_count", guildID, at.Unix()/86400*86400, memberCount)
+	s.historyChanged(guildID)
+	return err
+}
+
+// MemberListDays is how long the daily member lists are kept
+const MemberListDays = 30
+
+// RecordMemberList stores who was a member of a guild on the UTC day of at, unless that day already has a list,
+// and deletes the lists of the guild older than MemberListDays. It returns false if the day already had a list.
+func (s *Store) RecordMemberList(guildID string, at time.Time, discordIDs []string) (bool, error) {
+	day := at.Unix() / 86400 * 86400
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM member_lists WHERE guild_id = ? AND day = ?)", guildID, day).Scan(&exists); err != nil || exists {
+		return false, err
+	}
+	insert, err := tx.Prepare("INSERT INTO member_lists(guild_id, day, discord_id) VALUES (?, ?, ?) ON CONFLICT DO NOTHING")
+	if err != nil {
+		return false, err
+	}
+	defer insert.Close()
+	for _, discordID := range discordIDs {
+		if _, err := insert.Exec(guildID, day, discordID); err != nil {
+			return false, err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM member_lists WHERE guild_id = ? AND day < ?", guildID, day-MemberListDays*86400); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// MemberList returns who was a member of a guild on the newest UTC day with a list on or before the day of at, and that day.
+// It returns false if there is no list that old.
+func (s *Store) MemberList(guildID string, at time.Time) (map[string]bool, time.Time, bool, error) {
+	var day sql.NullInt64
+	if err := s.db.QueryRow("SELECT MAX(day) FROM member_lists WHERE guild_id = ? AND day <= ?", guildID, at.Unix()).Scan(&day); err != nil || !day.Valid {
+		return nil, time.Time{}, false, err
+	}
+	rows, err := s.db.Query("SELECT discord_id FROM member_lists WHERE guild_id = ? AND day = ?", guildID, day.Int64)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	defer rows.Close()
+	members := map[string]bool{}
+	for rows.Next() {
+		var discordID string
+		if err := rows.Scan(&discordID); err != nil {
+			return nil, time.Time{}, false, err
+		}
+		members[discordID] = true
+	}
+	return members, time.Unix(day.Int64, 0).UTC(), true, rows.Err()
+}
+
+// JoinSource is an invite and how many joins used it
+type JoinSource struct {
+	Invite    string
+	InviterID string
+	Joins     int
+}
+
+// JoinSources returns the invites most joins since a time used, most used first.
+// Joins are only recorded with their invite when invite tracking is on.
+func (s *Store) JoinSources(guildID string, since time.Time, limit int) ([]JoinSource, error) {
+	rows, err := s.db.Query(`SELECT invite, MAX(inviter_id), COUNT(*) AS joins FROM (
+		SELECT `+s.dialect.jsonField("details", "invite")+` AS invite, COALESCE(`+s.dialect.jsonField("details", "inviter_id")+`, '') AS inviter_id
+		FROM history WHERE guild_id = ? AND event = ? AND created_at >= ? AND `+s.dialect.isJSON("details")+`
+	) AS joined WHERE invite IS NOT NULL GROUP BY invite ORDER BY joins DESC, invite LIMIT ?`, guildID, EventJoin, since.Unix(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := []JoinSource{}
+	for rows.Next() {
+		var source JoinSource
+		if err := rows.Scan(&source.Invite, &source.InviterID, &source.Joins); err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, rows.Err()
+}
+
+// DayCount is the member count at the end of a UTC day
+type DayCount struct {
+	Day   time.Time
+	Count int
+}
+
+// MemberCountHistory returns the member count of each UTC day from since (a UTC midnight) up to today, oldest first.
+// Days with a snapshot use it, other days are worked out backwards from the next day's count and the history.
+func (s *Store) MemberCountHistory(guildID string, current int, since time.Time, days int) ([]DayCount, error) {
+	counts, err := s.cached(guildID, s.cache.key(guildID, "MemberCountHistory", current, since.Unix(), days), func() (interface{}, error) {
+		return s.memberCountHistory(guildID, current, since, days)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]DayCount{}, counts.([]DayCount)...), nil
+}
+
+func (s *Store) memberCountHistory(guildID string, current int, since time.Time, days int) ([]DayCount, error) {
+	events, err := s.EventsByDay(guildID, since)
+	if err != nil {
+		return nil, err
+	}
+	eventsByDay := make(map[int64]DayEvents, len(events))
+	for _, day := range events {
+		eventsByDay[day.Day.Unix()] = day
+	}
+
+	rows, err := s.db.Query("SELECT day, member_count FROM member_snapshots WHERE guild_id = ? AND day >= ?", guildID, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	snapshots := map[int64]int{}
+	for rows.Next() {
+		var day int64
+		var count int
+		if err := rows.Scan(&day, &count); err != nil {
+			return nil, err
+		}
+		snapshots[day] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	counts := make([]DayCount, days)
+	count := current
+	for i := days - 1; i >= 0; i-- {
+		day := since.AddDate(0, 0, i)
+		// today's count is the current one, a snapshot taken earlier today may be outdated
+		if snapshot, ok := snapshots[day.Unix()]; ok && i != days-1 {
+			count = snapshot
+		}
+		counts[i] = DayCount{Day: day, Count: count}
+		dayEvents := eventsByDay[day.Unix()]
+		count = count - dayEvents.Joins + dayEvents.Leaves
+	}
+	return counts, nil
+}
+
+// RecordMilestone records that a guild reached a member count.
+// It returns false if the milestone was already recorded.
+func (s *Store) RecordMilestone(guildID string, memberCount int, at time.Time) (bool, error) {
+	result, err := s.db.Exec("INSERT INTO milestones(guild_id, member_count, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING", guildID, memberCount, at.Unix())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RecordAnniversary records that a member's join anniversary was celebrated.
+// It returns false if the anniversary was already recorded.
+func (s *Store) RecordAnniversary(guildID, discordID string, years int, at time.Time) (bool, error) {
+	result, err := s.db.Exec("INSERT INTO anniversaries(guild_id, discord_id, years, created_at) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING", guildID, discordID, years, at.Unix())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// AcquireLease takes or renews a named lease for holder until now plus ttl,
+// unless another holder's lease hasn't expired yet. It returns whether holder has the lease.
+// With Postgres, the lease is an advisory lock held by a connection of its own instead, see advisoryLocks.
+func (s *Store) AcquireLease(name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	if s.locks != nil {
+		return s.locks.acquire(name, holder, ttl)
+	}
+	result, err := s.db.Exec(`INSERT INTO leases(name, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE leases.holder = excluded.holder OR leases.expires_at <= ?`, name, holder, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// LeaseHolder returns who holds a lease at a time, false if nobody does
+func (s *Store) LeaseHolder(name string, now time.Time) (string, bool, error) {
+	if s.locks != nil {
+		return s.locks.holder(name)
+	}
+	var holder string
+	err := s.db.QueryRow("SELECT holder FROM leases WHERE name = ? AND expires_at > ?", name, now.UnixMilli()).Scan(&holder)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	return holder, err == nil, err
+}
+
+// ReleaseLease gives up a lease if holder has it
+func (s *Store) ReleaseLease(name, holder string) error {
+	if s.locks != nil {
+		return s.locks.release(name)
+	}
+	_, err := s.db.Exec("DELETE FROM leases WHERE name = ? AND holder = ?", name, holder)
+	return err
+}
+
+// MilestonesSince returns the member counts a guild first reached since a time, in the order they were reached
+func (s *Store) MilestonesSince(guildID string, since time.Time) ([]int, error) {
+	rows, err := s.db.Query("SELECT member_count FROM milestones WHERE guild_id = ? AND created_at >= ? ORDER BY created_at, member_count", guildID, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	milestones := []int{}
+	for rows.Next() {
+		var memberCount int
+		if err := rows.Scan(&memberCount); err != nil {
+			return nil, err
+		}
+		milestones = append(milestones, memberCount)
+	}
+	return milestones, rows.Err()
+}
+
+// Name kinds stored in the name history
+const (
+	NameUsername = "username"
+	NameNick     = "nick"
+)
+
+// Name is a name a user was seen with
+type Name struct {
+	Kind      string
+	Name      string
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// RecordName records that a user was seen with a name, extending its first and last seen times if it is already known
+func (s *Store) RecordName(guildID, discordID, kind, name string, at time.Time) error {
+	_, err := s.db.Exec(
+		"INSERT INTO name_history(guild_id, discord_id, kind, name, first_seen, last_seen) VALUES (?, ?, ?, ?, ?, ?) "+
+			"ON CONFLICT (guild_id, discord_id, kind, name) DO UPDATE SET "+
+			"first_seen = CASE WHEN excluded.first_seen < name_history.first_seen THEN excluded.first_seen ELSE name_history.first_seen END, "+
+			"last_seen = CASE WHEN excluded.last_seen > name_history.last_seen THEN excluded.last_seen ELSE name_history.last_seen END",
+		guildID, discordID, kind, name, at.Unix(), at.Unix(),
+	)
+	return err
+}
+
+// Names returns every name a user was seen with in a guild, oldest first
+func (s *Store) Names(guildID, discordID string) ([]Name, error) {
+	rows, err := s.db.Query("SELECT kind, name, first_seen, last_seen FROM name_history WHERE guild_id = ? AND discord_id = ? ORDER BY first_seen, id", guildID, discordID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []Name{}
+	for rows.Next() {
+		var (
+			name                Name
+			firstSeen, lastSeen int64
+		)
+		if err := rows.Scan(&name.Kind, &name.Name, &firstSeen, &lastSeen); err != nil {
+			return nil, err
+		}
+		name.FirstSeen = time.Unix(firstSeen, 0)
+		name.LastSeen = time.Unix(lastSeen, 0)
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// GuildSettings returns the runtime settings of a guild, keyed by setting name
+func (s *Store) GuildSettings(guildID string) (map[string]string, error) {
+	rows, err := s.db.Query("SELECT key, value FROM guild_settings WHERE guild_id = ?", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		settings[key] = value
+	}
+	return settings, rows.Err()
+}
+
+// SetGuildSetting stores a runtime setting of a guild, replacing any previous value
+func (s *Store) SetGuildSetting(guildID, key, value string, at time.Time) error {
+	_, err := s.db.Exec("INSERT INTO guild_settings(guild_id, key, value, updated_at) VALUES (?, ?, ?, ?) ON CONFLICT (guild_id, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at", guildID, key, value, at.Unix())
+	return err
+}
+
+// DeleteGuildSetting removes a runtime setting of a guild
+func (s *Store) DeleteGuildSetting(guildID, key string) error {
+	_, err := s.db.Exec("DELETE FROM guild_settings WHERE guild_id = ? AND key = ?", guildID, key)
+	return err
+}
+
+// WatchedUser is a user flagged for moderator alerts
+type WatchedUser struct {
+	DiscordID string
+	// AddedBy is the moderator who started watching the user
+	AddedBy string
+	AddedAt time.Time
+}
+
+// WatchUser flags a user for alerts, returning false if they were already watched
+func (s *Store) WatchUser(guildID, discordID, addedBy string, at time.Time) (bool, error) {
+	result, err := s.db.Exec("INSERT INTO watched_users(guild_id, discord_id, added_by, created_at) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING", guildID, discordID, addedBy, at.Unix())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// UnwatchUser stops watching a user, returning false if they weren't watched
+func (s *Store) UnwatchUser(guildID, discordID string) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM watched_users WHERE guild_id = ? AND discord_id = ?", guildID, discordID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// WatchedUsers returns the watched users of a guild, oldest first
+func (s *Store) WatchedUsers(guildID string) ([]WatchedUser, error) {
+	rows, err := s.db.Query("SELECT discord_id, added_by, created_at FROM watched_users WHERE guild_id = ? ORDER BY created_at, discord_id", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	watched := []WatchedUser{}
+	for rows.Next() {
+		var user WatchedUser
+		var createdAt int64
+		if err := rows.Scan(&user.DiscordID, &user.AddedBy, &createdAt); err != nil {
+			return nil, err
+		}
+		user.AddedAt = time.Unix(createdAt, 0)
+		watched = append(watched, user)
+	}
+	return watched, rows.Err()
+}
+
+// JoinMessage is the announcement of a member's join, edited when they leave
+type JoinMessage struct {
+	ChannelID string
+	MessageID string
+	SentAt    time.Time
+}
+
+// SaveJoinMessage remembers the join announcement of a member, replacing the one of an earlier join
+func (s *Store) SaveJoinMessage(guildID, discordID string, message JoinMessage) error {
+	_, err := s.db.Exec(`INSERT INTO join_messages(guild_id, discord_id, channel_id, message_id, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (guild_id, discord_id) DO UPDATE SET channel_id = excluded.channel_id, message_id = excluded.message_id, created_at = excluded.created_at`,
+		guildID, discordID, message.ChannelID, message.MessageID, message.SentAt.Unix())
+	return err
+}
+
+// TakeJoinMessage returns and forgets the join announcement of a member, returning false if there is none
+func (s *Store) TakeJoinMessage(guildID, discordID string) (JoinMessage, bool, error) {
+	var message JoinMessage
+	var createdAt int64
+	row := s.db.QueryRow("SELECT channel_id, message_id, created_at FROM join_messages WHERE guild_id = ? AND discord_id = ?", guildID, discordID)
+	if err := row.Scan(&message.ChannelID, &message.MessageID, &createdAt); err == sql.ErrNoRows {
+		return message, false, nil
+	} else if err != nil {
+		return message, false, err
+	}
+	message.SentAt = time.Unix(createdAt, 0)
+	_, err := s.db.Exec("DELETE FROM join_messages WHERE guild_id = ? AND discord_id = ?", guildID, discordID)
+	return message, err == nil, err
+}
+
+// Presence is when a member was last seen online
+type Presence struct {
+	DiscordID string
+	Online    bool
+	// OnlineSince is when an online member came online, or when the bot connected if they already were then.
+	// It is zero if the member was never seen coming online.
+	OnlineSince time.Time
+	// LastSeen is when the member went offline, or when they were last known to be online if they still are
+	LastSeen time.Time
+}
+
+// RecordPresence records a member coming online or going offline at a time, ignoring repeated updates without a transition
+func (s *Store) RecordPresence(guildID, discordID string, online bool, at time.Time) error {
+	var err error
+	if online {
+		_, err = s.db.Exec(`INSERT INTO presence(guild_id, discord_id, online, online_since, last_seen) VALUES (?, ?, 1, ?, ?)
+			ON CONFLICT(guild_id, discord_id) DO UPDATE SET online = 1, online_since = excluded.online_since, last_seen = excluded.last_seen
+			WHERE presence.online = 0`, guildID, discordID, at.Unix(), at.Unix())
+	} else {
+		_, err = s.db.Exec(`INSERT INTO presence(guild_id, discord_id, online, online_since, last_seen) VALUES (?, ?, 0, 0, ?)
+			ON CONFLICT(guild_id, discord_id) DO UPDATE SET online = 0, last_seen = excluded.last_seen
+			WHERE presence.online = 1`, guildID, discordID, at.Unix())
+	}
+	return err
+}
+
+// LastSeen returns the presence of a member, returning false if they were never seen
+func (s *Store) LastSeen(guildID, discordID string) (Presence, bool, error) {
+	presence := Presence{DiscordID: discordID}
+	var onlineSince, lastSeen int64
+	row := s.db.QueryRow("SELECT online, online_since, last_seen FROM presence WHERE guild_id = ? AND discord_id = ?", guildID, discordID)
+	if err := row.Scan(&presence.Online, &onlineSince, &lastSeen); err == sql.ErrNoRows {
+		return presence, false, nil
+	} else if err != nil {
+		return presence, false, err
+	}
+	presence.OnlineSince = timeOrZero(onlineSince)
+	presence.LastSeen = time.Unix(lastSeen, 0)
+	return presence, true, nil
+}
+
+// Presences returns the presences of every member of a guild seen so far
+func (s *Store) Presences(guildID string) ([]Presence, error) {
+	rows, err := s.db.Query("SELECT discord_id, online, online_since, last_seen FROM presence WHERE guild_id = ? ORDER BY discord_id", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	presences := []Presence{}
+	for rows.Next() {
+		var presence Presence
+		var onlineSince, lastSeen int64
+		if err := rows.Scan(&presence.DiscordID, &presence.Online, &onlineSince, &lastSeen); err != nil {
+			return nil, err
+		}
+		presence.OnlineSince = timeOrZero(onlineSince)
+		presence.LastSeen = time.Unix(lastSeen, 0)
+		presences = append(presences, presence)
+	}
+	return presences, rows.Err()
+}
+
+// FirstMessage is when a new member first posted after joining
+type FirstMessage struct {
+	DiscordID string
+	JoinedAt  time.Time
+	// ChannelID and PostedAt are empty while the member hasn't posted yet
+	ChannelID string
+	PostedAt  time.Time
+}
+
+// Posted returns whether the member posted since joining
+func (f FirstMessage) Posted() bool {
+	return !f.PostedAt.IsZero()
+}
+
+// AwaitFirstMessage starts waiting for the first message of a member who joined, forgetting the one of an earlier join
+func (s *Store) AwaitFirstMessage(guildID, discordID string, joinedAt time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO first_messages(guild_id, discord_id, joined_at, channel_id, posted_at) VALUES (?, ?, ?, '', 0)
+		ON CONFLICT (guild_id, discord_id) DO UPDATE SET joined_at = excluded.joined_at, channel_id = '', posted_at = 0`, guildID, discordID, joinedAt.Unix())
+	return err
+}
+
+// RecordFirstMessage records a message of a member, returning false if they aren't awaited or already posted
+func (s *Store) RecordFirstMessage(guildID, discordID, channelID string, at time.Time) (bool, error) {
+	result, err := s.db.Exec("UPDATE first_messages SET channel_id = ?, posted_at = ? WHERE guild_id = ? AND discord_id = ? AND posted_at = 0", channelID, at.Unix(), guildID, discordID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// FirstMessage returns the first message of a member since their last tracked join, returning false if their join wasn't tracked
+func (s *Store) FirstMessage(guildID, discordID string) (FirstMessage, bool, error) {
+	message := FirstMessage{DiscordID: discordID}
+	var joinedAt, postedAt int64
+	row := s.db.QueryRow("SELECT joined_at, channel_id, posted_at FROM first_messages WHERE guild_id = ? AND discord_id = ?", guildID, discordID)
+	if err := row.Scan(&joinedAt, &message.ChannelID, &postedAt); err == sql.ErrNoRows {
+		return message, false, nil
+	} else if err != nil {
+		return message, false, err
+	}
+	message.JoinedAt = time.Unix(joinedAt, 0)
+	message.PostedAt = timeOrZero(postedAt)
+	return message, true, nil
+}
+
+// FirstMessages returns the first messages of members who joined since a time, posted or not, oldest join first
+func (s *Store) FirstMessages(guildID string, since time.Time) ([]FirstMessage, error) {
+	rows, err := s.db.Query("SELECT discord_id, joined_at, channel_id, posted_at FROM first_messages WHERE guild_id = ? AND joined_at >= ? ORDER BY joined_at, discord_id", guildID, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []FirstMessage{}
+	for rows.Next() {
+		var message FirstMessage
+		var joinedAt, postedAt int64
+		if err := rows.Scan(&message.DiscordID, &joinedAt, &message.ChannelID, &postedAt); err != nil {
+			return nil, err
+		}
+		message.JoinedAt = time.Unix(joinedAt, 0)
+		message.PostedAt = timeOrZero(postedAt)
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+// RecordMessage records that a member posted at a time, keeping the latest
+func (s *Store) RecordMessage(guildID, discordID string, at time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO last_messages(guild_id, discord_id, posted_at) VALUES (?, ?, ?)
+		ON CONFLICT(guild_id, discord_id) DO UPDATE SET posted_at = excluded.posted_at
+		WHERE excluded.posted_at > last_messages.posted_at`, guildID, discordID, at.Unix())
+	return err
+}
+
+// LastActivity returns when each member of a guild last posted, used a voice channel, or was online, whichever is latest.
+// Members online now count as active at now, and members without any recorded activity are left out.
+func (s *Store) LastActivity(guildID string, now time.Time) (map[string]time.Time, error) {
+	rows, err := s.db.Query(`SELECT discord_id, MAX(at) FROM (
+			SELECT discord_id, posted_at AS at FROM last_messages WHERE guild_id = ?
+			UNION ALL SELECT discord_id, created_at FROM history WHERE guild_id = ? AND event IN (?, ?, ?)
+			UNION ALL SELECT discord_id, CASE WHEN online = 1 THEN ? ELSE last_seen END FROM presence WHERE guild_id = ?
+		) AS activity GROUP BY discord_id`,
+		guildID,
+		guildID, EventVoiceJoin, EventVoiceLeave, EventVoiceMove,
+		now.Unix(), guildID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := map[string]time.Time{}
+	for rows.Next() {
+		var discordID string
+		var at int64
+		if err := rows.Scan(&discordID, &at); err != nil {
+			return nil, err
+		}
+		activity[discordID] = time.Unix(at, 0)
+	}
+	return activity, rows.Err()
+}
+
+// Announcement is an encoded announcement in the outbox, waiting to be sent
+type Announcement struct {
+	ID        int64
+	DiscordID string
+	// Alert is sent to the alert channel instead of announced
+	Alert bool
+	Event string
+	At    time.Time
+}
+
+// QueueAnnouncement adds an announcement to the outbox of a guild, returning its ID
+func (s *Store) QueueAnnouncement(guildID string, announcement Announcement) (int64, error) {
+	var id int64
+	err := s.db.QueryRow("INSERT INTO outbox(guild_id, discord_id, alert, event, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id", guildID, announcement.DiscordID, announcement.Alert, announcement.Event, announcement.At.Unix()).Scan(&id)
+	return id, err
+}
+
+// QueuedAnnouncements returns the announcements in the outbox of a guild, oldest first
+func (s *Store) QueuedAnnouncements(guildID string) ([]Announcement, error) {
+	rows, err := s.db.Query("SELECT id, discord_id, alert, event, created_at FROM outbox WHERE guild_id = ? ORDER BY id", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	announcements := []Announcement{}
+	for rows.Next() {
+		var announcement Announcement
+		var createdAt int64
+		if err := rows.Scan(&announcement.ID, &announcement.DiscordID, &announcement.Alert, &announcement.Event, &createdAt); err != nil {
+			return nil, err
+		}
+		announcement.At = time.Unix(createdAt, 0)
+		announcements = append(announcements, announcement)
+	}
+	return announcements, rows.Err()
+}
+
+// DeleteAnnouncements removes sent announcements from the outbox
+func (s *Store) DeleteAnnouncements(ids []int64) error {
+	for _, id := range ids {
+		if _, err := s.db.Exec("DELETE FROM outbox WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WebhookFailure is an event that couldn't be delivered to a webhook, kept to be redelivered
+type WebhookFailure struct {
+	ID        int64
+	URL       string
+	GuildID   string
+	DiscordID string
+	Event     string
+	// Payload is the JSON body that was sent
+	Payload  string
+	Attempts int
+	Error    string
+	At       time.Time
+}
+
+// AddWebhookFailure adds a failed delivery to the dead letters
+func (s *Store) AddWebhookFailure(failure WebhookFailure) error {
+	_, err := s.db.Exec(
+		"INSERT INTO webhook_failures(url, guild_id, discord_id, event, payload, attempts, error, failed_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		failure.URL, failure.GuildID, failure.DiscordID, failure.Event, failure.Payload, failure.Attempts, failure.Error, failure.At.Unix(),
+	)
+	return err
+}
+
+// WebhookFailures returns the failed deliveries, oldest first
+func (s *Store) WebhookFailures() ([]WebhookFailure, error) {
+	rows, err := s.db.Query("SELECT id, url, guild_id, discord_id, event, payload, attempts, error, failed_at FROM webhook_failures ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	failures := []WebhookFailure{}
+	for rows.Next() {
+		var failure WebhookFailure
+		var failedAt int64
+		if err := rows.Scan(&failure.ID, &failure.URL, &failure.GuildID, &failure.DiscordID, &failure.Event, &failure.Payload, &failure.Attempts, &failure.Error, &failedAt); err != nil {
+			return nil, err
+		}
+		failure.At = time.Unix(failedAt, 0)
+		failures = append(failures, failure)
+	}
+	return failures, rows.Err()
+}
+
+// DeleteWebhookFailure removes a failed delivery, once it was redelivered
+func (s *Store) DeleteWebhookFailure(id int64) error {
+	_, err := s.db.Exec("DELETE FROM webhook_failures WHERE id = ?", id)
+	return err
+}
+
+// LeaveReason is why a member left, answered by them in the leave survey or noted by a moderator
+type LeaveReason struct {
+	DiscordID string
+	LeftAt    time.Time
+	Reason    string
+	// AnsweredBy is the Discord ID of who answered, the member themselves or a moderator
+	AnsweredBy string
+	AnsweredAt time.Time
+}
+
+// RecordLeaveReason records why a member left, replacing an earlier answer for the same leave
+func (s *Store) RecordLeaveReason(guildID string, reason LeaveReason) error {
+	_, err := s.db.Exec(
+		"INSERT INTO leave_reasons(guild_id, discord_id, left_at, reason, answered_by, answered_at) VALUES (?, ?, ?, ?, ?, ?) "+
+			"ON CONFLICT (guild_id, discord_id, left_at) DO UPDATE SET reason = excluded.reason, answered_by = excluded.answered_by, answered_at = excluded.answered_at",
+		guildID, reason.DiscordID, reason.LeftAt.Unix(), reason.Reason, reason.AnsweredBy, reason.AnsweredAt.Unix(),
+	)
+	return err
+}
+
+// LeaveReason returns why a member last left, returning false if it's unknown
+func (s *Store) LeaveReason(guildID, discordID string) (LeaveReason, bool, error) {
+	reason := LeaveReason{DiscordID: discordID}
+	var leftAt, answeredAt int64
+	row := s.db.QueryRow("SELECT left_at, reason, answered_by, answered_at FROM leave_reasons WHERE guild_id = ? AND discord_id = ? ORDER BY left_at DESC LIMIT 1", guildID, discordID)
+	if err := row.Scan(&leftAt, &reason.Reason, &reason.AnsweredBy, &answeredAt); err == sql.ErrNoRows {
+		return reason, false, nil
+	} else if err != nil {
+		return reason, false, err
+	}
+	reason.LeftAt = time.Unix(leftAt, 0)
+	reason.AnsweredAt = time.Unix(answeredAt, 0)
+	return reason, true, nil
+}
+
+// LeaveReasons returns why members who left since a time did so, oldest leave first
+func (s *Store) LeaveReasons(guildID string, since time.Time) ([]LeaveReason, error) {
+	rows, err := s.db.Query("SELECT discord_id, left_at, reason, answered_by, answered_at FROM leave_reasons WHERE guild_id = ? AND left_at >= ? ORDER BY left_at, discord_id", guildID, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reasons := []LeaveReason{}
+	for rows.Next() {
+		var reason LeaveReason
+		var leftAt, answeredAt int64
+		if err := rows.Scan(&reason.DiscordID, &leftAt, &reason.Reason, &reason.AnsweredBy, &answeredAt); err != nil {
+			return nil, err
+		}
+		reason.LeftAt = time.Unix(leftAt, 0)
+		reason.AnsweredAt = time.Unix(answeredAt, 0)
+		reasons = append(reasons, reason)
+	}
+	return reasons, rows.Err()
+}
+
+// Note is a moderator's note about a user
+type Note struct {
+	ID        int64
+	DiscordID string
+	Text      string
+	// AuthorID is the Discord ID of the moderator who wrote it
+	AuthorID string
+	At       time.Time
+}
+
+// AddNote records a note about a user, returning its ID
+func (s *Store) AddNote(guildID string, note Note) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(
+		"INSERT INTO notes(guild_id, discord_id, text, author_id, at) VALUES (?, ?, ?, ?, ?) RETURNING id",
+		guildID, note.DiscordID, note.Text, note.AuthorID, note.At.Unix(),
+	).Scan(&id)
+	return id, err
+}
+
+// Notes returns the notes about a user, oldest first
+func (s *Store) Notes(guildID, discordID string) ([]Note, error) {
+	return s.queryNotes("SELECT id, discord_id, text, author_id, at FROM notes WHERE guild_id = ? AND discord_id = ? ORDER BY at, id", guildID, discordID)
+}
+
+// RecentNotes returns the latest notes about the users of a guild, newest first
+func (s *Store) RecentNotes(guildID string, limit int) ([]Note, error) {
+	return s.queryNotes("SELECT id, discord_id, text, author_id, at FROM notes WHERE guild_id = ? ORDER BY at DESC, id DESC LIMIT ?", guildID, limit)
+}
+
+func (s *Store) queryNotes(query string, args ...interface{}) ([]Note, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []Note{}
+	for rows.Next() {
+		var note Note
+		var at int64
+		if err := rows.Scan(&note.ID, &note.DiscordID, &note.Text, &note.AuthorID, &at); err != nil {
+			return nil, err
+		}
+		note.At = time.Unix(at, 0)
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+// ScheduledEvent is a scheduled event of a guild that members can mark themselves interested in
+type ScheduledEvent struct {
+	ID string
+	// Name is empty if the event was never seen, only RSVPs to it
+	Name     string
+	StartsAt time.Time
+	// Interested counts the RSVPs that weren't withdrawn, only set by ScheduledEvents
+	Interested int
+}
+
+// RSVP is a member marking themselves interested in a scheduled event
+type RSVP struct {
+	DiscordID string
+	At        time.Time
+	// WithdrawnAt is when they stopped being interested, zero if they still are
+	WithdrawnAt time.Time
+}
+
+// RecordScheduledEvent records or renames a scheduled event
+func (s *Store) RecordScheduledEvent(guildID string, event ScheduledEvent) error {
+	_, err := s.db.Exec(
+		"INSERT INTO scheduled_events(guild_id, event_id, name, starts_at) VALUES (?, ?, ?, ?) ON CONFLICT (guild_id, event_id) DO UPDATE SET name = excluded.name, starts_at = excluded.starts_at",
+		guildID, event.ID, event.Name, event.StartsAt.Unix(),
+	)
+	return err
+}
+
+// ScheduledEvent returns a recorded scheduled event, returning false if it wasn't recorded
+func (s *Store) ScheduledEvent(guildID, eventID string) (ScheduledEvent, bool, error) {
+	event := ScheduledEvent{ID: eventID}
+	var startsAt int64
+	row := s.db.QueryRow("SELECT name, starts_at FROM scheduled_events WHERE guild_id = ? AND event_id = ?", guildID, eventID)
+	if err := row.Scan(&event.Name, &startsAt); err == sql.ErrNoRows {
+		return event, false, nil
+	} else if err != nil {
+		return event, false, err
+	}
+	event.StartsAt = time.Unix(startsAt, 0)
+	return event, true, nil
+}
+
+// ScheduledEvents returns up to limit scheduled events of a guild with how many members are interested, the latest start first
+func (s *Store) ScheduledEvents(guildID string, limit int) ([]ScheduledEvent, error) {
+	rows, err := s.db.Query(`SELECT e.event_id, e.name, e.starts_at, COUNT(r.discord_id) FROM scheduled_events e
+		LEFT JOIN event_rsvps r ON r.guild_id = e.guild_id AND r.event_id = e.event_id AND r.withdrawn_at = 0
+		WHERE e.guild_id = ? GROUP BY e.guild_id, e.event_id ORDER BY e.starts_at DESC, e.event_id DESC LIMIT ?`, guildID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []ScheduledEvent{}
+	for rows.Next() {
+		var event ScheduledEvent
+		var startsAt int64
+		if err := rows.Scan(&event.ID, &event.Name, &startsAt, &event.Interested); err != nil {
+			return nil, err
+		}
+		event.StartsAt = time.Unix(startsAt, 0)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// RecordRSVP records a member marking themselves interested in a scheduled event, or withdrawing, a new RSVP replaces a withdrawn one
+func (s *Store) RecordRSVP(guildID, eventID, discordID string, interested bool, at time.Time) error {
+	if !interested {
+		_, err := s.db.Exec(
+			"UPDATE event_rsvps SET withdrawn_at = ? WHERE guild_id = ? AND event_id = ? AND discord_id = ? AND withdrawn_at = 0",
+			at.Unix(), guildID, eventID, discordID,
+		)
+		return err
+	}
+	_, err := s.db.Exec(`INSERT INTO event_rsvps(guild_id, event_id, discord_id, rsvp_at, withdrawn_at) VALUES (?, ?, ?, ?, 0)
+		ON CONFLICT(guild_id, event_id, discord_id) DO UPDATE SET rsvp_at = excluded.rsvp_at, withdrawn_at = 0`,
+		guildID, eventID, discordID, at.Unix(),
+	)
+	return err
+}
+
+// RSVPs returns the RSVPs to a scheduled event, including withdrawn ones, oldest first
+func (s *Store) RSVPs(guildID, eventID string) ([]RSVP, error) {
+	rows, err := s.db.Query("SELECT discord_id, rsvp_at, withdrawn_at FROM event_rsvps WHERE guild_id = ? AND event_id = ? ORDER BY rsvp_at, discord_id", guildID, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rsvps := []RSVP{}
+	for rows.Next() {
+		var rsvp RSVP
+		var at, withdrawnAt int64
+		if err := rows.Scan(&rsvp.DiscordID, &at, &withdrawnAt); err != nil {
+			return nil, err
+		}
+		rsvp.At = time.Unix(at, 0)
+		if withdrawnAt != 0 {
+			rsvp.WithdrawnAt = time.Unix(withdrawnAt, 0)
+		}
+		rsvps = append(rsvps, rsvp)
+	}
+	return rsvps, rows.Err()
+}
+
+// PruneHistory deletes history recorded before cutoff
+func (s *Store) PruneHistory(cutoff time.Time) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM history WHERE created_at < ?", cutoff.Unix())
+	if err != nil {
+		return 0, err
+	}
+	s.cache.invalidateAll()
+	return result.RowsAffected()
+}
+
+// AdoptLegacyRows assigns rows stored before multi-guild support to guildID
+func (s *Store) AdoptLegacyRows(guildID string) error {
+	for _, table := range []string{"members", "history"} {
+		result, err := s.db.Exec("UPDATE "+table+" SET guild_id = ? WHERE guild_id = ''", guildID)
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected > 0 {
+			log.Printf("assigned %v legacy %v rows to guild '%v'", affected, table, guildID)
+			s.cache.invalidateAll()
+		}
+	}
+	return nil
+}
+
+// forgetTables are the tables with rows about users, all of them have guild_id and discord_id columns.
+// members comes first, ForgetInGuild may skip it.
+var forgetTables = []string{"members", "history", "name_history", "watched_users", "join_messages", "anniversaries", "outbox", "presence", "first_messages", "last_messages", "webhook_failures", "leave_reasons", "event_rsvps", "notes", "member_lists"}
+
+// Forget deletes everything stored about a Discord user, across all guilds.
+// It returns the number of deleted rows.
+func (s *Store) Forget(discordID string) (int64, error) {
+	affected, err := s.forget(forgetTables, "discord_id = ?", discordID)
+	if err != nil {
+		return 0, err
+	}
+	s.cache.invalidateAll()
+	log.Printf("[forget] purged stored data for '%v' (%v rows)", discordID, affected)
+	return affected, nil
+}
+
+// ForgetInGuild deletes everything a guild stored about a Discord user, leaving other guilds alone.
+// keepMember keeps the user's row in members, for current members who are still tracked.
+// It returns the number of deleted rows.
+func (s *Store) ForgetInGuild(guildID, discordID string, keepMember bool) (int64, error) {
+	tables := forgetTables
+	if keepMember {
+		tables = forgetTables[1:]
+	}
+	affected, err := s.forget(tables, "guild_id = ? AND discord_id = ?", guildID, discordID)
+	if err != nil {
+		return 0, err
+	}
+	s.cache.invalidate(guildID)
+	log.Printf("[forget] purged stored data for '%v' in guild '%v' (%v rows)", discordID, guildID, affected)
+	return affected, nil
+}
+
+// forget deletes the rows about users matching a condition from tables in one transaction
+func (s *Store) forget(tables []string, where string, args ...interface{}) (int64, error) {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var affected int64
+	for _, table := range tables {
+		result, err := tx.Exec("DELETE FROM "+table+" WHERE "+where, args...)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		affected += n
+	}
+	return affected, tx.Commit()
+}
@@ -0,0 +1,1216 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	st, err := Open(filepath.Join(t.TempDir(), "dul.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func TestCheckWritable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dul.db")
+	st, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.CheckWritable(); err != nil {
+		t.Errorf("expected a writable database, got %v", err)
+	}
+	st.Close()
+
+	st, err = Open("file:" + path + "?mode=ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if err := st.CheckWritable(); err == nil {
+		t.Error("expected a read-only database to fail")
+	}
+}
+
+func TestLeases(t *testing.T) {
+	st := openTestStore(t)
+	now := time.Unix(1700000000, 0)
+	acquire := func(holder string, at time.Time, expected bool) {
+		t.Helper()
+		acquired, err := st.AcquireLease("leader", holder, at, 30*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acquired != expected {
+			t.Errorf("expected %v acquiring at %v to be %v", holder, at, expected)
+		}
+	}
+
+	acquire("a", now, true)
+	acquire("b", now.Add(10*time.Second), false)
+	// renewing extends the lease
+	acquire("a", now.Add(20*time.Second), true)
+	acquire("b", now.Add(40*time.Second), false)
+	acquire("b", now.Add(50*time.Second), true)
+	acquire("a", now.Add(60*time.Second), false)
+
+	if err := st.ReleaseLease("leader", "a"); err != nil {
+		t.Fatal(err)
+	}
+	acquire("a", now.Add(60*time.Second), false)
+	if err := st.ReleaseLease("leader", "b"); err != nil {
+		t.Fatal(err)
+	}
+	acquire("a", now.Add(60*time.Second), true)
+
+	if holder, held, err := st.LeaseHolder("leader", now.Add(80*time.Second)); err != nil || !held || holder != "a" {
+		t.Errorf("expected a to hold the lease, got %q %v %v", holder, held, err)
+	}
+	if _, held, err := st.LeaseHolder("leader", now.Add(90*time.Second)); err != nil || held {
+		t.Errorf("expected the lease to have expired, got %v %v", held, err)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	for query, expected := range map[string]string{
+		"SELECT holder FROM leases WHERE name = ? AND expires_at > ?": "SELECT holder FROM leases WHERE name = $1 AND expires_at > $2",
+		"SELECT '?', \"a?\" FROM t WHERE v = ? AND w = 'it''s ?'":     "SELECT '?', \"a?\" FROM t WHERE v = $1 AND w = 'it''s ?'",
+		"SELECT 1": "SELECT 1",
+	} {
+		if actual := rebind(query); actual != expected {
+			t.Errorf("expected %q, got %q", expected, actual)
+		}
+	}
+}
+
+// TestPostgresLeases runs against the empty Postgres database at DUL_TEST_POSTGRES, like postgres://localhost/dul_test
+func TestPostgresLeases(t *testing.T) {
+	url := os.Getenv("DUL_TEST_POSTGRES")
+	if url == "" {
+		t.Skip("DUL_TEST_POSTGRES isn't set")
+	}
+	open := func() *Store {
+		st, err := Open(url)
+		if err != nil {
+			t.Fatalf("failed to open store: %v", err)
+		}
+		t.Cleanup(func() { st.Close() })
+		return st
+	}
+	a, b := open(), open()
+	now := time.Now()
+
+	if acquired, err := a.AcquireLease("leader", "a", now, 30*time.Second); err != nil || !acquired {
+		t.Fatalf("expected a to acquire the lease, got %v %v", acquired, err)
+	}
+	if acquired, err := b.AcquireLease("leader", "b", now, 30*time.Second); err != nil || acquired {
+		t.Fatalf("expected b to wait, got %v %v", acquired, err)
+	}
+	// renewing checks the session holding the lock
+	if acquired, err := a.AcquireLease("leader", "a", now, 30*time.Second); err != nil || !acquired {
+		t.Fatalf("expected a to keep the lease, got %v %v", acquired, err)
+	}
+	if holder, held, err := b.LeaseHolder("leader", now); err != nil || !held || holder != "a" {
+		t.Errorf("expected a to hold the lease, got %q %v %v", holder, held, err)
+	}
+
+	if err := a.ReleaseLease("leader", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if acquired, err := b.AcquireLease("leader", "b", now, 30*time.Second); err != nil || !acquired {
+		t.Fatalf("expected b to take over, got %v %v", acquired, err)
+	}
+	// closing ends the sessions holding locks
+	b.Close()
+	if acquired, err := a.AcquireLease("leader", "a", now, 30*time.Second); err != nil || !acquired {
+		t.Fatalf("expected a to take over, got %v %v", acquired, err)
+	}
+
+	// booleans are stored as integers like with SQLite
+	if err := a.RecordPresence("100", "1", true, now); err != nil {
+		t.Fatal(err)
+	}
+	if presence, seen, err := a.LastSeen("100", "1"); err != nil || !seen || !presence.Online {
+		t.Errorf("expected 1 to be online, got %+v %v %v", presence, seen, err)
+	}
+	if err := a.Vacuum(); !errors.Is(err, ErrSQLiteOnly) {
+		t.Errorf("expected vacuuming to need SQLite, got %v", err)
+	}
+}
+
+func TestIsCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "garbage.db")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("garbage!"), 512), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Open(path)
+	if !IsCorrupt(err, "") {
+		t.Errorf("expected opening garbage to fail as corrupt, got %v", err)
+	}
+	if IsCorrupt(err, "key") {
+		t.Error("expected a file that isn't a database not to count as corrupt with a key, it may be the wrong key")
+	}
+	if IsCorrupt(errors.New("database is locked"), "") {
+		t.Error("expected other errors not to count as corrupt")
+	}
+}
+
+func TestOutboxTransaction(t *testing.T) {
+	st := openTestStore(t)
+	at := time.Unix(1700000000, 0)
+	queue := func(commit bool) {
+		t.Helper()
+		tx, err := st.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.AddMember("g", "a", Member{User: User{Username: "alice"}}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.QueueAnnouncement("g", Announcement{DiscordID: "a", Event: `{"Type":"join"}`, At: at}); err != nil {
+			t.Fatal(err)
+		}
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertQueued := func(expected int) {
+		t.Helper()
+		members, err := st.Members("g")
+		if err != nil {
+			t.Fatal(err)
+		}
+		queued, err := st.QueuedAnnouncements("g")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(members) != expected || len(queued) != expected {
+			t.Fatalf("expected %v members and announcements, got %v and %v", expected, len(members), len(queued))
+		}
+	}
+
+	// rolling back drops the member and the announcement together
+	queue(false)
+	assertQueued(0)
+	queue(true)
+	assertQueued(1)
+
+	queued, err := st.QueuedAnnouncements("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued[0].DiscordID != "a" || queued[0].Alert || queued[0].Event != `{"Type":"join"}` || !queued[0].At.Equal(at) {
+		t.Errorf("unexpected announcement %+v", queued[0])
+	}
+	if err := st.DeleteAnnouncements([]int64{queued[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if queued, err = st.QueuedAnnouncements("g"); err != nil || len(queued) != 0 {
+		t.Errorf("expected the sent announcement to be deleted, got %v %v", queued, err)
+	}
+}
+
+func TestRecentEvents(t *testing.T) {
+	st := openTestStore(t)
+	start := time.Unix(1700000000, 0)
+	for i, event := range []string{EventJoin, EventJoin, EventLeave, "other", EventJoin} {
+		if err := st.RecordEvent(HistoryEvent{GuildID: "g", DiscordID: string(rune('a' + i)), Event: event, At: start.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.RecordEvent(HistoryEvent{GuildID: "other-guild", DiscordID: "z", Event: EventJoin, At: start}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := st.RecentEvents("g", []string{EventJoin, EventLeave}, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].DiscordID != "c" || events[1].DiscordID != "b" {
+		t.Errorf("unexpected events %+v", events)
+	}
+	if !events[0].At.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("unexpected time %v", events[0].At)
+	}
+}
+
+func TestUserHistory(t *testing.T) {
+	st := openTestStore(t)
+	start := time.Unix(1700000000, 0)
+	for _, event := range []HistoryEvent{
+		{GuildID: "g", DiscordID: "1", Event: EventLeave, At: start.Add(time.Hour), Details: `{"roles":["5"]}`},
+		{GuildID: "g", DiscordID: "1", Event: EventJoin, At: start},
+		{GuildID: "g", DiscordID: "2", Event: EventJoin, At: start},
+		{GuildID: "other-guild", DiscordID: "1", Event: EventJoin, At: start},
+	} {
+		if err := st.RecordEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := st.UserHistory("g", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Event != EventJoin || history[1].Event != EventLeave || history[1].Details != `{"roles":["5"]}` {
+		t.Errorf("unexpected history %+v", history)
+	}
+}
+
+func TestLastJoin(t *testing.T) {
+	st := openTestStore(t)
+	start := time.Unix(1700000000, 0)
+	for i, event := range []string{EventJoin, EventLeave, EventJoin, EventLeave} {
+		if err := st.RecordEvent(HistoryEvent{GuildID: "g", DiscordID: "a", Event: event, At: start.Add(time.Duration(i) * time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if at, ok, err := st.LastJoin("g", "a"); err != nil || !ok || !at.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("unexpected last join %v, %v, %v", at, ok, err)
+	}
+	if _, ok, err := st.LastJoin("other-guild", "a"); err != nil || ok {
+		t.Errorf("expected no join in another guild, got %v, %v", ok, err)
+	}
+}
+
+func TestStays(t *testing.T) {
+	st := openTestStore(t)
+	start := time.Unix(1700000000, 0)
+	for _, event := range []HistoryEvent{
+		{GuildID: "g", DiscordID: "a", Event: EventJoin, At: start},
+		{GuildID: "g", DiscordID: "b", Event: EventJoin, At: start.Add(time.Hour)},
+		{GuildID: "g", DiscordID: "a", Event: EventLeave, At: start.Add(2 * time.Hour)},
+		{GuildID: "g", DiscordID: "a", Event: EventJoin, At: start.Add(3 * time.Hour)},
+		{GuildID: "other-guild", DiscordID: "b", Event: EventLeave, At: start.Add(4 * time.Hour)},
+	} {
+		if err := st.RecordEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stays, err := st.Stays("g", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Stay{
+		{DiscordID: "a", JoinedAt: start, LeftAt: start.Add(2 * time.Hour)},
+		{DiscordID: "b", JoinedAt: start.Add(time.Hour)},
+		{DiscordID: "a", JoinedAt: start.Add(3 * time.Hour)},
+	}
+	if !reflect.DeepEqual(stays, expected) {
+		t.Errorf("unexpected stays %+v", stays)
+	}
+}
+
+func TestForget(t *testing.T) {
+	st := openTestStore(t)
+	if err := st.AddMember("g1", "1", Member{User: User{Username: "alice"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AddMember("g2", "1", Member{User: User{Username: "alice"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AddMember("g1", "2", Member{User: User{Username: "bob"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.RecordEvent(HistoryEvent{GuildID: "g1", DiscordID: "1", Event: EventJoin, User: User{Username: "alice"}, At: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	affected, err := st.Forget("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected != 3 {
+		t.Errorf("expected 3 deleted rows, got %v", affected)
+	}
+
+	members, err := st.Members("g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := members["1"]; ok || len(members) != 1 {
+		t.Errorf("unexpected members after forgetting %v", members)
+	}
+	events, err := st.RecentEvents("g1", []string{EventJoin}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("unexpected history after forgetting %v", events)
+	}
+}
+
+func TestForgetInGuild(t *testing.T) {
+	st := openTestStore(t)
+	for _, guildID := range []string{"g1", "g2"} {
+		if err := st.AddMember(guildID, "1", Member{User: User{Username: "alice"}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.RecordEvent(HistoryEvent{GuildID: guildID, DiscordID: "1", Event: EventJoin, User: User{Username: "alice"}, At: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	affected, err := st.ForgetInGuild("g1", "1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected != 2 {
+		t.Errorf("expected 2 deleted rows, got %v", affected)
+	}
+	for guildID, expected := range map[string]int{"g1": 0, "g2": 1} {
+		members, err := st.Members(guildID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, err := st.RecentEvents(guildID, []string{EventJoin}, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(members) != expected || len(events) != expected {
+			t.Errorf("expected %v members and joins in %v, got %v and %v", expected, guildID, members, events)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	st := openTestStore(t)
+	start := time.Unix(1700000000, 0)
+	for i, name := range []struct{ kind, name string }{
+		{NameUsername, "alice"},
+		{NameNick, "Al"},
+		{NameUsername, "alice2"},
+		{NameUsername, "alice"},
+	} {
+		if err := st.RecordName("g", "1", name.kind, name.name, start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.RecordName("other-guild", "1", NameNick, "Secret", start); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := st.Names("g", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Name{
+		{NameUsername, "alice", start, start.Add(3 * time.Hour)},
+		{NameNick, "Al", start.Add(time.Hour), start.Add(time.Hour)},
+		{NameUsername, "alice2", start.Add(2 * time.Hour), start.Add(2 * time.Hour)},
+	}
+	if len(names) != len(expected) {
+		t.Fatalf("got names %+v, expected %+v", names, expected)
+	}
+	for i := range expected {
+		if names[i].Kind != expected[i].Kind || names[i].Name != expected[i].Name || !names[i].FirstSeen.Equal(expected[i].FirstSeen) || !names[i].LastSeen.Equal(expected[i].LastSeen) {
+			t.Errorf("got names %+v, expected %+v", names, expected)
+			break
+		}
+	}
+}
+
+func TestGuildSettings(t *testing.T) {
+	st := openTestStore(t)
+	for _, setting := range [][3]string{
+		{"g1", "channel_id", "1"},
+		{"g1", "channel_id", "2"},
+		{"g1", "announce", "join"},
+		{"g2", "channel_id", "3"},
+	} {
+		if err := st.SetGuildSetting(setting[0], setting[1], setting[2], time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.DeleteGuildSetting("g1", "announce"); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := st.GuildSettings("g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings) != 1 || settings["channel_id"] != "2" {
+		t.Errorf("unexpected settings %v", settings)
+	}
+}
+
+func TestMemberRoles(t *testing.T) {
+	st := openTestStore(t)
+	if err := st.AddMember("g1", "1", Member{Roles: []string{"10", "20"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AddMember("g1", "2", Member{}); err != nil {
+		t.Fatal(err)
+	}
+	members, err := st.Members("g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !members["1"].Same(Member{Roles: []string{"10", "20"}}) || members["2"].Roles != nil {
+		t.Errorf("unexpected members %+v", members)
+	}
+	if !members["1"].HasAnyRole([]string{"30", "20"}) || members["1"].HasAnyRole([]string{"30"}) || members["2"].HasAnyRole([]string{"10"}) {
+		t.Errorf("unexpected role checks of %+v", members)
+	}
+}
+
+func TestWatchedUsers(t *testing.T) {
+	st := openTestStore(t)
+	now := time.Unix(1688212800, 0)
+	for _, watch := range []struct {
+		guildID, discordID string
+		added              bool
+	}{
+		{"g1", "1", true},
+		{"g1", "1", false},
+		{"g1", "2", true},
+		{"g2", "1", true},
+	} {
+		added, err := st.WatchUser(watch.guildID, watch.discordID, "mod", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added != watch.added {
+			t.Errorf("expected watching %v in %v to return %v", watch.discordID, watch.guildID, watch.added)
+		}
+	}
+	if removed, err := st.UnwatchUser("g1", "2"); err != nil || !removed {
+		t.Errorf("expected to unwatch 2, got %v %v", removed, err)
+	}
+	if removed, err := st.UnwatchUser("g1", "3"); err != nil || removed {
+		t.Errorf("expected 3 to not be watched, got %v %v", removed, err)
+	}
+
+	watched, err := st.WatchedUsers("g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watched) != 1 || watched[0] != (WatchedUser{DiscordID: "1", AddedBy: "mod", AddedAt: now}) {
+		t.Errorf("unexpected watched users %+v", watched)
+	}
+
+	// forgetting a user stops watching them everywhere
+	if _, err := st.Forget("1"); err != nil {
+		t.Fatal(err)
+	}
+	if watched, err := st.WatchedUsers("g2"); err != nil || len(watched) != 0 {
+		t.Errorf("expected forgotten users to be unwatched, got %+v %v", watched, err)
+	}
+}
+
+func TestJoinMessages(t *testing.T) {
+	st := openTestStore(t)
+	now := time.Unix(1688212800, 0)
+	for _, messageID := range []string{"10", "11"} {
+		if err := st.SaveJoinMessage("g1", "1", JoinMessage{ChannelID: "5", MessageID: messageID, SentAt: now}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a rejoin replaces the earlier message
+	message, ok, err := st.TakeJoinMessage("g1", "1")
+	if err != nil || !ok || message != (JoinMessage{ChannelID: "5", MessageID: "11", SentAt: now}) {
+		t.Errorf("unexpected join message %+v %v %v", message, ok, err)
+	}
+	if _, ok, err := st.TakeJoinMessage("g1", "1"); err != nil || ok {
+		t.Errorf("expected the join message to be taken once, got %v %v", ok, err)
+	}
+	if _, ok, err := st.TakeJoinMessage("g2", "1"); err != nil || ok {
+		t.Errorf("expected no join message in another guild, got %v %v", ok, err)
+	}
+}
+
+func TestRecordAnniversary(t *testing.T) {
+	st := openTestStore(t)
+	now := time.Unix(1688212800, 0)
+	if first, err := st.RecordAnniversary("g1", "1", 1, now); err != nil || !first {
+		t.Errorf("expected the first anniversary to be new, got %v %v", first, err)
+	}
+	if first, err := st.RecordAnniversary("g1", "1", 1, now); err != nil || first {
+		t.Errorf("expected the anniversary to be recorded once, got %v %v", first, err)
+	}
+	if first, err := st.RecordAnniversary("g1", "1", 2, now); err != nil || !first {
+		t.Errorf("expected the next year to be new, got %v %v", first, err)
+	}
+	if first, err := st.RecordAnniversary("g2", "1", 1, now); err != nil || !first {
+		t.Errorf("expected another guild to be new, got %v %v", first, err)
+	}
+}
+
+func TestEventsByDay(t *testing.T) {
+	st := openTestStore(t)
+	day := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, event := range []HistoryEvent{
+		{Event: EventJoin, At: day.Add(-time.Hour)},
+		{Event: EventJoin, At: day},
+		{Event: EventJoin, At: day.Add(23 * time.Hour)},
+		{Event: EventLeave, At: day.Add(12 * time.Hour)},
+		{Event: EventBoostStart, At: day},
+		{Event: EventLeave, At: day.Add(50 * time.Hour)},
+	} {
+		event.GuildID = "g"
+		if err := st.RecordEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	days, err := st.EventsByDay("g", day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []DayEvents{
+		{Day: day, Joins: 2, Leaves: 1},
+		{Day: day.AddDate(0, 0, 2), Joins: 0, Leaves: 1},
+	}
+	if len(days) != len(expected) {
+		t.Fatalf("got %+v, expected %+v", days, expected)
+	}
+	for i := range expected {
+		if !days[i].Day.Equal(expected[i].Day) || days[i].Joins != expected[i].Joins || days[i].Leaves != expected[i].Leaves {
+			t.Errorf("got %+v, expected %+v", days, expected)
+		}
+	}
+}
+
+func TestMemberCountHistory(t *testing.T) {
+	st := openTestStore(t)
+	since := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, event := range []HistoryEvent{
+		{Event: EventJoin, At: since},
+		{Event: EventJoin, At: since},
+		{Event: EventJoin, At: since.AddDate(0, 0, 3)},
+		{Event: EventLeave, At: since.AddDate(0, 0, 3)},
+		{Event: EventLeave, At: since.AddDate(0, 0, 3)},
+	} {
+		event.GuildID = "g"
+		if err := st.RecordEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// joins from before the history was kept, the snapshot knows better
+	if err := st.RecordSnapshot("g", since.AddDate(0, 0, 1).Add(12*time.Hour), 20); err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := st.MemberCountHistory("g", 10, since, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{20, 20, 11, 10}
+	for i, count := range counts {
+		if count.Count != expected[i] || !count.Day.Equal(since.AddDate(0, 0, i)) {
+			t.Errorf("got %+v, expected counts %v", counts, expected)
+			break
+		}
+	}
+}
+
+func TestPresence(t *testing.T) {
+	st := openTestStore(t)
+	start := time.Unix(1700000000, 0)
+	record := func(online bool, minutes int) {
+		t.Helper()
+		if err := st.RecordPresence("g", "a", online, start.Add(time.Duration(minutes)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertPresence := func(online bool, onlineSince, lastSeen int) {
+		t.Helper()
+		presence, ok, err := st.LastSeen("g", "a")
+		if err != nil || !ok {
+			t.Fatalf("expected a presence, got %v %v", ok, err)
+		}
+		expected := Presence{DiscordID: "a", Online: online, OnlineSince: start.Add(time.Duration(onlineSince) * time.Minute), LastSeen: start.Add(time.Duration(lastSeen) * time.Minute)}
+		if presence != expected {
+			t.Errorf("presence is %+v, expected %+v", presence, expected)
+		}
+	}
+
+	if _, ok, err := st.LastSeen("g", "a"); ok || err != nil {
+		t.Fatalf("expected no presence yet, got %v %v", ok, err)
+	}
+	record(true, 0)
+	// repeated updates without a transition change nothing
+	record(true, 5)
+	assertPresence(true, 0, 0)
+	record(false, 10)
+	record(false, 15)
+	assertPresence(false, 0, 10)
+	record(true, 20)
+	assertPresence(true, 20, 20)
+
+	presences, err := st.Presences("g")
+	if err != nil || len(presences) != 1 {
+		t.Errorf("expected one presence, got %v %v", presences, err)
+	}
+}
+
+func TestFirstMessages(t *testing.T) {
+	st := openTestStore(t)
+	joined := time.Unix(1700000000, 0)
+
+	if recorded, err := st.RecordFirstMessage("g", "a", "c", joined); recorded || err != nil {
+		t.Fatalf("expected untracked members to be skipped, got %v %v", recorded, err)
+	}
+	if err := st.AwaitFirstMessage("g", "a", joined); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AwaitFirstMessage("g", "b", joined.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if recorded, err := st.RecordFirstMessage("g", "a", "c", joined.Add(2*time.Hour)); !recorded || err != nil {
+		t.Fatalf("expected the first message to be recorded, got %v %v", recorded, err)
+	}
+	if recorded, err := st.RecordFirstMessage("g", "a", "d", joined.Add(3*time.Hour)); recorded || err != nil {
+		t.Fatalf("expected later messages to be skipped, got %v %v", recorded, err)
+	}
+
+	message, ok, err := st.FirstMessage("g", "a")
+	expected := FirstMessage{DiscordID: "a", JoinedAt: joined, ChannelID: "c", PostedAt: joined.Add(2 * time.Hour)}
+	if err != nil || !ok || message != expected {
+		t.Errorf("first message is %+v %v %v, expected %+v", message, ok, err, expected)
+	}
+
+	messages, err := st.FirstMessages("g", joined.Add(time.Minute))
+	if err != nil || len(messages) != 1 || messages[0].DiscordID != "b" || messages[0].Posted() {
+		t.Errorf("expected only b to have joined since, without posting, got %+v %v", messages, err)
+	}
+
+	// rejoining waits for a new first message
+	if err := st.AwaitFirstMessage("g", "a", joined.Add(24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if message, _, _ := st.FirstMessage("g", "a"); message.Posted() {
+		t.Errorf("expected the first message to be forgotten after rejoining, got %+v", message)
+	}
+}
+
+func TestLastActivity(t *testing.T) {
+	st := openTestStore(t)
+	start := time.Unix(1700000000, 0)
+	at := func(hours int) time.Time {
+		return start.Add(time.Duration(hours) * time.Hour)
+	}
+
+	for _, message := range []struct {
+		discordID string
+		hours     int
+	}{{"a", 1}, {"a", 5}, {"a", 3}, {"b", 1}} {
+		if err := st.RecordMessage("g", message.discordID, at(message.hours)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, event := range []HistoryEvent{
+		{GuildID: "g", DiscordID: "b", Event: EventVoiceJoin, At: at(2)},
+		{GuildID: "g", DiscordID: "c", Event: EventJoin, At: at(4)},
+	} {
+		if err := st.RecordEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.RecordPresence("g", "d", true, at(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	activity, err := st.LastActivity("g", at(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]time.Time{"a": at(5), "b": at(2), "d": at(10)}
+	if !reflect.DeepEqual(activity, expected) {
+		t.Errorf("activity is %v, expected %v", activity, expected)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	st := openTestStore(t)
+	at := time.Unix(1700000000, 0)
+	for i := 0; i < 100; i++ {
+		if err := st.RecordEvent(HistoryEvent{GuildID: "g", DiscordID: "a", Event: EventJoin, At: at, Details: strings.Repeat("a", 1000)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.PruneHistory(at.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if problems, err := st.IntegrityCheck(); err != nil || len(problems) != 0 {
+		t.Errorf("expected no problems, got %v %v", problems, err)
+	}
+	if err := st.Optimize(); err != nil {
+		t.Error(err)
+	}
+	free, total, err := st.FreePages()
+	if err != nil || free == 0 || total <= free {
+		t.Fatalf("expected free pages after forgetting, got %v of %v, %v", free, total, err)
+	}
+	if err := st.Vacuum(); err != nil {
+		t.Fatal(err)
+	}
+	if free, _, err := st.FreePages(); err != nil || free != 0 {
+		t.Errorf("expected no free pages after vacuuming, got %v %v", free, err)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	st := openTestStore(t)
+	if err := st.AddMember("g", "a", Member{User: User{Username: "alice", Discriminator: "0"}}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "backup.db")
+	if err := st.Backup(path); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+	if problems, err := backup.QuickCheck(); err != nil || len(problems) != 0 {
+		t.Errorf("expected no problems, got %v %v", problems, err)
+	}
+	if members, err := backup.Members("g"); err != nil || members["a"].Username != "alice" {
+		t.Errorf("expected the backup to have alice, got %v %v", members, err)
+	}
+}
+
+func TestOpenEncryptedNeedsSQLCipher(t *testing.T) {
+	st, err := OpenEncrypted(filepath.Join(t.TempDir(), "dul.db"), "secret")
+	if err == nil {
+		// linked against SQLCipher
+		st.Close()
+		t.Skip("built with SQLCipher")
+	}
+	if err != ErrNoSQLCipher {
+		t.Errorf("expected ErrNoSQLCipher, got %v", err)
+	}
+}
+
+func TestAnonymize(t *testing.T) {
+	st := openTestStore(t)
+	at := time.Unix(1700000000, 0)
+	for _, event := range []HistoryEvent{
+		// alice left long ago
+		{GuildID: "g", DiscordID: "1", Event: EventJoin, User: User{Username: "alice", Discriminator: "0"}, At: at},
+		{GuildID: "g", DiscordID: "1", Event: EventLeave, User: User{Username: "alice", Discriminator: "0"}, At: at.Add(time.Hour), Details: `{"roles":["5"]}`},
+		// bob joined long ago and is still here
+		{GuildID: "g", DiscordID: "2", Event: EventJoin, User: User{Username: "bob", Discriminator: "0"}, At: at},
+		// carol left recently
+		{GuildID: "g", DiscordID: "3", Event: EventJoin, User: User{Username: "carol", Discriminator: "0"}, At: at},
+		{GuildID: "g", DiscordID: "3", Event: EventLeave, User: User{Username: "carol", Discriminator: "0"}, At: at.Add(48 * time.Hour)},
+	} {
+		if err := st.RecordEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.AddMember("g", "2", Member{User: User{Username: "bob", Discriminator: "0"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.RecordMessage("g", "1", at); err != nil {
+		t.Fatal(err)
+	}
+
+	cutoff := at.Add(24 * time.Hour)
+	if anonymized, err := st.Anonymize(cutoff); err != nil || anonymized != 1 {
+		t.Fatalf("expected alice to be anonymized, got %v %v", anonymized, err)
+	}
+	if history, err := st.UserHistory("g", "1"); err != nil || len(history) != 0 {
+		t.Errorf("expected no history under alice's ID, got %v %v", history, err)
+	}
+	events, err := st.RecentEvents("g", []string{EventJoin, EventLeave}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pseudonyms := map[string]bool{}
+	for _, event := range events {
+		if IsPseudonym(event.DiscordID) {
+			pseudonyms[event.DiscordID] = true
+			if event.User != (User{}) || event.Details != "" {
+				t.Errorf("expected names and details to be cleared, got %+v", event)
+			}
+		}
+	}
+	if len(pseudonyms) != 1 || len(events) != 5 {
+		t.Errorf("expected both of alice's events under one pseudonym, got %+v", events)
+	}
+	if activity, err := st.LastActivity("g", at); err != nil || len(activity) != 0 {
+		t.Errorf("expected alice's activity to be deleted, got %v", activity)
+	}
+
+	// already anonymized members aren't anonymized again
+	if anonymized, err := st.Anonymize(cutoff); err != nil || anonymized != 0 {
+		t.Errorf("expected nothing left to anonymize, got %v %v", anonymized, err)
+	}
+}
+
+func TestWebhookFailures(t *testing.T) {
+	st := openTestStore(t)
+	at := time.Unix(1700000000, 0)
+	for _, discordID := range []string{"1", "2"} {
+		failure := WebhookFailure{URL: "https://example.com/hook", GuildID: "g", DiscordID: discordID, Event: EventJoin, Payload: `{"type":"join"}`, Attempts: 5, Error: "502 Bad Gateway", At: at}
+		if err := st.AddWebhookFailure(failure); err != nil {
+			t.Fatal(err)
+		}
+	}
+	failures, err := st.WebhookFailures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 2 || failures[0].DiscordID != "1" || failures[0].Attempts != 5 || failures[0].Error != "502 Bad Gateway" || !failures[0].At.Equal(at) {
+		t.Fatalf("unexpected failures %+v", failures)
+	}
+
+	if err := st.DeleteWebhookFailure(failures[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	// the payload names the member, forgetting them deletes it
+	if _, err := st.Forget("2"); err != nil {
+		t.Fatal(err)
+	}
+	if failures, err := st.WebhookFailures(); err != nil || len(failures) != 0 {
+		t.Errorf("expected no failures left, got %+v (%v)", failures, err)
+	}
+}
+
+func TestLeaveReasons(t *testing.T) {
+	st := openTestStore(t)
+	left := time.Unix(1700000000, 0)
+	if _, ok, err := st.LeaveReason("g", "a"); ok || err != nil {
+		t.Fatalf("expected no leave reason, got %v (%v)", ok, err)
+	}
+	reasons := []LeaveReason{
+		{DiscordID: "a", LeftAt: left, Reason: "Too many notifications", AnsweredBy: "a", AnsweredAt: left.Add(time.Minute)},
+		// answering twice for the same leave keeps the last answer
+		{DiscordID: "a", LeftAt: left, Reason: "Something else", AnsweredBy: "a", AnsweredAt: left.Add(2 * time.Minute)},
+		{DiscordID: "b", LeftAt: left.Add(time.Hour), Reason: "Something else", AnsweredBy: "mod", AnsweredAt: left.Add(2 * time.Hour)},
+		{DiscordID: "a", LeftAt: left.Add(24 * time.Hour), Reason: "Not enough activity", AnsweredBy: "a", AnsweredAt: left.Add(25 * time.Hour)},
+	}
+	for _, reason := range reasons {
+		if err := st.RecordLeaveReason("g", reason); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if reason, ok, err := st.LeaveReason("g", "a"); !ok || err != nil || reason != reasons[3] {
+		t.Errorf("expected the latest leave reason %+v, got %+v (%v)", reasons[3], reason, err)
+	}
+	all, err := st.LeaveReasons("g", left)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0] != reasons[1] || all[1] != reasons[2] || all[2] != reasons[3] {
+		t.Errorf("unexpected leave reasons %+v", all)
+	}
+
+	if _, err := st.Forget("a"); err != nil {
+		t.Fatal(err)
+	}
+	if all, err := st.LeaveReasons("g", left); err != nil || len(all) != 1 {
+		t.Errorf("expected only b's leave reason left, got %+v (%v)", all, err)
+	}
+}
+
+func TestNotes(t *testing.T) {
+	st := openTestStore(t)
+	at := time.Unix(1700000000, 0)
+	notes := []Note{
+		{DiscordID: "a", Text: "raided with alts", AuthorID: "mod", At: at.Add(time.Hour)},
+		{DiscordID: "a", Text: "came back, keep an eye on them", AuthorID: "other", At: at.Add(2 * time.Hour)},
+		{DiscordID: "b", Text: "helpful", AuthorID: "mod", At: at},
+	}
+	for i := range notes {
+		id, err := st.AddNote("g", notes[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		notes[i].ID = id
+	}
+
+	found, err := st.Notes("g", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0] != notes[0] || found[1] != notes[1] {
+		t.Errorf("expected a's notes oldest first, got %+v", found)
+	}
+	if found, err := st.Notes("other", "a"); err != nil || len(found) != 0 {
+		t.Errorf("expected notes to belong to their guild, got %+v (%v)", found, err)
+	}
+	if recent, err := st.RecentNotes("g", 2); err != nil || len(recent) != 2 || recent[0] != notes[1] || recent[1] != notes[0] {
+		t.Errorf("expected the latest notes newest first, got %+v (%v)", recent, err)
+	}
+
+	if _, err := st.Forget("a"); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := st.Notes("g", "a"); err != nil || len(found) != 0 {
+		t.Errorf("expected a's notes forgotten, got %+v (%v)", found, err)
+	}
+	if found, err := st.Notes("g", "b"); err != nil || len(found) != 1 {
+		t.Errorf("expected b's note kept, got %+v (%v)", found, err)
+	}
+}
+
+func TestLastDeparture(t *testing.T) {
+	st := openTestStore(t)
+	at := time.Unix(1700000000, 0)
+	for _, event := range []HistoryEvent{
+		{GuildID: "a", DiscordID: "1", Event: EventBan, At: at},
+		{GuildID: "a", DiscordID: "1", Event: EventLeave, At: at.Add(time.Second)},
+		{GuildID: "b", DiscordID: "1", Event: EventLeave, At: at.Add(time.Hour)},
+		{GuildID: "c", DiscordID: "1", Event: EventJoin, At: at.Add(2 * time.Hour)},
+	} {
+		if err := st.RecordEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// bans win over newer leaves
+	if departure, ok, err := st.LastDeparture("1", "c", at); !ok || err != nil || departure.GuildID != "a" || departure.Event != EventBan {
+		t.Errorf("expected the ban in a, got %+v, %v (%v)", departure, ok, err)
+	}
+	if departure, ok, err := st.LastDeparture("1", "a", at); !ok || err != nil || departure.GuildID != "b" || !departure.At.Equal(at.Add(time.Hour)) {
+		t.Errorf("expected the leave of b, got %+v, %v (%v)", departure, ok, err)
+	}
+	if _, ok, err := st.LastDeparture("1", "c", at.Add(2*time.Hour)); ok || err != nil {
+		t.Errorf("expected no departure since the join, got %v (%v)", ok, err)
+	}
+}
+
+func TestScheduledEvents(t *testing.T) {
+	st := openTestStore(t)
+	at := time.Unix(1700000000, 0)
+	for _, event := range []ScheduledEvent{
+		{ID: "e1", Name: "Movie night", StartsAt: at.Add(24 * time.Hour)},
+		{ID: "e2", Name: "Game night", StartsAt: at.Add(48 * time.Hour)},
+		// renaming replaces the name
+		{ID: "e1", Name: "Movie night!", StartsAt: at.Add(24 * time.Hour)},
+	} {
+		if err := st.RecordScheduledEvent("g", event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, rsvp := range []struct {
+		eventID, discordID string
+		interested         bool
+		at                 time.Time
+	}{
+		{"e1", "a", true, at},
+		{"e1", "b", true, at.Add(time.Minute)},
+		{"e1", "b", false, at.Add(2 * time.Minute)},
+		{"e2", "a", true, at.Add(3 * time.Minute)},
+		{"e2", "a", false, at.Add(4 * time.Minute)},
+		// interested again replaces the withdrawn RSVP
+		{"e2", "a", true, at.Add(5 * time.Minute)},
+	} {
+		if err := st.RecordRSVP("g", rsvp.eventID, rsvp.discordID, rsvp.interested, rsvp.at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if event, ok, err := st.ScheduledEvent("g", "e1"); !ok || err != nil || event.Name != "Movie night!" {
+		t.Errorf("expected the renamed event, got %+v (%v, %v)", event, ok, err)
+	}
+	if _, ok, err := st.ScheduledEvent("g", "e3"); ok || err != nil {
+		t.Errorf("expected no unknown event, got %v (%v)", ok, err)
+	}
+	events, err := st.ScheduledEvents("g", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].ID != "e2" || events[0].Interested != 1 || events[1].ID != "e1" || events[1].Interested != 1 {
+		t.Errorf("unexpected events %+v", events)
+	}
+	rsvps, err := st.RSVPs("g", "e1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []RSVP{
+		{DiscordID: "a", At: at},
+		{DiscordID: "b", At: at.Add(time.Minute), WithdrawnAt: at.Add(2 * time.Minute)},
+	}
+	if !reflect.DeepEqual(rsvps, expected) {
+		t.Errorf("expected %+v, got %+v", expected, rsvps)
+	}
+	if rsvps, err := st.RSVPs("g", "e2"); err != nil || len(rsvps) != 1 || !rsvps[0].WithdrawnAt.IsZero() || !rsvps[0].At.Equal(at.Add(5*time.Minute)) {
+		t.Errorf("expected a renewed RSVP, got %+v (%v)", rsvps, err)
+	}
+
+	if _, err := st.Forget("a"); err != nil {
+		t.Fatal(err)
+	}
+	if rsvps, err := st.RSVPs("g", "e1"); err != nil || len(rsvps) != 1 {
+		t.Errorf("expected only b's RSVP left, got %+v (%v)", rsvps, err)
+	}
+}
+
+func TestMemberLists(t *testing.T) {
+	st := openTestStore(t)
+	day := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	if recorded, err := st.RecordMemberList("100", day, []string{"1", "2"}); err != nil || !recorded {
+		t.Fatalf("expected the list to be recorded, got %v, %v", recorded, err)
+	}
+	// a day only gets one list
+	if recorded, err := st.RecordMemberList("100", day.Add(time.Hour), []string{"1"}); err != nil || recorded {
+		t.Errorf("expected the second list of the day to be ignored, got %v, %v", recorded, err)
+	}
+
+	if _, _, ok, err := st.MemberList("100", day.AddDate(0, 0, -1)); err != nil || ok {
+		t.Errorf("expected no list before the first day, got %v, %v", ok, err)
+	}
+	members, at, ok, err := st.MemberList("100", day.AddDate(0, 0, 3))
+	if err != nil || !ok {
+		t.Fatalf("expected the newest older list, got %v, %v", ok, err)
+	}
+	if !at.Equal(time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)) || !reflect.DeepEqual(members, map[string]bool{"1": true, "2": true}) {
+		t.Errorf("unexpected list of %v: %v", at, members)
+	}
+
+	if _, err := st.Forget("2"); err != nil {
+		t.Fatal(err)
+	}
+	if members, _, _, err := st.MemberList("100", day); err != nil || !reflect.DeepEqual(members, map[string]bool{"1": true}) {
+		t.Errorf("expected forgetting to remove the member from lists, got %v, %v", members, err)
+	}
+	// old lists are deleted
+	if _, err := st.RecordMemberList("100", day.AddDate(0, 0, MemberListDays+1), []string{"1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok, err := st.MemberList("100", day); err != nil || ok {
+		t.Errorf("expected the old list to be deleted, got %v, %v", ok, err)
+	}
+	members, _, _, err = st.MemberList("100", day.AddDate(0, 0, MemberListDays+1))
+	if err != nil || !reflect.DeepEqual(members, map[string]bool{"1": true}) {
+		t.Errorf("unexpected list %v, %v", members, err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	from, to := openTestStore(t), openTestStore(t)
+	at := time.Unix(1700000000, 0)
+	for i := 0; i < copyBatch+2; i++ {
+		if err := from.RecordEvent(HistoryEvent{GuildID: "g", DiscordID: fmt.Sprint(i), Event: EventJoin, At: at, Details: `{"invite":"abc"}`}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := from.AddMember("g", "1", Member{User: User{Username: "one"}, Pending: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := from.SetGuildSetting("g", "language", "de", at); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := from.AddNote("g", Note{DiscordID: "1", Text: "first", AuthorID: "2", At: at}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := from.AcquireLease("leader", "a", at, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	copied, err := Copy(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := map[string]int64{}
+	for _, table := range copied {
+		tables[table.Table] = table.Rows
+	}
+	if tables["history"] != copyBatch+2 || tables["members"] != 1 || tables["guild_settings"] != 1 || tables["notes"] != 1 {
+		t.Errorf("unexpected copied rows %v", tables)
+	}
+	if _, ok := tables["leases"]; ok {
+		t.Errorf("expected leases to stay behind, got %v", tables)
+	}
+	if settings, err := to.GuildSettings("g"); err != nil || settings["language"] != "de" {
+		t.Errorf("expected the settings to be copied, got %v %v", settings, err)
+	}
+	// new rows get IDs after the copied ones
+	if id, err := to.AddNote("g", Note{DiscordID: "1", Text: "second", AuthorID: "2", At: at}); err != nil || id != 2 {
+		t.Errorf("expected the next note ID, got %v %v", id, err)
+	}
+
+	if _, err := Copy(from, to); err == nil || !strings.Contains(err.Error(), "isn't empty") {
+		t.Errorf("expected copying into a database with data to fail, got %v", err)
+	}
+}
+
+func TestTenants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dul.db")
+	st, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	at := time.Unix(1700000000, 0)
+
+	if err := st.SaveTenant(Tenant{Name: "Bad Name"}); err == nil {
+		t.Errorf("expected an invalid name to fail")
+	}
+	if err := st.SaveTenant(Tenant{Name: "acme", Token: "a", OwnerID: "1", Guilds: []TenantGuild{{"10", "11"}, {"20", "21"}}, CreatedAt: at}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveTenant(Tenant{Name: "acme", Token: "b", OwnerID: "2", Guilds: []TenantGuild{{"10", "12"}}, CreatedAt: at.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := st.Tenants()
+	expected := []Tenant{{Name: "acme", Token: "b", OwnerID: "2", Guilds: []TenantGuild{{"10", "12"}}, CreatedAt: at}}
+	if err != nil || !reflect.DeepEqual(tenants, expected) {
+		t.Errorf("expected the tenant to be replaced but keep its creation time, got %+v %v", tenants, err)
+	}
+
+	// tenants see only their own data
+	acme, err := OpenTenant(path, "", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer acme.Close()
+	other, err := OpenTenant(path, "", "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if err := acme.AddMember("10", "1", Member{User: User{Username: "one"}}); err != nil {
+		t.Fatal(err)
+	}
+	for name, tenant := range map[string]*Store{"main": st, "other": other} {
+		if members, err := tenant.Members("10"); err != nil || len(members) != 0 {
+			t.Errorf("expected %v to have no members, got %v %v", name, members, err)
+		}
+	}
+	if members, err := acme.Members("10"); err != nil || len(members) != 1 {
+		t.Errorf("expected the tenant's member, got %v %v", members, err)
+	}
+
+	if removed, err := st.RemoveTenant("acme"); err != nil || !removed {
+		t.Errorf("expected the tenant to be removed, got %v %v", removed, err)
+	}
+	if removed, err := st.RemoveTenant("acme"); err != nil || removed {
+		t.Errorf("expected nothing left to remove, got %v %v", removed, err)
+	}
+	acme.Close()
+	if err := DropTenant(path, "acme"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), "tenants", "acme.db")); !os.IsNotExist(err) {
+		t.Errorf("expected the tenant's database to be deleted, got %v", err)
+	}
+}
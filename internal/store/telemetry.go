@@ -0,0 +1,61 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/telemetry"
+)
+
+// slowQuery is how long a query takes before it is traced.
+// Queries don't know the operation they are part of, tracing every one would bury the traces worth looking at.
+const slowQuery = 50 * time.Millisecond
+
+var queryDuration = telemetry.NewHistogram("db.client.operation.duration", "s", "Duration of database queries", telemetry.DurationBounds)
+
+// tracedQuerier measures the queries made with a querier
+type tracedQuerier struct {
+	querier
+	dialect dialect
+}
+
+func (q tracedQuerier) Exec(query string, args ...interface{}) (result sql.Result, err error) {
+	defer observeQuery(q.dialect, query, time.Now(), &err)
+	return q.querier.Exec(query, args...)
+}
+
+func (q tracedQuerier) Query(query string, args ...interface{}) (rows *sql.Rows, err error) {
+	defer observeQuery(q.dialect, query, time.Now(), &err)
+	return q.querier.Query(query, args...)
+}
+
+func (q tracedQuerier) QueryRow(query string, args ...interface{}) *sql.Row {
+	started := time.Now()
+	row := q.querier.QueryRow(query, args...)
+	err := row.Err()
+	observeQuery(q.dialect, query, started, &err)
+	return row
+}
+
+// observeQuery records the duration of a query that started at a time, and traces it if it was slow
+func observeQuery(d dialect, query string, started time.Time, err *error) {
+	if !telemetry.Enabled() {
+		return
+	}
+	took := time.Since(started)
+	operation := strings.ToUpper(strings.Fields(query)[0])
+	attributes := []telemetry.Attribute{telemetry.String("db.system", d.String()), telemetry.String("db.operation.name", operation)}
+	// a missing row is an answer, not a failure
+	failed := *err
+	if failed == sql.ErrNoRows {
+		failed = nil
+	}
+	if failed != nil {
+		attributes = append(attributes, telemetry.String("error.type", "query"))
+	}
+	queryDuration.Record(took.Seconds(), attributes...)
+	if took >= slowQuery {
+		telemetry.Finished(operation, started, failed, append(attributes, telemetry.String("db.query.text", query))...)
+	}
+}
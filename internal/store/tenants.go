@@ -0,0 +1,177 @@
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// tenantName limits tenant names to what is safe in file and schema names
+var tenantName = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// ValidTenantName reports whether name can name a tenant: 1 to 32 lowercase letters, digits, and underscores
+func ValidTenantName(name string) bool {
+	return tenantName.MatchString(name)
+}
+
+// Tenant is a bot hosted for an owner in tenant mode.
+// Its members, history, and settings are kept in a database of its own, see OpenTenant.
+type Tenant struct {
+	Name      string
+	Token     string
+	OwnerID   string
+	Guilds    []TenantGuild
+	CreatedAt time.Time
+}
+
+// TenantGuild is a guild a tenant's bot tracks and the channel it announces in
+type TenantGuild struct {
+	ID        string
+	ChannelID string
+}
+
+// Tenants returns the tenants ordered by name
+func (s *Store) Tenants() ([]Tenant, error) {
+	rows, err := s.db.Query("SELECT name, token, owner_id, guilds, created_at FROM tenants ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tenants := []Tenant{}
+	for rows.Next() {
+		var tenant Tenant
+		var guilds string
+		var createdAt int64
+		if err := rows.Scan(&tenant.Name, &tenant.Token, &tenant.OwnerID, &guilds, &createdAt); err != nil {
+			return nil, err
+		}
+		for _, guild := range strings.Split(guilds, ",") {
+			if id, channelID, ok := strings.Cut(guild, ":"); ok {
+				tenant.Guilds = append(tenant.Guilds, TenantGuild{ID: id, ChannelID: channelID})
+			}
+		}
+		tenant.CreatedAt = time.Unix(createdAt, 0)
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
+
+// SaveTenant adds a tenant, or replaces the token, owner, and guilds of the tenant with its name
+func (s *Store) SaveTenant(tenant Tenant) error {
+	if !ValidTenantName(tenant.Name) {
+		return fmt.Errorf("invalid tenant name '%v'", tenant.Name)
+	}
+	guilds := make([]string, len(tenant.Guilds))
+	for i, guild := range tenant.Guilds {
+		guilds[i] = guild.ID + ":" + guild.ChannelID
+	}
+	_, err := s.db.Exec(`INSERT INTO tenants(name, token, owner_id, guilds, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET token = excluded.token, owner_id = excluded.owner_id, guilds = excluded.guilds`,
+		tenant.Name, tenant.Token, tenant.OwnerID, strings.Join(guilds, ","), tenant.CreatedAt.Unix())
+	return err
+}
+
+// RemoveTenant removes a tenant, reporting whether it existed. Its database is kept, see DropTenant.
+func (s *Store) RemoveTenant(name string) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM tenants WHERE name = ?", name)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// OpenTenant opens (or creates) the database of a tenant next to the database at path, encrypted with the same key.
+// With SQLite it is tenants/<name>.db in the directory of path, with Postgres the schema tenant_<name> of the same database.
+func OpenTenant(path, key, name string) (*Store, error) {
+	if !ValidTenantName(name) {
+		return nil, fmt.Errorf("invalid tenant name '%v'", name)
+	}
+	if !IsPostgres(path) {
+		tenantPath, err := tenantFile(path, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(strings.TrimPrefix(tenantPath, "file:")), 0700); err != nil {
+			return nil, err
+		}
+		return OpenEncrypted(tenantPath, key)
+	}
+	if key != "" {
+		return nil, ErrSQLiteOnly
+	}
+
+	schema := tenantSchema(name)
+	if err := execPostgres(path, "CREATE SCHEMA IF NOT EXISTS "+schema); err != nil {
+		return nil, err
+	}
+	// unqualified tables are the tenant's, the migrations create them there too
+	parsed, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	query := parsed.Query()
+	query.Set("search_path", "tenant_"+name)
+	parsed.RawQuery = query.Encode()
+	return openPostgres(parsed.String())
+}
+
+// DropTenant deletes the database of a tenant, see OpenTenant
+func DropTenant(path, name string) error {
+	if !ValidTenantName(name) {
+		return fmt.Errorf("invalid tenant name '%v'", name)
+	}
+	if IsPostgres(path) {
+		return execPostgres(path, "DROP SCHEMA IF EXISTS "+tenantSchema(name)+" CASCADE")
+	}
+	tenantPath, err := tenantFile(path, name)
+	if err != nil {
+		return err
+	}
+	file, _, _ := strings.Cut(strings.TrimPrefix(tenantPath, "file:"), "?")
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Remove(file + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// tenantFile is the path of a tenant's SQLite database, keeping the options of path
+func tenantFile(path, name string) (string, error) {
+	file, options, _ := strings.Cut(path, "?")
+	file, uri := strings.CutPrefix(file, "file:")
+	if file == "" || file == ":memory:" {
+		return "", fmt.Errorf("tenants need a database file, not '%v'", path)
+	}
+	tenantPath := filepath.Join(filepath.Dir(file), "tenants", name+".db")
+	if uri {
+		tenantPath = "file:" + tenantPath
+	}
+	if options != "" {
+		tenantPath += "?" + options
+	}
+	return tenantPath, nil
+}
+
+func tenantSchema(name string) string {
+	return pgx.Identifier{"tenant_" + name}.Sanitize()
+}
+
+// execPostgres runs a statement on its own connection to the Postgres database at dsn
+func execPostgres(dsn, statement string) error {
+	db, _, err := openPostgresDB(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.Exec(statement)
+	return err
+}
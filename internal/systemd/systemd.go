@@ -0,0 +1,82 @@
+// Package systemd reports the state of a Type=notify service to systemd, and keeps its watchdog fed.
+// Outside of systemd (without NOTIFY_SOCKET) nothing is sent.
+package systemd
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// States sent with Notify
+const (
+	Ready     = "READY=1"
+	Reloading = "RELOADING=1"
+	Stopping  = "STOPPING=1"
+	Watchdog  = "WATCHDOG=1"
+)
+
+// Notify sends a state to the socket systemd passed in NOTIFY_SOCKET, it does nothing without one
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// sockets starting with @ are in the abstract namespace
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval returns how often systemd expects a watchdog ping, 0 if the watchdog isn't on for this process
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// RunWatchdog pings systemd twice per interval while healthy reports true, until ctx is canceled.
+// Once pings stop for the whole interval systemd restarts the service.
+func RunWatchdog(ctx context.Context, interval time.Duration, healthy func() bool) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	wasHealthy := true
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		isHealthy := healthy()
+		if isHealthy != wasHealthy {
+			if isHealthy {
+				log.Println("[systemd] healthy again, resuming watchdog pings")
+			} else {
+				log.Println("[systemd] unhealthy, skipping watchdog pings")
+			}
+			wasHealthy = isHealthy
+		}
+		if !isHealthy {
+			continue
+		}
+		if err := Notify(Watchdog); err != nil {
+			log.Printf("[systemd] failed to ping the watchdog: %v", err)
+		}
+	}
+}
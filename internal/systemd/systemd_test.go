@@ -0,0 +1,91 @@
+package systemd
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// listen creates a notify socket and points NOTIFY_SOCKET at it
+func listen(t *testing.T) *net.UnixConn {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func receive(t *testing.T, conn *net.UnixConn) string {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Notify(Ready); err != nil {
+		t.Errorf("expected nothing to be sent outside of systemd, got %v", err)
+	}
+
+	conn := listen(t)
+	if err := Notify(Ready); err != nil {
+		t.Fatal(err)
+	}
+	if state := receive(t, conn); state != Ready {
+		t.Errorf("expected %v, got %v", Ready, state)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	for _, test := range []struct {
+		usec, pid string
+		expected  time.Duration
+	}{
+		{"", "", 0},
+		{"30000000", "", 30 * time.Second},
+		{"30000000", strconv.Itoa(os.Getpid()), 30 * time.Second},
+		// meant for another process
+		{"30000000", "1", 0},
+	} {
+		t.Setenv("WATCHDOG_USEC", test.usec)
+		t.Setenv("WATCHDOG_PID", test.pid)
+		if interval := WatchdogInterval(); interval != test.expected {
+			t.Errorf("expected %v for %+v, got %v", test.expected, test, interval)
+		}
+	}
+}
+
+func TestRunWatchdog(t *testing.T) {
+	conn := listen(t)
+	var healthy int32
+	checks := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go RunWatchdog(ctx, 20*time.Millisecond, func() bool {
+		select {
+		case checks <- struct{}{}:
+		default:
+		}
+		return atomic.LoadInt32(&healthy) == 1
+	})
+
+	// unhealthy checks don't ping
+	<-checks
+	<-checks
+	atomic.StoreInt32(&healthy, 1)
+	if state := receive(t, conn); state != Watchdog {
+		t.Errorf("expected %v, got %v", Watchdog, state)
+	}
+}
@@ -0,0 +1,60 @@
+package telemetry
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var requestDuration = NewHistogram("http.client.request.duration", "s", "Duration of requests to the Discord API", DurationBounds)
+
+// Transport traces and measures Discord API requests made with Base, or http.DefaultTransport if it is nil
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !Enabled() {
+		return base.RoundTrip(req)
+	}
+
+	route := urlTemplate(req.URL.Path)
+	attributes := []Attribute{String("http.request.method", req.Method), String("url.template", route)}
+	span := StartClient(req.Context(), req.Method+" "+route, append(attributes, String("server.address", req.URL.Hostname()))...)
+	started := time.Now()
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		span.Fail(err)
+		attributes = append(attributes, String("error.type", "request"))
+	} else {
+		status := Int("http.response.status_code", resp.StatusCode)
+		span.SetAttributes(status)
+		attributes = append(attributes, status)
+		if resp.StatusCode >= 400 {
+			span.Fail(fmt.Errorf("responded with %v", resp.Status))
+		}
+	}
+	requestDuration.Record(time.Since(started).Seconds(), attributes...)
+	span.End()
+	return resp, err
+}
+
+// urlTemplate replaces the IDs in a Discord API path with {id}, and the interaction and webhook tokens following them with {token}.
+// It keeps routes few, and tokens out of the collector.
+func urlTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if i >= 2 && segments[i-1] == "{id}" && (segments[i-2] == "webhooks" || segments[i-2] == "interactions") {
+			segments[i] = "{token}"
+		} else if segment != "" && strings.Trim(segment, "0123456789") == "" {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
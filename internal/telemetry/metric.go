@@ -0,0 +1,114 @@
+package telemetry
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// DurationBounds are histogram bucket bounds in seconds for requests and queries
+var DurationBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	gaugesLock sync.Mutex
+	gauges     []*Gauge
+)
+
+func registeredGauges() []*Gauge {
+	gaugesLock.Lock()
+	defer gaugesLock.Unlock()
+	return append([]*Gauge{}, gauges...)
+}
+
+// Histogram is a distribution of measurements, declared before an exporter is enabled
+type Histogram struct {
+	name        string
+	unit        string
+	description string
+	bounds      []float64
+}
+
+// NewHistogram declares a histogram, unit is like "s" (UCUM) and bounds are the upper bounds of its buckets
+func NewHistogram(name, unit, description string, bounds []float64) *Histogram {
+	return &Histogram{name: name, unit: unit, description: description, bounds: bounds}
+}
+
+// Record adds a measurement, it is ignored when telemetry isn't enabled
+func (h *Histogram) Record(value float64, attributes ...Attribute) {
+	e := exporter()
+	if e == nil {
+		return
+	}
+	instrument, ok := e.instruments.Load(h)
+	if !ok {
+		created, err := e.meter.Float64Histogram(h.name, metric.WithUnit(h.unit), metric.WithDescription(h.description), metric.WithExplicitBucketBoundaries(h.bounds...))
+		if err != nil {
+			log.Printf("[telemetry] failed to create histogram '%v': %v", h.name, err)
+		}
+		instrument, _ = e.instruments.LoadOrStore(h, created)
+	}
+	instrument.(metric.Float64Histogram).Record(context.Background(), value, metric.WithAttributes(attributes...))
+}
+
+// Counter is a count that only goes up, like events dropped
+type Counter struct {
+	name        string
+	unit        string
+	description string
+}
+
+// NewCounter declares a counter, unit is like "{event}" (UCUM)
+func NewCounter(name, unit, description string) *Counter {
+	return &Counter{name: name, unit: unit, description: description}
+}
+
+// Add counts n more, it is ignored when telemetry isn't enabled
+func (c *Counter) Add(n uint64) {
+	e := exporter()
+	if e == nil {
+		return
+	}
+	instrument, ok := e.instruments.Load(c)
+	if !ok {
+		created, err := e.meter.Int64Counter(c.name, metric.WithUnit(c.unit), metric.WithDescription(c.description))
+		if err != nil {
+			log.Printf("[telemetry] failed to create counter '%v': %v", c.name, err)
+		}
+		instrument, _ = e.instruments.LoadOrStore(c, created)
+	}
+	instrument.(metric.Int64Counter).Add(context.Background(), int64(n))
+}
+
+// Gauge is a value observed when exporting, like the length of a queue
+type Gauge struct {
+	name        string
+	unit        string
+	description string
+	observe     func() int64
+}
+
+// NewGauge registers a gauge calling observe on every export
+func NewGauge(name, unit, description string, observe func() int64) *Gauge {
+	g := &Gauge{name: name, unit: unit, description: description, observe: observe}
+	gaugesLock.Lock()
+	gauges = append(gauges, g)
+	gaugesLock.Unlock()
+	if e := exporter(); e != nil {
+		e.observe(g)
+	}
+	return g
+}
+
+// observe reports a gauge with every export of e
+func (e *Exporter) observe(g *Gauge) {
+	_, err := e.meter.Int64ObservableGauge(g.name, metric.WithUnit(g.unit), metric.WithDescription(g.description),
+		metric.WithInt64Callback(func(_ context.Context, observer metric.Int64Observer) error {
+			observer.Observe(g.observe())
+			return nil
+		}))
+	if err != nil {
+		log.Printf("[telemetry] failed to create gauge '%v': %v", g.name, err)
+	}
+}
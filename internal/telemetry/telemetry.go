@@ -0,0 +1,120 @@
+// Package telemetry traces and measures Discord API calls, database queries, and syncs with the OpenTelemetry SDK,
+// exporting them to a collector with OTLP over HTTP.
+// Nothing is recorded until an exporter is enabled.
+package telemetry
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// exportInterval is how often spans and metrics are sent to the collector
+	exportInterval = 10 * time.Second
+	// maxQueuedSpans limits the spans kept between exports, more are dropped until the next export
+	maxQueuedSpans = 2048
+	// shutdownTimeout limits sending what is left when shutting down
+	shutdownTimeout = 10 * time.Second
+	// scopeName is the instrumentation scope of everything exported
+	scopeName = "go.albinodrought/discord-user-log"
+)
+
+// current is the enabled *Exporter
+var current atomic.Value
+
+// Exporter records spans and metrics with the SDK, and sends them to a collector in the background
+type Exporter struct {
+	traces  *sdktrace.TracerProvider
+	metrics *sdkmetric.MeterProvider
+	tracer  trace.Tracer
+	meter   metric.Meter
+
+	// instruments maps each registered *Histogram and *Counter to its SDK instrument, created when first recorded
+	instruments sync.Map
+}
+
+// New creates an exporter for a collector at endpoint, like http://collector:4318, sending headers with every request
+func New(endpoint string, headers map[string]string, serviceName string) (*Exporter, error) {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	// exporting happens in the background, the SDK reports its failures here
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Printf("[telemetry] %v", err)
+	}))
+
+	ctx := context.Background()
+	spanExporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint+"/v1/traces"), otlptracehttp.WithHeaders(headers))
+	if err != nil {
+		return nil, err
+	}
+	metricExporter, err := otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpointURL(endpoint+"/v1/metrics"), otlpmetrichttp.WithHeaders(headers))
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	return newExporter(res,
+		sdktrace.WithBatcher(spanExporter, sdktrace.WithBatchTimeout(exportInterval), sdktrace.WithMaxQueueSize(maxQueuedSpans)),
+		sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(exportInterval)),
+	), nil
+}
+
+// newExporter creates an exporter sending spans with a span processor, and metrics when reader collects them
+func newExporter(res *resource.Resource, spans sdktrace.TracerProviderOption, reader sdkmetric.Reader) *Exporter {
+	traces := sdktrace.NewTracerProvider(sdktrace.WithResource(res), spans)
+	metrics := sdkmetric.NewMeterProvider(sdkmetric.WithResource(res), sdkmetric.WithReader(reader))
+	return &Exporter{
+		traces:  traces,
+		metrics: metrics,
+		tracer:  traces.Tracer(scopeName),
+		meter:   metrics.Meter(scopeName),
+	}
+}
+
+// Enable starts recording spans and metrics for e, they are exported until Shutdown
+func Enable(e *Exporter) {
+	current.Store(e)
+	if e == nil {
+		return
+	}
+	for _, g := range registeredGauges() {
+		e.observe(g)
+	}
+}
+
+// Enabled reports whether an exporter is enabled, callers can skip measuring otherwise
+func Enabled() bool {
+	return exporter() != nil
+}
+
+func exporter() *Exporter {
+	e, _ := current.Load().(*Exporter)
+	return e
+}
+
+// Shutdown sends the spans and metrics recorded since the last export and stops exporting, failures are logged
+func (e *Exporter) Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := e.traces.Shutdown(ctx); err != nil {
+		log.Printf("[telemetry] failed to export the last spans: %v", err)
+	}
+	if err := e.metrics.Shutdown(ctx); err != nil {
+		log.Printf("[telemetry] failed to export the last metrics: %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// enableTestExporter records spans in memory and metrics when they are collected from the returned reader
+func enableTestExporter(t *testing.T) (*tracetest.InMemoryExporter, *sdkmetric.ManualReader) {
+	spans := tracetest.NewInMemoryExporter()
+	reader := sdkmetric.NewManualReader()
+	Enable(newExporter(resource.NewSchemaless(attribute.String("service.name", "user-log")), sdktrace.WithSyncer(spans), reader))
+	t.Cleanup(func() { Enable(nil) })
+	return spans, reader
+}
+
+// collect returns the metrics recorded so far by name
+func collect(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Metrics {
+	var collected metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &collected); err != nil {
+		t.Fatal(err)
+	}
+	metrics := map[string]metricdata.Metrics{}
+	for _, scope := range collected.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+func TestDisabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "sync")
+	if span != nil || trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		t.Errorf("expected no span while disabled")
+	}
+	// a nil span ignores everything
+	span.SetAttributes(Int("members", 1))
+	span.Fail(errors.New("failed"))
+	span.End()
+	NewHistogram("test.disabled", "s", "Test durations", DurationBounds).Record(1)
+}
+
+func TestSpans(t *testing.T) {
+	spans, _ := enableTestExporter(t)
+
+	ctx, parent := Start(context.Background(), "sync", String("guild.id", "100"))
+	child := StartClient(ctx, "GET /guilds/{id}/members")
+	child.Fail(errors.New("timed out"))
+	child.End()
+	parent.SetAttributes(Int("members", 3), Bool("canceled", false))
+	parent.End()
+
+	ended := spans.GetSpans()
+	if len(ended) != 2 {
+		t.Fatalf("expected 2 spans, got %v", ended)
+	}
+	exportedChild, exportedParent := ended[0], ended[1]
+	if exportedChild.Parent.SpanID() != exportedParent.SpanContext.SpanID() || exportedChild.SpanContext.TraceID() != exportedParent.SpanContext.TraceID() {
+		t.Errorf("expected the client span to be a child of the sync span, got %+v and %+v", exportedChild, exportedParent)
+	}
+	if exportedParent.Parent.IsValid() {
+		t.Errorf("expected the sync span to be a root, got %+v", exportedParent)
+	}
+	if exportedChild.SpanKind != trace.SpanKindClient || exportedChild.Status.Code != codes.Error || exportedChild.Status.Description != "timed out" {
+		t.Errorf("expected the client span to have failed, got %+v", exportedChild)
+	}
+	expected := []Attribute{String("guild.id", "100"), Int("members", 3), Bool("canceled", false)}
+	if len(exportedParent.Attributes) != len(expected) {
+		t.Fatalf("unexpected sync attributes %v", exportedParent.Attributes)
+	}
+	for i := range expected {
+		if exportedParent.Attributes[i] != expected[i] {
+			t.Errorf("unexpected sync attributes %v", exportedParent.Attributes)
+		}
+	}
+	if service, _ := exportedParent.Resource.Set().Value("service.name"); service.AsString() != "user-log" {
+		t.Errorf("unexpected resource %v", exportedParent.Resource)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	_, reader := enableTestExporter(t)
+	h := NewHistogram("test.duration", "s", "Test durations", []float64{1, 10})
+
+	h.Record(0.5, String("op", "a"))
+	h.Record(5, String("op", "a"))
+	h.Record(50, String("op", "a"))
+	h.Record(2, String("op", "b"))
+
+	metric := collect(t, reader)["test.duration"]
+	histogram, ok := metric.Data.(metricdata.Histogram[float64])
+	if !ok || metric.Unit != "s" || histogram.Temporality != metricdata.CumulativeTemporality || len(histogram.DataPoints) != 2 {
+		t.Fatalf("unexpected metric %+v", metric)
+	}
+	for _, point := range histogram.DataPoints {
+		var expectedCount uint64
+		var expectedBuckets []uint64
+		switch op, _ := point.Attributes.Value("op"); op.AsString() {
+		case "a":
+			expectedCount, expectedBuckets = 3, []uint64{1, 1, 1}
+		case "b":
+			expectedCount, expectedBuckets = 1, []uint64{0, 1, 0}
+		}
+		if point.Count != expectedCount || len(point.BucketCounts) != len(expectedBuckets) {
+			t.Errorf("expected count %v and buckets %v, got %+v", expectedCount, expectedBuckets, point)
+			continue
+		}
+		for i := range expectedBuckets {
+			if point.BucketCounts[i] != expectedBuckets[i] {
+				t.Errorf("expected buckets %v, got %+v", expectedBuckets, point)
+			}
+		}
+	}
+}
+
+func TestCounterAndGauge(t *testing.T) {
+	_, reader := enableTestExporter(t)
+	counter := NewCounter("test.dropped", "{event}", "Test drops")
+	NewGauge("test.depth", "{event}", "Test depth", func() int64 { return 7 })
+
+	counter.Add(2)
+	counter.Add(1)
+
+	metrics := collect(t, reader)
+	if sum, ok := metrics["test.dropped"].Data.(metricdata.Sum[int64]); !ok || !sum.IsMonotonic || sum.DataPoints[0].Value != 3 {
+		t.Errorf("unexpected counter %+v", metrics["test.dropped"])
+	}
+	if gauge, ok := metrics["test.depth"].Data.(metricdata.Gauge[int64]); !ok || gauge.DataPoints[0].Value != 7 {
+		t.Errorf("unexpected gauge %+v", metrics["test.depth"])
+	}
+}
+
+func TestTransport(t *testing.T) {
+	spans, reader := enableTestExporter(t)
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer discord.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+	resp, err := client.Post(discord.URL+"/api/v9/interactions/123/aW50ZXJhY3Rpb24/callback", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	span := spans.GetSpans()[0]
+	if span.Name != "POST /api/v9/interactions/{id}/{token}/callback" {
+		t.Errorf("unexpected span name %v", span.Name)
+	}
+	if span.Status.Code != codes.Error {
+		t.Errorf("expected a rate limited request to fail, got %+v", span)
+	}
+	if _, ok := collect(t, reader)["http.client.request.duration"]; !ok {
+		t.Errorf("expected the request to be measured")
+	}
+}
+
+func TestExport(t *testing.T) {
+	var lock sync.Mutex
+	received := map[string]http.Header{}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		received[r.URL.Path] = r.Header
+	}))
+	defer collector.Close()
+
+	e, err := New(collector.URL+"/", map[string]string{"Authorization": "Bearer secret"}, "user-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Enable(e)
+	defer Enable(nil)
+	_, span := Start(context.Background(), "sync")
+	span.End()
+	NewCounter("test.exported", "{event}", "Test exports").Add(1)
+	// shutting down sends what wasn't exported yet
+	e.Shutdown()
+
+	lock.Lock()
+	defer lock.Unlock()
+	for _, path := range []string{"/v1/traces", "/v1/metrics"} {
+		if headers, ok := received[path]; !ok || headers.Get("Authorization") != "Bearer secret" {
+			t.Errorf("expected an export to %v with the configured headers, got %v", path, received)
+		}
+	}
+}
+
+func TestURLTemplate(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/api/v9/guilds/100/members":                     "/api/v9/guilds/{id}/members",
+		"/api/v9/channels/1/messages/2":                  "/api/v9/channels/{id}/messages/{id}",
+		"/api/v9/webhooks/3/token123/messages/@original": "/api/v9/webhooks/{id}/{token}/messages/@original",
+		"/api/v9/gateway/bot":                            "/api/v9/gateway/bot",
+	} {
+		if actual := urlTemplate(path); actual != expected {
+			t.Errorf("expected %v to be %v, got %v", path, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute describes a span or metric data point
+type Attribute = attribute.KeyValue
+
+// String is a string attribute
+func String(key, value string) Attribute {
+	return attribute.String(key, value)
+}
+
+// Int is an integer attribute
+func Int(key string, value int) Attribute {
+	return attribute.Int(key, value)
+}
+
+// Bool is a boolean attribute
+func Bool(key string, value bool) Attribute {
+	return attribute.Bool(key, value)
+}
+
+// Span is an operation being traced, a nil span (telemetry isn't enabled) ignores everything
+type Span struct {
+	span trace.Span
+}
+
+// Start starts a span as a child of the span in ctx, and returns ctx with the new span for its children
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	e := exporter()
+	if e == nil {
+		return ctx, nil
+	}
+	ctx, span := e.tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+	return ctx, &Span{span}
+}
+
+// StartClient starts a span for a request to another service, as a child of the span in ctx
+func StartClient(ctx context.Context, name string, attributes ...Attribute) *Span {
+	e := exporter()
+	if e == nil {
+		return nil
+	}
+	_, span := e.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+	return &Span{span}
+}
+
+// Finished records a request to another service that already finished, without a parent
+func Finished(name string, start time.Time, err error, attributes ...Attribute) {
+	e := exporter()
+	if e == nil {
+		return
+	}
+	_, span := e.tracer.Start(context.Background(), name, trace.WithSpanKind(trace.SpanKindClient), trace.WithTimestamp(start), trace.WithAttributes(attributes...))
+	s := &Span{span}
+	s.Fail(err)
+	s.End()
+}
+
+// SetAttributes adds attributes to the span, like results only known at its end
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(attributes...)
+}
+
+// Fail marks the span as failed, a nil err is ignored
+func (s *Span) Fail(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End finishes the span, it is exported with the next batch
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.span.End()
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// feedEntryCount is how many events the Atom feed lists
+const feedEntryCount = 50
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string     `xml:"id"`
+	Title   string     `xml:"title"`
+	Updated string     `xml:"updated"`
+	Author  atomAuthor `xml:"author"`
+	Content string     `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// atom serves the recent joins and leaves of the guild in the guild query parameter as an Atom feed.
+// Feed readers can't log in, so it uses the events token.
+func (s *Server) atom(w http.ResponseWriter, r *http.Request) {
+	if !s.eventsAuthorized(r) {
+		http.Error(w, "invalid events token", http.StatusUnauthorized)
+		return
+	}
+	guildID := r.URL.Query().Get("guild")
+	if guildID == "" {
+		http.Error(w, "the guild parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	events, err := s.store.RecentEvents(guildID, []string{store.EventJoin, store.EventLeave}, feedEntryCount, 0)
+	if err != nil {
+		log.Printf("[web] failed to load the feed of guild '%v': %v", guildID, err)
+		http.Error(w, "failed to load events", http.StatusInternalServerError)
+		return
+	}
+	feed := buildAtomFeed(guildID, s.guildName(guildID), s.feedURL(guildID), events, time.Now().In(s.options.Location))
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	fmt.Fprint(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		log.Printf("[web] failed to write the feed of guild '%v': %v", guildID, err)
+	}
+}
+
+// feedURL links to a guild's feed, without the token
+func (s *Server) feedURL(guildID string) string {
+	return s.options.BaseURL + "/feed.atom?" + url.Values{"guild": {guildID}}.Encode()
+}
+
+// buildAtomFeed shows dates in the entry content in the location of now
+func buildAtomFeed(guildID, guildName, selfURL string, events []store.HistoryEvent, now time.Time) atomFeed {
+	feed := atomFeed{
+		ID:      "urn:user-log:guild:" + guildID,
+		Title:   guildName + " Joins and Leaves",
+		Updated: now.UTC().Format(time.RFC3339),
+		Link:    atomLink{Rel: "self", Href: selfURL},
+		Entries: []atomEntry{},
+	}
+	if len(events) > 0 {
+		// events are newest first
+		feed.Updated = events[0].At.UTC().Format(time.RFC3339)
+	}
+	for _, event := range events {
+		name := event.User.Tag()
+		if name == "" {
+			name = event.DiscordID
+		}
+		action := "joined"
+		if event.Event == store.EventLeave {
+			action = "left"
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      fmt.Sprintf("urn:user-log:guild:%v:%v:%v:%v", guildID, event.Event, event.DiscordID, event.At.Unix()),
+			Title:   fmt.Sprintf("%v %v %v", name, action, guildName),
+			Updated: event.At.UTC().Format(time.RFC3339),
+			Author:  atomAuthor{Name: guildName},
+			Content: fmt.Sprintf("%v (%v) %v %v at %v", name, event.DiscordID, action, guildName, event.At.In(now.Location()).Format(time.RFC1123)),
+		})
+	}
+	return feed
+}
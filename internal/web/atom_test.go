@@ -0,0 +1,60 @@
+package web
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestAtomFeed(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "dul.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	at := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	for i, event := range []store.HistoryEvent{
+		{GuildID: "100", DiscordID: "1", Event: store.EventJoin, User: store.User{Username: "alice", Discriminator: "0"}, At: at},
+		{GuildID: "100", DiscordID: "1", Event: store.EventBoostStart, At: at.Add(time.Minute)},
+		{GuildID: "100", DiscordID: "2", Event: store.EventLeave, At: at.Add(2 * time.Minute)},
+		{GuildID: "200", DiscordID: "3", Event: store.EventJoin, At: at},
+	} {
+		if err := st.RecordEvent(event); err != nil {
+			t.Fatalf("failed to record event %v: %v", i, err)
+		}
+	}
+
+	server, err := New(Options{BaseURL: "https://userlog.example.com", EventsToken: "hunter2"}, st, &fakeDiscord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	if status, _ := get(t, http.DefaultClient, ts.URL+"/feed.atom?guild=100"); status != http.StatusUnauthorized {
+		t.Errorf("expected the feed to require the token, got %v", status)
+	}
+	status, body := get(t, http.DefaultClient, ts.URL+"/feed.atom?guild=100&token=hunter2")
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status %v: %v", status, body)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal([]byte(body), &feed); err != nil {
+		t.Fatal(err)
+	}
+	if feed.Title != "Guild 100 Joins and Leaves" || feed.Link.Href != "https://userlog.example.com/feed.atom?guild=100" || feed.Updated != "2023-07-01T12:02:00Z" {
+		t.Errorf("unexpected feed %+v", feed)
+	}
+	titles := []string{}
+	for _, entry := range feed.Entries {
+		titles = append(titles, entry.Title)
+	}
+	if len(titles) != 2 || titles[0] != "2 left Guild 100" || titles[1] != "alice joined Guild 100" {
+		t.Errorf("unexpected entries %v", titles)
+	}
+}
@@ -0,0 +1,181 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	sessionCookie   = "dul_session"
+	stateCookie     = "dul_oauth_state"
+	sessionDuration = 7 * 24 * time.Hour
+)
+
+// sessionUser is the logged in Discord user, stored in a signed cookie
+type sessionUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Expires  int64  `json:"exp"`
+}
+
+func (s *Server) redirectURL() string {
+	return strings.TrimSuffix(s.options.BaseURL, "/") + "/callback"
+}
+
+func (s *Server) secureCookies() bool {
+	return strings.HasPrefix(s.options.BaseURL, "https://")
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	stateBytes := make([]byte, 16)
+	if _, err := rand.Read(stateBytes); err != nil {
+		s.error(w, http.StatusInternalServerError, "Failed to start logging in.")
+		return
+	}
+	state := hex.EncodeToString(stateBytes)
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   s.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	query := url.Values{
+		"client_id":     {s.options.ClientID},
+		"redirect_uri":  {s.redirectURL()},
+		"response_type": {"code"},
+		"scope":         {"identify"},
+		"state":         {state},
+	}
+	http.Redirect(w, r, s.authorizeURL+"?"+query.Encode(), http.StatusFound)
+}
+
+func (s *Server) callback(w http.ResponseWriter, r *http.Request) {
+	state, err := r.Cookie(stateCookie)
+	if err != nil || state.Value == "" || r.URL.Query().Get("state") != state.Value {
+		s.error(w, http.StatusBadRequest, "Login expired, try again.")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/", MaxAge: -1})
+
+	user, err := s.exchange(r.URL.Query().Get("code"))
+	if err != nil {
+		log.Printf("[web] failed to log in: %v", err)
+		s.error(w, http.StatusBadGateway, "Failed to log in with Discord.")
+		return
+	}
+	user.Expires = time.Now().Add(sessionDuration).Unix()
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    s.sign(user),
+		Path:     "/",
+		MaxAge:   int(sessionDuration / time.Second),
+		HttpOnly: true,
+		Secure:   s.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	log.Printf("[web] '%v' logged in", user.ID)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// exchange trades an authorization code for the identity of the user who logged in
+func (s *Server) exchange(code string) (sessionUser, error) {
+	resp, err := s.httpClient.PostForm(s.tokenURL, url.Values{
+		"client_id":     {s.options.ClientID},
+		"client_secret": {s.options.ClientSecret},
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {s.redirectURL()},
+	})
+	if err != nil {
+		return sessionUser{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return sessionUser{}, fmt.Errorf("unexpected token status %v", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return sessionUser{}, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, s.userURL, nil)
+	if err != nil {
+		return sessionUser{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	resp, err = s.httpClient.Do(req)
+	if err != nil {
+		return sessionUser{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return sessionUser{}, fmt.Errorf("unexpected user status %v", resp.Status)
+	}
+	var user sessionUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return sessionUser{}, err
+	}
+	if user.ID == "" {
+		return sessionUser{}, fmt.Errorf("no user ID returned")
+	}
+	return user, nil
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.error(w, http.StatusMethodNotAllowed, "Log out with the button.")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (s *Server) mac(payload string) string {
+	mac := hmac.New(sha256.New, s.sessionKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sign encodes a user into a cookie value like "<payload>.<signature>"
+func (s *Server) sign(user sessionUser) string {
+	data, _ := json.Marshal(user)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + s.mac(payload)
+}
+
+// session returns the logged in user of a request
+func (s *Server) session(r *http.Request) (sessionUser, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return sessionUser{}, false
+	}
+	payload, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.mac(payload))) {
+		return sessionUser{}, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return sessionUser{}, false
+	}
+	var user sessionUser
+	if err := json.Unmarshal(data, &user); err != nil || time.Now().Unix() > user.Expires {
+		return sessionUser{}, false
+	}
+	return user, true
+}
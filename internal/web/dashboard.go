@@ -0,0 +1,112 @@
+package web
+
+import (
+	"html/template"
+	"sort"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/report"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+const (
+	// growthDays is how far back the growth chart goes
+	growthDays = 90
+	// recentEventCount is how many joins and leaves are listed
+	recentEventCount = 50
+	// memberListLimit caps the member table on large guilds, newest members first
+	memberListLimit = 1000
+	// recentNoteCount is how many moderator notes are listed
+	recentNoteCount = 50
+)
+
+func templateFuncs(location *time.Location) template.FuncMap {
+	return template.FuncMap{
+		"date": func(t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return t.In(location).Format("2006-01-02 15:04")
+		},
+	}
+}
+
+type guildPage struct {
+	User  sessionUser
+	ID    string
+	Name  string
+	Chart template.HTML
+	// MemberCount is the number of known members, Members may be truncated
+	MemberCount int
+	Members     []memberRow
+	Events      []store.HistoryEvent
+	Notes       []noteRow
+}
+
+type memberRow struct {
+	ID string
+	store.Member
+}
+
+// noteRow is a moderator note, User is the tag of the user it is about if they are a member, or their ID
+type noteRow struct {
+	store.Note
+	User string
+}
+
+func (s *Server) guildPage(guildID string, now time.Time) (guildPage, error) {
+	members, err := s.store.Members(guildID)
+	if err != nil {
+		return guildPage{}, err
+	}
+	since := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -growthDays+1)
+	counts, err := s.store.MemberCountHistory(guildID, len(members), since, growthDays)
+	if err != nil {
+		return guildPage{}, err
+	}
+	events, err := s.store.RecentEvents(guildID, []string{store.EventJoin, store.EventLeave}, recentEventCount, 0)
+	if err != nil {
+		return guildPage{}, err
+	}
+
+	notes, err := s.store.RecentNotes(guildID, recentNoteCount)
+	if err != nil {
+		return guildPage{}, err
+	}
+	noteRows := make([]noteRow, len(notes))
+	for i, note := range notes {
+		noteRows[i] = noteRow{Note: note, User: note.DiscordID}
+		if tag := members[note.DiscordID].User.Tag(); tag != "" {
+			noteRows[i].User = tag
+		}
+	}
+
+	rows := make([]memberRow, 0, len(members))
+	for discordID, member := range members {
+		rows = append(rows, memberRow{ID: discordID, Member: member})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].JoinedAt.Equal(rows[j].JoinedAt) {
+			return rows[i].JoinedAt.After(rows[j].JoinedAt)
+		}
+		return rows[i].ID < rows[j].ID
+	})
+	if len(rows) > memberListLimit {
+		rows = rows[:memberListLimit]
+	}
+
+	return guildPage{
+		ID:          guildID,
+		Chart:       report.Chart(counts),
+		MemberCount: len(members),
+		Members:     rows,
+		Events:      events,
+		Notes:       noteRows,
+	}, nil
+}
+
+func sortGuildLinks(guilds []guildLink) {
+	sort.Slice(guilds, func(i, j int) bool {
+		return guilds[i].Name < guilds[j].Name
+	})
+}
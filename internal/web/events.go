@@ -0,0 +1,63 @@
+package web
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Events streams the JSON of published events, it is implemented by *feed.Broker
+type Events interface {
+	Subscribe(guildID string) (events <-chan []byte, cancel func())
+}
+
+// eventsKeepalive is how often an idle stream sends a comment, so proxies don't close it
+const eventsKeepalive = 30 * time.Second
+
+// events streams events as server-sent events, one JSON event per message.
+// The optional guild query parameter limits the stream to one guild.
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	if !s.eventsAuthorized(r) {
+		http.Error(w, "invalid events token", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, cancel := s.options.Events.Subscribe(r.URL.Query().Get("guild"))
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventsKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case event := <-events:
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		flusher.Flush()
+	}
+}
+
+// eventsAuthorized checks the token from the Authorization header, or the token query parameter for clients like EventSource that can't set headers
+func (s *Server) eventsAuthorized(r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		token = strings.TrimPrefix(header, "Bearer ")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.options.EventsToken)) == 1
+}
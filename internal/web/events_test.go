@@ -0,0 +1,65 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/feed"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestEventStream(t *testing.T) {
+	broker := feed.NewBroker()
+	server, err := New(Options{Events: broker, EventsToken: "hunter2"}, nil, &fakeDiscord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	// without OAuth2 only the stream is served
+	if status, _ := get(t, http.DefaultClient, ts.URL+"/"); status != http.StatusNotFound {
+		t.Errorf("expected the dashboard to be disabled, got %v", status)
+	}
+	for _, url := range []string{ts.URL + "/events", ts.URL + "/events?token=wrong"} {
+		if status, _ := get(t, http.DefaultClient, url); status != http.StatusUnauthorized {
+			t.Errorf("expected %v to be unauthorized, got %v", url, status)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/events?guild=100", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer hunter2")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response %v %v", resp.StatusCode, resp.Header)
+	}
+
+	at := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	broker.Publish(notify.Event{Type: store.EventLeave, GuildID: "200", UserID: "2", At: at})
+	broker.Publish(notify.Event{Type: store.EventJoin, GuildID: "100", UserID: "1", At: at, MemberCount: 5})
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event feed.Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+		t.Fatalf("failed to decode %q: %v", line, err)
+	}
+	if event.Type != store.EventJoin || event.UserID != "1" || event.MemberCount != 5 {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
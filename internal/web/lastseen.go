@@ -0,0 +1,60 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+type lastSeenResponse struct {
+	GuildID string           `json:"guild_id"`
+	Members []lastSeenMember `json:"members"`
+}
+
+type lastSeenMember struct {
+	UserID string `json:"user_id"`
+	Online bool   `json:"online"`
+	// OnlineSince is left out for offline members, and members never seen coming online
+	OnlineSince *time.Time `json:"online_since,omitempty"`
+	LastSeen    time.Time  `json:"last_seen"`
+}
+
+// lastSeen serves when the members of the guild in the guild query parameter were last seen online as JSON, with the events token.
+// The user query parameter limits it to one member.
+func (s *Server) lastSeen(w http.ResponseWriter, r *http.Request) {
+	if !s.eventsAuthorized(r) {
+		http.Error(w, "invalid events token", http.StatusUnauthorized)
+		return
+	}
+	guildID := r.URL.Query().Get("guild")
+	if guildID == "" {
+		http.Error(w, "the guild parameter is required", http.StatusBadRequest)
+		return
+	}
+	userID := r.URL.Query().Get("user")
+
+	presences, err := s.store.Presences(guildID)
+	if err != nil {
+		log.Printf("[web] failed to load the presences of guild '%v': %v", guildID, err)
+		http.Error(w, "failed to load presences", http.StatusInternalServerError)
+		return
+	}
+	response := lastSeenResponse{GuildID: guildID, Members: []lastSeenMember{}}
+	for _, presence := range presences {
+		if userID != "" && presence.DiscordID != userID {
+			continue
+		}
+		member := lastSeenMember{UserID: presence.DiscordID, Online: presence.Online, LastSeen: presence.LastSeen.UTC()}
+		if presence.Online && !presence.OnlineSince.IsZero() {
+			onlineSince := presence.OnlineSince.UTC()
+			member.OnlineSince = &onlineSince
+		}
+		response.Members = append(response.Members, member)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("[web] failed to write the presences of guild '%v': %v", guildID, err)
+	}
+}
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestLastSeen(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "dul.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	at := time.Unix(1700000000, 0)
+	for _, presence := range []struct {
+		userID string
+		online bool
+		at     time.Time
+	}{
+		{"1", true, at},
+		{"2", true, at},
+		{"2", false, at.Add(time.Hour)},
+	} {
+		if err := st.RecordPresence("100", presence.userID, presence.online, presence.at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server, err := New(Options{EventsToken: "hunter2"}, st, &fakeDiscord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	if status, _ := get(t, http.DefaultClient, ts.URL+"/lastseen.json?guild=100"); status != http.StatusUnauthorized {
+		t.Errorf("expected the last seen API to require the token, got %v", status)
+	}
+	status, body := get(t, http.DefaultClient, ts.URL+"/lastseen.json?guild=100&token=hunter2")
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status %v: %v", status, body)
+	}
+	var response lastSeenResponse
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.GuildID != "100" || len(response.Members) != 2 {
+		t.Fatalf("unexpected response %v", body)
+	}
+	if online := response.Members[0]; !online.Online || online.OnlineSince == nil || !online.OnlineSince.Equal(at) {
+		t.Errorf("unexpected online member %v", body)
+	}
+	if offline := response.Members[1]; offline.Online || offline.OnlineSince != nil || !offline.LastSeen.Equal(at.Add(time.Hour)) {
+		t.Errorf("unexpected offline member %v", body)
+	}
+
+	_, body = get(t, http.DefaultClient, ts.URL+"/lastseen.json?guild=100&user=2&token=hunter2")
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Members) != 1 || response.Members[0].UserID != "2" {
+		t.Errorf("expected only the requested user, got %v", body)
+	}
+}
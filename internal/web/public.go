@@ -0,0 +1,132 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+const (
+	// sparklineDays is how far back the growth sparkline of the public stats goes
+	sparklineDays = 30
+	// publicStatsCacheDuration is how long public stats are reused, so unauthenticated visitors can't load the database
+	publicStatsCacheDuration = 5 * time.Minute
+)
+
+type publicPage struct {
+	Name        string
+	MemberCount int
+	Sparkline   template.HTML
+	// JoinsThisWeek and LeavesThisWeek count since Monday midnight
+	JoinsThisWeek  int
+	LeavesThisWeek int
+}
+
+type publicEntry struct {
+	page    publicPage
+	expires time.Time
+}
+
+// publicStats serves a guild's member count, growth, and this week's joins and leaves without logging in, for embedding in iframes
+func (s *Server) publicStats(w http.ResponseWriter, r *http.Request) {
+	guildID := strings.TrimPrefix(r.URL.Path, "/public/")
+	if _, ok := s.options.GuildRoles[guildID]; !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	now := time.Now()
+	s.lock.Lock()
+	entry, ok := s.publicCache[guildID]
+	s.lock.Unlock()
+	if !ok || now.After(entry.expires) {
+		page, err := s.publicPage(guildID, now)
+		if err != nil {
+			log.Printf("[web] failed to load public stats of guild '%v': %v", guildID, err)
+			http.Error(w, "failed to load stats", http.StatusInternalServerError)
+			return
+		}
+		page.Name = s.guildName(guildID)
+		entry = publicEntry{page: page, expires: now.Add(publicStatsCacheDuration)}
+		s.lock.Lock()
+		s.publicCache[guildID] = entry
+		s.lock.Unlock()
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%v", int(publicStatsCacheDuration.Seconds())))
+	s.render(w, http.StatusOK, "public.html", entry.page)
+}
+
+func (s *Server) publicPage(guildID string, now time.Time) (publicPage, error) {
+	members, err := s.store.Members(guildID)
+	if err != nil {
+		return publicPage{}, err
+	}
+	since := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -sparklineDays+1)
+	counts, err := s.store.MemberCountHistory(guildID, len(members), since, sparklineDays)
+	if err != nil {
+		return publicPage{}, err
+	}
+	weekStart := startOfWeek(now.In(s.options.Location))
+	joins, err := s.store.CountEvents(guildID, store.EventJoin, weekStart)
+	if err != nil {
+		return publicPage{}, err
+	}
+	leaves, err := s.store.CountEvents(guildID, store.EventLeave, weekStart)
+	if err != nil {
+		return publicPage{}, err
+	}
+	return publicPage{
+		MemberCount:    len(members),
+		Sparkline:      sparkline(counts),
+		JoinsThisWeek:  joins,
+		LeavesThisWeek: leaves,
+	}, nil
+}
+
+// startOfWeek returns Monday midnight of the week of t, in its location
+func startOfWeek(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, t.Location())
+}
+
+// sparkline draws member counts as a small inline SVG line without labels
+func sparkline(counts []store.DayCount) template.HTML {
+	const (
+		width  = 120
+		height = 30
+	)
+	if len(counts) < 2 {
+		return ""
+	}
+	min, max := counts[0].Count, counts[0].Count
+	for _, count := range counts {
+		if count.Count < min {
+			min = count.Count
+		}
+		if count.Count > max {
+			max = count.Count
+		}
+	}
+	if max == min {
+		max = min + 1
+	}
+
+	points := make([]string, len(counts))
+	for i, count := range counts {
+		x := float64(i) * width / float64(len(counts)-1)
+		y := 1 + float64(max-count.Count)*(height-2)/float64(max-min)
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	// every value is a number, nothing needs escaping
+	return template.HTML(fmt.Sprintf(
+		`<svg class="sparkline" viewBox="0 0 %v %v" role="img" aria-label="Member count over the last %v days">`+
+			`<polyline fill="none" stroke="currentColor" stroke-width="1.5" points="%v"/></svg>`,
+		width, height, sparklineDays, strings.Join(points, " "),
+	))
+}
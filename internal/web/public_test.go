@@ -0,0 +1,68 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestPublicStats(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "dul.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	for _, id := range []string{"1", "2"} {
+		if err := st.AddMember("100", id, store.Member{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.RecordEvent(store.HistoryEvent{GuildID: "100", DiscordID: id, Event: store.EventJoin, At: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server, err := New(Options{GuildRoles: map[string]string{"100": "mods"}, PublicStats: true}, st, &fakeDiscord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	status, body := get(t, http.DefaultClient, ts.URL+"/public/100")
+	if status != http.StatusOK {
+		t.Fatalf("expected the stats without logging in, got %v", status)
+	}
+	for _, expected := range []string{"Guild 100", "2 members", "+2 joined", "0 left"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in %v", expected, body)
+		}
+	}
+	if status, _ := get(t, http.DefaultClient, ts.URL+"/public/200"); status != http.StatusNotFound {
+		t.Errorf("expected guilds that aren't tracked to be hidden, got %v", status)
+	}
+	// the dashboard isn't configured
+	if status, _ := get(t, http.DefaultClient, ts.URL+"/"); status != http.StatusNotFound {
+		t.Errorf("expected no dashboard, got %v", status)
+	}
+}
+
+func TestStartOfWeek(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, tc := range []struct{ at, expected time.Time }{
+		{time.Date(2023, 7, 5, 12, 0, 0, 0, berlin), time.Date(2023, 7, 3, 0, 0, 0, 0, berlin)},
+		{time.Date(2023, 7, 3, 0, 0, 0, 0, berlin), time.Date(2023, 7, 3, 0, 0, 0, 0, berlin)},
+		{time.Date(2023, 7, 9, 23, 59, 0, 0, berlin), time.Date(2023, 7, 3, 0, 0, 0, 0, berlin)},
+	} {
+		if actual := startOfWeek(tc.at); !actual.Equal(tc.expected) {
+			t.Errorf("expected %v for %v, got %v", tc.expected, tc.at, actual)
+		}
+	}
+}
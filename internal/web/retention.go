@@ -0,0 +1,47 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/retention"
+)
+
+type retentionResponse struct {
+	GuildID string `json:"guild_id"`
+	retention.Stats
+	// MedianStaySeconds is retention.Stats.MedianStay, which JSON would show in nanoseconds
+	MedianStaySeconds int64 `json:"median_stay_seconds"`
+}
+
+// retention serves the retention metrics of the guild in the guild query parameter as JSON, with the events token
+func (s *Server) retention(w http.ResponseWriter, r *http.Request) {
+	if !s.eventsAuthorized(r) {
+		http.Error(w, "invalid events token", http.StatusUnauthorized)
+		return
+	}
+	guildID := r.URL.Query().Get("guild")
+	if guildID == "" {
+		http.Error(w, "the guild parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := retention.Compute(s.store, guildID, time.Now().In(s.options.Location))
+	if err != nil {
+		log.Printf("[web] failed to compute the retention of guild '%v': %v", guildID, err)
+		http.Error(w, "failed to load retention", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(retentionResponse{
+		GuildID:           guildID,
+		Stats:             stats,
+		MedianStaySeconds: int64(stats.MedianStay / time.Second),
+	})
+	if err != nil {
+		log.Printf("[web] failed to write the retention of guild '%v': %v", guildID, err)
+	}
+}
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+func TestRetention(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "dul.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	at := time.Now().AddDate(0, 0, -40)
+	for i, event := range []store.HistoryEvent{
+		{GuildID: "100", DiscordID: "1", Event: store.EventJoin, At: at},
+		{GuildID: "100", DiscordID: "2", Event: store.EventJoin, At: at},
+		{GuildID: "100", DiscordID: "2", Event: store.EventLeave, At: at.Add(time.Hour)},
+	} {
+		if err := st.RecordEvent(event); err != nil {
+			t.Fatalf("failed to record event %v: %v", i, err)
+		}
+	}
+
+	server, err := New(Options{EventsToken: "hunter2"}, st, &fakeDiscord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	if status, _ := get(t, http.DefaultClient, ts.URL+"/retention.json?guild=100"); status != http.StatusUnauthorized {
+		t.Errorf("expected the retention API to require the token, got %v", status)
+	}
+	status, body := get(t, http.DefaultClient, ts.URL+"/retention.json?guild=100&token=hunter2")
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status %v: %v", status, body)
+	}
+	var response struct {
+		GuildID           string `json:"guild_id"`
+		Cohorts           []struct{ Joins, Remaining int }
+		Day30             struct{ Joins, Retained int } `json:"day_30"`
+		Left              int
+		MedianStaySeconds int64 `json:"median_stay_seconds"`
+	}
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.GuildID != "100" || len(response.Cohorts) != 6 || response.Day30.Joins != 2 || response.Day30.Retained != 1 || response.Left != 1 || response.MedianStaySeconds != 3600 {
+		t.Errorf("unexpected response %v", body)
+	}
+}
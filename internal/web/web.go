@@ -0,0 +1,266 @@
+// Package web serves a read-only dashboard of the tracked guilds, behind Discord OAuth2 login,
+// a token-protected stream and Atom feed of member events, and optionally public stats pages.
+package web
+
+import (
+	"crypto/rand"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+//go:embed static
+var staticFS embed.FS
+
+// Options configure the dashboard
+type Options struct {
+	// BaseURL is where the dashboard is reachable from browsers, like "https://userlog.example.com"
+	BaseURL      string
+	ClientID     string
+	ClientSecret string
+	// GuildRoles maps the guilds shown on the dashboard to the role required to view them
+	GuildRoles map[string]string
+	// Location shows dates, nil is UTC
+	Location *time.Location
+
+	// PublicStats serves /public/<guild ID>, a page of each guild's member count and growth without logging in
+	PublicStats bool
+
+	// Events are streamed from /events to clients with EventsToken, which also protects /feed.atom.
+	// An empty token disables both.
+	Events      Events
+	EventsToken string
+}
+
+// Store is the subset of *store.Store shown on the dashboard
+type Store interface {
+	Members(guildID string) (map[string]store.Member, error)
+	RecentEvents(guildID string, events []string, limit, offset int) ([]store.HistoryEvent, error)
+	MemberCountHistory(guildID string, current int, since time.Time, days int) ([]store.DayCount, error)
+	Stays(guildID string, since time.Time) ([]store.Stay, error)
+	CountEvents(guildID, event string, since time.Time) (int, error)
+	Presences(guildID string) ([]store.Presence, error)
+	RecentNotes(guildID string, limit int) ([]store.Note, error)
+}
+
+// Discord is the subset of *discordgo.Session used to look up guilds and check roles
+type Discord interface {
+	Guild(guildID string) (*discordgo.Guild, error)
+	GuildMember(guildID, userID string) (*discordgo.Member, error)
+}
+
+// accessCacheDuration is how long role checks are cached, removing the role takes effect after this
+const accessCacheDuration = 5 * time.Minute
+
+// Server serves the dashboard
+type Server struct {
+	options   Options
+	store     Store
+	discord   Discord
+	templates *template.Template
+	// sessionKey signs session cookies, sessions end when the bot restarts
+	sessionKey []byte
+
+	// oauth endpoints, replaced in tests
+	authorizeURL string
+	tokenURL     string
+	userURL      string
+
+	lock        sync.Mutex
+	access      map[string]accessEntry
+	guildNames  map[string]string
+	publicCache map[string]publicEntry
+	httpClient  *http.Client
+	staticFiles http.Handler
+}
+
+type accessEntry struct {
+	allowed bool
+	expires time.Time
+}
+
+func New(options Options, store Store, discord Discord) (*Server, error) {
+	if options.Location == nil {
+		options.Location = time.UTC
+	}
+	templates, err := template.New("").Funcs(templateFuncs(options.Location)).ParseFS(templateFS, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	sessionKey := make([]byte, 32)
+	if _, err := rand.Read(sessionKey); err != nil {
+		return nil, err
+	}
+	static, err := fs.Sub(staticFS, "static")
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		options:      options,
+		store:        store,
+		discord:      discord,
+		templates:    templates,
+		sessionKey:   sessionKey,
+		authorizeURL: "https://discord.com/oauth2/authorize",
+		tokenURL:     discordgo.EndpointAPI + "oauth2/token",
+		userURL:      discordgo.EndpointUsers + "@me",
+		access:       map[string]accessEntry{},
+		guildNames:   map[string]string{},
+		publicCache:  map[string]publicEntry{},
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		staticFiles:  http.StripPrefix("/static/", http.FileServer(http.FS(static))),
+	}, nil
+}
+
+// Handler routes the dashboard pages, if OAuth2 is configured, the public stats, if they are enabled,
+// and the event stream, feed, retention, and last seen APIs, if they have a token
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	if s.options.ClientID != "" {
+		mux.HandleFunc("/", s.index)
+		mux.HandleFunc("/login", s.login)
+		mux.HandleFunc("/callback", s.callback)
+		mux.HandleFunc("/logout", s.logout)
+		mux.HandleFunc("/guilds/", s.guild)
+		mux.Handle("/static/", s.staticFiles)
+	}
+	if s.options.PublicStats {
+		mux.HandleFunc("/public/", s.publicStats)
+	}
+	if s.options.EventsToken != "" {
+		mux.HandleFunc("/feed.atom", s.atom)
+		mux.HandleFunc("/retention.json", s.retention)
+		mux.HandleFunc("/lastseen.json", s.lastSeen)
+		if s.options.Events != nil {
+			mux.HandleFunc("/events", s.events)
+		}
+	}
+	return mux
+}
+
+func (s *Server) render(w http.ResponseWriter, status int, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := s.templates.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("[web] failed to render %v: %v", name, err)
+	}
+}
+
+func (s *Server) error(w http.ResponseWriter, status int, message string) {
+	s.render(w, status, "error.html", struct{ Message string }{message})
+}
+
+// canView reports whether a user has the role required to view a guild
+func (s *Server) canView(userID, guildID string) bool {
+	roleID, ok := s.options.GuildRoles[guildID]
+	if !ok || roleID == "" {
+		return false
+	}
+
+	key := userID + ":" + guildID
+	s.lock.Lock()
+	entry, cached := s.access[key]
+	s.lock.Unlock()
+	if cached && time.Now().Before(entry.expires) {
+		return entry.allowed
+	}
+
+	allowed := false
+	member, err := s.discord.GuildMember(guildID, userID)
+	if err == nil {
+		for _, memberRoleID := range member.Roles {
+			if memberRoleID == roleID {
+				allowed = true
+				break
+			}
+		}
+	}
+	s.lock.Lock()
+	s.access[key] = accessEntry{allowed: allowed, expires: time.Now().Add(accessCacheDuration)}
+	s.lock.Unlock()
+	return allowed
+}
+
+// guildName looks up the name of a guild, falling back to its ID
+func (s *Server) guildName(guildID string) string {
+	s.lock.Lock()
+	name, ok := s.guildNames[guildID]
+	s.lock.Unlock()
+	if ok {
+		return name
+	}
+
+	guild, err := s.discord.Guild(guildID)
+	if err != nil {
+		log.Printf("[web] failed to look up guild '%v': %v", guildID, err)
+		return guildID
+	}
+	s.lock.Lock()
+	s.guildNames[guildID] = guild.Name
+	s.lock.Unlock()
+	return guild.Name
+}
+
+type guildLink struct {
+	ID   string
+	Name string
+}
+
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		s.error(w, http.StatusNotFound, "Page not found.")
+		return
+	}
+	user, ok := s.session(r)
+	if !ok {
+		s.render(w, http.StatusOK, "login.html", nil)
+		return
+	}
+
+	guilds := []guildLink{}
+	for guildID := range s.options.GuildRoles {
+		if s.canView(user.ID, guildID) {
+			guilds = append(guilds, guildLink{ID: guildID, Name: s.guildName(guildID)})
+		}
+	}
+	sortGuildLinks(guilds)
+	s.render(w, http.StatusOK, "index.html", struct {
+		User   sessionUser
+		Guilds []guildLink
+	}{user, guilds})
+}
+
+func (s *Server) guild(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.session(r)
+	if !ok {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	guildID := strings.TrimPrefix(r.URL.Path, "/guilds/")
+	if !s.canView(user.ID, guildID) {
+		s.error(w, http.StatusForbidden, "You don't have access to this server.")
+		return
+	}
+
+	page, err := s.guildPage(guildID, time.Now())
+	if err != nil {
+		log.Printf("[web] failed to load dashboard of guild '%v': %v", guildID, err)
+		s.error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to load the dashboard of %v.", guildID))
+		return
+	}
+	page.User = user
+	page.Name = s.guildName(guildID)
+	s.render(w, http.StatusOK, "guild.html", page)
+}
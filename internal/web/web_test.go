@@ -0,0 +1,184 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+type fakeDiscord struct {
+	roles map[string][]string
+}
+
+func (f *fakeDiscord) Guild(guildID string) (*discordgo.Guild, error) {
+	return &discordgo.Guild{ID: guildID, Name: "Guild " + guildID}, nil
+}
+
+func (f *fakeDiscord) GuildMember(guildID, userID string) (*discordgo.Member, error) {
+	roles, ok := f.roles[guildID+":"+userID]
+	if !ok {
+		return nil, errors.New("unknown member")
+	}
+	return &discordgo.Member{Roles: roles}, nil
+}
+
+// fakeOAuth serves the Discord token and user endpoints, the code is the user ID
+func fakeOAuth(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.FormValue("client_secret") != "secret" {
+				http.Error(w, "bad secret", http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "token-" + r.FormValue("code")})
+		case "/users/@me":
+			userID := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer token-")
+			json.NewEncoder(w).Encode(map[string]string{"id": userID, "username": "user" + userID})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *store.Store) {
+	t.Helper()
+	st, err := store.Open(filepath.Join(t.TempDir(), "dul.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	oauth := fakeOAuth(t)
+	t.Cleanup(oauth.Close)
+
+	discord := &fakeDiscord{roles: map[string][]string{
+		"100:1": {"mods"},
+		"100:2": {"everyone"},
+	}}
+	server, err := New(Options{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		GuildRoles:   map[string]string{"100": "mods"},
+	}, st, discord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.tokenURL = oauth.URL + "/token"
+	server.userURL = oauth.URL + "/users/@me"
+
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	server.options.BaseURL = ts.URL
+	// log in by "authorizing" straight back to our callback, with the user ID as the code
+	server.authorizeURL = ts.URL + "/test-authorize"
+	return ts, st
+}
+
+// loginAs returns a client with a session for a user
+func loginAs(t *testing.T, ts *httptest.Server, userID string) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar, CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if req.URL.Path == "/test-authorize" {
+			req.URL.Path = "/callback"
+			req.URL.RawQuery = "code=" + userID + "&state=" + req.URL.Query().Get("state")
+		}
+		return nil
+	}}
+	resp, err := client.Get(ts.URL + "/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return client
+}
+
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestDashboardRequiresRole(t *testing.T) {
+	ts, st := newTestServer(t)
+	if err := st.AddMember("100", "5", store.Member{User: store.User{Username: "alice", Discriminator: "0"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.AddNote("100", store.Note{DiscordID: "5", Text: "asked about <b>raids</b>", AuthorID: "1", At: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, body := get(t, http.DefaultClient, ts.URL+"/"); status != http.StatusOK || !strings.Contains(body, "Log in with Discord") {
+		t.Errorf("expected the login page, got %v %q", status, body)
+	}
+
+	moderator := loginAs(t, ts, "1")
+	if _, body := get(t, moderator, ts.URL+"/"); !strings.Contains(body, `<a href="/guilds/100">Guild 100</a>`) {
+		t.Errorf("expected a link to the guild, got %q", body)
+	}
+	if status, body := get(t, moderator, ts.URL+"/guilds/100"); status != http.StatusOK || !strings.Contains(body, "alice") || !strings.Contains(body, "<svg") {
+		t.Errorf("expected the dashboard, got %v %q", status, body)
+	}
+	if _, body := get(t, moderator, ts.URL+"/guilds/100"); !strings.Contains(body, "asked about &lt;b&gt;raids&lt;/b&gt;") {
+		t.Errorf("expected the escaped note on the dashboard, got %q", body)
+	}
+
+	member := loginAs(t, ts, "2")
+	if _, body := get(t, member, ts.URL+"/"); !strings.Contains(body, "You don't have access to any servers.") {
+		t.Errorf("expected no guilds, got %q", body)
+	}
+	if status, _ := get(t, member, ts.URL+"/guilds/100"); status != http.StatusForbidden {
+		t.Errorf("expected forbidden, got %v", status)
+	}
+}
+
+func TestForgedSessionsAreRejected(t *testing.T) {
+	server, err := New(Options{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := server.sign(sessionUser{ID: "1", Expires: time.Now().Add(time.Hour).Unix()})
+	other, err := New(Options{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		server   *Server
+		value    string
+		expected bool
+	}{
+		{server, value, true},
+		{other, value, false},
+		{server, strings.Replace(value, ".", "x.", 1), false},
+		{server, server.sign(sessionUser{ID: "1", Expires: time.Now().Add(-time.Hour).Unix()}), false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: sessionCookie, Value: test.value})
+		if _, ok := test.server.session(r); ok != test.expected {
+			t.Errorf("session(%q) = %v, expected %v", test.value, ok, test.expected)
+		}
+	}
+}
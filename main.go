@@ -1,364 +1,598 @@
 package main
 
 import (
-	"database/sql"
-	"embed"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
-	"path"
-	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3"
+	_ "time/tzdata" // quiet hours timezones, the container has no zoneinfo
 
 	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/bot"
+	"go.albinodrought/discord-user-log/internal/bus"
+	"go.albinodrought/discord-user-log/internal/feed"
+	"go.albinodrought/discord-user-log/internal/leader"
+	"go.albinodrought/discord-user-log/internal/maintenance"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/report"
+	"go.albinodrought/discord-user-log/internal/rpc"
+	"go.albinodrought/discord-user-log/internal/store"
+	"go.albinodrought/discord-user-log/internal/systemd"
+	"go.albinodrought/discord-user-log/internal/telemetry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
-var (
-	guildID, channelID string
+// shutdownTimeout bounds how long closing may take before exiting anyway
+const shutdownTimeout = 15 * time.Second
 
-	knownMemberStateLock  sync.RWMutex
-	knownMemberState      map[string]discordUser
-	knownMemberStateEmpty bool
+// staleHeartbeat is how long a shard may go without an acknowledged heartbeat before the systemd watchdog is no longer pinged.
+// It is longer than discordgo takes to notice missed heartbeats and reconnect.
+const staleHeartbeat = 5 * time.Minute
 
-	db                              *sql.DB
-	stmtAdd, stmtUpdate, stmtRemove *sql.Stmt
-)
+// historyRetention is the current retention as a time.Duration, it changes when the config is reloaded
+var historyRetention int64
 
-type discordUser struct {
-	username      string
-	discriminator string
-}
+// anonymizeAfter is how long after leaving former members are anonymized as a time.Duration, it changes when the config is reloaded
+var anonymizeAfter int64
+
+// syncSlices is how many ranges scheduled syncs are spread over, it changes when the config is reloaded
+var syncSlices int64
 
 func main() {
-	var err error
+	configPath := flag.String("config", os.Getenv("DUL_CONFIG"), "path to a YAML config file (DUL_CONFIG)")
+	// exits on errors and -help
+	parseFlags(flag.CommandLine, os.Args[1:])
 
-	authenticationToken := os.Getenv("DUL_TOKEN")
-	guildID = os.Getenv("DUL_GUILD_ID")
-	channelID = os.Getenv("DUL_CHANNEL_ID")
-	statePath := os.Getenv("DUL_STATE_PATH")
-	if statePath == "" {
-		statePath = "./dul.db"
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
 	}
 
-	if authenticationToken == "" || guildID == "" || channelID == "" {
-		log.Fatal("require DUL_TOKEN, DUL_GUILD_ID, DUL_CHANNEL_ID")
+	// migrate manages the schema itself, opening the store would apply every migration first
+	if flag.Arg(0) == "migrate" {
+		runMigrate(cfg.StatePath, cfg.DBKey, flag.Args()[1:])
+		return
 	}
-
-	db, err = sql.Open("sqlite3", statePath)
-	if err != nil {
-		log.Fatalf("failed to open sqlite db at %v: %v", statePath, err)
+	// migrate-store opens the databases it is given instead of the configured one
+	if flag.Arg(0) == "migrate-store" {
+		runMigrateStore(cfg, flag.Args()[1:])
+		return
 	}
-	defer db.Close()
-
-	if err := migrate(db); err != nil {
-		log.Fatalf("failed to migrate: %v", err)
+	// doctor reports problems opening the store instead of failing on them
+	if flag.Arg(0) == "doctor" {
+		runDoctor(cfg)
+		return
 	}
 
-	stmtAdd, err = db.Prepare("INSERT INTO members(discord_id, discord_username, discord_discriminator) VALUES (?, ?, ?)")
-	if err != nil {
-		log.Fatalf("failed to prepare INSERT statement: %v", err)
+	// validating the config fails on recovering a Postgres database
+	if cfg.Maintenance.Recover && flag.NArg() == 0 && !store.IsPostgres(cfg.StatePath) {
+		recoverStore(cfg)
 	}
-
-	stmtUpdate, err = db.Prepare("UPDATE members SET discord_username = ?, discord_discriminator = ? WHERE discord_id = ?")
+	st, err := store.OpenEncrypted(cfg.StatePath, cfg.DBKey)
 	if err != nil {
-		log.Fatalf("failed to prepare UPDATE statement: %v", err)
+		log.Fatalf("failed to open the database at %v: %v", store.Redact(cfg.StatePath), err)
 	}
+	defer st.Close()
 
-	stmtRemove, err = db.Prepare("DELETE FROM members WHERE discord_id = ?")
-	if err != nil {
-		log.Fatalf("failed to prepare DELETE statement: %v", err)
+	if flag.NArg() > 0 {
+		runCommand(cfg, st, flag.Arg(0), flag.Args()[1:])
+		return
 	}
 
-	// load members from persistent storage
-	knownMemberState = map[string]discordUser{}
-	{
-		rows, err := db.Query("SELECT discord_id, discord_username, discord_discriminator FROM members")
+	if err := cfg.validate(); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Telemetry.Endpoint != "" {
+		// enabled before connecting, so the first requests to Discord are traced too
+		exporter, err := telemetry.New(cfg.Telemetry.Endpoint, cfg.Telemetry.Headers, "user-log")
 		if err != nil {
-			log.Fatalf("failed to query members: %v", err)
+			log.Fatalf("failed to set up telemetry: %v", err)
 		}
+		telemetry.Enable(exporter)
+		defer exporter.Shutdown()
+	}
 
-		var discordID string
-		for rows.Next() {
-			discordUser := discordUser{}
-			if err = rows.Scan(&discordID, &discordUser.username, &discordUser.discriminator); err != nil {
-				log.Fatalf("failed scanning discord ID from row: %v", err)
-			}
-			knownMemberState[discordID] = discordUser
+	// the event bus and its consumers outlive leadership terms, only the leader has events to publish
+	eventBus := bus.New()
+	eventBus.Disable(cfg.DisabledConsumers)
+	setConsumerFilters(eventBus, cfg)
+	events := feed.NewBroker()
+	if cfg.Web.Listen != "" && cfg.Web.EventsToken != "" {
+		eventBus.Register(consumerEventStream, events)
+	}
+	if cfg.EventLog != "" {
+		eventLog, err := feed.OpenFile(cfg.EventLog, int64(cfg.EventLogMaxMB)*1024*1024)
+		if err != nil {
+			log.Fatalf("failed to open event log %v: %v", cfg.EventLog, err)
 		}
-
-		rows.Close()
-		loadedCount := len(knownMemberState)
-		if loadedCount == 0 {
-			knownMemberStateEmpty = true
-			log.Println("loaded no members from DB, assuming first time load, squelching notifications")
-		} else {
-			knownMemberStateEmpty = false
-			log.Printf("loaded %v members from DB", loadedCount)
+		defer eventLog.Close()
+		eventBus.Register(consumerEventLog, eventLog)
+	}
+	location, _ := cfg.timezoneFor(guildConfig{})
+	pushTemplates, _ := cfg.templatesFor(guildConfig{})
+	pushTemplates = pushTemplates.In(location)
+	if cfg.DryRun {
+		log.Println("Dry run: announcements and role changes are only logged, push notifications and reports are off")
+	}
+	if cfg.Push.NtfyURL != "" && !cfg.DryRun {
+		ntfy := notify.NewNtfy(cfg.Push.NtfyURL, cfg.Push.NtfyToken, pushTemplates)
+		eventBus.Register(consumerNtfy, feed.NewForwarder("ntfy", ntfy, cfg.Push.Events))
+	}
+	if cfg.Push.PushoverToken != "" && !cfg.DryRun {
+		pushover := notify.NewPushover(cfg.Push.PushoverToken, cfg.Push.PushoverUser, pushTemplates)
+		eventBus.Register(consumerPushover, feed.NewForwarder("pushover", pushover, cfg.Push.Events))
+	}
+	if cfg.Publish.MQTTURL != "" {
+		mqtt, err := feed.NewMQTT(cfg.Publish.MQTTURL, cfg.Publish.MQTTTopic)
+		if err != nil {
+			log.Fatalf("failed to set up MQTT publishing: %v", err)
 		}
+		eventBus.Register(consumerMQTT, mqtt)
 	}
-
-	session, err := discordgo.New("Bot " + authenticationToken)
-	if err != nil {
-		log.Fatal("failed to create discord session: ", err)
+	if cfg.Publish.NATSURL != "" {
+		nats, err := feed.NewNATS(cfg.Publish.NATSURL, cfg.Publish.NATSSubject)
+		if err != nil {
+			log.Fatalf("failed to set up NATS publishing: %v", err)
+		}
+		eventBus.Register(consumerNATS, nats)
+	}
+	var api *rpc.Server
+	if cfg.GRPC.Listen != "" {
+		creds, err := credentials.NewServerTLSFromFile(cfg.GRPC.CertFile, cfg.GRPC.KeyFile)
+		if err != nil {
+			log.Fatalf("failed to load the gRPC TLS certificate: %v", err)
+		}
+		api = rpc.New(st, cfg.GRPC.Token, grpc.Creds(creds))
+		eventBus.Register(consumerGRPC, api)
+	}
+	for _, webhook := range cfg.Webhooks {
+		var consumer bus.Consumer = feed.NewWebhook(webhook.URL, webhook.Secret, webhook.Events, st)
+		if webhookFilter, _ := webhook.filter(); webhookFilter != nil {
+			consumer = bus.Filtered(webhookFilter, consumer)
+		}
+		eventBus.Register(consumerWebhooks, consumer)
+	}
+	if enabled := eventBus.Enabled(); len(enabled) > 0 {
+		log.Printf("Publishing events to %v", strings.Join(enabled, ", "))
 	}
-	session.AddHandler(ready)
-	session.AddHandler(guildMemberAdd)
-	session.AddHandler(guildMemberRemove)
 
-	session.Identify.Intents = discordgo.IntentsGuildMembers // this is a privileged intent
+	// the API reads the store, a standby serves it too
+	if api != nil {
+		listener, err := net.Listen("tcp", cfg.GRPC.Listen)
+		if err != nil {
+			log.Fatalf("failed to listen for gRPC: %v", err)
+		}
+		go func() {
+			log.Printf("Serving gRPC on %v", cfg.GRPC.Listen)
+			if err := api.Serve(listener); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
 
-	if err := session.Open(); err != nil {
-		log.Fatal("failed to open discord session: ", err)
+	// leading has the shards of the running term, a standby has nothing to watch and keeps pinging.
+	// In tenant mode the watchdog isn't stopped by the connection of a single tenant, that would restart the others too.
+	var leading atomic.Pointer[bot.Shards]
+	if interval := systemd.WatchdogInterval(); interval > 0 {
+		go systemd.RunWatchdog(context.Background(), interval, func() bool {
+			shards := leading.Load()
+			return shards == nil || shards.HeartbeatAge() < staleHeartbeat
+		})
 	}
-	defer session.Close()
 
-	log.Println("Syncing members from server")
-	syncMembersFromServer(session)
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, syscall.SIGHUP, syscall.SIGUSR1)
+	var elector *leader.Elector
+	if cfg.LeaderLease != "" {
+		elector = newElector(st, cfg.LeaderLease)
+	}
+	for {
+		var lost <-chan error
+		if elector != nil {
+			var stopping bool
+			if cfg, lost, stopping = waitForLeadership(elector, cfg, *configPath, sc); stopping {
+				log.Println("I'm closing 😢")
+				notifySystemd(systemd.Stopping)
+				break
+			}
+		}
+		t := startTerm(cfg, *configPath, st, eventBus, events)
+		leading.Store(t.shards())
+		log.Println("I'm running 😊")
+		stopping := t.run(sc, lost)
+		if stopping {
+			log.Println("I'm closing 😢")
+			notifySystemd(systemd.Stopping)
+		}
+		cfg = t.stop()
+		leading.Store(nil)
+		if stopping {
+			break
+		}
+	}
+	if elector != nil {
+		// after the shards are closed, so the standby can't connect before this instance stopped
+		elector.Release()
+	}
+	if api != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := api.Shutdown(ctx); err != nil {
+			log.Printf("failed to stop the gRPC server: %v", err)
+		}
+	}
+}
 
-	go func() {
-		timer := time.NewTicker(12 * time.Hour)
-		for range timer.C {
-			log.Println("Performing scheduled sync")
-			syncMembersFromServer(session)
+// shutdown cancels running work and waits for events being handled, within shutdownTimeout.
+// The shards are closed afterwards.
+// Store queries and Discord requests don't take the context, ones already running finish or run into the timeout.
+func shutdown(cancel context.CancelFunc, bots []*bot.Bot, servers ...*http.Server) {
+	ctx, done := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer done()
+
+	cancel()
+	var wg sync.WaitGroup
+	for _, b := range bots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.Close(ctx); err != nil {
+				log.Fatalf("gave up waiting for events being handled: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, server := range servers {
+		if server == nil {
+			continue
+		}
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("failed to stop the server on %v: %v", server.Addr, err)
 		}
-	}()
+	}
+}
 
-	log.Println("I'm running 😊")
-	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
-	<-sc
-	log.Println("I'm closing 😢")
+// notifySystemd reports a state to systemd when running as a Type=notify service
+func notifySystemd(state string) {
+	if err := systemd.Notify(state); err != nil {
+		log.Printf("failed to notify systemd of %v: %v", state, err)
+	}
 }
 
-//go:embed migrations
-var migrations embed.FS
+// newElector contends for the leader lease as this host and process
+func newElector(st *store.Store, leaseDuration string) *leader.Elector {
+	lease, _ := parseDuration(leaseDuration)
+	hostname, _ := os.Hostname()
+	return leader.New(st, fmt.Sprintf("%v-%v", hostname, os.Getpid()), lease)
+}
 
-func migrate(db *sql.DB) error {
-	_, err := db.Exec("CREATE TABLE IF NOT EXISTS migrations (id INTEGER NOT NULL PRIMARY KEY, name TEXT UNIQUE);")
-	if err != nil {
-		return err
+// waitForLeadership blocks until this instance holds the leader lease, then keeps renewing it, sending on lost if it loses it,
+// so the term steps down before the lease expires and a standby only takes over from an instance that stopped announcing.
+// While standing by, SIGHUP and SIGUSR1 reload the config for the next term, other signals give up waiting.
+// Failing to reach the database is retried, the instance keeps standing by.
+func waitForLeadership(elector *leader.Elector, cfg *config, configPath string, sc <-chan os.Signal) (latest *config, lost <-chan error, stopping bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	waited := make(chan error, 1)
+	wait := func() { waited <- elector.Wait(ctx) }
+	go wait()
+
+	log.Printf("Waiting to become the leader as '%v'", elector.Holder())
+	for {
+		select {
+		case err := <-waited:
+			if err != nil {
+				log.Printf("failed to take the leader lease, trying again: %v", err)
+				time.AfterFunc(elector.Interval(), wait)
+				continue
+			}
+			log.Println("Became the leader")
+			leaseLost := make(chan error, 1)
+			go elector.Keep(context.Background(), func(err error) { leaseLost <- err })
+			return cfg, leaseLost, false
+		case sig := <-sc:
+			if sig == syscall.SIGHUP || sig == syscall.SIGUSR1 {
+				reloaded, err := reloadConfig(configPath)
+				if err != nil {
+					log.Printf("failed to reload config, keeping the old one: %v", err)
+					continue
+				}
+				log.Println("Reloaded config, it applies when taking over")
+				cfg = reloaded
+				continue
+			}
+			cancel()
+			// taking the lease may have won the race with the signal
+			if err := <-waited; err == nil {
+				elector.Release()
+			}
+			return cfg, nil, true
+		}
 	}
+}
 
-	stmtCheck, err := db.Prepare("SELECT 1 FROM migrations WHERE name = ?")
-	if err != nil {
-		return err
+// newReporter emails reports of the configured guilds, the config must already be validated
+func newReporter(cfg *config, st *store.Store, session *discordgo.Session) *report.Reporter {
+	location, _ := cfg.reportLocation()
+	guildIDs := []string{}
+	for _, guild := range cfg.Guilds {
+		guildIDs = append(guildIDs, guild.ID)
 	}
-	defer stmtCheck.Close()
-
-	stmtStore, err := db.Prepare("INSERT INTO migrations(name) VALUES (?)")
-	if err != nil {
-		return err
+	return &report.Reporter{
+		Schedule: cfg.Report.Schedule,
+		Location: location,
+		Mailer: &report.Mailer{
+			Addr:     cfg.Report.SMTPAddr,
+			Username: cfg.Report.SMTPUsername,
+			Password: cfg.Report.SMTPPassword,
+			From:     cfg.Report.From,
+			To:       cfg.Report.To,
+		},
+		Summarize: func(since time.Time) ([]report.Summary, error) {
+			summaries := []report.Summary{}
+			for _, guildID := range guildIDs {
+				name := guildID
+				if guild, err := session.Guild(guildID); err == nil {
+					name = guild.Name
+				}
+				summary, err := report.Summarize(st, guildID, name, since)
+				if err != nil {
+					return nil, err
+				}
+				summaries = append(summaries, summary)
+			}
+			return summaries, nil
+		},
 	}
-	defer stmtStore.Close()
+}
 
-	migrationDirEntries, err := migrations.ReadDir("migrations")
-	if err != nil {
-		return err
+func newMaintainer(cfg *config, st *store.Store, session *discordgo.Session) *maintenance.Maintainer {
+	start, end, _ := cfg.maintenanceWindow()
+	location, _ := cfg.timezoneFor(guildConfig{})
+	maintainer := &maintenance.Maintainer{
+		Store:    st,
+		Start:    start,
+		End:      end,
+		Location: location,
+		// backups are still written in dry runs, they don't change anything
+		BackupDir:  cfg.Maintenance.BackupDir,
+		BackupKeep: cfg.Maintenance.BackupKeep,
 	}
-
-	migrationFiles := []string{}
-	for _, migrationDirEntry := range migrationDirEntries {
-		if migrationDirEntry.IsDir() {
-			continue
+	if channelID := cfg.Maintenance.ChannelID; channelID != "" && !cfg.DryRun {
+		maintainer.Report = func(problem string) {
+			if _, err := session.ChannelMessageSend(channelID, "🩺 "+problem); err != nil {
+				log.Printf("failed to report a maintenance problem: %v", err)
+			}
 		}
-		migrationFiles = append(migrationFiles, migrationDirEntry.Name())
 	}
+	return maintainer
+}
 
-	sort.Slice(migrationFiles, func(i, j int) bool {
-		return strings.Compare(migrationFiles[i], migrationFiles[j]) <= 0
-	})
-
-	for _, migrationFile := range migrationFiles {
-		result := stmtCheck.QueryRow(migrationFile)
-		var i int
-		err := result.Scan(&i)
-		if err == nil {
-			// already migrated
-			continue
-		}
-		if err != sql.ErrNoRows {
-			// other unknown error
+// recoverStore restores the newest backup of the database, or starts it over, if it is missing or corrupt.
+// Anything else keeping it from opening, like a wrong key, a failed migration, or a lock, fails startup as usual.
+// With a leader lease, a database is only replaced once its lease shows no other instance is using it.
+func recoverStore(cfg *config) {
+	path, _, _ := strings.Cut(cfg.StatePath, "?")
+	err := maintenance.Recover(path, cfg.Maintenance.BackupDir, func() error {
+		st, err := store.OpenEncrypted(cfg.StatePath, cfg.DBKey)
+		if store.IsCorrupt(err, cfg.DBKey) && cfg.LeaderLease != "" {
+			return fmt.Errorf("%v, and its leader lease can't be read to make sure no other instance uses it: stop the other instances and start once without DUL_LEADER_LEASE to recover it", err)
+		} else if store.IsCorrupt(err, cfg.DBKey) {
+			return fmt.Errorf("%w: %v", maintenance.ErrCorrupt, err)
+		} else if err != nil {
 			return err
 		}
-
-		migrationSql, err := migrations.ReadFile(path.Join("migrations", migrationFile))
-		if err != nil {
+		defer st.Close()
+		problems, err := st.QuickCheck()
+		if store.IsCorrupt(err, cfg.DBKey) {
+			problems = []string{err.Error()}
+		} else if err != nil {
 			return err
 		}
-
-		log.Printf("[migration] RUN %v", migrationFile)
-		_, err = db.Exec(string(migrationSql))
-		if err != nil {
-			return err
+		if len(problems) == 0 {
+			return nil
 		}
-		_, err = stmtStore.Exec(migrationFile)
-		if err != nil {
-			return err
+		if cfg.LeaderLease != "" {
+			holder, held, err := st.LeaseHolder(leader.LeaseName, time.Now())
+			if err != nil {
+				return fmt.Errorf("%v problems, like %v, and its leader lease can't be read to make sure no other instance uses it: %v", len(problems), problems[0], err)
+			}
+			if held {
+				return fmt.Errorf("%v problems, like %v, but '%v' holds the leader lease, stop it to recover the database", len(problems), problems[0], holder)
+			}
 		}
-		log.Printf("[migration] FIN %v", migrationFile)
+		return fmt.Errorf("%w: %v problems, like %v", maintenance.ErrCorrupt, len(problems), problems[0])
+	})
+	if err != nil {
+		log.Fatalf("failed to recover the database at %v: %v", cfg.StatePath, err)
 	}
+}
 
-	return nil
+// configureGuild applies the reloadable guild options.
+// The config and guild must already be validated.
+func configureGuild(g *bot.Guild, session *discordgo.Session, cfg *config, guild guildConfig) {
+	templates, _ := cfg.templatesFor(guild)
+	hook, _ := cfg.hookFor(guild)
+	eventFilter, _ := cfg.filterFor(guild)
+	milestones := cfg.milestonesFor(guild)
+	quietHours, _ := cfg.quietHoursFor(guild)
+	massLeave, _ := cfg.massLeaveFor(guild)
+	threadMode, threadLocation, _ := cfg.threadFor(guild)
+	language, _ := cfg.languageFor(guild)
+	var sender notify.MessageSender = session
+	var roles bot.RoleAdder = session
+	if cfg.DryRun {
+		sender, roles = dryRunSession{}, dryRunSession{}
+	} else if fallbackChannelID := cfg.fallbackChannelFor(guild); fallbackChannelID != "" || cfg.OwnerID != "" {
+		sender = notify.NewFallback(session, fallbackChannelID, cfg.OwnerID)
+	}
+	var notifier notify.Notifier = notify.NewChannel(sender, guild.ChannelID, templates)
+	if threadMode != threadModeChannel && !cfg.DryRun {
+		notifier = notify.NewDailyThread(session, guild.ChannelID, threadMode, threadLocation, templates)
+	}
+	var surveySender bot.SurveySender = session
+	if cfg.DryRun {
+		surveySender = dryRunSession{}
+	}
+	var leaveSurvey *bot.LeaveSurvey
+	if enabled, reasons, _ := cfg.leaveSurveyFor(guild); enabled {
+		leaveSurvey = &bot.LeaveSurvey{Sender: surveySender, Reasons: reasons, NoteChannelID: cfg.alertChannelFor(guild)}
+	}
+	var voice notify.Notifier
+	if voiceChannelID := cfg.voiceChannelFor(guild); voiceChannelID != "" {
+		voice = notify.NewChannel(sender, voiceChannelID, templates)
+	}
+	g.Configure(bot.GuildOptions{
+		Notifier:          notifier,
+		IgnoredUsers:      append(append([]string{}, cfg.IgnoredUsers...), guild.IgnoredUsers...),
+		Announce:          cfg.announceFor(guild),
+		AnniversaryOptOut: append(append([]string{}, cfg.AnniversaryOptOut...), guild.AnniversaryOptOut...),
+		Milestones: bot.Milestones{
+			Every: milestones.Every,
+			At:    milestones.At,
+		},
+		QuietHours:   quietHours,
+		AutoRoleID:   cfg.autoRoleFor(guild),
+		WatchRoleID:  cfg.watchRoleFor(guild),
+		LeaveRoles:   cfg.leaveRolesFor(guild),
+		EditLeaves:   cfg.editLeavesFor(guild),
+		SyncSummary:  cfg.syncSummaryFor(guild),
+		Language:     language,
+		Roles:        roles,
+		MassLeave:    massLeave,
+		Alerts:       notify.NewChannel(sender, cfg.alertChannelFor(guild), templates),
+		Voice:        voice,
+		Hook:         hook,
+		Filter:       eventFilter,
+		LeaveSurvey:  leaveSurvey,
+		QuickActions: cfg.quickActionsFor(guild),
+	})
 }
 
-func ready(s *discordgo.Session, event *discordgo.Ready) {
-	s.UpdateGameStatus(0, "hello")
+// dryRunSession logs the messages and role changes it is asked to make instead of making them
+type dryRunSession struct{}
+
+func (dryRunSession) ChannelMessageSend(channelID string, content string) (*discordgo.Message, error) {
+	log.Printf("[dry run] would send to channel '%v': %v", channelID, content)
+	return &discordgo.Message{ChannelID: channelID}, nil
 }
 
-func guildMemberAdd(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
-	if m.GuildID != guildID || m.User == nil {
-		return
-	}
-	// log.Printf("received member added event: %v", m.User.ID)
-	// memberAdded(s, m.User.ID, m.User.Username, m.User.Discriminator)
-	memberAdded(s, m.User.ID, discordUser{
-		username:      m.User.Username,
-		discriminator: m.User.Discriminator,
-	})
+func (dryRunSession) UserChannelCreate(recipientID string) (*discordgo.Channel, error) {
+	return &discordgo.Channel{ID: "dm-" + recipientID, Type: discordgo.ChannelTypeDM}, nil
 }
 
-func guildMemberRemove(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
-	if m.GuildID != guildID || m.User == nil {
-		return
-	}
-	// log.Printf("received member remove event: %v", m.User.ID)
-	memberRemoved(s, m.User.ID)
+func (dryRunSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	log.Printf("[dry run] would send to channel '%v': %v", channelID, data.Content)
+	return &discordgo.Message{ChannelID: channelID}, nil
 }
 
-func memberAdded(s *discordgo.Session, discordID string, user discordUser) {
-	knownMemberStateLock.Lock()
-	defer knownMemberStateLock.Unlock()
-	memberAddedLocked(s, discordID, user)
+func (dryRunSession) GuildMemberRoleAdd(guildID, userID, roleID string) error {
+	log.Printf("[dry run] would give '%v' the role '%v' in guild '%v'", userID, roleID, guildID)
+	return nil
 }
 
-func memberAddedLocked(s *discordgo.Session, discordID string, user discordUser) {
-	_, exists := knownMemberState[discordID]
-	if exists {
-		return
+// reloadToken reads the token from the config again and switches every shard to it, if it changed and works.
+// The rest of the config is only reloaded by SIGHUP.
+func reloadToken(configPath string, shards *bot.Shards) error {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if cfg.Token == "" {
+		return errors.New("the token is empty")
 	}
-	_, err := stmtAdd.Exec(discordID, user.username, user.discriminator)
+	if err := shards.Rotate(cfg.Token); err != nil {
+		return err
+	}
+	log.Println("Switched to the new token, reconnecting the shards")
+	return nil
+}
+
+// reloadTenantToken reads the token of a tenant from the database again and switches every shard of its bot to it,
+// if it changed and works
+func reloadTenantToken(st *store.Store, name string, shards *bot.Shards) error {
+	tenants, err := st.Tenants()
 	if err != nil {
-		log.Fatalf("failed to insert member '%v' to persistent storage: %v", err, discordID)
-	}
-	knownMemberState[discordID] = user
-	if !knownMemberStateEmpty {
-		if user.username == "" && user.discriminator == "" {
-			_, err = s.ChannelMessageSend(channelID, fmt.Sprintf("<@%v> joined the server", discordID))
-		} else if user.discriminator == "0" {
-			// discriminator of "0" == new discord username format, numberless
-			_, err = s.ChannelMessageSend(channelID, fmt.Sprintf("<@%v> (%v) joined the server", discordID, user.username))
-		} else {
-			_, err = s.ChannelMessageSend(channelID, fmt.Sprintf("<@%v> (%v#%v) joined the server", discordID, user.username, user.discriminator))
+		return err
+	}
+	for _, tenant := range tenants {
+		if tenant.Name != name {
+			continue
 		}
-		if err != nil {
-			log.Fatalf("failed to send message about '%v' joining server: %v", discordID, err)
+		if err := shards.Rotate(tenant.Token); err != nil {
+			return err
 		}
-		log.Printf("messaged about '%v' joining", discordID)
+		log.Printf("Switched tenant '%v' to the new token, reconnecting its shards", name)
+		return nil
 	}
+	return fmt.Errorf("tenant '%v' was removed", name)
 }
 
-func memberUpdatedLocked(s *discordgo.Session, discordID string, user discordUser) {
-	_, err := stmtUpdate.Exec(user.username, user.discriminator, discordID)
+// reloadConfig reads the config file again and validates it
+func reloadConfig(configPath string) (*config, error) {
+	cfg, err := loadConfig(configPath)
 	if err != nil {
-		log.Fatalf("failed to update member '%v' in persistent storage: %v", err, discordID)
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
 	}
-	knownMemberState[discordID] = user
+	return cfg, nil
+}
+
+// applyConfig applies the options of a changed config that aren't about a bot, the config must already be validated
+func applyConfig(cfg *config, eventBus *bus.Bus) {
+	atomic.StoreInt64(&syncSlices, int64(cfg.SyncSlices))
+	retention, _ := parseDuration(cfg.HistoryRetention)
+	atomic.StoreInt64(&historyRetention, int64(retention))
+	anonymizePeriod, _ := parseDuration(cfg.AnonymizeAfter)
+	atomic.StoreInt64(&anonymizeAfter, int64(anonymizePeriod))
+	eventBus.Disable(cfg.DisabledConsumers)
+	setConsumerFilters(eventBus, cfg)
 }
 
-func memberRemoved(s *discordgo.Session, discordID string) {
-	knownMemberStateLock.Lock()
-	defer knownMemberStateLock.Unlock()
-	memberRemovedLocked(s, discordID)
+// setConsumerFilters limits the event consumers to their filters, the config must already be validated
+func setConsumerFilters(eventBus *bus.Bus, cfg *config) {
+	parsed, _ := cfg.consumerFilters()
+	filters := make(map[string]bus.Filter, len(parsed))
+	for name, consumerFilter := range parsed {
+		filters[name] = consumerFilter
+	}
+	eventBus.SetFilters(filters)
 }
 
-func memberRemovedLocked(s *discordgo.Session, discordID string) {
-	user, exists := knownMemberState[discordID]
-	if !exists {
+func pruneHistory(st *store.Store) {
+	retention := time.Duration(atomic.LoadInt64(&historyRetention))
+	if retention <= 0 {
 		return
 	}
-	_, err := stmtRemove.Exec(discordID)
+	cutoff := time.Now().Add(-retention)
+	affected, err := st.PruneHistory(cutoff)
 	if err != nil {
-		log.Fatalf("failed to delete member '%v' from persistent storage: %v", err, discordID)
-	}
-	delete(knownMemberState, discordID)
-	if !knownMemberStateEmpty {
-		if user.username == "" && user.discriminator == "" {
-			_, err = s.ChannelMessageSend(channelID, fmt.Sprintf("<@%v> left the server", discordID))
-		} else if user.discriminator == "0" {
-			// discriminator of "0" == new discord username format, numberless
-			_, err = s.ChannelMessageSend(channelID, fmt.Sprintf("<@%v> (%v) left the server", discordID, user.username))
-		} else {
-			_, err = s.ChannelMessageSend(channelID, fmt.Sprintf("<@%v> (%v#%v) left the server", discordID, user.username, user.discriminator))
-		}
-		if err != nil {
-			log.Fatalf("failed to send message about '%v' leaving server: %v", discordID, err)
-		}
-		log.Printf("messaged about '%v' leaving", discordID)
+		log.Printf("failed to prune history older than %v: %v", cutoff, err)
+		return
 	}
+	log.Printf("pruned %v history rows older than %v", affected, cutoff)
 }
 
-func syncMembersFromServer(s *discordgo.Session) {
-	knownMemberStateLock.Lock()
-	defer knownMemberStateLock.Unlock()
-
-	// we'll remove members from this as we go
-	// any members left at the end are no longer in the server
-	knownMemberStateClone := make(map[string]interface{}, len(knownMemberState))
-	for discordID := range knownMemberState {
-		knownMemberStateClone[discordID] = nil
-	}
-
-	var (
-		after   string
-		members []*discordgo.Member
-		err     error
-	)
-	const limit = 1000
-	for {
-		members, err = s.GuildMembers(guildID, after, limit)
-		if err != nil {
-			log.Fatalf("failed fetching guild members after '%v': %v", after, err)
-		}
-
-		for _, member := range members {
-			if member.User == nil {
-				continue
-			}
-			memberUser := discordUser{
-				username:      member.User.Username,
-				discriminator: member.User.Discriminator,
-			}
-			user, exists := knownMemberState[member.User.ID]
-			if exists {
-				if user.username != memberUser.username || user.discriminator != memberUser.discriminator {
-					memberUpdatedLocked(s, member.User.ID, memberUser)
-				}
-			} else {
-				memberAddedLocked(s, member.User.ID, memberUser)
-			}
-			delete(knownMemberStateClone, member.User.ID)
-		}
-
-		// less than limit returned - we're done!
-		if len(members) < limit {
-			break
-		}
-
-		// could be more
-		after = members[len(members)-1].User.ID
+func anonymizeHistory(st *store.Store) {
+	period := time.Duration(atomic.LoadInt64(&anonymizeAfter))
+	if period <= 0 {
+		return
 	}
-
-	// these users weren't found in the server, assume we missed their leave event
-	for discordID := range knownMemberStateClone {
-		memberRemovedLocked(s, discordID)
+	cutoff := time.Now().Add(-period)
+	if _, err := st.Anonymize(cutoff); err != nil {
+		log.Printf("failed to anonymize members who left before %v: %v", cutoff, err)
 	}
-
-	// member state is known now, notifications are allowed
-	knownMemberStateEmpty = false
 }
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"go.albinodrought/discord-user-log/internal/notify"
+)
+
+// option is a setting passed as a command-line flag or an environment variable, the flag takes precedence
+type option struct {
+	flag  string
+	env   string
+	usage string
+	// secret options can also be read from the file named by --<flag>-file or <env>_FILE, like Docker secrets
+	secret bool
+}
+
+var options = []option{
+	{"token", "DUL_TOKEN", "Discord bot token", true},
+	{"guild", "DUL_GUILD_ID", "ID of the guild to log, replacing the guilds of the config file", false},
+	{"channel", "DUL_CHANNEL_ID", "ID of the channel announcing joins and leaves of --guild", false},
+	{"state-path", "DUL_STATE_PATH", "path to the SQLite database, or a postgres:// URL", true},
+	{"db-key", "DUL_DB_KEY", "SQLCipher key encrypting the database", true},
+	{"sync-interval", "DUL_SYNC_INTERVAL", "how often to sync members, like 12h", false},
+	{"sync-mode", "DUL_SYNC_MODE", "sync members over rest or the gateway", false},
+	{"sync-slices", "DUL_SYNC_SLICES", "reconcile a range of members per scheduled sync, a full pass taking this many intervals", false},
+	{"shard-count", "DUL_SHARD_COUNT", "gateway shards to connect, 0 asks Discord", false},
+	{"event-workers", "DUL_EVENT_WORKERS", "how many member events are handled at once, 0 is 4", false},
+	{"event-queue-size", "DUL_EVENT_QUEUE_SIZE", "how many member events may wait before they are dropped, 0 is 10000", false},
+	{"leader-lease", "DUL_LEADER_LEASE", "leader lease duration for standby instances, like 30s", false},
+	{"tenants", "DUL_TENANTS", "run a bot for every tenant in the database, see the tenants command", false},
+	{"tenant", "DUL_TENANT", "tenant whose database commands use in tenant mode", false},
+	{"dry-run", "DUL_DRY_RUN", "log announcements and role changes instead of making them", false},
+	{"track-presence", "DUL_TRACK_PRESENCE", "record when members come online and go offline", false},
+	{"track-first-messages", "DUL_TRACK_FIRST_MESSAGES", "record when members who join first post", false},
+	{"track-scheduled-events", "DUL_TRACK_SCHEDULED_EVENTS", "record which members RSVP to scheduled events", false},
+	{"track-invites", "DUL_TRACK_INVITES", "record which invite members joined with, needs the Manage Server permission", false},
+	{"history-retention", "DUL_HISTORY_RETENTION", "prune history older than this, like 180d", false},
+	{"anonymize-after", "DUL_ANONYMIZE_AFTER", "anonymize members who left longer ago than this, like 90d", false},
+	{"cross-guild-window", "DUL_CROSS_GUILD_WINDOW", "alert about joins within this long of leaving or being banned from another tracked guild, like 7d", false},
+	{"avatar-archive", "DUL_AVATAR_ARCHIVE", "directory to download changed avatars to", false},
+	{"hook", "DUL_HOOK", "template run on every event, deciding whether and how it is announced", false},
+	{"hook-script", "DUL_HOOK_SCRIPT", "Lua script run on every event instead of --hook", false},
+	{"filter", "DUL_FILTER", "expression limiting announcements to the events it matches, like 'event == \"leave\" && member_age_days < 1'", false},
+	{"announce", "DUL_ANNOUNCE", "event types to announce, comma-separated", false},
+	{"milestone-every", "DUL_MILESTONE_EVERY", "announce every this many members", false},
+	{"milestones", "DUL_MILESTONES", "member counts to announce, comma-separated", false},
+	{"ignored-users", "DUL_IGNORED_USERS", "user IDs not to announce, comma-separated", false},
+	{"anniversary-opt-out", "DUL_ANNIVERSARY_OPT_OUT", "user IDs whose anniversaries aren't announced, comma-separated", false},
+	{"quiet-hours", "DUL_QUIET_HOURS", "hours to hold announcements back, like 01:00-08:00", false},
+	{"quiet-hours-timezone", "DUL_QUIET_HOURS_TIMEZONE", "timezone of --quiet-hours", false},
+	{"autorole-id", "DUL_AUTOROLE_ID", "role given to members who join", false},
+	{"watch-role-id", "DUL_WATCH_ROLE_ID", "role mentioned by alerts about watched users", false},
+	{"leave-roles", "DUL_LEAVE_ROLES", "only announce leaves of members with one of these role IDs, comma-separated", false},
+	{"edit-leaves", "DUL_EDIT_LEAVES", "edit join announcements when members leave", false},
+	{"leave-survey", "DUL_LEAVE_SURVEY", "ask members who leave why, by DM or with a note for moderators", false},
+	{"leave-survey-reasons", "DUL_LEAVE_SURVEY_REASONS", "reasons offered by --leave-survey, comma-separated", false},
+	{"quick-actions", "DUL_QUICK_ACTIONS", "buttons under leave and ban announcements, ban and note, comma-separated", false},
+	{"sync-summary", "DUL_SYNC_SUMMARY", "summarize syncs finding more than this many events", false},
+	{"thread-mode", "DUL_THREAD_MODE", "announce in a new thread (thread) or forum post (forum) every day", false},
+	{"thread-timezone", "DUL_THREAD_TIMEZONE", "timezone starting the threads", false},
+	{"mass-leave-count", "DUL_MASS_LEAVE_COUNT", "alert when more than this many members leave within --mass-leave-window", false},
+	{"mass-leave-window", "DUL_MASS_LEAVE_WINDOW", "window of --mass-leave-count, like 10m", false},
+	{"alert-channel-id", "DUL_ALERT_CHANNEL_ID", "channel receiving alerts", false},
+	{"voice-channel-id", "DUL_VOICE_CHANNEL_ID", "channel logging voice activity", false},
+	{"fallback-channel-id", "DUL_FALLBACK_CHANNEL_ID", "channel receiving what the bot isn't allowed to post to the other channels", false},
+	{"owner-id", "DUL_OWNER_ID", "user told by DM when the bot isn't allowed to post to a channel, and allowed to run /userlog reload-token", false},
+	{"language", "DUL_LANGUAGE", "language of announcements and commands", false},
+	{"timezone", "DUL_TIMEZONE", "timezone of dates, months, and the maintenance window", false},
+	{"web-listen", "DUL_WEB_LISTEN", "address serving the dashboard, like :8080", false},
+	{"web-base-url", "DUL_WEB_BASE_URL", "public URL of the dashboard", false},
+	{"web-client-id", "DUL_WEB_CLIENT_ID", "OAuth2 client ID of the dashboard", false},
+	{"web-client-secret", "DUL_WEB_CLIENT_SECRET", "OAuth2 client secret of the dashboard", true},
+	{"web-role-id", "DUL_WEB_ROLE_ID", "role allowed to see the dashboard", false},
+	{"web-events-token", "DUL_WEB_EVENTS_TOKEN", "token of the event stream, feed, and metrics APIs", true},
+	{"grpc-listen", "DUL_GRPC_LISTEN", "address serving the gRPC API, like :9090", false},
+	{"grpc-cert-file", "DUL_GRPC_CERT_FILE", "TLS certificate of the gRPC API", false},
+	{"grpc-key-file", "DUL_GRPC_KEY_FILE", "TLS key of the gRPC API", false},
+	{"grpc-token", "DUL_GRPC_TOKEN", "token gRPC clients must send", true},
+	{"public-stats", "DUL_PUBLIC_STATS", "serve a public page of each guild's member count and growth", false},
+	{"mqtt-url", "DUL_MQTT_URL", "MQTT broker to publish events to", true},
+	{"mqtt-topic", "DUL_MQTT_TOPIC", "MQTT topic prefix", false},
+	{"nats-url", "DUL_NATS_URL", "NATS server to publish events to", true},
+	{"nats-subject", "DUL_NATS_SUBJECT", "NATS subject prefix", false},
+	{"webhook-url", "DUL_WEBHOOK_URL", "HTTP endpoint to post signed events to, replacing the webhooks of the config file", false},
+	{"webhook-secret", "DUL_WEBHOOK_SECRET", "secret signing the events posted to --webhook-url", true},
+	{"webhook-events", "DUL_WEBHOOK_EVENTS", "event types posted to --webhook-url, comma-separated, all by default", false},
+	{"webhook-filter", "DUL_WEBHOOK_FILTER", "expression limiting the events posted to --webhook-url", false},
+	{"disabled-consumers", "DUL_DISABLED_CONSUMERS", "event consumers that receive nothing, comma-separated", false},
+	{"event-log", "DUL_EVENT_LOG", "file to append events to as JSON lines", false},
+	{"event-log-max-mb", "DUL_EVENT_LOG_MAX_MB", "size at which the event log is rotated", false},
+	{"push-events", "DUL_PUSH_EVENTS", "event types to push, comma-separated", false},
+	{"ntfy-url", "DUL_NTFY_URL", "ntfy topic URL to push to", false},
+	{"ntfy-token", "DUL_NTFY_TOKEN", "ntfy access token", true},
+	{"pushover-token", "DUL_PUSHOVER_TOKEN", "Pushover application token", true},
+	{"pushover-user", "DUL_PUSHOVER_USER", "Pushover user key", false},
+	{"report-schedule", "DUL_REPORT_SCHEDULE", "email reports daily, weekly, or monthly", false},
+	{"report-timezone", "DUL_REPORT_TIMEZONE", "timezone of the reports", false},
+	{"report-from", "DUL_REPORT_FROM", "sender of the reports", false},
+	{"report-to", "DUL_REPORT_TO", "recipients of the reports, comma-separated", false},
+	{"smtp-addr", "DUL_SMTP_ADDR", "SMTP server sending the reports, like smtp.example.com:587", false},
+	{"smtp-username", "DUL_SMTP_USERNAME", "SMTP username", false},
+	{"smtp-password", "DUL_SMTP_PASSWORD", "SMTP password", true},
+	{"presence-template", "DUL_PRESENCE_TEMPLATE", "template of the bot's status", false},
+	{"presence-interval", "DUL_PRESENCE_INTERVAL", "how often the status is refreshed, like 5m", false},
+	{"maintenance-window", "DUL_MAINTENANCE_WINDOW", "daily database maintenance window, like 03:00-05:00", false},
+	{"maintenance-channel-id", "DUL_MAINTENANCE_CHANNEL_ID", "channel receiving database problems", false},
+	{"backup-dir", "DUL_BACKUP_DIR", "directory receiving a database backup after every maintenance", false},
+	{"backup-keep", "DUL_BACKUP_KEEP", "how many backups to keep, 0 is 7", false},
+	{"recover-db", "DUL_RECOVER_DB", "restore the newest backup, or start over, if the database is missing or corrupt", false},
+	{"telemetry-endpoint", "DUL_TELEMETRY_ENDPOINT", "OTLP/HTTP collector to export traces and metrics to", false},
+	{"telemetry-headers", "DUL_TELEMETRY_HEADERS", "headers sent to the collector, like key=value,key=value", true},
+}
+
+// allOptions are the options, with a template option for every event type
+func allOptions() []option {
+	all := append([]option{}, options...)
+	eventTypes := []string{}
+	for eventType := range notify.DefaultTemplates {
+		eventTypes = append(eventTypes, eventType)
+	}
+	sort.Strings(eventTypes)
+	for _, eventType := range eventTypes {
+		all = append(all, option{
+			flag:  strings.ReplaceAll(eventType, "_", "-") + "-template",
+			env:   "DUL_" + strings.ToUpper(eventType) + "_TEMPLATE",
+			usage: "template announcing " + eventType + " events",
+		})
+	}
+	for _, consumer := range consumers {
+		all = append(all, option{
+			flag:  strings.ReplaceAll(consumer, "_", "-") + "-filter",
+			env:   "DUL_" + strings.ToUpper(consumer) + "_FILTER",
+			usage: "expression limiting the events sent to the " + consumer + " consumer",
+		})
+	}
+	return all
+}
+
+// setFlags are the option flags given on the command line, by environment variable
+var setFlags = map[string]string{}
+
+// parseFlags parses the command line, with a flag for every option
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	envs := map[string]string{}
+	for _, o := range allOptions() {
+		flags.String(o.flag, "", fmt.Sprintf("%v (%v)", o.usage, o.env))
+		envs[o.flag] = o.env
+		if o.secret {
+			flags.String(o.flag+"-file", "", fmt.Sprintf("file containing --%v (%v_FILE)", o.flag, o.env))
+			envs[o.flag+"-file"] = o.env + "_FILE"
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if env, ok := envs[f.Name]; ok {
+			setFlags[env] = f.Value.String()
+		}
+	})
+	return nil
+}
+
+// optionValues resolves every option by environment variable name.
+// Flags take precedence over environment variables, and a value over a file containing it.
+func optionValues() (map[string]string, error) {
+	values := map[string]string{}
+	for _, o := range allOptions() {
+		for _, lookup := range []func(string) (string, bool){
+			func(env string) (string, bool) { value, ok := setFlags[env]; return value, ok },
+			os.LookupEnv,
+		} {
+			if value, ok := lookup(o.env); ok && value != "" {
+				values[o.env] = value
+				break
+			}
+			if path, ok := lookup(o.env + "_FILE"); ok && path != "" && o.secret {
+				secret, err := os.ReadFile(path)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read %v_FILE: %w", o.env, err)
+				}
+				// editors and echo end files with a newline
+				values[o.env] = strings.TrimRight(string(secret), "\r\n")
+				break
+			}
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	"go.albinodrought/discord-user-log/internal/bus"
+	"go.albinodrought/discord-user-log/internal/feed"
+	"go.albinodrought/discord-user-log/internal/filter"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// alertEvents are sent to the alert channel instead of being announced
+var alertEvents = map[string]bool{
+	notify.EventMassLeave:      true,
+	notify.EventCrossGuildJoin: true,
+	notify.EventWatchedJoin:    true,
+	notify.EventWatchedLeave:   true,
+	notify.EventWatchedRename:  true,
+}
+
+// voiceEvents are sent to the voice log channel, if there is one
+var voiceEvents = map[string]bool{
+	store.EventVoiceJoin:  true,
+	store.EventVoiceLeave: true,
+	store.EventVoiceMove:  true,
+}
+
+// replayGuild announces replayed events like the bot announces the events of a guild, to a dry run session
+type replayGuild struct {
+	announce map[string]bool
+	ignored  map[string]bool
+	hook     *notify.Hook
+	filter   *filter.Filter
+	notifier notify.Notifier
+	alerts   notify.Notifier
+	voice    notify.Notifier
+}
+
+// runReplay pushes recorded events through the hooks, filters, and templates of their guild and the event consumers,
+// logging the announcements and deliveries instead of making them, to try configuration changes on real traffic
+func runReplay(cfg *config, args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	path := flags.String("events", "", "JSON lines of events, like the event log")
+	dryRun := flags.Bool("dry-run", false, "log announcements and deliveries instead of making them, required")
+	flags.Parse(args)
+	if *path == "" || !*dryRun {
+		log.Fatal("usage: replay --events <events.jsonl> --dry-run")
+	}
+	if err := cfg.validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	guilds := map[string]*replayGuild{}
+	for _, guild := range cfg.Guilds {
+		guilds[guild.ID] = newReplayGuild(cfg, guild)
+	}
+	eventBus := bus.New()
+	for _, consumer := range configuredConsumers(cfg) {
+		eventBus.Register(consumer.name, consumer)
+	}
+	eventBus.Disable(cfg.DisabledConsumers)
+	setConsumerFilters(eventBus, cfg)
+
+	file, err := os.Open(*path)
+	if err != nil {
+		log.Fatalf("failed to open '%v': %v", *path, err)
+	}
+	defer file.Close()
+	lines := bufio.NewScanner(file)
+	// events are small, but leave room for long tag lists
+	lines.Buffer(make([]byte, 64*1024), 1024*1024)
+	replayed := 0
+	for line := 1; lines.Scan(); line++ {
+		if len(lines.Bytes()) == 0 {
+			continue
+		}
+		event, err := feed.Decode(lines.Bytes())
+		if err != nil {
+			log.Fatalf("failed to read line %v of '%v': %v", line, *path, err)
+		}
+		guild, ok := guilds[event.GuildID]
+		if !ok {
+			log.Printf("[replay] line %v: skipped '%v' event of guild '%v', it isn't configured", line, event.Type, event.GuildID)
+			continue
+		}
+		log.Printf("[replay] line %v: '%v' event of '%v'", line, event.Type, event.UserID)
+		guild.replay(event, eventBus)
+		replayed++
+	}
+	if err := lines.Err(); err != nil {
+		log.Fatalf("failed to read '%v': %v", *path, err)
+	}
+	log.Printf("[replay] replayed %v events", replayed)
+}
+
+// newReplayGuild applies the guild options that decide what is announced, the config must already be validated
+func newReplayGuild(cfg *config, guild guildConfig) *replayGuild {
+	templates, _ := cfg.templatesFor(guild)
+	hook, _ := cfg.hookFor(guild)
+	eventFilter, _ := cfg.filterFor(guild)
+	g := &replayGuild{
+		announce: map[string]bool{},
+		ignored:  map[string]bool{},
+		hook:     hook,
+		filter:   eventFilter,
+		notifier: notify.NewChannel(dryRunSession{}, guild.ChannelID, templates),
+		alerts:   notify.NewChannel(dryRunSession{}, cfg.alertChannelFor(guild), templates),
+	}
+	for _, eventType := range cfg.announceFor(guild) {
+		g.announce[eventType] = true
+	}
+	for _, discordID := range append(append([]string{}, cfg.IgnoredUsers...), guild.IgnoredUsers...) {
+		g.ignored[discordID] = true
+	}
+	if voiceChannelID := cfg.voiceChannelFor(guild); voiceChannelID != "" {
+		g.voice = notify.NewChannel(dryRunSession{}, voiceChannelID, templates)
+	}
+	return g
+}
+
+// replay publishes and announces an event like the bot does once it's recorded, logging why it isn't announced
+func (g *replayGuild) replay(event notify.Event, eventBus *bus.Bus) {
+	if g.hook != nil {
+		if err := g.hook.Apply(&event); err != nil {
+			log.Printf("[replay] ignoring the hook: %v", err)
+		}
+	}
+	eventBus.Publish(event)
+
+	var notifier notify.Notifier
+	switch {
+	case alertEvents[event.Type]:
+		notifier = g.alerts
+	case voiceEvents[event.Type]:
+		if g.voice == nil {
+			log.Printf("[replay] not logged, there is no voice log channel")
+			return
+		}
+		if g.ignored[event.UserID] {
+			log.Printf("[replay] not logged, the user is ignored")
+			return
+		}
+		notifier = g.voice
+	case event.Type == notify.EventMilestone:
+		// milestones are announced whenever they are reached
+		notifier = g.notifier
+	case !g.announce[event.Type]:
+		log.Printf("[replay] not announced, '%v' isn't an announced event type", event.Type)
+		return
+	case g.ignored[event.UserID]:
+		log.Printf("[replay] not announced, the user is ignored")
+		return
+	case !g.filter.Match(event):
+		log.Printf("[replay] not announced, it doesn't match the filter")
+		return
+	default:
+		notifier = g.notifier
+	}
+	if event.Skipped {
+		log.Printf("[replay] not announced, the hook skipped it")
+		return
+	}
+	if err := notifier.Notify(event); err != nil {
+		log.Printf("[replay] failed to render the announcement: %v", err)
+	}
+}
+
+// replayConsumer logs the events an event consumer would receive
+type replayConsumer struct {
+	// name is the consumer's name on the bus, target what it delivers to
+	name   string
+	target string
+	// types are the event types the consumer takes, every type if empty
+	types  []string
+	filter *filter.Filter
+}
+
+func (c replayConsumer) Publish(event notify.Event) {
+	selected := len(c.types) == 0
+	for _, eventType := range c.types {
+		selected = selected || eventType == event.Type
+	}
+	if !selected || !c.filter.Match(event) {
+		return
+	}
+	if c.target != "" {
+		log.Printf("[dry run] would publish to %v %v", c.name, c.target)
+		return
+	}
+	log.Printf("[dry run] would publish to %v", c.name)
+}
+
+// configuredConsumers returns the event consumers the config sets up, like main registers them
+func configuredConsumers(cfg *config) []replayConsumer {
+	consumers := []replayConsumer{}
+	if cfg.Web.Listen != "" && cfg.Web.EventsToken != "" {
+		consumers = append(consumers, replayConsumer{name: consumerEventStream})
+	}
+	if cfg.EventLog != "" {
+		consumers = append(consumers, replayConsumer{name: consumerEventLog})
+	}
+	if cfg.Push.NtfyURL != "" {
+		consumers = append(consumers, replayConsumer{name: consumerNtfy, types: cfg.Push.Events})
+	}
+	if cfg.Push.PushoverToken != "" {
+		consumers = append(consumers, replayConsumer{name: consumerPushover, types: cfg.Push.Events})
+	}
+	if cfg.Publish.MQTTURL != "" {
+		consumers = append(consumers, replayConsumer{name: consumerMQTT})
+	}
+	if cfg.Publish.NATSURL != "" {
+		consumers = append(consumers, replayConsumer{name: consumerNATS})
+	}
+	if cfg.GRPC.Listen != "" {
+		consumers = append(consumers, replayConsumer{name: consumerGRPC})
+	}
+	for _, webhook := range cfg.Webhooks {
+		webhookFilter, _ := webhook.filter()
+		consumers = append(consumers, replayConsumer{name: consumerWebhooks, target: webhook.URL, types: webhook.Events, filter: webhookFilter})
+	}
+	return consumers
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/bot"
+	"go.albinodrought/discord-user-log/internal/notify"
+	"go.albinodrought/discord-user-log/internal/store"
+)
+
+// templateSettingPrefix is followed by an event type, like "template_join"
+const templateSettingPrefix = "template_"
+
+// withSettings applies runtime guild settings set by /userlog config on top of a guild's config.
+// A setting that changes part of a fallback option, like only the quiet hours timezone, copies the global option into the guild first.
+func (cfg *config) withSettings(guild guildConfig, settings map[string]string) (guildConfig, error) {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := strings.TrimSpace(settings[key])
+		switch {
+		case key == "channel_id":
+			guild.ChannelID = value
+		case key == "alert_channel_id":
+			guild.AlertChannelID = value
+		case key == "voice_channel_id":
+			guild.VoiceChannelID = value
+		case key == "fallback_channel_id":
+			guild.FallbackChannelID = value
+		case key == "autorole_id":
+			guild.AutoRoleID = value
+		case key == "watch_role_id":
+			guild.WatchRoleID = value
+		case key == "ignored_users":
+			guild.IgnoredUsers = splitList(value)
+		case key == "anniversary_opt_out":
+			guild.AnniversaryOptOut = splitList(value)
+		case key == "announce":
+			guild.Announce = splitList(value)
+		case key == "leave_roles":
+			guild.LeaveRoles = splitList(value)
+		case key == "quick_actions":
+			guild.QuickActions = splitList(value)
+		case key == "edit_leaves":
+			editLeaves, err := strconv.ParseBool(value)
+			if err != nil {
+				return guild, fmt.Errorf("failed to parse edit_leaves: %w", err)
+			}
+			guild.EditLeaves = &editLeaves
+		case key == "sync_summary":
+			syncSummary, err := strconv.Atoi(value)
+			if err != nil {
+				return guild, fmt.Errorf("failed to parse sync_summary: %w", err)
+			}
+			guild.SyncSummary = &syncSummary
+		case key == "hook":
+			// a hook set at runtime replaces the guild's hook script too, scripts are files only the config names
+			guild.Hook, guild.HookScript = value, ""
+		case key == "filter":
+			guild.Filter = value
+		case key == "language":
+			guild.Language = value
+		case key == "timezone":
+			guild.Timezone = value
+		case key == "thread_mode":
+			guild.ThreadMode = value
+		case key == "thread_timezone":
+			guild.ThreadTimezone = value
+		case key == "quiet_hours":
+			quietHours := cfg.copyQuietHours(guild)
+			quietHours.Start, quietHours.End = "", ""
+			if value != "" && value != "off" {
+				start, end, ok := strings.Cut(value, "-")
+				if !ok {
+					return guild, errors.New("quiet_hours must be a range like 01:00-08:00, or off")
+				}
+				quietHours.Start, quietHours.End = strings.TrimSpace(start), strings.TrimSpace(end)
+			}
+			guild.QuietHours = quietHours
+		case key == "quiet_hours_timezone":
+			quietHours := cfg.copyQuietHours(guild)
+			quietHours.Timezone = value
+			guild.QuietHours = quietHours
+		case key == "mass_leave_count":
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				return guild, fmt.Errorf("failed to parse mass_leave_count: %w", err)
+			}
+			massLeave := cfg.copyMassLeave(guild)
+			massLeave.Count = count
+			guild.MassLeave = massLeave
+		case key == "mass_leave_window":
+			massLeave := cfg.copyMassLeave(guild)
+			massLeave.Window = value
+			guild.MassLeave = massLeave
+		case key == "leave_survey":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return guild, fmt.Errorf("failed to parse leave_survey: %w", err)
+			}
+			leaveSurvey := cfg.copyLeaveSurvey(guild)
+			leaveSurvey.Enabled = enabled
+			guild.LeaveSurvey = leaveSurvey
+		case key == "leave_survey_reasons":
+			leaveSurvey := cfg.copyLeaveSurvey(guild)
+			leaveSurvey.Reasons = splitList(value)
+			guild.LeaveSurvey = leaveSurvey
+		case key == "milestone_every":
+			every, err := strconv.Atoi(value)
+			if err != nil {
+				return guild, fmt.Errorf("failed to parse milestone_every: %w", err)
+			}
+			milestones := cfg.milestonesFor(guild)
+			milestones.Every = every
+			guild.Milestones = &milestones
+		case key == "milestones":
+			milestones := cfg.milestonesFor(guild)
+			milestones.At = nil
+			for _, at := range splitList(value) {
+				count, err := strconv.Atoi(at)
+				if err != nil {
+					return guild, fmt.Errorf("failed to parse milestones: %w", err)
+				}
+				milestones.At = append(milestones.At, count)
+			}
+			guild.Milestones = &milestones
+		case strings.HasPrefix(key, templateSettingPrefix):
+			templates := templateConfig{}
+			for eventType, source := range guild.Templates {
+				templates[eventType] = source
+			}
+			templates[strings.TrimPrefix(key, templateSettingPrefix)] = settings[key]
+			guild.Templates = templates
+		default:
+			return guild, fmt.Errorf("unknown setting '%v'", key)
+		}
+	}
+	return guild, nil
+}
+
+func (cfg *config) copyQuietHours(guild guildConfig) *quietHoursConfig {
+	quietHours := quietHoursConfig{}
+	if guild.QuietHours != nil {
+		quietHours = *guild.QuietHours
+	} else if cfg.QuietHours != nil {
+		quietHours = *cfg.QuietHours
+	}
+	return &quietHours
+}
+
+func (cfg *config) copyMassLeave(guild guildConfig) *massLeaveConfig {
+	massLeave := massLeaveConfig{}
+	if guild.MassLeave != nil {
+		massLeave = *guild.MassLeave
+	} else if cfg.MassLeave != nil {
+		massLeave = *cfg.MassLeave
+	}
+	return &massLeave
+}
+
+func (cfg *config) copyLeaveSurvey(guild guildConfig) *surveyConfig {
+	leaveSurvey := surveyConfig{}
+	if guild.LeaveSurvey != nil {
+		leaveSurvey = *guild.LeaveSurvey
+	} else if cfg.LeaveSurvey != nil {
+		leaveSurvey = *cfg.LeaveSurvey
+	}
+	return &leaveSurvey
+}
+
+// splitList splits a comma-separated setting, an empty value is an empty list
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// guildConfigurer applies the config file and the runtime settings of each guild
+type guildConfigurer struct {
+	store   *store.Store
+	session *discordgo.Session
+	bot     *bot.Bot
+
+	lock sync.Mutex
+	cfg  *config
+}
+
+// setConfig replaces the config file options, it must already be validated
+func (c *guildConfigurer) setConfig(cfg *config) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// dry runs also turn off push notifications and reports, which are only set up at startup
+	cfg.DryRun = c.cfg.DryRun
+	c.cfg = cfg
+}
+
+// configure applies the options of a guild, returning an error if its runtime settings are invalid
+func (c *guildConfigurer) configure(guildID string) error {
+	c.lock.Lock()
+	cfg := c.cfg
+	c.lock.Unlock()
+
+	g, ok := c.bot.Guild(guildID)
+	if !ok {
+		return fmt.Errorf("guild '%v' isn't tracked", guildID)
+	}
+	var guild guildConfig
+	found := false
+	for _, configured := range cfg.Guilds {
+		if configured.ID == guildID {
+			guild, found = configured, true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("guild '%v' isn't configured", guildID)
+	}
+
+	guild, err := cfg.withStoredSettings(c.store, guild)
+	if err != nil {
+		return err
+	}
+	configureGuild(g, c.session, cfg, guild)
+	return nil
+}
+
+// withStoredSettings applies the runtime settings stored for a guild, failing if they are invalid
+func (cfg *config) withStoredSettings(st *store.Store, guild guildConfig) (guildConfig, error) {
+	settings, err := st.GuildSettings(guild.ID)
+	if err != nil {
+		return guild, err
+	}
+	guild, err = cfg.withSettings(guild, settings)
+	if err != nil {
+		return guild, err
+	}
+	if guild.ChannelID == "" {
+		return guild, errors.New("require a channel_id")
+	}
+	return guild, cfg.validateGuild(guild)
+}
+
+// configureAll applies the options of every configured guild, falling back to the config file for guilds with invalid runtime settings
+func (c *guildConfigurer) configureAll() {
+	c.lock.Lock()
+	cfg := c.cfg
+	c.lock.Unlock()
+
+	for _, guild := range cfg.Guilds {
+		g, ok := c.bot.Guild(guild.ID)
+		if !ok {
+			continue
+		}
+		if err := c.configure(guild.ID); err != nil {
+			log.Printf("ignoring runtime settings of guild '%v': %v", guild.ID, err)
+			configureGuild(g, c.session, cfg, guild)
+		}
+	}
+}
+
+// settingNames lists the settings /userlog config accepts
+func settingNames() []string {
+	names := []string{
+		"channel_id", "alert_channel_id", "voice_channel_id", "fallback_channel_id", "autorole_id", "watch_role_id", "ignored_users", "anniversary_opt_out", "announce", "leave_roles", "edit_leaves", "sync_summary",
+		"hook", "filter", "language", "timezone", "thread_mode", "thread_timezone",
+		"quiet_hours", "quiet_hours_timezone", "mass_leave_count", "mass_leave_window", "leave_survey", "leave_survey_reasons", "quick_actions",
+		"milestone_every", "milestones",
+	}
+	templates := []string{}
+	for eventType := range notify.DefaultTemplates {
+		templates = append(templates, templateSettingPrefix+eventType)
+	}
+	sort.Strings(templates)
+	return append(names, templates...)
+}
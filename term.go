@@ -0,0 +1,518 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.albinodrought/discord-user-log/internal/avatars"
+	"go.albinodrought/discord-user-log/internal/bot"
+	"go.albinodrought/discord-user-log/internal/bus"
+	"go.albinodrought/discord-user-log/internal/feed"
+	"go.albinodrought/discord-user-log/internal/store"
+	"go.albinodrought/discord-user-log/internal/systemd"
+	"go.albinodrought/discord-user-log/internal/web"
+)
+
+// term is what runs while this instance leads: the bots, the dashboard, and the scheduled work.
+// Without a leader lease there is a single term until exiting, with one a leader that loses the lease stops its term and stands by.
+type term struct {
+	cfg        *config
+	configPath string
+	st         *store.Store
+	eventBus   *bus.Bus
+
+	// runners are the running bots by tenant name, a single one named "" without tenant mode
+	runners map[string]*runner
+	server  *http.Server
+	// failed counts the tenants that failed to start, retry starts them again
+	failed int
+	retry  *time.Ticker
+
+	// ctx is canceled when the term stops, stopping syncs, reports, and dashboard requests
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// tenantRetryInterval is how often tenants that failed to start are tried again
+const tenantRetryInterval = time.Minute
+
+// runner is a running bot: its shards, its guilds, and the syncs and scheduled work of its store
+type runner struct {
+	// tenant is empty without tenant mode
+	tenant     store.Tenant
+	store      *store.Store
+	shards     *bot.Shards
+	bot        *bot.Bot
+	configurer *guildConfigurer
+	syncTimer  *time.Ticker
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// startTerm connects to Discord and starts tracking the configured guilds, or the guilds of every tenant in tenant mode.
+// The config must already be validated.
+func startTerm(cfg *config, configPath string, st *store.Store, eventBus *bus.Bus, events *feed.Broker) *term {
+	t := &term{cfg: cfg, configPath: configPath, st: st, eventBus: eventBus, runners: map[string]*runner{}}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+
+	// a standby may have reloaded the config since the last term
+	applyConfig(cfg, eventBus)
+
+	if cfg.Tenants {
+		t.retry = time.NewTicker(tenantRetryInterval)
+		t.reconcileTenants(cfg)
+		log.Printf("Running %v tenants", len(t.runners))
+		notifySystemd(systemd.Ready)
+		return t
+	}
+
+	// rows stored before multi-guild support have no guild, they belong to the first configured one
+	if err := st.AdoptLegacyRows(cfg.Guilds[0].ID); err != nil {
+		log.Fatalf("failed to assign legacy rows to guild '%v': %v", cfg.Guilds[0].ID, err)
+	}
+	r, err := t.startRunner(cfg, st, store.Tenant{}, func(shards *bot.Shards) error {
+		return reloadToken(configPath, shards)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	t.runners[""] = r
+	session := r.shards.Primary()
+
+	if cfg.Web.Listen != "" {
+		location, _ := cfg.timezoneFor(guildConfig{})
+		dashboard, err := web.New(web.Options{
+			BaseURL:      cfg.Web.BaseURL,
+			ClientID:     cfg.Web.ClientID,
+			ClientSecret: cfg.Web.ClientSecret,
+			GuildRoles:   cfg.dashboardRoles(),
+			Events:       events,
+			EventsToken:  cfg.Web.EventsToken,
+			PublicStats:  cfg.Web.PublicStats,
+			Location:     location,
+		}, st, session)
+		if err != nil {
+			log.Fatalf("failed to create dashboard: %v", err)
+		}
+		t.server = &http.Server{
+			Addr:    cfg.Web.Listen,
+			Handler: dashboard.Handler(),
+			// event streams only end when their request is canceled
+			BaseContext: func(net.Listener) context.Context { return t.ctx },
+		}
+		server := t.server
+		go func() {
+			log.Printf("Serving dashboard on %v", cfg.Web.Listen)
+			if err := server.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	if cfg.Report.SMTPAddr != "" && !cfg.DryRun {
+		go newReporter(cfg, st, session).Run(t.ctx)
+	}
+
+	if cfg.Maintenance.Window != "" {
+		go newMaintainer(cfg, st, session).Run(t.ctx)
+	}
+	return t
+}
+
+// startRunner connects a bot to Discord with the token and guilds of cfg, recording into st.
+// It publishes to the event bus without tenant mode, tenants' events aren't published.
+func (t *term) startRunner(cfg *config, st *store.Store, tenant store.Tenant, reload func(*bot.Shards) error) (*runner, error) {
+	r := &runner{tenant: tenant, store: st}
+	r.ctx, r.cancel = context.WithCancel(t.ctx)
+
+	// the members intent is privileged, the guilds intent lets the state cache tell which voice channel members leave or move from
+	intents := discordgo.IntentsGuildMembers | discordgo.IntentsGuilds | discordgo.IntentsGuildVoiceStates | discordgo.IntentsGuildBans
+	if cfg.TrackPresence {
+		// privileged too
+		intents |= discordgo.IntentsGuildPresences
+	}
+	if cfg.TrackFirstMessages {
+		// only when messages are posted, not their content
+		intents |= discordgo.IntentsGuildMessages
+	}
+	if cfg.TrackScheduledEvents {
+		intents |= discordgo.IntentsGuildScheduledEvents
+	}
+	if cfg.TrackInvites {
+		intents |= discordgo.IntentsGuildInvites
+	}
+	shards, err := bot.NewShards(cfg.Token, cfg.ShardCount, intents)
+	if err != nil {
+		r.cancel()
+		return nil, fmt.Errorf("failed to create discord sessions: %w", err)
+	}
+	r.shards = shards
+	// requests that aren't about a guild can use any shard
+	session := shards.Primary()
+
+	location, _ := cfg.timezoneFor(guildConfig{})
+	options := bot.Options{
+		GatewaySync:        cfg.SyncMode == syncModeGateway,
+		Location:           location,
+		TrackPresence:      cfg.TrackPresence,
+		TrackFirstMessages: cfg.TrackFirstMessages,
+	}
+	options.TrackScheduledEvents = cfg.TrackScheduledEvents
+	options.TrackInvites = cfg.TrackInvites
+	options.EventWorkers = cfg.EventWorkers
+	options.EventQueueSize = cfg.EventQueueSize
+	options.CrossGuildWindow, _ = parseDuration(cfg.CrossGuildWindow)
+	options.Presence, _ = bot.ParsePresence(cfg.Presence.Template)
+	options.PresenceInterval, _ = parseDuration(cfg.Presence.Interval)
+	if cfg.AvatarArchive != "" {
+		options.AvatarArchive = avatars.New(cfg.AvatarArchive)
+	}
+	r.configurer = &guildConfigurer{store: st, session: session, cfg: cfg}
+	options.Reconfigure = r.configurer.configure
+	options.ReloadToken = func() error {
+		return reload(shards)
+	}
+	options.OwnerID = cfg.OwnerID
+	options.SettingNames = settingNames()
+	if tenant.Name == "" {
+		options.Publisher = t.eventBus
+	}
+	r.bot = bot.New(st, options)
+	r.configurer.bot = r.bot
+	for _, guild := range cfg.Guilds {
+		if _, err := r.bot.AddGuild(guild.ID); err != nil {
+			r.cancel()
+			r.bot.Close(context.Background())
+			return nil, fmt.Errorf("failed to load members of guild '%v': %w", guild.ID, err)
+		}
+	}
+	r.configurer.configureAll()
+	r.bot.AddHandlers(shards)
+	shards.OnReady(func() {
+		if tenant.Name != "" {
+			log.Printf("Tenant '%v' connected to the gateway", tenant.Name)
+			return
+		}
+		log.Println("Connected to the gateway")
+		notifySystemd(systemd.Ready)
+	})
+
+	if shards.Count() > 1 {
+		log.Printf("Connecting %v shards", shards.Count())
+	}
+	if err := shards.Open(); err != nil {
+		r.cancel()
+		r.bot.Close(context.Background())
+		return nil, fmt.Errorf("failed to open discord session: %w", err)
+	}
+
+	pruneHistory(st)
+	anonymizeHistory(st)
+	go func() {
+		timer := time.NewTicker(24 * time.Hour)
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+				pruneHistory(st)
+				anonymizeHistory(st)
+			case <-r.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	syncInterval, _ := parseDuration(cfg.SyncInterval)
+	r.syncTimer = time.NewTicker(syncInterval)
+	go func() {
+		log.Printf("Syncing members%v from server", r.of())
+		r.bot.SyncAll(r.ctx, shards)
+		for {
+			select {
+			case <-r.syncTimer.C:
+				if slices := int(atomic.LoadInt64(&syncSlices)); slices > 1 {
+					log.Printf("Performing scheduled incremental sync%v", r.of())
+					r.bot.SyncIncremental(r.ctx, shards, slices)
+					continue
+				}
+				log.Printf("Performing scheduled sync%v", r.of())
+				r.bot.SyncAll(r.ctx, shards)
+			case <-r.ctx.Done():
+				return
+			}
+		}
+	}()
+	return r, nil
+}
+
+// of names the tenant of the runner in logs, it is empty without tenant mode
+func (r *runner) of() string {
+	if r.tenant.Name == "" {
+		return ""
+	}
+	return fmt.Sprintf(" of tenant '%v'", r.tenant.Name)
+}
+
+// tenantConfig is cfg with the token, owner, guilds, and avatar archive of a tenant, the config of a single bot
+func tenantConfig(cfg *config, tenant store.Tenant) *config {
+	tenantCfg := *cfg
+	tenantCfg.Tenants, tenantCfg.Tenant = false, ""
+	tenantCfg.Token = tenant.Token
+	tenantCfg.OwnerID = tenant.OwnerID
+	tenantCfg.Guilds = make([]guildConfig, len(tenant.Guilds))
+	for i, guild := range tenant.Guilds {
+		tenantCfg.Guilds[i] = guildConfig{ID: guild.ID, ChannelID: guild.ChannelID}
+	}
+	if cfg.AvatarArchive != "" {
+		tenantCfg.AvatarArchive = filepath.Join(cfg.AvatarArchive, tenant.Name)
+	}
+	return &tenantCfg
+}
+
+// startTenant opens the database of a tenant and starts its bot
+func (t *term) startTenant(cfg *config, tenant store.Tenant) (*runner, error) {
+	if len(tenant.Guilds) == 0 {
+		return nil, errors.New("it has no guilds")
+	}
+	st, err := store.OpenTenant(cfg.StatePath, cfg.DBKey, tenant.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open its database: %w", err)
+	}
+	r, err := t.startRunner(tenantConfig(cfg, tenant), st, tenant, func(shards *bot.Shards) error {
+		return reloadTenantToken(t.st, tenant.Name, shards)
+	})
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// reconcileTenants brings the running bots in line with the tenants in the database: it starts the bots of added tenants,
+// stops the ones of removed tenants, and restarts the ones whose owner or guilds changed.
+// The other bots switch to a changed token and apply cfg. Tenants failing to start are tried again on the next reload,
+// or within tenantRetryInterval.
+func (t *term) reconcileTenants(cfg *config) {
+	tenants, err := t.st.Tenants()
+	if err != nil {
+		log.Printf("failed to load the tenants, keeping the running ones: %v", err)
+		return
+	}
+
+	removed := make(map[string]*runner, len(t.runners))
+	for name, r := range t.runners {
+		removed[name] = r
+	}
+	starting := []store.Tenant{}
+	for _, tenant := range tenants {
+		r, ok := t.runners[tenant.Name]
+		delete(removed, tenant.Name)
+		if !ok {
+			starting = append(starting, tenant)
+			continue
+		}
+		if r.tenant.OwnerID != tenant.OwnerID || !reflect.DeepEqual(r.tenant.Guilds, tenant.Guilds) {
+			log.Printf("The owner or guilds of tenant '%v' changed, restarting it", tenant.Name)
+			t.stopTenant(r)
+			starting = append(starting, tenant)
+			continue
+		}
+		if err := r.shards.Rotate(tenant.Token); err != nil && !errors.Is(err, bot.ErrTokenUnchanged) {
+			log.Printf("failed to switch tenant '%v' to its new token, restarting it: %v", tenant.Name, err)
+			t.stopTenant(r)
+			starting = append(starting, tenant)
+			continue
+		} else if err == nil {
+			log.Printf("Switched tenant '%v' to its new token, reconnecting its shards", tenant.Name)
+		}
+		r.tenant = tenant
+		r.reconfigure(tenantConfig(cfg, tenant))
+	}
+	for name, r := range removed {
+		log.Printf("Tenant '%v' was removed, stopping it", name)
+		t.stopTenant(r)
+	}
+	t.startTenants(cfg, starting)
+}
+
+// retryTenants starts the bots of tenants that aren't running, leaving the running ones alone
+func (t *term) retryTenants(cfg *config) {
+	tenants, err := t.st.Tenants()
+	if err != nil {
+		log.Printf("failed to load the tenants: %v", err)
+		return
+	}
+	starting := []store.Tenant{}
+	for _, tenant := range tenants {
+		if _, ok := t.runners[tenant.Name]; !ok {
+			starting = append(starting, tenant)
+		}
+	}
+	t.startTenants(cfg, starting)
+}
+
+// startTenants starts the bots of tenants at the same time, counting the ones that failed to start
+func (t *term) startTenants(cfg *config, starting []store.Tenant) {
+	// tenants connect with tokens of their own, Discord limits identifying per bot
+	started := make(chan *runner, len(starting))
+	var wg sync.WaitGroup
+	for _, tenant := range starting {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := t.startTenant(cfg, tenant)
+			if err != nil {
+				log.Printf("failed to start tenant '%v', trying again later: %v", tenant.Name, err)
+				return
+			}
+			started <- r
+		}()
+	}
+	wg.Wait()
+	close(started)
+	t.failed = len(starting) - len(started)
+	for r := range started {
+		t.runners[r.tenant.Name] = r
+	}
+}
+
+// stopTenant stops the bot of a tenant and closes its database, the other tenants keep running
+func (t *term) stopTenant(r *runner) {
+	delete(t.runners, r.tenant.Name)
+	r.syncTimer.Stop()
+	ctx, done := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer done()
+	r.cancel()
+	if err := r.bot.Close(ctx); err != nil {
+		log.Printf("gave up waiting for events of tenant '%v' being handled: %v", r.tenant.Name, err)
+	}
+	r.shards.Close()
+	r.store.Close()
+}
+
+// reconfigure applies a changed config to a running bot without reconnecting or re-syncing.
+// Guilds can't be added or removed without a restart.
+func (r *runner) reconfigure(cfg *config) {
+	r.configurer.setConfig(cfg)
+	r.configurer.configureAll()
+
+	configured := make(map[string]struct{}, len(cfg.Guilds))
+	for _, guild := range cfg.Guilds {
+		configured[guild.ID] = struct{}{}
+		if _, ok := r.bot.Guild(guild.ID); !ok {
+			log.Printf("guild '%v' was added to the config, restart to start tracking it", guild.ID)
+		}
+	}
+	for _, guildID := range r.bot.GuildIDs() {
+		if _, ok := configured[guildID]; !ok {
+			log.Printf("guild '%v' was removed from the config, restart to stop tracking it", guildID)
+		}
+	}
+
+	syncInterval, _ := parseDuration(cfg.SyncInterval)
+	r.syncTimer.Reset(syncInterval)
+}
+
+// shards are the shards of the bot without tenant mode, nil in tenant mode
+func (t *term) shards() *bot.Shards {
+	if r, ok := t.runners[""]; ok {
+		return r.shards
+	}
+	return nil
+}
+
+// run handles signals until one stops the instance, returning true, or until the leader lease is lost, returning false.
+// In tenant mode SIGUSR1 applies the changes to the tenants in the database, SIGHUP also reloads the config.
+func (t *term) run(sc <-chan os.Signal, lost <-chan error) bool {
+	var retry <-chan time.Time
+	if t.retry != nil {
+		retry = t.retry.C
+	}
+	for {
+		select {
+		case <-retry:
+			if t.failed > 0 {
+				t.retryTenants(t.cfg)
+			}
+		case err := <-lost:
+			log.Printf("lost the leader lease, stepping down to let the new leader take over: %v", err)
+			return false
+		case sig := <-sc:
+			if sig == syscall.SIGUSR1 && t.cfg.Tenants {
+				log.Println("Reloading the tenants")
+				t.reconcileTenants(t.cfg)
+				continue
+			}
+			if sig == syscall.SIGUSR1 {
+				log.Println("Reloading the token")
+				if err := reloadToken(t.configPath, t.shards()); errors.Is(err, bot.ErrTokenUnchanged) {
+					log.Println("the token didn't change")
+				} else if err != nil {
+					log.Printf("failed to reload the token, keeping the old one: %v", err)
+				}
+				continue
+			}
+			if sig != syscall.SIGHUP {
+				return true
+			}
+			log.Println("Reloading config")
+			notifySystemd(systemd.Reloading)
+			cfg, err := reloadConfig(t.configPath)
+			if err == nil && cfg.Tenants != t.cfg.Tenants {
+				err = errors.New("switching tenant mode requires a restart")
+			}
+			if err != nil {
+				log.Printf("failed to reload config, keeping the old one: %v", err)
+			} else {
+				applyConfig(cfg, t.eventBus)
+				if cfg.Tenants {
+					t.reconcileTenants(cfg)
+				} else {
+					t.runners[""].reconfigure(cfg)
+				}
+				t.cfg = cfg
+				log.Println("Reloaded config")
+			}
+			notifySystemd(systemd.Ready)
+		}
+	}
+}
+
+// stop shuts the term down and disconnects from Discord, returning the latest config for the next term
+func (t *term) stop() *config {
+	if t.retry != nil {
+		t.retry.Stop()
+	}
+	bots := make([]*bot.Bot, 0, len(t.runners))
+	for _, r := range t.runners {
+		r.syncTimer.Stop()
+		bots = append(bots, r.bot)
+	}
+	shutdown(t.cancel, bots, t.server)
+	for name, r := range t.runners {
+		r.shards.Close()
+		if name != "" {
+			r.store.Close()
+		}
+	}
+
+	latest := *t.cfg
+	if shards := t.shards(); shards != nil {
+		// the token may have been rotated since the config was loaded
+		latest.Token = shards.Token()
+	}
+	return &latest
+}